@@ -0,0 +1,50 @@
+package smartturn
+
+import "github.com/cortexswarm/smart-turn-go/features"
+
+// VADBackend computes a speech probability per chunk. The default backend is
+// Silero VAD on ONNX Runtime; alternatives (pure-Go models, remote inference)
+// can be supplied through Config.VADBackend. Implementations are stateful and
+// are only called from the engine's goroutine.
+type VADBackend interface {
+	// SpeechProb returns the speech probability in [0, 1] for one chunk of
+	// Config.ChunkSize samples at Config.SampleRate (256 at 8 kHz).
+	SpeechProb(chunk []float32) (float32, error)
+	// Reset clears recurrent state (called by Engine.Reset).
+	Reset()
+	// Close releases resources; the engine calls it from Close.
+	Close() error
+}
+
+// TurnBackend scores turn completion. The engine computes the model input
+// (Whisper log-mel, shape (80, 800), row-major, for the 8s window; see
+// TurnFeatureSize, or Config.SmartTurnFeatures.Shape() when overridden) and
+// the backend returns the completion probability in [0, 1].
+// The default backend is the Smart-Turn ONNX model on ONNX Runtime; custom
+// backends are supplied through Config.TurnBackend.
+type TurnBackend interface {
+	Predict(features []float32) (float32, error)
+	Close() error
+}
+
+// TurnFeatureSize is the length of the features slice passed to a custom
+// TurnBackend with the default feature parameters: 80 mel bins × 800 frames.
+const TurnFeatureSize = features.NMels * features.Frames
+
+// modelBackend is implemented by backends that load the Smart-Turn model
+// themselves and so know its input convention.
+type modelBackend interface {
+	smartTurnModel() smartTurnModel
+}
+
+// rawOutputBackend is implemented by backends that keep the raw model
+// outputs of their last Predict.
+type rawOutputBackend interface {
+	lastOutputs() (logit float32, aux []ModelOutput)
+}
+
+// featureBuffer is implemented by backends that can expose their input
+// storage, letting the engine write features in place instead of copying.
+type featureBuffer interface {
+	featureBuffer() []float32
+}
@@ -0,0 +1,307 @@
+package smartturn
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go/features"
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// Defaults for BatchOptions.
+const (
+	DefaultBatchSize   = 16
+	DefaultBatchWindow = 5 * time.Millisecond
+)
+
+// ErrBatcherClosed is returned by Predict after TurnBatcher.Close.
+var ErrBatcherClosed = errors.New("smart-turn: batcher is closed")
+
+// BatchOptions configures a TurnBatcher.
+type BatchOptions struct {
+	// MaxBatch is the most requests run in one inference (default 16).
+	MaxBatch int
+	// Window is how long the first request of a batch waits for others
+	// (default 5ms); it adds at most that much to each turn decision.
+	Window time.Duration
+
+	// Provider, SessionOptions, and Features are as Config.SmartTurnProvider,
+	// Config.SmartTurnSessionOptions, and Config.SmartTurnFeatures.
+	Provider       ExecutionProvider
+	SessionOptions SessionOptions
+	Features       features.Params
+
+	// ONNXRuntimeLibPath is as Config.ONNXRuntimeLibPath.
+	ONNXRuntimeLibPath string
+}
+
+// TurnBatcher runs the Smart-Turn model for many engines, coalescing
+// requests that arrive within BatchOptions.Window into one batched ONNX
+// Runtime call. On a GPU a batch costs about as much as a single input, so
+// this multiplies throughput when many sessions end turns together. Each
+// engine gets its own handle from Backend; the batcher outlives them and is
+// closed separately. The model must be a mel (v3) export with a dynamic
+// batch dimension.
+type TurnBatcher struct {
+	opts  BatchOptions
+	model smartTurnModel
+
+	requests chan *batchRequest
+	done     chan struct{}
+
+	mu     sync.RWMutex // guards closed against in-flight submits
+	closed bool
+
+	// infer runs one batch; set to the ORT session, replaceable for tests.
+	infer func(batch []*batchRequest) error
+	ort   *ortBatchSession
+
+	fallbackErr error
+}
+
+// batchRequest is one engine's pending Predict. Each handle reuses its own.
+type batchRequest struct {
+	features []float32
+	prob     float32
+	err      error
+	ready    chan struct{}
+}
+
+// NewTurnBatcher loads modelPath once for batched inference.
+func NewTurnBatcher(modelPath string, opts BatchOptions) (*TurnBatcher, error) {
+	if opts.MaxBatch < 0 || opts.Window < 0 {
+		return nil, errors.New("smart-turn: BatchOptions.MaxBatch and Window must be >= 0")
+	}
+	if err := validateProvider(opts.Provider); err != nil {
+		return nil, err
+	}
+	if err := validateSessionOptions("SessionOptions", opts.SessionOptions); err != nil {
+		return nil, err
+	}
+	libPath := opts.ONNXRuntimeLibPath
+	if libPath == "" {
+		libPath = runtimeLibPath(Config{})
+	}
+	if err := acquireRuntime(libPath); err != nil {
+		return nil, err
+	}
+	model, err := detectSmartTurnModel(modelPath, opts.Features)
+	if err == nil && model.input != smartTurnMel {
+		err = errors.New("smart-turn: batching requires a mel (v3) model")
+	}
+	var s *ortBatchSession
+	var fallbackErr error
+	if err == nil {
+		s, fallbackErr, err = newORTBatchSession(modelPath, model, opts)
+	}
+	if err != nil {
+		_ = releaseRuntime()
+		return nil, err
+	}
+	b := newTurnBatcher(model, opts, s.run)
+	b.ort = s
+	b.fallbackErr = fallbackErr
+	return b, nil
+}
+
+func newTurnBatcher(model smartTurnModel, opts BatchOptions, infer func([]*batchRequest) error) *TurnBatcher {
+	if opts.MaxBatch == 0 {
+		opts.MaxBatch = DefaultBatchSize
+	}
+	if opts.Window == 0 {
+		opts.Window = DefaultBatchWindow
+	}
+	b := &TurnBatcher{
+		opts:     opts,
+		model:    model,
+		requests: make(chan *batchRequest, 4*opts.MaxBatch),
+		done:     make(chan struct{}),
+		infer:    infer,
+	}
+	go b.run()
+	return b
+}
+
+// FallbackErr reports why the requested provider was unavailable when
+// BatchOptions.Provider.FallbackToCPU moved the session to CPU; nil otherwise.
+func (b *TurnBatcher) FallbackErr() error { return b.fallbackErr }
+
+// Backend returns a TurnBackend for one engine (Config.TurnBackend). Its
+// Close, called by the engine, does not close the batcher.
+func (b *TurnBatcher) Backend() TurnBackend {
+	return &batchBackend{b: b, req: batchRequest{ready: make(chan struct{}, 1)}}
+}
+
+// Close finishes the requests already queued, fails later ones with
+// ErrBatcherClosed, and releases the session. Close the engines using the
+// batcher first.
+func (b *TurnBatcher) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.requests)
+	b.mu.Unlock()
+	<-b.done
+	if b.ort == nil {
+		return nil
+	}
+	err := b.ort.close()
+	if rerr := releaseRuntime(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+func (b *TurnBatcher) submit(r *batchRequest) error {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrBatcherClosed
+	}
+	b.requests <- r
+	b.mu.RUnlock()
+	<-r.ready
+	return r.err
+}
+
+// run collects batches: it blocks for a first request, then takes more
+// until MaxBatch or Window has passed.
+func (b *TurnBatcher) run() {
+	defer close(b.done)
+	batch := make([]*batchRequest, 0, b.opts.MaxBatch)
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	for first := range b.requests {
+		batch = append(batch[:0], first)
+		timer.Reset(b.opts.Window)
+	collect:
+		for len(batch) < b.opts.MaxBatch {
+			select {
+			case r, ok := <-b.requests:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, r)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+		err := b.infer(batch)
+		for _, r := range batch {
+			if err != nil {
+				r.err = err
+			}
+			r.ready <- struct{}{}
+		}
+		clear(batch)
+	}
+}
+
+// batchBackend is one engine's handle on a TurnBatcher.
+type batchBackend struct {
+	b   *TurnBatcher
+	req batchRequest
+}
+
+// Predict queues features for the next batch and waits for its result.
+func (h *batchBackend) Predict(features []float32) (float32, error) {
+	if len(features) != h.b.model.params.Shape().Mels*h.b.model.params.Shape().Frames {
+		return 0, errInvalidSegment
+	}
+	h.req.features, h.req.prob, h.req.err = features, 0, nil
+	if err := h.b.submit(&h.req); err != nil {
+		return 0, err
+	}
+	return h.req.prob, nil
+}
+
+// Close implements TurnBackend; the batcher stays open.
+func (h *batchBackend) Close() error { return nil }
+
+// smartTurnModel lets the engine use the batcher's feature parameters.
+func (h *batchBackend) smartTurnModel() smartTurnModel { return h.b.model }
+
+// ortBatchSession is a dynamic-shape session with input/output tensors
+// created per batch size on first use.
+type ortBatchSession struct {
+	session *ort.DynamicAdvancedSession
+	model   smartTurnModel
+	inputs  []*ort.Tensor[float32] // index n-1 holds batch size n
+	outputs []*ort.Tensor[float32]
+}
+
+func newORTBatchSession(modelPath string, model smartTurnModel, opts BatchOptions) (*ortBatchSession, error, error) {
+	if model.batch > 0 {
+		return nil, nil, fmt.Errorf("smart-turn: model has a fixed batch size of %d; batching needs a dynamic batch dimension", model.batch)
+	}
+	sess, fallbackErr, err := withProviderFallback(opts.SessionOptions, opts.Provider, func(o *ort.SessionOptions) (*ort.DynamicAdvancedSession, error) {
+		return ort.NewDynamicAdvancedSession(modelPath, []string{model.inputName}, []string{model.outputName}, o)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	maxBatch := opts.MaxBatch
+	if maxBatch == 0 {
+		maxBatch = DefaultBatchSize
+	}
+	return &ortBatchSession{
+		session: sess,
+		model:   model,
+		inputs:  make([]*ort.Tensor[float32], maxBatch),
+		outputs: make([]*ort.Tensor[float32], maxBatch),
+	}, fallbackErr, nil
+}
+
+// run copies the batch into its tensors and runs the session once.
+func (s *ortBatchSession) run(batch []*batchRequest) error {
+	n := len(batch)
+	if s.inputs[n-1] == nil {
+		p := s.model.params
+		in, err := ort.NewEmptyTensor[float32](ort.NewShape(int64(n), int64(p.NMels), int64(p.Frames)))
+		if err != nil {
+			return err
+		}
+		out, err := ort.NewEmptyTensor[float32](ort.NewShape(int64(n), 1))
+		if err != nil {
+			_ = in.Destroy()
+			return err
+		}
+		s.inputs[n-1], s.outputs[n-1] = in, out
+	}
+	in, out := s.inputs[n-1], s.outputs[n-1]
+	data := in.GetData()
+	size := len(data) / n
+	for i, r := range batch {
+		copy(data[i*size:(i+1)*size], r.features)
+	}
+	if err := s.session.Run([]ort.Value{in}, []ort.Value{out}); err != nil {
+		return err
+	}
+	probs := out.GetData()
+	for i, r := range batch {
+		r.prob = probs[i]
+	}
+	return nil
+}
+
+func (s *ortBatchSession) close() error {
+	err := s.session.Destroy()
+	for i := range s.inputs {
+		if s.inputs[i] == nil {
+			continue
+		}
+		if derr := s.inputs[i].Destroy(); err == nil {
+			err = derr
+		}
+		if derr := s.outputs[i].Destroy(); err == nil {
+			err = derr
+		}
+	}
+	return err
+}
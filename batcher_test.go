@@ -0,0 +1,175 @@
+package smartturn
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go/features"
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// fakeInfer records the batch sizes it is called with and answers each
+// request with its first feature.
+type fakeInfer struct {
+	mu    sync.Mutex
+	sizes []int
+	err   error
+}
+
+func (f *fakeInfer) run(batch []*batchRequest) error {
+	f.mu.Lock()
+	f.sizes = append(f.sizes, len(batch))
+	f.mu.Unlock()
+	for _, r := range batch {
+		r.prob = r.features[0]
+	}
+	return f.err
+}
+
+func newTestBatcher(t *testing.T, opts BatchOptions, f *fakeInfer) *TurnBatcher {
+	t.Helper()
+	p := features.Params{Frames: 10}.WithDefaults()
+	model := smartTurnModel{input: smartTurnMel, params: p, windowSamples: p.WindowSamples()}
+	b := newTurnBatcher(model, opts, f.run)
+	t.Cleanup(func() { _ = b.Close() })
+	return b
+}
+
+func batchFeatures(b *TurnBatcher, v float32) []float32 {
+	s := b.model.params.Shape()
+	x := make([]float32, s.Mels*s.Frames)
+	x[0] = v
+	return x
+}
+
+// TestTurnBatcherCoalesces checks that concurrent requests share one
+// inference once MaxBatch is reached and each gets its own result.
+func TestTurnBatcherCoalesces(t *testing.T) {
+	const n = 4
+	f := &fakeInfer{}
+	b := newTestBatcher(t, BatchOptions{MaxBatch: n, Window: time.Hour}, f)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			want := float32(i) / n
+			got, err := b.Backend().Predict(batchFeatures(b, want))
+			if err != nil || got != want {
+				t.Errorf("request %d: Predict = %v, %v; want %v", i, got, err, want)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(f.sizes) != 1 || f.sizes[0] != n {
+		t.Errorf("batch sizes %v, want [%d]", f.sizes, n)
+	}
+}
+
+// TestTurnBatcherWindow checks that a lone request runs once Window has
+// passed, and that a backend handle can be reused.
+func TestTurnBatcherWindow(t *testing.T) {
+	f := &fakeInfer{}
+	b := newTestBatcher(t, BatchOptions{MaxBatch: 8, Window: time.Millisecond}, f)
+	h := b.Backend()
+	for _, want := range []float32{0.25, 0.75} {
+		got, err := h.Predict(batchFeatures(b, want))
+		if err != nil || got != want {
+			t.Fatalf("Predict = %v, %v; want %v", got, err, want)
+		}
+	}
+	if len(f.sizes) != 2 || f.sizes[0] != 1 || f.sizes[1] != 1 {
+		t.Errorf("batch sizes %v, want [1 1]", f.sizes)
+	}
+}
+
+// TestTurnBatcherErrors checks that an inference error reaches every
+// request of the batch, that malformed features are rejected before
+// queueing, and that Predict fails after Close.
+func TestTurnBatcherErrors(t *testing.T) {
+	errInfer := errors.New("inference failed")
+	f := &fakeInfer{err: errInfer}
+	b := newTestBatcher(t, BatchOptions{MaxBatch: 2, Window: time.Hour}, f)
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := b.Backend().Predict(batchFeatures(b, 1)); !errors.Is(err, errInfer) {
+				t.Errorf("Predict error = %v, want %v", err, errInfer)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := b.Backend().Predict(make([]float32, 3)); !errors.Is(err, errInvalidSegment) {
+		t.Errorf("short features: error = %v, want %v", err, errInvalidSegment)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Backend().Predict(batchFeatures(b, 1)); !errors.Is(err, ErrBatcherClosed) {
+		t.Errorf("after Close: error = %v, want %v", err, ErrBatcherClosed)
+	}
+	if len(f.sizes) != 1 {
+		t.Errorf("batch sizes %v, want one batch", f.sizes)
+	}
+}
+
+// TestTurnBatcherBackend checks that a Smart-Turn stage built on a
+// batcher handle takes the batcher's feature parameters and infers
+// through it.
+func TestTurnBatcherBackend(t *testing.T) {
+	f := &fakeInfer{}
+	b := newTestBatcher(t, BatchOptions{}, f)
+	st, err := newCustomSmartTurn(b.Backend(), features.Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.destroy()
+	if st.model.params != b.model.params {
+		t.Fatalf("engine features %+v, want the batcher's %+v", st.model.params, b.model.params)
+	}
+	audio := make([]float32, RequiredSampleRate)
+	for i := range audio {
+		audio[i] = float32(i%100) / 200
+	}
+	if _, err := st.run(audio); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.sizes) != 1 {
+		t.Errorf("batch sizes %v, want one inference", f.sizes)
+	}
+}
+
+// TestProviderFallback checks that a provider that cannot load falls back
+// to CPU only with FallbackToCPU, reporting why.
+func TestProviderFallback(t *testing.T) {
+	ep := ExecutionProvider{Kind: ProviderNNAPI} // Android only
+	if err := providerSupported(ep.Kind); err == nil {
+		t.Skip("NNAPI is supported here")
+	}
+	var calls int
+	create := func(o *ort.SessionOptions) (int, error) {
+		calls++
+		if o != nil {
+			t.Error("CPU fallback got session options, want nil")
+		}
+		return 1, nil
+	}
+	sess, fallbackErr, err := withProviderFallback(SessionOptions{}, ep, create)
+	if err == nil || fallbackErr != nil || calls != 0 || sess != 0 {
+		t.Errorf("without FallbackToCPU: sess %v, fallbackErr %v, err %v, %d creates", sess, fallbackErr, err, calls)
+	}
+
+	ep.FallbackToCPU = true
+	sess, fallbackErr, err = withProviderFallback(SessionOptions{}, ep, create)
+	if err != nil || sess != 1 || calls != 1 {
+		t.Fatalf("with FallbackToCPU: sess %v, err %v, %d creates", sess, err, calls)
+	}
+	if !errors.Is(fallbackErr, ErrProviderUnavailable) {
+		t.Errorf("fallbackErr = %v, want ErrProviderUnavailable", fallbackErr)
+	}
+}
@@ -0,0 +1,180 @@
+package smartturn_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// The inference benchmarks need the models in models/ (as the examples
+// download them) and ONNX Runtime from EnvONNXRuntimeLib or the
+// onnxruntime_go default; they are skipped otherwise. One chunk is 32 ms
+// of audio, the real-time budget of a stream.
+
+// BenchmarkEnginePushPCM measures PushPCM over alternating 2 s speech and
+// 1 s silence with energy VAD and a scripted Smart-Turn backend, every
+// callback set: segmentation, callbacks and mel features for every end of
+// turn, without ONNX Runtime.
+func BenchmarkEnginePushPCM(b *testing.B) {
+	synth, _ := smartturntest.Synth{Seed: 1}.Generate(
+		smartturntest.Speech(2*time.Second), smartturntest.Silence(time.Second),
+		smartturntest.Speech(2*time.Second), smartturntest.Silence(time.Second))
+	chunks := smartturntest.Chunks(synth)
+	cfg := benchConfig()
+	cfg.VadStopMs = 300
+	cfg.TurnSegmentEmitMs = 500
+	cfg.VADBackend = &smartturntest.EnergyVAD{}
+	cfg.TurnBackend = &smartturntest.TurnScript{Probabilities: []float32{0.2, 0.9}}
+	engine, err := smartturn.New(cfg, smartturn.Callbacks{
+		OnSpeechStart:          func() {},
+		OnSpeechEnd:            func() {},
+		OnTurnEnd:              func(smartturn.TurnEndReason) {},
+		OnVadScore:             func(float32, int64) {},
+		OnChunk:                func([]float32) {},
+		OnSegmentReady:         func([]float32) {},
+		OnSegment:              func(seg *smartturn.Segment) { seg.Release() },
+		OnTurnPredictionDetail: func(smartturn.TurnPrediction) {},
+		OnError:                func(err error) { b.Error(err) },
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer engine.Close()
+	engine.Start()
+	// One pass over the audio warms the engine's buffers.
+	for _, c := range chunks {
+		if err := engine.PushPCM(c); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportAllocs()
+	i := 0
+	for b.Loop() {
+		if err := engine.PushPCM(chunks[i%len(chunks)]); err != nil {
+			b.Fatal(err)
+		}
+		i++
+	}
+}
+
+// BenchmarkSileroVAD measures one PushPCM of non-speech audio: Silero
+// plus segmentation.
+func BenchmarkSileroVAD(b *testing.B) {
+	engine, err := smartturn.New(modelConfig(b), smartturn.Callbacks{})
+	if err != nil {
+		b.Skip(err)
+	}
+	defer engine.Close()
+	engine.Start()
+	silence := make([]float32, smartturn.RequiredChunkSize)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := engine.PushPCM(silence); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSmartTurn measures the PushPCM call that ends a 3 s speech
+// segment, which runs the mel pipeline and Smart-Turn inference. Speech is
+// scripted with a VAD backend so the result does not depend on the signal.
+func BenchmarkSmartTurn(b *testing.B) {
+	cfg := modelConfig(b)
+	vad := &scriptedVAD{speech: 3 * smartturn.RequiredSampleRate / smartturn.RequiredChunkSize}
+	cfg.VADBackend = vad
+	engine, err := smartturn.New(cfg, smartturn.Callbacks{OnError: func(err error) { b.Error(err) }})
+	if err != nil {
+		b.Skip(err)
+	}
+	defer engine.Close()
+	engine.Start()
+	audio := voiced(10 * smartturn.RequiredSampleRate)
+	chunk := smartturn.RequiredChunkSize
+	stop := (cfg.VadStopMs*smartturn.RequiredSampleRate/1000 + chunk - 1) / chunk
+	pos := 0
+	push := func() {
+		if pos+chunk > len(audio) {
+			pos = 0
+		}
+		if err := engine.PushPCM(audio[pos : pos+chunk]); err != nil {
+			b.Fatal(err)
+		}
+		pos += chunk
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		b.StopTimer()
+		engine.Reset()
+		vad.n = 0
+		for range vad.speech + stop - 1 {
+			push()
+		}
+		b.StartTimer()
+		push() // ends the segment
+	}
+}
+
+func benchConfig() smartturn.Config {
+	return smartturn.Config{
+		SampleRate:             smartturn.RequiredSampleRate,
+		ChunkSize:              smartturn.RequiredChunkSize,
+		VadThreshold:           0.5,
+		VadPreSpeechMs:         200,
+		VadStopMs:              800,
+		TurnMaxDurationSeconds: 600,
+		TurnSegmentEmitMs:      1000,
+		TurnThreshold:          0.5,
+		TurnTimeoutMs:          1000,
+	}
+}
+
+// modelConfig returns benchConfig with the models in models/, skipping b
+// when they are missing.
+func modelConfig(b *testing.B) smartturn.Config {
+	cfg := benchConfig()
+	cfg.SileroVADModelPath = filepath.Join("models", "silero_vad.onnx")
+	cfg.SmartTurnModelPath = filepath.Join("models", "smart-turn-v3.2-cpu.onnx")
+	for _, p := range []string{cfg.SileroVADModelPath, cfg.SmartTurnModelPath} {
+		if _, err := os.Stat(p); err != nil {
+			b.Skipf("model not available: %v", err)
+		}
+	}
+	return cfg
+}
+
+// scriptedVAD reports speech for the first speech chunks, then silence.
+type scriptedVAD struct {
+	speech int
+	n      int
+}
+
+func (v *scriptedVAD) SpeechProb([]float32) (float32, error) {
+	v.n++
+	if v.n <= v.speech {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (v *scriptedVAD) Reset()       {}
+func (v *scriptedVAD) Close() error { return nil }
+
+// voiced is a voiced-like signal: harmonics of 150 Hz with a syllable envelope.
+func voiced(n int) []float32 {
+	out := make([]float32, n)
+	for i := range out {
+		t := float64(i) / smartturn.RequiredSampleRate
+		env := 0.5 * (1 + math.Sin(2*math.Pi*4*t))
+		var v float64
+		for k := 1; k < 10; k++ {
+			v += math.Sin(2*math.Pi*150*float64(k)*t) / float64(k)
+		}
+		out[i] = float32(0.2 * env * v)
+	}
+	return out
+}
@@ -0,0 +1,46 @@
+package smartturn
+
+import (
+	"errors"
+	"math"
+)
+
+// Calibration rescales Smart-Turn probabilities for teams that measured
+// miscalibration on their own audio. With logit the model's raw score, the
+// calibrated probability is
+//
+//	sigmoid(A*logit/Temperature + B)
+//
+// so Temperature alone is temperature scaling and A, B are Platt scaling
+// parameters fitted on held-out data. The zero value changes nothing.
+type Calibration struct {
+	// Temperature divides the logit; above 1 softens probabilities toward
+	// 0.5, below 1 sharpens them. 0 means 1.
+	Temperature float32
+	// A scales the tempered logit (0 means 1) and B shifts it.
+	A, B float32
+}
+
+func (c Calibration) identity() bool {
+	return (c.Temperature == 0 || c.Temperature == 1) && (c.A == 0 || c.A == 1) && c.B == 0
+}
+
+func validateCalibration(c Calibration) error {
+	if c.Temperature < 0 || c.A < 0 {
+		return errors.New("config: TurnCalibration.Temperature and A must be >= 0")
+	}
+	return nil
+}
+
+// apply returns the calibrated probability for a raw logit.
+func (c Calibration) apply(logit float32) float32 {
+	x := float64(logit)
+	if c.Temperature != 0 {
+		x /= float64(c.Temperature)
+	}
+	if c.A != 0 {
+		x *= float64(c.A)
+	}
+	x += float64(c.B)
+	return float32(1 / (1 + math.Exp(-x)))
+}
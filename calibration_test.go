@@ -0,0 +1,76 @@
+package smartturn_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// TestCalibration checks calibrated probabilities against
+// sigmoid(A*logit/Temperature + B) for a backend scoring 0.6.
+func TestCalibration(t *testing.T) {
+	raw := math.Log(0.6 / 0.4)
+	sigmoid := func(x float64) float32 { return float32(1 / (1 + math.Exp(-x))) }
+	for _, tc := range []struct {
+		calib smartturn.Calibration
+		want  float32
+	}{
+		{smartturn.Calibration{}, 0.6},
+		{smartturn.Calibration{Temperature: 1, A: 1}, 0.6},
+		{smartturn.Calibration{Temperature: 2}, sigmoid(raw / 2)},
+		{smartturn.Calibration{Temperature: 0.5}, sigmoid(raw * 2)},
+		{smartturn.Calibration{A: 0.5, B: 1}, sigmoid(raw*0.5 + 1)},
+		{smartturn.Calibration{Temperature: 2, A: 3, B: -1}, sigmoid(raw*3/2 - 1)},
+	} {
+		e := newTestEngine(t, []float32{0.6}, smartturn.Callbacks{}, func(cfg *smartturn.Config) { cfg.TurnCalibration = tc.calib })
+		p, err := e.PredictFeatures(make([]float32, smartturn.TurnFeatureSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(float64(p.Probability-tc.want)) > 1e-6 || p.Complete != (tc.want > 0.5) {
+			t.Errorf("%+v: probability %v, complete %v; want %v", tc.calib, p.Probability, p.Complete, tc.want)
+		}
+		if math.Abs(float64(p.Logit)-raw) > 1e-6 {
+			t.Errorf("%+v: Logit = %v, want the raw %v", tc.calib, p.Logit, raw)
+		}
+	}
+}
+
+// TestCalibrationDecision checks that the engine decides on the
+// calibrated probability.
+func TestCalibrationDecision(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 7}.Generate(smartturntest.Speech(time.Second), smartturntest.Silence(2*time.Second))
+	for _, tc := range []struct {
+		calib smartturn.Calibration
+		want  smartturn.TurnEndReason
+	}{
+		{smartturn.Calibration{}, smartturn.TurnEndModel},
+		{smartturn.Calibration{B: -1}, smartturn.TurnEndTimeout},
+	} {
+		var reasons []smartturn.TurnEndReason
+		var probs []float32
+		e := newTestEngine(t, []float32{0.6}, smartturn.Callbacks{
+			OnTurnEnd:        func(r smartturn.TurnEndReason) { reasons = append(reasons, r) },
+			OnTurnPrediction: func(_ bool, p float32) { probs = append(probs, p) },
+		}, func(cfg *smartturn.Config) { cfg.TurnCalibration = tc.calib })
+		pushAll(t, e, audio)
+		if len(reasons) != 1 || reasons[0] != tc.want {
+			t.Errorf("%+v: turn ends %v (probabilities %v), want %v", tc.calib, reasons, probs, tc.want)
+		}
+	}
+}
+
+func TestCalibrationValidate(t *testing.T) {
+	for _, c := range []smartturn.Calibration{{Temperature: -1}, {A: -0.5}} {
+		cfg := benchConfig()
+		cfg.VADBackend = &smartturntest.EnergyVAD{}
+		cfg.TurnBackend = &smartturntest.TurnScript{}
+		cfg.TurnCalibration = c
+		if err := smartturn.ValidateConfig(cfg); err == nil {
+			t.Errorf("%+v accepted", c)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package smartturn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	errCallChannels = errors.New("smart-turn: call channels differ in length")
+	errCallStereo   = errors.New("smart-turn: stereo audio must have an even number of samples")
+)
+
+// CallSpeaker is the speaker of one channel of a call recording.
+type CallSpeaker int
+
+const (
+	SpeakerCaller CallSpeaker = iota // left channel
+	SpeakerAgent                     // right channel
+)
+
+func (s CallSpeaker) String() string {
+	switch s {
+	case SpeakerCaller:
+		return "caller"
+	case SpeakerAgent:
+		return "agent"
+	default:
+		return "unknown"
+	}
+}
+
+// CallTurn is one turn on the timeline of a CallProcessor. Times are audio
+// time since the start of the call.
+type CallTurn struct {
+	Speaker CallSpeaker
+	ID      int           // TurnStart.ID in the speaker's session
+	Start   time.Duration // first sample, pre-speech padding included
+	// SpeechEnd is the end of the turn's last speech, and End the end of
+	// the chunk that ended the turn (about VadStopMs, or TurnTimeoutMs
+	// after an incomplete prediction, later). Both are zero while the
+	// turn is open.
+	SpeechEnd time.Duration
+	End       time.Duration
+	Reason    TurnEndReason
+	// Probability is the last Smart-Turn probability of the turn; 0 when
+	// none ran.
+	Probability float32
+	// Overlap is set when the turn started while the other speaker was
+	// speaking: a barge-in or cross-talk.
+	Overlap bool
+	// Merged is set when speech resumed within TurnMergeGapMs of the
+	// turn's end, so the turn was reopened and ends again.
+	Merged bool
+}
+
+// CallChannel configures the session of one speaker.
+type CallChannel struct {
+	// Overrides are the session's thresholds and timeouts; the zero value
+	// takes SessionGroup.Defaults.
+	Overrides SessionOverrides
+	// Callbacks are the session's own, e.g. OnSegmentReady to transcribe
+	// the speaker. They fire before the CallCallbacks of the same chunk.
+	Callbacks Callbacks
+}
+
+// CallCallbacks report the merged timeline of a CallProcessor as it grows.
+type CallCallbacks struct {
+	// OnTurnStart receives a turn when it opens, End unset.
+	OnTurnStart func(t CallTurn)
+	// OnTurnEnd receives a turn when it ends, and again, with Merged set,
+	// each time a merge reopens it.
+	OnTurnEnd func(t CallTurn)
+}
+
+// CallProcessor runs turn detection on two-channel call audio, one speaker
+// per channel as contact-center recorders write it, and merges the turns
+// of both into one timeline ordered by start. Each channel has its own
+// session of a SessionGroup, so the speakers do not affect each other's
+// VAD or turn state and the group's Smart-Turn model is shared with other
+// calls. The group's Config must not set InputQueue, and a VADBackend in
+// it would be shared by both sessions, so leave it unset.
+//
+// A CallProcessor is not safe for concurrent use; like Process, its
+// methods run the pipeline on the caller's goroutine.
+type CallProcessor struct {
+	sessions [2]*Engine
+	cb       CallCallbacks
+	chunk    int              // samples per channel per chunk
+	vadStop  [2]time.Duration // VadStopMs of each session
+	idle     time.Duration    // silence End pushes to close open turns
+
+	pending [2][]float32 // audio toward the next chunk
+	scratch [2][]float32 // PushStereo and ReadPCM16 channel buffers
+	frames  int64        // chunks processed per channel
+
+	turns    []CallTurn // ordered by Start
+	open     [2]int     // ID of each speaker's open turn, -1 for none
+	speaking [2]bool    // VAD speech active after the last chunk
+	segEnd   [2]time.Duration
+}
+
+// NewCallProcessor creates the caller and agent sessions from g.
+func NewCallProcessor(g *SessionGroup, caller, agent CallChannel, cb CallCallbacks) (*CallProcessor, error) {
+	if g.cfg.InputQueue.Size > 0 {
+		return nil, errProcessQueued
+	}
+	p := &CallProcessor{cb: cb, chunk: g.cfg.ChunkSize, open: [2]int{-1, -1}}
+	for i, ch := range []CallChannel{caller, agent} {
+		o := ch.Overrides
+		if o == (SessionOverrides{}) {
+			o = g.Defaults()
+		}
+		e, err := g.NewSession(o, ch.Callbacks)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		e.Start()
+		p.sessions[i] = e
+		p.vadStop[i] = time.Duration(o.VadStopMs) * time.Millisecond
+		if idle := time.Duration(o.VadStopMs+o.TurnTimeoutMs)*time.Millisecond + chunkDuration; idle > p.idle {
+			p.idle = idle
+		}
+	}
+	return p, nil
+}
+
+// Session returns the engine of speaker s, e.g. for Health or
+// MemoryStats. Feed audio through the CallProcessor only.
+func (p *CallProcessor) Session(s CallSpeaker) *Engine {
+	return p.sessions[s]
+}
+
+// Push processes the next audio of both channels, at Config.SampleRate
+// and of equal length; any length is accepted and assembled into chunks.
+// When a session fails a chunk, the other still processes it and the
+// timeline advances past it, so the channels stay aligned. The error is
+// returned, naming the channel, and the audio after that chunk stays
+// pending for the next Push.
+func (p *CallProcessor) Push(caller, agent []float32) error {
+	if len(caller) != len(agent) {
+		return errCallChannels
+	}
+	p.pending[0] = append(p.pending[0], caller...)
+	p.pending[1] = append(p.pending[1], agent...)
+	n := 0
+	for ; len(p.pending[0])-n >= p.chunk; n += p.chunk {
+		if err := p.frame(p.pending[0][n:n+p.chunk], p.pending[1][n:n+p.chunk]); err != nil {
+			p.consume(n + p.chunk)
+			return err
+		}
+	}
+	p.consume(n)
+	return nil
+}
+
+// consume drops the first n pending samples of each channel.
+func (p *CallProcessor) consume(n int) {
+	for i := range p.pending {
+		p.pending[i] = p.pending[i][:copy(p.pending[i], p.pending[i][n:])]
+	}
+}
+
+// PushStereo is Push for interleaved stereo samples: caller (left), agent
+// (right), caller, ...
+func (p *CallProcessor) PushStereo(samples []float32) error {
+	if len(samples)%2 != 0 {
+		return errCallStereo
+	}
+	n := len(samples) / 2
+	for i := range p.scratch {
+		if cap(p.scratch[i]) < n {
+			p.scratch[i] = make([]float32, n)
+		}
+		p.scratch[i] = p.scratch[i][:n]
+	}
+	for i := range n {
+		p.scratch[0][i], p.scratch[1][i] = samples[2*i], samples[2*i+1]
+	}
+	return p.Push(p.scratch[0], p.scratch[1])
+}
+
+// ReadPCM16 processes interleaved s16le stereo audio from r until EOF, as
+// ReadPCM16 does for mono, e.g.
+//
+//	ffmpeg -i call.wav -f s16le -ac 2 -ar 16000 - | app
+//
+// EOF returns nil with turns still open; call End at the end of the call.
+func (p *CallProcessor) ReadPCM16(r io.Reader) error {
+	buf := make([]byte, 4*p.chunk)
+	for i := range p.scratch {
+		p.scratch[i] = make([]float32, p.chunk)
+	}
+	n := 0 // bytes buffered toward the next chunk
+	for {
+		m, err := r.Read(buf[n:])
+		n += m
+		if n == len(buf) {
+			if perr := p.Push(decodeStereoPCM16(buf, p.scratch[0], p.scratch[1])); perr != nil {
+				return perr
+			}
+			n = 0
+		}
+		if err == nil {
+			continue
+		}
+		if frames := n / 4; frames > 0 {
+			// A partial trailing frame is dropped; End pads the rest.
+			caller, agent := decodeStereoPCM16(buf[:4*frames], p.scratch[0][:frames], p.scratch[1][:frames])
+			if perr := p.Push(caller, agent); perr != nil {
+				return perr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+}
+
+// decodeStereoPCM16 splits the s16le frames of b into caller and agent.
+func decodeStereoPCM16(b []byte, caller, agent []float32) ([]float32, []float32) {
+	for i := range caller {
+		caller[i] = float32(int16(binary.LittleEndian.Uint16(b[4*i:]))) / 32768
+		agent[i] = float32(int16(binary.LittleEndian.Uint16(b[4*i+2:]))) / 32768
+	}
+	return caller, agent
+}
+
+// End finishes the call: it pads the audio pushed so far to a whole chunk
+// and advances both sessions through silence until their open turns end,
+// so the timeline is complete. Audio pushed afterwards continues the call.
+func (p *CallProcessor) End() error {
+	if n := len(p.pending[0]); n > 0 {
+		pad := make([]float32, p.chunk-n)
+		if err := p.Push(pad, pad); err != nil {
+			return err
+		}
+	}
+	for d := time.Duration(0); d < p.idle && (p.open[0] >= 0 || p.open[1] >= 0); d += chunkDuration {
+		if err := p.frame(nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Turns returns the timeline so far, ordered by start; open turns have a
+// zero End.
+func (p *CallProcessor) Turns() []CallTurn {
+	return append([]CallTurn(nil), p.turns...)
+}
+
+// Close closes both sessions; the SessionGroup stays open.
+func (p *CallProcessor) Close() {
+	for _, e := range p.sessions {
+		if e != nil {
+			e.Close()
+		}
+	}
+}
+
+// frame runs one chunk of each channel, or a chunk of gap for both when
+// they are nil, and updates the timeline from the events.
+func (p *CallProcessor) frame(caller, agent []float32) error {
+	var events [2][]Event
+	var err error
+	for i, chunk := range [][]float32{caller, agent} {
+		var cerr error
+		if chunk == nil {
+			events[i], cerr = p.sessions[i].ProcessGap(chunkDuration)
+		} else {
+			events[i], cerr = p.sessions[i].Process(chunk)
+		}
+		if cerr != nil {
+			// The session took the chunk all the same; its events up
+			// to the failure are handled below.
+			err = errors.Join(err, fmt.Errorf("%s channel: %w", CallSpeaker(i), cerr))
+		}
+	}
+	p.frames++
+	end := time.Duration(p.frames) * chunkDuration
+	for i, e := range p.sessions {
+		speaking := e.InSpeech()
+		if p.speaking[i] && !speaking {
+			// The segment ended after VadStopMs of silence.
+			p.segEnd[i] = end - p.vadStop[i]
+		}
+		p.speaking[i] = speaking
+	}
+	for i := range events {
+		for _, ev := range events[i] {
+			p.handle(CallSpeaker(i), ev, end)
+		}
+	}
+	return err
+}
+
+// handle applies one event of speaker s's session, from a chunk ending at
+// end.
+func (p *CallProcessor) handle(s CallSpeaker, ev Event, end time.Duration) {
+	switch ev.Kind {
+	case EventTurnStart:
+		t := CallTurn{
+			Speaker: s,
+			ID:      ev.TurnStart.ID,
+			Start:   time.Duration(ev.TurnStart.Offset) * time.Second / RequiredSampleRate,
+			Overlap: p.speaking[1-s],
+		}
+		i := len(p.turns)
+		for i > 0 && p.turns[i-1].Start > t.Start {
+			i--
+		}
+		p.turns = append(p.turns, CallTurn{})
+		copy(p.turns[i+1:], p.turns[i:])
+		p.turns[i] = t
+		p.open[s] = t.ID
+		if p.cb.OnTurnStart != nil {
+			p.cb.OnTurnStart(t)
+		}
+	case EventTurnMerged:
+		if t := p.last(s); t != nil {
+			t.Merged = true
+			t.SpeechEnd, t.End = 0, 0
+			p.open[s] = t.ID
+		}
+	case EventTurnPrediction:
+		if t := p.current(s); t != nil {
+			t.Probability = ev.Prediction.Probability
+		}
+	case EventSpeechEnd:
+		t := p.current(s)
+		if t == nil {
+			return
+		}
+		p.open[s] = -1
+		t.End, t.Reason = end, ev.EndReason
+		switch ev.EndReason {
+		case TurnEndMaxDuration, TurnEndSpeakerChange:
+			// Cut while speaking.
+			t.SpeechEnd = end
+		default:
+			t.SpeechEnd = p.segEnd[s]
+			if t.SpeechEnd < t.Start {
+				t.SpeechEnd = t.Start
+			}
+		}
+		if p.cb.OnTurnEnd != nil {
+			p.cb.OnTurnEnd(*t)
+		}
+	}
+}
+
+// current returns the open turn of speaker s, or nil.
+func (p *CallProcessor) current(s CallSpeaker) *CallTurn {
+	if p.open[s] < 0 {
+		return nil
+	}
+	for i := len(p.turns) - 1; i >= 0; i-- {
+		if t := &p.turns[i]; t.Speaker == s && t.ID == p.open[s] {
+			return t
+		}
+	}
+	return nil
+}
+
+// last returns the latest turn of speaker s, or nil.
+func (p *CallProcessor) last(s CallSpeaker) *CallTurn {
+	for i := len(p.turns) - 1; i >= 0; i-- {
+		if p.turns[i].Speaker == s {
+			return &p.turns[i]
+		}
+	}
+	return nil
+}
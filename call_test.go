@@ -0,0 +1,207 @@
+package smartturn_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// failVAD is an EnergyVAD that fails chunks starting with marker.
+type failVAD struct {
+	smartturntest.EnergyVAD
+	marker float32
+	err    error
+}
+
+func (v *failVAD) SpeechProb(chunk []float32) (float32, error) {
+	if chunk[0] == v.marker {
+		return 0, v.err
+	}
+	return v.EnergyVAD.SpeechProb(chunk)
+}
+
+// callAudio is a call in which the agent barges in 200 ms before the
+// caller stops: caller speech 0-1 s, agent speech 0.8-1.8 s.
+func callAudio() (caller, agent []float32) {
+	caller, _ = smartturntest.Synth{Seed: 41}.Generate(
+		smartturntest.Speech(time.Second), smartturntest.Silence(2*time.Second))
+	agent, _ = smartturntest.Synth{Seed: 42}.Generate(
+		smartturntest.Silence(800*time.Millisecond), smartturntest.Speech(time.Second), smartturntest.Silence(1200*time.Millisecond))
+	return caller, agent
+}
+
+// newCall returns a CallProcessor on a group with vad, VadStopMs 300,
+// VadPreSpeechMs 200 and a Smart-Turn model predicting 0.9, and the turns
+// its callbacks report.
+func newCall(t *testing.T, vad smartturn.VADBackend) (*smartturn.CallProcessor, *[]string) {
+	t.Helper()
+	cfg := benchConfig()
+	cfg.VadStopMs = 300
+	cfg.VADBackend = vad
+	cfg.TurnBackend = &smartturntest.TurnScript{Probabilities: []float32{0.9}}
+	g, err := smartturn.NewSessionGroup(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = g.Close() })
+	var got []string
+	p, err := smartturn.NewCallProcessor(g, smartturn.CallChannel{}, smartturn.CallChannel{}, smartturn.CallCallbacks{
+		OnTurnStart: func(t smartturn.CallTurn) { got = append(got, "start "+t.Speaker.String()) },
+		OnTurnEnd:   func(t smartturn.CallTurn) { got = append(got, "end "+t.Speaker.String()) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+	return p, &got
+}
+
+func within(d, want time.Duration) bool {
+	const chunk = 32 * time.Millisecond
+	return d >= want-chunk && d <= want+chunk
+}
+
+// TestCallProcessor checks the merged timeline of a call with a barge-in,
+// fed as separate channels, as interleaved stereo, and as s16le.
+func TestCallProcessor(t *testing.T) {
+	caller, agent := callAudio()
+	p, got := newCall(t, &smartturntest.EnergyVAD{})
+	// Pieces that are not whole chunks are assembled.
+	for i := 0; i < len(caller); i += 1000 {
+		j := min(i+1000, len(caller))
+		if err := p.Push(caller[i:j], agent[i:j]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.End(); err != nil {
+		t.Fatal(err)
+	}
+	turns := p.Turns()
+	if len(turns) != 2 {
+		t.Fatalf("turns %+v", turns)
+	}
+	// Speech ends with the chunk holding its last sample (1024 ms and
+	// 1824 ms), and VadStopMs rounds up to 10 chunks.
+	c, a := turns[0], turns[1]
+	if c.Speaker != smartturn.SpeakerCaller || c.Start != 0 || !within(c.SpeechEnd, 1024*time.Millisecond) ||
+		!within(c.End, 1344*time.Millisecond) || c.Reason != smartturn.TurnEndModel || c.Probability != 0.9 || c.Overlap || c.Merged {
+		t.Errorf("caller turn %+v", c)
+	}
+	// The agent's turn starts VadPreSpeechMs before its speech, while the
+	// caller speaks.
+	if a.Speaker != smartturn.SpeakerAgent || !within(a.Start, 600*time.Millisecond) || !within(a.SpeechEnd, 1824*time.Millisecond) ||
+		!within(a.End, 2144*time.Millisecond) || a.Reason != smartturn.TurnEndModel || !a.Overlap {
+		t.Errorf("agent turn %+v", a)
+	}
+	if want := "start caller,start agent,end caller,end agent"; strings.Join(*got, ",") != want {
+		t.Errorf("callbacks %q, want %q", *got, want)
+	}
+	if p.Session(smartturn.SpeakerAgent).InSpeech() {
+		t.Error("agent still in speech after End")
+	}
+
+	// Interleaved stereo, as float samples and as s16le, gives the same
+	// timeline up to the quantization.
+	stereo := make([]float32, 2*len(caller))
+	var pcm bytes.Buffer
+	for i := range caller {
+		stereo[2*i], stereo[2*i+1] = caller[i], agent[i]
+		for _, v := range []float32{caller[i], agent[i]} {
+			_ = binary.Write(&pcm, binary.LittleEndian, int16(max(-1, min(1, v))*32767))
+		}
+	}
+	p2, _ := newCall(t, &smartturntest.EnergyVAD{})
+	if err := p2.PushStereo(stereo); err != nil {
+		t.Fatal(err)
+	}
+	p3, _ := newCall(t, &smartturntest.EnergyVAD{})
+	if err := p3.ReadPCM16(iotest.HalfReader(&pcm)); err != nil {
+		t.Fatal(err)
+	}
+	for name, p := range map[string]*smartturn.CallProcessor{"PushStereo": p2, "ReadPCM16": p3} {
+		if err := p.End(); err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Turns(); !reflect.DeepEqual(got, turns) {
+			t.Errorf("%s turns\n%+v\nwant\n%+v", name, got, turns)
+		}
+	}
+}
+
+// TestCallProcessorChannelError checks that a chunk one session fails is
+// still processed by the other, whose turn it starts, and that the
+// timeline stays aligned.
+func TestCallProcessorChannelError(t *testing.T) {
+	caller, agent := callAudio()
+	p, _ := newCall(t, &smartturntest.EnergyVAD{})
+	if err := p.Push(caller, agent); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.End(); err != nil {
+		t.Fatal(err)
+	}
+	want := p.Turns()
+
+	const marker = 0.123
+	for _, tc := range []struct {
+		name   string
+		failed smartturn.CallSpeaker
+		at     time.Duration // the other speaker's first speech chunk
+	}{
+		{"caller fails", smartturn.SpeakerCaller, 800 * time.Millisecond},
+		{"agent fails", smartturn.SpeakerAgent, 0},
+	} {
+		vad := &failVAD{marker: marker, err: errors.New("vad failed")}
+		p, _ := newCall(t, vad)
+		channels := [2][]float32{append([]float32(nil), caller...), append([]float32(nil), agent...)}
+		channels[tc.failed][int(tc.at.Seconds()*smartturn.RequiredSampleRate)] = marker
+		err := p.Push(channels[0], channels[1])
+		if !errors.Is(err, vad.err) || !strings.Contains(err.Error(), tc.failed.String()+" channel") {
+			t.Errorf("%s: error %v", tc.name, err)
+		}
+		// The audio after the failed chunk is still pending.
+		if err := p.Push(nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.End(); err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Turns(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: turns\n%+v\nwant\n%+v", tc.name, got, want)
+		}
+	}
+}
+
+func TestCallProcessorErrors(t *testing.T) {
+	p, _ := newCall(t, &smartturntest.EnergyVAD{})
+	if err := p.Push(make([]float32, 10), make([]float32, 9)); err == nil {
+		t.Error("channels of different lengths accepted")
+	}
+	if err := p.PushStereo(make([]float32, 3)); err == nil {
+		t.Error("odd stereo accepted")
+	}
+	if got := p.Turns(); len(got) != 0 {
+		t.Errorf("turns %+v", got)
+	}
+
+	cfg := benchConfig()
+	cfg.VADBackend = &smartturntest.EnergyVAD{}
+	cfg.TurnBackend = &smartturntest.TurnScript{}
+	cfg.InputQueue.Size = 4
+	g, err := smartturn.NewSessionGroup(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	if _, err := smartturn.NewCallProcessor(g, smartturn.CallChannel{}, smartturn.CallChannel{}, smartturn.CallCallbacks{}); err == nil || !strings.Contains(err.Error(), "InputQueue") {
+		t.Errorf("group with InputQueue: %v", err)
+	}
+}
@@ -1,8 +1,27 @@
 package smartturn
 
+import "time"
+
 // Callbacks are invoked synchronously by the engine from the same goroutine
-// that calls PushPCM. The SDK does not spawn goroutines. All fields are
+// that calls PushPCM, unless an option below moves them. All fields are
 // optional (nil is allowed).
+//
+// By default the SDK starts no goroutines. These options start them:
+//   - Config.InputQueue: one engine goroutine, from New until Close,
+//     processes the queue and invokes every callback.
+//   - Config.CallbackWatchdog.Async: one dispatch goroutine, from New until
+//     Close, runs the callbacks moved off the engine goroutine.
+//   - Config.FeatureWorkers > 1: mel extraction fans out to short-lived
+//     goroutines the engine waits for.
+//   - Config.SemanticCheck: each Check runs on a goroutine the engine
+//     waits for up to Deadline; one that overruns it is left running.
+//   - NewTurnBatcher: one goroutine, until Close, batches the inferences.
+//
+// The subpackages start their own: publish.New one goroutine sending to
+// the Bus, the publish/nats and publish/mqtt clients a reader per
+// connection (mqtt also a keep-alive pinger), and realtime.Server, besides
+// the net/http connection goroutines, a time.AfterFunc timer per detached
+// session awaiting resume and one goroutine in Shutdown.
 type Callbacks struct {
 	OnListeningStarted func()
 	OnListeningStopped func()
@@ -10,14 +29,197 @@ type Callbacks struct {
 	OnSpeechStart func()
 	OnSpeechEnd   func()
 
+	// OnTurnStart fires just before OnSpeechStart when speech begins a new
+	// logical turn, with the turn's ID and where its audio starts
+	// (pre-speech padding included). Speech that continues a turn, after
+	// an incomplete prediction or as an OnTurnMerged merge, starts none.
+	OnTurnStart func(t TurnStart)
+
+	// OnDTMF reports a keypad tone ('0'-'9', '*', '#', 'A'-'D') found with
+	// Config.DetectDTMF, once per tone, at its first chunk.
+	OnDTMF func(digit rune)
+
+	// OnWakeWord reports that Config.WakeWord fired and the turn pipeline
+	// is active until the turn ends.
+	OnWakeWord func()
+
+	// OnSpeakerChange reports a new speaker label from Config.Speakers. It
+	// fires before the OnTurnEnd and OnSpeechEnd of the previous speaker's
+	// turn and the OnSpeechStart of the new one.
+	OnSpeakerChange func(c SpeakerChange)
+
+	// OnVadScore receives the raw Silero speech probability of every chunk
+	// and the offset of its first sample in the audio accepted since New
+	// (chunks dropped while stopped do not count), for live meters and
+	// analytics. It is called before any other callback for the chunk.
+	OnVadScore func(prob float32, sampleOffset int64)
+
+	// OnTurnEnd fires right before each OnSpeechEnd with the reason the
+	// turn ended, telling a model-confirmed end from a forced one.
+	OnTurnEnd func(reason TurnEndReason)
+
 	OnChunk        func(chunk []float32)
 	// OnSegmentReady receives segment audio; the engine may reuse the slice after the callback returns—copy if retaining.
 	OnSegmentReady func(segment []float32)
+	// OnSegment receives the same slices as OnSegmentReady in a pooled
+	// Segment the callback owns, for audio that outlives the callback (e.g.
+	// streamed to ASR from another goroutine); call Release when done.
+	OnSegment func(seg *Segment)
+
+	// OnTurnMerged corrects the last OnSpeechEnd: speech resumed within
+	// Config.TurnMergeGapMs of it, so it was premature and the new speech
+	// continues the same turn. OnSpeechStart does not fire for it; the turn
+	// ends with a later OnSpeechEnd.
+	OnTurnMerged func(m TurnMerge)
+
+	// OnTurnSplit reports that speech reached TurnMaxDurationSeconds with
+	// Config.SplitLongTurns set: the segment continues in a new part
+	// instead of ending the turn (no OnSpeechEnd/OnSpeechStart).
+	OnTurnSplit func(s TurnSplit)
 
 	// OnTurnPrediction receives Smart-Turn's decision when a segment ends by VAD
-	// silence (not by max-duration cap). `complete` is true when the model
-	// thinks the turn is finished; `probability` is the underlying score.
+	// silence (not by max-duration cap).
 	OnTurnPrediction func(complete bool, probability float32)
+	// OnTurnPredictionDetail receives the same decision as a TurnPrediction,
+	// with the raw logit, the model's further outputs and the latencies
+	// behind it, right after OnTurnPrediction.
+	OnTurnPredictionDetail func(p TurnPrediction)
+
+	// OnShadowPrediction compares the shadow Smart-Turn model with the
+	// primary after each of the primary's predictions, once the decision
+	// has been delivered.
+	OnShadowPrediction func(s ShadowPrediction)
+
+	// OnTranscript delivers the Config.Transcriber result of each turn,
+	// after its OnSpeechEnd.
+	OnTranscript func(t Transcript)
+
+	// OnOverload reports when the real-time factor crosses
+	// Config.Overload.Threshold and when it recovers.
+	OnOverload func(ev OverloadEvent)
+
+	// OnAudioDropped reports chunks discarded because Config.InputQueue was
+	// full, on the engine goroutine before the next queued chunk is
+	// processed, so the host can tell a gap in the audio from silence.
+	OnAudioDropped func(chunks int)
+	// OnAudioEvicted warns that the current segment reached
+	// Config.MaxBufferedAudioMs and its oldest audio is being evicted.
+	// It fires once per segment.
+	OnAudioEvicted func(ev AudioEviction)
+	// OnSlowCallback reports a callback that ran longer than
+	// Config.CallbackWatchdog.Budget, after it returned. It is not timed
+	// itself.
+	OnSlowCallback func(s SlowCallback)
 
 	OnError func(err error)
 }
+
+// TurnEndReason says why a turn ended (OnTurnEnd, Event.EndReason).
+type TurnEndReason int
+
+const (
+	// TurnEndModel: the segment ended in silence and Smart-Turn confirmed
+	// the turn (at or above TurnThreshold when it applies).
+	TurnEndModel TurnEndReason = iota
+	// TurnEndTimeout: Smart-Turn failed or judged the turn incomplete, and
+	// no speech followed within TurnTimeoutMs, so the turn was abandoned.
+	TurnEndTimeout
+	// TurnEndMaxDuration: speech reached TurnMaxDurationSeconds (without
+	// SplitLongTurns) and the turn was cut without a prediction.
+	TurnEndMaxDuration
+	// TurnEndSpeakerChange: Config.Speakers reported another speaker, so
+	// the turn was cut without a prediction.
+	TurnEndSpeakerChange
+)
+
+func (r TurnEndReason) String() string {
+	switch r {
+	case TurnEndModel:
+		return "model"
+	case TurnEndTimeout:
+		return "timeout"
+	case TurnEndMaxDuration:
+		return "max_duration"
+	case TurnEndSpeakerChange:
+		return "speaker_change"
+	}
+	return "unknown"
+}
+
+// ModelOutput is one output tensor of the Smart-Turn model.
+type ModelOutput struct {
+	Name  string
+	Shape []int64
+	Data  []float32 // row-major
+}
+
+// TurnStart is passed to OnTurnStart.
+type TurnStart struct {
+	// ID numbers the turns started since New, from 0 (Reset does not
+	// restart it). Transcript, TurnLog, and TurnExport use the same ID.
+	ID int
+	// Offset is the first sample of the turn's audio, pre-speech padding
+	// included, in the audio accepted since New; Time is its media time.
+	Offset int64
+	Time   time.Time
+	// Padding is the pre-speech audio committed to the turn before the
+	// chunk VAD detected speech in (up to VadPreSpeechMs).
+	Padding time.Duration
+}
+
+// AudioEviction is passed to OnAudioEvicted.
+type AudioEviction struct {
+	Turn int // TurnStart.ID of the turn
+	// Offset is the first sample still buffered, in the audio accepted
+	// since New.
+	Offset int64
+	Budget time.Duration // Config.MaxBufferedAudioMs
+}
+
+// TurnMerge is passed to OnTurnMerged.
+type TurnMerge struct {
+	// Gap is the time from the retracted OnSpeechEnd to the resumed speech
+	// (audio time, before pre-speech padding).
+	Gap time.Duration
+}
+
+// TurnSplit is passed to OnTurnSplit. OnSegmentReady slices after it belong
+// to the next part, whose first OverlapSamples repeat the end of the ended
+// part, so ASR consumers can transcribe both and stitch on the overlap.
+type TurnSplit struct {
+	Part           int // 1 for the first split of a segment, then 2, ...
+	Samples        int // length of the part that ended
+	OverlapSamples int
+}
+
+// TurnPrediction is a Smart-Turn result passed to OnTurnPredictionDetail. The
+// end-of-turn latency after the user stops speaking is roughly
+// SilenceBeforeDecision + QueueWait + InferenceDuration.
+type TurnPrediction struct {
+	// Complete is true when the model thinks the turn is finished
+	// (Probability > 0.5); OnSpeechEnd uses Config.TurnThreshold instead.
+	Complete    bool
+	Probability float32
+	// Instant is this evaluation's probability. It equals Probability
+	// unless Config.TurnSmoothing averages it with earlier ones of the turn.
+	Instant float32
+	// Logit is the model's raw score before the sigmoid, for custom
+	// calibration; Config.TurnCalibration does not change it. v3 exports apply the sigmoid in the graph, so for them
+	// (and custom backends) it is recovered from Probability.
+	Logit float32
+	// AuxOutputs holds the model's further outputs with a static shape, if
+	// any; their Data is reused by the next inference, so copy to retain.
+	AuxOutputs []ModelOutput
+	// InferenceDuration is the wall-clock time of the Smart-Turn call,
+	// feature extraction included.
+	InferenceDuration time.Duration
+	// Verdict is the answer of Config.SemanticCheck when the probability
+	// fell in its uncertain band; it overrides TurnThreshold.
+	Verdict TurnVerdict
+	// QueueWait is the time spent waiting for a Config.InferencePool slot
+	// before inference started; zero without a pool.
+	QueueWait time.Duration
+	// SilenceBeforeDecision is the trailing silence (audio time) VAD
+	// observed before ending the segment, about Config.VadStopMs.
+	SilenceBeforeDecision time.Duration
+}
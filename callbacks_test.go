@@ -0,0 +1,80 @@
+package smartturn_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// newTestEngine returns a started engine on benchConfig with energy VAD
+// and a Smart-Turn backend scripted with probs, closed with the test.
+func newTestEngine(t testing.TB, probs []float32, cb smartturn.Callbacks, tweak func(*smartturn.Config)) *smartturn.Engine {
+	t.Helper()
+	cfg := benchConfig()
+	cfg.VadStopMs = 300
+	cfg.VADBackend = &smartturntest.EnergyVAD{}
+	cfg.TurnBackend = &smartturntest.TurnScript{Probabilities: probs}
+	if tweak != nil {
+		tweak(&cfg)
+	}
+	e, err := smartturn.New(cfg, cb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+	e.Start()
+	return e
+}
+
+// pushAll feeds audio to e chunk by chunk.
+func pushAll(t testing.TB, e *smartturn.Engine, audio []float32) {
+	t.Helper()
+	for _, c := range smartturntest.Chunks(audio) {
+		if err := e.PushPCM(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestTurnPredictionCallbacks checks that OnTurnPrediction keeps its
+// (complete, probability) form and OnTurnPredictionDetail follows it with
+// the same decision.
+func TestTurnPredictionCallbacks(t *testing.T) {
+	var calls []string
+	var old, detail []float32
+	e := newTestEngine(t, []float32{0.3, 0.8}, smartturn.Callbacks{
+		OnTurnPrediction: func(complete bool, probability float32) {
+			calls = append(calls, "prediction")
+			if complete != (probability > 0.5) {
+				t.Errorf("complete = %v for probability %v", complete, probability)
+			}
+			old = append(old, probability)
+		},
+		OnTurnPredictionDetail: func(p smartturn.TurnPrediction) {
+			calls = append(calls, "detail")
+			if p.SilenceBeforeDecision <= 0 {
+				t.Errorf("SilenceBeforeDecision = %v", p.SilenceBeforeDecision)
+			}
+			detail = append(detail, p.Probability)
+		},
+	}, nil)
+	audio, _ := smartturntest.Synth{Seed: 1}.Generate(
+		smartturntest.Speech(time.Second), smartturntest.Silence(500*time.Millisecond),
+		smartturntest.Speech(time.Second), smartturntest.Silence(500*time.Millisecond))
+	pushAll(t, e, audio)
+
+	want := []float32{0.3, 0.8}
+	if len(old) != 2 || old[0] != want[0] || old[1] != want[1] {
+		t.Errorf("OnTurnPrediction probabilities = %v, want %v", old, want)
+	}
+	if len(detail) != 2 || detail[0] != want[0] || detail[1] != want[1] {
+		t.Errorf("OnTurnPredictionDetail probabilities = %v, want %v", detail, want)
+	}
+	for i := 0; i+1 < len(calls); i += 2 {
+		if calls[i] != "prediction" || calls[i+1] != "detail" {
+			t.Fatalf("call order %v, want prediction before detail", calls)
+		}
+	}
+}
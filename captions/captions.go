@@ -0,0 +1,125 @@
+// Package captions writes the turns an engine detects in a recording as
+// SubRip (.srt) or WebVTT (.vtt) cues, so the recording can be reviewed in
+// any standard player with the turn markers overlaid.
+//
+//	cues, err := captions.Turns(engine, recording, cfg.ChunkSize)
+//	err = captions.WriteVTT(f, cues)
+//
+// Each cue spans one turn, from the chunk where OnSpeechStart fired to the
+// one where OnSpeechEnd fired, and names the turn, why it ended, and the
+// last Smart-Turn probability.
+package captions
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+)
+
+// chunkDuration is the audio time of one engine chunk at either sample
+// rate.
+const chunkDuration = time.Duration(smartturn.RequiredChunkSize) * time.Second / smartturn.RequiredSampleRate
+
+// Cue is one caption: a turn and its text.
+type Cue struct {
+	Start, End time.Duration // from the start of the recording
+	Text       string
+}
+
+// Turns runs e over audio (mono at the engine's Config.SampleRate) in
+// chunks of chunkSize samples with Process, and returns one cue per turn.
+// The last chunk is padded with silence; append silence of about
+// TurnTimeoutMs to the recording so its last turn can end, or it is
+// marked unfinished. e is reset and started first and stopped at the end;
+// it must not use Config.InputQueue.
+func Turns(e *smartturn.Engine, audio []float32, chunkSize int) ([]Cue, error) {
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("captions: invalid chunk size %d", chunkSize)
+	}
+	var (
+		cues []Cue
+		open bool    // the last cue is the current turn
+		prob float32 // last probability of the current turn
+		seen bool    // prob is set
+	)
+	e.Reset()
+	e.Start()
+	defer e.Stop()
+	buf := make([]float32, chunkSize)
+	for i := 0; i*chunkSize < len(audio); i++ {
+		chunk := audio[i*chunkSize:]
+		if len(chunk) < chunkSize {
+			clear(buf)
+			copy(buf, chunk)
+			chunk = buf
+		}
+		events, err := e.Process(chunk[:chunkSize])
+		if err != nil {
+			return nil, fmt.Errorf("captions: chunk %d: %w", i, err)
+		}
+		at := time.Duration(i) * chunkDuration
+		for _, ev := range events {
+			switch ev.Kind {
+			case smartturn.EventSpeechStart:
+				cues = append(cues, Cue{Start: at})
+				open, seen = true, false
+			case smartturn.EventTurnMerged:
+				// The last turn's end is retracted; it goes on.
+				if len(cues) > 0 {
+					open = true
+				}
+			case smartturn.EventTurnPrediction:
+				prob, seen = ev.Prediction.Probability, true
+			case smartturn.EventSpeechEnd:
+				if open {
+					c := &cues[len(cues)-1]
+					c.End = at + chunkDuration
+					c.Text = cueText(len(cues), ev.EndReason.String(), prob, seen)
+					open = false
+				}
+			}
+		}
+	}
+	if open {
+		c := &cues[len(cues)-1]
+		c.End = time.Duration(len(audio)) * chunkDuration / time.Duration(chunkSize)
+		c.Text = cueText(len(cues), "unfinished", prob, seen)
+	}
+	return cues, nil
+}
+
+// cueText names turn n, why it ended, and its last probability.
+func cueText(n int, reason string, prob float32, seen bool) string {
+	if !seen {
+		return fmt.Sprintf("Turn %d\nend: %s", n, reason)
+	}
+	return fmt.Sprintf("Turn %d\nend: %s, p=%.2f", n, reason, prob)
+}
+
+// WriteSRT writes cues as a SubRip file.
+func WriteSRT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	for i, c := range cues {
+		fmt.Fprintf(bw, "%d\n%s --> %s\n%s\n\n", i+1, timestamp(c.Start, ','), timestamp(c.End, ','), c.Text)
+	}
+	return bw.Flush()
+}
+
+// WriteVTT writes cues as a WebVTT file.
+func WriteVTT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT\n\n")
+	for i, c := range cues {
+		fmt.Fprintf(bw, "%d\n%s --> %s\n%s\n\n", i+1, timestamp(c.Start, '.'), timestamp(c.End, '.'), c.Text)
+	}
+	return bw.Flush()
+}
+
+// timestamp formats d as hh:mm:ss followed by sep and milliseconds.
+func timestamp(d time.Duration, sep byte) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
@@ -0,0 +1,164 @@
+package captions_test
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/captions"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// newEngine returns an engine with energy VAD and a scripted Smart-Turn
+// backend predicting probs.
+func newEngine(t *testing.T, probs []float32, tweak func(*smartturn.Config)) *smartturn.Engine {
+	t.Helper()
+	cfg := smartturn.Config{
+		SampleRate:             smartturn.RequiredSampleRate,
+		ChunkSize:              smartturn.RequiredChunkSize,
+		VadThreshold:           0.5,
+		VadPreSpeechMs:         200,
+		VadStopMs:              300,
+		TurnMaxDurationSeconds: 600,
+		TurnSegmentEmitMs:      1000,
+		TurnThreshold:          0.5,
+		TurnTimeoutMs:          1000,
+		VADBackend:             &smartturntest.EnergyVAD{},
+		TurnBackend:            &smartturntest.TurnScript{Probabilities: probs},
+	}
+	if tweak != nil {
+		tweak(&cfg)
+	}
+	e, err := smartturn.New(cfg, smartturn.Callbacks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+	return e
+}
+
+// decimate halves the rate of 16 kHz audio by averaging sample pairs.
+func decimate(audio []float32) []float32 {
+	out := make([]float32, len(audio)/2)
+	for i := range out {
+		out[i] = (audio[2*i] + audio[2*i+1]) / 2
+	}
+	return out
+}
+
+func TestTurns(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 1}.Generate(
+		smartturntest.Silence(500*time.Millisecond), smartturntest.Speech(time.Second),
+		smartturntest.Silence(600*time.Millisecond), smartturntest.Speech(time.Second),
+		smartturntest.Silence(600*time.Millisecond), smartturntest.Speech(700*time.Millisecond))
+	// Cues run from the chunk where EnergyVAD hears the speech to the end
+	// of the chunk VadStopMs (300 ms) into the silence after it.
+	want := []captions.Cue{
+		{480 * time.Millisecond, 1824 * time.Millisecond, "Turn 1\nend: model, p=0.90"},
+		{2080 * time.Millisecond, 3424 * time.Millisecond, "Turn 2\nend: model, p=0.90"},
+		// Cut off by the end of the audio, before any prediction.
+		{3712 * time.Millisecond, 4400 * time.Millisecond, "Turn 3\nend: unfinished"},
+	}
+	for _, tc := range []struct {
+		name      string
+		tweak     func(*smartturn.Config)
+		audio     []float32
+		chunkSize int
+	}{
+		{"16 kHz", nil, audio, smartturn.RequiredChunkSize},
+		{"8 kHz", func(cfg *smartturn.Config) {
+			cfg.SampleRate, cfg.ChunkSize = smartturn.TelephonySampleRate, smartturn.TelephonyChunkSize
+		}, decimate(audio), smartturn.TelephonyChunkSize},
+	} {
+		e := newEngine(t, []float32{0.9}, tc.tweak)
+		// Turns resets the engine, so a second run gives the same cues.
+		for run := range 2 {
+			cues, err := captions.Turns(e, tc.audio, tc.chunkSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(cues, want) {
+				t.Errorf("%s, run %d: cues %q\nwant %q", tc.name, run, cues, want)
+			}
+			if e.Health().Listening {
+				t.Errorf("%s: engine still listening", tc.name)
+			}
+		}
+	}
+}
+
+// TestTurnsMerged checks that a merged turn extends the cue of the turn
+// it continues, and that a turn without predictions has no probability.
+func TestTurnsMerged(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 2}.Generate(
+		smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond),
+		smartturntest.Speech(time.Second), smartturntest.Silence(2*time.Second))
+	e := newEngine(t, []float32{0.9, 0.3}, func(cfg *smartturn.Config) { cfg.TurnMergeGapMs = 800 })
+	cues, err := captions.Turns(e, audio, smartturn.RequiredChunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The merged turn times out TurnTimeoutMs (1 s) after VadStopMs, 1.3 s
+	// after its speech ends at 2.6 s.
+	want := []captions.Cue{{0, 3936 * time.Millisecond, "Turn 1\nend: timeout, p=0.30"}}
+	if !slices.Equal(cues, want) {
+		t.Errorf("cues %q, want %q", cues, want)
+	}
+
+	e = newEngine(t, nil, func(cfg *smartturn.Config) { cfg.TurnBackend = failingTurn{} })
+	cues, err = captions.Turns(e, audio, smartturn.RequiredChunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cues) != 1 || cues[0].Text != "Turn 1\nend: timeout" {
+		t.Errorf("cues %q, want one timeout without a probability", cues)
+	}
+
+	if _, err := captions.Turns(e, audio, 0); err == nil {
+		t.Error("chunk size 0 accepted")
+	}
+	if _, err := captions.Turns(e, audio, 100); err == nil {
+		t.Error("chunk size 100 accepted")
+	}
+}
+
+// failingTurn is a TurnBackend whose every prediction fails.
+type failingTurn struct{}
+
+func (failingTurn) Predict([]float32) (float32, error) { return 0, errors.New("inference failed") }
+func (failingTurn) Close() error                       { return nil }
+
+func TestWrite(t *testing.T) {
+	cues := []captions.Cue{
+		{480 * time.Millisecond, 1824 * time.Millisecond, "Turn 1\nend: model, p=0.90"},
+		{time.Hour + 2*time.Minute + 3*time.Second + 4*time.Millisecond, 2*time.Hour + 999*time.Millisecond, "Turn 2\nend: unfinished"},
+	}
+	var srt, vtt bytes.Buffer
+	if err := captions.WriteSRT(&srt, cues); err != nil {
+		t.Fatal(err)
+	}
+	if err := captions.WriteVTT(&vtt, cues); err != nil {
+		t.Fatal(err)
+	}
+	const body = `1
+00:00:00_480 --> 00:00:01_824
+Turn 1
+end: model, p=0.90
+
+2
+01:02:03_004 --> 02:00:00_999
+Turn 2
+end: unfinished
+
+`
+	if want := strings.ReplaceAll(body, "_", ","); srt.String() != want {
+		t.Errorf("SRT:\n%s\nwant:\n%s", srt.String(), want)
+	}
+	if want := "WEBVTT\n\n" + strings.ReplaceAll(body, "_", "."); vtt.String() != want {
+		t.Errorf("VTT:\n%s\nwant:\n%s", vtt.String(), want)
+	}
+}
@@ -0,0 +1,150 @@
+package smartturn
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go/features"
+)
+
+// ClassifierOptions configures a TurnClassifier. Fields mirror the
+// Smart-Turn settings of Config.
+type ClassifierOptions struct {
+	// Backend replaces the ONNX Runtime model, as Config.TurnBackend; the
+	// model path is then ignored. The classifier closes it in Close.
+	Backend TurnBackend
+
+	Provider       ExecutionProvider
+	SessionOptions SessionOptions
+	Features       features.Params
+	Calibration    Calibration
+
+	// ONNXRuntimeLibPath is as Config.ONNXRuntimeLibPath.
+	ONNXRuntimeLibPath string
+}
+
+// TurnClassifier is the Smart-Turn model on its own, without VAD or
+// streaming, for scoring audio that was segmented elsewhere (e.g.
+// re-scoring ASR utterances). Calls are independent of each other and safe
+// from any goroutine; they run one at a time.
+type TurnClassifier struct {
+	mu          sync.Mutex
+	st          *smartTurn
+	usesRuntime bool
+	closed      bool
+}
+
+// NewTurnClassifier loads the Smart-Turn model at modelPath.
+func NewTurnClassifier(modelPath string, opts ClassifierOptions) (*TurnClassifier, error) {
+	if err := validateCalibration(opts.Calibration); err != nil {
+		return nil, err
+	}
+	if err := opts.Features.Validate(); err != nil {
+		return nil, fmt.Errorf("smart-turn: Features: %w", err)
+	}
+	c := &TurnClassifier{}
+	var err error
+	if opts.Backend != nil {
+		c.st, err = newCustomSmartTurn(opts.Backend, opts.Features)
+	} else {
+		if err := validateProvider(opts.Provider); err != nil {
+			return nil, err
+		}
+		if err := validateSessionOptions("SessionOptions", opts.SessionOptions); err != nil {
+			return nil, err
+		}
+		if modelPath == "" {
+			return nil, errors.New("smart-turn: model path is required")
+		}
+		if err := acquireRuntime(runtimeLibPath(Config{ONNXRuntimeLibPath: opts.ONNXRuntimeLibPath})); err != nil {
+			return nil, err
+		}
+		c.usesRuntime = true
+		c.st, err = newSmartTurn(modelPath, opts.Features, opts.SessionOptions, opts.Provider)
+	}
+	if err != nil {
+		if c.usesRuntime {
+			_ = releaseRuntime()
+		}
+		return nil, err
+	}
+	c.st.calib = opts.Calibration
+	return c, nil
+}
+
+// FallbackErr reports why the requested provider was unavailable when
+// Provider.FallbackToCPU moved the session to CPU; nil otherwise.
+func (c *TurnClassifier) FallbackErr() error { return c.st.fallbackErr }
+
+// PredictEndOfTurn scores audio (16 kHz mono, samples in [-1, 1]) ending
+// where a turn might end. Only the last 8 seconds (the model window) are
+// used; shorter audio is padded, and empty audio is an error. The result
+// has Complete, Probability, Logit, AuxOutputs, and InferenceDuration set;
+// AuxOutputs is reused by the next call.
+func (c *TurnClassifier) PredictEndOfTurn(audio []float32) (TurnPrediction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return TurnPrediction{}, ErrClosed
+	}
+	start := time.Now()
+	c.st.resetSegment()
+	r, err := c.st.run(audio)
+	if err != nil {
+		return TurnPrediction{}, err
+	}
+	return r.prediction(time.Since(start)), nil
+}
+
+// PredictFeatures scores precomputed model input, bypassing the internal
+// feature extractor, for pipelines that already produce Whisper features
+// (e.g. on a GPU, or shared with an ASR model). For mel models features is
+// the row-major log-mel of FeatureShape, normalized as features.ComputeLogMel
+// does; a length other than FeatureSize returns ErrFeatureSize.
+func (c *TurnClassifier) PredictFeatures(features []float32) (TurnPrediction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return TurnPrediction{}, ErrClosed
+	}
+	if len(features) != len(c.st.features) {
+		return TurnPrediction{}, ErrFeatureSize
+	}
+	start := time.Now()
+	r, err := c.st.predict(features)
+	if err != nil {
+		return TurnPrediction{}, err
+	}
+	return r.prediction(time.Since(start)), nil
+}
+
+// FeatureShape is the mel input shape of the model (80 × 800 by default);
+// zero for raw-audio (v2) models, whose input is FeatureSize samples.
+func (c *TurnClassifier) FeatureShape() features.Shape {
+	if c.st.model.input != smartTurnMel {
+		return features.Shape{}
+	}
+	return c.st.model.params.Shape()
+}
+
+// FeatureSize is the length of the input PredictFeatures expects.
+func (c *TurnClassifier) FeatureSize() int { return len(c.st.features) }
+
+// Close releases the model. Later calls return ErrClosed.
+func (c *TurnClassifier) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	err := c.st.destroy()
+	if c.usesRuntime {
+		if rerr := releaseRuntime(); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,199 @@
+package smartturn_test
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/features"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// recordingTurn is a TurnBackend that keeps the features of its last
+// Predict and returns Probability.
+type recordingTurn struct {
+	Probability float32
+
+	mu       sync.Mutex
+	features []float32
+	calls    int
+	closed   bool
+}
+
+func (b *recordingTurn) Predict(f []float32) (float32, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.features = append(b.features[:0], f...)
+	b.calls++
+	return b.Probability, nil
+}
+
+func (b *recordingTurn) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	return nil
+}
+
+func TestTurnClassifier(t *testing.T) {
+	backend := &recordingTurn{Probability: 0.8}
+	c, err := smartturn.NewTurnClassifier("", smartturn.ClassifierOptions{Backend: backend})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.FeatureShape(); got != (features.Shape{Mels: features.NMels, Frames: features.Frames}) {
+		t.Errorf("FeatureShape() = %+v", got)
+	}
+	if c.FeatureSize() != smartturn.TurnFeatureSize {
+		t.Errorf("FeatureSize() = %d, want %d", c.FeatureSize(), smartturn.TurnFeatureSize)
+	}
+
+	// The model sees the log-mel of the last 8 seconds, as the engine's
+	// streamed extraction computes it.
+	// Calls are independent: nothing is reused from the previous audio.
+	audio, _ := smartturntest.Synth{Seed: 9}.Generate(smartturntest.Speech(10*time.Second), smartturntest.Silence(300*time.Millisecond))
+	other, _ := smartturntest.Synth{Seed: 11}.Generate(smartturntest.Speech(10*time.Second), smartturntest.Silence(300*time.Millisecond))
+	for _, a := range [][]float32{audio, other, audio[len(audio)-20000:]} {
+		p, err := c.PredictEndOfTurn(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Probability != 0.8 || !p.Complete || math.Abs(float64(p.Logit)-math.Log(4)) > 1e-5 {
+			t.Errorf("prediction %+v", p)
+		}
+		want, _ := features.ComputeLogMel(a)
+		if len(backend.features) != len(want) {
+			t.Fatalf("%d features, want %d", len(backend.features), len(want))
+		}
+		for i := range want {
+			if math.Abs(float64(backend.features[i]-want[i])) > 1e-4 {
+				t.Errorf("%d samples: feature %d = %v, want the log-mel %v", len(a), i, backend.features[i], want[i])
+				break
+			}
+		}
+	}
+	if _, err := c.PredictEndOfTurn(nil); err == nil {
+		t.Error("empty audio accepted")
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !backend.closed {
+		t.Error("Close did not close the backend")
+	}
+	if _, err := c.PredictEndOfTurn(audio); !errors.Is(err, smartturn.ErrClosed) {
+		t.Errorf("after Close: error %v, want ErrClosed", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+// TestTurnClassifierConcurrent scores from several goroutines; run with
+// -race.
+func TestTurnClassifierConcurrent(t *testing.T) {
+	backend := &recordingTurn{Probability: 0.3}
+	c, err := smartturn.NewTurnClassifier("", smartturn.ClassifierOptions{
+		Backend:     backend,
+		Calibration: smartturn.Calibration{B: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	audio, _ := smartturntest.Synth{Seed: 10}.Generate(smartturntest.Speech(2 * time.Second))
+	want := float32(1 / (1 + math.Exp(-(math.Log(0.3/0.7) + 2))))
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for range 3 {
+				p, err := c.PredictEndOfTurn(audio)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if math.Abs(float64(p.Probability-want)) > 1e-6 {
+					t.Errorf("calibrated probability %v, want %v", p.Probability, want)
+				}
+			}
+		})
+	}
+	wg.Wait()
+	if backend.calls != 12 {
+		t.Errorf("%d predictions, want 12", backend.calls)
+	}
+}
+
+func TestNewTurnClassifierErrors(t *testing.T) {
+	for name, opts := range map[string]smartturn.ClassifierOptions{
+		"no model":    {},
+		"calibration": {Backend: &recordingTurn{}, Calibration: smartturn.Calibration{Temperature: -1}},
+		"features":    {Backend: &recordingTurn{}, Features: features.Params{NMels: -1}},
+	} {
+		if c, err := smartturn.NewTurnClassifier("", opts); err == nil {
+			c.Close()
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+// TestTurnClassifierPredictFeatures checks that precomputed features
+// reach the model as they are, sized by the feature parameters.
+func TestTurnClassifierPredictFeatures(t *testing.T) {
+	backend := &recordingTurn{Probability: 0.7}
+	params := features.Params{NMels: 40, Frames: 400}
+	c, err := smartturn.NewTurnClassifier("", smartturn.ClassifierOptions{Backend: backend, Features: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if got := c.FeatureShape(); got != (features.Shape{Mels: 40, Frames: 400}) || c.FeatureSize() != 40*400 {
+		t.Fatalf("FeatureShape() = %+v, FeatureSize() = %d", got, c.FeatureSize())
+	}
+
+	in := make([]float32, c.FeatureSize())
+	for i := range in {
+		in[i] = float32(i%97) / 97
+	}
+	p, err := c.PredictFeatures(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Probability != 0.7 || !p.Complete {
+		t.Errorf("prediction %+v", p)
+	}
+	if !slices.Equal(backend.features, in) {
+		t.Error("the backend did not get the features as passed")
+	}
+
+	// Scoring audio goes through the same model input.
+	audio, _ := smartturntest.Synth{Seed: 13}.Generate(smartturntest.Speech(3 * time.Second))
+	if _, err := c.PredictEndOfTurn(audio); err != nil || len(backend.features) != 40*400 {
+		t.Errorf("PredictEndOfTurn: %v, %d features", err, len(backend.features))
+	}
+
+	for _, n := range []int{0, smartturn.TurnFeatureSize, c.FeatureSize() + 1} {
+		if _, err := c.PredictFeatures(make([]float32, n)); !errors.Is(err, smartturn.ErrFeatureSize) {
+			t.Errorf("%d features: error %v, want ErrFeatureSize", n, err)
+		}
+	}
+}
+
+// TestEnginePredictFeaturesSize checks the engine's input size under
+// custom feature parameters.
+func TestEnginePredictFeaturesSize(t *testing.T) {
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{}, func(cfg *smartturn.Config) {
+		cfg.SmartTurnFeatures = features.Params{Frames: 400}
+	})
+	if _, err := e.PredictFeatures(make([]float32, smartturn.TurnFeatureSize)); !errors.Is(err, smartturn.ErrFeatureSize) {
+		t.Errorf("default size: error %v, want ErrFeatureSize", err)
+	}
+	if _, err := e.PredictFeatures(make([]float32, features.NMels*400)); err != nil {
+		t.Errorf("80x400 features: %v", err)
+	}
+}
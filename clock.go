@@ -0,0 +1,18 @@
+package smartturn
+
+import "time"
+
+// Clock supplies wall-clock time to an engine: inference latencies, Health
+// timestamps, debug file names, and the periodic reset of Silero's
+// recurrent state. Segmentation (VadStopMs, TurnTimeoutMs, pre-speech and
+// max duration) counts audio chunks and never reads the clock, so with a
+// fake Clock (see smartturntest.Clock) an engine fed the same audio
+// behaves identically on every run.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the default Clock.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
@@ -0,0 +1,342 @@
+package smartturn
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// ClosePolicy selects what happens to callbacks of a PushPCM (or Start,
+// Stop, Reset) call that is in flight on another goroutine when Close is
+// called.
+type ClosePolicy int
+
+const (
+	// CloseDrain lets the in-flight call finish and deliver its callbacks,
+	// including its Smart-Turn decision; Close waits for it.
+	CloseDrain ClosePolicy = iota
+	// CloseDiscard drops every callback from the moment Close is called. The
+	// in-flight call still finishes (a running model inference cannot be
+	// interrupted) and Close waits for it, but its results are not
+	// delivered. Errors are still logged and counted in Health.
+	CloseDiscard
+)
+
+// guardCallbacks wraps every non-nil callback so it is skipped once Close
+// forbids delivery, tracks how many callbacks are executing, and recovers
+// their panics.
+func (e *Engine) guardCallbacks(cb Callbacks) Callbacks {
+	g := cb
+	if cb.OnListeningStarted != nil {
+		g.OnListeningStarted = guard0(e, "OnListeningStarted", cb.OnListeningStarted)
+	}
+	if cb.OnListeningStopped != nil {
+		g.OnListeningStopped = guard0(e, "OnListeningStopped", cb.OnListeningStopped)
+	}
+	if cb.OnSpeechStart != nil {
+		g.OnSpeechStart = guard0(e, "OnSpeechStart", cb.OnSpeechStart)
+	}
+	if cb.OnSpeechEnd != nil {
+		g.OnSpeechEnd = guard0(e, "OnSpeechEnd", cb.OnSpeechEnd)
+	}
+	if cb.OnWakeWord != nil {
+		g.OnWakeWord = guard0(e, "OnWakeWord", cb.OnWakeWord)
+	}
+	if cb.OnTurnStart != nil {
+		g.OnTurnStart = guard1(e, "OnTurnStart", cb.OnTurnStart, nil)
+	}
+	if cb.OnDTMF != nil {
+		g.OnDTMF = guard1(e, "OnDTMF", cb.OnDTMF, nil)
+	}
+	if cb.OnSpeakerChange != nil {
+		g.OnSpeakerChange = guard1(e, "OnSpeakerChange", cb.OnSpeakerChange, nil)
+	}
+	if cb.OnTurnEnd != nil {
+		g.OnTurnEnd = guard1(e, "OnTurnEnd", cb.OnTurnEnd, nil)
+	}
+	if cb.OnChunk != nil {
+		g.OnChunk = guard1(e, "OnChunk", cb.OnChunk, nil)
+	}
+	if cb.OnSegmentReady != nil {
+		g.OnSegmentReady = guard1(e, "OnSegmentReady", cb.OnSegmentReady, nil)
+	}
+	if cb.OnSegment != nil {
+		// A Segment that is not delivered goes back to the pool.
+		g.OnSegment = guard1(e, "OnSegment", cb.OnSegment, (*Segment).Release)
+	}
+	if cb.OnTurnMerged != nil {
+		g.OnTurnMerged = guard1(e, "OnTurnMerged", cb.OnTurnMerged, nil)
+	}
+	if cb.OnTurnSplit != nil {
+		g.OnTurnSplit = guard1(e, "OnTurnSplit", cb.OnTurnSplit, nil)
+	}
+	if cb.OnTurnPredictionDetail != nil {
+		g.OnTurnPredictionDetail = guard1(e, "OnTurnPredictionDetail", cb.OnTurnPredictionDetail, nil)
+	}
+	if cb.OnShadowPrediction != nil {
+		g.OnShadowPrediction = guard1(e, "OnShadowPrediction", cb.OnShadowPrediction, nil)
+	}
+	if cb.OnTranscript != nil {
+		g.OnTranscript = guard1(e, "OnTranscript", cb.OnTranscript, nil)
+	}
+	if cb.OnOverload != nil {
+		g.OnOverload = guard1(e, "OnOverload", cb.OnOverload, nil)
+	}
+	if cb.OnAudioDropped != nil {
+		g.OnAudioDropped = guard1(e, "OnAudioDropped", cb.OnAudioDropped, nil)
+	}
+	if cb.OnAudioEvicted != nil {
+		g.OnAudioEvicted = guard1(e, "OnAudioEvicted", cb.OnAudioEvicted, nil)
+	}
+	if cb.OnSlowCallback != nil {
+		g.OnSlowCallback = guard1(e, "OnSlowCallback", cb.OnSlowCallback, nil)
+	}
+	if cb.OnError != nil {
+		g.OnError = guard1(e, "OnError", cb.OnError, nil)
+	}
+	if cb.OnVadScore != nil {
+		g.OnVadScore = guard2(e, "OnVadScore", cb.OnVadScore)
+	}
+	if cb.OnTurnPrediction != nil {
+		g.OnTurnPrediction = guard2(e, "OnTurnPrediction", cb.OnTurnPrediction)
+	}
+	return g
+}
+
+// guard0 wraps a callback without arguments.
+func guard0(e *Engine, name string, f func()) func() {
+	return func() {
+		if e.enter() {
+			defer e.leave()
+			defer e.recoverCallback(name)
+			f()
+		}
+	}
+}
+
+// guard1 wraps a callback of one argument; skipped, if set, receives the
+// argument of a call that is not delivered.
+func guard1[T any](e *Engine, name string, f func(T), skipped func(T)) func(T) {
+	return func(v T) {
+		if e.enter() {
+			defer e.leave()
+			defer e.recoverCallback(name)
+			f(v)
+		} else if skipped != nil {
+			skipped(v)
+		}
+	}
+}
+
+// guard2 wraps a callback of two arguments.
+func guard2[A, B any](e *Engine, name string, f func(A, B)) func(A, B) {
+	return func(a A, b B) {
+		if e.enter() {
+			defer e.leave()
+			defer e.recoverCallback(name)
+			f(a, b)
+		}
+	}
+}
+
+// enter reports whether a callback may run and, if so, counts it as
+// executing until leave. Callbacks run on the goroutine holding callMu, so
+// only that one changes depth.
+func (e *Engine) enter() bool {
+	if e.depth.Load() == 0 {
+		e.callbackGo.Store(goroutineID(e.stackBuf[:]))
+	}
+	e.depth.Add(1)
+	if e.closing.Load() && (e.cfg.ClosePolicy == CloseDiscard || e.deferredClose.Load()) {
+		e.leave()
+		return false
+	}
+	return true
+}
+
+func (e *Engine) leave() {
+	if e.depth.Add(-1) == 0 {
+		e.callbackGo.Store(0)
+	}
+}
+
+// inCallback reports whether the calling goroutine is executing a
+// callback.
+func (e *Engine) inCallback() bool {
+	if e.depth.Load() == 0 {
+		return false
+	}
+	var buf [64]byte
+	return e.callbackGo.Load() == goroutineID(buf[:])
+}
+
+// goroutineID returns the id of the calling goroutine, from the header of
+// its stack trace ("goroutine 18 [running]:") written to buf.
+func goroutineID(buf []byte) uint64 {
+	b := buf[:runtime.Stack(buf, false)]
+	var id uint64
+	for _, c := range b[min(len(b), len("goroutine ")):] {
+		if c < '0' || c > '9' {
+			break
+		}
+		id = id*10 + uint64(c-'0')
+	}
+	return id
+}
+
+// acquire serializes an API call with Close. Calls made from inside a
+// callback already run under the lock of the call that invoked it, so they
+// return false and must not call finish.
+func (e *Engine) acquire() bool {
+	if e.inCallback() {
+		return false
+	}
+	e.callMu.Lock()
+	return true
+}
+
+// finish ends a call taken with acquire, releasing the engine first if
+// Close was deferred while it ran.
+func (e *Engine) finish() {
+	for {
+		if e.deferredClose.Load() && !e.closed {
+			e.release()
+		}
+		e.callMu.Unlock()
+		// A deferred Close that raced with the unlock is handled by whoever
+		// takes the lock next, here or in Close.
+		if !e.deferredClose.Load() || !e.callMu.TryLock() {
+			return
+		}
+		if e.closed {
+			e.callMu.Unlock()
+			return
+		}
+	}
+}
+
+// Close stops the engine and releases its sessions and resources. It may be
+// called from any goroutine, also while PushPCM runs on another one: new
+// calls fail with ErrClosed at once, Close waits for the in-flight call per
+// Config.ClosePolicy, and no callback starts after Close returns. Calling it
+// again is a no-op. With Config.InputQueue, CloseDrain also processes the
+// audio still queued, and CloseDiscard drops it.
+//
+// Close may also be called from inside a callback. It then cannot wait for
+// the call that invoked the callback, so it returns at once, the remaining
+// callbacks of that call are dropped, and resources are released when the
+// call returns. A Close from another goroutine waits per the policy also
+// while a callback is executing.
+func (e *Engine) Close() {
+	if !e.closing.CompareAndSwap(false, true) {
+		return
+	}
+	inCallback := e.inCallback()
+	if e.queue != nil {
+		e.queue.close(e.cfg.ClosePolicy == CloseDiscard)
+		if inCallback {
+			e.deferredClose.Store(true)
+			return
+		}
+		<-e.queue.done
+		return
+	}
+	if inCallback {
+		e.deferredClose.Store(true)
+		if e.callMu.TryLock() {
+			if !e.closed {
+				e.release()
+			}
+			e.callMu.Unlock()
+		}
+		return
+	}
+	e.callMu.Lock()
+	if !e.closed {
+		e.release()
+	}
+	e.callMu.Unlock()
+}
+
+// release frees everything the engine holds. Called once, under callMu.
+func (e *Engine) release() {
+	e.closed = true
+	e.listening = false
+	e.health.setState(false, false)
+	if err := e.vad.Close(); err != nil {
+		e.reportError("closing vad backend", err)
+	}
+	if err := e.smartTurn.destroy(); err != nil {
+		e.reportError("closing smart-turn backend", err)
+	}
+	if e.shadow != nil {
+		if err := e.shadow.destroy(); err != nil {
+			e.reportError("closing shadow smart-turn backend", err)
+		}
+	}
+	if m := e.reload.Swap(nil); m != nil {
+		if err := m.close(); err != nil {
+			e.reportError("closing reloaded models", err)
+		}
+	}
+	if e.asyncCalls != nil {
+		close(e.asyncCalls)
+	}
+	if e.cfg.WakeWord != nil {
+		if err := e.cfg.WakeWord.Close(); err != nil {
+			e.reportError("closing wake word detector", err)
+		}
+	}
+	if e.recorder != nil {
+		if err := e.recorder.close(); err != nil {
+			e.reportError("closing debug audio recording", err)
+		}
+	}
+	if e.usesRuntime {
+		e.usesRuntime = false
+		if err := releaseRuntime(); err != nil {
+			e.reportError("releasing onnx runtime", err)
+		}
+	}
+	e.log.Info("engine closed")
+}
+
+// ErrCallbackPanic is wrapped by the error reported when a callback
+// panics; see CallbackPanic.
+var ErrCallbackPanic = errors.New("callback panicked")
+
+// CallbackPanic is passed to OnError when a callback panics. The panic is
+// recovered where the engine invoked the callback, so processing goes on
+// with the next step as if the callback had returned.
+type CallbackPanic struct {
+	Callback string // e.g. "OnSpeechEnd"
+	Value    any    // the value passed to panic
+	Stack    []byte // the panicking goroutine's stack
+}
+
+func (p *CallbackPanic) Error() string {
+	return fmt.Sprintf("%s panicked: %v", p.Callback, p.Value)
+}
+
+func (p *CallbackPanic) Unwrap() error { return ErrCallbackPanic }
+
+// recoverCallback, deferred by a callback wrapper, reports a panic of the
+// callback name like reportError. A panic in OnError is only logged and
+// counted, since reporting it would call OnError again.
+func (e *Engine) recoverCallback(name string) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	err := &CallbackPanic{Callback: name, Value: v, Stack: debug.Stack()}
+	e.log.Error("callback panicked", "err", err, "stack", string(err.Stack))
+	e.health.error(err, e.clock.Now())
+	if name == "OnError" {
+		return
+	}
+	e.record(Event{Kind: EventError, Err: err})
+	if e.cb.OnError != nil {
+		e.cb.OnError(err)
+	}
+}
@@ -0,0 +1,323 @@
+package smartturn_test
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// blockingTurn is a TurnBackend whose Predict reports that it started and
+// waits for release.
+type blockingTurn struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingTurn) Predict([]float32) (float32, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return 0.9, nil
+}
+
+func (b *blockingTurn) Close() error { return nil }
+
+// callbackCounter counts the calls of every callback it sets.
+type callbackCounter struct {
+	n                          atomic.Int64
+	predictions, ends, turnEnd atomic.Int64
+}
+
+func (c *callbackCounter) callbacks(extra func()) smartturn.Callbacks {
+	hit := func() {
+		c.n.Add(1)
+		if extra != nil {
+			extra()
+		}
+	}
+	return smartturn.Callbacks{
+		OnSpeechStart:  hit,
+		OnSpeechEnd:    func() { hit(); c.ends.Add(1) },
+		OnTurnEnd:      func(smartturn.TurnEndReason) { hit(); c.turnEnd.Add(1) },
+		OnVadScore:     func(float32, int64) { hit() },
+		OnChunk:        func([]float32) { hit() },
+		OnSegmentReady: func([]float32) { hit() },
+		OnSegment:      func(s *smartturn.Segment) { hit(); s.Release() },
+		OnTurnPrediction: func(bool, float32) {
+			hit()
+			c.predictions.Add(1)
+		},
+		OnTurnPredictionDetail: func(smartturn.TurnPrediction) { hit() },
+	}
+}
+
+// speechTurns is speech ended by silence, repeated.
+func speechTurns(n int) [][]float32 {
+	var parts []smartturntest.Part
+	for range n {
+		parts = append(parts, smartturntest.Speech(600*time.Millisecond), smartturntest.Silence(500*time.Millisecond))
+	}
+	audio, _ := smartturntest.Synth{Seed: 3}.Generate(parts...)
+	return smartturntest.Chunks(audio)
+}
+
+// TestCloseInFlight closes the engine while a PushPCM is blocked in
+// Smart-Turn inference on another goroutine. Close must wait for it;
+// CloseDrain then delivers the decision and CloseDiscard drops it.
+func TestCloseInFlight(t *testing.T) {
+	for _, policy := range []smartturn.ClosePolicy{smartturn.CloseDrain, smartturn.CloseDiscard} {
+		name := map[smartturn.ClosePolicy]string{smartturn.CloseDrain: "drain", smartturn.CloseDiscard: "discard"}[policy]
+		t.Run(name, func(t *testing.T) {
+			turn := &blockingTurn{started: make(chan struct{}, 1), release: make(chan struct{})}
+			var c callbackCounter
+			e := newTestEngine(t, nil, c.callbacks(nil), func(cfg *smartturn.Config) {
+				cfg.TurnBackend = turn
+				cfg.ClosePolicy = policy
+			})
+			pushed := make(chan error, 1)
+			go func() {
+				for _, chunk := range speechTurns(1) {
+					if err := e.PushPCM(chunk); err != nil {
+						pushed <- err
+						return
+					}
+				}
+				pushed <- nil
+			}()
+			<-turn.started
+
+			closed := make(chan struct{})
+			go func() {
+				e.Close()
+				close(closed)
+			}()
+			time.Sleep(20 * time.Millisecond)
+			select {
+			case <-closed:
+				t.Fatal("Close returned while PushPCM was in flight")
+			default:
+			}
+			close(turn.release)
+			<-closed
+			err := <-pushed
+			if err != nil && !errors.Is(err, smartturn.ErrClosed) {
+				t.Fatalf("PushPCM: %v", err)
+			}
+
+			want := int64(1)
+			if policy == smartturn.CloseDiscard {
+				want = 0
+			}
+			if got := c.predictions.Load(); got != want {
+				t.Errorf("OnTurnPrediction calls = %d, want %d", got, want)
+			}
+			if got := c.ends.Load(); got != want {
+				t.Errorf("OnSpeechEnd calls = %d, want %d", got, want)
+			}
+			if got := c.turnEnd.Load(); got != want {
+				t.Errorf("OnTurnEnd calls = %d, want %d", got, want)
+			}
+			n := c.n.Load()
+			if err := e.PushPCM(make([]float32, smartturn.RequiredChunkSize)); !errors.Is(err, smartturn.ErrClosed) {
+				t.Errorf("PushPCM after Close: %v, want ErrClosed", err)
+			}
+			e.Reset()
+			e.Stop()
+			if got := c.n.Load(); got != n {
+				t.Errorf("%d callbacks ran after Close returned", got-n)
+			}
+		})
+	}
+}
+
+// TestCloseRace closes engines at varying points of a PushPCM loop on
+// another goroutine, from outside and from inside a callback, with each
+// policy and with and without an input queue, for the race detector.
+// Every engine must stop delivering callbacks once Close has returned and
+// the pushing goroutine has seen ErrClosed.
+func TestCloseRace(t *testing.T) {
+	chunks := speechTurns(3)
+	for _, policy := range []smartturn.ClosePolicy{smartturn.CloseDrain, smartturn.CloseDiscard} {
+		for _, queue := range []int{0, 8} {
+			for _, inside := range []bool{false, true} {
+				for round := range 3 {
+					var (
+						c    callbackCounter
+						e    *smartturn.Engine
+						once sync.Once
+					)
+					var extra func()
+					if inside {
+						// The callback Close lands in varies with the round.
+						limit := int64(40 + 97*round)
+						extra = func() {
+							if c.n.Load() >= limit {
+								once.Do(e.Close)
+							}
+						}
+					}
+					e = newTestEngine(t, []float32{0.2, 0.9}, c.callbacks(extra), func(cfg *smartturn.Config) {
+						cfg.ClosePolicy = policy
+						cfg.InputQueue = smartturn.InputQueue{Size: queue}
+					})
+					done := make(chan struct{})
+					go func() {
+						defer close(done)
+						for i := 0; ; i++ {
+							if err := e.PushPCM(chunks[i%len(chunks)]); err != nil {
+								if !errors.Is(err, smartturn.ErrClosed) {
+									t.Errorf("PushPCM: %v", err)
+								}
+								return
+							}
+						}
+					}()
+					if !inside {
+						time.Sleep(time.Duration(3*round) * time.Millisecond)
+						e.Close()
+					}
+					<-done
+					e.Close()
+					n := c.n.Load()
+					if err := e.PushPCM(chunks[0]); !errors.Is(err, smartturn.ErrClosed) {
+						t.Errorf("PushPCM after Close: %v", err)
+					}
+					if got := c.n.Load(); got != n {
+						t.Errorf("policy %d queue %d inside %v: %d callbacks after Close", policy, queue, inside, got-n)
+					}
+					if c.ends.Load() != c.turnEnd.Load() && policy == smartturn.CloseDrain && !inside {
+						t.Errorf("OnSpeechEnd %d times but OnTurnEnd %d", c.ends.Load(), c.turnEnd.Load())
+					}
+				}
+			}
+		}
+	}
+}
+
+// closingTurn is a TurnBackend that records its Close.
+type closingTurn struct {
+	smartturntest.TurnScript
+	closed atomic.Bool
+}
+
+func (c *closingTurn) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+// TestCloseDuringCallback closes the engine from another goroutine while
+// a slow OnTurnEnd runs. Close must wait for the callback and the rest of
+// its call, and release the engine before it returns.
+func TestCloseDuringCallback(t *testing.T) {
+	for _, policy := range []smartturn.ClosePolicy{smartturn.CloseDrain, smartturn.CloseDiscard} {
+		turn := &closingTurn{TurnScript: smartturntest.TurnScript{Probabilities: []float32{0.9}}}
+		inside, release := make(chan struct{}), make(chan struct{})
+		var returned atomic.Bool
+		e := newTestEngine(t, nil, smartturn.Callbacks{
+			OnTurnEnd: func(smartturn.TurnEndReason) {
+				close(inside)
+				<-release
+				returned.Store(true)
+			},
+		}, func(cfg *smartturn.Config) {
+			cfg.TurnBackend = turn
+			cfg.ClosePolicy = policy
+		})
+		go func() {
+			for _, chunk := range speechTurns(1) {
+				if e.PushPCM(chunk) != nil {
+					return
+				}
+			}
+		}()
+		<-inside
+
+		closed := make(chan struct{})
+		go func() {
+			e.Close()
+			close(closed)
+		}()
+		time.Sleep(20 * time.Millisecond)
+		select {
+		case <-closed:
+			t.Fatalf("policy %d: Close returned while OnTurnEnd ran", policy)
+		default:
+		}
+		if turn.closed.Load() {
+			t.Fatalf("policy %d: backend closed while OnTurnEnd ran", policy)
+		}
+		close(release)
+		<-closed
+		if !returned.Load() || !turn.closed.Load() {
+			t.Errorf("policy %d: Close returned before OnTurnEnd (%v) or the release (%v)", policy, returned.Load(), turn.closed.Load())
+		}
+	}
+}
+
+// TestCallbackPanic checks that a panicking callback is recovered and
+// reported to OnError as a CallbackPanic, and that the chunk and the turn
+// go on as if it had returned.
+func TestCallbackPanic(t *testing.T) {
+	var errs []error
+	var calls []string
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+		OnSpeechStart: func() {
+			calls = append(calls, "speech start")
+			panic("boom")
+		},
+		OnTurnStart: func(smartturn.TurnStart) { calls = append(calls, "turn start") },
+		OnSpeechEnd: func() { calls = append(calls, "speech end") },
+		OnTurnEnd:   func(smartturn.TurnEndReason) { calls = append(calls, "turn end") },
+		OnError:     func(err error) { errs = append(errs, err) },
+	}, nil)
+	for _, c := range speechTurns(2) {
+		if err := e.PushPCM(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "turn start,speech start,turn end,speech end,turn start,speech start,turn end,speech end"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("callbacks %q, want %q", got, want)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("OnError %d times, want 2: %v", len(errs), errs)
+	}
+	var p *smartturn.CallbackPanic
+	if !errors.As(errs[0], &p) || !errors.Is(errs[0], smartturn.ErrCallbackPanic) || p.Callback != "OnSpeechStart" || p.Value != "boom" || len(p.Stack) == 0 {
+		t.Errorf("error %#v", errs[0])
+	}
+	if h := e.Health(); h.Errors != 2 || !strings.Contains(h.LastError, "OnSpeechStart panicked: boom") {
+		t.Errorf("health errors %d, last %q", h.Errors, h.LastError)
+	}
+}
+
+// TestOnErrorPanic checks that a panic in OnError is recovered and
+// counted but not reported to OnError again.
+func TestOnErrorPanic(t *testing.T) {
+	var onError, ends int
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+		OnSpeechStart: func() { panic("first") },
+		OnSpeechEnd:   func() { ends++ },
+		OnError: func(error) {
+			onError++
+			panic("second")
+		},
+	}, nil)
+	for _, c := range speechTurns(1) {
+		if err := e.PushPCM(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if onError != 1 || ends != 1 {
+		t.Errorf("OnError %d times, OnSpeechEnd %d times; want 1 each", onError, ends)
+	}
+	// Both panics count as errors; the second is the last.
+	if h := e.Health(); h.Errors != 2 || !strings.Contains(h.LastError, "OnError panicked: second") {
+		t.Errorf("health errors %d, last %q", h.Errors, h.LastError)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cortexswarm/smart-turn-go/realtime"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var errUnauthorized = errors.New("missing or invalid API key")
+
+// apiKeys authenticates Realtime requests by API key. Keys are held as
+// SHA-256 digests, so a lookup takes the same time however much of a
+// guessed key matches.
+type apiKeys map[[sha256.Size]byte]realtime.Tenant
+
+// loadAPIKeys reads a key file: one "tenant key [max-sessions]" line per
+// key, with blank lines and lines starting with # ignored. A tenant may
+// have several keys; max-sessions defaults to defaultMax.
+func loadAPIKeys(path string, defaultMax int) (apiKeys, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	keys := make(apiKeys)
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("%s:%d: want \"tenant key [max-sessions]\"", path, line)
+		}
+		t := realtime.Tenant{ID: fields[0], MaxSessions: defaultMax}
+		if len(fields) == 3 {
+			if t.MaxSessions, err = strconv.Atoi(fields[2]); err != nil || t.MaxSessions < 0 {
+				return nil, fmt.Errorf("%s:%d: invalid max-sessions %q", path, line, fields[2])
+			}
+		}
+		sum := sha256.Sum256([]byte(fields[1]))
+		if _, dup := keys[sum]; dup {
+			return nil, fmt.Errorf("%s:%d: duplicate key", path, line)
+		}
+		keys[sum] = t
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: no keys", path)
+	}
+	return keys, nil
+}
+
+// authenticate implements realtime.Server.Authenticate.
+func (k apiKeys) authenticate(r *http.Request) (realtime.Tenant, error) {
+	key := realtime.APIKey(r)
+	if key == "" {
+		return realtime.Tenant{}, errUnauthorized
+	}
+	t, ok := k[sha256.Sum256([]byte(key))]
+	if !ok {
+		return realtime.Tenant{}, errUnauthorized
+	}
+	return t, nil
+}
+
+// tenantSessions exports the sessions of each tenant as a gauge.
+type tenantSessions struct {
+	srv  *realtime.Server
+	desc *prometheus.Desc
+}
+
+func newTenantSessions(srv *realtime.Server) *tenantSessions {
+	return &tenantSessions{srv: srv, desc: prometheus.NewDesc("smartturn_tenant_sessions",
+		"Realtime sessions of each tenant, connected or waiting to be resumed.", []string{"tenant"}, nil)}
+}
+
+// Describe implements prometheus.Collector.
+func (c *tenantSessions) Describe(ch chan<- *prometheus.Desc) { ch <- c.desc }
+
+// Collect implements prometheus.Collector.
+func (c *tenantSessions) Collect(ch chan<- prometheus.Metric) {
+	for tenant, n := range c.srv.TenantSessions() {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), tenant)
+	}
+}
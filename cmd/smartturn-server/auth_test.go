@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cortexswarm/smart-turn-go/realtime"
+)
+
+func writeKeys(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	keys, err := loadAPIKeys(writeKeys(t, `
+# tenant key [max-sessions]
+acme  sk-acme-1 4
+acme  sk-acme-2
+other sk-other 0
+`), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		header http.Header
+		want   realtime.Tenant
+		ok     bool
+	}{
+		{http.Header{"Authorization": {"Bearer sk-acme-1"}}, realtime.Tenant{ID: "acme", MaxSessions: 4}, true},
+		{http.Header{"Authorization": {"Bearer sk-acme-2"}}, realtime.Tenant{ID: "acme", MaxSessions: 2}, true},
+		{http.Header{"Sec-Websocket-Protocol": {"realtime, openai-insecure-api-key.sk-other"}}, realtime.Tenant{ID: "other"}, true},
+		{http.Header{"Authorization": {"Bearer sk-acme"}}, realtime.Tenant{}, false},
+		{http.Header{}, realtime.Tenant{}, false},
+	} {
+		got, err := keys.authenticate(&http.Request{Header: tc.header})
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("%v: authenticate = %+v, %v; want %+v, ok %v", tc.header, got, err, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestLoadAPIKeysErrors(t *testing.T) {
+	for _, tc := range []struct{ content, want string }{
+		{"acme\n", "want"},
+		{"acme sk 1 extra\n", "want"},
+		{"acme sk -1\n", "invalid max-sessions"},
+		{"acme sk\nother sk\n", "duplicate key"},
+		{"# nothing\n", "no keys"},
+	} {
+		_, err := loadAPIKeys(writeKeys(t, tc.content), 0)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%q: error %v, want one containing %q", tc.content, err, tc.want)
+		}
+	}
+}
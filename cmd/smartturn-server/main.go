@@ -0,0 +1,296 @@
+// Command smartturn-server serves turn detection over WebSocket with the
+// messages of the OpenAI Realtime API (see package realtime), for running
+// as a container:
+//
+//	smartturn-server -addr :8080 -models-dir /models
+//
+// Endpoints:
+//   - /v1/realtime: Realtime sessions (WebSocket)
+//   - /healthz: 200 while serving, 503 while draining, with a JSON body
+//   - /metrics: Prometheus metrics of all sessions
+//
+// Every flag can also be set from the environment as SMARTTURN_ followed
+// by the flag name in upper case with underscores, e.g.
+// SMARTTURN_TURN_THRESHOLD=0.7; flags given on the command line win. At
+// startup the models are resolved into -models-dir, downloading those
+// missing, unless their paths are given. ONNX Runtime is taken from
+// -onnxruntime, ONNXRUNTIME_SHARED_LIBRARY_PATH, or the resolver.
+//
+// With -api-keys, clients must present a key from the file, as a bearer
+// token or an openai-insecure-api-key subprotocol. Each key belongs to a
+// tenant with its own session limit, and the engine metrics are labeled
+// by tenant.
+//
+// With -tls-cert and -tls-key the server speaks HTTPS (wss://). Adding
+// -tls-client-ca requires client certificates signed by those CAs (mTLS);
+// -tls-client-auth verify-if-given keeps /healthz reachable for probes
+// without one.
+//
+// On SIGTERM or SIGINT the server stops accepting sessions, reports 503 on
+// /healthz, and waits up to -drain-timeout for connected sessions to end
+// before closing them.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/internal/resolver"
+	"github.com/cortexswarm/smart-turn-go/metrics"
+	"github.com/cortexswarm/smart-turn-go/realtime"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// envPrefix prefixes the environment variable of every flag.
+const envPrefix = "SMARTTURN_"
+
+var (
+	addr          = flag.String("addr", ":8080", "listen address")
+	modelsDir     = flag.String("models-dir", resolver.ModelsDir, "directory models are resolved and downloaded into")
+	sileroPath    = flag.String("silero", "", "path to silero_vad.onnx (default: resolved in -models-dir)")
+	smartTurnPath = flag.String("smart-turn", "", "path to the Smart-Turn model (default: resolved in -models-dir)")
+	onnxRuntime   = flag.String("onnxruntime", "", "path to the ONNX Runtime shared library")
+	modelCache    = flag.String("model-cache", "pin", "pin uses downloaded models as is; revalidate checks them against their source at startup")
+	vadThreshold  = flag.Float64("vad-threshold", 0.5, "Config.VadThreshold")
+	preSpeechMs   = flag.Int("pre-speech-ms", 300, "Config.VadPreSpeechMs")
+	stopMs        = flag.Int("stop-ms", 500, "Config.VadStopMs")
+	maxDuration   = flag.Float64("max-duration", 600, "Config.TurnMaxDurationSeconds")
+	emitMs        = flag.Int("emit-ms", 1000, "Config.TurnSegmentEmitMs")
+	turnThreshold = flag.Float64("turn-threshold", 0.5, "Config.TurnThreshold")
+	timeoutMs     = flag.Int("timeout-ms", 3000, "Config.TurnTimeoutMs")
+	resumeWindow  = flag.Duration("resume-window", 30*time.Second, "how long a dropped session can be resumed; 0 disables")
+	maxSessions   = flag.Int("max-sessions", 0, "sessions to admit before refusing with 429; 0 means no limit")
+	apiKeyFile    = flag.String("api-keys", "", "file of \"tenant key [max-sessions]\" lines; when set, clients must present one of the keys")
+	tenantMax     = flag.Int("tenant-max-sessions", 0, "sessions per tenant when the -api-keys line gives none; 0 means no limit")
+	maxChunkRate  = flag.Float64("max-chunk-rate", 0, "audio chunks (32 ms) per second a session may send, real time being 31.25; 0 means no limit")
+	tlsCert       = flag.String("tls-cert", "", "PEM certificate chain; with -tls-key, serve HTTPS")
+	tlsKey        = flag.String("tls-key", "", "PEM private key of -tls-cert")
+	tlsClientCA   = flag.String("tls-client-ca", "", "PEM CAs that client certificates are verified against (mTLS)")
+	tlsClientAuth = flag.String("tls-client-auth", "", "none, request, require, verify-if-given or require-and-verify (default with -tls-client-ca)")
+	origins       = flag.String("allowed-origins", "", "comma-separated browser origins allowed besides the server's own, e.g. https://app.example.com or https://*.example.com; * allows any")
+	drainTimeout  = flag.Duration("drain-timeout", 30*time.Second, "how long to wait for sessions to end on shutdown")
+	logLevel      = flag.String("log-level", "info", "debug, info, warn or error")
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "smartturn-server: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	if err := flagsFromEnv(); err != nil {
+		return err
+	}
+	flag.Parse()
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		return fmt.Errorf("-log-level: %w", err)
+	}
+	log := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	slog.SetDefault(log)
+
+	if err := resolveModels(); err != nil {
+		return err
+	}
+	var keys apiKeys
+	if *apiKeyFile != "" {
+		var err error
+		if keys, err = loadAPIKeys(*apiKeyFile, *tenantMax); err != nil {
+			return fmt.Errorf("-api-keys: %w", err)
+		}
+	}
+	m := metrics.New(metrics.Options{PerTenant: keys != nil})
+	cfg := smartturn.Config{
+		SampleRate:             smartturn.RequiredSampleRate,
+		ChunkSize:              smartturn.RequiredChunkSize,
+		VadThreshold:           float32(*vadThreshold),
+		VadPreSpeechMs:         *preSpeechMs,
+		VadStopMs:              *stopMs,
+		TurnMaxDurationSeconds: float32(*maxDuration),
+		TurnSegmentEmitMs:      *emitMs,
+		TurnThreshold:          float32(*turnThreshold),
+		TurnTimeoutMs:          *timeoutMs,
+		SileroVADModelPath:     *sileroPath,
+		SmartTurnModelPath:     *smartTurnPath,
+		ONNXRuntimeLibPath:     *onnxRuntime,
+		Observer:               m,
+		Logger:                 log.With("component", "engine"),
+	}
+	group, err := smartturn.NewSessionGroup(cfg)
+	if err != nil {
+		return err
+	}
+	defer group.Close()
+	srv, err := realtime.NewServer(group)
+	if err != nil {
+		return err
+	}
+	srv.Log = log
+	srv.ResumeWindow = *resumeWindow
+	srv.MaxSessions, srv.MaxChunkRate = *maxSessions, *maxChunkRate
+	for _, o := range strings.Split(*origins, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			srv.AllowedOrigins = append(srv.AllowedOrigins, o)
+		}
+	}
+	if keys != nil {
+		srv.Authenticate = keys.authenticate
+		srv.Observer = m.Tenant
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(m,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "smartturn",
+			Name:      "sessions",
+			Help:      "Connected Realtime sessions.",
+		}, func() float64 { return float64(srv.Sessions()) }),
+	)
+	if keys != nil {
+		reg.MustRegister(newTenantSessions(srv))
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/v1/realtime", srv)
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		status, code := "ok", http.StatusOK
+		if srv.Draining() {
+			status, code = "draining", http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": status, "sessions": srv.Sessions()})
+	})
+	hs := &http.Server{Addr: *addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	if hs.TLSConfig, err = tlsConfig(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() {
+		if hs.TLSConfig != nil {
+			errc <- hs.ListenAndServeTLS("", "")
+		} else {
+			errc <- hs.ListenAndServe()
+		}
+	}()
+	log.Info("serving", "addr", *addr, "tls", hs.TLSConfig != nil, "client_auth", hs.TLSConfig != nil && hs.TLSConfig.ClientAuth != tls.NoClientCert)
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Info("draining", "sessions", srv.Sessions(), "timeout", *drainTimeout)
+	dctx, cancel := context.WithTimeout(context.Background(), *drainTimeout)
+	defer cancel()
+	go func() { _ = hs.Shutdown(dctx) }() // stops the listener; sessions are hijacked
+	if err := srv.Shutdown(dctx); err != nil {
+		log.Warn("closed sessions still connected after the drain timeout")
+	}
+	_ = hs.Close()
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Info("stopped")
+	return nil
+}
+
+// flagsFromEnv sets every flag whose environment variable is set, before
+// flag.Parse lets the command line override it.
+func flagsFromEnv() error {
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if v, ok := os.LookupEnv(name); ok && err == nil {
+			if serr := f.Value.Set(v); serr != nil {
+				err = fmt.Errorf("%s: %w", name, serr)
+			}
+		}
+	})
+	return err
+}
+
+// clientAuth maps -tls-client-auth values to policies.
+var clientAuth = map[string]tls.ClientAuthType{
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify-if-given":    tls.VerifyClientCertIfGiven,
+	"require-and-verify": tls.RequireAndVerifyClientCert,
+}
+
+// tlsConfig returns the TLS configuration of the -tls flags, or nil to
+// serve plain HTTP.
+func tlsConfig() (*tls.Config, error) {
+	if *tlsCert == "" && *tlsKey == "" {
+		if *tlsClientCA != "" || *tlsClientAuth != "" {
+			return nil, errors.New("-tls-client-ca and -tls-client-auth need -tls-cert and -tls-key")
+		}
+		return nil, nil
+	}
+	opts := realtime.TLSOptions{CertFile: *tlsCert, KeyFile: *tlsKey, ClientCAFile: *tlsClientCA}
+	if *tlsClientAuth != "" {
+		policy, ok := clientAuth[*tlsClientAuth]
+		if !ok {
+			return nil, fmt.Errorf("-tls-client-auth: unknown policy %q", *tlsClientAuth)
+		}
+		if policy == tls.NoClientCert && *tlsClientCA != "" {
+			return nil, errors.New("-tls-client-auth none contradicts -tls-client-ca")
+		}
+		opts.ClientAuth = policy
+	}
+	return opts.Config()
+}
+
+// resolveModels fills in the model and library paths not given, downloading
+// missing models into -models-dir.
+func resolveModels() error {
+	switch *modelCache {
+	case "pin":
+		resolver.Cache = resolver.CachePin
+	case "revalidate":
+		resolver.Cache = resolver.CacheRevalidate
+	default:
+		return fmt.Errorf("-model-cache: want pin or revalidate, got %q", *modelCache)
+	}
+	var err error
+	if *sileroPath == "" {
+		if *sileroPath, err = resolver.ResolveSileroVAD(*modelsDir); err != nil {
+			return fmt.Errorf("resolve Silero VAD: %w", err)
+		}
+	}
+	if *smartTurnPath == "" {
+		if *smartTurnPath, err = resolver.ResolveSmartTurn(*modelsDir); err != nil {
+			return fmt.Errorf("resolve Smart-Turn: %w", err)
+		}
+	}
+	if *onnxRuntime == "" && os.Getenv(smartturn.EnvONNXRuntimeLib) == "" {
+		// "" when the resolver has nothing for this platform: New then
+		// reports the missing library.
+		if *onnxRuntime, err = resolver.ResolveONNXRuntimeLibWithDownload(*modelsDir); err != nil {
+			return fmt.Errorf("resolve ONNX Runtime: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTLSFlags checks the -tls flag combinations refused before any file
+// is read.
+func TestTLSFlags(t *testing.T) {
+	defer func(cert, key, ca, auth string) {
+		*tlsCert, *tlsKey, *tlsClientCA, *tlsClientAuth = cert, key, ca, auth
+	}(*tlsCert, *tlsKey, *tlsClientCA, *tlsClientAuth)
+	for _, tc := range []struct {
+		cert, key, ca, auth string
+		want                string // error substring; "" for plain HTTP
+	}{
+		{"", "", "", "", ""},
+		{"", "", "ca.pem", "", "need -tls-cert"},
+		{"", "", "", "require", "need -tls-cert"},
+		{"c.pem", "k.pem", "", "sometimes", "unknown policy"},
+		{"c.pem", "k.pem", "ca.pem", "none", "contradicts"},
+		{"c.pem", "", "", "", "both"},
+	} {
+		*tlsCert, *tlsKey, *tlsClientCA, *tlsClientAuth = tc.cert, tc.key, tc.ca, tc.auth
+		cfg, err := tlsConfig()
+		switch {
+		case tc.want == "" && (err != nil || cfg != nil):
+			t.Errorf("%+v: %v, %v; want plain HTTP", tc, cfg, err)
+		case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+			t.Errorf("%+v: error %v, want one containing %q", tc, err, tc.want)
+		}
+	}
+}
+
+// TestFlagsFromEnv checks that environment variables set flags, that the
+// command line overrides them, and that a bad value names its variable.
+func TestFlagsFromEnv(t *testing.T) {
+	names := []string{"stop-ms", "turn-threshold", "resume-window", "allowed-origins", "max-sessions"}
+	saved := map[string]string{}
+	for _, n := range names {
+		saved[n] = flag.Lookup(n).Value.String()
+	}
+	defer func() {
+		for n, v := range saved {
+			_ = flag.Set(n, v)
+		}
+	}()
+
+	t.Setenv("SMARTTURN_STOP_MS", "250")
+	t.Setenv("SMARTTURN_TURN_THRESHOLD", "0.7")
+	t.Setenv("SMARTTURN_RESUME_WINDOW", "5s")
+	t.Setenv("SMARTTURN_ALLOWED_ORIGINS", "https://a.example.com,https://b.example.com")
+	if err := flagsFromEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if *stopMs != 250 || *turnThreshold != 0.7 || *resumeWindow != 5*time.Second ||
+		*origins != "https://a.example.com,https://b.example.com" {
+		t.Errorf("from the environment: stop-ms %d, turn-threshold %v, resume-window %v, allowed-origins %q",
+			*stopMs, *turnThreshold, *resumeWindow, *origins)
+	}
+	if err := flag.CommandLine.Parse([]string{"-stop-ms", "400"}); err != nil {
+		t.Fatal(err)
+	}
+	if *stopMs != 400 || *turnThreshold != 0.7 {
+		t.Errorf("after the command line: stop-ms %d, turn-threshold %v; want 400 and 0.7", *stopMs, *turnThreshold)
+	}
+
+	// Flags are visited in lexical order; the first bad value is reported.
+	t.Setenv("SMARTTURN_MAX_SESSIONS", "many")
+	t.Setenv("SMARTTURN_STOP_MS", "soon")
+	if err := flagsFromEnv(); err == nil || !strings.Contains(err.Error(), "SMARTTURN_MAX_SESSIONS") {
+		t.Errorf("bad values: %v, want an error naming SMARTTURN_MAX_SESSIONS", err)
+	}
+}
@@ -0,0 +1,302 @@
+// Command smartturn holds tools for working with smart-turn-go.
+//
+//	smartturn init-config [-profile name] [-format json|yaml] [-o file] [-force]
+//
+// init-config writes an annotated starting configuration: the tuning
+// fields of smartturn.Config with recommended values for a profile
+// (conversational, telephony, dictation, push-to-talk or far-field) and a
+// comment on each. The JSON form decodes into smartturn.Config with
+// encoding/json, which skips the "//" comment keys:
+//
+//	var cfg smartturn.Config
+//	err := json.Unmarshal(data, &cfg)
+//
+// The YAML form carries the same keys, for YAML decoders that go through
+// encoding/json (e.g. sigs.k8s.io/yaml).
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cortexswarm/smart-turn-go"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "smartturn: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return errors.New("no command")
+	}
+	switch args[0] {
+	case "init-config":
+		return initConfig(args[1:])
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+		return nil
+	default:
+		usage(os.Stderr)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, `Usage: smartturn <command> [flags]
+
+Commands:
+  init-config  write an annotated config file for a profile (%s)
+`, strings.Join(profileNames(), ", "))
+}
+
+func initConfig(args []string) error {
+	fs := flag.NewFlagSet("init-config", flag.ContinueOnError)
+	name := fs.String("profile", "conversational", "profile: "+strings.Join(profileNames(), ", "))
+	format := fs.String("format", "", "json or yaml (default: from the -o extension, else json)")
+	out := fs.String("o", "", "file to write (default: standard output)")
+	force := fs.Bool("force", false, "overwrite an existing -o file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("init-config: unexpected argument %q", fs.Arg(0))
+	}
+	p, ok := findProfile(*name)
+	if !ok {
+		return fmt.Errorf("init-config: unknown profile %q (want %s)", *name, strings.Join(profileNames(), ", "))
+	}
+	if *format == "" {
+		*format = "json"
+		if ext := filepath.Ext(*out); ext == ".yaml" || ext == ".yml" {
+			*format = "yaml"
+		}
+	}
+	var buf bytes.Buffer
+	switch *format {
+	case "json":
+		writeJSON(&buf, p)
+	case "yaml":
+		writeYAML(&buf, p)
+	default:
+		return fmt.Errorf("init-config: -format: want json or yaml, got %q", *format)
+	}
+	if *out == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	mode := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if *force {
+		mode = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(*out, mode, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("init-config: %s exists; use -force to overwrite it", *out)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// setting is one field written to the config file.
+type setting struct {
+	name  string
+	value any
+	doc   string
+}
+
+// settings lists the fields of p in file order; a profile's note on a
+// field follows the field's own description.
+func settings(p profile) []setting {
+	c := p.cfg
+	s := []setting{
+		{"SampleRate", c.SampleRate, "Input sample rate: 16000, or 8000 for narrowband telephony audio."},
+		{"ChunkSize", c.ChunkSize, "Samples per Process call: 512 at 16000, 256 at 8000."},
+		{"VadThreshold", c.VadThreshold, "Silero speech probability that counts a chunk as speech. Raise it to ignore background noise, lower it for quiet talkers."},
+		{"VadPreSpeechMs", c.VadPreSpeechMs, "Audio kept from before speech was detected, so word onsets are not clipped."},
+		{"VadStopMs", c.VadStopMs, "Trailing silence that ends VAD speech and runs Smart-Turn on the segment. Shorter reacts faster at the cost of more inferences."},
+		{"TurnMaxDurationSeconds", c.TurnMaxDurationSeconds, "Hard cap on one turn; speech reaching it ends the turn."},
+		{"SplitLongTurns", c.SplitLongTurns, "Continue speech that reaches TurnMaxDurationSeconds in a new segment instead of ending the turn."},
+		{"TurnSplitOverlapMs", c.TurnSplitOverlapMs, "Audio a split segment repeats from the end of the previous one."},
+		{"TurnSegmentEmitMs", c.TurnSegmentEmitMs, "How often OnSegmentReady delivers audio while speech is active."},
+		{"TurnThreshold", c.TurnThreshold, "Smart-Turn probability at or above which a pause ends the turn. Raise it to interrupt less, lower it to respond sooner."},
+		{"TurnSmoothing", c.TurnSmoothing, "Weight of earlier Smart-Turn evaluations within a turn, in [0, 1); 0 disables smoothing."},
+		{"TurnMergeGapMs", c.TurnMergeGapMs, "Speech resuming within this long of a turn end continues that turn; 0 disables merging."},
+		{"TurnTimeoutMs", c.TurnTimeoutMs, "Silence after an incomplete prediction before the turn is ended anyway."},
+		{"DetectDTMF", c.DetectDTMF, "Report keypad tones through OnDTMF and keep them out of speech."},
+		{"SileroVADModelPath", c.SileroVADModelPath, "Path to silero_vad.onnx."},
+		{"SmartTurnModelPath", c.SmartTurnModelPath, "Path to the Smart-Turn model."},
+		{"ONNXRuntimeLibPath", c.ONNXRuntimeLibPath, "ONNX Runtime shared library; empty uses " + smartturn.EnvONNXRuntimeLib + " or the onnxruntime_go default."},
+	}
+	for i := range s {
+		if note := p.notes[s[i].name]; note != "" {
+			s[i].doc += " " + p.name + ": " + note
+		}
+	}
+	return s
+}
+
+func writeJSON(w io.Writer, p profile) {
+	fmt.Fprintf(w, "{\n  %s: %s", quote("//"), quote(header(p)))
+	for _, s := range settings(p) {
+		v, _ := json.Marshal(s.value)
+		fmt.Fprintf(w, ",\n\n  %s: %s,\n  %s: %s", quote("//"+s.name), quote(s.doc), quote(s.name), v)
+	}
+	fmt.Fprint(w, "\n}\n")
+}
+
+func writeYAML(w io.Writer, p profile) {
+	fmt.Fprint(w, comment(header(p)))
+	for _, s := range settings(p) {
+		v, _ := json.Marshal(s.value) // JSON scalars are YAML scalars
+		fmt.Fprintf(w, "\n%s%s: %s\n", comment(s.doc), s.name, v)
+	}
+}
+
+func header(p profile) string {
+	return fmt.Sprintf("smart-turn-go %s config, profile %s: %s", smartturn.SDKVersion, p.name, p.doc)
+}
+
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// comment wraps doc into "# " lines of at most 78 columns.
+func comment(doc string) string {
+	var b strings.Builder
+	line := "#"
+	for _, word := range strings.Fields(doc) {
+		if len(line)+1+len(word) > 78 && line != "#" {
+			b.WriteString(line + "\n")
+			line = "#"
+		}
+		line += " " + word
+	}
+	b.WriteString(line + "\n")
+	return b.String()
+}
+
+// profile is a starting configuration for one kind of deployment.
+type profile struct {
+	name  string
+	doc   string
+	cfg   smartturn.Config
+	notes map[string]string // why the profile departs from its base, by field
+}
+
+func profiles() []profile {
+	withModels := func(c smartturn.Config) smartturn.Config {
+		c.SileroVADModelPath = "models/silero_vad.onnx"
+		c.SmartTurnModelPath = "models/smart-turn-v3.2-cpu.onnx"
+		return c
+	}
+	base := withModels(smartturn.ProfileConversational())
+
+	ptt := base
+	ptt.VadThreshold = 0.35
+	ptt.VadPreSpeechMs = 100
+	ptt.VadStopMs = 500
+	ptt.TurnMergeGapMs = 500
+	ptt.TurnTimeoutMs = 5000
+
+	far := base
+	far.VadThreshold = 0.4
+	far.VadPreSpeechMs = 400
+	far.VadStopMs = 400
+	far.TurnThreshold = 0.6
+	far.TurnSmoothing = 0.3
+	far.TurnMergeGapMs = 300
+
+	return []profile{
+		{
+			name: "conversational",
+			doc:  "a voice agent on 16 kHz wideband audio, following the Smart-Turn guidance of a short VAD stop with a 3 s fallback (smartturn.ProfileConversational).",
+			cfg:  base,
+		},
+		{
+			name: "telephony",
+			doc:  "8 kHz PSTN or SIP calls (G.711; smartturn.ProfileTelephony).",
+			cfg:  withModels(smartturn.ProfileTelephony()),
+			notes: map[string]string{
+				"SampleRate":     "native 8 kHz; the engine upsamples for Smart-Turn.",
+				"VadThreshold":   "raised against line noise and comfort noise.",
+				"VadStopMs":      "longer, as codec jitter breaks up pauses.",
+				"TurnMergeGapMs": "rejoins turns split by packet loss.",
+				"TurnTimeoutMs":  "callers expect a prompt reply on the phone.",
+				"DetectDTMF":     "IVR callers press keys.",
+			},
+		},
+		{
+			name: "dictation",
+			doc:  "long-form dictation with thinking pauses mid-sentence (smartturn.ProfileDictation).",
+			cfg:  withModels(smartturn.ProfileDictation()),
+			notes: map[string]string{
+				"VadStopMs":      "longer, so brief hesitations do not run the model.",
+				"SplitLongTurns": "long dictation continues in a new segment instead of ending.",
+				"TurnThreshold":  "raised; ending mid-thought costs more than a late end.",
+				"TurnSmoothing":  "one confident prediction at a hesitation does not end the turn.",
+				"TurnMergeGapMs": "rejoins a sentence resumed after a pause.",
+				"TurnTimeoutMs":  "a long silence ends dictation even when the text looks unfinished.",
+			},
+		},
+		{
+			name: "push-to-talk",
+			doc:  "audio arrives only while the user holds a button; the application ends the turn on release.",
+			cfg:  ptt,
+			notes: map[string]string{
+				"VadThreshold":   "lowered; the user means to speak, so missing speech costs more than noise.",
+				"VadPreSpeechMs": "short, as the button press marks the start.",
+				"VadStopMs":      "longer, so thinking pauses while holding do not cut the turn.",
+				"TurnMergeGapMs": "keeps one press one turn.",
+				"TurnTimeoutMs":  "release is the real end; the timeout is only a backstop.",
+			},
+		},
+		{
+			name: "far-field",
+			doc:  "room microphones and speakerphones, with reverberation and low signal-to-noise ratio.",
+			cfg:  far,
+			notes: map[string]string{
+				"VadThreshold":   "lowered for distant, quieter speech.",
+				"VadPreSpeechMs": "longer, as soft onsets are detected late.",
+				"VadStopMs":      "longer, so reverberation tails do not read as speech ending and resuming.",
+				"TurnThreshold":  "raised, as reverberant audio makes the model less certain.",
+				"TurnSmoothing":  "steadies predictions on noisy audio.",
+				"TurnMergeGapMs": "rejoins turns split by noise bursts.",
+			},
+		},
+	}
+}
+
+func findProfile(name string) (profile, bool) {
+	for _, p := range profiles() {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return profile{}, false
+}
+
+func profileNames() []string {
+	var names []string
+	for _, p := range profiles() {
+		names = append(names, p.name)
+	}
+	return names
+}
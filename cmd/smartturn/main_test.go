@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cortexswarm/smart-turn-go"
+)
+
+// yamlToJSON converts the flat "Key: scalar" YAML that init-config writes,
+// whose scalars are JSON, to a JSON object, as a YAML decoder going
+// through encoding/json would.
+func yamlToJSON(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var fields []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, ": ")
+		if !ok {
+			t.Fatalf("not a YAML mapping line: %q", line)
+		}
+		fields = append(fields, quote(k)+": "+v)
+	}
+	return []byte("{" + strings.Join(fields, ", ") + "}")
+}
+
+// TestInitConfigRoundTrip writes every profile in both formats and
+// decodes the file back into a Config equal to the profile's.
+func TestInitConfigRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range profiles() {
+		for _, ext := range []string{".json", ".yaml"} {
+			path := filepath.Join(dir, p.name+ext)
+			if err := run([]string{"init-config", "-profile", p.name, "-o", path}); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ext == ".yaml" {
+				data = yamlToJSON(t, data)
+			}
+			var cfg smartturn.Config
+			if err := json.Unmarshal(data, &cfg); err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+			if !reflect.DeepEqual(cfg, p.cfg) {
+				t.Errorf("%s decodes to\n%+v\nwant\n%+v", path, cfg, p.cfg)
+			}
+		}
+	}
+}
+
+func TestInitConfigErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := run([]string{"init-config", "-o", path}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"init-config", "-o", path}, "use -force"},
+		{[]string{"init-config", "-profile", "nope"}, `unknown profile "nope"`},
+		{[]string{"init-config", "-format", "toml"}, "want json or yaml"},
+		{[]string{"init-config", "extra"}, `unexpected argument "extra"`},
+		{[]string{"frobnicate"}, `unknown command "frobnicate"`},
+	} {
+		if err := run(tc.args); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: error %v, want %q", tc.args, err, tc.want)
+		}
+	}
+	if err := run([]string{"init-config", "-profile", "telephony", "-o", path, "-force"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	var cfg smartturn.Config
+	if err := json.Unmarshal(data, &cfg); err != nil || cfg.SampleRate != smartturn.TelephonySampleRate {
+		t.Errorf("-force did not overwrite: %v, SampleRate %d", err, cfg.SampleRate)
+	}
+}
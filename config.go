@@ -2,18 +2,34 @@ package smartturn
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
+
+	"github.com/cortexswarm/smart-turn-go/features"
+	"github.com/cortexswarm/smart-turn-go/webrtcvad"
 )
 
 const (
 	RequiredSampleRate = 16000
 	RequiredChunkSize  = 512
+
+	// TelephonySampleRate and TelephonyChunkSize select the native 8 kHz
+	// mode: 32 ms chunks, as at 16 kHz.
+	TelephonySampleRate = 8000
+	TelephonyChunkSize  = 256
 )
 
 // Config holds SDK configuration. All fields must be set; no silent defaults.
 type Config struct {
-	SampleRate   int     // must be 16000
-	ChunkSize    int     // must be 512
+	// SampleRate is 16000, or TelephonySampleRate (8000) for telephony
+	// audio: VAD then scores the native 8 kHz chunks, and only the rest of
+	// the pipeline (segments, Smart-Turn, callbacks) gets them upsampled to
+	// 16 kHz, so callers need not resample. Sample offsets and segments
+	// are at 16 kHz in both modes.
+	SampleRate   int
+	ChunkSize    int     // 512, or TelephonyChunkSize (256) at 8000
 	VadThreshold float32 // speech probability threshold (e.g. 0.5)
 
 	// VAD behaviour and buffering.
@@ -21,6 +37,11 @@ type Config struct {
 	VadStopMs      int     // ms of trailing silence to end VAD speech (e.g. 800)
 	// TurnMaxDurationSeconds is a hard cap per turn in seconds (e.g. 600 for 10 minutes).
 	TurnMaxDurationSeconds float32
+	// SplitLongTurns continues speech that reaches TurnMaxDurationSeconds in
+	// a new segment, reported by OnTurnSplit, instead of ending the turn.
+	// The new segment starts with the last TurnSplitOverlapMs of audio.
+	SplitLongTurns     bool
+	TurnSplitOverlapMs int
 
 	// TurnSegmentEmitMs controls how often OnSegmentReady is called while speech is active.
 	// For example, 1000 emits 1-second slices; any remaining tail is emitted before OnSpeechEnd.
@@ -31,6 +52,27 @@ type Config struct {
 	// threshold (or Smart-Turn fails), OnSpeechEnd is not invoked.
 	TurnThreshold float32
 
+	// TurnCalibration rescales Smart-Turn probabilities (temperature or
+	// Platt scaling) before TurnThreshold and OnTurnPrediction see them.
+	// The zero value leaves them unchanged.
+	TurnCalibration Calibration
+
+	// TurnSmoothing averages successive Smart-Turn evaluations within a turn
+	// (segments of a pending turn) so one noisy inference does not flip the
+	// decision: p = TurnSmoothing*previous + (1-TurnSmoothing)*current, in
+	// [0, 1). 0 disables smoothing.
+	TurnSmoothing float32
+
+	// SemanticCheck lets the application decide turns Smart-Turn is unsure
+	// about, e.g. from a partial transcript; off when Check is nil.
+	SemanticCheck SemanticCheck
+
+	// TurnMergeGapMs merges a turn into the previous one when speech resumes
+	// less than this long after its OnSpeechEnd (e.g. 300, for a breath
+	// right after a "complete" boundary); OnTurnMerged then fires instead of
+	// OnSpeechStart. 0 disables merging.
+	TurnMergeGapMs int
+
 	// TurnTimeoutMs is how long (in ms of silence) to wait after a failed turn
 	// before forcing OnSpeechEnd. If there is no speech for this period after
 	// we skipped OnSpeechEnd, we invoke OnSpeechEnd (timeout).
@@ -39,18 +81,204 @@ type Config struct {
 	SileroVADModelPath string // path to silero_vad.onnx
 	SmartTurnModelPath string // path to smart-turn-v3.2-cpu.onnx
 
+	// SileroWindowSamples is the window silero_vad.onnx scores per run:
+	// 256, 512 (default) or 768 samples. A static input length in the
+	// graph is detected and must agree. The v4 (h/c state) and v5 (single
+	// state) graph layouts are both detected from the model.
+	SileroWindowSamples int
+
+	// VADBackend and TurnBackend replace the built-in ONNX Runtime models
+	// with another inference implementation (pure-Go, remote, accelerator
+	// SDK). When a backend is set, its model path is not required; when both
+	// are set, ONNX Runtime is never initialized and its shared library need
+	// not be present. The engine takes ownership and closes them in Close.
+	VADBackend  VADBackend
+	TurnBackend TurnBackend
+
+	// ShadowSmartTurnModelPath (or ShadowTurnBackend, which takes
+	// precedence) adds a second Smart-Turn model that runs in shadow, to
+	// compare a new model version on live traffic: after every primary
+	// prediction it scores the same audio, and OnShadowPrediction reports
+	// both, but decisions still come from the primary. The shadow model
+	// uses SmartTurnFeatures and SmartTurnSessionOptions, always on CPU,
+	// and runs on the engine goroutine after the decision is delivered.
+	ShadowSmartTurnModelPath string
+	ShadowTurnBackend        TurnBackend
+
+	// VADEnsemble adds VADs that score every chunk alongside the built-in
+	// VAD (or VADBackend), combined by VADVote; e.g. Silero AND an
+	// AdaptiveEnergyVAD gate with VoteAll cuts false triggers in loud
+	// environments. The engine takes ownership and closes them in Close.
+	VADEnsemble []VADBackend
+	VADVote     VotePolicy
+
+	// VADEngine selects the built-in VAD when VADBackend is nil: Silero
+	// (default) or WebRTC, whose GMM detector needs no model download or
+	// ONNX Runtime and suits constrained devices at some cost in accuracy.
+	// WebRTCVADMode sets its aggressiveness (webrtcvad.Quality, the
+	// default, through webrtcvad.VeryAggressive).
+	VADEngine     VADKind
+	WebRTCVADMode webrtcvad.Mode
+
+	// SmartTurnProvider selects the ONNX Runtime execution provider for the
+	// Smart-Turn session (e.g. CUDA with a device ID). The zero value is CPU.
+	// Silero VAD always runs on CPU; its per-chunk cost is too small to benefit.
+	SmartTurnProvider ExecutionProvider
+
+	// SileroSessionOptions and SmartTurnSessionOptions tune the ONNX Runtime
+	// session of each model (thread pools, graph optimization, memory arena).
+	// Zero values keep ORT defaults; see SessionOptions for multi-engine advice.
+	SileroSessionOptions    SessionOptions
+	SmartTurnSessionOptions SessionOptions
+
+	// SmartTurnFeatures overrides the mel feature parameters (n_fft, window
+	// length, hop, mel bins, context frames) for fine-tuned Smart-Turn
+	// variants. Zero fields keep the Whisper defaults, and a zero Frames is
+	// taken from the model's input shape. Static dimensions of the model input
+	// must match, or New fails. Ignored for raw-audio (v2) models.
+	SmartTurnFeatures features.Params
+
+	// FeatureWorkers parallelizes the Smart-Turn mel spectrogram over up to
+	// this many goroutines (never more than GOMAXPROCS) while PushPCM waits
+	// for them. 0 or 1 computes it on the caller's goroutine. Useful on
+	// multi-core hosts running few engines; with one engine per core it
+	// only adds scheduling overhead.
+	FeatureWorkers int
+
+	// LazySmartTurn defers creating the Smart-Turn session until the first
+	// speech segment starts, for serverless deployments where many
+	// instances never hear speech. New still loads Silero and checks the
+	// Smart-Turn graph. The first segment then waits for the session (a few
+	// hundred ms on CPU); a failure is reported through OnError and retried
+	// at the next segment, and turns stay pending meanwhile. Ignored with
+	// TurnBackend.
+	LazySmartTurn bool
+
+	// MaxBufferedAudioMs caps the audio buffered for the current speech
+	// segment. Beyond it the oldest audio is evicted, an eighth of the cap
+	// at a time, so a long turn keeps between 7/8 of its most recent
+	// MaxBufferedAudioMs and all of it; the first eviction of each
+	// segment is reported by OnAudioEvicted. Smart-Turn sees the last 8 s
+	// (16 s for v2), so a cap below that shortens its context. 0 buffers
+	// up to TurnMaxDurationSeconds. See Engine.MemoryStats.
+	MaxBufferedAudioMs int
+
+	// CallbackWatchdog, when its Budget is set, times every callback and
+	// reports those that overrun it to OnSlowCallback, optionally moving
+	// them off the engine goroutine; see CallbackWatchdog.
+	CallbackWatchdog CallbackWatchdog
+
+	// InferencePool, shared by many engines, caps how many Smart-Turn
+	// inferences (features and model) run at once across them; nil runs
+	// each on its engine's goroutine without limit.
+	InferencePool *InferencePool
+
+	// Transcriber, when set, receives the audio of every turn and its
+	// transcript is delivered to OnTranscript; see Transcriber.
+	Transcriber Transcriber
+
+	// EnergyVADFallback makes New fall back to AdaptiveEnergyVAD when the
+	// Silero model cannot be loaded (missing file, broken ONNX Runtime)
+	// instead of failing. The cause is reported through OnError wrapping
+	// ErrVADDegraded, and Health.VADDegraded is set. A built-in Smart-Turn
+	// model still needs ONNX Runtime.
+	EnergyVADFallback bool
+
+	// NonSpeech, when set, overrides VAD for chunks it classifies as music
+	// or noise (hold music, background TV), which then count as silence.
+	// See SpectralRejector for a built-in heuristic.
+	NonSpeech NonSpeechClassifier
+
+	// Speakers, when set, labels the speaker of each speech chunk so a
+	// mixed channel is split into per-speaker turns: when the label
+	// changes, OnSpeakerChange fires and a turn in progress ends
+	// (TurnEndSpeakerChange). See PitchSpeakerTracker.
+	Speakers SpeakerTracker
+
+	// DetectDTMF finds in-band DTMF tones (telephony keypad presses),
+	// reports them through OnDTMF, and replaces their chunks with silence
+	// so tones neither register as speech nor corrupt turns. A tone is
+	// found from the first chunk it fills at least half of; any part of it
+	// before that chunk passes through.
+	DetectDTMF bool
+
+	// WakeWord, when set, keeps the engine dormant (no VAD or Smart-Turn)
+	// until the detector fires, then processes until the turn ends (or
+	// WakeWordTimeoutMs pass without speech; 0 waits indefinitely) and
+	// re-arms. The engine owns the detector and closes it in Close.
+	WakeWord          WakeWordDetector
+	WakeWordTimeoutMs int
+
+	// Observer receives inference latencies and segment events for metrics
+	// and tracing; nil disables instrumentation.
+	Observer Observer
+
+	// Clock replaces the system clock for latency measurements, Health,
+	// debug file names, and Silero's periodic state reset, making the
+	// engine deterministic in tests and simulations. Nil uses time.Now.
+	Clock Clock
+
+	// InputQueue decouples PushPCM from processing: chunks are queued and
+	// processed on an engine goroutine, so a slow callback or inference does
+	// not stall the audio source. Off when Size is 0.
+	InputQueue InputQueue
+
+	// Overload sets how the real-time factor is monitored and whether the
+	// engine sheds work when it cannot keep up (see OnOverload).
+	Overload Overload
+
+	// ClosePolicy decides whether callbacks of a call in flight when Close
+	// is called from another goroutine are delivered (CloseDrain, the
+	// default) or dropped (CloseDiscard).
+	ClosePolicy ClosePolicy
+
+	// Logger receives leveled, structured logs: lifecycle and turn decisions
+	// at Info, segments and inference timings at Debug, dropped audio at Warn,
+	// and everything also reported to OnError at Error. Nil disables logging.
+	Logger *slog.Logger
+
+	// DebugFeatureDump writes the audio window and model features of every
+	// Smart-Turn call to disk (.npy, optionally CSV) for comparison with the
+	// Python reference. Off when Dir is empty.
+	DebugFeatureDump FeatureDump
+
+	// DebugAudioRecording tees ingested audio, and optionally every
+	// Smart-Turn input window, into WAV files capped by size. Off when Dir
+	// is empty.
+	DebugAudioRecording AudioRecording
+
+	// TurnExport writes the audio of every completed turn to a WAV file
+	// named from a template, with retention limits. Off when Dir is empty.
+	TurnExport TurnExport
+
+	// TurnLog, when set, receives one JSON line per turn (TurnLogEntry):
+	// timestamps, durations, Smart-Turn probabilities, and why it ended.
+	// Each line is one Write, so an *os.File opened with O_APPEND can be
+	// shared by engines; other writers must be safe for the engines
+	// sharing them. Write errors are reported via OnError.
+	TurnLog io.Writer
+
 	// ONNXRuntimeLibPath is the path to the ONNX Runtime shared library (e.g. libonnxruntime.dylib).
 	// If empty, the SDK uses ONNXRUNTIME_SHARED_LIBRARY_PATH env var if set; otherwise onnxruntime_go default.
 	ONNXRuntimeLibPath string
 }
 
-// validate checks Config and returns an error on invalid or missing values.
-func validateConfig(cfg Config) error {
-	if cfg.SampleRate != RequiredSampleRate {
-		return errors.New("config: SampleRate must be 16000")
-	}
-	if cfg.ChunkSize != RequiredChunkSize {
-		return errors.New("config: ChunkSize must be 512")
+// ValidateConfig checks cfg as New does, returning an error on invalid or
+// missing values, including model files that do not exist. It loads
+// neither models nor ONNX Runtime, so services can fail fast at startup;
+// Config.Describe reports what a valid cfg resolves to.
+func ValidateConfig(cfg Config) error {
+	switch cfg.SampleRate {
+	case RequiredSampleRate:
+		if cfg.ChunkSize != RequiredChunkSize {
+			return errors.New("config: ChunkSize must be 512")
+		}
+	case TelephonySampleRate:
+		if cfg.ChunkSize != TelephonyChunkSize {
+			return errors.New("config: ChunkSize must be 256 at 8000 Hz")
+		}
+	default:
+		return errors.New("config: SampleRate must be 16000 or 8000")
 	}
 	if cfg.VadThreshold < 0 || cfg.VadThreshold > 1 {
 		return errors.New("config: VadThreshold must be in [0, 1]")
@@ -64,32 +292,123 @@ func validateConfig(cfg Config) error {
 	if cfg.TurnMaxDurationSeconds <= 0 {
 		return errors.New("config: TurnMaxDurationSeconds must be > 0")
 	}
+	if cfg.TurnSplitOverlapMs < 0 || float32(cfg.TurnSplitOverlapMs) >= cfg.TurnMaxDurationSeconds*1000 {
+		return errors.New("config: TurnSplitOverlapMs must be >= 0 and shorter than TurnMaxDurationSeconds")
+	}
+	if cfg.MaxBufferedAudioMs < 0 || (cfg.MaxBufferedAudioMs > 0 && cfg.MaxBufferedAudioMs <= cfg.VadPreSpeechMs) {
+		return errors.New("config: MaxBufferedAudioMs must be 0 or longer than VadPreSpeechMs")
+	}
+	if cfg.CallbackWatchdog.Budget < 0 || cfg.CallbackWatchdog.QueueSize < 0 {
+		return errors.New("config: CallbackWatchdog.Budget and QueueSize must be >= 0")
+	}
 	if cfg.TurnSegmentEmitMs <= 0 {
 		return errors.New("config: TurnSegmentEmitMs must be > 0")
 	}
 	if cfg.TurnThreshold < 0 || cfg.TurnThreshold > 1 {
 		return errors.New("config: TurnThreshold must be in [0, 1]")
 	}
+	if err := validateCalibration(cfg.TurnCalibration); err != nil {
+		return err
+	}
+	if cfg.TurnSmoothing < 0 || cfg.TurnSmoothing >= 1 {
+		return errors.New("config: TurnSmoothing must be in [0, 1)")
+	}
+	if err := validateSemanticCheck(cfg.SemanticCheck); err != nil {
+		return err
+	}
+	if cfg.WakeWordTimeoutMs < 0 {
+		return errors.New("config: WakeWordTimeoutMs must be >= 0")
+	}
+	if cfg.TurnMergeGapMs < 0 {
+		return errors.New("config: TurnMergeGapMs must be >= 0")
+	}
 	if cfg.TurnTimeoutMs <= 0 {
 		return errors.New("config: TurnTimeoutMs must be > 0")
 	}
-	if cfg.SileroVADModelPath == "" {
-		return errors.New("config: SileroVADModelPath is required")
+	if err := cfg.SmartTurnFeatures.Validate(); err != nil {
+		return fmt.Errorf("config: SmartTurnFeatures: %w", err)
 	}
-	if cfg.SmartTurnModelPath == "" {
-		return errors.New("config: SmartTurnModelPath is required")
+	if cfg.DebugAudioRecording.MaxBytes < 0 {
+		return errors.New("config: DebugAudioRecording.MaxBytes must be >= 0")
 	}
-	if _, err := os.Stat(cfg.SileroVADModelPath); err != nil {
-		if os.IsNotExist(err) {
-			return errors.New("config: Silero VAD model file not found: " + cfg.SileroVADModelPath)
+	if cfg.TurnExport.Dir != "" {
+		if err := cfg.TurnExport.validate(); err != nil {
+			return err
 		}
+	}
+	if err := validateOverload(cfg.Overload); err != nil {
 		return err
 	}
-	if _, err := os.Stat(cfg.SmartTurnModelPath); err != nil {
-		if os.IsNotExist(err) {
-			return errors.New("config: Smart-Turn model file not found: " + cfg.SmartTurnModelPath)
-		}
+	if cfg.ClosePolicy != CloseDrain && cfg.ClosePolicy != CloseDiscard {
+		return errors.New("config: ClosePolicy must be CloseDrain or CloseDiscard")
+	}
+	if cfg.InputQueue.Size < 0 {
+		return errors.New("config: InputQueue.Size must be >= 0")
+	}
+	if p := cfg.InputQueue.Overflow; p != OverflowBlock && p != OverflowDropOldest && p != OverflowDropNewest {
+		return errors.New("config: InputQueue.Overflow must be OverflowBlock, OverflowDropOldest, or OverflowDropNewest")
+	}
+	if cfg.FeatureWorkers < 0 {
+		return errors.New("config: FeatureWorkers must be >= 0")
+	}
+	if err := validateProvider(cfg.SmartTurnProvider); err != nil {
 		return err
 	}
+	if err := validateSessionOptions("SileroSessionOptions", cfg.SileroSessionOptions); err != nil {
+		return err
+	}
+	if err := validateSessionOptions("SmartTurnSessionOptions", cfg.SmartTurnSessionOptions); err != nil {
+		return err
+	}
+	if cfg.SileroWindowSamples != 0 && !validSileroWindow(cfg.SileroWindowSamples) {
+		return errors.New("config: SileroWindowSamples must be 256, 512 or 768")
+	}
+	for _, v := range cfg.VADEnsemble {
+		if v == nil {
+			return errors.New("config: VADEnsemble must not contain nil backends")
+		}
+	}
+	if cfg.VADVote < VoteAverage || cfg.VADVote > VoteMajority {
+		return errors.New("config: VADVote must be VoteAverage, VoteAll, VoteAny or VoteMajority")
+	}
+	switch cfg.VADEngine {
+	case VADSilero, VADWebRTC:
+	default:
+		return errors.New("config: VADEngine must be \"\" (Silero) or \"webrtc\"")
+	}
+	if cfg.WebRTCVADMode < webrtcvad.Quality || cfg.WebRTCVADMode > webrtcvad.VeryAggressive {
+		return errors.New("config: WebRTCVADMode must be between 0 and 3")
+	}
+	// With EnergyVADFallback a missing model degrades VAD in New instead.
+	if cfg.VADBackend == nil && cfg.VADEngine == VADSilero && !cfg.EnergyVADFallback {
+		if cfg.SileroVADModelPath == "" {
+			return errors.New("config: SileroVADModelPath is required")
+		}
+		if _, err := os.Stat(cfg.SileroVADModelPath); err != nil {
+			if os.IsNotExist(err) {
+				return errors.New("config: Silero VAD model file not found: " + cfg.SileroVADModelPath)
+			}
+			return err
+		}
+	}
+	if cfg.ShadowTurnBackend == nil && cfg.ShadowSmartTurnModelPath != "" {
+		if _, err := os.Stat(cfg.ShadowSmartTurnModelPath); err != nil {
+			if os.IsNotExist(err) {
+				return errors.New("config: shadow Smart-Turn model file not found: " + cfg.ShadowSmartTurnModelPath)
+			}
+			return err
+		}
+	}
+	if cfg.TurnBackend == nil {
+		if cfg.SmartTurnModelPath == "" {
+			return errors.New("config: SmartTurnModelPath is required")
+		}
+		if _, err := os.Stat(cfg.SmartTurnModelPath); err != nil {
+			if os.IsNotExist(err) {
+				return errors.New("config: Smart-Turn model file not found: " + cfg.SmartTurnModelPath)
+			}
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,150 @@
+package smartturn
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go/internal/wav"
+)
+
+// DefaultAudioRecordingMaxBytes caps AudioRecording when MaxBytes is 0
+// (about 70 minutes of audio).
+const DefaultAudioRecordingMaxBytes = 256 << 20
+
+// AudioRecording tees audio into WAV files (mono, 16 kHz, 32-bit float, so
+// the samples are exactly those the engine saw) for diagnosing missed or
+// early turn detections. Files named <engine start>.stream.wav and, with
+// TurnWindows, <engine start>_turn_<seq>_p<probability>.wav are written to
+// Dir:
+//   - .stream.wav: every chunk accepted by PushPCM while listening, back to
+//     back (gaps while stopped are not represented)
+//   - _turn_*.wav: the audio window handed to Smart-Turn on each call; "err"
+//     replaces the probability when inference failed
+//
+// Recording stops once the files of the engine reach MaxBytes; write
+// failures are reported via OnError and also stop it. Turn detection is
+// never affected.
+type AudioRecording struct {
+	Dir         string // recording is enabled when non-empty; created if missing
+	MaxBytes    int64  // total size cap; 0 means DefaultAudioRecordingMaxBytes
+	TurnWindows bool   // also write each Smart-Turn input window
+}
+
+// audioRecorder implements AudioRecording for one engine.
+type audioRecorder struct {
+	cfg     AudioRecording
+	log     *slog.Logger
+	prefix  string
+	file    *os.File // stream file, opened on the first chunk
+	stream  *wav.Writer
+	written int64
+	seq     int
+	stopped bool
+}
+
+func newAudioRecorder(cfg AudioRecording, log *slog.Logger, now time.Time) (*audioRecorder, error) {
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = DefaultAudioRecordingMaxBytes
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("audio recording: %w", err)
+	}
+	return &audioRecorder{cfg: cfg, log: log, prefix: now.Format("20060102T150405.000000")}, nil
+}
+
+// reserve accounts for n more bytes, stopping the recording when they do
+// not fit under MaxBytes.
+func (r *audioRecorder) reserve(n int64) bool {
+	if r.stopped {
+		return false
+	}
+	if r.written+n > r.cfg.MaxBytes {
+		r.log.Warn("debug audio recording reached MaxBytes; stopped", "dir", r.cfg.Dir, "max_bytes", r.cfg.MaxBytes)
+		r.stopped = true
+		return false
+	}
+	r.written += n
+	return true
+}
+
+// writeChunk appends one ingested chunk to the stream file.
+func (r *audioRecorder) writeChunk(chunk []float32) error {
+	n := int64(len(chunk)) * 4
+	if r.stream == nil {
+		n += wav.HeaderSize
+	}
+	if !r.reserve(n) {
+		return nil
+	}
+	if r.stream == nil {
+		f, err := os.Create(filepath.Join(r.cfg.Dir, r.prefix+".stream.wav"))
+		if err != nil {
+			return r.fail(err)
+		}
+		w, err := wav.NewWriter(f, RequiredSampleRate)
+		if err != nil {
+			_ = f.Close()
+			return r.fail(err)
+		}
+		r.file, r.stream = f, w
+	}
+	if err := r.stream.Write(chunk); err != nil {
+		return r.fail(err)
+	}
+	return nil
+}
+
+// writeTurn writes the window of one Smart-Turn call to its own file.
+func (r *audioRecorder) writeTurn(window []float32, prob float32, inferErr error) error {
+	if !r.cfg.TurnWindows || !r.reserve(int64(len(window))*4+wav.HeaderSize) {
+		return nil
+	}
+	r.seq++
+	label := fmt.Sprintf("p%.2f", prob)
+	if inferErr != nil {
+		label = "err"
+	}
+	f, err := os.Create(filepath.Join(r.cfg.Dir, fmt.Sprintf("%s_turn_%06d_%s.wav", r.prefix, r.seq, label)))
+	if err != nil {
+		return r.fail(err)
+	}
+	w, err := wav.NewWriter(f, RequiredSampleRate)
+	if err == nil {
+		err = w.Write(window)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return r.fail(err)
+	}
+	return nil
+}
+
+// fail stops the recording after a write error.
+func (r *audioRecorder) fail(err error) error {
+	r.stopped = true
+	if cerr := r.close(); cerr != nil {
+		r.log.Error("closing debug audio recording", "err", cerr)
+	}
+	return fmt.Errorf("audio recording: %w", err)
+}
+
+// close finalizes the stream file.
+func (r *audioRecorder) close() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.stream.Close()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	r.file, r.stream = nil, nil
+	return err
+}
@@ -0,0 +1,127 @@
+package smartturn
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go/internal/npy"
+)
+
+// FeatureDump writes what the Smart-Turn model saw on every inference, so a
+// disagreement with the Python reference can be traced to the features or
+// the model. For each call, files named <engine start>_<seq> are written to
+// Dir:
+//   - .audio.npy: the raw (un-normalized) audio window, float32 16 kHz; feed
+//     it to transformers.WhisperFeatureExtractor to reproduce the reference
+//   - .mel.npy: the log-mel features, shape (mels, frames) — or .input.npy,
+//     the normalized waveform, for raw-audio (v2) models
+//   - .mel.csv / .input.csv when CSV is set: the same values, one row per mel bin
+//
+// and a line "name,segment_samples,probability" is appended to
+// predictions.csv. Write failures are reported via OnError and do not affect
+// turn detection. Intended for debugging only: each call writes ~1 MB.
+type FeatureDump struct {
+	Dir string // dumping is enabled when non-empty; created if missing
+	CSV bool   // also write the features as CSV
+}
+
+// featureDumper implements FeatureDump for one engine.
+type featureDumper struct {
+	cfg    FeatureDump
+	prefix string
+	seq    int
+}
+
+func newFeatureDumper(cfg FeatureDump, now time.Time) (*featureDumper, error) {
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("feature dump: %w", err)
+	}
+	// Engines sharing a directory are told apart by their start time.
+	return &featureDumper{cfg: cfg, prefix: now.Format("20060102T150405.000000")}, nil
+}
+
+// dump writes the audio window and features of the last Smart-Turn call.
+func (d *featureDumper) dump(segment []float32, st *smartTurn, prob float32) error {
+	d.seq++
+	name := fmt.Sprintf("%s_%06d", d.prefix, d.seq)
+	window := st.window(segment)
+	if err := d.writeNPY(name+".audio.npy", window, len(window)); err != nil {
+		return err
+	}
+	kind, shape := "input", []int{len(st.features)}
+	if st.model.input == smartTurnMel {
+		s := st.model.params.Shape()
+		kind, shape = "mel", []int{s.Mels, s.Frames}
+	}
+	if err := d.writeNPY(name+"."+kind+".npy", st.features, shape...); err != nil {
+		return err
+	}
+	if d.cfg.CSV {
+		if err := d.writeCSV(name+"."+kind+".csv", st.features, shape[len(shape)-1]); err != nil {
+			return err
+		}
+	}
+	line := name + "," + strconv.Itoa(len(segment)) + "," + strconv.FormatFloat(float64(prob), 'g', -1, 32) + "\n"
+	return d.appendFile("predictions.csv", line)
+}
+
+func (d *featureDumper) writeNPY(file string, data []float32, shape ...int) error {
+	f, err := os.Create(filepath.Join(d.cfg.Dir, file))
+	if err != nil {
+		return fmt.Errorf("feature dump: %w", err)
+	}
+	err = npy.Write(f, data, shape...)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("feature dump: %s: %w", file, err)
+	}
+	return nil
+}
+
+// writeCSV writes data as rows of cols values.
+func (d *featureDumper) writeCSV(file string, data []float32, cols int) error {
+	f, err := os.Create(filepath.Join(d.cfg.Dir, file))
+	if err != nil {
+		return fmt.Errorf("feature dump: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	var buf []byte
+	for i, v := range data {
+		buf = strconv.AppendFloat(buf[:0], float64(v), 'g', -1, 32)
+		if (i+1)%cols == 0 {
+			buf = append(buf, '\n')
+		} else {
+			buf = append(buf, ',')
+		}
+		_, _ = w.Write(buf)
+	}
+	err = w.Flush()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("feature dump: %s: %w", file, err)
+	}
+	return nil
+}
+
+func (d *featureDumper) appendFile(file, line string) error {
+	f, err := os.OpenFile(filepath.Join(d.cfg.Dir, file), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("feature dump: %w", err)
+	}
+	_, err = f.WriteString(line)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("feature dump: %s: %w", file, err)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package smartturn
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// Sources of a Setting.
+const (
+	SourceConfig  = "config"  // set in Config
+	SourceDefault = "default" // zero in Config; the SDK's default applies
+	SourceEnv     = "env"     // taken from the environment
+)
+
+// Setting is one effective setting of a Config.
+type Setting struct {
+	Name   string // field path, e.g. "Overload.Window"
+	Value  string
+	Source string // SourceConfig, SourceDefault or SourceEnv
+}
+
+// ConfigReport lists the effective settings of a Config, in field order.
+type ConfigReport []Setting
+
+// String formats the report one "name=value" per line, marking values that
+// did not come from Config.
+func (r ConfigReport) String() string {
+	var b strings.Builder
+	for _, s := range r {
+		b.WriteString(s.Name)
+		b.WriteByte('=')
+		b.WriteString(s.Value)
+		if s.Source != SourceConfig {
+			b.WriteString(" (" + s.Source + ")")
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// LogValue groups the settings for slog:
+//
+//	logger.Info("smart-turn config", "config", cfg.Describe())
+func (r ConfigReport) LogValue() slog.Value {
+	attrs := make([]slog.Attr, len(r))
+	for i, s := range r {
+		attrs[i] = slog.String(s.Name, s.Value)
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// Describe returns the settings an engine built from c runs with: every
+// field with the SDK defaults applied to those left zero, and
+// ONNXRuntimeLibPath resolved from EnvONNXRuntimeLib. Pluggable
+// components (backends, Logger, TurnLog) are reported by type. Describe
+// does not validate c; see ValidateConfig.
+func (c Config) Describe() ConfigReport {
+	var r report
+	r.set("SampleRate", c.SampleRate)
+	r.set("ChunkSize", c.ChunkSize)
+	r.set("VadThreshold", c.VadThreshold)
+	r.set("VadPreSpeechMs", c.VadPreSpeechMs)
+	r.set("VadStopMs", c.VadStopMs)
+	r.set("TurnMaxDurationSeconds", c.TurnMaxDurationSeconds)
+	r.set("SplitLongTurns", c.SplitLongTurns)
+	r.set("TurnSplitOverlapMs", c.TurnSplitOverlapMs)
+	r.set("TurnSegmentEmitMs", c.TurnSegmentEmitMs)
+	r.set("TurnThreshold", c.TurnThreshold)
+	if c.TurnCalibration.identity() {
+		r.def("TurnCalibration", "none")
+	} else {
+		r.set("TurnCalibration.Temperature", c.TurnCalibration.Temperature)
+		r.set("TurnCalibration.A", c.TurnCalibration.A)
+		r.set("TurnCalibration.B", c.TurnCalibration.B)
+	}
+	r.set("TurnSmoothing", c.TurnSmoothing)
+	if c.SemanticCheck.Check == nil {
+		r.def("SemanticCheck", "off")
+	} else {
+		r.set("SemanticCheck.Low", c.SemanticCheck.Low)
+		r.set("SemanticCheck.High", c.SemanticCheck.High)
+		r.set("SemanticCheck.Deadline", c.SemanticCheck.Deadline)
+	}
+	r.set("TurnMergeGapMs", c.TurnMergeGapMs)
+	r.set("TurnTimeoutMs", c.TurnTimeoutMs)
+
+	r.component("VADBackend", c.VADBackend)
+	if c.VADBackend == nil {
+		r.or("VADEngine", c.VADEngine, "silero", c.VADEngine == VADSilero)
+		if c.VADEngine == VADWebRTC {
+			r.set("WebRTCVADMode", c.WebRTCVADMode)
+		} else {
+			r.set("SileroVADModelPath", c.SileroVADModelPath)
+			r.or("SileroWindowSamples", c.SileroWindowSamples, "from model, else 32 ms", c.SileroWindowSamples == 0)
+			r.sessionOptions("SileroSessionOptions", c.SileroSessionOptions)
+		}
+		r.set("EnergyVADFallback", c.EnergyVADFallback)
+	}
+	r.set("VADEnsemble", len(c.VADEnsemble))
+	if len(c.VADEnsemble) > 0 {
+		r.set("VADVote", c.VADVote)
+	}
+
+	r.component("TurnBackend", c.TurnBackend)
+	p := c.SmartTurnFeatures.WithDefaults()
+	if c.TurnBackend == nil {
+		r.set("SmartTurnModelPath", c.SmartTurnModelPath)
+		r.or("SmartTurnProvider", c.SmartTurnProvider.Kind, "cpu", c.SmartTurnProvider.Kind == ProviderCPU)
+		if c.SmartTurnProvider.Kind != ProviderCPU {
+			r.set("SmartTurnProvider.DeviceID", c.SmartTurnProvider.DeviceID)
+			r.set("SmartTurnProvider.FallbackToCPU", c.SmartTurnProvider.FallbackToCPU)
+		}
+		r.sessionOptions("SmartTurnSessionOptions", c.SmartTurnSessionOptions)
+		r.set("LazySmartTurn", c.LazySmartTurn)
+	}
+	r.or("SmartTurnFeatures.NFFT", p.NFFT, p.NFFT, c.SmartTurnFeatures.NFFT == 0)
+	r.or("SmartTurnFeatures.WindowLength", p.WindowLength, p.WindowLength, c.SmartTurnFeatures.WindowLength == 0)
+	r.or("SmartTurnFeatures.HopLength", p.HopLength, p.HopLength, c.SmartTurnFeatures.HopLength == 0)
+	r.or("SmartTurnFeatures.NMels", p.NMels, p.NMels, c.SmartTurnFeatures.NMels == 0)
+	r.or("SmartTurnFeatures.Frames", c.SmartTurnFeatures.Frames, "from model", c.SmartTurnFeatures.Frames == 0)
+	if c.ShadowTurnBackend != nil {
+		r.component("ShadowTurnBackend", c.ShadowTurnBackend)
+	} else if c.ShadowSmartTurnModelPath != "" {
+		r.set("ShadowSmartTurnModelPath", c.ShadowSmartTurnModelPath)
+	}
+	r.or("FeatureWorkers", c.FeatureWorkers, 1, c.FeatureWorkers == 0)
+	r.set("InferencePool", c.InferencePool != nil)
+
+	r.component("Transcriber", c.Transcriber)
+	r.component("NonSpeech", c.NonSpeech)
+	r.component("Speakers", c.Speakers)
+	r.set("DetectDTMF", c.DetectDTMF)
+	r.component("WakeWord", c.WakeWord)
+	if c.WakeWord != nil {
+		r.set("WakeWordTimeoutMs", c.WakeWordTimeoutMs)
+	}
+	r.component("Observer", c.Observer)
+	r.or("Clock", fmt.Sprintf("%T", c.Clock), "system", c.Clock == nil)
+
+	r.or("InputQueue.Size", c.InputQueue.Size, "off", c.InputQueue.Size == 0)
+	if c.InputQueue.Size > 0 {
+		r.set("InputQueue.Overflow", c.InputQueue.Overflow)
+	}
+	o := c.Overload.withDefaults()
+	r.or("Overload.Window", o.Window, o.Window, c.Overload.Window == 0)
+	r.or("Overload.Threshold", o.Threshold, o.Threshold, c.Overload.Threshold == 0)
+	r.set("Overload.Shed", o.Shed)
+	r.or("MaxBufferedAudioMs", c.MaxBufferedAudioMs, "unlimited", c.MaxBufferedAudioMs == 0)
+	w := c.CallbackWatchdog
+	r.or("CallbackWatchdog.Budget", w.Budget, "off", w.Budget == 0)
+	if w.Budget > 0 {
+		r.set("CallbackWatchdog.Async", w.Async)
+		if w.Async {
+			r.or("CallbackWatchdog.QueueSize", w.QueueSize, DefaultCallbackQueueSize, w.QueueSize == 0)
+		}
+	}
+	if c.ClosePolicy == CloseDiscard {
+		r.set("ClosePolicy", "discard")
+	} else {
+		r.def("ClosePolicy", "drain")
+	}
+	r.component("Logger", c.Logger)
+
+	r.or("DebugFeatureDump.Dir", c.DebugFeatureDump.Dir, "off", c.DebugFeatureDump.Dir == "")
+	r.or("DebugAudioRecording.Dir", c.DebugAudioRecording.Dir, "off", c.DebugAudioRecording.Dir == "")
+	r.or("TurnExport.Dir", c.TurnExport.Dir, "off", c.TurnExport.Dir == "")
+	if c.TurnExport.Dir != "" {
+		r.or("TurnExport.Name", c.TurnExport.Name, DefaultTurnExportName, c.TurnExport.Name == "")
+		r.set("TurnExport.MaxFiles", c.TurnExport.MaxFiles)
+		r.set("TurnExport.MaxBytes", c.TurnExport.MaxBytes)
+	}
+	r.component("TurnLog", c.TurnLog)
+
+	switch env := os.Getenv(EnvONNXRuntimeLib); {
+	case c.ONNXRuntimeLibPath != "":
+		r.set("ONNXRuntimeLibPath", c.ONNXRuntimeLibPath)
+	case env != "":
+		r.add("ONNXRuntimeLibPath", env, SourceEnv)
+	default:
+		r.def("ONNXRuntimeLibPath", "onnxruntime_go default")
+	}
+	return ConfigReport(r)
+}
+
+// report builds a ConfigReport.
+type report []Setting
+
+func (r *report) add(name string, v any, source string) {
+	*r = append(*r, Setting{Name: name, Value: formatSetting(v), Source: source})
+}
+
+func (r *report) set(name string, v any) { r.add(name, v, SourceConfig) }
+
+func (r *report) def(name string, v any) { r.add(name, v, SourceDefault) }
+
+// or reports v, or dflt when isDefault.
+func (r *report) or(name string, v, dflt any, isDefault bool) {
+	if isDefault {
+		r.def(name, dflt)
+	} else {
+		r.set(name, v)
+	}
+}
+
+// component reports a pluggable field by its dynamic type, "none" when nil.
+func (r *report) component(name string, v any) {
+	if isNil(v) {
+		r.def(name, "none")
+	} else {
+		r.set(name, fmt.Sprintf("%T", v))
+	}
+}
+
+func (r *report) sessionOptions(name string, so SessionOptions) {
+	r.or(name+".IntraOpThreads", so.IntraOpThreads, "ort default", so.IntraOpThreads == 0)
+	r.or(name+".InterOpThreads", so.InterOpThreads, "sequential", so.InterOpThreads == 0)
+	r.or(name+".GraphOptimization", so.GraphOptimization, "ort default", so.GraphOptimization == "")
+	r.set(name+".DisableCPUMemArena", so.DisableCPUMemArena)
+	r.or(name+".LogLevel", so.LogLevel, "warning", so.LogLevel == ORTLogDefault)
+	r.or(name+".ProfilePrefix", so.ProfilePrefix, "off", so.ProfilePrefix == "")
+}
+
+func formatSetting(v any) string {
+	if d, ok := v.(time.Duration); ok {
+		return d.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// isNil reports whether v is nil or a nil pointer in an interface.
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	switch x := v.(type) {
+	case *slog.Logger:
+		return x == nil
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package smartturn_test
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// settings indexes a report by name, checking that names are unique.
+func settings(t *testing.T, r smartturn.ConfigReport) map[string]smartturn.Setting {
+	t.Helper()
+	m := make(map[string]smartturn.Setting, len(r))
+	for _, s := range r {
+		if _, dup := m[s.Name]; dup {
+			t.Errorf("%s reported twice", s.Name)
+		}
+		m[s.Name] = s
+	}
+	return m
+}
+
+// checkSettings compares the named settings of r with want, given as
+// "value" for SourceConfig or "value (source)", and checks that the
+// names in absent are not reported.
+func checkSettings(t *testing.T, r smartturn.ConfigReport, want map[string]string, absent ...string) {
+	t.Helper()
+	m := settings(t, r)
+	for name, w := range want {
+		s, ok := m[name]
+		if !ok {
+			t.Errorf("%s not reported", name)
+			continue
+		}
+		got := s.Value
+		if s.Source != smartturn.SourceConfig {
+			got += " (" + s.Source + ")"
+		}
+		if got != w {
+			t.Errorf("%s = %q, want %q", name, got, w)
+		}
+	}
+	for _, name := range absent {
+		if s, ok := m[name]; ok {
+			t.Errorf("%s reported: %+v", name, s)
+		}
+	}
+}
+
+func TestDescribeDefaults(t *testing.T) {
+	t.Setenv(smartturn.EnvONNXRuntimeLib, "")
+	r := smartturn.Config{}.Describe()
+	checkSettings(t, r, map[string]string{
+		"SampleRate":                          "0",
+		"VadThreshold":                        "0",
+		"TurnCalibration":                     "none (default)",
+		"SemanticCheck":                       "off (default)",
+		"VADBackend":                          "none (default)",
+		"VADEngine":                           "silero (default)",
+		"SileroWindowSamples":                 "from model, else 32 ms (default)",
+		"SileroSessionOptions.InterOpThreads": "sequential (default)",
+		"SmartTurnProvider":                   "cpu (default)",
+		"SmartTurnSessionOptions.LogLevel":    "warning (default)",
+		"SmartTurnFeatures.NFFT":              "400 (default)",
+		"SmartTurnFeatures.HopLength":         "160 (default)",
+		"SmartTurnFeatures.Frames":            "from model (default)",
+		"FeatureWorkers":                      "1 (default)",
+		"Clock":                               "system (default)",
+		"InputQueue.Size":                     "off (default)",
+		"Overload.Window":                     "2s (default)",
+		"MaxBufferedAudioMs":                  "unlimited (default)",
+		"CallbackWatchdog.Budget":             "off (default)",
+		"ClosePolicy":                         "drain (default)",
+		"TurnExport.Dir":                      "off (default)",
+		"ONNXRuntimeLibPath":                  "onnxruntime_go default (default)",
+	}, "WebRTCVADMode", "VADVote", "InputQueue.Overflow", "CallbackWatchdog.Async", "TurnExport.Name", "ShadowSmartTurnModelPath")
+
+	// String has one line per setting, in field order.
+	lines := strings.Split(strings.TrimSuffix(r.String(), "\n"), "\n")
+	if len(lines) != len(r) || lines[0] != "SampleRate=0" || lines[len(lines)-1] != "ONNXRuntimeLibPath=onnxruntime_go default (default)" {
+		t.Errorf("String:\n%s", r)
+	}
+	if v := r.LogValue(); v.Kind() != slog.KindGroup || len(v.Group()) != len(r) {
+		t.Errorf("LogValue %v", v)
+	}
+}
+
+func TestDescribeOverrides(t *testing.T) {
+	t.Setenv(smartturn.EnvONNXRuntimeLib, "/opt/ort/libonnxruntime.so")
+	cfg := smartturn.ProfileConversational()
+	cfg.VADEngine = smartturn.VADWebRTC
+	cfg.WebRTCVADMode = 2
+	cfg.TurnBackend = &smartturntest.TurnScript{}
+	cfg.ShadowSmartTurnModelPath = "shadow.onnx"
+	cfg.SmartTurnFeatures.Frames = 500
+	cfg.InputQueue = smartturn.InputQueue{Size: 8, Overflow: smartturn.OverflowDropOldest}
+	cfg.Overload.Window = 5 * time.Second
+	cfg.CallbackWatchdog = smartturn.CallbackWatchdog{Budget: 20 * time.Millisecond, Async: true}
+	cfg.ClosePolicy = smartturn.CloseDiscard
+	cfg.TurnExport.Dir = "turns"
+	cfg.Clock = smartturntest.NewClock(time.Time{})
+	r := cfg.Describe()
+	checkSettings(t, r, map[string]string{
+		"VadStopMs":                  "200",
+		"VADEngine":                  "webrtc",
+		"WebRTCVADMode":              "2",
+		"TurnBackend":                "*smartturntest.TurnScript",
+		"SmartTurnFeatures.Frames":   "500",
+		"ShadowSmartTurnModelPath":   "shadow.onnx",
+		"Clock":                      "*smartturntest.Clock",
+		"InputQueue.Size":            "8",
+		"InputQueue.Overflow":        "1",
+		"Overload.Window":            "5s",
+		"Overload.Threshold":         "1 (default)",
+		"CallbackWatchdog.Budget":    "20ms",
+		"CallbackWatchdog.Async":     "true",
+		"CallbackWatchdog.QueueSize": "64 (default)",
+		"ClosePolicy":                "discard",
+		"TurnExport.Dir":             "turns",
+		"TurnExport.Name":            smartturn.DefaultTurnExportName + " (default)",
+		"ONNXRuntimeLibPath":         "/opt/ort/libonnxruntime.so (env)",
+	}, "SileroVADModelPath", "SileroSessionOptions.IntraOpThreads", "SmartTurnModelPath", "SmartTurnProvider", "LazySmartTurn")
+
+	cfg.ONNXRuntimeLibPath = "lib.so"
+	checkSettings(t, cfg.Describe(), map[string]string{"ONNXRuntimeLibPath": "lib.so"})
+}
@@ -0,0 +1,194 @@
+package smartturn
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Defaults for DetectorOptions.
+const (
+	DefaultDetectorThreshold    = 0.5
+	DefaultDetectorMinSilenceMs = 100
+	DefaultDetectorPadMs        = 30
+)
+
+// DetectorOptions configures a SpeechDetector.
+type DetectorOptions struct {
+	// Backend replaces Silero VAD on ONNX Runtime, as Config.VADBackend;
+	// the model path is then ignored. The detector closes it in Close.
+	Backend VADBackend
+
+	SessionOptions SessionOptions
+	// WindowSamples is as Config.SileroWindowSamples.
+	WindowSamples int
+	// ONNXRuntimeLibPath is as Config.ONNXRuntimeLibPath.
+	ONNXRuntimeLibPath string
+
+	// Threshold is the speech probability above which a chunk is speech
+	// (default 0.5).
+	Threshold float32
+	// MinSilenceMs is the silence that ends a speech span (default 100).
+	MinSilenceMs int
+	// PadMs widens every span on both sides (default 30); spans that then
+	// overlap are merged. Negative disables padding.
+	PadMs int
+}
+
+// SpeechSpan is a region of speech in samples: [Start, End).
+type SpeechSpan struct {
+	Start, End int
+}
+
+// SpeechDetector runs the VAD over whole buffers, for trimming recordings
+// and preparing datasets. Each call starts from a fresh VAD state and
+// resets it every 5 seconds of audio, as a streaming engine does in real
+// time. Calls are safe from any goroutine; they run one at a time.
+type SpeechDetector struct {
+	opts DetectorOptions
+
+	mu          sync.Mutex
+	vad         VADBackend
+	clock       *audioClock
+	chunk       [RequiredChunkSize]float32
+	usesRuntime bool
+	closed      bool
+}
+
+// NewSpeechDetector loads the Silero VAD model at modelPath.
+func NewSpeechDetector(modelPath string, opts DetectorOptions) (*SpeechDetector, error) {
+	if opts.Threshold < 0 || opts.Threshold > 1 {
+		return nil, errors.New("smart-turn: DetectorOptions.Threshold must be in [0, 1]")
+	}
+	if opts.MinSilenceMs < 0 {
+		return nil, errors.New("smart-turn: DetectorOptions.MinSilenceMs must be >= 0")
+	}
+	if opts.Threshold == 0 {
+		opts.Threshold = DefaultDetectorThreshold
+	}
+	if opts.MinSilenceMs == 0 {
+		opts.MinSilenceMs = DefaultDetectorMinSilenceMs
+	}
+	if opts.PadMs == 0 {
+		opts.PadMs = DefaultDetectorPadMs
+	}
+	d := &SpeechDetector{opts: opts, vad: opts.Backend, clock: &audioClock{}}
+	if d.vad != nil {
+		return d, nil
+	}
+	if err := validateSessionOptions("SessionOptions", opts.SessionOptions); err != nil {
+		return nil, err
+	}
+	if modelPath == "" {
+		return nil, errors.New("smart-turn: model path is required")
+	}
+	if opts.WindowSamples != 0 && !validSileroWindow(opts.WindowSamples) {
+		return nil, errors.New("smart-turn: DetectorOptions.WindowSamples must be 256, 512 or 768")
+	}
+	if err := acquireRuntime(runtimeLibPath(Config{ONNXRuntimeLibPath: opts.ONNXRuntimeLibPath})); err != nil {
+		return nil, err
+	}
+	silero, err := newSileroVAD(modelPath, RequiredSampleRate, opts.WindowSamples, opts.SessionOptions, d.clock)
+	if err != nil {
+		_ = releaseRuntime()
+		return nil, err
+	}
+	d.vad = silero
+	d.usesRuntime = true
+	return d, nil
+}
+
+// Probabilities returns the speech probability of every 512-sample chunk
+// of audio (16 kHz mono); a partial last chunk is zero-padded.
+func (d *SpeechDetector) Probabilities(audio []float32) ([]float32, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, ErrClosed
+	}
+	d.vad.Reset()
+	probs := make([]float32, 0, ceilDiv(len(audio), RequiredChunkSize))
+	for off := 0; off < len(audio); off += RequiredChunkSize {
+		n := copy(d.chunk[:], audio[off:])
+		clear(d.chunk[n:])
+		sanitizeInPlace(d.chunk[:])
+		p, err := d.vad.SpeechProb(d.chunk[:])
+		if err != nil {
+			return nil, err
+		}
+		probs = append(probs, p)
+		d.clock.advance(chunkDuration)
+	}
+	return probs, nil
+}
+
+// DetectSpeech returns the speech spans of audio (16 kHz mono) in order.
+func (d *SpeechDetector) DetectSpeech(audio []float32) ([]SpeechSpan, error) {
+	probs, err := d.Probabilities(audio)
+	if err != nil {
+		return nil, err
+	}
+	return speechSpans(probs, len(audio), d.opts), nil
+}
+
+// Close releases the model. Later calls return ErrClosed.
+func (d *SpeechDetector) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	err := d.vad.Close()
+	if d.usesRuntime {
+		if rerr := releaseRuntime(); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// speechSpans turns chunk probabilities into padded, merged sample spans.
+func speechSpans(probs []float32, samples int, opts DetectorOptions) []SpeechSpan {
+	minSilence := max(ceilDiv(opts.MinSilenceMs*RequiredSampleRate/1000, RequiredChunkSize), 1)
+	pad := max(opts.PadMs, 0) * RequiredSampleRate / 1000
+	var spans []SpeechSpan
+	add := func(startChunk, endChunk int) {
+		s := SpeechSpan{
+			Start: max(startChunk*RequiredChunkSize-pad, 0),
+			End:   min(endChunk*RequiredChunkSize+pad, samples),
+		}
+		if n := len(spans); n > 0 && s.Start <= spans[n-1].End {
+			spans[n-1].End = s.End
+			return
+		}
+		spans = append(spans, s)
+	}
+	start, silence := -1, 0
+	for i, p := range probs {
+		switch {
+		case p > opts.Threshold:
+			if start < 0 {
+				start = i
+			}
+			silence = 0
+		case start >= 0:
+			silence++
+			if silence >= minSilence {
+				add(start, i+1-silence)
+				start, silence = -1, 0
+			}
+		}
+	}
+	if start >= 0 {
+		add(start, len(probs)-silence)
+	}
+	return spans
+}
+
+// audioClock is a Clock driven by audio time, so Silero's periodic state
+// reset happens at the same points of a buffer on every run.
+type audioClock struct{ t time.Time }
+
+func (c *audioClock) Now() time.Time          { return c.t }
+func (c *audioClock) advance(d time.Duration) { c.t = c.t.Add(d) }
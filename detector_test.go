@@ -0,0 +1,153 @@
+package smartturn_test
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// vadScript is a VADBackend that returns Probabilities in order from
+// each Reset, 0 past their end.
+type vadScript struct {
+	Probabilities []float32
+	next          int
+	closed        bool
+}
+
+func (v *vadScript) SpeechProb([]float32) (float32, error) {
+	v.next++
+	if v.next > len(v.Probabilities) {
+		return 0, nil
+	}
+	return v.Probabilities[v.next-1], nil
+}
+
+func (v *vadScript) Reset()       { v.next = 0 }
+func (v *vadScript) Close() error { v.closed = true; return nil }
+
+// TestSpeechSpans checks span building from chunk probabilities: the
+// threshold, the silence that ends a span, padding, merging, and clamping
+// to the audio.
+func TestSpeechSpans(t *testing.T) {
+	const c = smartturn.RequiredChunkSize
+	for _, tc := range []struct {
+		name    string
+		probs   []float32
+		samples int
+		opts    smartturn.DetectorOptions // Backend is set by the test
+		want    []smartturn.SpeechSpan
+	}{
+		{"none", []float32{0, 0.5, 0.2}, 3 * c, smartturn.DetectorOptions{PadMs: -1}, nil},
+		// 100 ms of silence is 4 chunks.
+		{"two spans", []float32{0, 1, 1, 0, 0, 0, 0, 0, 1, 1, 0, 0}, 12 * c, smartturn.DetectorOptions{PadMs: -1},
+			[]smartturn.SpeechSpan{{1 * c, 3 * c}, {8 * c, 10 * c}}},
+		{"short gap", []float32{1, 1, 0, 0, 0, 1}, 6 * c, smartturn.DetectorOptions{PadMs: -1},
+			[]smartturn.SpeechSpan{{0, 6 * c}}},
+		{"min silence", []float32{1, 1, 0, 0, 1}, 5 * c, smartturn.DetectorOptions{PadMs: -1, MinSilenceMs: 50},
+			[]smartturn.SpeechSpan{{0, 2 * c}, {4 * c, 5 * c}}},
+		{"threshold", []float32{0.3, 0.6, 0.6, 0.3}, 4 * c, smartturn.DetectorOptions{PadMs: -1, Threshold: 0.25},
+			[]smartturn.SpeechSpan{{0, 4 * c}}},
+		// 30 ms of padding is 480 samples.
+		{"padding", []float32{0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0}, 16 * c, smartturn.DetectorOptions{},
+			[]smartturn.SpeechSpan{{2*c - 480, 3*c + 480}, {10*c - 480, 11*c + 480}}},
+		{"merged by padding", []float32{0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0}, 12 * c, smartturn.DetectorOptions{PadMs: 100},
+			[]smartturn.SpeechSpan{{0, 8*c + 1600}}},
+		// Padded by 1024 samples, the spans meet at 1536.
+		{"touching", []float32{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0}, 12 * c, smartturn.DetectorOptions{PadMs: 64},
+			[]smartturn.SpeechSpan{{0, 6*c + 1024}}},
+		{"clamped", []float32{1, 0, 0, 0, 0, 1}, 5*c + 100, smartturn.DetectorOptions{},
+			[]smartturn.SpeechSpan{{0, c + 480}, {5*c - 480, 5*c + 100}}},
+	} {
+		vad := &vadScript{Probabilities: tc.probs}
+		tc.opts.Backend = vad
+		d, err := smartturn.NewSpeechDetector("", tc.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		spans, err := d.DetectSpeech(make([]float32, tc.samples))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(spans, tc.want) {
+			t.Errorf("%s: spans %v, want %v", tc.name, spans, tc.want)
+		}
+		// Every call starts from a fresh VAD state.
+		if again, _ := d.DetectSpeech(make([]float32, tc.samples)); !slices.Equal(again, spans) {
+			t.Errorf("%s: second call %v, first %v", tc.name, again, spans)
+		}
+		if err := d.Close(); err != nil || !vad.closed {
+			t.Errorf("%s: Close: %v, backend closed %v", tc.name, err, vad.closed)
+		}
+		if _, err := d.DetectSpeech(nil); !errors.Is(err, smartturn.ErrClosed) {
+			t.Errorf("%s: after Close: error %v, want ErrClosed", tc.name, err)
+		}
+	}
+}
+
+// TestDetectSpeechSynth finds the speech of generated audio with an
+// energy VAD, to chunk accuracy.
+func TestDetectSpeechSynth(t *testing.T) {
+	audio, parts := smartturntest.Synth{Seed: 12}.Generate(
+		smartturntest.Silence(500*time.Millisecond), smartturntest.Speech(time.Second),
+		smartturntest.Silence(700*time.Millisecond), smartturntest.Speech(1500*time.Millisecond),
+		smartturntest.Silence(300*time.Millisecond))
+	// NaN is read as silence rather than failing the call.
+	audio[100] = float32(math.NaN())
+	var want []smartturntest.Span
+	for _, p := range parts {
+		if p.Speech {
+			want = append(want, p)
+		}
+	}
+	d, err := smartturn.NewSpeechDetector("", smartturn.DetectorOptions{Backend: &smartturntest.EnergyVAD{}, PadMs: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	probs, err := d.Probabilities(audio[:len(audio)-100])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := (len(audio) - 100 + 511) / 512; len(probs) != n {
+		t.Errorf("%d probabilities, want %d", len(probs), n)
+	}
+	spans, err := d.DetectSpeech(audio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != len(want) {
+		t.Fatalf("spans %v, want %v", spans, want)
+	}
+	for i, s := range spans {
+		if abs(s.Start-want[i].Start) > 512 || abs(s.End-want[i].End) > 512 {
+			t.Errorf("span %d = %v, want %v to a chunk", i, s, want[i])
+		}
+	}
+}
+
+func abs(n int) int { return max(n, -n) }
+
+func TestNewSpeechDetectorErrors(t *testing.T) {
+	for name, opts := range map[string]smartturn.DetectorOptions{
+		"no model":           {},
+		"threshold":          {Backend: &vadScript{}, Threshold: 1.5},
+		"min silence":        {Backend: &vadScript{}, MinSilenceMs: -1},
+		"window":             {WindowSamples: 300},
+		"negative threshold": {Backend: &vadScript{}, Threshold: -0.1},
+	} {
+		path := ""
+		if name == "window" {
+			path = "silero_vad.onnx"
+		}
+		if d, err := smartturn.NewSpeechDetector(path, opts); err == nil {
+			d.Close()
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package smartturn
+
+import (
+	"log/slog"
+	"math"
+)
+
+// DTMF frequencies (Hz): rows, then columns of the keypad.
+var (
+	dtmfRows   = [4]float64{697, 770, 852, 941}
+	dtmfCols   = [4]float64{1209, 1336, 1477, 1633}
+	dtmfDigits = [4][4]rune{
+		{'1', '2', '3', 'A'},
+		{'4', '5', '6', 'B'},
+		{'7', '8', '9', 'C'},
+		{'*', '0', '#', 'D'},
+	}
+)
+
+const (
+	// dtmfMinRMS ignores chunks quieter than about -46 dBFS.
+	dtmfMinRMS = 0.005
+	// dtmfMinShare is the share of chunk energy the two tones must carry;
+	// speech and music spread theirs far wider. It is low enough to catch
+	// a tone filling half a chunk, so a 40 ms tone is found at any offset.
+	dtmfMinShare = 0.4
+	// dtmfMaxTwist bounds the power ratio of the two tones (±8 dB).
+	dtmfMaxTwist = 6.3
+)
+
+// dtmfDetector finds DTMF tones in 512-sample chunks with the Goertzel
+// algorithm. It keeps the digit of the previous chunk so a tone spanning
+// several chunks is reported once.
+type dtmfDetector struct {
+	rowCoef, colCoef [4]float64
+	last             rune // 0 when the previous chunk had no tone
+}
+
+func newDTMFDetector() dtmfDetector {
+	var d dtmfDetector
+	for i := range dtmfRows {
+		d.rowCoef[i] = 2 * math.Cos(2*math.Pi*dtmfRows[i]/RequiredSampleRate)
+		d.colCoef[i] = 2 * math.Cos(2*math.Pi*dtmfCols[i]/RequiredSampleRate)
+	}
+	return d
+}
+
+// detect reports whether chunk belongs to a tone and so must be silenced,
+// and returns the tone's digit on its first chunk, 0 otherwise. The chunk
+// after a tone counts too: it holds the tone's tail, too short to
+// recognise but loud enough for VAD.
+func (d *dtmfDetector) detect(chunk []float32) (digit rune, tone bool) {
+	digit, last := d.digit(chunk), d.last
+	d.last = digit
+	switch {
+	case digit == last:
+		return 0, digit != 0
+	case digit != 0:
+		return digit, true
+	}
+	return 0, true
+}
+
+func (d *dtmfDetector) digit(chunk []float32) rune {
+	var energy float64
+	for _, v := range chunk {
+		energy += float64(v) * float64(v)
+	}
+	n := float64(len(chunk))
+	if energy < dtmfMinRMS*dtmfMinRMS*n {
+		return 0
+	}
+	row, rowPower := strongest(chunk, &d.rowCoef)
+	col, colPower := strongest(chunk, &d.colCoef)
+	if rowPower > dtmfMaxTwist*colPower || colPower > dtmfMaxTwist*rowPower {
+		return 0
+	}
+	// A full-scale sinusoid of amplitude A has Goertzel power A²n²/4 and
+	// energy A²n/2, so 2P/(n·energy) is the share of energy at its frequency.
+	if 2*(rowPower+colPower)/(n*energy) < dtmfMinShare {
+		return 0
+	}
+	return dtmfDigits[row][col]
+}
+
+// strongest returns the index and Goertzel power of the strongest of four
+// frequencies.
+func strongest(chunk []float32, coef *[4]float64) (int, float64) {
+	best, bestPower := 0, 0.0
+	for i, c := range coef {
+		var s1, s2 float64
+		for _, v := range chunk {
+			s1, s2 = float64(v)+c*s1-s2, s1
+		}
+		if p := s1*s1 + s2*s2 - c*s1*s2; p > bestPower {
+			best, bestPower = i, p
+		}
+	}
+	return best, bestPower
+}
+
+// dtmf checks a chunk for a DTMF tone. A tone is reported once through
+// OnDTMF, and its chunks are replaced by silence so the tone neither
+// triggers VAD nor ends up in a segment.
+func (e *Engine) dtmf(chunk []float32) []float32 {
+	digit, tone := e.dtmfDet.detect(chunk)
+	if !tone {
+		return chunk
+	}
+	if digit != 0 {
+		if e.logs(slog.LevelDebug) {
+			e.log.Debug("dtmf tone", "digit", string(digit))
+		}
+		e.record(Event{Kind: EventDTMF, Digit: digit})
+		if e.cb.OnDTMF != nil {
+			e.cb.OnDTMF(digit)
+		}
+	}
+	return e.silence[:]
+}
@@ -0,0 +1,155 @@
+package smartturn_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+const keypad = "123A456B789C*0#D"
+
+// dtmfFreqs returns the row and column frequencies of a keypad digit.
+func dtmfFreqs(digit rune) (row, col float64) {
+	rows := []float64{697, 770, 852, 941}
+	cols := []float64{1209, 1336, 1477, 1633}
+	for i, d := range keypad {
+		if d == digit {
+			return rows[i/4], cols[i%4]
+		}
+	}
+	panic("not a DTMF digit")
+}
+
+// tone returns d of two sinusoids with amplitudes a1 and a2, plus white
+// noise of amplitude noise.
+func tone(f1, a1, f2, a2, noise float64, d time.Duration, rng *rand.Rand) []float32 {
+	out := make([]float32, int(d*16000/time.Second))
+	for i := range out {
+		t := float64(i) / 16000
+		v := a1*math.Sin(2*math.Pi*f1*t) + a2*math.Sin(2*math.Pi*f2*t)
+		if noise > 0 {
+			v += noise * (2*rng.Float64() - 1)
+		}
+		out[i] = float32(v)
+	}
+	return out
+}
+
+// dtmfEngine returns an engine with DetectDTMF that records the digits it
+// reports and the number of speech starts.
+func dtmfEngine(t *testing.T, detect bool) (e *smartturn.Engine, digits *[]rune, starts *int) {
+	digits, starts = new([]rune), new(int)
+	e = newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+		OnDTMF:        func(d rune) { *digits = append(*digits, d) },
+		OnSpeechStart: func() { *starts++ },
+	}, func(cfg *smartturn.Config) { cfg.DetectDTMF = detect })
+	return e, digits, starts
+}
+
+// press returns a key press: d of its tone, then 100 ms of silence and
+// up to a chunk more, so that presses in a row each start a chunk.
+func press(digit rune, d time.Duration, noise float64, rng *rand.Rand) []float32 {
+	row, col := dtmfFreqs(digit)
+	audio := append(tone(row, 0.3, col, 0.25, noise, d, rng), silence(100*time.Millisecond)...)
+	return append(audio, make([]float32, (512-len(audio)%512)%512)...)
+}
+
+func TestDTMF(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var audio []float32
+	for _, d := range keypad {
+		audio = append(audio, press(d, 100*time.Millisecond, 0.05, rng)...)
+	}
+	// A long tone is reported once; the same key pressed again is reported
+	// again.
+	audio = append(audio, press('0', time.Second, 0, rng)...)
+	audio = append(audio, press('0', 70*time.Millisecond, 0, rng)...)
+	audio = append(audio, silence(400*time.Millisecond)...)
+
+	e, digits, starts := dtmfEngine(t, true)
+	pushAll(t, e, audio)
+	if got, want := string(*digits), keypad+"00"; got != want || *starts != 0 {
+		t.Errorf("digits %q, want %q; %d speech starts, want 0", got, want, *starts)
+	}
+
+	// Without DetectDTMF the tones are speech.
+	e, digits, starts = dtmfEngine(t, false)
+	pushAll(t, e, audio)
+	if len(*digits) != 0 || *starts == 0 {
+		t.Errorf("DetectDTMF off: digits %q, %d speech starts", string(*digits), *starts)
+	}
+}
+
+// TestDTMFShortTone checks that a one-chunk (32 ms) tone is found at any
+// offset in the chunk grid, where it may fill as little as half a chunk.
+func TestDTMFShortTone(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for offset := 0; offset < 512; offset += 32 {
+		audio := append(silence(time.Duration(offset)*time.Second/16000), tone(852, 0.3, 1477, 0.3, 0.02, 32*time.Millisecond, rng)...)
+		audio = append(audio, silence(100*time.Millisecond)...)
+		e, digits, _ := dtmfEngine(t, true)
+		pushAll(t, e, audio)
+		if string(*digits) != "9" {
+			t.Errorf("32ms tone at offset %d: digits %q, want \"9\"", offset, string(*digits))
+		}
+	}
+}
+
+// TestDTMFRejects checks for false positives on speech, noise, single
+// tones, unbalanced pairs and quiet tones.
+func TestDTMFRejects(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	cases := map[string][]float32{
+		"white noise": tone(0, 0, 0, 0, 0.3, 2*time.Second, rng),
+		"1 kHz":       tone(1000, 0.5, 0, 0, 0, time.Second, rng),
+		"twist":       tone(770, 0.5, 1209, 0.15, 0, time.Second, rng),
+		"quiet":       tone(770, 0.003, 1209, 0.003, 0, time.Second, rng),
+	}
+	for _, f := range []float64{697, 770, 852, 941, 1209, 1336, 1477, 1633} {
+		cases["single tone"] = append(cases["single tone"], tone(f, 0.5, 0, 0, 0.01, 300*time.Millisecond, rng)...)
+		cases["single tone"] = append(cases["single tone"], silence(100*time.Millisecond)...)
+	}
+	for seed := uint64(1); seed <= 8; seed++ {
+		speech, _ := smartturntest.Synth{Seed: seed}.Generate(smartturntest.Speech(5 * time.Second))
+		cases["speech"] = append(cases["speech"], speech...)
+	}
+	for name, audio := range cases {
+		e, digits, _ := dtmfEngine(t, true)
+		pushAll(t, e, audio)
+		if len(*digits) != 0 {
+			t.Errorf("%s: digits %q", name, string(*digits))
+		}
+	}
+
+	// Speech is not silenced.
+	speech, _ := smartturntest.Synth{Seed: 1}.Generate(smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	e, _, starts := dtmfEngine(t, true)
+	pushAll(t, e, speech)
+	if *starts != 1 {
+		t.Errorf("%d speech starts for speech with DetectDTMF, want 1", *starts)
+	}
+}
+
+func TestDTMFProcess(t *testing.T) {
+	e, _, _ := dtmfEngine(t, true)
+	audio := append(tone(697, 0.3, 1633, 0.3, 0, 200*time.Millisecond, nil), silence(100*time.Millisecond)...)
+	var got []rune
+	for _, c := range smartturntest.Chunks(audio) {
+		events, err := e.Process(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ev := range events {
+			if ev.Kind == smartturn.EventDTMF {
+				got = append(got, ev.Digit)
+			}
+		}
+	}
+	if string(got) != "A" {
+		t.Errorf("EventDTMF digits %q, want \"A\"", string(got))
+	}
+}
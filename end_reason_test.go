@@ -0,0 +1,97 @@
+package smartturn_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// failingTurn is a TurnBackend whose every prediction fails.
+type failingTurn struct{}
+
+func (failingTurn) Predict([]float32) (float32, error) { return 0, errors.New("inference failed") }
+func (failingTurn) Close() error                       { return nil }
+
+// TestTurnEndReason checks the reason OnTurnEnd and EventSpeechEnd give
+// for each way a turn ends, and that OnTurnEnd comes right before
+// OnSpeechEnd.
+func TestTurnEndReason(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		probs []float32
+		tweak func(*smartturn.Config)
+		parts []smartturntest.Part
+		want  smartturn.TurnEndReason
+	}{
+		{"model", []float32{0.9}, nil,
+			[]smartturntest.Part{smartturntest.Speech(time.Second), smartturntest.Silence(600 * time.Millisecond)},
+			smartturn.TurnEndModel},
+		{"incomplete", []float32{0.1}, nil,
+			[]smartturntest.Part{smartturntest.Speech(time.Second), smartturntest.Silence(2 * time.Second)},
+			smartturn.TurnEndTimeout},
+		{"failed", nil, func(cfg *smartturn.Config) { cfg.TurnBackend = failingTurn{} },
+			[]smartturntest.Part{smartturntest.Speech(time.Second), smartturntest.Silence(2 * time.Second)},
+			smartturn.TurnEndTimeout},
+		{"max duration", []float32{0.1}, func(cfg *smartturn.Config) { cfg.TurnMaxDurationSeconds = 1 },
+			[]smartturntest.Part{smartturntest.Speech(1500 * time.Millisecond)},
+			smartturn.TurnEndMaxDuration},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			audio, _ := smartturntest.Synth{Seed: 5}.Generate(tc.parts...)
+
+			var reasons []smartturn.TurnEndReason
+			last := ""
+			e := newTestEngine(t, tc.probs, smartturn.Callbacks{
+				OnTurnEnd: func(r smartturn.TurnEndReason) {
+					reasons = append(reasons, r)
+					last = "turn end"
+				},
+				OnSpeechEnd: func() {
+					if last != "turn end" {
+						t.Error("OnSpeechEnd without OnTurnEnd right before it")
+					}
+					last = "speech end"
+				},
+				OnError: func(error) {},
+			}, tc.tweak)
+			pushAll(t, e, audio)
+			if len(reasons) != 1 || reasons[0] != tc.want {
+				t.Errorf("OnTurnEnd reasons %v, want [%v]", reasons, tc.want)
+			}
+
+			e = newTestEngine(t, tc.probs, smartturn.Callbacks{OnError: func(error) {}}, tc.tweak)
+			var ends []smartturn.TurnEndReason
+			for _, c := range smartturntest.Chunks(audio) {
+				events, err := e.Process(c)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, ev := range events {
+					if ev.Kind == smartturn.EventSpeechEnd {
+						ends = append(ends, ev.EndReason)
+					}
+				}
+			}
+			if len(ends) != 1 || ends[0] != tc.want {
+				t.Errorf("EventSpeechEnd reasons %v, want [%v]", ends, tc.want)
+			}
+		})
+	}
+}
+
+func TestTurnEndReasonString(t *testing.T) {
+	for r, want := range map[smartturn.TurnEndReason]string{
+		smartturn.TurnEndModel:         "model",
+		smartturn.TurnEndTimeout:       "timeout",
+		smartturn.TurnEndMaxDuration:   "max_duration",
+		smartturn.TurnEndSpeakerChange: "speaker_change",
+		smartturn.TurnEndReason(99):    "unknown",
+	} {
+		if got := r.String(); got != want {
+			t.Errorf("TurnEndReason(%d).String() = %q, want %q", int(r), got, want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package smartturn
+
+import (
+	"errors"
+	"math"
+)
+
+// EndpointingParams are the endpointing settings of a hosted ASR API,
+// translated onto the engine's, to ease replacing hosted endpointing with
+// local Smart-Turn. Settings carry over in intent rather than exactly:
+// where the hosted API ends an utterance after a fixed silence, the engine
+// waits that long (VadStopMs) and then asks Smart-Turn, ending the turn
+// only if it is complete, or once the longer forced-end silence
+// (VadStopMs + TurnTimeoutMs) has passed.
+type EndpointingParams interface {
+	// Overrides returns o with the translated settings replaced.
+	Overrides(o SessionOverrides) (SessionOverrides, error)
+}
+
+// ApplyEndpointing returns cfg with p's settings translated, e.g.
+//
+//	cfg, err = smartturn.ApplyEndpointing(cfg, smartturn.DeepgramEndpointing{EndpointingMs: 300, UtteranceEndMs: 1500})
+//
+// For a SessionGroup, call p.Overrides(group.Defaults()) instead.
+func ApplyEndpointing(cfg Config, p EndpointingParams) (Config, error) {
+	o, err := p.Overrides(overridesOf(cfg))
+	if err != nil {
+		return cfg, err
+	}
+	return o.apply(cfg), nil
+}
+
+// DeepgramEndpointing holds Deepgram's live endpointing parameters. Zero
+// fields keep the current settings.
+type DeepgramEndpointing struct {
+	// EndpointingMs (endpointing) is the silence that finalizes speech; it
+	// becomes VadStopMs. Deepgram's default of 10 ms suits its own model;
+	// Smart-Turn works best with a few hundred. Deepgram's endpointing=false
+	// has no equivalent: Smart-Turn always endpoints, so leave it 0.
+	EndpointingMs int
+	// UtteranceEndMs (utterance_end_ms) is the silence after which the
+	// utterance ends regardless; TurnTimeoutMs becomes what remains of it
+	// after VadStopMs.
+	UtteranceEndMs int
+}
+
+// Overrides implements EndpointingParams.
+func (p DeepgramEndpointing) Overrides(o SessionOverrides) (SessionOverrides, error) {
+	if p.EndpointingMs < 0 || p.UtteranceEndMs < 0 {
+		return o, errors.New("config: Deepgram endpointing and utterance_end_ms must be >= 0")
+	}
+	if p.EndpointingMs > 0 {
+		o.VadStopMs = p.EndpointingMs
+	}
+	if p.UtteranceEndMs > 0 {
+		o.TurnTimeoutMs = forcedEndTimeout(p.UtteranceEndMs, o.VadStopMs)
+	}
+	return o, nil
+}
+
+// GladiaEndpointing holds Gladia's live endpointing parameters, in seconds
+// as Gladia takes them. Zero fields keep the current settings.
+type GladiaEndpointing struct {
+	// Endpointing is the silence in seconds that ends an utterance; it
+	// becomes VadStopMs.
+	Endpointing float64
+	// MaximumDurationWithoutEndpointing
+	// (maximum_duration_without_endpointing) caps an utterance, in
+	// seconds; it becomes TurnMaxDurationSeconds.
+	MaximumDurationWithoutEndpointing float64
+}
+
+// Overrides implements EndpointingParams.
+func (p GladiaEndpointing) Overrides(o SessionOverrides) (SessionOverrides, error) {
+	if p.Endpointing < 0 || p.MaximumDurationWithoutEndpointing < 0 {
+		return o, errors.New("config: Gladia endpointing durations must be >= 0")
+	}
+	if p.Endpointing > 0 {
+		o.VadStopMs = max(int(math.Round(p.Endpointing*1000)), 1)
+	}
+	if p.MaximumDurationWithoutEndpointing > 0 {
+		o.TurnMaxDurationSeconds = float32(p.MaximumDurationWithoutEndpointing)
+	}
+	return o, nil
+}
+
+// AssemblyAITurnDetection holds AssemblyAI's streaming turn detection
+// parameters, whose end-of-turn model plays the part of Smart-Turn. Zero
+// fields keep the current settings.
+type AssemblyAITurnDetection struct {
+	// EndOfTurnConfidenceThreshold (end_of_turn_confidence_threshold)
+	// becomes TurnThreshold.
+	EndOfTurnConfidenceThreshold float32
+	// MinEndOfTurnSilenceWhenConfidentMs
+	// (min_end_of_turn_silence_when_confident) is the silence before the
+	// model is asked; it becomes VadStopMs.
+	MinEndOfTurnSilenceWhenConfidentMs int
+	// MaxTurnSilenceMs (max_turn_silence) is the silence that ends the turn
+	// regardless; TurnTimeoutMs becomes what remains of it after
+	// VadStopMs.
+	MaxTurnSilenceMs int
+}
+
+// Overrides implements EndpointingParams.
+func (p AssemblyAITurnDetection) Overrides(o SessionOverrides) (SessionOverrides, error) {
+	if p.EndOfTurnConfidenceThreshold < 0 || p.EndOfTurnConfidenceThreshold > 1 {
+		return o, errors.New("config: AssemblyAI end_of_turn_confidence_threshold must be in [0, 1]")
+	}
+	if p.MinEndOfTurnSilenceWhenConfidentMs < 0 || p.MaxTurnSilenceMs < 0 {
+		return o, errors.New("config: AssemblyAI turn silences must be >= 0")
+	}
+	if p.EndOfTurnConfidenceThreshold > 0 {
+		o.TurnThreshold = p.EndOfTurnConfidenceThreshold
+	}
+	if p.MinEndOfTurnSilenceWhenConfidentMs > 0 {
+		o.VadStopMs = p.MinEndOfTurnSilenceWhenConfidentMs
+	}
+	if p.MaxTurnSilenceMs > 0 {
+		o.TurnTimeoutMs = forcedEndTimeout(p.MaxTurnSilenceMs, o.VadStopMs)
+	}
+	return o, nil
+}
+
+// forcedEndTimeout returns the TurnTimeoutMs that ends a turn after
+// silenceMs of silence in total, of which VadStopMs pass before Smart-Turn
+// is asked. A silence no longer than VadStopMs ends the turn right after
+// the first incomplete verdict.
+func forcedEndTimeout(silenceMs, vadStopMs int) int {
+	return max(silenceMs-vadStopMs, 1)
+}
@@ -0,0 +1,160 @@
+package smartturn_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// TestApplyEndpointing checks each translation against benchConfig, in
+// which VadStopMs is 800, TurnTimeoutMs 1000, TurnThreshold 0.5 and
+// TurnMaxDurationSeconds 600.
+func TestApplyEndpointing(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		p    smartturn.EndpointingParams
+		want func(*smartturn.Config)
+	}{
+		{"Deepgram", smartturn.DeepgramEndpointing{EndpointingMs: 300, UtteranceEndMs: 1500},
+			func(c *smartturn.Config) { c.VadStopMs, c.TurnTimeoutMs = 300, 1200 }},
+		{"Deepgram endpointing", smartturn.DeepgramEndpointing{EndpointingMs: 10},
+			func(c *smartturn.Config) { c.VadStopMs = 10 }},
+		// The forced end counts from the current VadStopMs.
+		{"Deepgram utterance end", smartturn.DeepgramEndpointing{UtteranceEndMs: 2000},
+			func(c *smartturn.Config) { c.TurnTimeoutMs = 1200 }},
+		{"Deepgram utterance end within endpointing", smartturn.DeepgramEndpointing{EndpointingMs: 500, UtteranceEndMs: 400},
+			func(c *smartturn.Config) { c.VadStopMs, c.TurnTimeoutMs = 500, 1 }},
+		{"Deepgram zero", smartturn.DeepgramEndpointing{}, nil},
+		{"Gladia", smartturn.GladiaEndpointing{Endpointing: 0.3, MaximumDurationWithoutEndpointing: 30},
+			func(c *smartturn.Config) { c.VadStopMs, c.TurnMaxDurationSeconds = 300, 30 }},
+		{"Gladia rounding", smartturn.GladiaEndpointing{Endpointing: 0.0126},
+			func(c *smartturn.Config) { c.VadStopMs = 13 }},
+		{"Gladia under a millisecond", smartturn.GladiaEndpointing{Endpointing: 0.0004},
+			func(c *smartturn.Config) { c.VadStopMs = 1 }},
+		{"Gladia maximum duration", smartturn.GladiaEndpointing{MaximumDurationWithoutEndpointing: 12.5},
+			func(c *smartturn.Config) { c.TurnMaxDurationSeconds = 12.5 }},
+		{"Gladia zero", smartturn.GladiaEndpointing{}, nil},
+		{"AssemblyAI", smartturn.AssemblyAITurnDetection{
+			EndOfTurnConfidenceThreshold: 0.7, MinEndOfTurnSilenceWhenConfidentMs: 160, MaxTurnSilenceMs: 2400},
+			func(c *smartturn.Config) { c.TurnThreshold, c.VadStopMs, c.TurnTimeoutMs = 0.7, 160, 2240 }},
+		{"AssemblyAI threshold", smartturn.AssemblyAITurnDetection{EndOfTurnConfidenceThreshold: 1},
+			func(c *smartturn.Config) { c.TurnThreshold = 1 }},
+		{"AssemblyAI max turn silence", smartturn.AssemblyAITurnDetection{MaxTurnSilenceMs: 1280},
+			func(c *smartturn.Config) { c.TurnTimeoutMs = 480 }},
+		{"AssemblyAI max turn silence within min silence", smartturn.AssemblyAITurnDetection{MaxTurnSilenceMs: 800},
+			func(c *smartturn.Config) { c.TurnTimeoutMs = 1 }},
+		{"AssemblyAI zero", smartturn.AssemblyAITurnDetection{}, nil},
+	} {
+		cfg := benchConfig()
+		cfg.VADBackend = &smartturntest.EnergyVAD{}
+		cfg.TurnBackend = &smartturntest.TurnScript{}
+		cfg.TurnMergeGapMs = 200
+		want := cfg
+		if tc.want != nil {
+			tc.want(&want)
+		}
+		got, err := smartturn.ApplyEndpointing(cfg, tc.p)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: %+v, want %+v", tc.name, got, want)
+		}
+		e, err := smartturn.New(got, smartturn.Callbacks{})
+		if err != nil {
+			t.Errorf("%s: New: %v", tc.name, err)
+			continue
+		}
+		e.Close()
+
+		// For a SessionGroup, Overrides translates the same.
+		o, err := tc.p.Overrides(smartturn.SessionOverrides{
+			VadThreshold: cfg.VadThreshold, VadPreSpeechMs: cfg.VadPreSpeechMs, VadStopMs: cfg.VadStopMs,
+			TurnThreshold: cfg.TurnThreshold, TurnTimeoutMs: cfg.TurnTimeoutMs,
+			TurnMaxDurationSeconds: cfg.TurnMaxDurationSeconds, TurnMergeGapMs: cfg.TurnMergeGapMs,
+			TurnSegmentEmitMs: cfg.TurnSegmentEmitMs,
+		})
+		wantO := smartturn.SessionOverrides{
+			VadThreshold: want.VadThreshold, VadPreSpeechMs: want.VadPreSpeechMs, VadStopMs: want.VadStopMs,
+			TurnThreshold: want.TurnThreshold, TurnTimeoutMs: want.TurnTimeoutMs,
+			TurnMaxDurationSeconds: want.TurnMaxDurationSeconds, TurnMergeGapMs: want.TurnMergeGapMs,
+			TurnSegmentEmitMs: want.TurnSegmentEmitMs,
+		}
+		if err != nil || o != wantO {
+			t.Errorf("%s: Overrides %+v, %v; want %+v", tc.name, o, err, wantO)
+		}
+	}
+}
+
+func TestApplyEndpointingErrors(t *testing.T) {
+	for _, p := range []smartturn.EndpointingParams{
+		smartturn.DeepgramEndpointing{EndpointingMs: -1},
+		smartturn.DeepgramEndpointing{EndpointingMs: 300, UtteranceEndMs: -1},
+		smartturn.GladiaEndpointing{Endpointing: -0.1},
+		smartturn.GladiaEndpointing{Endpointing: 0.3, MaximumDurationWithoutEndpointing: -1},
+		smartturn.AssemblyAITurnDetection{EndOfTurnConfidenceThreshold: -0.1},
+		smartturn.AssemblyAITurnDetection{EndOfTurnConfidenceThreshold: 1.1},
+		smartturn.AssemblyAITurnDetection{MinEndOfTurnSilenceWhenConfidentMs: -1},
+		smartturn.AssemblyAITurnDetection{MaxTurnSilenceMs: -1},
+	} {
+		cfg := benchConfig()
+		got, err := smartturn.ApplyEndpointing(cfg, p)
+		if err == nil {
+			t.Errorf("%#v accepted", p)
+		}
+		if !reflect.DeepEqual(got, cfg) {
+			t.Errorf("%#v: config changed on error", p)
+		}
+	}
+}
+
+// TestApplyEndpointingTurns checks the intent of a Deepgram translation on
+// an engine: a complete turn ends endpointing after the speech, and an
+// incomplete one utterance_end after it.
+func TestApplyEndpointingTurns(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 2}.Generate(smartturntest.Speech(time.Second), smartturntest.Silence(3*time.Second))
+	p := smartturn.DeepgramEndpointing{EndpointingMs: 400, UtteranceEndMs: 2000}
+	// end returns how long after the last voiced chunk the turn ends.
+	end := func(prob float32) time.Duration {
+		var e *smartturn.Engine
+		var voiced, ended time.Time
+		e = newTestEngine(t, []float32{prob}, smartturn.Callbacks{
+			OnVadScore: func(p float32, _ int64) {
+				if p >= 0.5 {
+					voiced = e.MediaTime()
+				}
+			},
+			OnSpeechEnd: func() { ended = e.MediaTime() },
+		}, func(cfg *smartturn.Config) {
+			var err error
+			if *cfg, err = smartturn.ApplyEndpointing(*cfg, p); err != nil {
+				t.Fatal(err)
+			}
+		})
+		chunks := smartturntest.Chunks(audio)
+		if err := e.PushPCMAt(chunks[0], time.Unix(0, 0)); err != nil {
+			t.Fatal(err)
+		}
+		pushAll(t, e, audio[len(chunks[0]):])
+		if ended.IsZero() {
+			t.Fatalf("probability %v: the turn did not end", prob)
+		}
+		return ended.Sub(voiced)
+	}
+	const chunk = 32 * time.Millisecond
+	for _, tc := range []struct {
+		prob float32
+		want time.Duration
+	}{
+		{0.9, 400 * time.Millisecond},
+		{0.1, 2000 * time.Millisecond},
+	} {
+		if d := end(tc.prob); d < tc.want-chunk || d > tc.want+chunk {
+			t.Errorf("probability %v: turn ended %v after the speech, want %v", tc.prob, d, tc.want)
+		}
+	}
+}
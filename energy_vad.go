@@ -0,0 +1,89 @@
+package smartturn
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrVADDegraded is reported through OnError when Config.EnergyVADFallback
+// replaced a Silero VAD that could not be loaded.
+var ErrVADDegraded = errors.New("silero vad unavailable")
+
+// Defaults for AdaptiveEnergyVAD.
+const (
+	DefaultEnergyMarginDB   = 9.0
+	DefaultEnergyMinLevelDB = -55.0
+)
+
+// The noise floor is the quietest 32 ms chunk of the last 3s: speech nearly
+// always pauses within that, and a louder background is learned as fast.
+const (
+	energyFloorChunks = 3 * RequiredSampleRate / RequiredChunkSize
+	energyDBFloor     = -100
+)
+
+// AdaptiveEnergyVAD is a VADBackend that scores chunks by their energy
+// above an adaptive noise floor. It is much less accurate than Silero,
+// being fooled by any loud non-speech sound, and serves as a degraded mode
+// (Config.EnergyVADFallback) that keeps turns flowing when the model
+// cannot be loaded. It can also be set as Config.VADBackend.
+type AdaptiveEnergyVAD struct {
+	// MarginDB is the level above the noise floor scored 0.5, the usual
+	// VadThreshold (default 9).
+	MarginDB float64
+	// MinLevelDB is the chunk RMS level (dBFS) below which audio is never
+	// speech, so near-silent input is not mistaken for it (default -55).
+	MinLevelDB float64
+
+	levels [energyFloorChunks]float64 // ring of chunk levels (dB)
+	next   int
+	filled int
+}
+
+// NewAdaptiveEnergyVAD returns v with defaults applied to zero fields.
+func NewAdaptiveEnergyVAD(v AdaptiveEnergyVAD) (*AdaptiveEnergyVAD, error) {
+	if v.MarginDB < 0 || v.MinLevelDB > 0 {
+		return nil, errors.New("smart-turn: AdaptiveEnergyVAD: MarginDB must be >= 0 and MinLevelDB <= 0")
+	}
+	if v.MarginDB == 0 {
+		v.MarginDB = DefaultEnergyMarginDB
+	}
+	if v.MinLevelDB == 0 {
+		v.MinLevelDB = DefaultEnergyMinLevelDB
+	}
+	return &v, nil
+}
+
+// SpeechProb implements VADBackend, mapping the level above the noise
+// floor to a probability that crosses 0.5 at MarginDB.
+func (v *AdaptiveEnergyVAD) SpeechProb(chunk []float32) (float32, error) {
+	if len(chunk) != RequiredChunkSize && len(chunk) != TelephonyChunkSize {
+		return 0, errVADChunkSize
+	}
+	var sum float64
+	for _, s := range chunk {
+		sum += float64(s) * float64(s)
+	}
+	db := math.Max(10*math.Log10(sum/float64(len(chunk))), energyDBFloor)
+	v.levels[v.next] = db
+	v.next = (v.next + 1) % energyFloorChunks
+	v.filled = min(v.filled+1, energyFloorChunks)
+	floor := db
+	for _, l := range v.levels[:v.filled] {
+		floor = math.Min(floor, l)
+	}
+	snr := db - floor
+	if db < v.MinLevelDB {
+		return 0, nil
+	}
+	// 2 dB per unit of the logistic: 0.12 at margin-4 dB, 0.88 at margin+4.
+	return float32(1 / (1 + math.Exp(-(snr-v.MarginDB)/2))), nil
+}
+
+// Reset implements VADBackend; the noise floor is learned again.
+func (v *AdaptiveEnergyVAD) Reset() {
+	v.next, v.filled = 0, 0
+}
+
+// Close implements VADBackend.
+func (v *AdaptiveEnergyVAD) Close() error { return nil }
@@ -0,0 +1,143 @@
+package smartturn_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// scores returns v's speech probability for each chunk of audio.
+func scores(t *testing.T, v smartturn.VADBackend, audio []float32) []float32 {
+	t.Helper()
+	var out []float32
+	for _, c := range smartturntest.Chunks(audio) {
+		p, err := v.SpeechProb(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func TestAdaptiveEnergyVAD(t *testing.T) {
+	for _, noise := range []float32{0.001, 0.01} {
+		audio, spans := smartturntest.Synth{Seed: 9, Noise: noise}.Generate(
+			smartturntest.Silence(time.Second), smartturntest.Speech(2*time.Second),
+			smartturntest.Silence(time.Second), smartturntest.Speech(time.Second),
+			smartturntest.Silence(time.Second))
+		v, err := smartturn.NewAdaptiveEnergyVAD(smartturn.AdaptiveEnergyVAD{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := scores(t, v, audio)
+		for _, s := range spans {
+			// Chunks straddling a boundary may go either way.
+			for i := s.StartChunk() + 1; i < s.EndChunk()-1; i++ {
+				if s.Speech != (p[i] > 0.5) {
+					t.Errorf("noise %v: chunk %d scored %.2f, in a span with speech %v", noise, i, p[i], s.Speech)
+				}
+			}
+		}
+	}
+}
+
+// TestAdaptiveEnergyVADFloor checks that a steady background becomes the
+// floor within 3 s, that Reset forgets the floor, and that near-silent
+// input is never speech.
+func TestAdaptiveEnergyVADFloor(t *testing.T) {
+	v, _ := smartturn.NewAdaptiveEnergyVAD(smartturn.AdaptiveEnergyVAD{})
+	quiet, _ := smartturntest.Synth{Seed: 1, Noise: 0.001}.Generate(smartturntest.Silence(time.Second))
+	loud, _ := smartturntest.Synth{Seed: 2, Noise: 0.05}.Generate(smartturntest.Silence(4 * time.Second))
+	scores(t, v, quiet)
+	p := scores(t, v, loud)
+	if p[0] < 0.5 {
+		t.Errorf("a jump of the background to 0.05 RMS scored %.2f, want speech at first", p[0])
+	}
+	for i := 95; i < len(p); i++ {
+		if p[i] > 0.5 {
+			t.Fatalf("chunk %d of a steady background scored %.2f after 3 s", i, p[i])
+		}
+	}
+
+	scores(t, v, quiet)
+	v.Reset()
+	if p := scores(t, v, loud[:512]); p[0] > 0.5 {
+		t.Errorf("first chunk after Reset scored %.2f, want no floor learned yet", p[0])
+	}
+
+	// Well above the floor but below MinLevelDB.
+	v, _ = smartturn.NewAdaptiveEnergyVAD(smartturn.AdaptiveEnergyVAD{})
+	faint, _ := smartturntest.Synth{Seed: 3, Amplitude: 0.002}.Generate(smartturntest.Silence(time.Second), smartturntest.Speech(time.Second))
+	for i, s := range scores(t, v, faint) {
+		if s != 0 {
+			t.Fatalf("chunk %d of speech under -55 dBFS scored %.2f", i, s)
+		}
+	}
+}
+
+func TestNewAdaptiveEnergyVAD(t *testing.T) {
+	v, err := smartturn.NewAdaptiveEnergyVAD(smartturn.AdaptiveEnergyVAD{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.MarginDB != smartturn.DefaultEnergyMarginDB || v.MinLevelDB != smartturn.DefaultEnergyMinLevelDB {
+		t.Errorf("defaults %v, %v", v.MarginDB, v.MinLevelDB)
+	}
+	for _, bad := range []smartturn.AdaptiveEnergyVAD{{MarginDB: -1}, {MinLevelDB: 1}} {
+		if _, err := smartturn.NewAdaptiveEnergyVAD(bad); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+	for _, n := range []int{smartturn.RequiredChunkSize, smartturn.TelephonyChunkSize} {
+		if _, err := v.SpeechProb(make([]float32, n)); err != nil {
+			t.Errorf("%d-sample chunk: %v", n, err)
+		}
+	}
+	if _, err := v.SpeechProb(make([]float32, 100)); err == nil {
+		t.Error("100-sample chunk accepted")
+	}
+}
+
+// TestEnergyVADFallback checks that New degrades to the energy VAD when
+// the Silero model cannot be loaded, and only with EnergyVADFallback.
+func TestEnergyVADFallback(t *testing.T) {
+	if newTestEngine(t, nil, smartturn.Callbacks{}, nil).Health().VADDegraded {
+		t.Error("VADDegraded set with a working VAD")
+	}
+	cfg := benchConfig()
+	cfg.VadStopMs = 300
+	cfg.SileroVADModelPath = filepath.Join(t.TempDir(), "missing.onnx")
+	cfg.TurnBackend = &smartturntest.TurnScript{Probabilities: []float32{0.9}}
+	if _, err := smartturn.New(cfg, smartturn.Callbacks{}); err == nil {
+		t.Fatal("New without EnergyVADFallback accepted a missing Silero model")
+	}
+
+	cfg.EnergyVADFallback = true
+	var errs []error
+	var got []string
+	e, err := smartturn.New(cfg, smartturn.Callbacks{
+		OnError:       func(err error) { errs = append(errs, err) },
+		OnSpeechStart: func() { got = append(got, "start") },
+		OnSpeechEnd:   func() { got = append(got, "end") },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	if len(errs) != 1 || !errors.Is(errs[0], smartturn.ErrVADDegraded) || !e.Health().VADDegraded {
+		t.Fatalf("errors %v, Health().VADDegraded %v", errs, e.Health().VADDegraded)
+	}
+	e.Start()
+	audio, _ := smartturntest.Synth{Seed: 9, Noise: 0.002}.Generate(
+		smartturntest.Silence(time.Second), smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	pushAll(t, e, audio)
+	if s := strings.Join(got, " "); s != "start end" {
+		t.Errorf("callbacks %q on the energy VAD, want \"start end\"", s)
+	}
+}
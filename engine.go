@@ -2,41 +2,156 @@ package smartturn
 
 import (
 	"errors"
-	"os"
+	"fmt"
+	"log/slog"
 	"sync"
-
-	ort "github.com/yalue/onnxruntime_go"
+	"sync/atomic"
+	"time"
 )
 
-// segmentEmitPool reuses buffers for OnSegmentReady to avoid per-emit allocations.
-// Callbacks must copy the slice if they need to retain it (engine may reuse the buffer).
-var segmentEmitPool = sync.Pool{
-	New: func() interface{} { return make([]float32, 0, 32000) }, // 2s @ 16kHz
-}
-
 // EnvONNXRuntimeLib is the environment variable read before initializing ONNX.
 // If set, it is used as the ONNX Runtime shared library path (e.g. on macOS
 // after brew install onnxruntime, set to the path to libonnxruntime.dylib).
 const EnvONNXRuntimeLib = "ONNXRUNTIME_SHARED_LIBRARY_PATH"
 
 var (
-	ErrChunkSize = errors.New("chunk must be exactly 512 samples")
+	ErrChunkSize = errors.New("chunk must be exactly Config.ChunkSize samples")
+	ErrClosed    = errors.New("engine is closed")
+	// ErrFeatureSize is returned by PredictFeatures for input whose length
+	// is not the model's (TurnFeatureSize with default features).
+	ErrFeatureSize = errors.New("features do not match the Smart-Turn input size")
 )
 
+// Detector is the API of Engine, for applications that want to substitute
+// it in tests (see the smartturntest package) or wrap it.
+type Detector interface {
+	Start()
+	Stop()
+	Reset()
+	PushPCM(chunk []float32) error
+	Close()
+	Health() Health
+}
+
+var _ Detector = (*Engine)(nil)
+
 // Engine is the main SDK entry. It is single-threaded and not goroutine-safe;
-// the caller must serialize PushPCM and lifecycle methods.
+// the caller must serialize PushPCM, Start, Stop, and Reset (calling them
+// from callbacks is fine). Close and Health may be called from any goroutine.
+// With Config.InputQueue, every method may be called from any goroutine.
 type Engine struct {
 	cfg       Config
 	cb        Callbacks
-	vad       *sileroVAD
+	vad       VADBackend
 	segmenter *segmenter
 	smartTurn *smartTurn
+	dumper    *featureDumper // nil unless Config.DebugFeatureDump.Dir is set
+	recorder  *audioRecorder // nil unless Config.DebugAudioRecording.Dir is set
+	exporter  *turnExporter  // nil unless Config.TurnExport.Dir is set
+	turnLog   *turnLog       // nil unless Config.TurnLog is set
+	shadow    *smartTurn     // Config.ShadowSmartTurnModelPath or ShadowTurnBackend
+	health    healthStats
+	clean     [RequiredChunkSize]float32 // sanitized copy of an out-of-range chunk
+	queue     *inputQueue    // nil unless Config.InputQueue.Size > 0
+	load      loadMonitor
+	shedding  bool // overloaded with Config.Overload.Shed
+	poolReady chan struct{} // reused wake-up for Config.InferencePool
 
-	listening bool
-	closed    bool
+	// Process collects the events of the chunk in events.
+	collecting bool
+	events     []Event
+	log       *slog.Logger   // Config.Logger or a discarding logger
+	clock     Clock          // Config.Clock or the system clock
 
-	segmentEmitSamples  int // target samples per OnSegmentReady slice
+	// Close coordination (see close.go). callMu is held by each API call
+	// for its duration, callbacks included.
+	callMu        sync.Mutex
+	closing       atomic.Bool   // Close was called
+	deferredClose atomic.Bool   // Close returned early; the running call releases
+	depth         atomic.Int32  // callbacks executing
+	callbackGo    atomic.Uint64 // goroutine executing them, while depth > 0
+	stackBuf      [64]byte      // scratch for goroutineID in enter
+
+	listening   bool
+	closed      bool // resources released; guarded by callMu
+	dropped     int // chunks discarded while stopped, logged on the next Start
+	usesRuntime bool // holds a reference on the shared ONNX Runtime environment
+
+	segmentEmitSamples  int // target samples per OnSegmentReady/OnSegment slice
+	emitBuf             []float32 // reused for OnSegmentReady; callbacks must copy to retain
 	segmentEmittedSoFar int // how many samples of the current segment have been emitted
+	splitParts          int // parts of the current segment ended by SplitLongTurns
+
+	// smoothed is the EMA of this turn's Smart-Turn probabilities
+	// (Config.TurnSmoothing), valid when evals > 0.
+	smoothed float32
+	evals    int
+
+	// Transcriber and TurnExport state of the current turn: whether audio
+	// was pushed, and its span.
+	turnAudio          bool
+	turnStart, turnEnd int64
+
+	// turnID is the TurnStart.ID of the current (or last) turn, and
+	// nextTurnID that of the next one.
+	turnID     int
+	nextTurnID int
+
+	// Wake-word gate (Config.WakeWord): dormant until the word fires, then
+	// awaitingSpeech until a segment starts or wakeTimeoutChunks pass.
+	dormant           bool
+	awaitingSpeech    bool
+	wakeChunks        int
+	wakeTimeoutChunks int
+
+	// speaker is the Config.Speakers label of the current speech, -1 before
+	// one is known.
+	speaker int
+
+	// dtmfDet finds DTMF tones (Config.DetectDTMF); their chunks are
+	// replaced by silence.
+	dtmfDet dtmfDetector
+	silence [RequiredChunkSize]float32
+
+	// reload holds models loaded by ReloadModels until no turn is in
+	// progress; builtinSilero is set when the VAD is the Silero model.
+	reload        atomic.Pointer[reloadedModels]
+	builtinSilero bool
+	models        atomic.Pointer[modelPaths] // for ModelInfo
+
+	// mem backs MemoryStats; evicting is set once the current segment
+	// reached Config.MaxBufferedAudioMs.
+	mem      memoryStats
+	evicting bool
+
+	// asyncCalls feeds the dispatch goroutine of CallbackWatchdog.Async;
+	// release closes it. callbacksAsync is set once the callbacks moved
+	// there.
+	asyncCalls     chan func()
+	callbacksAsync bool
+
+	// up upsamples 8 kHz input into wide (Config.SampleRate 8000).
+	up   *upsampler
+	wide [RequiredChunkSize]float32
+
+	// samples counts the audio accepted since New, the sample offset of the
+	// next chunk.
+	samples int64
+
+	// chunkTime is the media time of the chunk being processed; mediaBase
+	// is the last caller timestamp, taken at sample offset mediaOffset.
+	chunkTime   time.Time
+	mediaBase   time.Time
+	mediaOffset int64
+
+	// gapSamples is lost audio (PushGap) shorter than a chunk, carried
+	// over to the next gap.
+	gapSamples int64
+
+	// sinceTurnEnd counts chunks since OnSpeechEnd, -1 when speech started
+	// since; speech resuming within mergeChunks continues the turn.
+	sinceTurnEnd int
+	mergeChunks  int
 
 	// When a segment ends but Smart-Turn fails (prob < TurnThreshold), we skip
 	// OnSpeechEnd and set turnPending. We do not fire OnSpeechStart for the
@@ -50,58 +165,219 @@ type Engine struct {
 // models, and creates sessions. The ONNX Runtime shared library path is taken from
 // Config.ONNXRuntimeLibPath if set, else from EnvONNXRuntimeLib. Caller is responsible
 // for resolving the lib path (e.g. via a utility or env).
+//
+// Any number of engines may exist in one process. They share a single ONNX
+// Runtime environment (shared library handle and default allocator), created by
+// the first New and destroyed by the last Close; all engines must therefore use
+// the same library path.
 func New(cfg Config, cb Callbacks) (*Engine, error) {
-	if err := validateConfig(cfg); err != nil {
+	if err := ValidateConfig(cfg); err != nil {
 		return nil, err
 	}
-	if path := cfg.ONNXRuntimeLibPath; path != "" {
-		ort.SetSharedLibraryPath(path)
-	} else if path := os.Getenv(EnvONNXRuntimeLib); path != "" {
-		ort.SetSharedLibraryPath(path)
+	e := &Engine{cfg: cfg, log: cfg.Logger, clock: cfg.Clock, load: newLoadMonitor(cfg.Overload), sinceTurnEnd: -1, speaker: -1}
+	if cfg.InferencePool != nil {
+		e.poolReady = make(chan struct{}, 1)
 	}
-	if err := ort.InitializeEnvironment(); err != nil {
-		return nil, err
+	if cfg.CallbackWatchdog.Budget > 0 {
+		cb = e.watchCallbacks(cb)
+	}
+	e.cb = e.guardCallbacks(cb)
+	if e.log == nil {
+		e.log = discardLogger
+	}
+	if e.clock == nil {
+		e.clock = systemClock{}
+	}
+	if cfg.DebugFeatureDump.Dir != "" {
+		d, err := newFeatureDumper(cfg.DebugFeatureDump, e.clock.Now())
+		if err != nil {
+			return nil, err
+		}
+		e.dumper = d
+	}
+	if cfg.DebugAudioRecording.Dir != "" {
+		rec, err := newAudioRecorder(cfg.DebugAudioRecording, e.log, e.clock.Now())
+		if err != nil {
+			return nil, err
+		}
+		e.recorder = rec
+	}
+	if cfg.TurnExport.Dir != "" {
+		x, err := newTurnExporter(cfg.TurnExport, e.clock.Now())
+		if err != nil {
+			return nil, err
+		}
+		e.exporter = x
+	}
+	if cfg.TurnLog != nil {
+		e.turnLog = newTurnLog()
+	}
+	// ONNX Runtime is only loaded when at least one built-in model is used.
+	// With EnergyVADFallback, a runtime or Silero failure only degrades VAD
+	// (the runtime is still required by a built-in Smart-Turn model, primary
+	// or shadow).
+	var vadErr error
+	useSilero := cfg.VADBackend == nil && cfg.VADEngine == VADSilero
+	ortShadow := cfg.ShadowTurnBackend == nil && cfg.ShadowSmartTurnModelPath != ""
+	turnRuntime := cfg.TurnBackend == nil || ortShadow
+	if useSilero || turnRuntime {
+		if err := acquireRuntime(runtimeLibPath(cfg)); err != nil {
+			if !cfg.EnergyVADFallback || turnRuntime {
+				return nil, err
+			}
+			vadErr = err
+		} else {
+			e.usesRuntime = true
+		}
+	}
+	vad := cfg.VADBackend
+	if vad == nil && cfg.VADEngine == VADWebRTC {
+		w, err := NewWebRTCVAD(cfg.WebRTCVADMode)
+		if err != nil {
+			e.releaseRuntime()
+			return nil, err
+		}
+		vad = w
+	}
+	if vad == nil && vadErr == nil {
+		silero, err := newSileroVAD(cfg.SileroVADModelPath, cfg.SampleRate, cfg.SileroWindowSamples, cfg.SileroSessionOptions, e.clock)
+		if err != nil && !cfg.EnergyVADFallback {
+			e.releaseRuntime()
+			return nil, err
+		}
+		if err != nil {
+			vadErr = err
+			if !turnRuntime {
+				e.releaseRuntime()
+			}
+		} else {
+			vad = silero
+			e.builtinSilero = true
+		}
+	}
+	if vad == nil {
+		vad, _ = NewAdaptiveEnergyVAD(AdaptiveEnergyVAD{})
+	}
+	var st *smartTurn
+	var err error
+	switch {
+	case cfg.TurnBackend != nil:
+		st, err = newCustomSmartTurn(cfg.TurnBackend, cfg.SmartTurnFeatures)
+	case cfg.LazySmartTurn:
+		st, err = newLazySmartTurn(cfg.SmartTurnModelPath, cfg.SmartTurnFeatures, cfg.SmartTurnSessionOptions, cfg.SmartTurnProvider)
+	default:
+		st, err = newSmartTurn(cfg.SmartTurnModelPath, cfg.SmartTurnFeatures, cfg.SmartTurnSessionOptions, cfg.SmartTurnProvider)
 	}
-	e := &Engine{cfg: cfg, cb: cb}
-	vad, err := newSileroVAD(cfg.SileroVADModelPath)
 	if err != nil {
+		if cfg.VADBackend == nil {
+			_ = vad.Close()
+		}
+		e.releaseRuntime()
 		return nil, err
 	}
-	st, err := newSmartTurn(cfg.SmartTurnModelPath)
-	if err != nil {
-		_ = vad.destroy()
+	st.setFeatureWorkers(cfg.FeatureWorkers)
+	st.calib = cfg.TurnCalibration
+	if e.shadow, err = newShadowTurn(cfg); err != nil {
+		_ = st.destroy()
+		if cfg.VADBackend == nil {
+			_ = vad.Close()
+		}
+		e.releaseRuntime()
 		return nil, err
 	}
-	seg := newSegmenter(cfg.SampleRate, cfg.ChunkSize, cfg.VadPreSpeechMs, cfg.VadStopMs, cfg.TurnMaxDurationSeconds)
+	if st.fallbackErr != nil {
+		e.reportError("smart-turn execution provider unavailable", st.fallbackErr)
+	}
+	if vadErr != nil {
+		e.health.degradeVAD()
+		e.reportError("silero vad unavailable; using energy vad", fmt.Errorf("%w: %v", ErrVADDegraded, vadErr))
+	}
+	// The pipeline after VAD runs at 16 kHz; 8 kHz input is upsampled.
+	if cfg.SampleRate == TelephonySampleRate {
+		e.up = &upsampler{}
+	}
+	seg := newSegmenter(RequiredSampleRate, RequiredChunkSize, cfg.VadPreSpeechMs, cfg.VadStopMs, cfg.TurnMaxDurationSeconds)
+	if cfg.SplitLongTurns {
+		seg.setSplit(cfg.TurnSplitOverlapMs, RequiredSampleRate)
+	}
+	if cfg.MaxBufferedAudioMs > 0 {
+		seg.setBudget(cfg.MaxBufferedAudioMs, RequiredSampleRate)
+	}
+	if len(cfg.VADEnsemble) > 0 {
+		vad = newVADEnsemble(vad, cfg.VADEnsemble, cfg.VADVote)
+	}
 	e.vad = vad
 	e.segmenter = seg
 	e.smartTurn = st
+	e.setModelPaths()
 	// Derive how many samples correspond to one emit interval.
 	if cfg.TurnSegmentEmitMs > 0 {
-		e.segmentEmitSamples = int(float64(cfg.TurnSegmentEmitMs) * float64(cfg.SampleRate) / 1000.0)
+		e.segmentEmitSamples = int(float64(cfg.TurnSegmentEmitMs) * RequiredSampleRate / 1000.0)
 		if e.segmentEmitSamples <= 0 {
-			e.segmentEmitSamples = cfg.ChunkSize
+			e.segmentEmitSamples = RequiredChunkSize
 		}
 	} else {
-		e.segmentEmitSamples = cfg.ChunkSize
+		e.segmentEmitSamples = RequiredChunkSize
 	}
 	// 512 samples @ 16 kHz = 32 ms per chunk
 	chunkMs := 32
+	e.mergeChunks = ceilDiv(cfg.TurnMergeGapMs, chunkMs)
+	e.wakeTimeoutChunks = ceilDiv(cfg.WakeWordTimeoutMs, chunkMs)
+	e.dormant = cfg.WakeWord != nil
+	if cfg.DetectDTMF {
+		e.dtmfDet = newDTMFDetector()
+	}
 	if cfg.TurnTimeoutMs > 0 {
 		e.turnTimeoutChunks = (cfg.TurnTimeoutMs + chunkMs - 1) / chunkMs
 		if e.turnTimeoutChunks <= 0 {
 			e.turnTimeoutChunks = 1
 		}
 	}
+	e.log.Info("smart-turn engine ready",
+		"turn_input", st.kind(),
+		"turn_window_s", float64(st.model.windowSamples)/RequiredSampleRate,
+		"custom_vad", cfg.VADBackend != nil,
+		"energy_vad", vadErr != nil,
+		"webrtc_vad", cfg.VADBackend == nil && !useSilero,
+		"vad_ensemble", len(cfg.VADEnsemble),
+		"custom_turn", cfg.TurnBackend != nil,
+		"shadow_turn", e.shadow != nil,
+		"lazy_turn", cfg.LazySmartTurn && cfg.TurnBackend == nil,
+		"onnxruntime", e.usesRuntime)
+	e.health.setState(true, false)
+	e.trackMemory()
+	if e.asyncCalls != nil {
+		go e.dispatchCallbacks(e.asyncCalls)
+	}
+	if cfg.InputQueue.Size > 0 {
+		e.queue = newInputQueue(e, cfg.InputQueue)
+		go e.queue.run()
+	}
 	return e, nil
 }
 
 // Start starts listening. Invokes OnListeningStarted callback.
 func (e *Engine) Start() {
-	if e.closed {
+	if e.queue != nil {
+		e.queue.control(opStart)
 		return
 	}
+	if e.acquire() {
+		defer e.finish()
+	}
+	if !e.closing.Load() {
+		e.start()
+	}
+}
+
+func (e *Engine) start() {
 	e.listening = true
+	if e.dropped > 0 {
+		e.log.Debug("discarded audio pushed while stopped", "chunks", e.dropped)
+		e.dropped = 0
+	}
+	e.health.setState(true, true)
+	e.log.Info("listening started")
 	if e.cb.OnListeningStarted != nil {
 		e.cb.OnListeningStarted()
 	}
@@ -109,36 +385,115 @@ func (e *Engine) Start() {
 
 // Stop stops listening. Invokes OnListeningStopped callback.
 func (e *Engine) Stop() {
-	if e.closed {
+	if e.queue != nil {
+		e.queue.control(opStop)
 		return
 	}
+	if e.acquire() {
+		defer e.finish()
+	}
+	if !e.closing.Load() {
+		e.stop()
+	}
+}
+
+func (e *Engine) stop() {
 	e.listening = false
+	e.health.setState(true, false)
+	e.log.Info("listening stopped")
 	if e.cb.OnListeningStopped != nil {
 		e.cb.OnListeningStopped()
 	}
 }
 
-// PushPCM processes one chunk of 512 float32 samples (mono, 16 kHz).
-// Returns ErrChunkSize if len(chunk) != 512. Callbacks are invoked synchronously.
+// PushPCM processes one chunk of Config.ChunkSize float32 samples (mono):
+// 512 at 16 kHz, 256 at 8 kHz. Returns ErrChunkSize for any other length.
+// Callbacks are invoked synchronously.
+//
+// With Config.InputQueue, PushPCM copies the chunk into the queue and
+// returns; inference errors then only reach OnError.
 func (e *Engine) PushPCM(chunk []float32) error {
-	if e.closed {
-		return errors.New("engine is closed")
+	return e.PushPCMAt(chunk, time.Time{})
+}
+
+// PushPCMAt is PushPCM for a chunk whose first sample the source stamped
+// with media time ts (RTP, NTP); see MediaTime.
+func (e *Engine) PushPCMAt(chunk []float32, ts time.Time) error {
+	if e.queue != nil {
+		return e.queue.push(chunk, ts)
+	}
+	if e.acquire() {
+		defer e.finish()
+	}
+	if e.closing.Load() {
+		e.dropClosed(chunk)
+		return ErrClosed
 	}
-	if len(chunk) != RequiredChunkSize {
+	return e.pushPCM(chunk, ts)
+}
+
+// dropClosed accounts for a chunk pushed after Close.
+func (e *Engine) dropClosed(chunk []float32) {
+	e.health.dropped()
+	e.log.Warn("audio dropped: engine is closed", "samples", len(chunk))
+}
+
+func (e *Engine) pushPCM(chunk []float32, ts time.Time) error {
+	if len(chunk) != e.cfg.ChunkSize {
+		e.health.dropped()
+		e.log.Warn("audio dropped: wrong chunk size", "samples", len(chunk), "want", e.cfg.ChunkSize)
 		return ErrChunkSize
 	}
 	if !e.listening {
+		e.dropped++
+		e.health.dropped()
 		return nil
 	}
+	start := e.clock.Now()
+	e.stamp(ts, start)
+	err := e.process(chunk, false)
+	e.trackLoad(e.clock.Now().Sub(start))
+	return err
+}
 
-	prob, err := e.vad.speechProb(chunk)
-	if err != nil {
-		if e.cb.OnError != nil {
-			e.cb.OnError(err)
+// process runs VAD, segmentation, and Smart-Turn on one accepted chunk.
+// A gap chunk (PushGap) stands in for lost audio: it is non-speech, and
+// VAD and the DTMF, non-speech and speaker stages do not see it.
+func (e *Engine) process(chunk []float32, gap bool) error {
+	defer e.trackMemory()
+	if e.reload.Load() != nil && !e.segmenter.speechActive && !e.turnPending {
+		e.installModels()
+	}
+	offset := e.samples
+	e.samples += RequiredChunkSize
+	chunk = e.sanitize(chunk)
+	// VAD scores the chunk at its native rate; everything else at 16 kHz.
+	vadChunk := chunk
+	if e.up != nil {
+		chunk = e.up.process(chunk, e.wide[:])
+	}
+	if e.recorder != nil {
+		if err := e.recorder.writeChunk(chunk); err != nil {
+			e.reportError("debug audio recording failed", err)
+		}
+	}
+	if e.cfg.DetectDTMF && !gap {
+		chunk = e.dtmf(chunk)
+		if &chunk[0] == &e.silence[0] {
+			vadChunk = e.silence[:len(vadChunk)]
+		}
+	}
+	if e.cfg.WakeWord != nil && e.wake(chunk) {
+		return nil
+	}
+
+	isSpeech, speaker := false, e.speaker
+	if !gap {
+		var err error
+		if isSpeech, speaker, err = e.classify(chunk, vadChunk, offset); err != nil {
+			return err
 		}
-		return err
 	}
-	isSpeech := prob > e.cfg.VadThreshold
 
 	// If we're in a pending turn (skipped OnSpeechEnd), count silence and maybe timeout.
 	if e.turnPending {
@@ -147,122 +502,428 @@ func (e *Engine) PushPCM(chunk []float32) error {
 		} else {
 			e.turnPendingSilenceChunks++
 			if e.turnPendingSilenceChunks >= e.turnTimeoutChunks {
-				e.turnPending = false
-				e.turnPendingSilenceChunks = 0
-				if e.cb.OnSpeechEnd != nil {
-					e.cb.OnSpeechEnd()
+				if e.logs(slog.LevelInfo) {
+					e.log.Info("turn timed out; ending speech", "timeout_ms", e.cfg.TurnTimeoutMs)
 				}
+				e.endTurn(TurnEndTimeout)
 			}
 		}
 	}
 
 	res := e.segmenter.processChunk(isSpeech, chunk)
+	if speaker != e.speaker {
+		e.changeSpeaker(speaker, offset, &res)
+	}
+	if e.sinceTurnEnd >= 0 {
+		e.sinceTurnEnd++
+	}
 	// Reset emitted counter on a new segment.
 	if res.Started {
 		e.segmentEmittedSoFar = 0
+		e.splitParts = 0
+		e.evicting = false
+		if err := e.loadSmartTurn(); err != nil {
+			e.reportError("loading smart-turn model", err)
+		}
+		if e.smartTurn != nil {
+			e.smartTurn.resetSegment()
+		}
+		if e.shadow != nil {
+			e.shadow.resetSegment()
+		}
+		if e.cfg.Observer != nil {
+			e.cfg.Observer.SegmentStarted()
+		}
+		e.log.Debug("speech segment started", "turn_pending", e.turnPending)
 	}
 	// Do not fire OnSpeechStart again if we're still in a turn that didn't complete.
-	if res.Started && !e.turnPending && e.cb.OnSpeechStart != nil {
-		e.cb.OnSpeechStart()
+	if res.Started && !e.turnPending {
+		merged := e.sinceTurnEnd >= 0 && e.sinceTurnEnd <= e.mergeChunks
+		if merged {
+			e.mergeTurn()
+		} else {
+			e.startTurn(len(res.Segment))
+			e.record(Event{Kind: EventSpeechStart})
+			if e.cb.OnSpeechStart != nil {
+				e.cb.OnSpeechStart()
+			}
+		}
+		if e.turnLog != nil {
+			e.turnLog.start(e.turnID, e.mediaTimeAt(e.samples-int64(len(res.Segment))), merged)
+		}
+	}
+	if res.Started {
+		e.sinceTurnEnd = -1
+		e.awaitingSpeech = false
 	}
 	if e.cb.OnChunk != nil {
 		e.cb.OnChunk(chunk)
 	}
 
-	// While speech is active, res.Segment holds the full accumulated segment so far.
-	if len(res.Segment) > 0 && e.segmentEmitSamples > 0 && e.cb.OnSegmentReady != nil {
+	// While speech is active, res.Segment holds the accumulated segment so
+	// far, or its last MaxBufferedAudioMs.
+	emitsSegments := e.cb.OnSegmentReady != nil || e.cb.OnSegment != nil || e.collecting || e.cfg.Transcriber != nil || e.exporter != nil
+	segStart := e.samples - int64(len(res.Segment))
+	if res.Evicted > 0 {
+		e.evicted(res.Evicted, segStart)
+	}
+	if len(res.Segment) > 0 && e.segmentEmitSamples > 0 && emitsSegments {
 		total := len(res.Segment)
+		emit := e.segmentEmitSamples
+		if e.shedding {
+			emit *= 2
+		}
 		// Emit fixed-size slices as we cross each interval boundary.
-		for total-e.segmentEmittedSoFar >= e.segmentEmitSamples {
-			start := e.segmentEmittedSoFar
-			end := start + e.segmentEmitSamples
-			n := end - start
-			slice := segmentEmitPool.Get().([]float32)
-			if cap(slice) < n {
-				slice = make([]float32, n)
-			} else {
-				slice = slice[:n]
-			}
-			copy(slice, res.Segment[start:end])
-			e.cb.OnSegmentReady(slice)
-			segmentEmitPool.Put(slice)
+		for total-e.segmentEmittedSoFar >= emit {
+			end := e.segmentEmittedSoFar + emit
+			e.emitSegment(res.Segment[e.segmentEmittedSoFar:end], segStart+int64(e.segmentEmittedSoFar))
 			e.segmentEmittedSoFar = end
 		}
 	}
 
 	if res.Ended {
 		shouldEndSpeech := true
+		var primary TurnPrediction // for the shadow model, when shadowDue
+		shadowDue := false
 
 		// Emit any remaining tail for this segment before Smart-Turn or speech end callback.
-		if len(res.Segment) > e.segmentEmittedSoFar && e.cb.OnSegmentReady != nil {
-			start := e.segmentEmittedSoFar
-			end := len(res.Segment)
-			n := end - start
-			slice := segmentEmitPool.Get().([]float32)
-			if cap(slice) < n {
-				slice = make([]float32, n)
-			} else {
-				slice = slice[:n]
-			}
-			copy(slice, res.Segment[start:end])
-			e.cb.OnSegmentReady(slice)
-			segmentEmitPool.Put(slice)
+		if len(res.Segment) > e.segmentEmittedSoFar && emitsSegments {
+			e.emitSegment(res.Segment[e.segmentEmittedSoFar:], segStart+int64(e.segmentEmittedSoFar))
 		}
 
 		// Best-effort Smart-Turn inference on the full segment. If the model
 		// fails or reports a low probability, we skip OnSpeechEnd so the host
 		// can treat this as an incomplete turn.
-		if res.EndedBySilence && e.smartTurn != nil {
-			if r, err := e.smartTurn.run(res.Segment); err != nil {
-				if e.cb.OnError != nil {
-					e.cb.OnError(err)
+		if e.cfg.Observer != nil {
+			e.cfg.Observer.SegmentEnded(len(res.Segment), res.EndedBySilence)
+		}
+		if e.turnLog != nil {
+			e.turnLog.segment(len(res.Segment))
+		}
+		if e.logs(slog.LevelDebug) {
+			e.log.Debug("speech segment ended",
+				"duration_s", float64(len(res.Segment))/RequiredSampleRate,
+				"by_silence", res.EndedBySilence)
+		}
+		if res.EndedBySilence && e.smartTurn != nil && e.shedding {
+			// Overloaded: leave the turn pending for TurnTimeoutMs to end.
+			shouldEndSpeech = false
+			e.log.Debug("smart-turn skipped: engine overloaded")
+		} else if res.EndedBySilence && e.smartTurn != nil {
+			var queueWait time.Duration
+			if pool := e.cfg.InferencePool; pool != nil {
+				waitStart := e.clock.Now()
+				pool.acquire(PriorityEndOfSpeech, e.poolReady)
+				queueWait = e.clock.Now().Sub(waitStart)
+			}
+			turnStart := e.clock.Now()
+			r, err := e.smartTurn.run(res.Segment)
+			turnDuration := e.clock.Now().Sub(turnStart)
+			if e.cfg.InferencePool != nil {
+				e.cfg.InferencePool.release()
+			}
+			e.health.turnInference(turnStart, turnDuration, err)
+			if err == nil && e.dumper != nil {
+				if derr := e.dumper.dump(res.Segment, e.smartTurn, r.Probability); derr != nil {
+					e.reportError("feature dump failed", derr)
 				}
+			}
+			if e.recorder != nil {
+				if rerr := e.recorder.writeTurn(e.smartTurn.window(res.Segment), r.Probability, err); rerr != nil {
+					e.reportError("debug audio recording failed", rerr)
+				}
+			}
+			instant := r.Probability
+			if err == nil && e.cfg.TurnSmoothing > 0 {
+				r.Probability = e.smooth(r.Probability)
+				r.Complete = r.Probability > 0.5
+			}
+			if err != nil {
+				e.reportError("smart-turn inference failed", err)
 				shouldEndSpeech = false
-			} else if e.cb.OnTurnPrediction != nil {
-				e.cb.OnTurnPrediction(r.Complete, r.Probability)
+			} else {
+				p := TurnPrediction{
+					Complete:              r.Complete,
+					Probability:           r.Probability,
+					Instant:               instant,
+					Logit:                 r.Logit,
+					AuxOutputs:            r.Aux,
+					InferenceDuration:     turnDuration,
+					QueueWait:             queueWait,
+					SilenceBeforeDecision: time.Duration(res.TrailingChunks*RequiredChunkSize) * time.Second / RequiredSampleRate,
+				}
+				if sc := e.cfg.SemanticCheck; sc.Check != nil && p.Probability >= sc.Low && p.Probability < sc.High {
+					p.Verdict = e.semanticCheck(p)
+				}
+				e.record(Event{Kind: EventTurnPrediction, Prediction: p})
+				if e.cb.OnTurnPrediction != nil {
+					e.cb.OnTurnPrediction(p.Complete, p.Probability)
+				}
+				if e.cb.OnTurnPredictionDetail != nil {
+					e.cb.OnTurnPredictionDetail(p)
+				}
 				if r.Probability < e.cfg.TurnThreshold {
 					shouldEndSpeech = false
 				}
+				switch p.Verdict {
+				case VerdictComplete:
+					shouldEndSpeech = true
+				case VerdictIncomplete:
+					shouldEndSpeech = false
+				}
+				primary, shadowDue = p, e.shadow != nil
+			}
+			if e.cfg.Observer != nil {
+				e.cfg.Observer.TurnInference(turnDuration, r.Probability, shouldEndSpeech, err)
+			}
+			if e.turnLog != nil {
+				e.turnLog.inference(turnDuration, r.Probability, err)
+			}
+			if err == nil && e.logs(slog.LevelDebug) {
+				e.log.Debug("smart-turn inference",
+					"duration", turnDuration,
+					"queue_wait", queueWait,
+					"probability", r.Probability,
+					"end_of_turn", shouldEndSpeech)
 			}
 		}
 
-		if shouldEndSpeech {
-			e.turnPending = false
-			e.turnPendingSilenceChunks = 0
-			if e.cb.OnSpeechEnd != nil {
-				e.cb.OnSpeechEnd()
+		if res.Split {
+			e.splitTurn(len(res.Segment), res.Overlap)
+		} else if shouldEndSpeech {
+			reason := TurnEndModel
+			if !res.EndedBySilence {
+				reason = TurnEndMaxDuration
+			}
+			if e.logs(slog.LevelInfo) {
+				e.log.Info("end of turn", "reason", reason)
 			}
+			e.endTurn(reason)
 		} else {
+			if !e.turnPending && e.logs(slog.LevelInfo) {
+				e.log.Info("turn incomplete; waiting for more speech", "timeout_ms", e.cfg.TurnTimeoutMs)
+			}
 			e.turnPending = true
 			e.turnPendingSilenceChunks = 0
 		}
 		e.segmentEmittedSoFar = 0
+		if shadowDue {
+			e.runShadow(res.Segment, primary)
+		}
 	}
 	return nil
 }
 
+// classify runs VAD on vadChunk (the chunk at the input rate) and the
+// non-speech and speaker stages on chunk, returning whether it is speech
+// and who is speaking.
+func (e *Engine) classify(chunk, vadChunk []float32, offset int64) (isSpeech bool, speaker int, err error) {
+	vadStart := e.clock.Now()
+	prob, err := e.vad.SpeechProb(vadChunk)
+	vadDuration := e.clock.Now().Sub(vadStart)
+	e.health.vadInference(vadStart, vadDuration, err)
+	if e.cfg.Observer != nil {
+		e.cfg.Observer.VADInference(vadDuration, prob, err)
+	}
+	if err != nil {
+		e.reportError("vad inference failed", err)
+		return false, e.speaker, err
+	}
+	if e.cb.OnVadScore != nil {
+		e.cb.OnVadScore(prob, offset)
+	}
+	isSpeech = prob > e.cfg.VadThreshold
+	if e.cfg.NonSpeech != nil {
+		nonSpeech, err := e.cfg.NonSpeech.Classify(chunk)
+		if err != nil {
+			e.reportError("non-speech classification failed", err)
+		} else if nonSpeech && isSpeech {
+			isSpeech = false
+			if e.logs(slog.LevelDebug) {
+				e.log.Debug("vad trigger rejected as non-speech", "prob", prob)
+			}
+		}
+	}
+	speaker = e.speaker
+	if isSpeech && e.cfg.Speakers != nil {
+		s, err := e.cfg.Speakers.Speaker(chunk)
+		if err != nil {
+			e.reportError("speaker tracking failed", err)
+		} else if s >= 0 {
+			speaker = s
+		}
+	}
+	return isSpeech, speaker, nil
+}
+
+// smooth folds one Smart-Turn probability into the turn's EMA and returns
+// it; the first evaluation of a turn is taken as is.
+func (e *Engine) smooth(p float32) float32 {
+	if e.evals > 0 {
+		a := e.cfg.TurnSmoothing
+		p = a*e.smoothed + (1-a)*p
+	}
+	e.smoothed = p
+	e.evals++
+	return p
+}
+
+// endTurn fires OnTurnEnd and OnSpeechEnd and clears the pending turn.
+func (e *Engine) endTurn(reason TurnEndReason) {
+	e.turnPending = false
+	e.turnPendingSilenceChunks = 0
+	e.sinceTurnEnd = 0
+	e.evals = 0
+	e.record(Event{Kind: EventSpeechEnd, EndReason: reason})
+	if e.cb.OnTurnEnd != nil {
+		e.cb.OnTurnEnd(reason)
+	}
+	if e.cb.OnSpeechEnd != nil {
+		e.cb.OnSpeechEnd()
+	}
+	e.finishTurnAudio(reason, true)
+	if e.turnLog != nil {
+		e.writeTurnLog(reason)
+	}
+	e.rearm()
+}
+
+// startTurn numbers a new turn whose audio (segment samples so far,
+// pre-speech padding included) ends with the current chunk, and reports it.
+func (e *Engine) startTurn(segment int) {
+	e.turnID = e.nextTurnID
+	e.nextTurnID++
+	offset := e.samples - int64(segment)
+	t := TurnStart{
+		ID:      e.turnID,
+		Offset:  offset,
+		Time:    e.mediaTimeAt(offset),
+		Padding: time.Duration(segment-RequiredChunkSize) * time.Second / RequiredSampleRate,
+	}
+	e.record(Event{Kind: EventTurnStart, TurnStart: t})
+	if e.cb.OnTurnStart != nil {
+		e.cb.OnTurnStart(t)
+	}
+}
+
+// mergeTurn reports that speech resumed within TurnMergeGapMs of the last
+// OnSpeechEnd, so the new segment continues that turn.
+func (e *Engine) mergeTurn() {
+	m := TurnMerge{Gap: time.Duration(e.sinceTurnEnd) * chunkDuration}
+	if e.logs(slog.LevelInfo) {
+		e.log.Info("speech resumed right after end of turn; merging", "gap", m.Gap)
+	}
+	e.record(Event{Kind: EventTurnMerged, Merge: m})
+	if e.cb.OnTurnMerged != nil {
+		e.cb.OnTurnMerged(m)
+	}
+}
+
+// splitTurn reports that a segment hit TurnMaxDurationSeconds and goes on
+// in a new part whose first overlap samples repeat its end.
+func (e *Engine) splitTurn(samples, overlap int) {
+	e.splitParts++
+	if e.smartTurn != nil {
+		e.smartTurn.resetSegment()
+	}
+	if e.shadow != nil {
+		e.shadow.resetSegment()
+	}
+	if e.cfg.Observer != nil {
+		e.cfg.Observer.SegmentStarted()
+	}
+	split := TurnSplit{Part: e.splitParts, Samples: samples, OverlapSamples: overlap}
+	if e.logs(slog.LevelInfo) {
+		e.log.Info("turn split at max duration", "part", split.Part, "overlap_samples", overlap)
+	}
+	e.record(Event{Kind: EventTurnSplit, Split: split})
+	if e.cb.OnTurnSplit != nil {
+		e.cb.OnTurnSplit(split)
+	}
+}
+
+// emitSegment passes a copy of part of the segment to OnSegmentReady and
+// OnSegment, so a callback cannot alter the audio Smart-Turn will see.
+func (e *Engine) emitSegment(part []float32, offset int64) {
+	e.record(Event{Kind: EventSegmentReady, Segment: part[:len(part):len(part)]})
+	if e.cb.OnSegmentReady != nil {
+		if cap(e.emitBuf) < len(part) {
+			e.emitBuf = make([]float32, len(part))
+		}
+		buf := e.emitBuf[:len(part)]
+		copy(buf, part)
+		e.cb.OnSegmentReady(buf)
+	}
+	if e.cb.OnSegment != nil {
+		e.cb.OnSegment(newSegment(part))
+	}
+	if e.cfg.Transcriber != nil || e.exporter != nil {
+		e.pushTurnAudio(part, offset)
+	}
+}
+
+// InSpeech reports whether the engine is inside a speech segment after the
+// last chunk: from the chunk that started it until VadStopMs of silence
+// ended it. A turn may stay open after its segment ends, while Smart-Turn
+// waits for more speech. Call it from callbacks or between calls, like
+// PushPCM.
+func (e *Engine) InSpeech() bool {
+	return e.segmenter.speechActive
+}
+
 // Reset clears VAD state, segment state, and turn-pending state. Sessions are not closed.
 func (e *Engine) Reset() {
-	if e.closed {
+	if e.queue != nil {
+		e.queue.control(opReset)
 		return
 	}
-	e.vad.resetState()
+	if e.acquire() {
+		defer e.finish()
+	}
+	if !e.closing.Load() {
+		e.reset()
+	}
+}
+
+func (e *Engine) reset() {
+	if e.reload.Load() != nil {
+		e.installModels()
+	}
+	e.vad.Reset()
+	if e.up != nil {
+		e.up.reset()
+	}
 	e.segmenter.reset()
+	e.trackMemory()
+	if e.smartTurn != nil {
+		e.smartTurn.resetSegment()
+	}
+	if e.shadow != nil {
+		e.shadow.resetSegment()
+	}
 	e.turnPending = false
 	e.turnPendingSilenceChunks = 0
-}
-
-// Close releases ONNX sessions and resources. The engine must not be used after Close.
-func (e *Engine) Close() {
-	if e.closed {
-		return
+	e.sinceTurnEnd = -1
+	e.evals = 0
+	e.finishTurnAudio(TurnEndModel, false)
+	if e.turnLog != nil {
+		e.turnLog.active = false
 	}
-	e.closed = true
-	e.listening = false
-	if err := e.vad.destroy(); err != nil && e.cb.OnError != nil {
-		e.cb.OnError(err)
+	e.rearm()
+	if e.cfg.NonSpeech != nil {
+		e.cfg.NonSpeech.Reset()
+	}
+	if e.cfg.Speakers != nil {
+		e.cfg.Speakers.Reset()
 	}
-	if err := e.smartTurn.destroy(); err != nil && e.cb.OnError != nil {
-		e.cb.OnError(err)
+	e.speaker = -1
+	e.log.Debug("engine reset")
+}
+
+// releaseRuntime drops the engine's runtime reference on a failed New.
+func (e *Engine) releaseRuntime() {
+	if e.usesRuntime {
+		e.usesRuntime = false
+		_ = releaseRuntime()
 	}
 }
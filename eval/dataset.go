@@ -0,0 +1,114 @@
+package eval
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/internal/wav"
+)
+
+// LabelsFile is the name of the label file in a dataset directory.
+const LabelsFile = "labels.csv"
+
+// Clip is one labeled recording.
+type Clip struct {
+	Name  string
+	Audio []float32 // 16 kHz mono
+	// TurnEnds are the times (seconds from the start) at which the speaker
+	// finished a turn, ascending.
+	TurnEnds []float64
+}
+
+// Dataset is a set of labeled clips.
+type Dataset struct {
+	Clips []Clip
+}
+
+// Turns returns the number of labeled turn ends.
+func (d *Dataset) Turns() int {
+	n := 0
+	for _, c := range d.Clips {
+		n += len(c.TurnEnds)
+	}
+	return n
+}
+
+// Load reads a dataset directory: 16 kHz WAV files (PCM or float, mono or
+// stereo) and a labels.csv with rows "file,turn_end_seconds", one row per
+// turn end. A header row and clips without turns (rows with an empty time)
+// are allowed.
+func Load(dir string) (*Dataset, error) {
+	f, err := os.Open(filepath.Join(dir, LabelsFile))
+	if err != nil {
+		return nil, fmt.Errorf("eval: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	r.Comment = '#'
+
+	ends := make(map[string][]float64)
+	var order []string
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("eval: %s: %w", LabelsFile, err)
+		}
+		if _, seen := ends[rec[0]]; !seen {
+			if line == 1 && rec[0] == "file" {
+				continue
+			}
+			order = append(order, rec[0])
+			ends[rec[0]] = nil
+		}
+		if rec[1] == "" {
+			continue
+		}
+		t, err := strconv.ParseFloat(rec[1], 64)
+		if err != nil || t < 0 {
+			return nil, fmt.Errorf("eval: %s line %d: invalid time %q", LabelsFile, line, rec[1])
+		}
+		ends[rec[0]] = append(ends[rec[0]], t)
+	}
+	if len(order) == 0 {
+		return nil, errors.New("eval: " + LabelsFile + " lists no clips")
+	}
+
+	ds := &Dataset{}
+	for _, name := range order {
+		audio, err := readWAV(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("eval: %s: %w", name, err)
+		}
+		te := ends[name]
+		slices.Sort(te)
+		ds.Clips = append(ds.Clips, Clip{Name: name, Audio: audio, TurnEnds: te})
+	}
+	return ds, nil
+}
+
+func readWAV(path string) ([]float32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	audio, rate, err := wav.Read(f)
+	if err != nil {
+		return nil, err
+	}
+	if rate != smartturn.RequiredSampleRate {
+		return nil, fmt.Errorf("sample rate %d, want %d", rate, smartturn.RequiredSampleRate)
+	}
+	return audio, nil
+}
@@ -0,0 +1,174 @@
+// Package eval measures end-of-turn detection on a labeled dataset and
+// sweeps VadThreshold, TurnThreshold, and VadStopMs for the configuration
+// with the best accuracy within a latency budget.
+//
+// A dataset is a directory of 16 kHz WAV clips and a labels.csv listing the
+// time each turn ends (see Load). Every OnSpeechEnd is matched to the next
+// labeled turn end: it is a detection when it fires between EarlyTolerance
+// before and MaxLatency after it (latency is the difference, in audio
+// time), and an early cut-off when it fires sooner. Labeled ends without a
+// detection are misses.
+package eval
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+)
+
+// Options controls scoring. Zero fields take the defaults.
+type Options struct {
+	// MaxLatency is the longest delay after a labeled turn end that still
+	// counts as detecting it; default 2s.
+	MaxLatency time.Duration
+	// EarlyTolerance accepts OnSpeechEnd slightly before a labeled end, for
+	// label jitter; default 200ms.
+	EarlyTolerance time.Duration
+	// TrailingSilence is appended to every clip so decisions near its end
+	// can fire; default MaxLatency + 500ms.
+	TrailingSilence time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxLatency == 0 {
+		o.MaxLatency = 2 * time.Second
+	}
+	if o.EarlyTolerance == 0 {
+		o.EarlyTolerance = 200 * time.Millisecond
+	}
+	if o.TrailingSilence == 0 {
+		o.TrailingSilence = o.MaxLatency + 500*time.Millisecond
+	}
+	return o
+}
+
+// Result is the score of one configuration on a dataset.
+type Result struct {
+	VadThreshold  float32
+	TurnThreshold float32
+	VadStopMs     int
+
+	Turns    int // labeled turn ends
+	Detected int // turn ends detected within MaxLatency
+	Early    int // OnSpeechEnd before the speaker finished (cut-offs)
+	Missed   int // turn ends not detected in time
+
+	Precision float64 // Detected / (Detected + Early)
+	Recall    float64 // Detected / Turns
+	F1        float64
+
+	MeanLatency time.Duration // over detections
+	P50Latency  time.Duration
+	P90Latency  time.Duration
+}
+
+// Evaluate runs one engine built from cfg over every clip of ds (resetting
+// it between clips) and scores its OnSpeechEnd events. The engine takes
+// ownership of cfg's backends as usual.
+func Evaluate(ds *Dataset, cfg smartturn.Config, opts Options) (Result, error) {
+	opts = opts.withDefaults()
+	r := &runner{}
+	e, err := smartturn.New(cfg, r.callbacks())
+	if err != nil {
+		return Result{}, err
+	}
+	defer e.Close()
+	return r.run(e, ds, cfg, opts), nil
+}
+
+// runner drives an engine over a dataset and collects OnSpeechEnd times.
+type runner struct {
+	chunk int       // index of the chunk being pushed
+	ends  []float64 // OnSpeechEnd times of the current clip, in seconds
+}
+
+func (r *runner) callbacks() smartturn.Callbacks {
+	return smartturn.Callbacks{
+		OnSpeechEnd: func() {
+			r.ends = append(r.ends, float64((r.chunk+1)*smartturn.RequiredChunkSize)/smartturn.RequiredSampleRate)
+		},
+	}
+}
+
+func (r *runner) run(e smartturn.Detector, ds *Dataset, cfg smartturn.Config, opts Options) Result {
+	res := Result{VadThreshold: cfg.VadThreshold, TurnThreshold: cfg.TurnThreshold, VadStopMs: cfg.VadStopMs}
+	pad := int(opts.TrailingSilence.Seconds() * smartturn.RequiredSampleRate)
+	buf := make([]float32, smartturn.RequiredChunkSize)
+	var latencies []float64
+	for _, clip := range ds.Clips {
+		e.Reset()
+		e.Start()
+		r.ends = r.ends[:0]
+		n := len(clip.Audio) + pad
+		for r.chunk = 0; r.chunk*len(buf) < n; r.chunk++ {
+			clear(buf)
+			if off := r.chunk * len(buf); off < len(clip.Audio) {
+				copy(buf, clip.Audio[off:])
+			}
+			_ = e.PushPCM(buf)
+		}
+		e.Stop()
+		det, early, missed, lat := score(clip.TurnEnds, r.ends, opts)
+		res.Detected += det
+		res.Early += early
+		res.Missed += missed
+		latencies = append(latencies, lat...)
+	}
+	res.Turns = ds.Turns()
+	res.summarize(latencies)
+	return res
+}
+
+// score matches predicted turn ends to labeled ones (both ascending, in
+// seconds).
+func score(labels, preds []float64, opts Options) (detected, early, missed int, latencies []float64) {
+	maxLat, tol := opts.MaxLatency.Seconds(), opts.EarlyTolerance.Seconds()
+	i := 0
+	for _, p := range preds {
+		for i < len(labels) && p > labels[i]+maxLat {
+			missed++
+			i++
+		}
+		if i == len(labels) || p < labels[i]-tol {
+			early++
+			continue
+		}
+		detected++
+		latencies = append(latencies, max(0, p-labels[i]))
+		i++
+	}
+	missed += len(labels) - i
+	return detected, early, missed, latencies
+}
+
+func (r *Result) summarize(latencies []float64) {
+	if r.Detected+r.Early > 0 {
+		r.Precision = float64(r.Detected) / float64(r.Detected+r.Early)
+	}
+	if r.Turns > 0 {
+		r.Recall = float64(r.Detected) / float64(r.Turns)
+	}
+	if r.Precision+r.Recall > 0 {
+		r.F1 = 2 * r.Precision * r.Recall / (r.Precision + r.Recall)
+	}
+	if len(latencies) == 0 {
+		return
+	}
+	slices.Sort(latencies)
+	var sum float64
+	for _, l := range latencies {
+		sum += l
+	}
+	sec := func(s float64) time.Duration { return time.Duration(math.Round(s*1e6)) * time.Microsecond }
+	r.MeanLatency = sec(sum / float64(len(latencies)))
+	r.P50Latency = sec(percentile(latencies, 0.5))
+	r.P90Latency = sec(percentile(latencies, 0.9))
+}
+
+// percentile returns the q-quantile of sorted values (nearest rank).
+func percentile(sorted []float64, q float64) float64 {
+	i := int(q*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
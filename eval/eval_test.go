@@ -0,0 +1,69 @@
+package eval
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestScore(t *testing.T) {
+	opts := Options{}.withDefaults() // MaxLatency 2s, EarlyTolerance 200ms
+	for _, tc := range []struct {
+		name                  string
+		labels, preds         []float64
+		detected, early, miss int
+		latencies             []float64
+	}{
+		{"detected", []float64{1}, []float64{1.5}, 1, 0, 0, []float64{0.5}},
+		{"within tolerance", []float64{2}, []float64{1.9}, 1, 0, 0, []float64{0}},
+		{"at max latency", []float64{1}, []float64{3}, 1, 0, 0, []float64{2}},
+		{"early", []float64{2}, []float64{1}, 0, 1, 1, nil},
+		{"too late", []float64{1}, []float64{3.5}, 0, 1, 1, nil},
+		{"no prediction", []float64{1, 4}, nil, 0, 0, 2, nil},
+		{"no label", nil, []float64{1}, 0, 1, 0, nil},
+		{"one per label", []float64{1}, []float64{1.1, 1.2}, 1, 1, 0, []float64{0.1}},
+		{"mixed", []float64{1, 5, 9}, []float64{0.5, 1.25, 5.5, 12}, 2, 2, 1, []float64{0.25, 0.5}},
+	} {
+		det, early, missed, lat := score(tc.labels, tc.preds, opts)
+		if det != tc.detected || early != tc.early || missed != tc.miss {
+			t.Errorf("%s: detected %d, early %d, missed %d; want %d, %d, %d", tc.name, det, early, missed, tc.detected, tc.early, tc.miss)
+		}
+		if !slices.EqualFunc(lat, tc.latencies, func(a, b float64) bool { return a-b < 1e-9 && b-a < 1e-9 }) {
+			t.Errorf("%s: latencies %v, want %v", tc.name, lat, tc.latencies)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	ten := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for _, tc := range []struct {
+		sorted []float64
+		q      float64
+		want   float64
+	}{
+		{ten, 0, 1},
+		{ten, 0.5, 5},
+		{ten, 0.9, 9},
+		{ten, 0.95, 10},
+		{ten, 1, 10},
+		{[]float64{3}, 0.5, 3},
+		{[]float64{3}, 0.9, 3},
+		{[]float64{1, 2}, 0.5, 1},
+		{[]float64{1, 2, 3}, 0.5, 2},
+	} {
+		if got := percentile(tc.sorted, tc.q); got != tc.want {
+			t.Errorf("percentile(%v, %v) = %v, want %v", tc.sorted, tc.q, got, tc.want)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	r := Result{Turns: 4, Detected: 2, Early: 2}
+	r.summarize([]float64{0.3, 0.1})
+	if r.Precision != 0.5 || r.Recall != 0.5 || r.F1 != 0.5 {
+		t.Errorf("precision %v, recall %v, F1 %v", r.Precision, r.Recall, r.F1)
+	}
+	if r.MeanLatency != 200*time.Millisecond || r.P50Latency != 100*time.Millisecond || r.P90Latency != 300*time.Millisecond {
+		t.Errorf("latencies mean %v, p50 %v, p90 %v", r.MeanLatency, r.P50Latency, r.P90Latency)
+	}
+}
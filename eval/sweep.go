@@ -0,0 +1,206 @@
+package eval
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+)
+
+// Grid lists the values to try; an empty list keeps the base Config value.
+type Grid struct {
+	VadThresholds  []float32
+	TurnThresholds []float32
+	VadStopMs      []int
+}
+
+// Sweep evaluates every combination of grid on ds, starting from base, and
+// returns one Result per combination (progress, if set, is called as each
+// completes). Model calls are shared: VAD runs once per clip, and
+// Smart-Turn once per VadThreshold/VadStopMs pair, since TurnThreshold does
+// not change the segments it scores; the other combinations replay the
+// recorded probabilities. base.Observer, if set, sees every run, the
+// replayed ones with the recorded outputs. Custom backends in base are
+// closed on return.
+func Sweep(ds *Dataset, base smartturn.Config, grid Grid, opts Options, progress func(Result)) ([]Result, error) {
+	opts = opts.withDefaults()
+	vads := grid.VadThresholds
+	if len(vads) == 0 {
+		vads = []float32{base.VadThreshold}
+	}
+	turns := grid.TurnThresholds
+	if len(turns) == 0 {
+		turns = []float32{base.TurnThreshold}
+	}
+	stops := grid.VadStopMs
+	if len(stops) == 0 {
+		stops = []int{base.VadStopMs}
+	}
+	if base.VADBackend != nil {
+		defer func() { _ = base.VADBackend.Close() }()
+	}
+	if base.TurnBackend != nil {
+		defer func() { _ = base.TurnBackend.Close() }()
+	}
+
+	var vadProbs *replay
+	var results []Result
+	for _, vt := range vads {
+		for _, stop := range stops {
+			var turnProbs *replay
+			for _, tt := range turns {
+				cfg := base
+				cfg.VadThreshold, cfg.VadStopMs, cfg.TurnThreshold = vt, stop, tt
+				rec := &recorder{next: base.Observer}
+				if vadProbs != nil {
+					cfg.VADBackend = vadProbs.rewind()
+				} else {
+					rec.vad = &replay{}
+					if base.VADBackend != nil {
+						cfg.VADBackend = keepOpenVAD{base.VADBackend}
+					}
+				}
+				if turnProbs != nil {
+					cfg.TurnBackend = turnProbs.rewind()
+				} else {
+					rec.turn = &replay{}
+					if base.TurnBackend != nil {
+						cfg.TurnBackend = keepOpenTurn{base.TurnBackend}
+					}
+				}
+				cfg.Observer = rec
+				res, err := Evaluate(ds, cfg, opts)
+				if err != nil {
+					return results, fmt.Errorf("eval: VadThreshold=%v VadStopMs=%d TurnThreshold=%v: %w", vt, stop, tt, err)
+				}
+				if rec.vad != nil {
+					vadProbs = rec.vad
+				}
+				if rec.turn != nil {
+					turnProbs = rec.turn
+				}
+				results = append(results, res)
+				if progress != nil {
+					progress(res)
+				}
+			}
+		}
+	}
+	return results, nil
+}
+
+// Pareto returns the results not dominated by another one with higher or
+// equal F1 and lower or equal P90 latency (and better in one of them), in
+// input order.
+func Pareto(results []Result) []Result {
+	var front []Result
+	for i, a := range results {
+		dominated := false
+		for j, b := range results {
+			if i != j && b.F1 >= a.F1 && b.P90Latency <= a.P90Latency && (b.F1 > a.F1 || b.P90Latency < a.P90Latency) {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			front = append(front, a)
+		}
+	}
+	return front
+}
+
+// Recommend picks the result with the best F1 whose P90 latency fits the
+// budget, preferring lower latency on ties. It returns false when none fits.
+func Recommend(results []Result, budget time.Duration) (Result, bool) {
+	var best Result
+	found := false
+	for _, r := range results {
+		if r.P90Latency > budget {
+			continue
+		}
+		if !found || r.F1 > best.F1 || (r.F1 == best.F1 && r.P90Latency < best.P90Latency) {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+// replay records model outputs in call order and plays them back as a
+// backend. A sweep feeds the same audio in the same order on every run, so
+// the n-th call always sees the same input.
+type replay struct {
+	probs []float32
+	errs  []error
+	next  int
+}
+
+func (r *replay) add(prob float32, err error) {
+	r.probs = append(r.probs, prob)
+	r.errs = append(r.errs, err)
+}
+
+func (r *replay) rewind() *replay {
+	r.next = 0
+	return r
+}
+
+func (r *replay) play() (float32, error) {
+	if r.next >= len(r.probs) {
+		return 0, fmt.Errorf("eval: replay exhausted after %d calls", len(r.probs))
+	}
+	i := r.next
+	r.next++
+	return r.probs[i], r.errs[i]
+}
+
+func (r *replay) SpeechProb([]float32) (float32, error) { return r.play() }
+func (r *replay) Predict([]float32) (float32, error)    { return r.play() }
+func (r *replay) Reset()                                {}
+func (r *replay) Close() error                          { return nil }
+
+// recorder is an Observer capturing the model outputs of a run; it passes
+// every call on to next, the caller's Observer.
+type recorder struct {
+	vad, turn *replay
+	next      smartturn.Observer
+}
+
+func (r *recorder) VADInference(d time.Duration, prob float32, err error) {
+	if r.vad != nil {
+		r.vad.add(prob, err)
+	}
+	if r.next != nil {
+		r.next.VADInference(d, prob, err)
+	}
+}
+
+func (r *recorder) SegmentStarted() {
+	if r.next != nil {
+		r.next.SegmentStarted()
+	}
+}
+
+func (r *recorder) SegmentEnded(samples int, bySilence bool) {
+	if r.next != nil {
+		r.next.SegmentEnded(samples, bySilence)
+	}
+}
+
+func (r *recorder) TurnInference(d time.Duration, prob float32, endOfTurn bool, err error) {
+	if r.turn != nil {
+		r.turn.add(prob, err)
+	}
+	if r.next != nil {
+		r.next.TurnInference(d, prob, endOfTurn, err)
+	}
+}
+
+// keepOpenVAD and keepOpenTurn let several engines share a custom backend;
+// Sweep closes it at the end.
+type keepOpenVAD struct{ smartturn.VADBackend }
+
+func (keepOpenVAD) Close() error { return nil }
+
+type keepOpenTurn struct{ smartturn.TurnBackend }
+
+func (keepOpenTurn) Close() error { return nil }
@@ -0,0 +1,130 @@
+package eval
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// levelVAD scores a chunk by its RMS, so VadThreshold matters, and counts
+// its calls.
+type levelVAD struct{ calls int }
+
+func (v *levelVAD) SpeechProb(chunk []float32) (float32, error) {
+	v.calls++
+	var sum float64
+	for _, x := range chunk {
+		sum += float64(x) * float64(x)
+	}
+	return float32(min(1, 5*math.Sqrt(sum/float64(len(chunk))))), nil
+}
+
+func (v *levelVAD) Reset()       {}
+func (v *levelVAD) Close() error { return nil }
+
+// hashTurn derives a probability from its input, so a replay must hand
+// every segment the output of that segment, and counts its calls.
+type hashTurn struct{ calls int }
+
+func (b *hashTurn) Predict(features []float32) (float32, error) {
+	b.calls++
+	var sum float64
+	for _, f := range features {
+		sum += float64(f)
+	}
+	return float32(math.Abs(math.Sin(sum))), nil
+}
+
+func (b *hashTurn) Close() error { return nil }
+
+// countObserver counts the model calls an Observer sees.
+type countObserver struct{ vad, turn int }
+
+func (o *countObserver) VADInference(time.Duration, float32, error)        { o.vad++ }
+func (o *countObserver) SegmentStarted()                                   {}
+func (o *countObserver) SegmentEnded(int, bool)                            {}
+func (o *countObserver) TurnInference(time.Duration, float32, bool, error) { o.turn++ }
+
+func sweepDataset() *Dataset {
+	ds := &Dataset{}
+	for seed := range uint64(2) {
+		audio, _ := smartturntest.Synth{Seed: seed}.Generate(
+			smartturntest.Speech(time.Second), smartturntest.Silence(300*time.Millisecond),
+			smartturntest.Speech(700*time.Millisecond), smartturntest.Silence(1500*time.Millisecond),
+			smartturntest.Speech(800*time.Millisecond))
+		ds.Clips = append(ds.Clips, Clip{Audio: audio, TurnEnds: []float64{2.0, 4.3}})
+	}
+	return ds
+}
+
+func sweepBase() smartturn.Config {
+	cfg := smartturn.ProfileConversational()
+	cfg.TurnTimeoutMs = 1000
+	return cfg
+}
+
+// TestSweepReplay checks that Sweep runs the models once per VAD setting
+// and once per segmentation, and that replaying their outputs scores
+// every combination as a run of its own would.
+func TestSweepReplay(t *testing.T) {
+	ds := sweepDataset()
+	grid := Grid{
+		VadThresholds:  []float32{0.3, 0.6},
+		TurnThresholds: []float32{0.3, 0.5, 0.8},
+		VadStopMs:      []int{200, 500},
+	}
+	vad, turn, obs := &levelVAD{}, &hashTurn{}, &countObserver{}
+	base := sweepBase()
+	base.VADBackend, base.TurnBackend, base.Observer = vad, turn, obs
+	results, err := Sweep(ds, base, grid, Options{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 12 {
+		t.Fatalf("%d results, want 12", len(results))
+	}
+
+	// Without the cache: fresh backends for every combination.
+	var wantVAD, wantTurn, allVAD, allTurn int
+	i := 0
+	for _, vt := range grid.VadThresholds {
+		for _, stop := range grid.VadStopMs {
+			for k, tt := range grid.TurnThresholds {
+				cfg := sweepBase()
+				v, b := &levelVAD{}, &hashTurn{}
+				cfg.VADBackend, cfg.TurnBackend = v, b
+				cfg.VadThreshold, cfg.VadStopMs, cfg.TurnThreshold = vt, stop, tt
+				want, err := Evaluate(ds, cfg, Options{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(results[i], want) {
+					t.Errorf("VadThreshold %v VadStopMs %d TurnThreshold %v:\n%+v\nwant\n%+v", vt, stop, tt, results[i], want)
+				}
+				if i == 0 {
+					wantVAD = v.calls
+				}
+				if k == 0 {
+					wantTurn += b.calls
+				}
+				allVAD += v.calls
+				allTurn += b.calls
+				i++
+			}
+		}
+	}
+	if wantTurn == 0 {
+		t.Fatal("no Smart-Turn calls")
+	}
+	if vad.calls != wantVAD || turn.calls != wantTurn {
+		t.Errorf("model calls: VAD %d, Smart-Turn %d; want %d, %d", vad.calls, turn.calls, wantVAD, wantTurn)
+	}
+	// The caller's Observer sees the calls of every run, replayed or not.
+	if obs.vad != allVAD || obs.turn != allTurn {
+		t.Errorf("Observer saw VAD %d, Smart-Turn %d; want %d, %d", obs.vad, obs.turn, allVAD, allTurn)
+	}
+}
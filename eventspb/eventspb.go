@@ -0,0 +1,547 @@
+// Package eventspb encodes engine events in the protobuf schema of
+// events.proto (package smartturn.events.v1), so consumers in any language
+// can decode engine output with code generated from that file, whether it
+// arrives over a server connection or a message bus.
+//
+//	enc := eventspb.Encoder{SessionID: callID}
+//	events, err := engine.Process(chunk)
+//	for _, ev := range events {
+//		publish(enc.AppendEvent(nil, ev))
+//	}
+//	publish(enc.AppendStats(nil, time.Now(), engine.Health()))
+//
+// The Go side is written with protowire rather than generated, so the SDK
+// does not depend on protoc; Unmarshal decodes back into smartturn types.
+// Unknown fields are skipped, so consumers keep working as fields are
+// added.
+package eventspb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of Event.
+const (
+	fieldSessionID = 1
+	fieldTime      = 2
+	fieldStats     = 24
+)
+
+// eventFields maps event kinds to their field in the Event oneof.
+var eventFields = map[smartturn.EventKind]protowire.Number{
+	smartturn.EventSpeechStart:      10,
+	smartturn.EventTurnStart:        11,
+	smartturn.EventSpeechEnd:        12,
+	smartturn.EventSegmentReady:     13,
+	smartturn.EventTurnPrediction:   14,
+	smartturn.EventTurnSplit:        15,
+	smartturn.EventTurnMerged:       16,
+	smartturn.EventTranscript:       17,
+	smartturn.EventWakeWord:         18,
+	smartturn.EventDTMF:             19,
+	smartturn.EventSpeakerChange:    20,
+	smartturn.EventShadowPrediction: 21,
+	smartturn.EventOverload:         22,
+	smartturn.EventError:            23,
+	smartturn.EventAudioEvicted:     25,
+}
+
+var fieldKinds = func() map[protowire.Number]smartturn.EventKind {
+	m := make(map[protowire.Number]smartturn.EventKind, len(eventFields))
+	for k, n := range eventFields {
+		m[n] = k
+	}
+	return m
+}()
+
+// ErrFormat is returned by Unmarshal for input that is not an Event.
+var ErrFormat = errors.New("eventspb: invalid message")
+
+// Encoder encodes Event messages.
+type Encoder struct {
+	// SessionID is set as Event.session_id.
+	SessionID string
+	// SegmentAudio includes the samples of SegmentReady events; otherwise
+	// only their count is sent.
+	SegmentAudio bool
+}
+
+// AppendEvent appends ev as an Event message to b. Events of kinds the
+// schema does not know are encoded without a kind.
+func (enc Encoder) AppendEvent(b []byte, ev smartturn.Event) []byte {
+	return enc.appendEvent(b, ev, "")
+}
+
+// AppendSegmentRef appends a SegmentReady event ev as AppendEvent does,
+// with ref as the Segment's audio_ref: where the producer stored the
+// audio.
+func (enc Encoder) AppendSegmentRef(b []byte, ev smartturn.Event, ref string) []byte {
+	return enc.appendEvent(b, ev, ref)
+}
+
+func (enc Encoder) appendEvent(b []byte, ev smartturn.Event, ref string) []byte {
+	b = enc.header(b, ev.Time)
+	n, ok := eventFields[ev.Kind]
+	if !ok {
+		return b
+	}
+	var m []byte
+	switch ev.Kind {
+	case smartturn.EventTurnStart:
+		t := ev.TurnStart
+		m = appendVarint(m, 1, uint64(t.ID))
+		m = appendVarint(m, 2, uint64(t.Offset))
+		m = appendVarint(m, 3, uint64(t.Padding))
+	case smartturn.EventSpeechEnd:
+		m = appendVarint(m, 1, uint64(ev.EndReason))
+	case smartturn.EventSegmentReady:
+		m = appendVarint(m, 1, uint64(len(ev.Segment)))
+		if enc.SegmentAudio && len(ev.Segment) > 0 {
+			m = protowire.AppendTag(m, 2, protowire.BytesType)
+			m = protowire.AppendVarint(m, uint64(4*len(ev.Segment)))
+			for _, v := range ev.Segment {
+				m = protowire.AppendFixed32(m, math.Float32bits(v))
+			}
+		}
+		m = appendString(m, 3, ref)
+	case smartturn.EventTurnPrediction:
+		p := ev.Prediction
+		m = appendBool(m, 1, p.Complete)
+		m = appendFloat(m, 2, p.Probability)
+		m = appendFloat(m, 3, p.Instant)
+		m = appendFloat(m, 4, p.Logit)
+		m = appendVarint(m, 5, uint64(p.InferenceDuration))
+		m = appendVarint(m, 6, uint64(p.Verdict))
+		m = appendVarint(m, 7, uint64(p.QueueWait))
+		m = appendVarint(m, 8, uint64(p.SilenceBeforeDecision))
+	case smartturn.EventTurnSplit:
+		s := ev.Split
+		m = appendVarint(m, 1, uint64(s.Part))
+		m = appendVarint(m, 2, uint64(s.Samples))
+		m = appendVarint(m, 3, uint64(s.OverlapSamples))
+	case smartturn.EventTurnMerged:
+		m = appendVarint(m, 1, uint64(ev.Merge.Gap))
+	case smartturn.EventTranscript:
+		t := ev.Transcript
+		m = appendVarint(m, 1, uint64(t.Turn))
+		m = appendString(m, 2, t.Text)
+		m = appendVarint(m, 3, uint64(t.Start))
+		m = appendVarint(m, 4, uint64(t.End))
+		m = appendVarint(m, 5, uint64(t.Reason))
+	case smartturn.EventDTMF:
+		if ev.Digit != 0 {
+			m = appendString(m, 1, string(ev.Digit))
+		}
+	case smartturn.EventSpeakerChange:
+		c := ev.Speaker
+		m = appendVarint(m, 1, protowire.EncodeZigZag(int64(c.From)))
+		m = appendVarint(m, 2, protowire.EncodeZigZag(int64(c.To)))
+		m = appendVarint(m, 3, uint64(c.Offset))
+	case smartturn.EventShadowPrediction:
+		s := ev.Shadow
+		m = appendFloat(m, 1, s.Primary.Probability)
+		m = appendFloat(m, 2, s.Probability)
+		m = appendFloat(m, 3, s.Logit)
+		m = appendBool(m, 4, s.Complete)
+		m = appendVarint(m, 5, uint64(s.InferenceDuration))
+		if s.Err != nil {
+			m = appendString(m, 6, s.Err.Error())
+		}
+	case smartturn.EventOverload:
+		o := ev.Overload
+		m = appendBool(m, 1, o.Overloaded)
+		m = appendDouble(m, 2, o.RTF)
+		m = appendBool(m, 3, o.Shedding)
+	case smartturn.EventError:
+		if ev.Err != nil {
+			m = appendString(m, 1, ev.Err.Error())
+		}
+	case smartturn.EventAudioEvicted:
+		v := ev.Eviction
+		m = appendVarint(m, 1, uint64(v.Turn))
+		m = appendVarint(m, 2, uint64(v.Offset))
+		m = appendVarint(m, 3, uint64(v.Budget))
+	}
+	// The oneof member is sent even when empty, to carry the kind.
+	b = protowire.AppendTag(b, n, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+// AppendStats appends h, taken at time at, as an Event message with the
+// stats kind to b.
+func (enc Encoder) AppendStats(b []byte, at time.Time, h smartturn.Health) []byte {
+	b = enc.header(b, at)
+	var m []byte
+	m = appendBool(m, 1, h.ModelsLoaded)
+	m = appendBool(m, 2, h.Listening)
+	m = appendBool(m, 3, h.VADDegraded)
+	m = appendVarint(m, 4, uint64(unixNano(h.LastVADInference)))
+	m = appendVarint(m, 5, uint64(h.LastVADLatency))
+	m = appendVarint(m, 6, uint64(unixNano(h.LastTurnInference)))
+	m = appendVarint(m, 7, uint64(h.LastTurnLatency))
+	m = appendVarint(m, 8, h.ChunksProcessed)
+	m = appendVarint(m, 9, h.DroppedChunks)
+	m = appendVarint(m, 10, uint64(h.QueuedChunks))
+	m = appendVarint(m, 11, h.SanitizedSamples)
+	m = appendDouble(m, 12, h.RTF)
+	m = appendBool(m, 13, h.Overloaded)
+	m = appendVarint(m, 14, h.VADErrors)
+	m = appendVarint(m, 15, h.TurnErrors)
+	m = appendVarint(m, 16, h.Errors)
+	m = appendString(m, 17, h.LastError)
+	m = appendVarint(m, 18, uint64(unixNano(h.LastErrorTime)))
+	b = protowire.AppendTag(b, fieldStats, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+func (enc Encoder) header(b []byte, at time.Time) []byte {
+	b = appendString(b, fieldSessionID, enc.SessionID)
+	return appendVarint(b, fieldTime, uint64(unixNano(at)))
+}
+
+// Message is a decoded Event message.
+type Message struct {
+	SessionID string
+	Time      time.Time // zero when unset
+	// Event is set for event messages; Event.Time equals Time. Error
+	// messages are carried as errors.New(message).
+	Event smartturn.Event
+	// HasEvent is false for stats messages and for kinds this package does
+	// not know.
+	HasEvent bool
+	// SegmentSamples is the length of a SegmentReady segment; Event.Segment
+	// holds its audio when the producer included it, and SegmentRef where
+	// it stored it.
+	SegmentSamples int
+	SegmentRef     string
+	// Stats is set for stats messages.
+	Stats *smartturn.Health
+}
+
+// Unmarshal decodes an Event message.
+func Unmarshal(b []byte) (Message, error) {
+	var msg Message
+	err := fields(b, func(n protowire.Number, v uint64, data []byte) error {
+		switch {
+		case n == fieldSessionID:
+			if !utf8.Valid(data) {
+				return fmt.Errorf("%w: session_id is not UTF-8", ErrFormat)
+			}
+			msg.SessionID = string(data)
+		case n == fieldTime:
+			msg.Time = fromUnixNano(int64(v))
+		case n == fieldStats:
+			h, err := decodeStats(data)
+			if err != nil {
+				return err
+			}
+			msg.Stats, msg.HasEvent = &h, false
+		default:
+			kind, ok := fieldKinds[n]
+			if !ok {
+				return nil
+			}
+			msg.Event, msg.SegmentSamples, msg.SegmentRef = smartturn.Event{Kind: kind}, 0, ""
+			msg.HasEvent, msg.Stats = true, nil
+			return decodeEvent(&msg, data)
+		}
+		return nil
+	})
+	if err != nil {
+		return Message{}, err
+	}
+	msg.Event.Time = msg.Time
+	if msg.Event.Kind == smartturn.EventTurnStart {
+		msg.Event.TurnStart.Time = msg.Time
+	}
+	return msg, nil
+}
+
+func decodeEvent(msg *Message, b []byte) error {
+	ev := &msg.Event
+	return fields(b, func(n protowire.Number, v uint64, data []byte) error {
+		switch ev.Kind {
+		case smartturn.EventTurnStart:
+			switch n {
+			case 1:
+				ev.TurnStart.ID = int(v)
+			case 2:
+				ev.TurnStart.Offset = int64(v)
+			case 3:
+				ev.TurnStart.Padding = time.Duration(v)
+			}
+		case smartturn.EventSpeechEnd:
+			if n == 1 {
+				ev.EndReason = smartturn.TurnEndReason(v)
+			}
+		case smartturn.EventSegmentReady:
+			switch n {
+			case 1:
+				msg.SegmentSamples = int(v)
+			case 2:
+				if data == nil {
+					// Unpacked encoding: one float per field.
+					ev.Segment = append(ev.Segment, math.Float32frombits(uint32(v)))
+					break
+				}
+				if len(data)%4 != 0 {
+					return fmt.Errorf("%w: segment audio", ErrFormat)
+				}
+				for i := 0; i < len(data); i += 4 {
+					x, _ := protowire.ConsumeFixed32(data[i:])
+					ev.Segment = append(ev.Segment, math.Float32frombits(x))
+				}
+			case 3:
+				msg.SegmentRef = string(data)
+			}
+		case smartturn.EventTurnPrediction:
+			p := &ev.Prediction
+			switch n {
+			case 1:
+				p.Complete = v != 0
+			case 2:
+				p.Probability = math.Float32frombits(uint32(v))
+			case 3:
+				p.Instant = math.Float32frombits(uint32(v))
+			case 4:
+				p.Logit = math.Float32frombits(uint32(v))
+			case 5:
+				p.InferenceDuration = time.Duration(v)
+			case 6:
+				p.Verdict = smartturn.TurnVerdict(v)
+			case 7:
+				p.QueueWait = time.Duration(v)
+			case 8:
+				p.SilenceBeforeDecision = time.Duration(v)
+			}
+		case smartturn.EventTurnSplit:
+			switch n {
+			case 1:
+				ev.Split.Part = int(v)
+			case 2:
+				ev.Split.Samples = int(v)
+			case 3:
+				ev.Split.OverlapSamples = int(v)
+			}
+		case smartturn.EventTurnMerged:
+			if n == 1 {
+				ev.Merge.Gap = time.Duration(v)
+			}
+		case smartturn.EventTranscript:
+			t := &ev.Transcript
+			switch n {
+			case 1:
+				t.Turn = int(v)
+			case 2:
+				t.Text = string(data)
+			case 3:
+				t.Start = int64(v)
+			case 4:
+				t.End = int64(v)
+			case 5:
+				t.Reason = smartturn.TurnEndReason(v)
+			}
+		case smartturn.EventDTMF:
+			if n == 1 {
+				ev.Digit, _ = utf8.DecodeRune(data)
+			}
+		case smartturn.EventSpeakerChange:
+			switch n {
+			case 1:
+				ev.Speaker.From = int(protowire.DecodeZigZag(v))
+			case 2:
+				ev.Speaker.To = int(protowire.DecodeZigZag(v))
+			case 3:
+				ev.Speaker.Offset = int64(v)
+			}
+		case smartturn.EventShadowPrediction:
+			s := &ev.Shadow
+			switch n {
+			case 1:
+				s.Primary.Probability = math.Float32frombits(uint32(v))
+			case 2:
+				s.Probability = math.Float32frombits(uint32(v))
+			case 3:
+				s.Logit = math.Float32frombits(uint32(v))
+			case 4:
+				s.Complete = v != 0
+			case 5:
+				s.InferenceDuration = time.Duration(v)
+			case 6:
+				s.Err = errors.New(string(data))
+			}
+		case smartturn.EventOverload:
+			switch n {
+			case 1:
+				ev.Overload.Overloaded = v != 0
+			case 2:
+				ev.Overload.RTF = math.Float64frombits(v)
+			case 3:
+				ev.Overload.Shedding = v != 0
+			}
+		case smartturn.EventError:
+			if n == 1 {
+				ev.Err = errors.New(string(data))
+			}
+		case smartturn.EventAudioEvicted:
+			switch n {
+			case 1:
+				ev.Eviction.Turn = int(v)
+			case 2:
+				ev.Eviction.Offset = int64(v)
+			case 3:
+				ev.Eviction.Budget = time.Duration(v)
+			}
+		}
+		return nil
+	})
+}
+
+func decodeStats(b []byte) (smartturn.Health, error) {
+	var h smartturn.Health
+	err := fields(b, func(n protowire.Number, v uint64, data []byte) error {
+		switch n {
+		case 1:
+			h.ModelsLoaded = v != 0
+		case 2:
+			h.Listening = v != 0
+		case 3:
+			h.VADDegraded = v != 0
+		case 4:
+			h.LastVADInference = fromUnixNano(int64(v))
+		case 5:
+			h.LastVADLatency = time.Duration(v)
+		case 6:
+			h.LastTurnInference = fromUnixNano(int64(v))
+		case 7:
+			h.LastTurnLatency = time.Duration(v)
+		case 8:
+			h.ChunksProcessed = v
+		case 9:
+			h.DroppedChunks = v
+		case 10:
+			h.QueuedChunks = int(v)
+		case 11:
+			h.SanitizedSamples = v
+		case 12:
+			h.RTF = math.Float64frombits(v)
+		case 13:
+			h.Overloaded = v != 0
+		case 14:
+			h.VADErrors = v
+		case 15:
+			h.TurnErrors = v
+		case 16:
+			h.Errors = v
+		case 17:
+			h.LastError = string(data)
+		case 18:
+			h.LastErrorTime = fromUnixNano(int64(v))
+		}
+		return nil
+	})
+	return h, err
+}
+
+// fields calls fn for each field of message b, with the value of numeric
+// fields in v and the contents of length-delimited ones in data. Groups
+// are skipped.
+func fields(b []byte, fn func(n protowire.Number, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		n, typ, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			return fmt.Errorf("%w: %v", ErrFormat, protowire.ParseError(l))
+		}
+		b = b[l:]
+		var v uint64
+		var data []byte
+		switch typ {
+		case protowire.VarintType:
+			v, l = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var x uint32
+			x, l = protowire.ConsumeFixed32(b)
+			v = uint64(x)
+		case protowire.Fixed64Type:
+			v, l = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			data, l = protowire.ConsumeBytes(b)
+		default:
+			l = protowire.ConsumeFieldValue(n, typ, b)
+			if l >= 0 {
+				b = b[l:]
+				continue
+			}
+		}
+		if l < 0 {
+			return fmt.Errorf("%w: %v", ErrFormat, protowire.ParseError(l))
+		}
+		b = b[l:]
+		if err := fn(n, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The append helpers omit zero values, as proto3 encoders do.
+
+func appendVarint(b []byte, n protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, n, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendBool(b []byte, n protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	return appendVarint(b, n, 1)
+}
+
+func appendFloat(b []byte, n protowire.Number, v float32) []byte {
+	if math.Float32bits(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, n, protowire.Fixed32Type)
+	return protowire.AppendFixed32(b, math.Float32bits(v))
+}
+
+func appendDouble(b []byte, n protowire.Number, v float64) []byte {
+	if math.Float64bits(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, n, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+func appendString(b []byte, n protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, n, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
@@ -0,0 +1,397 @@
+package eventspb_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/eventspb"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/events.golden")
+
+var testTime = time.Unix(1_700_000_000, 123_456_789)
+
+// sample is an event with every field of its message set, so its
+// encoding exercises the whole schema.
+type sample struct {
+	name    string // proto message of the oneof member
+	ev      smartturn.Event
+	ref     string            // Segment.audio_ref
+	health  *smartturn.Health // for the Stats message
+	healthN int               // fields of Stats
+}
+
+func samples() []sample {
+	ev := func(kind smartturn.EventKind, set func(*smartturn.Event)) smartturn.Event {
+		e := smartturn.Event{Kind: kind, Time: testTime}
+		if set != nil {
+			set(&e)
+		}
+		return e
+	}
+	return []sample{
+		{name: "SpeechStart", ev: ev(smartturn.EventSpeechStart, nil)},
+		{name: "TurnStart", ev: ev(smartturn.EventTurnStart, func(e *smartturn.Event) {
+			e.TurnStart = smartturn.TurnStart{ID: 3, Offset: 48000, Time: testTime, Padding: 192 * time.Millisecond}
+		})},
+		{name: "SpeechEnd", ev: ev(smartturn.EventSpeechEnd, func(e *smartturn.Event) { e.EndReason = smartturn.TurnEndTimeout })},
+		{name: "Segment", ref: "s3://bucket/seg-1", ev: ev(smartturn.EventSegmentReady, func(e *smartturn.Event) {
+			e.Segment = []float32{0.5, -0.25, 1}
+		})},
+		{name: "TurnPrediction", ev: ev(smartturn.EventTurnPrediction, func(e *smartturn.Event) {
+			e.Prediction = smartturn.TurnPrediction{
+				Complete: true, Probability: 0.8, Instant: 0.7, Logit: 1.386,
+				InferenceDuration: 12 * time.Millisecond, Verdict: smartturn.VerdictIncomplete,
+				QueueWait: time.Millisecond, SilenceBeforeDecision: 320 * time.Millisecond,
+			}
+		})},
+		{name: "TurnSplit", ev: ev(smartturn.EventTurnSplit, func(e *smartturn.Event) {
+			e.Split = smartturn.TurnSplit{Part: 1, Samples: 160000, OverlapSamples: 8192}
+		})},
+		{name: "TurnMerged", ev: ev(smartturn.EventTurnMerged, func(e *smartturn.Event) { e.Merge.Gap = 400 * time.Millisecond })},
+		{name: "Transcript", ev: ev(smartturn.EventTranscript, func(e *smartturn.Event) {
+			e.Transcript = smartturn.Transcript{Turn: 2, Text: "héllo", Start: 16000, End: 64000, Reason: smartturn.TurnEndMaxDuration}
+		})},
+		{name: "WakeWord", ev: ev(smartturn.EventWakeWord, nil)},
+		{name: "DTMF", ev: ev(smartturn.EventDTMF, func(e *smartturn.Event) { e.Digit = '#' })},
+		{name: "SpeakerChange", ev: ev(smartturn.EventSpeakerChange, func(e *smartturn.Event) {
+			e.Speaker = smartturn.SpeakerChange{From: -1, To: 2, Offset: 32768}
+		})},
+		{name: "ShadowPrediction", ev: ev(smartturn.EventShadowPrediction, func(e *smartturn.Event) {
+			e.Shadow = smartturn.ShadowPrediction{
+				Primary: smartturn.TurnPrediction{Probability: 0.8}, Probability: 0.6, Logit: 0.405,
+				Complete: true, InferenceDuration: 5 * time.Millisecond, Err: errors.New("shadow failed"),
+			}
+		})},
+		{name: "Overload", ev: ev(smartturn.EventOverload, func(e *smartturn.Event) {
+			e.Overload = smartturn.OverloadEvent{Overloaded: true, RTF: 1.25, Shedding: true}
+		})},
+		{name: "Error", ev: ev(smartturn.EventError, func(e *smartturn.Event) { e.Err = errors.New("vad inference failed") })},
+		{name: "AudioEvicted", ev: ev(smartturn.EventAudioEvicted, func(e *smartturn.Event) {
+			e.Eviction = smartturn.AudioEviction{Turn: 4, Offset: 96000, Budget: 30 * time.Second}
+		})},
+		{name: "Stats", health: &smartturn.Health{
+			ModelsLoaded: true, Listening: true, VADDegraded: true,
+			LastVADInference: testTime, LastVADLatency: 800 * time.Microsecond,
+			LastTurnInference: testTime.Add(time.Second), LastTurnLatency: 15 * time.Millisecond,
+			ChunksProcessed: 1000, DroppedChunks: 2, QueuedChunks: 3, SanitizedSamples: 4,
+			RTF: 0.05, Overloaded: true, VADErrors: 5, TurnErrors: 6, Errors: 11,
+			LastError: "boom", LastErrorTime: testTime.Add(2 * time.Second),
+		}},
+	}
+}
+
+// encode encodes s the way a producer would.
+func (s sample) encode() []byte {
+	enc := eventspb.Encoder{SessionID: "call-7", SegmentAudio: true}
+	switch {
+	case s.health != nil:
+		return enc.AppendStats(nil, testTime, *s.health)
+	case s.ref != "":
+		return enc.AppendSegmentRef(nil, s.ev, s.ref)
+	}
+	return enc.AppendEvent(nil, s.ev)
+}
+
+// TestGolden pins the encoding of every message against
+// testdata/events.golden, and checks that decoding the golden bytes and
+// encoding the result again reproduces them. Run with -update after a
+// deliberate schema change.
+func TestGolden(t *testing.T) {
+	path := filepath.Join("testdata", "events.golden")
+	var got bytes.Buffer
+	for _, s := range samples() {
+		fmt.Fprintf(&got, "%s %s\n", s.name, hex.EncodeToString(s.encode()))
+	}
+	if *update {
+		if err := os.WriteFile(path, got.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("encoding differs from %s:\ngot:\n%s\nwant:\n%s", path, got.Bytes(), want)
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(want))
+	for sc.Scan() {
+		name, h, _ := strings.Cut(sc.Text(), " ")
+		b, err := hex.DecodeString(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := eventspb.Unmarshal(b)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if msg.SessionID != "call-7" || !msg.Time.Equal(testTime) {
+			t.Errorf("%s: session %q, time %v", name, msg.SessionID, msg.Time)
+		}
+		enc := eventspb.Encoder{SessionID: msg.SessionID, SegmentAudio: true}
+		var again []byte
+		switch {
+		case msg.Stats != nil:
+			again = enc.AppendStats(nil, msg.Time, *msg.Stats)
+		case msg.SegmentRef != "":
+			if msg.SegmentSamples != len(msg.Event.Segment) {
+				t.Errorf("%s: %d samples, %d decoded", name, msg.SegmentSamples, len(msg.Event.Segment))
+			}
+			again = enc.AppendSegmentRef(nil, msg.Event, msg.SegmentRef)
+		default:
+			if !msg.HasEvent {
+				t.Fatalf("%s: decoded without an event", name)
+			}
+			again = enc.AppendEvent(nil, msg.Event)
+		}
+		if !bytes.Equal(again, b) {
+			t.Errorf("%s: decode and encode gives\n%x\nwant\n%x", name, again, b)
+		}
+	}
+}
+
+// TestUnmarshalErrors checks that malformed input is rejected with
+// ErrFormat and unknown fields are skipped.
+func TestUnmarshalErrors(t *testing.T) {
+	for name, b := range map[string][]byte{
+		"truncated tag":    {0x80},
+		"truncated length": {0x0a, 0x05, 'a'},
+		"bad session id":   {0x0a, 0x01, 0xff},
+		"odd audio":        {0x6a, 0x04, 0x12, 0x02, 0x00, 0x00},
+	} {
+		if _, err := eventspb.Unmarshal(b); !errors.Is(err, eventspb.ErrFormat) {
+			t.Errorf("%s: error %v, want ErrFormat", name, err)
+		}
+	}
+	b := protowire.AppendTag(nil, 99, protowire.BytesType)
+	b = protowire.AppendString(b, "from a newer producer")
+	b = append(b, eventspb.Encoder{}.AppendEvent(nil, smartturn.Event{Kind: smartturn.EventWakeWord})...)
+	msg, err := eventspb.Unmarshal(b)
+	if err != nil || !msg.HasEvent || msg.Event.Kind != smartturn.EventWakeWord {
+		t.Errorf("unknown field: %+v, %v; want the WakeWord event", msg, err)
+	}
+}
+
+// protoField is a field of events.proto.
+type protoField struct {
+	typ      string
+	repeated bool
+	number   int
+}
+
+// protoSchema is what the tests need of events.proto: the fields of each
+// message and the values of each enum.
+type protoSchema struct {
+	messages map[string]map[string]protoField
+	enums    map[string]map[string]int
+}
+
+var (
+	protoBlockRe = regexp.MustCompile(`^(message|enum|oneof)\s+(\w+)\s*\{(\s*\})?$`)
+	protoFieldRe = regexp.MustCompile(`^(repeated\s+)?(\w+)\s+(\w+)\s*=\s*(\d+);`)
+	protoValueRe = regexp.MustCompile(`^(\w+)\s*=\s*(\d+);`)
+)
+
+// parseProto reads the messages and enums of a .proto file without
+// nested messages, flattening oneofs into their message.
+func parseProto(t *testing.T, path string) protoSchema {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	s := protoSchema{messages: map[string]map[string]protoField{}, enums: map[string]map[string]int{}}
+	var message, enum string
+	depth := 0
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		switch m := protoBlockRe.FindStringSubmatch(line); {
+		case line == "":
+		case m != nil:
+			switch m[1] {
+			case "message":
+				message = m[2]
+				s.messages[message] = map[string]protoField{}
+			case "enum":
+				enum = m[2]
+				s.enums[enum] = map[string]int{}
+			}
+			if m[3] == "" {
+				depth++
+			}
+		case line == "}":
+			if depth--; depth == 0 {
+				message, enum = "", ""
+			}
+		case enum != "":
+			v := protoValueRe.FindStringSubmatch(line)
+			if v == nil {
+				t.Fatalf("%s: cannot parse %q", path, line)
+			}
+			n, _ := strconv.Atoi(v[2])
+			s.enums[enum][v[1]] = n
+		case message != "":
+			v := protoFieldRe.FindStringSubmatch(line)
+			if v == nil {
+				t.Fatalf("%s: cannot parse %q", path, line)
+			}
+			n, _ := strconv.Atoi(v[4])
+			s.messages[message][v[3]] = protoField{typ: v[2], repeated: v[1] != "", number: n}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// wireType is the wire type protoc would use for a field.
+func (f protoField) wireType() protowire.Type {
+	if f.repeated {
+		return protowire.BytesType // packed scalars
+	}
+	switch f.typ {
+	case "float", "fixed32", "sfixed32":
+		return protowire.Fixed32Type
+	case "double", "fixed64", "sfixed64":
+		return protowire.Fixed64Type
+	case "bool", "int32", "int64", "uint32", "uint64", "sint32", "sint64":
+		return protowire.VarintType
+	case "string", "bytes":
+		return protowire.BytesType
+	}
+	if f.typ == "EndReason" || f.typ == "Verdict" {
+		return protowire.VarintType
+	}
+	return protowire.BytesType // message
+}
+
+// wireFields returns the field numbers and wire types of message b.
+func wireFields(t *testing.T, b []byte) map[int]protowire.Type {
+	t.Helper()
+	out := map[int]protowire.Type{}
+	for len(b) > 0 {
+		n, typ, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			t.Fatal(protowire.ParseError(l))
+		}
+		b = b[l:]
+		l = protowire.ConsumeFieldValue(n, typ, b)
+		if l < 0 {
+			t.Fatal(protowire.ParseError(l))
+		}
+		out[int(n)] = typ
+		b = b[l:]
+	}
+	return out
+}
+
+// fieldValue returns the contents of length-delimited field n of b.
+func fieldValue(b []byte, n int) []byte {
+	for len(b) > 0 {
+		num, typ, l := protowire.ConsumeTag(b)
+		b = b[l:]
+		l = protowire.ConsumeFieldValue(num, typ, b)
+		if int(num) == n {
+			v, _ := protowire.ConsumeBytes(b)
+			return v
+		}
+		b = b[l:]
+	}
+	return nil
+}
+
+// TestSchema parses events.proto and checks the encoder against it: each
+// sample's oneof member has the number and message type the .proto gives
+// it, and the sample sets every field of that message with the declared
+// number and wire type. The enums must match the smartturn constants.
+func TestSchema(t *testing.T) {
+	schema := parseProto(t, "events.proto")
+	event := schema.messages["Event"]
+	members := map[string]protoField{} // oneof members by message type
+	for name, f := range event {
+		if name != "session_id" && name != "time_unix_nano" {
+			members[f.typ] = f
+		}
+	}
+	covered := map[string]bool{}
+	for _, s := range samples() {
+		b := s.encode()
+		top := wireFields(t, b)
+		for _, name := range []string{"session_id", "time_unix_nano"} {
+			f := event[name]
+			if top[f.number] != f.wireType() {
+				t.Errorf("%s: Event.%s (%d) not encoded as wire type %v", s.name, name, f.number, f.wireType())
+			}
+		}
+		member, ok := members[s.name]
+		if !ok {
+			t.Errorf("%s: not a member of Event.kind in events.proto", s.name)
+			continue
+		}
+		covered[s.name] = true
+		if top[member.number] != protowire.BytesType || len(top) != 3 {
+			t.Errorf("%s: Event fields %v, want the header and member %d", s.name, top, member.number)
+			continue
+		}
+		got := wireFields(t, fieldValue(b, member.number))
+		fields := schema.messages[s.name]
+		if fields == nil {
+			t.Fatalf("%s: no such message in events.proto", s.name)
+		}
+		want := map[int]protowire.Type{}
+		for _, f := range fields {
+			want[f.number] = f.wireType()
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: encoded fields %v, events.proto declares %v", s.name, got, want)
+		}
+	}
+	var missing []string
+	for name := range members {
+		if !covered[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		t.Errorf("events.proto members without a sample: %v", missing)
+	}
+
+	for v := smartturn.TurnEndModel; v <= smartturn.TurnEndSpeakerChange; v++ {
+		name := "END_REASON_" + strings.ToUpper(v.String())
+		if n, ok := schema.enums["EndReason"][name]; !ok || n != int(v) {
+			t.Errorf("EndReason %s = %d (declared %v), want %d", name, n, ok, v)
+		}
+	}
+	for v := smartturn.VerdictAcoustic; v <= smartturn.VerdictIncomplete; v++ {
+		name := "VERDICT_" + strings.ToUpper(v.String())
+		if n, ok := schema.enums["Verdict"][name]; !ok || n != int(v) {
+			t.Errorf("Verdict %s = %d (declared %v), want %d", name, n, ok, v)
+		}
+	}
+	if len(schema.enums["EndReason"]) != 4 || len(schema.enums["Verdict"]) != 3 {
+		t.Errorf("enums %v: values without a smartturn constant", schema.enums)
+	}
+}
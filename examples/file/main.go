@@ -7,9 +7,10 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/cortexswarm/smart-turn-go"
-	"github.com/cortexswarm/smart-turn-go/examples/utility/resolver"
+	"github.com/cortexswarm/smart-turn-go/internal/resolver"
 	"github.com/youpy/go-wav"
 )
 
@@ -79,8 +80,9 @@ func main() {
 		OnListeningStopped: func() { fmt.Println("[event] listening stopped") },
 		OnSpeechStart:      func() { fmt.Println("[event] speech start") },
 		OnSpeechEnd:        func() { fmt.Println("[event] speech end") },
-		OnTurnPrediction: func(complete bool, prob float32) {
-			fmt.Printf("[event] turn prediction complete=%v prob=%.3f\n", complete, prob)
+		OnTurnPredictionDetail: func(p smartturn.TurnPrediction) {
+			fmt.Printf("[event] turn prediction complete=%v prob=%.3f inference=%v silence=%v\n",
+				p.Complete, p.Probability, p.InferenceDuration.Round(time.Millisecond), p.SilenceBeforeDecision)
 		},
 		OnSegmentReady: func(seg []float32) {
 			segmentNum++
@@ -0,0 +1,96 @@
+// Compares the Go Whisper feature pipeline (package features) against
+// golden log-mel fixtures produced by transformers.WhisperFeatureExtractor,
+// for 8 s clips and real recordings beyond the 1 s set that
+// TestWhisperParity checks. Generate fixtures with
+// scripts/whisper_mel_reference.py, then
+// run from repo root: go run ./examples/melparity [-dir testdata/parity] [-tol 1e-3]
+// Exits non-zero when any fixture differs by more than -tol.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cortexswarm/smart-turn-go/features"
+	"github.com/cortexswarm/smart-turn-go/internal/npy"
+)
+
+func main() {
+	dir := flag.String("dir", "testdata/parity", "directory with <name>.audio.npy / <name>.mel.npy pairs")
+	tol := flag.Float64("tol", 1e-3, "maximum allowed absolute difference per value")
+	flag.Parse()
+
+	refs, err := filepath.Glob(filepath.Join(*dir, "*.mel.npy"))
+	if err != nil || len(refs) == 0 {
+		fmt.Fprintf(os.Stderr, "no *.mel.npy fixtures in %s; generate them with scripts/whisper_mel_reference.py\n", *dir)
+		os.Exit(1)
+	}
+	sort.Strings(refs)
+
+	failed := 0
+	fmt.Printf("%-24s %12s %12s %12s\n", "fixture", "max |diff|", "mean |diff|", "worst (m,t)")
+	for _, refPath := range refs {
+		name := strings.TrimSuffix(filepath.Base(refPath), ".mel.npy")
+		maxDiff, meanDiff, worst, err := compare(filepath.Join(*dir, name+".audio.npy"), refPath)
+		if err != nil {
+			fmt.Printf("%-24s error: %v\n", name, err)
+			failed++
+			continue
+		}
+		status := "ok"
+		if maxDiff > *tol {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Printf("%-24s %12.3g %12.3g %12s %s\n", name, maxDiff, meanDiff, worst, status)
+	}
+	if failed > 0 {
+		fmt.Printf("%d of %d fixtures exceed tolerance %g\n", failed, len(refs), *tol)
+		os.Exit(1)
+	}
+}
+
+// compare computes features for the fixture audio and returns the maximum
+// and mean absolute difference to the reference, and where the maximum is.
+func compare(audioPath, refPath string) (maxDiff, meanDiff float64, worst string, err error) {
+	audio, _, err := readNPY(audioPath)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	ref, refShape, err := readNPY(refPath)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	mel, shape := features.ComputeLogMel(audio)
+	if mel == nil {
+		return 0, 0, "", fmt.Errorf("no features for %d samples", len(audio))
+	}
+	if len(refShape) != 2 || refShape[0] != shape.Mels || refShape[1] != shape.Frames {
+		return 0, 0, "", fmt.Errorf("reference shape %v, pipeline produces (%d, %d)", refShape, shape.Mels, shape.Frames)
+	}
+	var sum float64
+	worstIdx := 0
+	for i := range mel {
+		d := math.Abs(float64(mel[i]) - float64(ref[i]))
+		sum += d
+		if d > maxDiff {
+			maxDiff, worstIdx = d, i
+		}
+	}
+	worst = fmt.Sprintf("(%d,%d)", worstIdx/shape.Frames, worstIdx%shape.Frames)
+	return maxDiff, sum / float64(len(mel)), worst, nil
+}
+
+func readNPY(path string) ([]float32, []int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return npy.Read(f)
+}
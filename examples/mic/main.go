@@ -12,7 +12,7 @@ import (
 	"sync"
 
 	"github.com/cortexswarm/smart-turn-go"
-	"github.com/cortexswarm/smart-turn-go/examples/utility/resolver"
+	"github.com/cortexswarm/smart-turn-go/internal/resolver"
 	"github.com/gen2brain/malgo"
 )
 
@@ -73,8 +73,10 @@ func main() {
 		OnSpeechStart:      func() { fmt.Println("[callback] speech start") },
 		OnSpeechEnd:        func() { fmt.Println("[callback] speech end") },
 		OnSegmentReady:     func(seg []float32) { fmt.Printf("[callback] segment ready (%d samples)\n", len(seg)) },
-		OnTurnPrediction:   func(complete bool, prob float32) { fmt.Printf("[callback] turn prediction complete=%v prob=%.3f\n", complete, prob) },
-		OnError:            func(err error) { fmt.Printf("[callback] error: %v\n", err) },
+		OnTurnPredictionDetail: func(p smartturn.TurnPrediction) {
+			fmt.Printf("[callback] turn prediction complete=%v prob=%.3f\n", p.Complete, p.Probability)
+		},
+		OnError: func(err error) { fmt.Printf("[callback] error: %v\n", err) },
 	}
 
 	engine, err := smartturn.New(cfg, cb)
@@ -0,0 +1,90 @@
+// Run from repo root: go run ./examples/replay [flags] session.journal
+// Replays a journal written by journal.Recorder against the bundled models
+// and prints every step whose events differ from the recording. The flags
+// must match the Config the session was recorded with.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/internal/resolver"
+	"github.com/cortexswarm/smart-turn-go/journal"
+)
+
+func main() {
+	vadThreshold := flag.Float64("vad-threshold", 0.75, "Config.VadThreshold")
+	preSpeech := flag.Int("pre-speech-ms", 200, "Config.VadPreSpeechMs")
+	stopMs := flag.Int("stop-ms", 800, "Config.VadStopMs")
+	maxDur := flag.Float64("max-duration", 600, "Config.TurnMaxDurationSeconds")
+	emitMs := flag.Int("emit-ms", 1000, "Config.TurnSegmentEmitMs")
+	turnThreshold := flag.Float64("turn-threshold", 0.9, "Config.TurnThreshold")
+	timeoutMs := flag.Int("timeout-ms", 1000, "Config.TurnTimeoutMs")
+	tol := flag.Float64("tol", 0, "allowed Smart-Turn probability difference")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: replay [flags] session.journal")
+		os.Exit(2)
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open journal: %v\n", err)
+		os.Exit(1)
+	}
+	j, err := journal.Read(f)
+	_ = f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read journal: %v\n", err)
+		os.Exit(1)
+	}
+
+	sileroPath, err := resolver.ResolveSileroVAD(resolver.ModelsDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "resolve Silero VAD: %v\n", err)
+		os.Exit(1)
+	}
+	smartTurnPath, err := resolver.ResolveSmartTurn(resolver.ModelsDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "resolve Smart-Turn: %v\n", err)
+		os.Exit(1)
+	}
+	onnxLibPath, err := resolver.ResolveONNXRuntimeLibWithDownload(resolver.ModelsDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "resolve ONNX Runtime lib: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg := smartturn.Config{
+		SampleRate:             16000,
+		ChunkSize:              512,
+		VadThreshold:           float32(*vadThreshold),
+		VadPreSpeechMs:         *preSpeech,
+		VadStopMs:              *stopMs,
+		TurnMaxDurationSeconds: float32(*maxDur),
+		TurnSegmentEmitMs:      *emitMs,
+		TurnThreshold:          float32(*turnThreshold),
+		TurnTimeoutMs:          *timeoutMs,
+		SileroVADModelPath:     sileroPath,
+		SmartTurnModelPath:     smartTurnPath,
+		ONNXRuntimeLibPath:     onnxLibPath,
+	}
+	rp := journal.NewReplayer(j)
+	rp.Tolerance = float32(*tol)
+	engine, err := smartturn.New(cfg, rp.Callbacks())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "New: %v\n", err)
+		os.Exit(1)
+	}
+	mismatches := rp.Run(engine)
+	engine.Close()
+	for _, m := range mismatches {
+		fmt.Println(m)
+	}
+	fmt.Printf("%d steps replayed, %d mismatches\n", len(j.Steps), len(mismatches))
+	if len(mismatches) > 0 {
+		os.Exit(1)
+	}
+}
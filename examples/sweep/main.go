@@ -0,0 +1,125 @@
+// Run from repo root: go run ./examples/sweep -data path/to/dataset [flags]
+// Evaluates VadThreshold × VadStopMs × TurnThreshold combinations on a
+// labeled dataset (16 kHz WAVs plus labels.csv with "file,turn_end_seconds"
+// rows), prints every result and the Pareto front of F1 versus P90
+// latency, and recommends the most accurate configuration within -budget.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/eval"
+	"github.com/cortexswarm/smart-turn-go/internal/resolver"
+)
+
+func main() {
+	dataDir := flag.String("data", "", "dataset directory (required)")
+	vads := flag.String("vad", "0.5,0.6,0.75", "VadThreshold values")
+	turns := flag.String("turn", "0.5,0.7,0.9", "TurnThreshold values")
+	stops := flag.String("stop-ms", "300,500,800", "VadStopMs values")
+	budget := flag.Duration("budget", time.Second, "P90 end-of-turn latency budget")
+	maxLatency := flag.Duration("max-latency", 2*time.Second, "detections later than this count as missed")
+	flag.Parse()
+	if *dataDir == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	grid, err := parseGrid(*vads, *turns, *stops)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	ds, err := eval.Load(*dataDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	sileroPath, err := resolver.ResolveSileroVAD(resolver.ModelsDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "resolve Silero VAD: %v\n", err)
+		os.Exit(1)
+	}
+	smartTurnPath, err := resolver.ResolveSmartTurn(resolver.ModelsDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "resolve Smart-Turn: %v\n", err)
+		os.Exit(1)
+	}
+	onnxLibPath, err := resolver.ResolveONNXRuntimeLibWithDownload(resolver.ModelsDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "resolve ONNX Runtime lib: %v\n", err)
+		os.Exit(1)
+	}
+	base := smartturn.Config{
+		SampleRate:             16000,
+		ChunkSize:              512,
+		VadThreshold:           0.5,
+		VadPreSpeechMs:         200,
+		VadStopMs:              800,
+		TurnMaxDurationSeconds: 600,
+		TurnSegmentEmitMs:      1000,
+		TurnThreshold:          0.5,
+		TurnTimeoutMs:          1000,
+		SileroVADModelPath:     sileroPath,
+		SmartTurnModelPath:     smartTurnPath,
+		ONNXRuntimeLibPath:     onnxLibPath,
+	}
+
+	fmt.Printf("%d clips, %d labeled turn ends\n", len(ds.Clips), ds.Turns())
+	fmt.Println("vad   stop_ms turn  F1    prec  recall early missed p50      p90")
+	results, err := eval.Sweep(ds, base, grid, eval.Options{MaxLatency: *maxLatency}, printResult)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("\nPareto front (F1 vs P90 latency):")
+	for _, r := range eval.Pareto(results) {
+		printResult(r)
+	}
+	best, ok := eval.Recommend(results, *budget)
+	if !ok {
+		fmt.Printf("\nno configuration meets a P90 latency of %v\n", *budget)
+		os.Exit(1)
+	}
+	fmt.Printf("\nrecommended for P90 <= %v: VadThreshold=%.2f VadStopMs=%d TurnThreshold=%.2f (F1 %.3f, P90 %v)\n",
+		*budget, best.VadThreshold, best.VadStopMs, best.TurnThreshold, best.F1, best.P90Latency)
+}
+
+func printResult(r eval.Result) {
+	fmt.Printf("%.2f  %-7d %.2f  %.3f %.3f %.3f  %-5d %-6d %-8v %v\n",
+		r.VadThreshold, r.VadStopMs, r.TurnThreshold, r.F1, r.Precision, r.Recall, r.Early, r.Missed,
+		r.P50Latency.Round(time.Millisecond), r.P90Latency.Round(time.Millisecond))
+}
+
+func parseGrid(vads, turns, stops string) (eval.Grid, error) {
+	var g eval.Grid
+	for _, s := range strings.Split(vads, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 32)
+		if err != nil {
+			return g, fmt.Errorf("-vad: %w", err)
+		}
+		g.VadThresholds = append(g.VadThresholds, float32(v))
+	}
+	for _, s := range strings.Split(turns, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 32)
+		if err != nil {
+			return g, fmt.Errorf("-turn: %w", err)
+		}
+		g.TurnThresholds = append(g.TurnThresholds, float32(v))
+	}
+	for _, s := range strings.Split(stops, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return g, fmt.Errorf("-stop-ms: %w", err)
+		}
+		g.VadStopMs = append(g.VadStopMs, v)
+	}
+	return g, nil
+}
@@ -0,0 +1,139 @@
+// Transcribe example: runs a WAV file through the engine with a
+// Transcriber, so the OnSegmentReady audio of each turn goes to whisper.cpp
+// and one timestamped transcript per turn is printed, the end-to-end flow
+// of a voice agent.
+//
+// By default turns are posted to a whisper.cpp server
+// (./build/bin/whisper-server -m models/ggml-base.en.bin):
+//
+//	go run ./examples/transcribe [-server http://127.0.0.1:8080/inference] data/test.wav
+//
+// With the whisper.cpp Go bindings installed (go get
+// github.com/ggerganov/whisper.cpp/bindings/go and libwhisper built, see
+// its README), the model runs in-process instead:
+//
+//	go run -tags whisper ./examples/transcribe -model models/ggml-base.en.bin data/test.wav
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/internal/resolver"
+	"github.com/cortexswarm/smart-turn-go/internal/wav"
+)
+
+const chunkSize = 512
+
+const defaultWAV = "data/test.wav"
+
+var (
+	serverURL = flag.String("server", "http://127.0.0.1:8080/inference", "whisper.cpp server endpoint")
+	modelPath = flag.String("model", "models/ggml-base.en.bin", "whisper.cpp model (-tags whisper only)")
+	language  = flag.String("lang", "en", "spoken language")
+)
+
+func main() {
+	flag.Parse()
+	wavPath := defaultWAV
+	if flag.NArg() > 0 {
+		wavPath = flag.Arg(0)
+	}
+	samples, err := loadWAV(wavPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load WAV: %v\n", err)
+		os.Exit(1)
+	}
+
+	transcriber, closeTranscriber, err := newTranscriber()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "transcriber: %v\n", err)
+		os.Exit(1)
+	}
+	defer closeTranscriber()
+
+	sileroPath, err := resolver.ResolveSileroVAD(resolver.ModelsDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "resolve Silero VAD: %v\n", err)
+		os.Exit(1)
+	}
+	smartTurnPath, err := resolver.ResolveSmartTurn(resolver.ModelsDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "resolve Smart-Turn: %v\n", err)
+		os.Exit(1)
+	}
+	onnxLibPath, err := resolver.ResolveONNXRuntimeLibWithDownload(resolver.ModelsDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "resolve ONNX Runtime lib: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg := smartturn.Config{
+		SampleRate:             16000,
+		ChunkSize:              chunkSize,
+		VadThreshold:           0.5,
+		VadPreSpeechMs:         200,
+		VadStopMs:              800,
+		TurnMaxDurationSeconds: 30,
+		TurnSegmentEmitMs:      1000,
+		TurnThreshold:          0.5,
+		TurnTimeoutMs:          1500,
+		SileroVADModelPath:     sileroPath,
+		SmartTurnModelPath:     smartTurnPath,
+		ONNXRuntimeLibPath:     onnxLibPath,
+		Transcriber:            transcriber,
+	}
+	cb := smartturn.Callbacks{
+		OnSpeechStart: func() { fmt.Println("[speech start]") },
+		OnTurnEnd:     func(r smartturn.TurnEndReason) { fmt.Printf("[turn end: %v]\n", r) },
+		OnTranscript: func(t smartturn.Transcript) {
+			fmt.Printf("turn %d [%s - %s]: %s\n", t.Turn,
+				offset(t.Start), offset(t.End), t.Text)
+		},
+		OnError: func(err error) { fmt.Fprintf(os.Stderr, "[error] %v\n", err) },
+	}
+	engine, err := smartturn.New(cfg, cb)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "New: %v\n", err)
+		os.Exit(1)
+	}
+	defer engine.Close()
+
+	engine.Start()
+	for i := 0; i+chunkSize <= len(samples); i += chunkSize {
+		if err := engine.PushPCM(samples[i : i+chunkSize]); err != nil {
+			fmt.Fprintf(os.Stderr, "PushPCM: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	// Trailing silence lets a final turn end by VadStopMs/TurnTimeoutMs.
+	silence := make([]float32, chunkSize)
+	for i := 0; i < (cfg.VadStopMs+cfg.TurnTimeoutMs)/32+1; i++ {
+		_ = engine.PushPCM(silence)
+	}
+	engine.Stop()
+}
+
+// offset converts a sample offset into a timestamp in the file.
+func offset(samples int64) time.Duration {
+	return (time.Duration(samples) * time.Second / smartturn.RequiredSampleRate).Round(10 * time.Millisecond)
+}
+
+func loadWAV(path string) ([]float32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	samples, rate, err := wav.Read(f)
+	if err != nil {
+		return nil, err
+	}
+	if rate != smartturn.RequiredSampleRate {
+		return nil, fmt.Errorf("%s is %d Hz; resample to 16 kHz first", path, rate)
+	}
+	return samples, nil
+}
@@ -0,0 +1,13 @@
+//go:build !whisper
+
+package main
+
+import (
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/transcribe"
+)
+
+// newTranscriber posts each turn to the whisper.cpp server at -server.
+func newTranscriber() (smartturn.Transcriber, func(), error) {
+	return &transcribe.HTTP{URL: *serverURL, Language: *language}, func() {}, nil
+}
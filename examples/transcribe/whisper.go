@@ -0,0 +1,49 @@
+//go:build whisper
+
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/transcribe"
+	"github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+)
+
+// newTranscriber runs the whisper.cpp model at -model in-process through
+// its Go bindings, one Process call per turn.
+func newTranscriber() (smartturn.Transcriber, func(), error) {
+	model, err := whisper.New(*modelPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, err := model.NewContext()
+	if err != nil {
+		_ = model.Close()
+		return nil, nil, err
+	}
+	if err := ctx.SetLanguage(*language); err != nil {
+		_ = model.Close()
+		return nil, nil, err
+	}
+	t := &transcribe.Buffered{Func: func(audio []float32) (string, error) {
+		if err := ctx.Process(audio, nil, nil, nil); err != nil {
+			return "", err
+		}
+		var text strings.Builder
+		for {
+			seg, err := ctx.NextSegment()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return "", err
+			}
+			text.WriteString(seg.Text)
+		}
+		return strings.TrimSpace(text.String()), nil
+	}}
+	return t, func() { _ = model.Close() }, nil
+}
@@ -9,7 +9,7 @@ import (
 	"os"
 
 	"github.com/cortexswarm/smart-turn-go"
-	"github.com/cortexswarm/smart-turn-go/examples/utility/resolver"
+	"github.com/cortexswarm/smart-turn-go/internal/resolver"
 )
 
 func main() {
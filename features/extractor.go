@@ -0,0 +1,277 @@
+package features
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+var (
+	// ErrEmptyAudio is returned when there is no audio to compute features for.
+	ErrEmptyAudio = errors.New("features: empty audio")
+	// ErrShape is returned when the output slice does not hold Mels*Frames values.
+	ErrShape = errors.New("features: output length does not match the feature shape")
+	// ErrNonFinite is returned when the audio window contains NaN or ±Inf,
+	// which would turn every feature into NaN.
+	ErrNonFinite = errors.New("features: audio contains NaN or Inf")
+)
+
+// Extractor computes log-mel features for windows of a fixed number of frames
+// into caller-provided slices. It keeps all scratch memory between calls, so
+// after the first call Compute does not allocate. An Extractor is not safe for
+// concurrent use; create one per goroutine (the filterbank tables are shared).
+type Extractor struct {
+	p       Params // defaults applied
+	t       *tables
+	workers []worker // workers[0] is used by the serial path
+	cols    []columnPlan
+}
+
+// NewExtractor returns an extractor with the default parameters for windows
+// of frames frames (0 selects Frames, the 8s Smart-Turn v3 context) covering
+// the last frames*HopLength samples.
+func NewExtractor(frames int) *Extractor {
+	e, err := NewExtractorWithParams(Params{Frames: max(frames, 0)})
+	if err != nil {
+		// Only Frames differs from the defaults, and it is >= 0.
+		panic(err)
+	}
+	return e
+}
+
+// NewExtractorWithParams returns an extractor for p (zero fields take defaults).
+func NewExtractorWithParams(p Params) (*Extractor, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	p = p.WithDefaults()
+	t := getTables(p)
+	return &Extractor{
+		p:       p,
+		t:       t,
+		workers: []worker{newWorker(t)},
+		cols:    make([]columnPlan, p.Frames),
+	}, nil
+}
+
+// Params returns the extractor's parameters with defaults applied.
+func (e *Extractor) Params() Params {
+	return e.p
+}
+
+// Shape returns the shape of the features written by Compute.
+func (e *Extractor) Shape() Shape {
+	return e.p.Shape()
+}
+
+// SetWorkers lets Compute spread frames over up to n goroutines (never more
+// than GOMAXPROCS), returning once all are done. n <= 1 computes every frame
+// on the caller's goroutine, which is the default.
+func (e *Extractor) SetWorkers(n int) {
+	n = max(n, 1)
+	for len(e.workers) < n {
+		e.workers = append(e.workers, newWorker(e.t))
+	}
+	e.workers = e.workers[:n]
+}
+
+// Compute writes the log-mel features of the last Frames*HopLength samples
+// of audio into mel, which must have length Mels*Frames and is fully
+// overwritten.
+func (e *Extractor) Compute(mel, audio []float32) error {
+	_, err := e.compute(mel, audio, nil)
+	return err
+}
+
+// columnPlan says how to get the power spectrum of one frame.
+type columnPlan struct {
+	kind   columnKind
+	x      []complex64 // cached or to-be-filled spectrum (columnCached)
+	src    []float32   // raw frame to transform into x on a miss
+	offset int         // frame start in padded-window coordinates; may be negative
+}
+
+type columnKind uint8
+
+const (
+	columnPadding columnKind = iota // entirely zero padding: a constant after normalization
+	columnCached                    // full frame of audio, via a Stream's cache
+	columnDirect                    // normalize, reflect and transform the frame directly
+)
+
+// compute plans every frame serially (consulting the cache of s, if any) and
+// then computes the columns, concurrently when workers are configured. It
+// returns the offset of the window's first sample in audio.
+//
+// Frame t is centered on sample t*hop of the zero-padded window, with
+// n_fft/2 samples of reflect padding beyond both ends of the window, as
+// torch.stft and transformers' spectrogram do with center=True.
+func (e *Extractor) compute(mel, audio []float32, s *Stream) (start int, err error) {
+	nFFT, hop, frames := e.p.NFFT, e.p.HopLength, e.p.Frames
+	if len(mel) != e.p.NMels*frames {
+		return 0, ErrShape
+	}
+	if len(audio) == 0 {
+		return 0, ErrEmptyAudio
+	}
+	nSamples := frames * hop
+	if len(audio) > nSamples {
+		start = len(audio) - nSamples
+	}
+	window := audio[start:]
+	if !finite(window) {
+		return 0, ErrNonFinite
+	}
+	padLen := nSamples - len(window)
+	mean, scale := normalizationParams(window, nSamples)
+	if s != nil && !cacheable(window) {
+		s = nil
+	}
+
+	cols := e.cols[:frames]
+	hits := 0
+	for t := range cols {
+		offset := t*hop - nFFT/2 // in padded-window coordinates
+		c := columnPlan{offset: offset}
+		switch {
+		case offset+nFFT <= padLen && (offset >= 0 || -offset < padLen):
+			// Reflections of padding are padding too.
+			c.kind = columnPadding
+		case offset >= padLen && offset+nFFT <= nSamples && s != nil:
+			c.kind = columnCached
+			off := start - padLen + offset
+			if x, ok := s.spectra[s.base+off]; ok {
+				c.x = x
+				hits++
+			} else {
+				c.x = s.newSpectrum()
+				c.src = audio[off : off+nFFT]
+				s.spectra[s.base+off] = c.x
+			}
+		default:
+			c.kind = columnDirect
+		}
+		cols[t] = c
+	}
+
+	workers := min(len(e.workers), runtime.GOMAXPROCS(0))
+	// Not worth waking goroutines when nearly everything is cached.
+	if workers <= 1 || frames-hits < 2*workers {
+		e.workers[0].computeColumns(mel, window, cols, 0, frames, padLen, mean, scale)
+	} else {
+		var wg sync.WaitGroup
+		per := (frames + workers - 1) / workers
+		for w := 0; w < workers; w++ {
+			lo, hi := w*per, min((w+1)*per, frames)
+			if lo >= hi {
+				break
+			}
+			wg.Go(func() {
+				e.workers[w].computeColumns(mel, window, cols, lo, hi, padLen, mean, scale)
+			})
+		}
+		wg.Wait()
+	}
+	compressLogMel(mel)
+	return start, nil
+}
+
+// maxCachedSample bounds the samples of frames a Stream caches: the FFT
+// gains up to n_fft, and raw spectra are cached as complex64.
+const maxCachedSample = 1e30
+
+// cacheable reports whether the raw spectra of window fit the cache; louder
+// (malformed) audio is computed directly.
+func cacheable(window []float32) bool {
+	for _, v := range window {
+		if v > maxCachedSample || v < -maxCachedSample {
+			return false
+		}
+	}
+	return true
+}
+
+// worker holds the scratch memory for computing log-mel columns. Each
+// goroutine of an Extractor owns one. The Hann window and filterbank are
+// shared read-only tables.
+type worker struct {
+	t       *tables
+	scratch *fftScratch
+	frame   []float32 // windowed frame, n_fft samples
+	power   []float32 // one-sided power spectrum, n_fft/2+1 bins
+}
+
+func newWorker(t *tables) worker {
+	return worker{
+		t:       t,
+		scratch: t.fft.newScratch(),
+		frame:   make([]float32, t.fft.n),
+		power:   make([]float32, t.fft.n/2+1),
+	}
+}
+
+// computeColumns fills mel columns [lo, hi) from the frame plan.
+// window is the (unpadded) audio window, normalized as (x-mean)*scale.
+func (w *worker) computeColumns(mel, window []float32, cols []columnPlan, lo, hi, padLen int, mean, scale float64) {
+	frames := len(cols)
+	nBins := w.t.fft.n/2 + 1
+	for t := lo; t < hi; t++ {
+		c := &cols[t]
+		switch c.kind {
+		case columnPadding:
+			// Padding normalizes to the constant -mean*scale, whose
+			// windowed spectrum is that multiple of FFT(w).
+			p := mean * scale * mean * scale
+			for k := 0; k < nBins; k++ {
+				ws := w.t.windowSpectrum[k]
+				w.power[k] = float32(p * (real(ws)*real(ws) + imag(ws)*imag(ws)))
+			}
+		case columnCached:
+			if c.src != nil {
+				w.spectrumInto(c.x, c.src)
+				c.src = nil
+			}
+			// By linearity, FFT(s·(x−μ)·w) = s·(FFT(x·w) − μ·FFT(w)).
+			for k := 0; k < nBins; k++ {
+				v := complex128(c.x[k]) - complex(mean, 0)*w.t.windowSpectrum[k]
+				w.power[k] = float32(scale * scale * (real(v)*real(v) + imag(v)*imag(v)))
+			}
+		case columnDirect:
+			w.normalizedPower(window, padLen, c.offset, mean, scale)
+		}
+		melFrame(mel, t, frames, w.power, w.t.filters)
+	}
+}
+
+// spectrumInto writes the FFT of the Hann-windowed frame src (n_fft samples)
+// into x (n_fft/2+1 bins).
+func (w *worker) spectrumInto(x []complex64, src []float32) {
+	for i, h := range w.t.hann {
+		w.frame[i] = src[i] * h
+	}
+	w.t.fft.transform(w.frame, w.scratch)
+	for k := range x {
+		x[k] = complex(float32(w.scratch.re[k]), float32(w.scratch.im[k]))
+	}
+}
+
+// normalizedPower writes into w.power the power spectrum of the frame at
+// offset in the window src left-padded with padLen zeros, normalized as
+// (x-mean)*scale and reflected at both ends of the padded window.
+func (w *worker) normalizedPower(src []float32, padLen, offset int, mean, scale float64) {
+	n := padLen + len(src)
+	for i, h := range w.t.hann {
+		j := offset + i
+		if j < 0 {
+			j = -j
+		} else if j >= n {
+			j = 2*(n-1) - j
+		}
+		y := -mean * scale
+		if j >= padLen {
+			y = (float64(src[j-padLen]) - mean) * scale
+		}
+		w.frame[i] = float32(y) * h
+	}
+	w.t.fft.power(w.frame, w.scratch, w.power, 1)
+}
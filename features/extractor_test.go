@@ -0,0 +1,201 @@
+package features
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+// TestComputeAllocs checks that warmed-up extractors and streams do not
+// allocate per call.
+func TestComputeAllocs(t *testing.T) {
+	audio := testAudio(2*Frames*HopLength, 3)
+	mel := make([]float32, NMels*Frames)
+
+	e := NewExtractor(0)
+	if allocs := testing.AllocsPerRun(5, func() {
+		if err := e.Compute(mel, audio); err != nil {
+			t.Fatal(err)
+		}
+	}); allocs != 0 {
+		t.Errorf("Extractor.Compute: %v allocs per call, want 0", allocs)
+	}
+
+	s := NewStream(0)
+	n := Frames * HopLength / 2
+	grow := func() {
+		if err := s.Compute(mel, audio[:n]); err != nil {
+			t.Fatal(err)
+		}
+		n += 512
+	}
+	// Warm the cache past the window so spectra are recycled.
+	for n < len(audio)-20*512 {
+		grow()
+	}
+	if allocs := testing.AllocsPerRun(10, grow); allocs != 0 {
+		t.Errorf("Stream.Compute on a growing stream: %v allocs per call, want 0", allocs)
+	}
+}
+
+// TestWorkersMatchSerial checks that spreading frames over workers gives
+// the serial output bit for bit, for the Extractor and for a Stream whose
+// cache is partly warm.
+func TestWorkersMatchSerial(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+	audio := testAudio(Frames*HopLength+777, 4)
+	want := make([]float32, NMels*Frames)
+	if err := NewExtractor(0).Compute(want, audio); err != nil {
+		t.Fatal(err)
+	}
+	serial := NewStream(0)
+	for _, workers := range []int{2, 3, 4, 8} {
+		e := NewExtractor(0)
+		e.SetWorkers(workers)
+		got := make([]float32, NMels*Frames)
+		if err := e.Compute(got, audio); err != nil {
+			t.Fatal(err)
+		}
+		if d := maxAbsDiff(got, want); d != 0 {
+			t.Errorf("Extractor with %d workers differs from serial by %g", workers, d)
+		}
+
+		s := NewStream(0)
+		s.SetWorkers(workers)
+		serial.Reset()
+		wantS := make([]float32, NMels*Frames)
+		for _, n := range []int{len(audio) / 3, len(audio) / 2, len(audio)} {
+			if err := s.Compute(got, audio[:n]); err != nil {
+				t.Fatal(err)
+			}
+			if err := serial.Compute(wantS, audio[:n]); err != nil {
+				t.Fatal(err)
+			}
+			if d := maxAbsDiff(got, wantS); d != 0 {
+				t.Errorf("Stream with %d workers at %d samples differs from serial by %g", workers, n, d)
+			}
+		}
+	}
+}
+
+// BenchmarkExtractor scores a full 8 s window from scratch with 1 to 4
+// workers; the gain needs as many cores as workers.
+func BenchmarkExtractor(b *testing.B) {
+	audio := testAudio(Frames*HopLength, 5)
+	mel := make([]float32, NMels*Frames)
+	for _, workers := range []int{1, 2, 4} {
+		b.Run("workers="+strconv.Itoa(workers), func(b *testing.B) {
+			e := NewExtractor(0)
+			e.SetWorkers(workers)
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := e.Compute(mel, audio); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestComputeErrors(t *testing.T) {
+	e := NewExtractor(10)
+	mel := make([]float32, NMels*10)
+	if err := e.Compute(mel, nil); err != ErrEmptyAudio {
+		t.Errorf("empty audio: got %v, want ErrEmptyAudio", err)
+	}
+	if err := e.Compute(mel[:1], []float32{1}); err != ErrShape {
+		t.Errorf("short output: got %v, want ErrShape", err)
+	}
+	if err := e.Compute(mel, []float32{0, float32(math.NaN())}); err != ErrNonFinite {
+		t.Errorf("NaN: got %v, want ErrNonFinite", err)
+	}
+	if err := e.Compute(mel, []float32{0, float32(math.Inf(-1))}); err != ErrNonFinite {
+		t.Errorf("-Inf: got %v, want ErrNonFinite", err)
+	}
+	// Only the window counts: non-finite audio before it is ignored.
+	audio := append([]float32{float32(math.NaN())}, testAudio(10*HopLength, 9)...)
+	if err := e.Compute(mel, audio); err != nil {
+		t.Errorf("NaN before the window: %v", err)
+	}
+}
+
+// FuzzExtractor feeds arbitrary float32 audio (little-endian bytes, so
+// NaN, ±Inf, subnormals and extremes included) of any length to the
+// Extractor, a Stream and NormalizeWindow. Finite audio must give finite
+// features, the same from both; anything else must fail with an error.
+func FuzzExtractor(f *testing.F) {
+	for _, seed := range [][]float32{
+		{},
+		{0},
+		{1, -1},
+		{float32(math.NaN()), 0.5},
+		{float32(math.Inf(1))},
+		{float32(math.Inf(-1)), 1},
+		{math.MaxFloat32, -math.MaxFloat32, math.MaxFloat32},
+		{math.SmallestNonzeroFloat32, 0, -math.SmallestNonzeroFloat32},
+		testAudio(3*HopLength, 10),
+		testAudio(20*HopLength+7, 11),
+		scaled(testAudio(20*HopLength, 12), 1e38),
+	} {
+		f.Add(float32Bytes(seed), uint8(4))
+	}
+	f.Fuzz(func(t *testing.T, data []byte, frames uint8) {
+		audio := make([]float32, len(data)/4)
+		for i := range audio {
+			audio[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+		}
+		n := int(frames)%16 + 3 // Frames*HopLength must cover NFFT
+		e := NewExtractor(n)
+		s := NewStream(n)
+		mel := make([]float32, NMels*n)
+		streamed := make([]float32, NMels*n)
+		err := e.Compute(mel, audio)
+		serr := s.Compute(streamed, audio)
+		window := audio[max(len(audio)-n*HopLength, 0):]
+		switch {
+		case len(audio) == 0:
+			if !errors.Is(err, ErrEmptyAudio) || !errors.Is(serr, ErrEmptyAudio) {
+				t.Fatalf("empty audio: %v, %v", err, serr)
+			}
+			return
+		case !finite(window):
+			if !errors.Is(err, ErrNonFinite) || !errors.Is(serr, ErrNonFinite) {
+				t.Fatalf("non-finite window: %v, %v", err, serr)
+			}
+			if NormalizeWindow(make([]float32, n*HopLength), audio) {
+				t.Fatal("NormalizeWindow accepted a non-finite window")
+			}
+			return
+		case err != nil || serr != nil:
+			t.Fatalf("Compute: %v, %v", err, serr)
+		}
+		if !finite(mel) || !finite(streamed) {
+			t.Fatalf("non-finite features of finite audio: %v, %v", mel, streamed)
+		}
+		if d := maxAbsDiff(mel, streamed); d > 1e-3 {
+			t.Fatalf("Stream differs from Extractor by %g", d)
+		}
+		norm := make([]float32, n*HopLength)
+		if !NormalizeWindow(norm, audio) || !finite(norm) {
+			t.Fatalf("NormalizeWindow of finite audio: %v", norm)
+		}
+	})
+}
+
+func float32Bytes(x []float32) []byte {
+	b := make([]byte, 4*len(x))
+	for i, v := range x {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(v))
+	}
+	return b
+}
+
+func scaled(x []float32, k float32) []float32 {
+	for i := range x {
+		x[i] *= k
+	}
+	return x
+}
@@ -0,0 +1,140 @@
+package features
+
+import (
+	"math"
+	"math/bits"
+)
+
+// fftPlan holds the precomputed tables for complex DFTs of size n. Powers of
+// two use an iterative radix-2 FFT; other sizes (Whisper's n_fft=400) use
+// Bluestein's chirp-z algorithm on top of a radix-2 FFT of size m >= 2n-1.
+// A plan is immutable after creation and may be shared; per-call state lives
+// in fftScratch.
+type fftPlan struct {
+	n int
+	m int // radix-2 size; equals n when n is a power of two
+
+	rev      []int     // bit-reversal permutation for size m
+	twRe     []float64 // twiddles exp(-2πik/m), k < m/2
+	twIm     []float64
+	chirpRe  []float64 // w_k = exp(-iπk²/n), k < n (Bluestein only)
+	chirpIm  []float64
+	kernelRe []float64 // FFT of the conjugate chirp, length m (Bluestein only)
+	kernelIm []float64
+}
+
+// fftScratch is per-caller working memory for a plan.
+type fftScratch struct {
+	re, im []float64
+}
+
+func newFFTPlan(n int) *fftPlan {
+	p := &fftPlan{n: n, m: n}
+	if n&(n-1) != 0 {
+		p.m = 1 << bits.Len(uint(2*n-2))
+	}
+	logM := bits.TrailingZeros(uint(p.m))
+	p.rev = make([]int, p.m)
+	for i := range p.rev {
+		p.rev[i] = int(bits.Reverse(uint(i)) >> (bits.UintSize - logM))
+	}
+	p.twRe = make([]float64, p.m/2)
+	p.twIm = make([]float64, p.m/2)
+	for k := range p.twRe {
+		angle := -2 * math.Pi * float64(k) / float64(p.m)
+		p.twRe[k] = math.Cos(angle)
+		p.twIm[k] = math.Sin(angle)
+	}
+	if p.m == n {
+		return p
+	}
+	p.chirpRe = make([]float64, n)
+	p.chirpIm = make([]float64, n)
+	for k := 0; k < n; k++ {
+		// k² mod 2n keeps the angle small and exact for large k.
+		k2 := (k * k) % (2 * n)
+		angle := -math.Pi * float64(k2) / float64(n)
+		p.chirpRe[k] = math.Cos(angle)
+		p.chirpIm[k] = math.Sin(angle)
+	}
+	p.kernelRe = make([]float64, p.m)
+	p.kernelIm = make([]float64, p.m)
+	p.kernelRe[0], p.kernelIm[0] = p.chirpRe[0], -p.chirpIm[0]
+	for k := 1; k < n; k++ {
+		p.kernelRe[k], p.kernelIm[k] = p.chirpRe[k], -p.chirpIm[k]
+		p.kernelRe[p.m-k], p.kernelIm[p.m-k] = p.chirpRe[k], -p.chirpIm[k]
+	}
+	p.radix2(p.kernelRe, p.kernelIm)
+	return p
+}
+
+func (p *fftPlan) newScratch() *fftScratch {
+	return &fftScratch{re: make([]float64, p.m), im: make([]float64, p.m)}
+}
+
+// radix2 is an in-place iterative Cooley-Tukey FFT of size m.
+func (p *fftPlan) radix2(re, im []float64) {
+	m := p.m
+	for i, j := range p.rev {
+		if i < j {
+			re[i], re[j] = re[j], re[i]
+			im[i], im[j] = im[j], im[i]
+		}
+	}
+	for size := 2; size <= m; size <<= 1 {
+		half := size >> 1
+		step := m / size
+		for start := 0; start < m; start += size {
+			for k := 0; k < half; k++ {
+				wr, wi := p.twRe[k*step], p.twIm[k*step]
+				a, b := start+k, start+k+half
+				tr := re[b]*wr - im[b]*wi
+				ti := re[b]*wi + im[b]*wr
+				re[b], im[b] = re[a]-tr, im[a]-ti
+				re[a], im[a] = re[a]+tr, im[a]+ti
+			}
+		}
+	}
+}
+
+// transform computes the DFT of the real input x (len n) into s.re/s.im[:n].
+func (p *fftPlan) transform(x []float32, s *fftScratch) {
+	re, im := s.re, s.im
+	if p.m == p.n {
+		for i, v := range x {
+			re[i], im[i] = float64(v), 0
+		}
+		p.radix2(re, im)
+		return
+	}
+	// Bluestein: X_k = w_k * sum_j (x_j w_j) conj(w_{k-j}), a circular
+	// convolution evaluated with radix-2 FFTs of size m.
+	for j := 0; j < p.n; j++ {
+		re[j], im[j] = float64(x[j])*p.chirpRe[j], float64(x[j])*p.chirpIm[j]
+	}
+	clear(re[p.n:])
+	clear(im[p.n:])
+	p.radix2(re, im)
+	for k := 0; k < p.m; k++ {
+		r := re[k]*p.kernelRe[k] - im[k]*p.kernelIm[k]
+		i := re[k]*p.kernelIm[k] + im[k]*p.kernelRe[k]
+		// Conjugate so the forward FFT below acts as an inverse.
+		re[k], im[k] = r, -i
+	}
+	p.radix2(re, im)
+	invM := 1 / float64(p.m)
+	for k := 0; k < p.n; k++ {
+		cr, ci := re[k]*invM, -im[k]*invM
+		re[k] = cr*p.chirpRe[k] - ci*p.chirpIm[k]
+		im[k] = cr*p.chirpIm[k] + ci*p.chirpRe[k]
+	}
+}
+
+// power writes |X_k|²/norm for k <= n/2 (the one-sided power spectrum of
+// the real frame x) into power.
+func (p *fftPlan) power(x []float32, s *fftScratch, power []float32, norm float64) {
+	p.transform(x, s)
+	for k := 0; k <= p.n/2; k++ {
+		power[k] = float32((s.re[k]*s.re[k] + s.im[k]*s.im[k]) / norm)
+	}
+}
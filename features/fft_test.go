@@ -0,0 +1,103 @@
+package features
+
+import (
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"testing"
+)
+
+// naiveDFT is the O(n²) DFT the FFT replaced.
+func naiveDFT(x []float32) (re, im []float64) {
+	n := len(x)
+	re, im = make([]float64, n), make([]float64, n)
+	for k := 0; k < n; k++ {
+		for j, v := range x {
+			angle := -2 * math.Pi * float64((k*j)%n) / float64(n)
+			re[k] += float64(v) * math.Cos(angle)
+			im[k] += float64(v) * math.Sin(angle)
+		}
+	}
+	return re, im
+}
+
+func TestFFTMatchesDFT(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for _, n := range []int{1, 2, 7, 400, 512} {
+		x := make([]float32, n)
+		for i := range x {
+			x[i] = float32(rng.NormFloat64())
+		}
+		wantRe, wantIm := naiveDFT(x)
+		p := newFFTPlan(n)
+		s := p.newScratch()
+		p.transform(x, s)
+		// Rounding grows with n and the magnitude of the sums (~sqrt(n)).
+		tol := 1e-9 * float64(n)
+		for k := 0; k < n; k++ {
+			if d := math.Hypot(s.re[k]-wantRe[k], s.im[k]-wantIm[k]); d > tol {
+				t.Fatalf("n=%d: X[%d] = %g%+gi, want %g%+gi (|diff| %g > %g)",
+					n, k, s.re[k], s.im[k], wantRe[k], wantIm[k], d, tol)
+			}
+		}
+
+		power := make([]float32, n/2+1)
+		p.power(x, s, power, float64(n*n))
+		for k := range power {
+			want := (wantRe[k]*wantRe[k] + wantIm[k]*wantIm[k]) / float64(n*n)
+			if math.Abs(float64(power[k])-want) > 1e-5*math.Max(1, want) {
+				t.Fatalf("n=%d: power[%d] = %g, want %g", n, k, power[k], want)
+			}
+		}
+	}
+}
+
+// TestFFTPlanReuse checks that a plan's scratch carries no state between
+// transforms.
+func TestFFTPlanReuse(t *testing.T) {
+	p := newFFTPlan(400)
+	s := p.newScratch()
+	a := make([]float32, 400)
+	b := make([]float32, 400)
+	for i := range a {
+		a[i] = float32(math.Sin(float64(i)))
+		b[i] = float32(i % 3)
+	}
+	p.transform(a, s)
+	first := append([]float64(nil), s.re[:400]...)
+	p.transform(b, s)
+	p.transform(a, s)
+	for k := range first {
+		if s.re[k] != first[k] {
+			t.Fatalf("re[%d] = %g after reuse, want %g", k, s.re[k], first[k])
+		}
+	}
+}
+
+func BenchmarkFFT(b *testing.B) {
+	for _, n := range []int{400, 512} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			p := newFFTPlan(n)
+			s := p.newScratch()
+			x := make([]float32, n)
+			for i := range x {
+				x[i] = float32(math.Sin(float64(i) * 0.1))
+			}
+			b.ReportAllocs()
+			for b.Loop() {
+				p.transform(x, s)
+			}
+		})
+	}
+}
+
+// BenchmarkNaiveDFT is the baseline BenchmarkFFT improves on.
+func BenchmarkNaiveDFT(b *testing.B) {
+	x := make([]float32, NFFT)
+	for i := range x {
+		x[i] = float32(math.Sin(float64(i) * 0.1))
+	}
+	for b.Loop() {
+		naiveDFT(x)
+	}
+}
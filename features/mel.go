@@ -0,0 +1,199 @@
+// Package features implements the Whisper log-mel front end used by
+// Smart-Turn v3, matched to transformers.WhisperFeatureExtractor as the
+// Smart-Turn v3 reference inference calls it, so that Whisper-family ONNX
+// models can be fed from Go:
+//   - 16 kHz mono audio; the last Frames*HopLength samples are used
+//     (longer input is truncated, shorter input is left-padded with zeros)
+//   - zero-mean, unit-variance normalization of that window, padding
+//     included, as do_normalize=True: (x - mean) / sqrt(var + 1e-7)
+//   - STFT: n_fft=400, hop=160, periodic Hann window, power=2 (|X|², not
+//     scaled by 1/n²), centered frames with n_fft/2 samples of reflect
+//     padding at both ends, the last of the 1+len/hop frames dropped
+//   - mel filterbank: 80 triangular bins, 0–8000 Hz, Slaney mel scale and
+//     Slaney (area) normalization
+//   - log10 mel floored at 1e-10, then global dynamic range compression
+//     log_spec = (max(log_spec, log_spec.max()-8) + 4) / 4
+//
+// testdata/whisper holds reference outputs written by
+// scripts/whisper_mel_reference.py, which TestWhisperParity checks.
+//
+// Features are laid out row-major as (NMels, frames), the layout of a
+// (1, 80, frames) input_features tensor. ComputeLogMel is the one-shot entry
+// point; Extractor and Stream reuse their buffers across calls. Params
+// changes n_fft, window length, hop, mel bins, and context length for
+// fine-tuned variants; the sample rate and 0–8000 Hz band are fixed.
+package features
+
+import "math"
+
+// Whisper mel params (16kHz): n_fft=400, hop=160, n_mels=80. These are the
+// defaults; see Params for other Whisper-family configurations.
+const (
+	SampleRate = 16000
+	NFFT       = 400
+	HopLength  = 160
+	NMels      = 80
+	// Frames is the 8s context of Smart-Turn v3 (800 frames of 10ms).
+	Frames = 800
+)
+
+// Shape is the (mel bins, frames) shape of a feature matrix.
+type Shape struct {
+	Mels   int
+	Frames int
+}
+
+// ComputeLogMel returns the (80, 800) log-mel features of the last 8s of
+// audio (16 kHz mono), or nil for empty audio or audio containing NaN/Inf. It allocates on every call;
+// use an Extractor to compute features repeatedly.
+func ComputeLogMel(audio []float32) ([]float32, Shape) {
+	shape := Shape{Mels: NMels, Frames: Frames}
+	mel := make([]float32, shape.Mels*shape.Frames)
+	if err := NewExtractor(Frames).Compute(mel, audio); err != nil {
+		return nil, Shape{}
+	}
+	return mel, shape
+}
+
+// NormalizeWindow writes the last len(dst) samples of audio into dst,
+// left-padded with zeros, with zero-mean, unit-variance normalization over
+// all of dst. This is the waveform preprocessing applied before the STFT,
+// also used on its own by models that take raw audio. Returns false for
+// empty input or input containing NaN or ±Inf.
+func NormalizeWindow(dst, audio []float32) bool {
+	nSamples := len(dst)
+	if len(audio) == 0 || nSamples == 0 {
+		return false
+	}
+	// Take last nSamples (or full audio if shorter) for normalization.
+	if len(audio) > nSamples {
+		audio = audio[len(audio)-nSamples:]
+	}
+	if !finite(audio) {
+		return false
+	}
+	mean, scale := normalizationParams(audio, nSamples)
+
+	offset := nSamples - len(audio)
+	pad := float32(-mean * scale)
+	for i := range dst[:offset] {
+		dst[i] = pad
+	}
+	for i := 0; i < len(audio); i++ {
+		dst[offset+i] = float32((float64(audio[i]) - mean) * scale)
+	}
+	return true
+}
+
+// melFrame projects one power spectrum onto the mel filterbank and writes
+// log10 energies (floored at 1e-10) into column t of mel (nMels, frames).
+func melFrame(mel []float32, t, frames int, power, filters []float32) {
+	nBins := len(power)
+	nMels := len(filters) / nBins
+	for m := 0; m < nMels; m++ {
+		var v float32
+		for k := 0; k < nBins; k++ {
+			v += filters[m*nBins+k] * power[k]
+		}
+		if v < 1e-10 {
+			v = 1e-10
+		}
+		// log10 mel
+		mel[m*frames+t] = float32(math.Log10(float64(v)))
+	}
+}
+
+// compressLogMel applies Whisper's global dynamic range compression and scaling:
+// log_spec = max(log_spec, log_spec.max()-8)
+// log_spec = (log_spec + 4) / 4
+func compressLogMel(mel []float32) {
+	maxVal := float32(-1e30)
+	for i := range mel {
+		if mel[i] > maxVal {
+			maxVal = mel[i]
+		}
+	}
+	floor := maxVal - 8.0
+	for i := range mel {
+		if mel[i] < floor {
+			mel[i] = floor
+		}
+		mel[i] = (mel[i] + 4.0) / 4.0
+	}
+}
+
+// newMelFilterbank returns librosa/transformers mel_filter_bank(nBins,
+// nMels, 0, 8000, 16000, norm="slaney", mel_scale="slaney") as rows
+// (nMels, nBins): triangles between mel-spaced edges, each scaled to unit
+// area by 2/(right-left).
+func newMelFilterbank(nMels, nBins int) []float32 {
+	const sampleRate, lowFreq, highFreq = 16000.0, 0.0, 8000.0
+	lowMel := hzToMel(lowFreq)
+	highMel := hzToMel(highFreq)
+	hzPoints := make([]float64, nMels+2)
+	for i := range hzPoints {
+		hzPoints[i] = melToHz(lowMel + (highMel-lowMel)*float64(i)/float64(nMels+1))
+	}
+	filters := make([]float32, nMels*nBins)
+	for m := 0; m < nMels; m++ {
+		left, center, right := hzPoints[m], hzPoints[m+1], hzPoints[m+2]
+		enorm := 2 / (right - left)
+		for k := 0; k < nBins; k++ {
+			f := float64(k) * sampleRate / 2 / float64(nBins-1)
+			v := math.Min((f-left)/(center-left), (right-f)/(right-center))
+			filters[m*nBins+k] = float32(math.Max(v, 0) * enorm)
+		}
+	}
+	return filters
+}
+
+// Slaney mel scale: linear below 1 kHz, logarithmic above.
+const (
+	slaneyMinLogHz  = 1000.0
+	slaneyMinLogMel = 15.0
+	slaneyHzPerMel  = 200.0 / 3
+)
+
+var slaneyLogStep = math.Log(6.4) / 27
+
+func hzToMel(hz float64) float64 {
+	if hz < slaneyMinLogHz {
+		return hz / slaneyHzPerMel
+	}
+	return slaneyMinLogMel + math.Log(hz/slaneyMinLogHz)/slaneyLogStep
+}
+
+func melToHz(mel float64) float64 {
+	if mel < slaneyMinLogMel {
+		return mel * slaneyHzPerMel
+	}
+	return slaneyMinLogHz * math.Exp(slaneyLogStep*(mel-slaneyMinLogMel))
+}
+
+// finite reports whether audio holds no NaN or ±Inf.
+func finite(audio []float32) bool {
+	for _, v := range audio {
+		// v-v is NaN for both NaN and ±Inf.
+		if v-v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizationParams returns the mean and 1/sqrt(var+1e-7) of audio
+// left-padded with zeros to n samples, as do_normalize computes them.
+func normalizationParams(audio []float32, n int) (mean, scale float64) {
+	var sum, sumSq float64
+	for _, v := range audio {
+		x := float64(v)
+		sum += x
+		sumSq += x * x
+	}
+	mean = sum / float64(n)
+	variance := sumSq/float64(n) - mean*mean
+	if variance < 0 {
+		variance = 0
+	}
+	return mean, 1.0 / math.Sqrt(variance+1e-7)
+}
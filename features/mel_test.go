@@ -0,0 +1,84 @@
+package features
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cortexswarm/smart-turn-go/internal/npy"
+)
+
+// TestWhisperParity compares the pipeline with the WhisperFeatureExtractor
+// fixtures in testdata/whisper (1 s windows, see
+// scripts/whisper_mel_reference.py), through the Extractor and through a
+// Stream fed the clip in chunks.
+func TestWhisperParity(t *testing.T) {
+	refs, err := filepath.Glob(filepath.Join("testdata", "whisper", "*.mel.npy"))
+	if err != nil || len(refs) == 0 {
+		t.Fatalf("no fixtures in testdata/whisper: %v", err)
+	}
+	const tol = 1e-4
+	for _, ref := range refs {
+		name := strings.TrimSuffix(filepath.Base(ref), ".mel.npy")
+		t.Run(name, func(t *testing.T) {
+			audio, _ := readNPY(t, filepath.Join("testdata", "whisper", name+".audio.npy"))
+			want, shape := readNPY(t, ref)
+			if len(shape) != 2 || shape[0] != NMels {
+				t.Fatalf("fixture shape %v", shape)
+			}
+			got := make([]float32, len(want))
+			if err := NewExtractor(shape[1]).Compute(got, audio); err != nil {
+				t.Fatal(err)
+			}
+			if d, at := worstDiff(got, want); d > tol {
+				t.Errorf("Extractor: |diff| %.3g at (mel %d, frame %d)", d, at/shape[1], at%shape[1])
+			}
+
+			s := NewStream(shape[1])
+			for n := 0; n < len(audio); {
+				n = min(n+512, len(audio))
+				if err := s.Compute(got, audio[:n]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if d, at := worstDiff(got, want); d > tol {
+				t.Errorf("Stream: |diff| %.3g at (mel %d, frame %d)", d, at/shape[1], at%shape[1])
+			}
+		})
+	}
+}
+
+func readNPY(t *testing.T, path string) ([]float32, []int) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, shape, err := npy.Read(f)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return data, shape
+}
+
+// worstDiff returns the largest absolute difference and its index.
+func worstDiff(got, want []float32) (float64, int) {
+	worst, at := 0.0, 0
+	for i := range got {
+		if d := math.Abs(float64(got[i]) - float64(want[i])); d > worst {
+			worst, at = d, i
+		}
+	}
+	return worst, at
+}
+
+func BenchmarkComputeLogMel(b *testing.B) {
+	audio := testAudio(Frames*HopLength, 7)
+	b.ReportAllocs()
+	for b.Loop() {
+		ComputeLogMel(audio)
+	}
+}
@@ -0,0 +1,129 @@
+package features
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Params configures the feature pipeline. Zero fields take the Whisper /
+// Smart-Turn v3 defaults (the package constants).
+type Params struct {
+	NFFT int // FFT size (default 400)
+	// WindowLength is the Hann window length, centered in and zero-padded to
+	// NFFT as torch.stft does with win_length < n_fft (default NFFT).
+	WindowLength int
+	HopLength    int // samples between frames (default 160)
+	NMels        int // mel bins (default 80)
+	Frames       int // frames per window; the context is Frames*HopLength samples (default 800 = 8s)
+}
+
+// WithDefaults returns p with zero fields set to the defaults.
+func (p Params) WithDefaults() Params {
+	if p.NFFT == 0 {
+		p.NFFT = NFFT
+	}
+	if p.WindowLength == 0 {
+		p.WindowLength = p.NFFT
+	}
+	if p.HopLength == 0 {
+		p.HopLength = HopLength
+	}
+	if p.NMels == 0 {
+		p.NMels = NMels
+	}
+	if p.Frames == 0 {
+		p.Frames = Frames
+	}
+	return p
+}
+
+// Validate reports whether p (after defaults) describes a usable pipeline.
+func (p Params) Validate() error {
+	p = p.WithDefaults()
+	switch {
+	case p.NFFT < 2:
+		return errors.New("features: NFFT must be >= 2")
+	case p.WindowLength < 1 || p.WindowLength > p.NFFT:
+		return fmt.Errorf("features: WindowLength must be in [1, NFFT=%d]", p.NFFT)
+	case p.HopLength < 1:
+		return errors.New("features: HopLength must be >= 1")
+	case p.NMels < 1 || p.NMels > p.NFFT/2+1:
+		return fmt.Errorf("features: NMels must be in [1, NFFT/2+1=%d]", p.NFFT/2+1)
+	case p.Frames < 1:
+		return errors.New("features: Frames must be >= 1")
+	case p.Frames*p.HopLength < p.NFFT:
+		return errors.New("features: Frames*HopLength must cover at least one NFFT window")
+	}
+	return nil
+}
+
+// Shape returns the shape of the features produced with p.
+func (p Params) Shape() Shape {
+	p = p.WithDefaults()
+	return Shape{Mels: p.NMels, Frames: p.Frames}
+}
+
+// WindowSamples returns the number of trailing audio samples a window covers.
+func (p Params) WindowSamples() int {
+	p = p.WithDefaults()
+	return p.Frames * p.HopLength
+}
+
+// tables are the immutable per-parameter DSP tables (FFT plan, window,
+// filterbank). They are built once per parameter set and shared read-only by
+// every Extractor; the cache is guarded because extractors may be created
+// concurrently.
+type tables struct {
+	fft     *fftPlan
+	hann    []float32 // length NFFT
+	filters []float32 // (NMels, NFFT/2+1)
+	// windowSpectrum is FFT(w) of the window, used by Stream to apply the
+	// window mean to cached spectra.
+	windowSpectrum []complex128
+}
+
+type tablesKey struct {
+	nFFT, winLength, nMels int
+}
+
+var (
+	tablesMu    sync.Mutex
+	tablesCache = map[tablesKey]*tables{}
+)
+
+// getTables returns the tables for p, which must have defaults applied.
+func getTables(p Params) *tables {
+	key := tablesKey{p.NFFT, p.WindowLength, p.NMels}
+	tablesMu.Lock()
+	defer tablesMu.Unlock()
+	if t, ok := tablesCache[key]; ok {
+		return t
+	}
+	nBins := p.NFFT/2 + 1
+	t := &tables{
+		fft:     newFFTPlan(p.NFFT),
+		hann:    newHannWindow(p.NFFT, p.WindowLength),
+		filters: newMelFilterbank(p.NMels, nBins),
+	}
+	s := t.fft.newScratch()
+	t.fft.transform(t.hann, s)
+	t.windowSpectrum = make([]complex128, nBins)
+	for k := range t.windowSpectrum {
+		t.windowSpectrum[k] = complex(s.re[k], s.im[k])
+	}
+	tablesCache[key] = t
+	return t
+}
+
+// newHannWindow returns a periodic Hann window of length winLength centered
+// in nFFT samples of zeros.
+func newHannWindow(nFFT, winLength int) []float32 {
+	w := make([]float32, nFFT)
+	left := (nFFT - winLength) / 2
+	for i := 0; i < winLength; i++ {
+		w[left+i] = float32(0.5 * (1 - math.Cos(2*math.Pi*float64(i)/float64(winLength))))
+	}
+	return w
+}
@@ -0,0 +1,78 @@
+package features
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestConcurrentExtractors builds extractors and streams with different
+// parameters from many goroutines at once, so that -race catches
+// unsynchronized access to the shared tables, and checks that every result
+// matches one computed serially.
+func TestConcurrentExtractors(t *testing.T) {
+	params := []Params{
+		{Frames: 50},
+		{NFFT: 512, Frames: 60},
+		{NFFT: 512, WindowLength: 400, Frames: 60},
+		{NFFT: 256, HopLength: 128, NMels: 40, Frames: 40},
+		{NMels: 128, Frames: 30},
+		{NFFT: 200, WindowLength: 120, HopLength: 80, NMels: 64, Frames: 70},
+	}
+	audio := testAudio(8000, 6)
+	want := make([][]float32, len(params))
+	for i, p := range params {
+		e, err := NewExtractorWithParams(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := e.Shape()
+		want[i] = make([]float32, s.Mels*s.Frames)
+		if err := e.Compute(want[i], audio); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tablesMu.Lock()
+	clear(tablesCache)
+	tablesMu.Unlock()
+
+	const rounds = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, rounds*len(params))
+	for r := 0; r < rounds; r++ {
+		for i, p := range params {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var (
+					c interface {
+						Compute(mel, audio []float32) error
+					}
+					err error
+				)
+				if r%2 == 0 {
+					c, err = NewExtractorWithParams(p)
+				} else {
+					c, err = NewStreamWithParams(p)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+				got := make([]float32, len(want[i]))
+				if err := c.Compute(got, audio); err != nil {
+					errs <- err
+					return
+				}
+				if d := maxAbsDiff(got, want[i]); d > 1e-4 {
+					t.Errorf("%+v (round %d): differs from serial by %g", p, r, d)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
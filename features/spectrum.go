@@ -0,0 +1,33 @@
+package features
+
+// Spectrum computes Hann-windowed power spectra of fixed-size frames, for
+// audio heuristics outside the mel pipeline (e.g. spectral flatness). It
+// reuses its buffers and is not safe for concurrent use.
+type Spectrum struct {
+	plan    *fftPlan
+	scratch *fftScratch
+	hann    []float32
+	frame   []float32
+}
+
+// NewSpectrum returns a Spectrum for frames of n samples.
+func NewSpectrum(n int) *Spectrum {
+	p := newFFTPlan(n)
+	return &Spectrum{plan: p, scratch: p.newScratch(), hann: newHannWindow(n, n), frame: make([]float32, n)}
+}
+
+// Bins is the number of power values Power writes: n/2 + 1.
+func (s *Spectrum) Bins() int { return s.plan.n/2 + 1 }
+
+// Power writes the one-sided power spectrum of frame (n samples), bin k
+// at k·sampleRate/n Hz and scaled by 1/n², into dst[:Bins()] and returns
+// it.
+func (s *Spectrum) Power(dst, frame []float32) []float32 {
+	for i, h := range s.hann {
+		s.frame[i] = frame[i] * h
+	}
+	dst = dst[:s.Bins()]
+	n := float64(s.plan.n)
+	s.plan.power(s.frame, s.scratch, dst, n*n)
+	return dst
+}
@@ -0,0 +1,66 @@
+package features
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestSpectrumMatchesDFT(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	const n = 512
+	s := NewSpectrum(n)
+	if s.Bins() != n/2+1 {
+		t.Fatalf("Bins() = %d, want %d", s.Bins(), n/2+1)
+	}
+	frame := make([]float32, n)
+	for i := range frame {
+		frame[i] = float32(rng.NormFloat64())
+	}
+	hann := newHannWindow(n, n)
+	windowed := make([]float32, n)
+	for i := range frame {
+		windowed[i] = frame[i] * hann[i]
+	}
+	re, im := naiveDFT(windowed)
+	orig := slices.Clone(frame)
+	dst := make([]float32, n) // longer than Bins: Power reslices it
+	p := s.Power(dst, frame)
+	if len(p) != s.Bins() {
+		t.Fatalf("len(Power()) = %d, want %d", len(p), s.Bins())
+	}
+	for k := range p {
+		want := (re[k]*re[k] + im[k]*im[k]) / (n * n)
+		if math.Abs(float64(p[k])-want) > 1e-6*math.Max(want, 1e-3) {
+			t.Fatalf("bin %d: power %g, want %g", k, p[k], want)
+		}
+	}
+	// The input frame is left alone.
+	if !slices.Equal(frame, orig) {
+		t.Error("Power modified the caller's frame")
+	}
+}
+
+// TestSpectrumTone checks the scale of a bin-centred sinusoid: the Hann
+// window sums to n/2, so amplitude A gives A²/16 at its bin.
+func TestSpectrumTone(t *testing.T) {
+	const n, k, a = 512, 32, 0.5 // bin 32 is 1 kHz at 16 kHz
+	s := NewSpectrum(n)
+	frame := make([]float32, n)
+	for i := range frame {
+		frame[i] = a * float32(math.Cos(2*math.Pi*k*float64(i)/n))
+	}
+	p := s.Power(make([]float32, s.Bins()), frame)
+	if want := a * a / 16; math.Abs(float64(p[k])-want) > 1e-6 {
+		t.Errorf("power at the tone's bin %g, want %g", p[k], want)
+	}
+	for j, v := range p {
+		if (j < k-1 || j > k+1) && v > 1e-9 {
+			t.Errorf("bin %d: power %g leaked from bin %d", j, v, k)
+		}
+	}
+	if allocs := testing.AllocsPerRun(100, func() { s.Power(p, frame) }); allocs != 0 {
+		t.Errorf("Power allocates %v times per frame", allocs)
+	}
+}
@@ -0,0 +1,126 @@
+package features
+
+// Stream computes log-mel windows over one growing audio stream (e.g. a
+// speech segment that is scored more than once) and only runs the FFT for
+// frames it has not seen before.
+//
+// The complex STFT of every full frame of raw (un-normalized) audio is cached
+// by its sample offset in the stream. The window's zero-mean, unit-variance
+// normalization changes on every call, but the DFT is linear: for
+// y = s·(x − μ), FFT(y·w) = s·(FFT(x·w) − μ·FFT(w)). A new mean/scale is
+// therefore applied to cached spectra instead of invalidating them. Frames
+// that straddle the zero-padding boundary or reach into the reflect padding
+// at either end of the window are computed directly.
+//
+// Each Compute must be passed the whole stream so far, a continuation of the
+// audio of the previous call, less any samples dropped from its start with
+// Drop; call Reset before starting a different stream, otherwise stale
+// spectra are reused. Not safe for concurrent use.
+type Stream struct {
+	ext     *Extractor
+	spectra map[int][]complex64 // stream offset -> X_t (n_fft/2+1 bins)
+	free    [][]complex64       // evicted spectra kept for reuse
+	base    int                 // stream offset of the first sample passed to Compute
+}
+
+const (
+	// streamMaxGrids bounds the cache to this many windows' worth of frames.
+	// A stream fed in 512-sample chunks cycles through 5 grids (lcm(512, 160)/512).
+	streamMaxGrids = 5
+	// spectraSlab is how many spectra are allocated at once when the free
+	// list runs dry, so warming the cache is a handful of allocations.
+	spectraSlab = 64
+)
+
+// NewStream returns a stream with the default parameters for windows of
+// frames frames (0 selects Frames).
+func NewStream(frames int) *Stream {
+	return newStream(NewExtractor(frames))
+}
+
+// NewStreamWithParams returns a stream for p (zero fields take defaults).
+func NewStreamWithParams(p Params) (*Stream, error) {
+	e, err := NewExtractorWithParams(p)
+	if err != nil {
+		return nil, err
+	}
+	return newStream(e), nil
+}
+
+// newStream wraps e, with the cache index sized for its bound up front.
+func newStream(e *Extractor) *Stream {
+	return &Stream{
+		ext:     e,
+		spectra: make(map[int][]complex64, streamMaxGrids*e.p.Frames),
+	}
+}
+
+// Params returns the stream's parameters with defaults applied.
+func (s *Stream) Params() Params {
+	return s.ext.p
+}
+
+// Shape returns the shape of the features written by Compute.
+func (s *Stream) Shape() Shape {
+	return s.ext.Shape()
+}
+
+// SetWorkers is Extractor.SetWorkers.
+func (s *Stream) SetWorkers(n int) {
+	s.ext.SetWorkers(n)
+}
+
+// Reset drops all cached frames; their buffers are kept for reuse.
+func (s *Stream) Reset() {
+	for off, x := range s.spectra {
+		s.free = append(s.free, x)
+		delete(s.spectra, off)
+	}
+	s.base = 0
+}
+
+// Drop records that the first n samples of the stream were discarded, as
+// by a capped buffer: the next Compute is passed the audio after them.
+// Cached frames of the remaining audio stay valid.
+func (s *Stream) Drop(n int) {
+	s.base += n
+}
+
+// Compute writes the log-mel features of the last Frames*HopLength samples
+// of audio, the whole stream so far, into mel (length Mels*Frames).
+func (s *Stream) Compute(mel, audio []float32) error {
+	start, err := s.ext.compute(mel, audio, s)
+	if err != nil {
+		return err
+	}
+	// Frames before the window can never be reused: the stream only grows.
+	// The frame grid is anchored at the end of the audio, so streams that grow
+	// by a non-multiple of the hop leave several interleaved grids in the
+	// cache; past streamMaxGrids of them, keep only the current one.
+	p := s.ext.p
+	start += s.base
+	origin := s.base + len(audio) - p.Frames*p.HopLength - p.NFFT/2
+	overfull := len(s.spectra) > streamMaxGrids*p.Frames
+	for off, x := range s.spectra {
+		if off < start || overfull && (off-origin)%p.HopLength != 0 {
+			s.free = append(s.free, x)
+			delete(s.spectra, off)
+		}
+	}
+	return nil
+}
+
+// newSpectrum takes a spectrum buffer from the free list, refilling it with a
+// slab when empty.
+func (s *Stream) newSpectrum() []complex64 {
+	if len(s.free) == 0 {
+		nBins := s.ext.p.NFFT/2 + 1
+		slab := make([]complex64, spectraSlab*nBins)
+		for i := 0; i < spectraSlab; i++ {
+			s.free = append(s.free, slab[i*nBins:(i+1)*nBins:(i+1)*nBins])
+		}
+	}
+	x := s.free[len(s.free)-1]
+	s.free = s.free[:len(s.free)-1]
+	return x
+}
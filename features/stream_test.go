@@ -0,0 +1,147 @@
+package features
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// testAudio returns n samples of a noisy tone with a slow envelope, so
+// frames differ and the window statistics change as a stream grows.
+func testAudio(n int, seed uint64) []float32 {
+	rng := rand.New(rand.NewPCG(seed, 7))
+	x := make([]float32, n)
+	for i := range x {
+		t := float64(i) / SampleRate
+		env := 0.5 + 0.4*math.Sin(2*math.Pi*3*t)
+		x[i] = float32(env*0.3*math.Sin(2*math.Pi*(180+40*math.Sin(t))*t) + 0.02*rng.NormFloat64())
+	}
+	return x
+}
+
+func maxAbsDiff(a, b []float32) float64 {
+	var d float64
+	for i := range a {
+		d = math.Max(d, math.Abs(float64(a[i])-float64(b[i])))
+	}
+	return d
+}
+
+// TestStreamMatchesExtractor grows a stream in 512-sample chunks, past the
+// window so frames are evicted, and compares every score with a fresh
+// computation.
+func TestStreamMatchesExtractor(t *testing.T) {
+	const frames = 100
+	audio := testAudio(3*frames*HopLength, 1)
+	s := NewStream(frames)
+	e := NewExtractor(frames)
+	got := make([]float32, NMels*frames)
+	want := make([]float32, NMels*frames)
+	for n := 512; n <= len(audio); n += 512 {
+		if err := s.Compute(got, audio[:n]); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Compute(want, audio[:n]); err != nil {
+			t.Fatal(err)
+		}
+		if d := maxAbsDiff(got, want); d > 1e-4 {
+			t.Fatalf("%d samples: stream differs from extractor by %g", n, d)
+		}
+	}
+	if len(s.spectra) > streamMaxGrids*frames {
+		t.Errorf("cache holds %d spectra, bound is %d", len(s.spectra), streamMaxGrids*frames)
+	}
+
+	// After Reset, a different stream must not reuse stale spectra.
+	s.Reset()
+	other := testAudio(frames*HopLength, 2)
+	if err := s.Compute(got, other); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Compute(want, other); err != nil {
+		t.Fatal(err)
+	}
+	if d := maxAbsDiff(got, want); d > 1e-4 {
+		t.Fatalf("after Reset: stream differs from extractor by %g", d)
+	}
+}
+
+// TestStreamDrop checks that cached frames survive audio dropped from the
+// start of the stream, as a capped segment buffer evicts it.
+func TestStreamDrop(t *testing.T) {
+	const frames = 100
+	audio := testAudio(4*frames*HopLength, 3)
+	s := NewStream(frames)
+	e := NewExtractor(frames)
+	got := make([]float32, NMels*frames)
+	want := make([]float32, NMels*frames)
+	dropped := 0
+	for n := 512; n <= len(audio); n += 512 {
+		// Keep at most 1.5 windows, dropping a whole number of chunks
+		// at a time, not of hops.
+		if n-dropped > 3*frames*HopLength/2 {
+			cached := len(s.spectra)
+			s.Drop(4 * 512)
+			dropped += 4 * 512
+			// The audio so far, less the dropped samples, is all cached.
+			if err := s.Compute(got, audio[dropped:n-512]); err != nil {
+				t.Fatal(err)
+			}
+			if len(s.spectra) != cached {
+				t.Fatalf("%d samples: %d spectra after Drop, want the %d cached", n, len(s.spectra), cached)
+			}
+		}
+		if err := s.Compute(got, audio[dropped:n]); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Compute(want, audio[dropped:n]); err != nil {
+			t.Fatal(err)
+		}
+		if d := maxAbsDiff(got, want); d > 1e-4 {
+			t.Fatalf("%d samples, %d dropped: stream differs from extractor by %g", n, dropped, d)
+		}
+	}
+	if dropped == 0 {
+		t.Fatal("nothing dropped")
+	}
+
+	// Reset starts the next stream at offset 0.
+	s.Reset()
+	other := testAudio(frames*HopLength, 4)
+	if err := s.Compute(got, other); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Compute(want, other); err != nil {
+		t.Fatal(err)
+	}
+	if d := maxAbsDiff(got, want); d > 1e-4 {
+		t.Fatalf("after Reset: stream differs from extractor by %g", d)
+	}
+}
+
+// BenchmarkStream measures a window over a segment that grew by one
+// 512-sample chunk since the last call, as when it is rescored.
+func BenchmarkStream(b *testing.B) {
+	const chunk = 512
+	audio := testAudio(2*Frames*HopLength, 8)
+	s := NewStream(0)
+	mel := make([]float32, NMels*Frames)
+	// One pass over the audio warms the spectra cache and its free list.
+	n := Frames * HopLength
+	for ; n+chunk <= len(audio); n += chunk {
+		if err := s.Compute(mel, audio[:n+chunk]); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		if n+chunk > len(audio) {
+			s.Reset()
+			n = Frames * HopLength
+		}
+		n += chunk
+		if err := s.Compute(mel, audio[:n]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package smartturn
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+var errNegativeGap = errors.New("smart-turn: gap must be >= 0")
+
+// PushGap reports d of audio lost before the next chunk, e.g. packets the
+// network dropped or a stream that stalled. The engine advances through
+// the gap as non-speech: silence timers (VadStopMs, TurnTimeoutMs,
+// TurnMergeGapMs, WakeWordTimeoutMs) run, segments and sample offsets
+// keep their timing with zeros in place of the audio, and VAD neither
+// scores nor learns from the missing audio. Without it, lost audio either
+// freezes the timers or, if the caller pads it with zeros, reaches VAD as
+// fabricated silence. Gaps shorter than a chunk add up across calls.
+func (e *Engine) PushGap(d time.Duration) error {
+	if d < 0 {
+		return errNegativeGap
+	}
+	if e.queue != nil {
+		e.queue.gap(d)
+		return nil
+	}
+	if e.acquire() {
+		defer e.finish()
+	}
+	if e.closing.Load() {
+		return ErrClosed
+	}
+	return e.pushGap(d)
+}
+
+// ProcessGap is PushGap returning the events of the gap, as Process does
+// for a chunk.
+func (e *Engine) ProcessGap(d time.Duration) ([]Event, error) {
+	if e.queue != nil {
+		return nil, errProcessQueued
+	}
+	if d < 0 {
+		return nil, errNegativeGap
+	}
+	if e.acquire() {
+		defer e.finish()
+	}
+	if e.closing.Load() {
+		return nil, ErrClosed
+	}
+	e.events = e.events[:0]
+	e.collecting = true
+	err := e.pushGap(d)
+	e.collecting = false
+	return e.events, err
+}
+
+// pushGap runs whole chunks of silence, not seen by VAD, through the
+// pipeline for d plus the remainder of earlier gaps.
+func (e *Engine) pushGap(d time.Duration) error {
+	if !e.listening {
+		return nil
+	}
+	n := e.gapSamples + int64(d/(time.Second/RequiredSampleRate))
+	chunks := n / RequiredChunkSize
+	e.gapSamples = n % RequiredChunkSize
+	if chunks > 0 && e.logs(slog.LevelDebug) {
+		e.log.Debug("audio gap", "duration", d, "chunks", chunks)
+	}
+	for ; chunks > 0; chunks-- {
+		e.stamp(time.Time{}, e.clock.Now())
+		if err := e.process(e.silence[:e.cfg.ChunkSize], true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
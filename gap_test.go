@@ -0,0 +1,169 @@
+package smartturn_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+)
+
+// gapEngine returns an engine whose VAD calls every chunk speech, with
+// callbacks logging to got and the sample offset of the last chunk VAD
+// scored in offset.
+func gapEngine(t *testing.T, vad *constVAD, got *[]string, offset *int64, tweak func(*smartturn.Config)) *smartturn.Engine {
+	t.Helper()
+	return newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+		OnSpeechStart: func() { *got = append(*got, "start") },
+		OnTurnEnd:     func(r smartturn.TurnEndReason) { *got = append(*got, "turn_end:"+r.String()) },
+		OnSpeechEnd:   func() { *got = append(*got, "end") },
+		OnSegmentReady: func(s []float32) {
+			// The gap is in the segment as zeros.
+			if s[len(s)-1] == 0 && s[len(s)/4] != 0 {
+				*got = append(*got, "segment")
+			}
+		},
+		OnVadScore: func(_ float32, off int64) { *offset = off },
+	}, func(cfg *smartturn.Config) {
+		cfg.VADBackend = vad
+		if tweak != nil {
+			tweak(cfg)
+		}
+	})
+}
+
+// TestGap checks that a gap during speech ends the turn after VadStopMs
+// without VAD seeing it, and that gaps shorter than a chunk add up.
+func TestGap(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		tweak func(*smartturn.Config)
+		size  int
+	}{
+		{"16 kHz", nil, smartturn.RequiredChunkSize},
+		{"8 kHz", telephony, smartturn.TelephonyChunkSize},
+	} {
+		vad := &constVAD{p: 0.9}
+		var got []string
+		var offset int64
+		e := gapEngine(t, vad, &got, &offset, tc.tweak)
+		chunk := make([]float32, tc.size)
+		for i := range chunk {
+			chunk[i] = 0.1
+		}
+		for range 31 {
+			if err := e.PushPCM(chunk); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// 1 s in 10 ms pieces: 31 chunks and 128 samples over.
+		for range 100 {
+			if err := e.PushGap(10 * time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if s := strings.Join(got, " "); s != "start segment turn_end:model end" || vad.calls != 31 {
+			t.Errorf("%s: callbacks %q and %d VAD calls, want a model end of turn and 31 calls", tc.name, s, vad.calls)
+		}
+		if err := e.PushPCM(chunk); err != nil {
+			t.Fatal(err)
+		}
+		if want := int64(62 * smartturn.RequiredChunkSize); offset != want {
+			t.Errorf("%s: chunk after the gap at offset %d, want %d", tc.name, offset, want)
+		}
+		// The remainder carries into the next gap.
+		if err := e.PushGap(24 * time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.PushPCM(chunk); err != nil {
+			t.Fatal(err)
+		}
+		if want := int64(64 * smartturn.RequiredChunkSize); offset != want {
+			t.Errorf("%s: chunk after the second gap at offset %d, want %d", tc.name, offset, want)
+		}
+	}
+}
+
+func TestGapQueued(t *testing.T) {
+	done := make(chan struct{})
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+		OnSpeechEnd: func() { close(done) },
+	}, func(cfg *smartturn.Config) {
+		cfg.VADBackend = &constVAD{p: 0.9}
+		cfg.InputQueue.Size = 4
+	})
+	chunk := make([]float32, smartturn.RequiredChunkSize)
+	for range 31 {
+		if err := e.PushPCM(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.PushGap(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued gap did not end the turn")
+	}
+	if _, err := e.ProcessGap(time.Second); err == nil {
+		t.Error("ProcessGap accepted with InputQueue")
+	}
+}
+
+func TestProcessGap(t *testing.T) {
+	var got []string
+	var offset int64
+	e := gapEngine(t, &constVAD{p: 0.9}, &got, &offset, nil)
+	chunk := make([]float32, smartturn.RequiredChunkSize)
+	for range 31 {
+		if _, err := e.Process(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events, err := e.ProcessGap(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []smartturn.EventKind
+	for _, ev := range events {
+		kinds = append(kinds, ev.Kind)
+	}
+	// The gap crosses TurnSegmentEmitMs, then ends the turn.
+	want := []smartturn.EventKind{smartturn.EventSegmentReady, smartturn.EventSegmentReady, smartturn.EventTurnPrediction, smartturn.EventSpeechEnd}
+	if fmt.Sprint(kinds) != fmt.Sprint(want) {
+		t.Errorf("ProcessGap events %v, want %v", kinds, want)
+	}
+
+	if _, err := e.ProcessGap(-time.Millisecond); err == nil {
+		t.Error("ProcessGap accepted a negative gap")
+	}
+	if err := e.PushGap(-time.Millisecond); err == nil {
+		t.Error("PushGap accepted a negative gap")
+	}
+}
+
+// TestGapNotListening checks that a gap is dropped like audio while the
+// engine is stopped, and refused once it is closed.
+func TestGapNotListening(t *testing.T) {
+	var got []string
+	var offset int64
+	e := gapEngine(t, &constVAD{}, &got, &offset, nil)
+	e.Stop()
+	if err := e.PushGap(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	e.Start()
+	if err := e.PushPCM(make([]float32, smartturn.RequiredChunkSize)); err != nil {
+		t.Fatal(err)
+	}
+	if offset != 0 {
+		t.Errorf("chunk after a gap while stopped at offset %d, want 0", offset)
+	}
+	e.Close()
+	if err := e.PushGap(time.Second); !errors.Is(err, smartturn.ErrClosed) {
+		t.Errorf("PushGap after Close: %v", err)
+	}
+}
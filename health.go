@@ -0,0 +1,126 @@
+package smartturn
+
+import (
+	"sync"
+	"time"
+)
+
+// Health is a snapshot of an engine's state and counters, for health and
+// readiness endpoints of services embedding the SDK.
+type Health struct {
+	// ModelsLoaded is true from a successful New until Close; use it for
+	// readiness.
+	ModelsLoaded bool
+	Listening    bool
+	// VADDegraded is set when Config.EnergyVADFallback replaced Silero with
+	// the energy VAD; speech boundaries are then less reliable.
+	VADDegraded bool
+
+	// Last VAD and Smart-Turn calls; zero until the first one.
+	LastVADInference  time.Time
+	LastVADLatency    time.Duration
+	LastTurnInference time.Time
+	LastTurnLatency   time.Duration
+
+	ChunksProcessed uint64 // chunks VAD scored without error
+	// DroppedChunks counts PushPCM calls whose audio was not processed:
+	// wrong size, engine stopped, engine closed, or input queue full.
+	DroppedChunks uint64
+	// QueuedChunks is the audio waiting in Config.InputQueue.
+	QueuedChunks int
+	// SanitizedSamples counts samples that were NaN, ±Inf, or outside
+	// [-1, 1] and were replaced or clamped before processing.
+	SanitizedSamples uint64
+
+	// RTF is the real-time factor over Config.Overload.Window (processing
+	// time / audio time), zero until the window has filled; Overloaded is
+	// set while it is above Config.Overload.Threshold.
+	RTF        float64
+	Overloaded bool
+
+	VADErrors  uint64
+	TurnErrors uint64
+	// Errors counts everything reported to OnError.
+	Errors        uint64
+	LastError     string
+	LastErrorTime time.Time
+}
+
+// healthStats backs Engine.Health. It is the only engine state shared with
+// other goroutines.
+type healthStats struct {
+	mu sync.Mutex
+	h  Health
+}
+
+// Health returns a snapshot of the engine's health. Unlike the rest of the
+// Engine API it may be called from any goroutine.
+func (e *Engine) Health() Health {
+	e.health.mu.Lock()
+	h := e.health.h
+	e.health.mu.Unlock()
+	if e.queue != nil {
+		h.QueuedChunks = e.queue.depth()
+	}
+	return h
+}
+
+func (s *healthStats) vadInference(at time.Time, d time.Duration, err error) {
+	s.mu.Lock()
+	s.h.LastVADInference, s.h.LastVADLatency = at, d
+	if err != nil {
+		s.h.VADErrors++
+	} else {
+		s.h.ChunksProcessed++
+	}
+	s.mu.Unlock()
+}
+
+func (s *healthStats) turnInference(at time.Time, d time.Duration, err error) {
+	s.mu.Lock()
+	s.h.LastTurnInference, s.h.LastTurnLatency = at, d
+	if err != nil {
+		s.h.TurnErrors++
+	}
+	s.mu.Unlock()
+}
+
+func (s *healthStats) dropped() {
+	s.mu.Lock()
+	s.h.DroppedChunks++
+	s.mu.Unlock()
+}
+
+// sanitized counts n clamped samples and reports whether they are the first.
+func (s *healthStats) sanitized(n int) bool {
+	s.mu.Lock()
+	first := s.h.SanitizedSamples == 0
+	s.h.SanitizedSamples += uint64(n)
+	s.mu.Unlock()
+	return first
+}
+
+func (s *healthStats) load(rtf float64, overloaded bool) {
+	s.mu.Lock()
+	s.h.RTF, s.h.Overloaded = rtf, overloaded
+	s.mu.Unlock()
+}
+
+func (s *healthStats) error(err error, at time.Time) {
+	s.mu.Lock()
+	s.h.Errors++
+	s.h.LastError, s.h.LastErrorTime = err.Error(), at
+	s.mu.Unlock()
+}
+
+func (s *healthStats) degradeVAD() {
+	s.mu.Lock()
+	s.h.VADDegraded = true
+	s.mu.Unlock()
+}
+
+func (s *healthStats) setState(loaded, listening bool) {
+	s.mu.Lock()
+	s.h.ModelsLoaded, s.h.Listening = loaded, listening
+	s.mu.Unlock()
+}
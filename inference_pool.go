@@ -0,0 +1,112 @@
+package smartturn
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Priority orders waiting requests of an InferencePool.
+type Priority int
+
+const (
+	// PriorityEndOfSpeech is a turn decision a user is waiting for; the
+	// engine uses it for every Smart-Turn call.
+	PriorityEndOfSpeech Priority = iota
+	// PrioritySpeculative is work whose result may be discarded, such as a
+	// prediction made mid-speech; it runs only when no end-of-speech
+	// request is waiting.
+	PrioritySpeculative
+	numPriorities
+)
+
+// InferencePool bounds how many Smart-Turn inferences run at once across
+// all engines sharing it (Config.InferencePool), so hundreds of sessions
+// ending turns together queue up instead of thrashing the CPU. A request
+// runs on its own goroutine once one of the pool's slots is free; waiting
+// requests are admitted by Priority, then in arrival order.
+type InferencePool struct {
+	workers int
+
+	mu      sync.Mutex
+	busy    int
+	waiting [numPriorities][]chan struct{}
+}
+
+// InferencePoolStats is a snapshot of an InferencePool.
+type InferencePoolStats struct {
+	Workers int
+	Busy    int // slots in use
+	Waiting int // requests queued for a slot
+}
+
+// NewInferencePool returns a pool running at most workers inferences at
+// once; workers <= 0 means GOMAXPROCS.
+func NewInferencePool(workers int) *InferencePool {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return &InferencePool{workers: workers}
+}
+
+// Do runs fn once a slot is free, for applications that run their own
+// model calls (e.g. speculative predictions) under the same budget.
+func (p *InferencePool) Do(prio Priority, fn func()) {
+	ready := make(chan struct{}, 1)
+	p.acquire(prio, ready)
+	defer p.release()
+	fn()
+}
+
+// Stats returns the pool's current usage.
+func (p *InferencePool) Stats() InferencePoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := InferencePoolStats{Workers: p.workers, Busy: p.busy}
+	for _, q := range p.waiting {
+		s.Waiting += len(q)
+	}
+	return s
+}
+
+// acquire blocks until the caller holds a slot. ready must be a buffered
+// channel of capacity 1 owned by the caller; engines reuse theirs.
+func (p *InferencePool) acquire(prio Priority, ready chan struct{}) {
+	if prio < 0 || prio >= numPriorities {
+		prio = PrioritySpeculative
+	}
+	p.mu.Lock()
+	if p.busy < p.workers && p.queued(prio) == 0 {
+		p.busy++
+		p.mu.Unlock()
+		return
+	}
+	p.waiting[prio] = append(p.waiting[prio], ready)
+	p.mu.Unlock()
+	<-ready
+}
+
+// queued counts requests that would be admitted before one of prio.
+func (p *InferencePool) queued(prio Priority) int {
+	n := 0
+	for i := Priority(0); i <= prio; i++ {
+		n += len(p.waiting[i])
+	}
+	return n
+}
+
+// release hands the slot to the first waiter by priority, or frees it.
+func (p *InferencePool) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.waiting {
+		if q := p.waiting[i]; len(q) > 0 {
+			next := q[0]
+			copy(q, q[1:])
+			q[len(q)-1] = nil
+			p.waiting[i] = q[:len(q)-1]
+			next <- struct{}{} // the slot stays busy
+			return
+		}
+	}
+	p.busy--
+}
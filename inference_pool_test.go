@@ -0,0 +1,209 @@
+package smartturn_test
+
+import (
+	"errors"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// gatedTurn is a Smart-Turn model whose calls block until gate is closed.
+// It counts how many calls run at once across the engines sharing it and
+// logs the calls and Closes of each engine's backend.
+type gatedTurn struct {
+	gate    chan struct{}
+	entered chan struct{}
+
+	running, peak, calls atomic.Int32
+	open                 sync.Once
+
+	mu  sync.Mutex
+	log []string
+}
+
+func newGatedTurn() *gatedTurn {
+	return &gatedTurn{gate: make(chan struct{}), entered: make(chan struct{}, 100)}
+}
+
+// release opens the gate. Tests defer it too, so that a failing test does
+// not leave the engines' Close cleanups waiting for blocked calls.
+func (g *gatedTurn) release() { g.open.Do(func() { close(g.gate) }) }
+
+// backend returns the TurnBackend of the engine called name.
+func (g *gatedTurn) backend(name string) smartturn.TurnBackend { return gatedBackend{g, name} }
+
+func (g *gatedTurn) record(s string) {
+	g.mu.Lock()
+	g.log = append(g.log, s)
+	g.mu.Unlock()
+}
+
+type gatedBackend struct {
+	g    *gatedTurn
+	name string
+}
+
+func (b gatedBackend) Predict([]float32) (float32, error) {
+	g := b.g
+	n := g.running.Add(1)
+	for p := g.peak.Load(); n > p && !g.peak.CompareAndSwap(p, n); p = g.peak.Load() {
+	}
+	g.entered <- struct{}{}
+	<-g.gate
+	g.calls.Add(1)
+	g.running.Add(-1)
+	g.record(b.name + " predict")
+	return 0.9, nil
+}
+
+func (b gatedBackend) Close() error {
+	b.g.record(b.name + " close")
+	return nil
+}
+
+// poolEngine returns an engine on pool with backend.
+func poolEngine(t *testing.T, pool *smartturn.InferencePool, backend smartturn.TurnBackend) *smartturn.Engine {
+	t.Helper()
+	cfg := benchConfig()
+	cfg.VADBackend = &smartturntest.EnergyVAD{}
+	cfg.TurnBackend = backend
+	cfg.InferencePool = pool
+	e, err := smartturn.New(cfg, smartturn.Callbacks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+	return e
+}
+
+// waitStats polls p until its stats are want.
+func waitStats(t *testing.T, p *smartturn.InferencePool, want smartturn.InferencePoolStats) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Stats() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("pool stats %+v, want %+v", p.Stats(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestInferencePool runs PredictFeatures from engines on several
+// goroutines sharing a pool of two workers: no more than two inferences
+// run at once, the others wait for a slot, and every call completes.
+func TestInferencePool(t *testing.T) {
+	if got := smartturn.NewInferencePool(0).Stats().Workers; got != runtime.GOMAXPROCS(0) {
+		t.Errorf("NewInferencePool(0) has %d workers, want GOMAXPROCS %d", got, runtime.GOMAXPROCS(0))
+	}
+
+	const (
+		workers = 2
+		engines = 6
+		calls   = 4 // per engine
+	)
+	pool := smartturn.NewInferencePool(workers)
+	g := newGatedTurn()
+	features := make([]float32, smartturn.TurnFeatureSize)
+	var wg sync.WaitGroup
+	errs := make(chan error, engines*calls)
+	for range engines {
+		e := poolEngine(t, pool, g.backend("e"))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range calls {
+				p, err := e.PredictFeatures(features)
+				if err == nil && p.Probability != 0.9 {
+					err = errors.New("wrong probability")
+				}
+				errs <- err
+			}
+		}()
+	}
+	defer g.release()
+
+	// Two calls hold the slots; every other engine's first call waits.
+	for range workers {
+		<-g.entered
+	}
+	waitStats(t, pool, smartturn.InferencePoolStats{Workers: workers, Busy: workers, Waiting: engines - workers})
+	if n := g.running.Load(); n != workers {
+		t.Fatalf("%d inferences running, want %d", n, workers)
+	}
+
+	g.release()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if peak := g.peak.Load(); peak != workers {
+		t.Errorf("%d inferences ran at once, want %d", peak, workers)
+	}
+	if n := g.calls.Load(); n != engines*calls {
+		t.Errorf("%d calls ran, want %d", n, engines*calls)
+	}
+	waitStats(t, pool, smartturn.InferencePoolStats{Workers: workers})
+}
+
+// TestInferencePoolClose closes an engine whose call is waiting for a
+// slot: Close waits for the call to run and release its slot, and only
+// then closes the backend.
+func TestInferencePoolClose(t *testing.T) {
+	pool := smartturn.NewInferencePool(1)
+	g := newGatedTurn()
+	a, b := poolEngine(t, pool, g.backend("a")), poolEngine(t, pool, g.backend("b"))
+	defer g.release()
+	features := make([]float32, smartturn.TurnFeatureSize)
+
+	results := make(chan error, 2)
+	go func() {
+		_, err := a.PredictFeatures(features)
+		results <- err
+	}()
+	<-g.entered
+	go func() {
+		_, err := b.PredictFeatures(features)
+		results <- err
+	}()
+	waitStats(t, pool, smartturn.InferencePoolStats{Workers: 1, Busy: 1, Waiting: 1})
+
+	closed := make(chan struct{})
+	go func() {
+		b.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while the engine's call was waiting")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	g.release()
+	for range 2 {
+		if err := <-results; err != nil {
+			t.Fatalf("PredictFeatures: %v", err)
+		}
+	}
+	<-closed
+	if want := []string{"a predict", "b predict", "b close"}; !slices.Equal(g.log, want) {
+		t.Errorf("calls %q, want %q", g.log, want)
+	}
+	waitStats(t, pool, smartturn.InferencePoolStats{Workers: 1})
+	if _, err := b.PredictFeatures(features); !errors.Is(err, smartturn.ErrClosed) {
+		t.Errorf("PredictFeatures after Close: %v", err)
+	}
+	// The closed engine takes no slot; the other still gets one.
+	if _, err := a.PredictFeatures(features); err != nil {
+		t.Errorf("PredictFeatures on the open engine: %v", err)
+	}
+	waitStats(t, pool, smartturn.InferencePoolStats{Workers: 1})
+}
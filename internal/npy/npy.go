@@ -0,0 +1,123 @@
+// Package npy reads and writes float32 arrays in NumPy's .npy format
+// (version 1.0, little-endian '<f4', C order), enough to exchange features
+// with Python tooling.
+package npy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var magic = []byte("\x93NUMPY")
+
+// Write writes data with the given shape as a .npy array.
+func Write(w io.Writer, data []float32, shape ...int) error {
+	n := 1
+	dims := make([]string, len(shape))
+	for i, d := range shape {
+		n *= d
+		dims[i] = strconv.Itoa(d)
+	}
+	if n != len(data) {
+		return fmt.Errorf("npy: shape %v does not hold %d values", shape, len(data))
+	}
+	shapeStr := strings.Join(dims, ", ")
+	if len(shape) == 1 {
+		shapeStr += ","
+	}
+	header := fmt.Sprintf("{'descr': '<f4', 'fortran_order': False, 'shape': (%s), }", shapeStr)
+	// Pad with spaces so that magic+version+len+header+'\n' is a multiple of 64.
+	total := len(magic) + 2 + 2 + len(header) + 1
+	header += strings.Repeat(" ", (64-total%64)%64) + "\n"
+
+	bw := bufio.NewWriter(w)
+	_, _ = bw.Write(magic)
+	_, _ = bw.Write([]byte{1, 0})
+	_ = binary.Write(bw, binary.LittleEndian, uint16(len(header)))
+	_, _ = bw.WriteString(header)
+	var buf [4]byte
+	for _, v := range data {
+		binary.LittleEndian.PutUint32(buf[:], math.Float32bits(v))
+		_, _ = bw.Write(buf[:])
+	}
+	return bw.Flush()
+}
+
+var (
+	descrRE = regexp.MustCompile(`'descr':\s*'([^']*)'`)
+	orderRE = regexp.MustCompile(`'fortran_order':\s*(True|False)`)
+	shapeRE = regexp.MustCompile(`'shape':\s*\(([^)]*)\)`)
+)
+
+// Read reads a '<f4' C-order .npy array (format version 1.x, 2.x or 3.x).
+func Read(r io.Reader) (data []float32, shape []int, err error) {
+	br := bufio.NewReader(r)
+	var pre [8]byte
+	if _, err := io.ReadFull(br, pre[:]); err != nil {
+		return nil, nil, err
+	}
+	if string(pre[:6]) != string(magic) {
+		return nil, nil, errors.New("npy: not a .npy file")
+	}
+	var headerLen int
+	switch pre[6] {
+	case 1:
+		var n uint16
+		if err := binary.Read(br, binary.LittleEndian, &n); err != nil {
+			return nil, nil, err
+		}
+		headerLen = int(n)
+	case 2, 3:
+		var n uint32
+		if err := binary.Read(br, binary.LittleEndian, &n); err != nil {
+			return nil, nil, err
+		}
+		headerLen = int(n)
+	default:
+		return nil, nil, fmt.Errorf("npy: unsupported format version %d", pre[6])
+	}
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, nil, err
+	}
+	h := string(header)
+	if m := descrRE.FindStringSubmatch(h); m == nil || m[1] != "<f4" {
+		return nil, nil, fmt.Errorf("npy: only little-endian float32 ('<f4') is supported, header %q", strings.TrimSpace(h))
+	}
+	if m := orderRE.FindStringSubmatch(h); m == nil || m[1] != "False" {
+		return nil, nil, errors.New("npy: Fortran-order arrays are not supported")
+	}
+	m := shapeRE.FindStringSubmatch(h)
+	if m == nil {
+		return nil, nil, errors.New("npy: header has no shape")
+	}
+	n := 1
+	for _, f := range strings.Split(m[1], ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		d, err := strconv.Atoi(f)
+		if err != nil || d < 0 {
+			return nil, nil, fmt.Errorf("npy: bad shape %q", m[1])
+		}
+		shape = append(shape, d)
+		n *= d
+	}
+	data = make([]float32, n)
+	var buf [4]byte
+	for i := range data {
+		if _, err := io.ReadFull(br, buf[:]); err != nil {
+			return nil, nil, fmt.Errorf("npy: reading data: %w", err)
+		}
+		data[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[:]))
+	}
+	return data, shape, nil
+}
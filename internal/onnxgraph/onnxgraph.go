@@ -0,0 +1,214 @@
+// Package onnxgraph reads the inputs and outputs of an ONNX model from its
+// protobuf, so a model can be inspected without creating an ONNX Runtime
+// session, which loads and optimizes the whole graph.
+package onnxgraph
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	ort "github.com/yalue/onnxruntime_go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of onnx.proto.
+const (
+	modelGraph = 7 // ModelProto.graph
+
+	graphInitializer       = 5  // GraphProto.initializer
+	graphInput             = 11 // GraphProto.input
+	graphOutput            = 12 // GraphProto.output
+	graphSparseInitializer = 15 // GraphProto.sparse_initializer
+
+	tensorName       = 8 // TensorProto.name
+	sparseTensorVals = 1 // SparseTensorProto.values
+
+	valueInfoName = 1 // ValueInfoProto.name
+	valueInfoType = 2 // ValueInfoProto.type
+
+	typeTensor       = 1 // TypeProto.tensor_type
+	typeSequence     = 4 // TypeProto.sequence_type
+	typeMap          = 5 // TypeProto.map_type
+	typeSparseTensor = 8 // TypeProto.sparse_tensor_type
+	typeOptional     = 9 // TypeProto.optional_type
+
+	tensorTypeElem  = 1 // TypeProto.Tensor.elem_type
+	tensorTypeShape = 2 // TypeProto.Tensor.shape
+
+	shapeDim = 1 // TensorShapeProto.dim
+	dimValue = 1 // TensorShapeProto.Dimension.dim_value
+)
+
+// ReadFile returns the inputs and outputs of the model at path as ONNX
+// Runtime reports them: graph inputs that are initializers are left out,
+// and dimensions without a fixed size are -1.
+func ReadFile(path string) (inputs, outputs []ort.InputOutputInfo, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	inputs, outputs, err = Read(b)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return inputs, outputs, nil
+}
+
+// Read is ReadFile on the serialized model.
+func Read(model []byte) (inputs, outputs []ort.InputOutputInfo, err error) {
+	var graph []byte
+	err = fields(model, func(n protowire.Number, _ uint64, data []byte) error {
+		if n == modelGraph {
+			graph = data
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if graph == nil {
+		return nil, nil, errors.New("onnxgraph: model has no graph")
+	}
+	initializers := make(map[string]bool)
+	var ins, outs [][]byte
+	err = fields(graph, func(n protowire.Number, _ uint64, data []byte) error {
+		switch n {
+		case graphInitializer:
+			return names(data, tensorName, initializers)
+		case graphSparseInitializer:
+			return fields(data, func(n protowire.Number, _ uint64, values []byte) error {
+				if n == sparseTensorVals {
+					return names(values, tensorName, initializers)
+				}
+				return nil
+			})
+		case graphInput:
+			ins = append(ins, data)
+		case graphOutput:
+			outs = append(outs, data)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, b := range ins {
+		info, err := valueInfo(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !initializers[info.Name] {
+			inputs = append(inputs, info)
+		}
+	}
+	for _, b := range outs {
+		info, err := valueInfo(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		outputs = append(outputs, info)
+	}
+	return inputs, outputs, nil
+}
+
+// names adds the string field n of message b to set.
+func names(b []byte, n protowire.Number, set map[string]bool) error {
+	return fields(b, func(f protowire.Number, _ uint64, data []byte) error {
+		if f == n {
+			set[string(data)] = true
+		}
+		return nil
+	})
+}
+
+// valueInfo decodes a ValueInfoProto.
+func valueInfo(b []byte) (ort.InputOutputInfo, error) {
+	var info ort.InputOutputInfo
+	err := fields(b, func(n protowire.Number, _ uint64, data []byte) error {
+		switch n {
+		case valueInfoName:
+			info.Name = string(data)
+		case valueInfoType:
+			return fields(data, func(n protowire.Number, _ uint64, data []byte) error {
+				switch n {
+				case typeTensor:
+					info.OrtValueType = ort.ONNXTypeTensor
+					return tensorType(data, &info)
+				case typeSparseTensor:
+					info.OrtValueType = ort.ONNXTypeSparseTensor
+					return tensorType(data, &info)
+				case typeSequence:
+					info.OrtValueType = ort.ONNXTypeSequence
+				case typeMap:
+					info.OrtValueType = ort.ONNXTypeMap
+				case typeOptional:
+					info.OrtValueType = ort.ONNXTypeOptional
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return info, err
+}
+
+// tensorType decodes the element type and shape of a TypeProto.Tensor.
+func tensorType(b []byte, info *ort.InputOutputInfo) error {
+	return fields(b, func(n protowire.Number, v uint64, data []byte) error {
+		switch n {
+		case tensorTypeElem:
+			info.DataType = ort.TensorElementDataType(v)
+		case tensorTypeShape:
+			info.Dimensions = ort.Shape{}
+			return fields(data, func(n protowire.Number, _ uint64, dim []byte) error {
+				if n != shapeDim {
+					return nil
+				}
+				d := int64(-1) // symbolic or unknown
+				err := fields(dim, func(n protowire.Number, v uint64, _ []byte) error {
+					if n == dimValue {
+						d = int64(v)
+					}
+					return nil
+				})
+				info.Dimensions = append(info.Dimensions, d)
+				return err
+			})
+		}
+		return nil
+	})
+}
+
+// fields calls fn for each field of message b with its varint value or
+// its bytes; fixed-width fields are skipped.
+func fields(b []byte, fn func(n protowire.Number, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		n, typ, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			return fmt.Errorf("onnxgraph: %w", protowire.ParseError(l))
+		}
+		b = b[l:]
+		var v uint64
+		var data []byte
+		switch typ {
+		case protowire.VarintType:
+			v, l = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			data, l = protowire.ConsumeBytes(b)
+		default:
+			l = protowire.ConsumeFieldValue(n, typ, b)
+		}
+		if l < 0 {
+			return fmt.Errorf("onnxgraph: %w", protowire.ParseError(l))
+		}
+		b = b[l:]
+		if typ != protowire.VarintType && typ != protowire.BytesType {
+			continue
+		}
+		if err := fn(n, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package onnxgraph
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	ort "github.com/yalue/onnxruntime_go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func message(b []byte, n protowire.Number, fields ...[]byte) []byte {
+	b = protowire.AppendTag(b, n, protowire.BytesType)
+	var m []byte
+	for _, f := range fields {
+		m = append(m, f...)
+	}
+	return protowire.AppendBytes(b, m)
+}
+
+func str(n protowire.Number, s string) []byte {
+	return protowire.AppendString(protowire.AppendTag(nil, n, protowire.BytesType), s)
+}
+
+func varint(n protowire.Number, v uint64) []byte {
+	return protowire.AppendVarint(protowire.AppendTag(nil, n, protowire.VarintType), v)
+}
+
+// tensor is a ValueInfoProto field n of a tensor of elem with dims; a
+// negative dimension is symbolic.
+func tensor(n protowire.Number, name string, elem ort.TensorElementDataType, dims ...int64) []byte {
+	var shape [][]byte
+	for _, d := range dims {
+		if d < 0 {
+			shape = append(shape, message(nil, shapeDim, str(2, "batch")))
+		} else {
+			shape = append(shape, message(nil, shapeDim, varint(dimValue, uint64(d))))
+		}
+	}
+	return message(nil, n, str(valueInfoName, name),
+		message(nil, valueInfoType, message(nil, typeTensor,
+			varint(tensorTypeElem, uint64(elem)), message(nil, tensorTypeShape, shape...))))
+}
+
+// model is a ModelProto with ir_version, a producer and the graph fields.
+func model(graph ...[]byte) []byte {
+	b := varint(1, 8)
+	b = append(b, str(2, "pytorch")...)
+	// Fields the reader skips: the graph name, and unknown varint and
+	// fixed32 fields.
+	unknown := protowire.AppendFixed32(protowire.AppendTag(nil, 0x100, protowire.Fixed32Type), 1)
+	return message(b, modelGraph, append([][]byte{str(2, "main"), varint(0xfff, 1), unknown}, graph...)...)
+}
+
+func TestRead(t *testing.T) {
+	var f32, i64 ort.TensorElementDataType = ort.TensorElementDataTypeFloat, ort.TensorElementDataTypeInt64
+	b := model(
+		message(nil, 1, str(3, "Add")), // a node
+		message(nil, graphInitializer, str(tensorName, "weight"), varint(1, 4)),
+		message(nil, graphSparseInitializer, message(nil, sparseTensorVals, str(tensorName, "sparse"))),
+		tensor(graphInput, "input_features", f32, -1, 80, 800),
+		// Inputs that are initializers can be overridden but are not
+		// required, so ONNX Runtime leaves them out.
+		tensor(graphInput, "weight", f32, 4),
+		tensor(graphInput, "sparse", f32, 4),
+		tensor(graphInput, "sr", i64),
+		message(nil, graphInput, str(valueInfoName, "seq"), message(nil, valueInfoType, message(nil, typeSequence))),
+		tensor(graphOutput, "logits", f32, -1, 1),
+		message(nil, graphOutput, str(valueInfoName, "unknown"),
+			message(nil, valueInfoType, message(nil, typeTensor, varint(tensorTypeElem, uint64(f32))))),
+	)
+	path := filepath.Join(t.TempDir(), "model.onnx")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	inputs, outputs, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantIn := []ort.InputOutputInfo{
+		{Name: "input_features", OrtValueType: ort.ONNXTypeTensor, Dimensions: ort.NewShape(-1, 80, 800), DataType: f32},
+		{Name: "sr", OrtValueType: ort.ONNXTypeTensor, Dimensions: ort.Shape{}, DataType: i64},
+		{Name: "seq", OrtValueType: ort.ONNXTypeSequence},
+	}
+	wantOut := []ort.InputOutputInfo{
+		{Name: "logits", OrtValueType: ort.ONNXTypeTensor, Dimensions: ort.NewShape(-1, 1), DataType: f32},
+		{Name: "unknown", OrtValueType: ort.ONNXTypeTensor, DataType: f32},
+	}
+	if !reflect.DeepEqual(inputs, wantIn) {
+		t.Errorf("inputs %+v, want %+v", inputs, wantIn)
+	}
+	if !reflect.DeepEqual(outputs, wantOut) {
+		t.Errorf("outputs %+v, want %+v", outputs, wantOut)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		model []byte
+		want  string
+	}{
+		{"no graph", varint(1, 8), "no graph"},
+		{"truncated", model(tensor(graphInput, "x", ort.TensorElementDataTypeFloat, 1))[:20], "onnxgraph:"},
+		{"bad tag", []byte{0}, "onnxgraph:"},
+	} {
+		if _, _, err := Read(tc.model); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error %v, want %q", tc.name, err, tc.want)
+		}
+	}
+	if _, _, err := ReadFile(filepath.Join(t.TempDir(), "missing.onnx")); err == nil {
+		t.Error("missing file: no error")
+	}
+}
@@ -0,0 +1,226 @@
+// Archive sources: .zip and .tar.gz/.tgz downloads from which one member is
+// extracted, e.g. the shared library of an official ONNX Runtime release.
+package resolver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// maxMemberSize bounds the size of an extracted member, so a malformed or
+// hostile archive cannot fill the disk. The largest ONNX Runtime library,
+// that of the GPU build, is well under it.
+var maxMemberSize int64 = 2 << 30
+
+// errMemberTooLarge reports a member over maxMemberSize.
+var errMemberTooLarge = errors.New("member exceeds the size limit")
+
+// isArchive reports whether rawURL names a .zip, .tar.gz or .tgz file.
+func isArchive(rawURL string) bool {
+	return archiveKind(rawURL) != ""
+}
+
+func archiveKind(rawURL string) string {
+	p := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		p = u.Path
+	}
+	p = strings.ToLower(p)
+	switch {
+	case strings.HasSuffix(p, ".zip"):
+		return "zip"
+	case strings.HasSuffix(p, ".tar.gz"), strings.HasSuffix(p, ".tgz"):
+		return "tgz"
+	}
+	return ""
+}
+
+// archiveName is the file name an archive is cached under in destDir. It is
+// a base name on every GOOS, so the URL cannot place it elsewhere, e.g.
+// with backslashes on Windows.
+func archiveName(rawURL string) string {
+	p := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		p = u.Path
+	}
+	return filepath.Base(path.Base(p))
+}
+
+// fetch resolves destName in destDir from rawURL: downloaded as is, or, for
+// an archive, extracted from it as the regular file matching member.
+func fetch(rawURL, destDir, destName, member string) (string, error) {
+	if !isArchive(rawURL) {
+		return downloadFile(rawURL, destDir, destName)
+	}
+	return downloadMember(rawURL, destDir, destName, member)
+}
+
+// downloadMember downloads the archive at rawURL into destDir (kept there,
+// so the Cache policy applies to it) and extracts the regular file matching
+// member into destName. member is a path.Match pattern, matched against the
+// base name of each entry or, when it contains a slash, its full path.
+// Executable entries, and shared libraries, are written with mode 0755.
+// The member is extracted again whenever the archive is newer than it.
+// Members larger than maxMemberSize are refused.
+func downloadMember(rawURL, destDir, destName, member string) (string, error) {
+	dest := filepath.Join(destDir, destName)
+	archive, err := downloadFile(rawURL, destDir, archiveName(rawURL))
+	if err != nil {
+		return "", err
+	}
+	if fresh(dest, archive) {
+		return dest, nil
+	}
+	var found bool
+	switch archiveKind(rawURL) {
+	case "zip":
+		found, err = extractZip(archive, member, dest)
+	default:
+		found, err = extractTar(archive, member, dest)
+	}
+	if err != nil {
+		return "", fmt.Errorf("extract %s from %s: %w", member, archive, err)
+	}
+	if !found {
+		return "", fmt.Errorf("no file matching %q in %s", member, archive)
+	}
+	return dest, nil
+}
+
+// fresh reports whether dest exists and is not older than archive.
+func fresh(dest, archive string) bool {
+	d, err := os.Stat(dest)
+	if err != nil {
+		return false
+	}
+	a, err := os.Stat(archive)
+	return err == nil && !d.ModTime().Before(a.ModTime())
+}
+
+func matchMember(pattern, name string) bool {
+	name = strings.TrimPrefix(name, "./")
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(name)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+func extractZip(archive, member, dest string) (bool, error) {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return false, err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() || !matchMember(member, f.Name) {
+			continue
+		}
+		if f.UncompressedSize64 > uint64(maxMemberSize) {
+			return true, fmt.Errorf("%s: %w", f.Name, errMemberTooLarge)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return false, err
+		}
+		err = writeMember(rc, dest, f.Mode())
+		_ = rc.Close()
+		return true, err
+	}
+	return false, nil
+}
+
+func extractTar(archive, member, dest string) (bool, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return false, err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		// Symlinks such as libonnxruntime.so -> libonnxruntime.so.1 are
+		// skipped: the pattern should match the file they point to.
+		if h.Typeflag != tar.TypeReg || !matchMember(member, h.Name) {
+			continue
+		}
+		if h.Size > maxMemberSize {
+			return true, fmt.Errorf("%s: %w", h.Name, errMemberTooLarge)
+		}
+		return true, writeMember(tr, dest, h.FileInfo().Mode())
+	}
+}
+
+// writeMember writes r to dest through a temp file and rename. It fails
+// with errMemberTooLarge, whatever size the archive declared, once r
+// yields more than maxMemberSize bytes.
+func writeMember(r io.Reader, dest string, mode fs.FileMode) error {
+	perm := fs.FileMode(0644)
+	if mode&0111 != 0 || isSharedLib(dest) {
+		perm = 0755
+	}
+	tmp := dest + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, io.LimitReader(r, maxMemberSize+1))
+	if err == nil && n > maxMemberSize {
+		err = errMemberTooLarge
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		// OpenFile's perm is filtered by the umask; exec bits must stick.
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp, dest)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
+
+func isSharedLib(name string) bool {
+	name = strings.ToLower(filepath.Base(name))
+	return strings.HasSuffix(name, ".dll") || strings.HasSuffix(name, ".dylib") ||
+		strings.HasSuffix(name, ".so") || strings.Contains(name, ".so.")
+}
+
+// onnxRuntimeMember is the pattern of the shared library in an official
+// ONNX Runtime release archive for the current GOOS.
+func onnxRuntimeMember() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "libonnxruntime.*.dylib"
+	case "windows":
+		return "onnxruntime.dll"
+	default:
+		return "libonnxruntime.so.*"
+	}
+}
@@ -0,0 +1,286 @@
+package resolver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// entry is an archive member; a symlink's body is its target.
+type entry struct {
+	name, body string
+	mode       fs.FileMode
+}
+
+// ortEntries mimic an official ONNX Runtime release.
+var ortEntries = []entry{
+	{"onnxruntime-linux-x64-1.23.2/README.md", "readme", 0644},
+	{"onnxruntime-linux-x64-1.23.2/lib/", "", fs.ModeDir | 0755},
+	{"onnxruntime-linux-x64-1.23.2/lib/libonnxruntime.so.1", "libonnxruntime.so.1.23.2", fs.ModeSymlink | 0777},
+	{"onnxruntime-linux-x64-1.23.2/lib/libonnxruntime.so.1.23.2", "lib", 0644},
+	{"onnxruntime-linux-x64-1.23.2/bin/tool", "tool", 0755},
+}
+
+func makeZip(t *testing.T, entries []entry) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		h.SetMode(e.mode)
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func makeTgz(t *testing.T, entries []entry) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		h := &tar.Header{Name: e.name, Mode: int64(e.mode.Perm()), Typeflag: tar.TypeReg, Size: int64(len(e.body))}
+		switch {
+		case e.mode&fs.ModeSymlink != 0:
+			h.Typeflag, h.Linkname, h.Size = tar.TypeSymlink, e.body, 0
+		case e.mode.IsDir():
+			h.Typeflag, h.Size = tar.TypeDir, 0
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if h.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// serveArchives serves files by URL path and returns the server's URL.
+func serveArchives(t *testing.T, files map[string][]byte) string {
+	t.Setenv(EnvModelCache, "")
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(b)
+	}))
+	t.Cleanup(hs.Close)
+	return hs.URL
+}
+
+func TestDownloadMember(t *testing.T) {
+	base := serveArchives(t, map[string][]byte{
+		"/ort.zip":    makeZip(t, ortEntries),
+		"/ort.tgz":    makeTgz(t, ortEntries),
+		"/ort.tar.gz": makeTgz(t, ortEntries),
+	})
+	for _, archive := range []string{"ort.zip", "ort.tgz", "ort.tar.gz"} {
+		for _, tc := range []struct {
+			member, dest, body string
+			perm               fs.FileMode
+		}{
+			// The symlink matches first and is skipped; shared libraries
+			// are made executable.
+			{"libonnxruntime.so.*", "libonnxruntime.so", "lib", 0755},
+			{"libonnxruntime.so.*", "lib", "lib", 0644},
+			{"tool", "out", "tool", 0755},
+			{"README.md", "out", "readme", 0644},
+			{"*/bin/tool", "out", "tool", 0755},
+			{"onnxruntime-linux-x64-1.23.2/README.md", "out", "readme", 0644},
+		} {
+			dir := t.TempDir()
+			path, err := fetch(base+"/"+archive, dir, tc.dest, tc.member)
+			if err != nil {
+				t.Errorf("%s %s: %v", archive, tc.member, err)
+				continue
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tc.body || path != filepath.Join(dir, tc.dest) {
+				t.Errorf("%s %s: %s holds %q, want %q", archive, tc.member, path, b, tc.body)
+			}
+			if fi, err := os.Stat(path); err != nil {
+				t.Fatal(err)
+			} else if fi.Mode().Perm() != tc.perm {
+				t.Errorf("%s %s: mode %v, want %v", archive, tc.member, fi.Mode().Perm(), tc.perm)
+			}
+			if _, err := os.Stat(filepath.Join(dir, archive)); err != nil {
+				t.Errorf("%s %s: archive not kept: %v", archive, tc.member, err)
+			}
+		}
+		// Slash patterns match the full path, others the base name.
+		for _, member := range []string{"bin/tool", "lib", "libonnxruntime.so.1"} {
+			_, err := fetch(base+"/"+archive, t.TempDir(), "out", member)
+			if err == nil || !strings.Contains(err.Error(), "no file matching") {
+				t.Errorf("%s %s: error %v, want no file matching", archive, member, err)
+			}
+		}
+	}
+}
+
+// TestDownloadMemberTraversal checks that entry names cannot place the
+// member, or anything else, outside its destination.
+func TestDownloadMemberTraversal(t *testing.T) {
+	entries := []entry{
+		{"../../libonnxruntime.so.1", "evil", 0755},
+		{"/etc/libonnxruntime.so.1", "evil", 0755},
+		{"../libonnxruntime.so.1.23.2", "evil", 0755},
+	}
+	base := serveArchives(t, map[string][]byte{
+		"/ort.zip": makeZip(t, entries),
+		"/ort.tgz": makeTgz(t, entries),
+	})
+	for _, archive := range []string{"ort.zip", "ort.tgz"} {
+		root := t.TempDir()
+		dir := filepath.Join(root, "models", "ort")
+		path, err := fetch(base+"/"+archive, dir, "libonnxruntime.so", "libonnxruntime.so.*")
+		if err != nil {
+			t.Fatalf("%s: %v", archive, err)
+		}
+		if path != filepath.Join(dir, "libonnxruntime.so") {
+			t.Errorf("%s: extracted to %s", archive, path)
+		}
+		var files []string
+		_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				rel, _ := filepath.Rel(root, p)
+				files = append(files, filepath.ToSlash(rel))
+			}
+			return err
+		})
+		want := []string{
+			"models/ort/libonnxruntime.so",
+			"models/ort/" + archive,
+			"models/ort/" + archive + ".source.json",
+		}
+		if strings.Join(files, " ") != strings.Join(want, " ") {
+			t.Errorf("%s: files %v, want %v", archive, files, want)
+		}
+	}
+}
+
+func TestArchiveName(t *testing.T) {
+	for _, tc := range []struct {
+		url, want string
+	}{
+		{"https://github.com/microsoft/onnxruntime/releases/download/v1.23.2/onnxruntime-linux-x64-1.23.2.tgz", "onnxruntime-linux-x64-1.23.2.tgz"},
+		{"https://example.com/ort.zip?token=abc#sha256=00", "ort.zip"},
+		{"https://example.com/../../ort.zip", "ort.zip"},
+		{"s3://bucket/a/b/ort.tar.gz", "ort.tar.gz"},
+	} {
+		if got := archiveName(tc.url); got != tc.want {
+			t.Errorf("archiveName(%q) = %q, want %q", tc.url, got, tc.want)
+		}
+	}
+	// Backslashes are separators on Windows only; either way the name
+	// stays in the directory.
+	got := archiveName(`https://example.com/a%5C..%5C..%5Cort.zip`)
+	if got != filepath.Base(got) || got == ".." {
+		t.Errorf("archiveName of a backslashed path = %q, not a base name", got)
+	}
+}
+
+func TestMemberSizeLimit(t *testing.T) {
+	defer func(n int64) { maxMemberSize = n }(maxMemberSize)
+	maxMemberSize = 8
+	entries := []entry{
+		{"small.so", "12345678", 0644},
+		{"large.so", "123456789", 0644},
+	}
+	base := serveArchives(t, map[string][]byte{
+		"/ort.zip": makeZip(t, entries),
+		"/ort.tgz": makeTgz(t, entries),
+	})
+	for _, archive := range []string{"ort.zip", "ort.tgz"} {
+		dir := t.TempDir()
+		if _, err := fetch(base+"/"+archive, dir, "small", "small.so"); err != nil {
+			t.Errorf("%s: member at the limit: %v", archive, err)
+		}
+		// The declared size is refused before anything is written.
+		_, err := fetch(base+"/"+archive, dir, "large", "large.so")
+		if !errors.Is(err, errMemberTooLarge) || !strings.Contains(err.Error(), "large.so: ") {
+			t.Errorf("%s: member over the limit: error %v", archive, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "large")); !os.IsNotExist(err) {
+			t.Errorf("%s: oversized member written: %v", archive, err)
+		}
+	}
+
+	// A member larger than its declared size is cut off while writing.
+	dest := filepath.Join(t.TempDir(), "lib.so")
+	if err := writeMember(strings.NewReader("123456789"), dest, 0644); !errors.Is(err, errMemberTooLarge) {
+		t.Errorf("writeMember over the limit: error %v", err)
+	}
+	if matches, _ := filepath.Glob(dest + "*"); len(matches) != 0 {
+		t.Errorf("files left behind: %v", matches)
+	}
+}
+
+// TestDownloadMemberFresh checks that the member is extracted again only
+// when the archive is newer than it.
+func TestDownloadMemberFresh(t *testing.T) {
+	url := serveArchives(t, map[string][]byte{"/ort.tgz": makeTgz(t, ortEntries)}) + "/ort.tgz"
+	dir := t.TempDir()
+	dest, archive := filepath.Join(dir, "tool"), filepath.Join(dir, "ort.tgz")
+	if _, err := fetch(url, dir, "tool", "tool"); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, tc := range []struct {
+		name string
+		age  time.Duration // of the member, relative to the archive
+		want string
+	}{
+		{"newer member", time.Hour, "edited"},
+		{"same age", 0, "edited"},
+		{"older member", -time.Hour, "tool"},
+	} {
+		if err := os.WriteFile(dest, []byte("edited"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(archive, now, now); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(dest, now.Add(tc.age), now.Add(tc.age)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fetch(url, dir, "tool", "tool"); err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := os.ReadFile(dest); string(b) != tc.want {
+			t.Errorf("%s: member holds %q, want %q", tc.name, b, tc.want)
+		}
+	}
+}
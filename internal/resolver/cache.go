@@ -0,0 +1,96 @@
+// Cache revalidation: downloaded files remember their source's validators
+// so a later resolve can ask whether they are still current.
+package resolver
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// CachePolicy decides whether a file already in the models directory is
+// checked against its source.
+type CachePolicy int
+
+const (
+	// CachePin uses a cached file as is, never contacting the source: the
+	// default, so startup is deterministic and works offline.
+	CachePin CachePolicy = iota
+	// CacheRevalidate sends a conditional request (If-None-Match,
+	// If-Modified-Since) for a cached file the resolver downloaded, and
+	// replaces it when the source has changed. When the source cannot be
+	// reached, the cached file is used. Files placed in the directory by
+	// hand carry no validators and stay pinned.
+	CacheRevalidate
+)
+
+// EnvModelCache selects the CachePolicy: "pin" (default) or "revalidate".
+const EnvModelCache = "SMARTTURN_MODEL_CACHE"
+
+// Cache is the policy for cached files; EnvModelCache, when set, overrides
+// it.
+var Cache = CachePin
+
+func cachePolicy() CachePolicy {
+	switch strings.ToLower(os.Getenv(EnvModelCache)) {
+	case "pin":
+		return CachePin
+	case "revalidate":
+		return CacheRevalidate
+	}
+	return Cache
+}
+
+// sourceMeta is what the resolver records next to a downloaded file, in
+// <file>.source.json.
+type sourceMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+func metaPath(path string) string { return path + ".source.json" }
+
+// readMeta returns the validators recorded for path, if any.
+func readMeta(path string) (sourceMeta, bool) {
+	var m sourceMeta
+	b, err := os.ReadFile(metaPath(path))
+	if err != nil || json.Unmarshal(b, &m) != nil {
+		return m, false
+	}
+	return m, true
+}
+
+// writeMeta records the source and validators of a download of path.
+func writeMeta(path, url string, h http.Header) error {
+	b, err := json.Marshal(sourceMeta{URL: url, ETag: h.Get("ETag"), LastModified: h.Get("Last-Modified")})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(metaPath(path), b, 0644)
+}
+
+// conditional returns the headers revalidating a cached path downloaded
+// from url, or false when it cannot be revalidated.
+func conditional(path, url string) (http.Header, bool) {
+	m, ok := readMeta(path)
+	if !ok {
+		return nil, false
+	}
+	h := http.Header{}
+	if m.URL != url {
+		// The source changed: download it unconditionally.
+		return h, true
+	}
+	if m.ETag == "" && m.LastModified == "" {
+		return nil, false
+	}
+	if m.ETag != "" {
+		h.Set("If-None-Match", m.ETag)
+	}
+	if m.LastModified != "" {
+		h.Set("If-Modified-Since", m.LastModified)
+	}
+	return h, true
+}
@@ -0,0 +1,190 @@
+package resolver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// source serves one file with validators and records the conditional
+// headers of each request.
+type source struct {
+	hs *httptest.Server
+
+	mu           sync.Mutex
+	body         string
+	etag         string // "" sends none
+	lastModified string // "" sends none
+	status       int    // answer with this status when set
+	requests     []http.Header
+}
+
+func newSource(t *testing.T, body, etag, lastModified string) *source {
+	s := &source{body: body, etag: etag, lastModified: lastModified}
+	s.hs = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, http.Header{
+			"If-None-Match":     r.Header.Values("If-None-Match"),
+			"If-Modified-Since": r.Header.Values("If-Modified-Since"),
+		})
+		if s.status != 0 {
+			w.WriteHeader(s.status)
+			return
+		}
+		if s.etag != "" {
+			w.Header().Set("ETag", s.etag)
+			if r.Header.Get("If-None-Match") == s.etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		if s.lastModified != "" {
+			w.Header().Set("Last-Modified", s.lastModified)
+			if s.etag == "" && r.Header.Get("If-Modified-Since") == s.lastModified {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		_, _ = w.Write([]byte(s.body))
+	}))
+	t.Cleanup(s.hs.Close)
+	return s
+}
+
+func (s *source) set(body, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.body, s.etag = body, etag
+}
+
+// last returns the number of requests and the headers of the last one.
+func (s *source) last() (int, http.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return 0, nil
+	}
+	return len(s.requests), s.requests[len(s.requests)-1]
+}
+
+// resolve downloads url into dir and checks that the file holds want.
+func resolve(t *testing.T, url, dir, want string) {
+	t.Helper()
+	path, err := downloadFile(url, dir, "model.onnx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != want {
+		t.Errorf("file holds %q, want %q", b, want)
+	}
+}
+
+func TestCacheRevalidate(t *testing.T) {
+	t.Setenv(EnvModelCache, "revalidate")
+	s := newSource(t, "v1", `"v1"`, "Wed, 21 Oct 2026 07:28:00 GMT")
+	url, dir := s.hs.URL+"/model.onnx", t.TempDir()
+
+	resolve(t, url, dir, "v1")
+	if m, ok := readMeta(filepath.Join(dir, "model.onnx")); !ok || m.URL != url || m.ETag != `"v1"` || m.LastModified == "" {
+		t.Errorf("source metadata %+v, %v", m, ok)
+	}
+	resolve(t, url, dir, "v1")
+	n, h := s.last()
+	if n != 2 || h.Get("If-None-Match") != `"v1"` || h.Get("If-Modified-Since") != "Wed, 21 Oct 2026 07:28:00 GMT" {
+		t.Errorf("request %d: conditional headers %v", n, h)
+	}
+
+	s.set("v2", `"v2"`)
+	resolve(t, url, dir, "v2")
+	if m, _ := readMeta(filepath.Join(dir, "model.onnx")); m.ETag != `"v2"` {
+		t.Errorf("ETag %q after the update, want \"v2\"", m.ETag)
+	}
+
+	// A failing or unreachable source keeps the cached copy.
+	s.mu.Lock()
+	s.status = http.StatusInternalServerError
+	s.mu.Unlock()
+	resolve(t, url, dir, "v2")
+	s.hs.Close()
+	resolve(t, url, dir, "v2")
+}
+
+func TestCacheLastModified(t *testing.T) {
+	t.Setenv(EnvModelCache, "revalidate")
+	s := newSource(t, "v1", "", "Wed, 21 Oct 2026 07:28:00 GMT")
+	url, dir := s.hs.URL+"/model.onnx", t.TempDir()
+	resolve(t, url, dir, "v1")
+	resolve(t, url, dir, "v1")
+	if n, h := s.last(); n != 2 || h.Get("If-None-Match") != "" || h.Get("If-Modified-Since") == "" {
+		t.Errorf("request %d: conditional headers %v", n, h)
+	}
+}
+
+// TestCachePin checks that pinned files are used without contacting the
+// source, whether pinned by EnvModelCache or Cache.
+func TestCachePin(t *testing.T) {
+	defer func(c CachePolicy) { Cache = c }(Cache)
+	for _, tc := range []struct {
+		env    string
+		policy CachePolicy
+		want   int // requests for two resolves
+	}{
+		{"", CachePin, 1},
+		{"pin", CacheRevalidate, 1},
+		{"", CacheRevalidate, 2},
+		{"REVALIDATE", CachePin, 2},
+	} {
+		t.Setenv(EnvModelCache, tc.env)
+		Cache = tc.policy
+		s := newSource(t, "v1", `"v1"`, "")
+		url, dir := s.hs.URL+"/model.onnx", t.TempDir()
+		resolve(t, url, dir, "v1")
+		s.set("v2", `"v2"`)
+		resolve(t, url, dir, map[int]string{1: "v1", 2: "v2"}[tc.want])
+		if n, _ := s.last(); n != tc.want {
+			t.Errorf("%s=%q, Cache %d: %d requests, want %d", EnvModelCache, tc.env, tc.policy, n, tc.want)
+		}
+	}
+}
+
+// TestCacheUnrevalidatable checks that files without validators stay
+// pinned, and that a file from another URL is replaced unconditionally.
+func TestCacheUnrevalidatable(t *testing.T) {
+	t.Setenv(EnvModelCache, "revalidate")
+	s := newSource(t, "v1", "", "")
+	url, dir := s.hs.URL+"/model.onnx", t.TempDir()
+
+	// Placed by hand: no source metadata.
+	if err := os.WriteFile(filepath.Join(dir, "model.onnx"), []byte("mine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	resolve(t, url, dir, "mine")
+	if n, _ := s.last(); n != 0 {
+		t.Errorf("%d requests for a file placed by hand", n)
+	}
+
+	// Downloaded from a source without validators.
+	if err := os.Remove(filepath.Join(dir, "model.onnx")); err != nil {
+		t.Fatal(err)
+	}
+	resolve(t, url, dir, "v1")
+	s.set("v2", "")
+	resolve(t, url, dir, "v1")
+	if n, _ := s.last(); n != 1 {
+		t.Errorf("%d requests, want 1: no validators to revalidate with", n)
+	}
+
+	// The URL changed.
+	resolve(t, url+"?v=2", dir, "v2")
+	if n, h := s.last(); n != 2 || h.Get("If-None-Match") != "" || h.Get("If-Modified-Since") != "" {
+		t.Errorf("request %d for a new URL: conditional headers %v", n, h)
+	}
+}
@@ -0,0 +1,226 @@
+// Download helpers: fetch files from URLs into DataDir when not present.
+package resolver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Environment variables replacing the download URL of each artifact, e.g.
+// with a mirror where direct egress to GitHub and Hugging Face is not
+// allowed. Besides http(s), they take s3://bucket/key URLs, signed with
+// credentials from the standard AWS chain, and gs://bucket/object URLs,
+// authorized with Google Application Default Credentials. A .zip, .tar.gz
+// or .tgz URL is downloaded as an archive and the artifact extracted from
+// it, so e.g. SMARTTURN_ONNXRUNTIME_URL can name an official ONNX Runtime
+// release. Any URL may end in #sha256=<hex> for the download to be checked
+// against that digest.
+const (
+	EnvSileroVADURL   = "SMARTTURN_SILERO_VAD_URL"
+	EnvSmartTurnURL   = "SMARTTURN_SMART_TURN_URL"
+	EnvONNXRuntimeURL = "SMARTTURN_ONNXRUNTIME_URL"
+)
+
+// sourceURL returns the URL in env, or def.
+func sourceURL(env, def string) string {
+	if u := os.Getenv(env); u != "" {
+		return u
+	}
+	return def
+}
+
+const (
+	// URLs for ONNX Runtime shared libraries (yalue/onnxruntime_go test_data).
+	urlONNXRuntimeBase = "https://github.com/yalue/onnxruntime_go/raw/refs/heads/master/test_data"
+	// Official ONNX Runtime releases, for platforms test_data lacks. The
+	// version is the one onnxruntime_go is built against. The linux_amd64
+	// default carries no #sha256= digest yet; to check the download, set
+	// SMARTTURN_ONNXRUNTIME_URL to this URL with the digest of the
+	// release's published checksum.
+	urlONNXRuntimeRelease = "https://github.com/microsoft/onnxruntime/releases/download/v1.23.2/onnxruntime-"
+	// URLs for models.
+	urlSileroVAD  = "https://github.com/snakers4/silero-vad/raw/refs/heads/master/src/silero_vad/data/silero_vad.onnx"
+	urlSmartTurn  = "https://huggingface.co/pipecat-ai/smart-turn-v3/resolve/main/smart-turn-v3.2-cpu.onnx"
+	sileroVADName = "silero_vad.onnx"
+	smartTurnName = "smart-turn-v3.2-cpu.onnx"
+)
+
+// onnxRuntimeURL returns the download URL for the current GOOS/GOARCH, or "" if not supported.
+func onnxRuntimeURL() string {
+	if u := os.Getenv(EnvONNXRuntimeURL); u != "" {
+		return u
+	}
+	m := map[string]string{
+		"windows_amd64": urlONNXRuntimeBase + "/onnxruntime.dll",
+		"darwin_amd64":  urlONNXRuntimeBase + "/onnxruntime_amd64.dylib",
+		"darwin_arm64":  urlONNXRuntimeBase + "/onnxruntime_arm64.dylib",
+		"linux_arm64":   urlONNXRuntimeBase + "/onnxruntime_arm64.so",
+		"linux_amd64":   urlONNXRuntimeRelease + "linux-x64-1.23.2.tgz",
+	}
+	return m[runtime.GOOS+"_"+runtime.GOARCH]
+}
+
+// downloadFile fetches url into destDir with filename destName. Skips if file already exists,
+// unless the Cache policy is CacheRevalidate and the source has changed.
+// Uses a temp file and rename for atomic write.
+func downloadFile(url, destDir, destName string) (path string, err error) {
+	path = filepath.Join(destDir, destName)
+	var header http.Header
+	cached := pathExists(path)
+	if cached {
+		var ok bool
+		if header, ok = conditional(path, url); !ok || cachePolicy() == CachePin {
+			return path, nil
+		}
+	}
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return "", fmt.Errorf("mkdir %s: %w", destDir, err)
+	}
+	resp, err := open(url, header)
+	if err != nil {
+		if cached {
+			return path, nil // source unreachable: keep the cached copy
+		}
+		return "", fmt.Errorf("GET %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if cached && resp.StatusCode != http.StatusOK {
+		return path, nil // 304 Not Modified, or the source failed
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	want, err := wantDigest(url)
+	if err != nil {
+		return "", err
+	}
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return "", fmt.Errorf("create %s: %w", tmpPath, err)
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), resp.Body)
+	_ = f.Close()
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return "", fmt.Errorf("write %s: %w", tmpPath, err)
+	}
+	if n == 0 {
+		_ = os.Remove(tmpPath)
+		return "", fmt.Errorf("empty response from %s", url)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); want != "" && got != want {
+		_ = os.Remove(tmpPath)
+		return "", fmt.Errorf("%s: sha256 %s, want %s", url, got, want)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", fmt.Errorf("rename to %s: %w", path, err)
+	}
+	if err := writeMeta(path, url, resp.Header); err != nil {
+		return "", fmt.Errorf("write %s: %w", metaPath(path), err)
+	}
+	return path, nil
+}
+
+// wantDigest returns the hex SHA-256 of a #sha256=<hex> fragment of
+// rawURL, or "" when there is none.
+func wantDigest(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	v, ok := strings.CutPrefix(u.Fragment, "sha256=")
+	if !ok {
+		return "", nil
+	}
+	if b, err := hex.DecodeString(v); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("%s: invalid sha256 fragment", rawURL)
+	}
+	return strings.ToLower(v), nil
+}
+
+// open starts the download of an http(s), s3 or gs URL, with the extra
+// request headers in header.
+func open(rawURL string, header http.Header) (*http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Fragment = ""
+	var req *http.Request
+	switch u.Scheme {
+	case "s3":
+		req, err = s3Request(u, header)
+	case "gs":
+		req, err = gcsRequest(u, header)
+	default:
+		req, err = newRequest(u.String(), header)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// newRequest builds a GET of target with header.
+func newRequest(target string, header http.Header) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return req, nil
+}
+
+// ResolveSileroVAD ensures silero_vad.onnx exists in dir (e.g. models/), downloading from Silero repo if missing.
+// Returns the absolute path to the file.
+func ResolveSileroVAD(dir string) (string, error) {
+	path, err := fetch(sourceURL(EnvSileroVADURL, urlSileroVAD), dir, sileroVADName, "silero_vad*.onnx")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
+
+// ResolveSmartTurn ensures smart-turn-v3.2-cpu.onnx exists in dir (e.g. models/), downloading from Hugging Face if missing.
+// Returns the absolute path to the file.
+func ResolveSmartTurn(dir string) (string, error) {
+	path, err := fetch(sourceURL(EnvSmartTurnURL, urlSmartTurn), dir, smartTurnName, "smart-turn*.onnx")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
+
+// ResolveONNXRuntimeLibWithDownload ensures the ONNX Runtime shared library exists in dir (e.g. models/) for the
+// current platform, downloading from yalue/onnxruntime_go test_data if missing, or extracting it from an official
+// release archive (linux/amd64). If this platform has no download URL, falls back to ResolveONNXRuntimeLib()
+// (path-only). Returns the path to the library, or "" if not found.
+func ResolveONNXRuntimeLibWithDownload(dir string) (string, error) {
+	url := onnxRuntimeURL()
+	if url == "" {
+		// No download URL for this platform; use path-only resolution.
+		return ResolveONNXRuntimeLib(), nil
+	}
+	name := archiveName(url)
+	if isArchive(url) {
+		name = dataDirLibName()
+	}
+	path, err := fetch(url, dir, name, onnxRuntimeMember())
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
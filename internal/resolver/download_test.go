@@ -0,0 +1,51 @@
+package resolver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDownloadDigest checks the #sha256=<hex> fragment: a download that
+// matches is kept, one that does not leaves nothing behind.
+func TestDownloadDigest(t *testing.T) {
+	t.Setenv(EnvModelCache, "")
+	s := newSource(t, "model", "", "")
+	sum := sha256.Sum256([]byte("model"))
+	digest := hex.EncodeToString(sum[:])
+	for _, frag := range []string{digest, strings.ToUpper(digest)} {
+		resolve(t, s.hs.URL+"/m.onnx#sha256="+frag, t.TempDir(), "model")
+	}
+
+	other := sha256.Sum256([]byte("other"))
+	for _, tc := range []struct{ frag, want string }{
+		{"sha256=" + hex.EncodeToString(other[:]), "sha256 " + digest + ", want " + hex.EncodeToString(other[:])},
+		{"sha256=" + digest[:62], "invalid sha256 fragment"},
+		{"sha256=" + digest[:63] + "g", "invalid sha256 fragment"},
+	} {
+		dir := t.TempDir()
+		_, err := downloadFile(s.hs.URL+"/m.onnx#"+tc.frag, dir, "model.onnx")
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error %v, want %q", tc.frag, err, tc.want)
+		}
+		if left, _ := filepath.Glob(filepath.Join(dir, "*")); len(left) != 0 {
+			t.Errorf("%s: left %v", tc.frag, left)
+		}
+	}
+
+	// Another fragment is not a digest.
+	resolve(t, s.hs.URL+"/m.onnx#main", t.TempDir(), "model")
+
+	// An archive is checked before extraction.
+	base := serveArchives(t, map[string][]byte{"/ort.tgz": makeTgz(t, ortEntries)})
+	dir := t.TempDir()
+	if _, err := fetch(base+"/ort.tgz#sha256="+digest, dir, "out", "README.md"); err == nil {
+		t.Error("archive with a wrong digest extracted")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out")); !os.IsNotExist(err) {
+		t.Errorf("member extracted from an archive with a wrong digest: %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+// GCS source: gs://bucket/object URLs, authorized with Application Default
+// Credentials.
+package resolver
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// gcsScope is the OAuth scope of the access tokens the resolver requests.
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_only"
+
+// gcsEndpoint is the Cloud Storage JSON API; STORAGE_EMULATOR_HOST
+// replaces it, as for the Google client libraries.
+func gcsEndpoint() string {
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		return strings.TrimSuffix(host, "/")
+	}
+	return "https://storage.googleapis.com"
+}
+
+// gcsRequest builds an authorized GET of gs://bucket/object.
+func gcsRequest(u *url.URL, header http.Header) (*http.Request, error) {
+	bucket, object := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || object == "" {
+		return nil, fmt.Errorf("invalid GCS URL %s: want gs://bucket/object", u)
+	}
+	target := gcsEndpoint() + "/storage/v1/b/" + url.PathEscape(bucket) + "/o/" + url.PathEscape(object) + "?alt=media"
+	req, err := newRequest(target, header)
+	if err != nil {
+		return nil, err
+	}
+	token, err := gcsToken()
+	if err != nil {
+		return nil, fmt.Errorf("Google credentials: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// gcsToken returns an access token from Application Default Credentials:
+// the file GOOGLE_APPLICATION_CREDENTIALS names, gcloud's
+// application_default_credentials.json, then the GCE/GKE metadata server.
+// It returns "", for an anonymous request, when none is found.
+func gcsToken() (string, error) {
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		path = gcloudCredentialsPath()
+	} else if !pathExists(path) {
+		return "", fmt.Errorf("GOOGLE_APPLICATION_CREDENTIALS: %s not found", path)
+	}
+	if path != "" && pathExists(path) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return tokenFromCredentialsFile(b)
+	}
+	return metadataToken()
+}
+
+func gcloudCredentialsPath() string {
+	const name = "application_default_credentials.json"
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "gcloud", name)
+	}
+	if dir := os.Getenv("CLOUDSDK_CONFIG"); dir != "" {
+		return filepath.Join(dir, name)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gcloud", name)
+}
+
+// tokenFromCredentialsFile exchanges a service account key or a gcloud
+// user's refresh token for an access token.
+func tokenFromCredentialsFile(b []byte) (string, error) {
+	var f struct {
+		Type         string `json:"type"`
+		ClientEmail  string `json:"client_email"`
+		PrivateKey   string `json:"private_key"`
+		PrivateKeyID string `json:"private_key_id"`
+		TokenURI     string `json:"token_uri"`
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return "", fmt.Errorf("credentials file: %w", err)
+	}
+	if f.TokenURI == "" {
+		f.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	switch f.Type {
+	case "service_account":
+		assertion, err := signJWT(f.ClientEmail, f.PrivateKeyID, f.PrivateKey, f.TokenURI, time.Now())
+		if err != nil {
+			return "", err
+		}
+		return postToken(f.TokenURI, url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		})
+	case "authorized_user":
+		return postToken(f.TokenURI, url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {f.ClientID},
+			"client_secret": {f.ClientSecret},
+			"refresh_token": {f.RefreshToken},
+		})
+	}
+	return "", fmt.Errorf("unsupported credentials type %q", f.Type)
+}
+
+// signJWT builds the RS256-signed assertion of a service account for the
+// OAuth token endpoint aud.
+func signJWT(email, keyID, keyPEM, aud string, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return "", errors.New("service account private_key is not PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return "", fmt.Errorf("service account private_key: %w", err)
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("service account private_key is not RSA")
+	}
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	claims, _ := json.Marshal(map[string]any{
+		"iss": email, "scope": gcsScope, "aud": aud,
+		"iat": now.Unix(), "exp": now.Add(time.Hour).Unix(),
+	})
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// postToken requests an access token from an OAuth token endpoint.
+func postToken(endpoint string, form url.Values) (string, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.PostForm(endpoint, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	return decodeToken(resp, endpoint)
+}
+
+// metadataToken returns the attached service account's token from the
+// metadata server, or "" off Google Cloud.
+func metadataToken() (string, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "169.254.169.254"
+	}
+	endpoint := "http://" + host + "/computeMetadata/v1/instance/service-accounts/default/token?scopes=" + url.QueryEscape(gcsScope)
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := (&http.Client{Timeout: time.Second}).Do(req)
+	if err != nil {
+		return "", nil // not on Google Cloud
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Metadata-Flavor") != "Google" {
+		return "", nil // another cloud's metadata service
+	}
+	return decodeToken(resp, "metadata server")
+}
+
+func decodeToken(resp *http.Response, from string) (string, error) {
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token from %s: %s", from, resp.Status)
+	}
+	var v struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return "", fmt.Errorf("token from %s: %w", from, err)
+	}
+	if v.AccessToken == "" {
+		return "", fmt.Errorf("token from %s: no access_token", from)
+	}
+	return v.AccessToken, nil
+}
@@ -0,0 +1,224 @@
+package resolver
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeGoogle is a token endpoint, metadata server and Cloud Storage
+// emulator in one.
+type fakeGoogle struct {
+	t   *testing.T
+	key *rsa.PrivateKey
+	hs  *httptest.Server
+	// metadata, when false, omits the Metadata-Flavor header like another
+	// cloud's metadata service.
+	metadata bool
+}
+
+func newFakeGoogle(t *testing.T) *fakeGoogle {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &fakeGoogle{t: t, key: key, metadata: true}
+	g.hs = httptest.NewServer(http.HandlerFunc(g.serve))
+	t.Cleanup(g.hs.Close)
+	t.Setenv("STORAGE_EMULATOR_HOST", g.hs.URL)
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(g.hs.URL, "http://"))
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	return g
+}
+
+func (g *fakeGoogle) serve(w http.ResponseWriter, r *http.Request) {
+	t := g.t
+	switch {
+	case r.URL.Path == "/token":
+		token := "ya29.refreshed"
+		switch r.FormValue("grant_type") {
+		case "urn:ietf:params:oauth:grant-type:jwt-bearer":
+			g.checkAssertion(r.FormValue("assertion"))
+			token = "ya29.service-account"
+		case "refresh_token":
+			if r.FormValue("refresh_token") != "1//refresh" || r.FormValue("client_id") != "client" || r.FormValue("client_secret") != "shh" {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, `{"error":"unsupported_grant_type"}`, http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": token, "expires_in": 3599})
+	case strings.HasPrefix(r.URL.Path, "/computeMetadata/"):
+		if r.Header.Get("Metadata-Flavor") != "Google" || r.URL.Query().Get("scopes") != gcsScope {
+			t.Errorf("metadata request %s with Metadata-Flavor %q", r.URL, r.Header.Get("Metadata-Flavor"))
+		}
+		if g.metadata {
+			w.Header().Set("Metadata-Flavor", "Google")
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "ya29.metadata"})
+	case strings.HasPrefix(r.URL.Path, "/storage/v1/b/"):
+		if got := r.URL.EscapedPath(); got != "/storage/v1/b/models/o/v3%2Fsmart%20turn.onnx" || r.URL.Query().Get("alt") != "media" {
+			t.Errorf("storage request %s", r.URL)
+		}
+		_, _ = io.WriteString(w, r.Header.Get("Authorization"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// checkAssertion verifies the signature and claims of a service account's
+// JWT.
+func (g *fakeGoogle) checkAssertion(jwt string) {
+	t := g.t
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Errorf("assertion %q is not a JWT", jwt)
+		return
+	}
+	enc := base64.RawURLEncoding
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&g.key.PublicKey, crypto.SHA256, sum[:], sig); err != nil {
+		t.Errorf("assertion signature: %v", err)
+	}
+	var header map[string]string
+	var claims struct {
+		Iss, Scope, Aud string
+		Iat, Exp        int64
+	}
+	h, _ := enc.DecodeString(parts[0])
+	c, _ := enc.DecodeString(parts[1])
+	if json.Unmarshal(h, &header) != nil || json.Unmarshal(c, &claims) != nil {
+		t.Errorf("assertion %s.%s does not decode", h, c)
+		return
+	}
+	if header["alg"] != "RS256" || header["kid"] != "key-1" {
+		t.Errorf("JWT header %v", header)
+	}
+	if claims.Iss != "resolver@project.iam.gserviceaccount.com" || claims.Scope != gcsScope ||
+		claims.Aud != g.hs.URL+"/token" || claims.Exp-claims.Iat != 3600 {
+		t.Errorf("JWT claims %+v", claims)
+	}
+}
+
+// credentials writes a credentials file of typ and returns its path.
+func (g *fakeGoogle) credentials(typ string) string {
+	t := g.t
+	der, err := x509.MarshalPKCS8PrivateKey(g.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(map[string]string{
+		"type":           typ,
+		"client_email":   "resolver@project.iam.gserviceaccount.com",
+		"private_key_id": "key-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":      g.hs.URL + "/token",
+		"client_id":      "client",
+		"client_secret":  "shh",
+		"refresh_token":  "1//refresh",
+	})
+	path := filepath.Join(t.TempDir(), typ+".json")
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// get downloads gs://models/v3/smart turn.onnx from g, which answers with
+// the request's Authorization header.
+func (g *fakeGoogle) get() (string, error) {
+	resp, err := open("gs://models/v3/smart turn.onnx", nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	return string(b), err
+}
+
+// TestGCS downloads an object with each kind of Application Default
+// Credentials.
+func TestGCS(t *testing.T) {
+	g := newFakeGoogle(t)
+	for _, tc := range []struct {
+		name  string
+		setup func()
+		want  string
+	}{
+		{"service account", func() {
+			t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", g.credentials("service_account"))
+		}, "Bearer ya29.service-account"},
+		{"gcloud user", func() {
+			t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+			dir := t.TempDir()
+			b, _ := os.ReadFile(g.credentials("authorized_user"))
+			if err := os.WriteFile(filepath.Join(dir, "application_default_credentials.json"), b, 0600); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("CLOUDSDK_CONFIG", dir)
+		}, "Bearer ya29.refreshed"},
+		{"metadata server", func() {
+			t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+		}, "Bearer ya29.metadata"},
+		{"anonymous", func() { g.metadata = false }, ""},
+	} {
+		tc.setup()
+		got, err := g.get()
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: Authorization %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestGCSErrors(t *testing.T) {
+	g := newFakeGoogle(t)
+	write := func(content string) string {
+		path := filepath.Join(t.TempDir(), "creds.json")
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	for _, tc := range []struct {
+		name, creds, want string
+	}{
+		{"missing file", filepath.Join(t.TempDir(), "nope.json"), "not found"},
+		{"not JSON", write("{"), "credentials file"},
+		{"unsupported type", write(`{"type":"external_account"}`), `unsupported credentials type "external_account"`},
+		{"bad key", write(`{"type":"service_account","private_key":"not pem"}`), "not PEM"},
+		{"refused", write(`{"type":"authorized_user","token_uri":"` + g.hs.URL + `/token","refresh_token":"stale"}`), "400 Bad Request"},
+		{"no token", write(`{"type":"authorized_user","token_uri":"` + g.hs.URL + `/nowhere"}`), "404 Not Found"},
+	} {
+		t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", tc.creds)
+		if _, err := g.get(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error %v, want %q", tc.name, err, tc.want)
+		}
+	}
+	if _, err := open("gs://models", nil); err == nil {
+		t.Error("gs://bucket without an object accepted")
+	}
+}
@@ -1,6 +1,7 @@
 // Package resolver provides helpers to resolve the ONNX Runtime shared library
-// when bundled with the app (data/ or lib/<platform>/). Use from examples or
-// apps that bundle the runtime.
+// when bundled with the app (data/ or lib/<platform>/), and to download it and
+// the models when missing. It backs cmd/smartturn-server and the examples;
+// applications outside this module pass their own paths to smartturn.Config.
 package resolver
 
 import (
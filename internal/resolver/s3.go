@@ -0,0 +1,414 @@
+// S3 source: s3://bucket/key URLs, signed with AWS Signature Version 4 and
+// credentials from the standard AWS chain.
+package resolver
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// awsCredentials are the keys an S3 request is signed with; zero for an
+// anonymous request.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// s3Request builds a signed GET of s3://bucket/key. The region comes from
+// AWS_REGION or AWS_DEFAULT_REGION (default us-east-1), and
+// AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL selects an S3-compatible service
+// (path-style).
+func s3Request(u *url.URL, header http.Header) (*http.Request, error) {
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("invalid S3 URL %s: want s3://bucket/key", u)
+	}
+	region := firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	var target string
+	if ep := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); ep != "" {
+		target = strings.TrimSuffix(ep, "/") + "/" + bucket + "/" + escapePath(key)
+	} else {
+		target = "https://" + bucket + ".s3." + region + ".amazonaws.com/" + escapePath(key)
+	}
+	req, err := newRequest(target, header)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := awsCredentialChain()
+	if err != nil {
+		return nil, fmt.Errorf("AWS credentials: %w", err)
+	}
+	if creds.AccessKeyID != "" {
+		signV4(req, creds, region, "s3", time.Now())
+	}
+	return req, nil
+}
+
+// escapePath percent-encodes each segment of an S3 key as SigV4 expects.
+func escapePath(key string) string {
+	return uriEncode(key, true)
+}
+
+// signV4 signs req, which has no body, for service in region: every header
+// already set is signed, along with host, x-amz-date and
+// x-amz-content-sha256. The path is sent as signed: URI-encoded once, as S3
+// expects.
+func signV4(req *http.Request, c awsCredentials, region, service string, now time.Time) {
+	req.Header.Set("X-Amz-Date", now.UTC().Format("20060102T150405Z"))
+	if req.Header.Get("X-Amz-Content-Sha256") == "" {
+		req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	}
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+	req.URL.RawPath = escapePath(req.URL.Path)
+	req.Header.Set("Authorization", authorization(req, c, region, service, req.Header.Get("X-Amz-Content-Sha256")))
+}
+
+// authorization returns the SigV4 Authorization header of req, whose
+// X-Amz-Date header is set, for a payload with hash payloadHash. It signs
+// host and every header of req.
+func authorization(req *http.Request, c awsCredentials, region, service, payloadHash string) string {
+	stamp := req.Header.Get("X-Amz-Date")
+	day := stamp[:min(len(stamp), 8)]
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, vs := range req.Header {
+		vals := make([]string, len(vs))
+		for i, v := range vs {
+			vals[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[strings.ToLower(k)] = strings.Join(vals, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+	path := escapePath(req.URL.Path)
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{
+		req.Method, path, canonicalQuery(req.URL.Query()), canonHeaders.String(), signed, payloadHash,
+	}, "\n")
+
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+	key := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), day)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return "AWS4-HMAC-SHA256 Credential=" + c.AccessKeyID + "/" + scope +
+		", SignedHeaders=" + signed + ", Signature=" + hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+// canonicalQuery encodes q as SigV4 expects: each name and value encoded,
+// sorted by encoded name, then value.
+func canonicalQuery(q url.Values) string {
+	var pairs [][2]string
+	for k, vs := range q {
+		for _, v := range vs {
+			pairs = append(pairs, [2]string{awsEscape(k), awsEscape(v)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	parts := make([]string, len(pairs))
+	for i, p := range pairs {
+		parts[i] = p[0] + "=" + p[1]
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape is the UriEncode of SigV4 for a query name or value.
+func awsEscape(s string) string {
+	return uriEncode(s, false)
+}
+
+// uriEncode percent-encodes every byte of s but the unreserved characters
+// of RFC 3986 (A-Z, a-z, 0-9, '-', '.', '_', '~'), and '/' with keepSlash,
+// with uppercase hex digits. url.PathEscape and url.QueryEscape leave
+// characters such as ':', '=', '@', ',' and ';' alone, which SigV4
+// encodes.
+func uriEncode(s string, keepSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&15])
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsCredentialChain finds credentials the way the AWS SDKs do: the
+// environment, web identity (EKS IRSA), the shared credentials file,
+// container credentials (ECS, EKS Pod Identity), then EC2 instance
+// metadata. It returns zero credentials, for an anonymous request, when
+// none is found.
+func awsCredentialChain() (awsCredentials, error) {
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		return awsCredentials{id, secret, os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+	if tokenFile, role := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN"); tokenFile != "" && role != "" {
+		return awsWebIdentity(tokenFile, role)
+	}
+	if c, ok, err := awsSharedCredentials(); ok || err != nil {
+		return c, err
+	}
+	if c, ok, err := awsContainerCredentials(); ok || err != nil {
+		return c, err
+	}
+	return awsInstanceCredentials()
+}
+
+// awsSharedCredentials reads the profile AWS_PROFILE (default "default")
+// of AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials.
+func awsSharedCredentials() (awsCredentials, bool, error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return awsCredentials{}, false, nil
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return awsCredentials{}, false, nil
+	}
+	defer f.Close()
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	var c awsCredentials
+	var in, found bool
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			in = strings.TrimSpace(line[1:len(line)-1]) == profile
+			found = found || in
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !in || !ok {
+			continue
+		}
+		switch strings.TrimSpace(k) {
+		case "aws_access_key_id":
+			c.AccessKeyID = strings.TrimSpace(v)
+		case "aws_secret_access_key":
+			c.SecretAccessKey = strings.TrimSpace(v)
+		case "aws_session_token":
+			c.SessionToken = strings.TrimSpace(v)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return c, false, fmt.Errorf("read %s: %w", path, err)
+	}
+	if !found {
+		return c, false, nil
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return c, false, fmt.Errorf("profile %s in %s has no access keys", profile, path)
+	}
+	return c, true, nil
+}
+
+// awsContainerCredentials fetches credentials from the ECS or EKS Pod
+// Identity agent, when the environment points to one.
+func awsContainerCredentials() (awsCredentials, bool, error) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if rel := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); rel != "" {
+		endpoint = "http://169.254.170.2" + rel
+	}
+	if endpoint == "" {
+		return awsCredentials{}, false, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return awsCredentials{}, true, err
+	}
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if file := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return awsCredentials{}, true, err
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	c, err := fetchAWSJSON(req)
+	return c, true, err
+}
+
+// awsInstanceCredentials fetches the instance role's credentials from EC2
+// instance metadata (IMDSv2). Off EC2 it returns zero credentials.
+func awsInstanceCredentials() (awsCredentials, error) {
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return awsCredentials{}, nil
+	}
+	const base = "http://169.254.169.254/latest"
+	client := &http.Client{Timeout: time.Second}
+	req, _ := http.NewRequest(http.MethodPut, base+"/api/token", nil)
+	req.Header.Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "300")
+	resp, err := client.Do(req)
+	if err != nil {
+		return awsCredentials{}, nil // not on EC2
+	}
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return awsCredentials{}, nil
+	}
+	token := string(b)
+	get := func(path string) (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, base+path, nil)
+		if err == nil {
+			req.Header.Set("X-Aws-Ec2-Metadata-Token", token)
+		}
+		return req, err
+	}
+	req, _ = get("/meta-data/iam/security-credentials/")
+	resp, err = client.Do(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	b, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return awsCredentials{}, nil // no instance role
+	}
+	role := strings.TrimSpace(strings.SplitN(string(b), "\n", 2)[0])
+	req, _ = get("/meta-data/iam/security-credentials/" + role)
+	return fetchAWSJSON(req)
+}
+
+// fetchAWSJSON reads credentials in the JSON of the container and instance
+// metadata endpoints.
+func fetchAWSJSON(req *http.Request) (awsCredentials, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return awsCredentials{}, fmt.Errorf("GET %s: %s", req.URL, resp.Status)
+	}
+	var v struct{ AccessKeyId, SecretAccessKey, Token string }
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return awsCredentials{}, fmt.Errorf("GET %s: %w", req.URL, err)
+	}
+	return awsCredentials{v.AccessKeyId, v.SecretAccessKey, v.Token}, nil
+}
+
+// awsWebIdentity exchanges the web identity token in tokenFile for
+// credentials of role with STS.
+func awsWebIdentity(tokenFile, role string) (awsCredentials, error) {
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	endpoint := "https://sts.amazonaws.com/"
+	if region := firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"); region != "" {
+		endpoint = "https://sts." + region + ".amazonaws.com/"
+	}
+	session := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if session == "" {
+		session = "smart-turn-resolver"
+	}
+	q := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {role},
+		"RoleSessionName":  {session},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(q.Encode()))
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return awsCredentials{}, fmt.Errorf("AssumeRoleWithWebIdentity: %s", resp.Status)
+	}
+	var v struct {
+		Credentials struct {
+			AccessKeyId, SecretAccessKey, SessionToken string
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return awsCredentials{}, fmt.Errorf("AssumeRoleWithWebIdentity: %w", err)
+	}
+	if v.Credentials.AccessKeyId == "" {
+		return awsCredentials{}, errors.New("AssumeRoleWithWebIdentity: no credentials in response")
+	}
+	return awsCredentials{v.Credentials.AccessKeyId, v.Credentials.SecretAccessKey, v.Credentials.SessionToken}, nil
+}
+
+// firstEnv returns the first of the environment variables that is set.
+func firstEnv(names ...string) string {
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+// Package wav writes mono 32-bit float WAV files incrementally, for
+// recording audio whose length is not known up front, and reads the common
+// PCM and float WAV variants back as mono float32.
+package wav
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// HeaderSize is the size in bytes of the header written by NewWriter.
+const HeaderSize = 58
+
+// Writer streams float32 samples to a WAV file (WAVE_FORMAT_IEEE_FLOAT).
+// The sizes in the header are filled in by Close.
+type Writer struct {
+	w       io.WriteSeeker
+	bw      *bufio.Writer
+	rate    int
+	samples uint32
+}
+
+// NewWriter writes a header to w and returns a Writer for mono audio at
+// sampleRate.
+func NewWriter(w io.WriteSeeker, sampleRate int) (*Writer, error) {
+	wr := &Writer{w: w, bw: bufio.NewWriter(w), rate: sampleRate}
+	wr.header()
+	return wr, wr.bw.Flush()
+}
+
+func (wr *Writer) header() {
+	le := binary.LittleEndian
+	dataBytes := wr.samples * 4
+	var h [HeaderSize]byte
+	copy(h[0:], "RIFF")
+	le.PutUint32(h[4:], HeaderSize-8+dataBytes)
+	copy(h[8:], "WAVE")
+	copy(h[12:], "fmt ")
+	le.PutUint32(h[16:], 18)
+	le.PutUint16(h[20:], 3) // WAVE_FORMAT_IEEE_FLOAT
+	le.PutUint16(h[22:], 1) // mono
+	le.PutUint32(h[24:], uint32(wr.rate))
+	le.PutUint32(h[28:], uint32(wr.rate)*4)
+	le.PutUint16(h[32:], 4)  // block align
+	le.PutUint16(h[34:], 32) // bits per sample
+	le.PutUint16(h[36:], 0)  // extension size
+	copy(h[38:], "fact")
+	le.PutUint32(h[42:], 4)
+	le.PutUint32(h[46:], wr.samples)
+	copy(h[50:], "data")
+	le.PutUint32(h[54:], dataBytes)
+	_, _ = wr.bw.Write(h[:])
+}
+
+// Write appends samples.
+func (wr *Writer) Write(samples []float32) error {
+	var buf [4]byte
+	for _, v := range samples {
+		binary.LittleEndian.PutUint32(buf[:], math.Float32bits(v))
+		if _, err := wr.bw.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	wr.samples += uint32(len(samples))
+	return nil
+}
+
+// Close flushes buffered samples and rewrites the header with the final
+// sizes. It does not close the underlying file.
+func (wr *Writer) Close() error {
+	if err := wr.bw.Flush(); err != nil {
+		return err
+	}
+	if _, err := wr.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	wr.header()
+	if err := wr.bw.Flush(); err != nil {
+		return err
+	}
+	_, err := wr.w.Seek(0, io.SeekEnd)
+	return err
+}
+
+// ErrFormat is returned by Read for files it cannot decode.
+var ErrFormat = errors.New("wav: unsupported format")
+
+// Read decodes a PCM (8, 16, 24, or 32-bit integer) or 32-bit float WAV
+// file, averaging channels to mono, and returns samples in [-1, 1] with the
+// sample rate.
+func Read(r io.Reader) ([]float32, int, error) {
+	br := bufio.NewReader(r)
+	var riff [12]byte
+	if _, err := io.ReadFull(br, riff[:]); err != nil || string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, 0, ErrFormat
+	}
+	le := binary.LittleEndian
+	var format, channels, bits uint16
+	var rate uint32
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			return nil, 0, ErrFormat
+		}
+		id, size := string(hdr[0:4]), le.Uint32(hdr[4:])
+		switch id {
+		case "fmt ":
+			if size < 16 || size > 1<<16 {
+				return nil, 0, ErrFormat
+			}
+			b := make([]byte, size+size%2)
+			if _, err := io.ReadFull(br, b); err != nil {
+				return nil, 0, ErrFormat
+			}
+			format, channels, rate, bits = le.Uint16(b[0:]), le.Uint16(b[2:]), le.Uint32(b[4:]), le.Uint16(b[14:])
+			if format == 0xFFFE && size >= 26 {
+				format = le.Uint16(b[24:]) // WAVE_FORMAT_EXTENSIBLE sub-format
+			}
+		case "data":
+			if channels == 0 {
+				return nil, 0, ErrFormat
+			}
+			return readData(br, size, format, int(channels), int(bits), int(rate))
+		default:
+			if _, err := br.Discard(int(size + size%2)); err != nil {
+				return nil, 0, ErrFormat
+			}
+		}
+	}
+}
+
+func readData(br *bufio.Reader, size uint32, format uint16, channels, bits, rate int) ([]float32, int, error) {
+	width := bits / 8
+	var decode func(b []byte) float64
+	switch {
+	case format == 1 && bits == 8:
+		decode = func(b []byte) float64 { return (float64(b[0]) - 128) / 128 }
+	case format == 1 && bits == 16:
+		decode = func(b []byte) float64 { return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15) }
+	case format == 1 && bits == 24:
+		decode = func(b []byte) float64 {
+			return float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+		}
+	case format == 1 && bits == 32:
+		decode = func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31) }
+	case format == 3 && bits == 32:
+		decode = func(b []byte) float64 { return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) }
+	default:
+		return nil, 0, ErrFormat
+	}
+	frame := width * channels
+	// Recorders that could not seek leave size at 0 or 0xFFFFFFFF: read to EOF.
+	data, err := io.ReadAll(io.LimitReader(br, int64(size)))
+	if err != nil {
+		return nil, 0, err
+	}
+	if size == 0 {
+		if data, err = io.ReadAll(br); err != nil {
+			return nil, 0, err
+		}
+	}
+	out := make([]float32, len(data)/frame)
+	for i := range out {
+		var v float64
+		for c := 0; c < channels; c++ {
+			v += decode(data[i*frame+c*width:])
+		}
+		out[i] = float32(v / float64(channels))
+	}
+	return out, rate, nil
+}
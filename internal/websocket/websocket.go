@@ -0,0 +1,315 @@
+// Package websocket is the server side of RFC 6455, enough for the SDK's
+// server modes: upgrade, reading (fragmented, masked) messages, writing
+// unfragmented ones, ping/pong, and the closing handshake.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message opcodes.
+const (
+	Text   = 1
+	Binary = 2
+
+	opContinuation = 0
+	opClose        = 8
+	opPing         = 9
+	opPong         = 10
+)
+
+// Close status codes.
+const (
+	CloseNormal      = 1000
+	CloseGoingAway   = 1001
+	CloseProtocol    = 1002
+	CloseTooBig      = 1009
+	CloseServerError = 1011
+)
+
+// DefaultMaxMessage bounds the size of a read message when
+// Conn.MaxMessage is zero. Servers should set a bound of their own,
+// matched to the messages of their protocol.
+const DefaultMaxMessage = 1 << 20
+
+// acceptGUID is appended to the client's key to derive the accept value.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrClosed is returned by ReadMessage once the peer closed the
+// connection.
+var ErrClosed = errors.New("websocket: connection closed")
+
+// Conn is an upgraded connection. ReadMessage must be called from one
+// goroutine; writes may come from any.
+type Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	// MaxMessage bounds the size of a read message, all fragments
+	// together; a bigger one closes the connection with CloseTooBig. 0
+	// means DefaultMaxMessage.
+	MaxMessage int
+
+	wmu    sync.Mutex
+	closed bool
+}
+
+// Upgrade completes the handshake of a WebSocket request. When the client
+// offers subprotocols, the first of protocols it offers is selected. On
+// failure an HTTP error has been written.
+func Upgrade(w http.ResponseWriter, r *http.Request, protocols ...string) (*Conn, error) {
+	if r.Method != http.MethodGet ||
+		!headerHas(r.Header, "Connection", "upgrade") ||
+		!headerHas(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: not an upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("websocket: missing key")
+	}
+	var protocol string
+	for _, p := range protocols {
+		if headerHas(r.Header, "Sec-WebSocket-Protocol", p) {
+			protocol = p
+			break
+		}
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: response does not support hijacking")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	resp := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n"
+	if protocol != "" {
+		resp += "Sec-WebSocket-Protocol: " + protocol + "\r\n"
+	}
+	if _, err := conn.Write([]byte(resp + "\r\n")); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+	return &Conn{conn: conn, r: rw.Reader}, nil
+}
+
+// CheckOrigin reports whether the Origin of r may open a WebSocket, so a
+// page on another site cannot use a browser's ambient credentials
+// (cookies, client certificates) against the server. Requests without an
+// Origin, from non-browser clients, pass. Otherwise the origin must be the
+// request's own host, or one of allowed: "*" for any origin, a
+// scheme://host[:port] origin compared case-insensitively, or one with a
+// "*." wildcard for subdomains, e.g. "https://*.example.com".
+func CheckOrigin(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(a, origin) {
+			return true
+		}
+		scheme, host, ok := strings.Cut(a, "://*.")
+		if ok && strings.EqualFold(scheme, u.Scheme) &&
+			len(u.Host) > len(host)+1 && strings.EqualFold(u.Host[len(u.Host)-len(host)-1:], "."+host) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerHas reports whether a comma-separated header contains token,
+// case-insensitively.
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next text or binary message, answering pings
+// meanwhile. It returns ErrClosed after the peer's close frame (which it
+// acknowledges).
+func (c *Conn) ReadMessage() (op int, msg []byte, err error) {
+	limit := c.MaxMessage
+	if limit <= 0 {
+		limit = DefaultMaxMessage
+	}
+	for {
+		fin, opcode, payload, err := c.readFrame(limit - len(msg))
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			code := CloseNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			_ = c.CloseWith(code, "")
+			return 0, nil, ErrClosed
+		case opContinuation:
+			if op == 0 {
+				return 0, nil, c.fail(CloseProtocol, "unexpected continuation frame")
+			}
+		case Text, Binary:
+			if op != 0 {
+				return 0, nil, c.fail(CloseProtocol, "expected continuation frame")
+			}
+			op = int(opcode)
+		default:
+			return 0, nil, c.fail(CloseProtocol, "unknown opcode")
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return op, msg, nil
+		}
+	}
+}
+
+// readFrame reads one frame. A data frame may carry at most limit payload
+// bytes, a control frame 125.
+func (c *Conn) readFrame(limit int) (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode = head[0]&0x80 != 0, head[0]&0x0f
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(CloseProtocol, "reserved bits set")
+	}
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, c.fail(CloseProtocol, "client frame not masked")
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= opClose && (n > 125 || !fin) {
+		return false, 0, nil, c.fail(CloseProtocol, "invalid control frame")
+	}
+	if opcode < opClose && n > uint64(max(limit, 0)) {
+		return false, 0, nil, c.fail(CloseTooBig, "message too big")
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(c.r, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage sends msg as one text or binary frame.
+func (c *Conn) WriteMessage(op int, msg []byte) error {
+	return c.writeFrame(byte(op), msg)
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	head := make([]byte, 2, 10+len(payload))
+	head[0] = 0x80 | opcode
+	switch n := len(payload); {
+	case n < 126:
+		head[1] = byte(n)
+	case n <= 0xffff:
+		head[1] = 126
+		head = binary.BigEndian.AppendUint16(head, uint16(n))
+	default:
+		head[1] = 127
+		head = binary.BigEndian.AppendUint64(head, uint64(n))
+	}
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := c.conn.Write(append(head, payload...))
+	return err
+}
+
+// fail closes the connection with code after a protocol violation and
+// returns the matching error.
+func (c *Conn) fail(code int, reason string) error {
+	_ = c.CloseWith(code, reason)
+	return errors.New("websocket: " + reason)
+}
+
+// CloseWith sends a close frame with code and reason and closes the
+// connection.
+func (c *Conn) CloseWith(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	_ = c.writeFrame(opClose, append(payload, reason...))
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.conn.Close()
+}
+
+// Close closes the connection normally.
+func (c *Conn) Close() error {
+	return c.CloseWith(CloseNormal, "")
+}
+
+// RemoteAddr returns the peer's address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
@@ -0,0 +1,393 @@
+package websocket_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go/internal/websocket"
+)
+
+// newEchoServer serves WebSockets that echo each message until
+// ReadMessage fails, with the "realtime" subprotocol and maxMessage.
+// The failure of each connection is sent on the returned channel.
+func newEchoServer(t *testing.T, maxMessage int) (*httptest.Server, <-chan error) {
+	t.Helper()
+	errs := make(chan error, 1)
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Upgrade(w, r, "realtime")
+		if err != nil {
+			return
+		}
+		c.MaxMessage = maxMessage
+		for {
+			op, msg, err := c.ReadMessage()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if err := c.WriteMessage(op, msg); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}))
+	t.Cleanup(hs.Close)
+	return hs, errs
+}
+
+// client is the test side of a connection.
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// handshake sends an upgrade request edited by tweak, if set, and returns
+// the response, and on 101 the connection, closed with the test.
+func handshake(t *testing.T, hs *httptest.Server, tweak func(*http.Request)) (*http.Response, *client) {
+	t.Helper()
+	conn, err := net.Dial("tcp", hs.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, hs.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if tweak != nil {
+		tweak(req)
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	c := &client{conn: conn, r: bufio.NewReader(conn)}
+	resp, err := http.ReadResponse(c.r, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return resp, nil
+	}
+	t.Cleanup(func() { conn.Close() })
+	return resp, c
+}
+
+func dial(t *testing.T, hs *httptest.Server) *client {
+	t.Helper()
+	resp, c := handshake(t, hs, nil)
+	if c == nil {
+		t.Fatalf("handshake: %s", resp.Status)
+	}
+	return c
+}
+
+// raw writes b as is.
+func (c *client) raw(t *testing.T, b []byte) {
+	t.Helper()
+	if _, err := c.conn.Write(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// write sends one frame, masked with a random key.
+func (c *client) write(t *testing.T, fin bool, opcode byte, payload []byte) {
+	t.Helper()
+	c.raw(t, maskedFrame(fin, opcode, payload))
+}
+
+func maskedFrame(fin bool, opcode byte, payload []byte) []byte {
+	head := []byte{opcode, 0x80}
+	if fin {
+		head[0] |= 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		head[1] |= byte(n)
+	case n <= 0xffff:
+		head[1] |= 126
+		head = binary.BigEndian.AppendUint16(head, uint16(n))
+	default:
+		head[1] |= 127
+		head = binary.BigEndian.AppendUint64(head, uint64(n))
+	}
+	var mask [4]byte
+	_, _ = rand.Read(mask[:])
+	b := append(head, mask[:]...)
+	for i, p := range payload {
+		b = append(b, p^mask[i%4])
+	}
+	return b
+}
+
+// frame reads one server frame, which must be unmasked and final, and
+// checks that its length uses the shortest encoding.
+func (c *client) frame(t *testing.T) (opcode byte, payload []byte) {
+	t.Helper()
+	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	if head[0]&0xf0 != 0x80 || head[1]&0x80 != 0 {
+		t.Fatalf("server frame header %08b %08b: want FIN, no reserved bits and no mask", head[0], head[1])
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			t.Fatal(err)
+		}
+		if n = uint64(binary.BigEndian.Uint16(ext[:])); n < 126 {
+			t.Fatalf("16-bit length %d", n)
+		}
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			t.Fatal(err)
+		}
+		if n = binary.BigEndian.Uint64(ext[:]); n <= 0xffff {
+			t.Fatalf("64-bit length %d", n)
+		}
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return head[0] & 0x0f, payload
+}
+
+// expectClose reads the server's close frame and checks its status code,
+// then that the server closed the connection.
+func (c *client) expectClose(t *testing.T, code int) {
+	t.Helper()
+	op, payload := c.frame(t)
+	if op != 8 || len(payload) < 2 {
+		t.Fatalf("got opcode %d %q, want a close frame", op, payload)
+	}
+	if got := int(binary.BigEndian.Uint16(payload)); got != code {
+		t.Errorf("close code %d (%q), want %d", got, payload[2:], code)
+	}
+	// EOF, or a reset when the server left input unread.
+	if b, err := c.r.ReadByte(); err == nil {
+		t.Errorf("read %#x after close, want the connection closed", b)
+	}
+}
+
+// TestUpgrade checks the handshake response: the accept value of RFC 6455
+// section 1.3 and the selected subprotocol.
+func TestUpgrade(t *testing.T) {
+	hs, _ := newEchoServer(t, 0)
+	for _, tc := range []struct {
+		offer, want string
+	}{
+		{"", ""},
+		{"chat", ""},
+		{"openai-insecure-api-key.k, realtime", "realtime"},
+	} {
+		resp, c := handshake(t, hs, func(r *http.Request) {
+			if tc.offer != "" {
+				r.Header.Set("Sec-WebSocket-Protocol", tc.offer)
+			}
+		})
+		if c == nil {
+			t.Fatalf("offer %q: %s", tc.offer, resp.Status)
+		}
+		if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+			t.Errorf("Sec-WebSocket-Accept %q", got)
+		}
+		if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") || !strings.EqualFold(resp.Header.Get("Connection"), "upgrade") {
+			t.Errorf("response headers %v", resp.Header)
+		}
+		if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != tc.want {
+			t.Errorf("offer %q: subprotocol %q, want %q", tc.offer, got, tc.want)
+		}
+	}
+}
+
+func TestUpgradeRefused(t *testing.T) {
+	hs, _ := newEchoServer(t, 0)
+	for _, tc := range []struct {
+		name   string
+		tweak  func(*http.Request)
+		status int
+	}{
+		{"POST", func(r *http.Request) { r.Method = http.MethodPost }, http.StatusUpgradeRequired},
+		{"no Upgrade", func(r *http.Request) { r.Header.Del("Upgrade") }, http.StatusUpgradeRequired},
+		{"no Connection: upgrade", func(r *http.Request) { r.Header.Set("Connection", "keep-alive") }, http.StatusUpgradeRequired},
+		{"version 8", func(r *http.Request) { r.Header.Set("Sec-WebSocket-Version", "8") }, http.StatusBadRequest},
+		{"no key", func(r *http.Request) { r.Header.Del("Sec-WebSocket-Key") }, http.StatusBadRequest},
+	} {
+		resp, c := handshake(t, hs, tc.tweak)
+		if c != nil || resp.StatusCode != tc.status {
+			t.Errorf("%s: %s, want %d", tc.name, resp.Status, tc.status)
+		}
+		if tc.status == http.StatusBadRequest && tc.name == "version 8" && resp.Header.Get("Sec-WebSocket-Version") != "13" {
+			t.Errorf("%s: Sec-WebSocket-Version %q, want 13", tc.name, resp.Header.Get("Sec-WebSocket-Version"))
+		}
+	}
+}
+
+// TestMasking uses the masked and unmasked "Hello" frames of RFC 6455
+// section 5.7.
+func TestMasking(t *testing.T) {
+	hs, _ := newEchoServer(t, 0)
+	c := dial(t, hs)
+	c.raw(t, []byte{0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58})
+	want := []byte{0x81, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f}
+	got := make([]byte, len(want))
+	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(c.r, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("echo % x, want % x", got, want)
+	}
+}
+
+// TestFraming echoes messages across the 7-, 16- and 64-bit length
+// encodings in both directions.
+func TestFraming(t *testing.T) {
+	hs, _ := newEchoServer(t, 0)
+	c := dial(t, hs)
+	for _, n := range []int{0, 1, 125, 126, 0xffff, 0x10000, 300000} {
+		msg := make([]byte, n)
+		_, _ = rand.Read(msg)
+		c.write(t, true, websocket.Binary, msg)
+		op, got := c.frame(t)
+		if op != websocket.Binary || !bytes.Equal(got, msg) {
+			t.Errorf("%d bytes: echo opcode %d with %d bytes, or different", n, op, len(got))
+		}
+	}
+}
+
+// TestFragments checks that fragments are reassembled and that control
+// frames between them are answered.
+func TestFragments(t *testing.T) {
+	hs, _ := newEchoServer(t, 0)
+	c := dial(t, hs)
+	c.write(t, false, websocket.Text, []byte("hel"))
+	c.write(t, true, 9, []byte("are you there"))
+	c.write(t, false, 0, []byte("lo, "))
+	c.write(t, true, 10, []byte("unsolicited pong"))
+	c.write(t, true, 0, []byte("world"))
+	if op, payload := c.frame(t); op != 10 || string(payload) != "are you there" {
+		t.Errorf("got opcode %d %q, want the pong of the ping", op, payload)
+	}
+	if op, payload := c.frame(t); op != websocket.Text || string(payload) != "hello, world" {
+		t.Errorf("got opcode %d %q, want the reassembled message", op, payload)
+	}
+}
+
+// TestClose checks the closing handshake: the server answers the
+// client's close frame with its status code and ReadMessage returns
+// ErrClosed.
+func TestClose(t *testing.T) {
+	hs, errs := newEchoServer(t, 0)
+	c := dial(t, hs)
+	c.write(t, true, 8, append(binary.BigEndian.AppendUint16(nil, websocket.CloseGoingAway), "bye"...))
+	c.expectClose(t, websocket.CloseGoingAway)
+	if err := <-errs; !errors.Is(err, websocket.ErrClosed) {
+		t.Errorf("ReadMessage: %v, want ErrClosed", err)
+	}
+}
+
+// TestProtocolErrors checks that frames RFC 6455 forbids close the
+// connection with the matching status.
+func TestProtocolErrors(t *testing.T) {
+	unmasked := []byte{0x81, 0x02, 'h', 'i'}
+	long := make([]byte, 126)
+	for _, tc := range []struct {
+		name   string
+		frames [][]byte
+		code   int
+	}{
+		{"unmasked", [][]byte{unmasked}, websocket.CloseProtocol},
+		{"reserved bits", [][]byte{{0xc1, 0x80, 0, 0, 0, 0}}, websocket.CloseProtocol},
+		{"unknown opcode", [][]byte{maskedFrame(true, 3, nil)}, websocket.CloseProtocol},
+		{"leading continuation", [][]byte{maskedFrame(true, 0, []byte("x"))}, websocket.CloseProtocol},
+		{"interleaved message", [][]byte{maskedFrame(false, 1, []byte("a")), maskedFrame(true, 1, []byte("b"))}, websocket.CloseProtocol},
+		{"fragmented ping", [][]byte{maskedFrame(false, 9, nil)}, websocket.CloseProtocol},
+		{"long ping", [][]byte{maskedFrame(true, 9, long)}, websocket.CloseProtocol},
+		{"message too big", [][]byte{maskedFrame(true, 2, make([]byte, 65))}, websocket.CloseTooBig},
+		{"fragments too big", [][]byte{maskedFrame(false, 2, make([]byte, 40)), maskedFrame(true, 0, make([]byte, 40))}, websocket.CloseTooBig},
+		// The length alone fails the frame, before any payload is read.
+		{"huge length", [][]byte{{0x82, 0xff, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}}, websocket.CloseTooBig},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hs, errs := newEchoServer(t, 64)
+			c := dial(t, hs)
+			for _, f := range tc.frames {
+				c.raw(t, f)
+			}
+			c.expectClose(t, tc.code)
+			if err := <-errs; err == nil || errors.Is(err, websocket.ErrClosed) {
+				t.Errorf("ReadMessage: %v, want a protocol error", err)
+			}
+		})
+	}
+}
+
+// TestDefaultMaxMessage checks that a zero MaxMessage means
+// DefaultMaxMessage.
+func TestDefaultMaxMessage(t *testing.T) {
+	hs, _ := newEchoServer(t, 0)
+	c := dial(t, hs)
+	msg := make([]byte, websocket.DefaultMaxMessage)
+	c.write(t, true, websocket.Binary, msg)
+	if _, got := c.frame(t); len(got) != len(msg) {
+		t.Fatalf("echo of %d bytes, want %d", len(got), len(msg))
+	}
+	c.write(t, true, websocket.Binary, append(msg, 0))
+	c.expectClose(t, websocket.CloseTooBig)
+}
+
+func TestCheckOrigin(t *testing.T) {
+	allowed := []string{"https://app.example.com", "https://*.example.org"}
+	for _, tc := range []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},                            // not a browser
+		{"http://smartturn.local:8080", true}, // same host
+		{"https://app.example.com", true},
+		{"HTTPS://APP.EXAMPLE.COM", true},
+		{"http://app.example.com", false},
+		{"https://app.example.com:8443", false},
+		{"https://evil.example.com", false},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://evilexample.org", false},
+		{"http://a.example.org", false},
+		{"null", false},
+		{"https://smartturn.local:8080.evil.com", false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "http://smartturn.local:8080/v1/realtime", nil)
+		if tc.origin != "" {
+			r.Header.Set("Origin", tc.origin)
+		}
+		if got := websocket.CheckOrigin(r, allowed); got != tc.ok {
+			t.Errorf("origin %q: %v, want %v", tc.origin, got, tc.ok)
+		}
+	}
+	r := httptest.NewRequest(http.MethodGet, "http://smartturn.local/", nil)
+	r.Header.Set("Origin", "https://anywhere.example")
+	if !websocket.CheckOrigin(r, []string{"*"}) {
+		t.Error(`"*" refused an origin`)
+	}
+}
@@ -0,0 +1,327 @@
+// Package journal records the exact sequence of audio chunks (with their
+// media times), gaps, lifecycle calls, and callback events of an engine session to a file, and replays it
+// against a fresh engine to reproduce endpointing bugs offline.
+//
+// Recording, in place of calling the engine directly:
+//
+//	rec := journal.NewRecorder(f)
+//	engine, err := smartturn.New(cfg, rec.Wrap(callbacks))
+//	rec.Attach(engine)
+//	rec.Start(); rec.PushPCM(chunk); ...; err = rec.CloseJournal()
+//
+// Replaying with the same Config (models, thresholds, backends):
+//
+//	j, err := journal.Read(f)
+//	rp := journal.NewReplayer(j)
+//	engine, err := smartturn.New(cfg, rp.Callbacks())
+//	mismatches := rp.Run(engine)
+//
+// The engine is driven by chunk count, not wall-clock time, so a replay with
+// deterministic backends emits the recorded events at the same points.
+package journal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// magic starts every journal file; the digit is the format version.
+// Version 2 added OpPushPCMAt and OpPushGap; Read also accepts version 1.
+const (
+	magic   = "SMARTTURN-JOURNAL 2\n"
+	magicV1 = "SMARTTURN-JOURNAL 1\n"
+)
+
+// ErrFormat is returned by Read for input that is not a journal.
+var ErrFormat = errors.New("journal: invalid format")
+
+// Op is an input given to the engine.
+type Op uint8
+
+const (
+	OpPushPCM Op = iota + 1
+	OpStart
+	OpStop
+	OpReset
+	OpPushPCMAt
+	OpPushGap
+)
+
+func (o Op) String() string {
+	switch o {
+	case OpPushPCM:
+		return "PushPCM"
+	case OpStart:
+		return "Start"
+	case OpStop:
+		return "Stop"
+	case OpReset:
+		return "Reset"
+	case OpPushPCMAt:
+		return "PushPCMAt"
+	case OpPushGap:
+		return "PushGap"
+	}
+	return "Op(" + strconv.Itoa(int(o)) + ")"
+}
+
+// EventKind identifies the callback that produced an Event.
+type EventKind uint8
+
+const (
+	ListeningStarted EventKind = iota + 1
+	ListeningStopped
+	SpeechStart
+	SpeechEnd
+	SegmentReady
+	TurnPrediction
+	Error
+)
+
+var eventNames = [...]string{
+	ListeningStarted: "ListeningStarted",
+	ListeningStopped: "ListeningStopped",
+	SpeechStart:      "SpeechStart",
+	SpeechEnd:        "SpeechEnd",
+	SegmentReady:     "SegmentReady",
+	TurnPrediction:   "TurnPrediction",
+	Error:            "Error",
+}
+
+func (k EventKind) String() string {
+	if int(k) < len(eventNames) && eventNames[k] != "" {
+		return eventNames[k]
+	}
+	return "EventKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Event is one callback invocation. Only the fields of its Kind are set.
+// OnChunk is not recorded: it echoes every accepted chunk. Of a
+// TurnPrediction, only the deterministic decision and probability are kept.
+type Event struct {
+	Kind        EventKind
+	Samples     int     // SegmentReady: slice length
+	Complete    bool    // TurnPrediction
+	Probability float32 // TurnPrediction
+	Err         string  // Error: err.Error()
+}
+
+func (e Event) String() string {
+	switch e.Kind {
+	case SegmentReady:
+		return fmt.Sprintf("SegmentReady(%d)", e.Samples)
+	case TurnPrediction:
+		return fmt.Sprintf("TurnPrediction(%t, %.4f)", e.Complete, e.Probability)
+	case Error:
+		return fmt.Sprintf("Error(%q)", e.Err)
+	}
+	return e.Kind.String()
+}
+
+// Step is one engine input and the events it produced.
+type Step struct {
+	Op     Op
+	Chunk  []float32     // OpPushPCM and OpPushPCMAt
+	Time   time.Time     // OpPushPCMAt: media time of the chunk, zero if unset
+	Gap    time.Duration // OpPushGap
+	Events []Event
+}
+
+// Journal is a recorded session.
+type Journal struct {
+	Initial []Event // emitted by smartturn.New, before the first op
+	Steps   []Step
+}
+
+// Record tags in the file. Each step is an op record followed by its event
+// records.
+const (
+	tagOp    = 'O'
+	tagEvent = 'E'
+)
+
+type encoder struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+func (e *encoder) op(s Step) {
+	e.buf = append(e.buf[:0], tagOp, byte(s.Op))
+	switch s.Op {
+	case OpPushPCMAt:
+		// A zero time is a 0 flag byte, so it needs no Unix offset.
+		if s.Time.IsZero() {
+			e.buf = append(e.buf, 0)
+		} else {
+			e.buf = append(e.buf, 1)
+			e.buf = binary.AppendVarint(e.buf, s.Time.UnixNano())
+		}
+		fallthrough
+	case OpPushPCM:
+		e.buf = binary.AppendUvarint(e.buf, uint64(len(s.Chunk)))
+		for _, v := range s.Chunk {
+			e.buf = binary.LittleEndian.AppendUint32(e.buf, math.Float32bits(v))
+		}
+	case OpPushGap:
+		e.buf = binary.AppendVarint(e.buf, int64(s.Gap))
+	}
+	_, _ = e.w.Write(e.buf)
+}
+
+func (e *encoder) event(ev Event) {
+	e.buf = append(e.buf[:0], tagEvent, byte(ev.Kind))
+	switch ev.Kind {
+	case SegmentReady:
+		e.buf = binary.AppendUvarint(e.buf, uint64(ev.Samples))
+	case TurnPrediction:
+		var c byte
+		if ev.Complete {
+			c = 1
+		}
+		e.buf = append(e.buf, c)
+		e.buf = binary.LittleEndian.AppendUint32(e.buf, math.Float32bits(ev.Probability))
+	case Error:
+		e.buf = binary.AppendUvarint(e.buf, uint64(len(ev.Err)))
+		e.buf = append(e.buf, ev.Err...)
+	}
+	_, _ = e.w.Write(e.buf)
+}
+
+// Read parses a journal written by a Recorder.
+func Read(r io.Reader) (*Journal, error) {
+	br := bufio.NewReader(r)
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(br, head); err != nil || (string(head) != magic && string(head) != magicV1) {
+		return nil, ErrFormat
+	}
+	j := &Journal{}
+	for {
+		tag, err := br.ReadByte()
+		if err == io.EOF {
+			return j, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		kind, err := br.ReadByte()
+		if err != nil {
+			return nil, corrupt(err)
+		}
+		switch tag {
+		case tagOp:
+			s, err := readStep(br, Op(kind))
+			if err != nil {
+				return nil, corrupt(err)
+			}
+			j.Steps = append(j.Steps, s)
+		case tagEvent:
+			ev, err := readEvent(br, EventKind(kind))
+			if err != nil {
+				return nil, corrupt(err)
+			}
+			if len(j.Steps) == 0 {
+				j.Initial = append(j.Initial, ev)
+			} else {
+				last := &j.Steps[len(j.Steps)-1]
+				last.Events = append(last.Events, ev)
+			}
+		default:
+			return nil, ErrFormat
+		}
+	}
+}
+
+func readStep(br *bufio.Reader, op Op) (Step, error) {
+	s := Step{Op: op}
+	var err error
+	switch op {
+	case OpPushPCMAt:
+		set, err := br.ReadByte()
+		if err != nil {
+			return s, err
+		}
+		if set != 0 {
+			ns, err := binary.ReadVarint(br)
+			if err != nil {
+				return s, err
+			}
+			s.Time = time.Unix(0, ns)
+		}
+		fallthrough
+	case OpPushPCM:
+		s.Chunk, err = readFloats(br)
+	case OpPushGap:
+		var d int64
+		d, err = binary.ReadVarint(br)
+		s.Gap = time.Duration(d)
+	}
+	return s, err
+}
+
+// maxChunk bounds the chunk length read from a file.
+const maxChunk = 1 << 20
+
+func readFloats(br *bufio.Reader) ([]float32, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxChunk {
+		return nil, ErrFormat
+	}
+	raw := make([]byte, 4*n)
+	if _, err := io.ReadFull(br, raw); err != nil {
+		return nil, err
+	}
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[4*i:]))
+	}
+	return out, nil
+}
+
+func readEvent(br *bufio.Reader, kind EventKind) (Event, error) {
+	ev := Event{Kind: kind}
+	switch kind {
+	case SegmentReady:
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return ev, err
+		}
+		ev.Samples = int(n)
+	case TurnPrediction:
+		var raw [5]byte
+		if _, err := io.ReadFull(br, raw[:]); err != nil {
+			return ev, err
+		}
+		ev.Complete = raw[0] != 0
+		ev.Probability = math.Float32frombits(binary.LittleEndian.Uint32(raw[1:]))
+	case Error:
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return ev, err
+		}
+		if n > maxChunk {
+			return ev, ErrFormat
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(br, msg); err != nil {
+			return ev, err
+		}
+		ev.Err = string(msg)
+	}
+	return ev, nil
+}
+
+func corrupt(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: truncated", ErrFormat)
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package journal_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/journal"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+func newEngine(t *testing.T, cb smartturn.Callbacks) *smartturn.Engine {
+	t.Helper()
+	cfg := smartturn.ProfileConversational()
+	cfg.VADBackend = &smartturntest.EnergyVAD{}
+	cfg.TurnBackend = &smartturntest.TurnScript{Probabilities: []float32{0.9}}
+	cfg.TurnSegmentEmitMs = 500
+	e, err := smartturn.New(cfg, cb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+	return e
+}
+
+// TestRecordReplay records a session fed through every input a journal
+// keeps, reads it back, and replays it on a fresh engine, which must emit
+// the same events at every step.
+func TestRecordReplay(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 5}.Generate(
+		smartturntest.Silence(300*time.Millisecond), smartturntest.Speech(time.Second), smartturntest.Silence(200*time.Millisecond))
+	chunks := smartturntest.Chunks(audio)
+
+	var buf bytes.Buffer
+	rec := journal.NewRecorder(&buf)
+	rec.Attach(newEngine(t, rec.Wrap(smartturn.Callbacks{})))
+	rec.Start()
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+	for i, c := range chunks {
+		var err error
+		if i%2 == 0 {
+			err = rec.PushPCM(c)
+		} else {
+			err = rec.PushPCMAt(c, t0.Add(time.Duration(i)*32*time.Millisecond))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The gap ends the turn: its silence runs VadStopMs and the model.
+	if err := rec.PushGap(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.PushPCMAt(chunks[0], time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	rec.Stop()
+	rec.Reset()
+	if err := rec.CloseJournal(); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := journal.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ops []journal.Op
+	var kinds []journal.EventKind
+	for _, s := range j.Steps {
+		if len(ops) == 0 || ops[len(ops)-1] != s.Op {
+			ops = append(ops, s.Op)
+		}
+		for _, ev := range s.Events {
+			kinds = append(kinds, ev.Kind)
+		}
+	}
+	if len(j.Steps) != len(chunks)+5 {
+		t.Fatalf("%d steps, want %d", len(j.Steps), len(chunks)+5)
+	}
+	at, gap, last := j.Steps[2], j.Steps[len(chunks)+1], j.Steps[len(chunks)+2]
+	if at.Op != journal.OpPushPCMAt || !at.Time.Equal(t0.Add(32*time.Millisecond)) || !slices.Equal(at.Chunk, chunks[1]) {
+		t.Errorf("PushPCMAt step %v %v, %d samples", at.Op, at.Time, len(at.Chunk))
+	}
+	if gap.Op != journal.OpPushGap || gap.Gap != 2*time.Second {
+		t.Errorf("PushGap step %v %v", gap.Op, gap.Gap)
+	}
+	if last.Op != journal.OpPushPCMAt || !last.Time.IsZero() {
+		t.Errorf("PushPCMAt step without a time: %v %v", last.Op, last.Time)
+	}
+	// The gap holds the end of the turn.
+	var gapKinds []journal.EventKind
+	for _, ev := range gap.Events {
+		gapKinds = append(gapKinds, ev.Kind)
+	}
+	if !slices.Contains(gapKinds, journal.SpeechEnd) || !slices.Contains(gapKinds, journal.TurnPrediction) {
+		t.Errorf("gap events %v, want the end of the turn", gap.Events)
+	}
+	if !slices.Contains(kinds, journal.SpeechStart) || !slices.Contains(kinds, journal.SegmentReady) {
+		t.Errorf("events %v", kinds)
+	}
+
+	rp := journal.NewReplayer(j)
+	if m := rp.Run(newEngine(t, rp.Callbacks())); len(m) != 0 {
+		t.Errorf("replay mismatches:\n%v", m)
+	}
+}
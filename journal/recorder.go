@@ -0,0 +1,167 @@
+package journal
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+)
+
+// Recorder writes a journal while forwarding calls to an engine. It is a
+// smartturn.Detector: call its methods instead of the engine's. PushPCMAt
+// and PushGap are forwarded to an engine that has them, such as a
+// *smartturn.Engine. Like the engine, it is not goroutine-safe, except for
+// Health.
+type Recorder struct {
+	enc    encoder
+	engine smartturn.Detector
+}
+
+var _ smartturn.Detector = (*Recorder)(nil)
+
+// timedDetector and gapDetector are the optional inputs of a Detector that
+// a journal records.
+type (
+	timedDetector interface {
+		PushPCMAt(chunk []float32, ts time.Time) error
+	}
+	gapDetector interface {
+		PushGap(d time.Duration) error
+	}
+)
+
+// ErrUnsupported is returned by PushPCMAt and PushGap of a Recorder whose
+// engine lacks the method; nothing is recorded then.
+var ErrUnsupported = errors.New("journal: engine does not support the operation")
+
+// NewRecorder starts a journal on w. Nothing is forwarded until Attach.
+func NewRecorder(w io.Writer) *Recorder {
+	r := &Recorder{enc: encoder{w: bufio.NewWriterSize(w, 64<<10)}}
+	_, _ = r.enc.w.WriteString(magic)
+	return r
+}
+
+// Wrap returns callbacks that record each event, then call cb. Pass the
+// result to smartturn.New.
+func (r *Recorder) Wrap(cb smartturn.Callbacks) smartturn.Callbacks {
+	out := cb
+	out.OnListeningStarted = func() {
+		r.enc.event(Event{Kind: ListeningStarted})
+		if cb.OnListeningStarted != nil {
+			cb.OnListeningStarted()
+		}
+	}
+	out.OnListeningStopped = func() {
+		r.enc.event(Event{Kind: ListeningStopped})
+		if cb.OnListeningStopped != nil {
+			cb.OnListeningStopped()
+		}
+	}
+	out.OnSpeechStart = func() {
+		r.enc.event(Event{Kind: SpeechStart})
+		if cb.OnSpeechStart != nil {
+			cb.OnSpeechStart()
+		}
+	}
+	out.OnSpeechEnd = func() {
+		r.enc.event(Event{Kind: SpeechEnd})
+		if cb.OnSpeechEnd != nil {
+			cb.OnSpeechEnd()
+		}
+	}
+	out.OnSegmentReady = func(segment []float32) {
+		r.enc.event(Event{Kind: SegmentReady, Samples: len(segment)})
+		if cb.OnSegmentReady != nil {
+			cb.OnSegmentReady(segment)
+		}
+	}
+	out.OnTurnPredictionDetail = func(p smartturn.TurnPrediction) {
+		r.enc.event(Event{Kind: TurnPrediction, Complete: p.Complete, Probability: p.Probability})
+		if cb.OnTurnPredictionDetail != nil {
+			cb.OnTurnPredictionDetail(p)
+		}
+	}
+	out.OnError = func(err error) {
+		r.enc.event(Event{Kind: Error, Err: err.Error()})
+		if cb.OnError != nil {
+			cb.OnError(err)
+		}
+	}
+	return out
+}
+
+// Attach sets the engine that calls are forwarded to.
+func (r *Recorder) Attach(e smartturn.Detector) {
+	r.engine = e
+}
+
+// Start records and forwards Start.
+func (r *Recorder) Start() {
+	r.enc.op(Step{Op: OpStart})
+	r.engine.Start()
+}
+
+// Stop records and forwards Stop.
+func (r *Recorder) Stop() {
+	r.enc.op(Step{Op: OpStop})
+	r.engine.Stop()
+}
+
+// Reset records and forwards Reset.
+func (r *Recorder) Reset() {
+	r.enc.op(Step{Op: OpReset})
+	r.engine.Reset()
+}
+
+// PushPCM records chunk and forwards it.
+func (r *Recorder) PushPCM(chunk []float32) error {
+	r.enc.op(Step{Op: OpPushPCM, Chunk: chunk})
+	return r.engine.PushPCM(chunk)
+}
+
+// PushPCMAt records chunk with its media time and forwards it.
+func (r *Recorder) PushPCMAt(chunk []float32, ts time.Time) error {
+	e, ok := r.engine.(timedDetector)
+	if !ok {
+		return ErrUnsupported
+	}
+	r.enc.op(Step{Op: OpPushPCMAt, Chunk: chunk, Time: ts})
+	return e.PushPCMAt(chunk, ts)
+}
+
+// PushGap records a gap in the audio and forwards it.
+func (r *Recorder) PushGap(d time.Duration) error {
+	e, ok := r.engine.(gapDetector)
+	if !ok {
+		return ErrUnsupported
+	}
+	r.enc.op(Step{Op: OpPushGap, Gap: d})
+	return e.PushGap(d)
+}
+
+// Health returns the engine's health; it is not recorded.
+func (r *Recorder) Health() smartturn.Health {
+	return r.engine.Health()
+}
+
+// Flush writes buffered records to the underlying writer.
+func (r *Recorder) Flush() error {
+	return r.enc.w.Flush()
+}
+
+// Close closes the engine, recording any errors it reports, and flushes the
+// journal; CloseJournal also returns the flush error. The underlying writer
+// is not closed.
+func (r *Recorder) Close() {
+	_ = r.CloseJournal()
+}
+
+// CloseJournal is Close returning the error of the final flush.
+func (r *Recorder) CloseJournal() error {
+	if r.engine != nil {
+		r.engine.Close()
+	}
+	return r.Flush()
+}
@@ -0,0 +1,123 @@
+package journal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cortexswarm/smart-turn-go"
+)
+
+// Mismatch is a step whose replayed events differ from the recorded ones.
+type Mismatch struct {
+	Step int // index into Journal.Steps; -1 for Journal.Initial
+	Op   Op
+	Want []Event
+	Got  []Event
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("step %d (%v): want [%s], got [%s]", m.Step, m.Op, joinEvents(m.Want), joinEvents(m.Got))
+}
+
+func joinEvents(evs []Event) string {
+	s := make([]string, len(evs))
+	for i, ev := range evs {
+		s[i] = ev.String()
+	}
+	return strings.Join(s, " ")
+}
+
+// Replayer feeds a journal to an engine and compares the events it emits
+// with the recorded ones.
+type Replayer struct {
+	j   *Journal
+	got []Event
+
+	// Tolerance is the largest TurnPrediction probability difference that
+	// still matches, e.g. 1e-4 when replaying on different hardware.
+	// Zero requires identical probabilities.
+	Tolerance float32
+}
+
+// NewReplayer returns a Replayer for j.
+func NewReplayer(j *Journal) *Replayer {
+	return &Replayer{j: j}
+}
+
+// Callbacks returns the callbacks to pass to smartturn.New for the engine
+// given to Run.
+func (r *Replayer) Callbacks() smartturn.Callbacks {
+	add := func(ev Event) { r.got = append(r.got, ev) }
+	return smartturn.Callbacks{
+		OnListeningStarted: func() { add(Event{Kind: ListeningStarted}) },
+		OnListeningStopped: func() { add(Event{Kind: ListeningStopped}) },
+		OnSpeechStart:      func() { add(Event{Kind: SpeechStart}) },
+		OnSpeechEnd:        func() { add(Event{Kind: SpeechEnd}) },
+		OnSegmentReady:     func(seg []float32) { add(Event{Kind: SegmentReady, Samples: len(seg)}) },
+		OnTurnPrediction: func(complete bool, probability float32) {
+			add(Event{Kind: TurnPrediction, Complete: complete, Probability: probability})
+		},
+		OnError: func(err error) { add(Event{Kind: Error, Err: err.Error()}) },
+	}
+}
+
+// Run applies every recorded step to e, whose callbacks must come from
+// Callbacks, and returns the steps whose events differ. PushPCM errors are
+// not compared; they surface as events only when the engine reports them.
+// A PushPCMAt or PushGap step that e does not support emits no events.
+// e is not closed.
+func (r *Replayer) Run(e smartturn.Detector) []Mismatch {
+	var out []Mismatch
+	if !r.match(r.j.Initial, r.got) {
+		out = append(out, Mismatch{Step: -1, Want: r.j.Initial, Got: r.got})
+	}
+	for i, s := range r.j.Steps {
+		r.got = nil
+		switch s.Op {
+		case OpPushPCM:
+			_ = e.PushPCM(s.Chunk)
+		case OpStart:
+			e.Start()
+		case OpStop:
+			e.Stop()
+		case OpReset:
+			e.Reset()
+		case OpPushPCMAt:
+			if t, ok := e.(timedDetector); ok {
+				_ = t.PushPCMAt(s.Chunk, s.Time)
+			}
+		case OpPushGap:
+			if g, ok := e.(gapDetector); ok {
+				_ = g.PushGap(s.Gap)
+			}
+		}
+		if !r.match(s.Events, r.got) {
+			out = append(out, Mismatch{Step: i, Op: s.Op, Want: s.Events, Got: r.got})
+		}
+	}
+	r.got = nil
+	return out
+}
+
+func (r *Replayer) match(want, got []Event) bool {
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		w, g := want[i], got[i]
+		if w.Kind == TurnPrediction && g.Kind == TurnPrediction {
+			d := w.Probability - g.Probability
+			if d < 0 {
+				d = -d
+			}
+			if w.Complete != g.Complete || d > r.Tolerance {
+				return false
+			}
+			continue
+		}
+		if w != g {
+			return false
+		}
+	}
+	return true
+}
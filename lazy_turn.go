@@ -0,0 +1,92 @@
+package smartturn
+
+import (
+	"errors"
+
+	"github.com/cortexswarm/smart-turn-go/features"
+)
+
+// newLazySmartTurn inspects the model graph like newSmartTurn, reading it
+// from the file, but defers creating the session (Config.LazySmartTurn) to
+// Engine.loadSmartTurn. No session exists until then.
+func newLazySmartTurn(modelPath string, params features.Params, so SessionOptions, ep ExecutionProvider) (*smartTurn, error) {
+	model, err := detectSmartTurnModel(modelPath, params)
+	if err != nil {
+		return nil, err
+	}
+	st, err := newSmartTurnWithBackend(model, &lazyTurnBackend{path: modelPath, model: model, so: so, ep: ep})
+	if err != nil {
+		return nil, err
+	}
+	st.path = modelPath
+	return st, nil
+}
+
+// lazyTurnBackend is an ortTurnBackend whose session is created by load.
+// Predict fails until then.
+type lazyTurnBackend struct {
+	path  string
+	model smartTurnModel
+	so    SessionOptions
+	ep    ExecutionProvider
+	ort   *ortTurnBackend // nil until loaded
+}
+
+var errTurnNotLoaded = errors.New("smart-turn model not loaded")
+
+// load creates the session, returning the provider error when it fell
+// back to CPU. It is a no-op once loaded.
+func (b *lazyTurnBackend) load() (fallbackErr, err error) {
+	if b.ort != nil {
+		return nil, nil
+	}
+	ob, fallbackErr, err := newORTTurnBackend(b.path, b.model, b.so, b.ep)
+	if err != nil {
+		return nil, err
+	}
+	b.ort = ob
+	return fallbackErr, nil
+}
+
+func (b *lazyTurnBackend) Predict(features []float32) (float32, error) {
+	if b.ort == nil {
+		return 0, errTurnNotLoaded
+	}
+	return b.ort.Predict(features)
+}
+
+func (b *lazyTurnBackend) lastOutputs() (float32, []ModelOutput) {
+	if b.ort == nil {
+		return 0, nil
+	}
+	return b.ort.lastOutputs()
+}
+
+func (b *lazyTurnBackend) Close() error {
+	if b.ort == nil {
+		return nil
+	}
+	return b.ort.Close()
+}
+
+// loadSmartTurn creates the session deferred by Config.LazySmartTurn. It
+// is a no-op when the session exists or the model is not lazy.
+func (e *Engine) loadSmartTurn() error {
+	if e.smartTurn == nil {
+		return nil
+	}
+	b, ok := e.smartTurn.backend.(*lazyTurnBackend)
+	if !ok || b.ort != nil {
+		return nil
+	}
+	start := e.clock.Now()
+	fallbackErr, err := b.load()
+	if err != nil {
+		return err
+	}
+	if fallbackErr != nil {
+		e.reportError("smart-turn execution provider unavailable", fallbackErr)
+	}
+	e.log.Info("smart-turn model loaded", "load_ms", e.clock.Now().Sub(start).Milliseconds())
+	return nil
+}
@@ -0,0 +1,34 @@
+package smartturn
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardLogger is used when Config.Logger is nil.
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// reportError logs err, counts it in Health, and passes it to OnError.
+func (e *Engine) reportError(msg string, err error) {
+	e.log.Error(msg, "err", err)
+	e.health.error(err, e.clock.Now())
+	e.record(Event{Kind: EventError, Err: err})
+	if e.cb.OnError != nil {
+		e.cb.OnError(err)
+	}
+}
+
+// logs reports whether the logger records level. Log calls on the chunk
+// path check it first, so their arguments are not boxed into interfaces
+// when logging is off.
+func (e *Engine) logs(level slog.Level) bool {
+	return e.log.Enabled(context.Background(), level)
+}
+
+// kind names the Smart-Turn input convention for logs.
+func (st *smartTurn) kind() string {
+	if st.model.input == smartTurnRaw {
+		return "raw"
+	}
+	return "mel"
+}
@@ -0,0 +1,34 @@
+package smartturn
+
+import "time"
+
+// stamp sets the media time of the chunk about to be processed: ts when
+// the caller gave one, else the last timestamp extended by the audio
+// accepted since, else the arrival time now.
+func (e *Engine) stamp(ts, now time.Time) {
+	switch {
+	case !ts.IsZero():
+		e.mediaBase, e.mediaOffset = ts, e.samples
+	case e.mediaBase.IsZero():
+		e.chunkTime = now
+		return
+	}
+	e.chunkTime = e.mediaBase.Add(time.Duration(e.samples-e.mediaOffset) * (time.Second / RequiredSampleRate))
+}
+
+// MediaTime returns the media time of the chunk being processed, for
+// stamping callback events; Event.Time carries the same for Process. It
+// is the timestamp given to PushPCMAt or ProcessAt, so turn boundaries
+// follow the source's clock however bursty or delayed the audio arrives.
+// A chunk pushed without one continues the last timestamp by the audio
+// accepted since (32 ms per chunk); before any timestamp it is the arrival
+// time. Call it from callbacks or between calls, like PushPCM.
+func (e *Engine) MediaTime() time.Time {
+	return e.chunkTime
+}
+
+// mediaTimeAt returns the media time of sample offset, counted like
+// e.samples, relative to the chunk being processed.
+func (e *Engine) mediaTimeAt(offset int64) time.Time {
+	return e.chunkTime.Add(time.Duration(offset-(e.samples-RequiredChunkSize)) * (time.Second / RequiredSampleRate))
+}
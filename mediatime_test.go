@@ -0,0 +1,134 @@
+package smartturn_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+var (
+	arrival = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rtp     = time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	chunkMs = 32 * time.Millisecond
+)
+
+// mediaSteps are pushes of one chunk each: stamped with ts unless zero,
+// after the arrival clock moved by advance, and the media time expected.
+var mediaSteps = []struct {
+	ts      time.Time
+	advance time.Duration
+	want    time.Time
+}{
+	// Before any timestamp: the arrival time.
+	{time.Time{}, 0, arrival},
+	{time.Time{}, 100 * time.Millisecond, arrival.Add(100 * time.Millisecond)},
+	// Timestamps are followed, and extrapolated 32 ms per chunk however
+	// the audio arrives.
+	{rtp, time.Second, rtp},
+	{time.Time{}, 0, rtp.Add(chunkMs)},
+	{time.Time{}, time.Second, rtp.Add(2 * chunkMs)},
+	{time.Time{}, 0, rtp.Add(3 * chunkMs)},
+	// A jump either way is followed.
+	{rtp.Add(time.Second), 0, rtp.Add(time.Second)},
+	{time.Time{}, 0, rtp.Add(time.Second + chunkMs)},
+	{rtp.Add(time.Second + 10*time.Millisecond), 0, rtp.Add(time.Second + 10*time.Millisecond)},
+	{time.Time{}, 0, rtp.Add(time.Second + 10*time.Millisecond + chunkMs)},
+}
+
+// mediaTimes pushes mediaSteps to an engine, returning MediaTime as seen
+// from OnVadScore for each chunk.
+func mediaTimes(t *testing.T, tweak func(*smartturn.Config)) []time.Time {
+	clk := smartturntest.NewClock(arrival)
+	times := make(chan time.Time, len(mediaSteps))
+	var e *smartturn.Engine
+	e = newTestEngine(t, nil, smartturn.Callbacks{
+		OnVadScore: func(float32, int64) { times <- e.MediaTime() },
+	}, func(cfg *smartturn.Config) {
+		cfg.Clock = clk
+		if tweak != nil {
+			tweak(cfg)
+		}
+	})
+	chunk := make([]float32, smartturn.RequiredChunkSize)
+	var got []time.Time
+	for _, s := range mediaSteps {
+		clk.Advance(s.advance)
+		if err := e.PushPCMAt(chunk, s.ts); err != nil {
+			t.Fatal(err)
+		}
+		// Wait for a queued chunk before moving the clock again.
+		select {
+		case ts := <-times:
+			got = append(got, ts)
+		case <-time.After(5 * time.Second):
+			t.Fatal("chunk not processed")
+		}
+	}
+	return got
+}
+
+func TestMediaTime(t *testing.T) {
+	for name, tweak := range map[string]func(*smartturn.Config){
+		"sync":   nil,
+		"queued": func(cfg *smartturn.Config) { cfg.InputQueue.Size = 4 },
+	} {
+		for i, got := range mediaTimes(t, tweak) {
+			if want := mediaSteps[i].want; !got.Equal(want) {
+				t.Errorf("%s: chunk %d: MediaTime %v, want %v", name, i, got.Format(time.StampMilli), want.Format(time.StampMilli))
+			}
+		}
+	}
+}
+
+// TestMediaTimeEvents checks that Process events carry the media time of
+// their chunk and a turn starts at the media time of its first sample,
+// at either input rate.
+func TestMediaTimeEvents(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 13}.Generate(
+		smartturntest.Silence(time.Second), smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	for _, tc := range []struct {
+		name  string
+		tweak func(*smartturn.Config)
+		size  int
+		audio []float32
+	}{
+		{"16 kHz", nil, smartturn.RequiredChunkSize, audio},
+		{"8 kHz", telephony, smartturn.TelephonyChunkSize, decimate(audio)},
+	} {
+		var starts []smartturn.TurnStart
+		e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+			OnTurnStart: func(s smartturn.TurnStart) { starts = append(starts, s) },
+		}, tc.tweak)
+		kinds := map[smartturn.EventKind]bool{}
+		for i := 0; (i+1)*tc.size <= len(tc.audio); i++ {
+			ts := time.Time{}
+			if i == 0 {
+				ts = rtp
+			}
+			events, err := e.ProcessAt(tc.audio[i*tc.size:(i+1)*tc.size], ts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := rtp.Add(time.Duration(i) * chunkMs)
+			for _, ev := range events {
+				kinds[ev.Kind] = true
+				if !ev.Time.Equal(want) {
+					t.Errorf("%s: chunk %d: event %v at %v, want %v", tc.name, i, ev.Kind, ev.Time, want)
+				}
+				if ev.Kind == smartturn.EventTurnStart {
+					starts = append(starts, ev.TurnStart)
+				}
+			}
+		}
+		if !kinds[smartturn.EventSpeechStart] || !kinds[smartturn.EventSpeechEnd] || len(starts) != 2 {
+			t.Fatalf("%s: events %v, %d turn starts", tc.name, kinds, len(starts))
+		}
+		for _, s := range starts {
+			if want := rtp.Add(time.Duration(s.Offset) * time.Second / smartturn.RequiredSampleRate); !s.Time.Equal(want) || s.Offset == 0 {
+				t.Errorf("%s: turn at offset %d starts at %v, want %v", tc.name, s.Offset, s.Time, want)
+			}
+		}
+	}
+}
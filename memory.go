@@ -0,0 +1,89 @@
+package smartturn
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// MemoryStats reports the audio an engine holds, for servers running many
+// sessions to watch for turns that grow without bound.
+type MemoryStats struct {
+	// BufferedSamples is the audio of the current speech segment, at
+	// 16 kHz; zero outside speech.
+	BufferedSamples int
+	// BufferedBytes is the memory allocated for audio: segment and
+	// pre-speech buffers, OnSegmentReady slices, the turn held for
+	// TurnExport, and the InputQueue.
+	BufferedBytes int64
+	// EvictedSamples counts audio dropped by Config.MaxBufferedAudioMs
+	// since New, and Evictions the segments that reached it.
+	EvictedSamples uint64
+	Evictions      uint64
+}
+
+// memoryStats backs Engine.MemoryStats; the engine goroutine updates it
+// after every chunk.
+type memoryStats struct {
+	buffered  atomic.Int64
+	bytes     atomic.Int64
+	evicted   atomic.Uint64
+	evictions atomic.Uint64
+}
+
+// MemoryStats returns the engine's buffered audio. Like Health it may be
+// called from any goroutine.
+func (e *Engine) MemoryStats() MemoryStats {
+	return MemoryStats{
+		BufferedSamples: int(e.mem.buffered.Load()),
+		BufferedBytes:   e.mem.bytes.Load(),
+		EvictedSamples:  e.mem.evicted.Load(),
+		Evictions:       e.mem.evictions.Load(),
+	}
+}
+
+// trackMemory publishes the size of the engine's audio buffers.
+func (e *Engine) trackMemory() {
+	buffered := 0
+	if e.segmenter.speechActive {
+		buffered = len(e.segmenter.segment)
+	}
+	samples := e.segmenter.capacity() + cap(e.emitBuf)
+	if e.exporter != nil {
+		samples += cap(e.exporter.audio)
+	}
+	if e.cfg.InputQueue.Size > 0 {
+		samples += (e.cfg.InputQueue.Size + 1) * RequiredChunkSize
+	}
+	e.mem.buffered.Store(int64(buffered))
+	e.mem.bytes.Store(int64(samples) * 4)
+}
+
+// evicted accounts for n samples dropped from the start of the current
+// segment and warns at the first eviction of the segment.
+func (e *Engine) evicted(n int, segStart int64) {
+	e.segmentEmittedSoFar = max(0, e.segmentEmittedSoFar-n)
+	e.mem.evicted.Add(uint64(n))
+	// Cached mel frames of the audio kept stay valid.
+	if e.smartTurn != nil {
+		e.smartTurn.dropSegment(n)
+	}
+	if e.shadow != nil {
+		e.shadow.dropSegment(n)
+	}
+	if e.evicting {
+		return
+	}
+	e.evicting = true
+	e.mem.evictions.Add(1)
+	ev := AudioEviction{
+		Turn:   e.turnID,
+		Offset: segStart,
+		Budget: time.Duration(e.cfg.MaxBufferedAudioMs) * time.Millisecond,
+	}
+	e.log.Warn("segment reached MaxBufferedAudioMs; evicting oldest audio",
+		"turn", ev.Turn, "budget_ms", e.cfg.MaxBufferedAudioMs)
+	e.record(Event{Kind: EventAudioEvicted, Eviction: ev})
+	if e.cb.OnAudioEvicted != nil {
+		e.cb.OnAudioEvicted(ev)
+	}
+}
@@ -0,0 +1,89 @@
+package smartturn_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// TestAudioEviction checks that a segment longer than MaxBufferedAudioMs
+// keeps at most the budget, evicts an eighth of it at a time, and reports
+// the first eviction of each segment.
+func TestAudioEviction(t *testing.T) {
+	var evictions []smartturn.AudioEviction
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+		OnAudioEvicted: func(ev smartturn.AudioEviction) { evictions = append(evictions, ev) },
+	}, func(c *smartturn.Config) { c.MaxBufferedAudioMs = 1000 })
+
+	// 1000 ms is 32 chunks; an eighth of it is 4.
+	const (
+		budget = 32 * smartturn.RequiredChunkSize
+		slack  = 4 * smartturn.RequiredChunkSize
+	)
+	audio, _ := smartturntest.Synth{Seed: 7}.Generate(
+		smartturntest.Silence(time.Second), smartturntest.Speech(3*time.Second), smartturntest.Silence(time.Second),
+		smartturntest.Speech(2*time.Second), smartturntest.Silence(time.Second))
+	var (
+		prev, evicted, moves, events int
+		offsets                      []int64
+		pushed                       int64
+	)
+	for _, c := range smartturntest.Chunks(audio) {
+		evs, err := e.Process(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pushed += int64(len(c))
+		for _, ev := range evs {
+			if ev.Kind == smartturn.EventAudioEvicted {
+				events++
+			}
+		}
+		m := e.MemoryStats()
+		if len(evictions) > len(offsets) {
+			// Offset is the first sample still buffered.
+			offsets = append(offsets, pushed-int64(m.BufferedSamples))
+		}
+		if m.BufferedSamples > budget {
+			t.Fatalf("buffered %d samples, budget %d", m.BufferedSamples, budget)
+		}
+		if m.BufferedBytes < int64(m.BufferedSamples)*4 {
+			t.Fatalf("%d bytes for %d samples", m.BufferedBytes, m.BufferedSamples)
+		}
+		if prev > 0 && m.BufferedSamples > 0 && m.BufferedSamples < prev+len(c) {
+			// The segment grew by the chunk and lost its oldest audio.
+			n := prev + len(c) - m.BufferedSamples
+			if n != len(c)+slack {
+				t.Fatalf("evicted %d samples, want %d", n, len(c)+slack)
+			}
+			evicted += n
+			moves++
+		}
+		prev = m.BufferedSamples
+	}
+
+	m := e.MemoryStats()
+	if m.BufferedSamples != 0 {
+		t.Errorf("buffered %d samples after the turns", m.BufferedSamples)
+	}
+	if m.Evictions != 2 || len(evictions) != 2 || events != 2 {
+		t.Fatalf("Evictions %d, OnAudioEvicted %d, events %d; want 2 each", m.Evictions, len(evictions), events)
+	}
+	if int(m.EvictedSamples) != evicted {
+		t.Errorf("EvictedSamples %d, want %d", m.EvictedSamples, evicted)
+	}
+	// A segment runs from VadPreSpeechMs before the speech to VadStopMs
+	// after it: 110 chunks for the first turn and 79 for the second,
+	// overflowing the budget by 78 and 47. With 5 chunks evicted per move
+	// the buffer is moved 16 + 10 times, not once per chunk.
+	if moves != 26 {
+		t.Errorf("buffer moved %d times, want 26", moves)
+	}
+	for i, ev := range evictions {
+		if ev.Turn != i || ev.Budget != time.Second || ev.Offset != offsets[i] {
+			t.Errorf("eviction %d: %+v, want offset %d", i, ev, offsets[i])
+		}
+	}
+}
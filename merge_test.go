@@ -0,0 +1,86 @@
+package smartturn_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// TestTurnMerge checks that speech resuming within TurnMergeGapMs of an
+// end of turn continues it, and that a longer pause starts a new turn.
+func TestTurnMerge(t *testing.T) {
+	const chunk = 32 * time.Millisecond
+	for _, tc := range []struct {
+		name  string
+		gapMs int
+		pause time.Duration
+		want  string
+		// The turn ends VadStopMs (300) into the pause, so a merged
+		// resume comes pause-300ms after it.
+		wantGap time.Duration
+	}{
+		{"disabled", 0, 600 * time.Millisecond, "turn0 start end turn1 start end", 0},
+		{"within gap", 800, 600 * time.Millisecond, "turn0 start end merged end", 300 * time.Millisecond},
+		{"after gap", 800, 1500 * time.Millisecond, "turn0 start end turn1 start end", 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			var gap time.Duration
+			cb := smartturn.Callbacks{
+				OnTurnStart:   func(s smartturn.TurnStart) { got = append(got, fmt.Sprintf("turn%d", s.ID)) },
+				OnSpeechStart: func() { got = append(got, "start") },
+				OnSpeechEnd:   func() { got = append(got, "end") },
+				OnTurnMerged: func(m smartturn.TurnMerge) {
+					got = append(got, "merged")
+					gap = m.Gap
+				},
+			}
+			e := newTestEngine(t, []float32{0.9}, cb, func(cfg *smartturn.Config) { cfg.TurnMergeGapMs = tc.gapMs })
+			audio, _ := smartturntest.Synth{Seed: 4}.Generate(
+				smartturntest.Speech(time.Second), smartturntest.Silence(tc.pause),
+				smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+			pushAll(t, e, audio)
+			if s := strings.Join(got, " "); s != tc.want {
+				t.Errorf("callbacks %q, want %q", s, tc.want)
+			}
+			if d := gap - tc.wantGap; d < -2*chunk || d > 2*chunk {
+				t.Errorf("TurnMerge.Gap = %v, want %v", gap, tc.wantGap)
+			}
+		})
+	}
+}
+
+// TestTurnMergeProcess checks that Process reports a merge as
+// EventTurnMerged in place of EventSpeechStart.
+func TestTurnMergeProcess(t *testing.T) {
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{}, func(cfg *smartturn.Config) { cfg.TurnMergeGapMs = 800 })
+	audio, _ := smartturntest.Synth{Seed: 4}.Generate(
+		smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond),
+		smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	var kinds []smartturn.EventKind
+	for _, c := range smartturntest.Chunks(audio) {
+		events, err := e.Process(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ev := range events {
+			switch ev.Kind {
+			case smartturn.EventSpeechStart, smartturn.EventSpeechEnd, smartturn.EventTurnMerged:
+				kinds = append(kinds, ev.Kind)
+			}
+		}
+	}
+	want := []smartturn.EventKind{smartturn.EventSpeechStart, smartturn.EventSpeechEnd, smartturn.EventTurnMerged, smartturn.EventSpeechEnd}
+	if len(kinds) != len(want) {
+		t.Fatalf("events %v, want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("events %v, want %v", kinds, want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Package metrics exports engine measurements as Prometheus metrics.
+//
+//	m := metrics.New(metrics.Options{})
+//	prometheus.MustRegister(m)
+//	cfg.Observer = m // one Collector may be shared by all engines
+//
+// Exported series (namespace "smartturn" by default):
+//   - chunks_processed_total: chunks run through VAD
+//   - vad_inference_seconds: VAD latency per chunk (histogram)
+//   - speech_segments_total{end="silence"|"max_duration"}: finished segments
+//   - speech_segment_duration_seconds: segment length (histogram)
+//   - turn_predictions_total{outcome="end_of_turn"|"incomplete"|"error"}
+//   - turn_inference_seconds: Smart-Turn latency incl. features (histogram)
+//   - inference_errors_total{stage="vad"|"smart_turn"}
+//   - queue_depth: chunks waiting to be processed, when Options.QueueDepth is set
+//
+// With Options.PerTenant, every series but queue_depth also carries a
+// tenant label, and the engines of a tenant report through Tenant(id):
+//
+//	m := metrics.New(metrics.Options{PerTenant: true})
+//	cfg.Observer = m.Tenant("acme")
+package metrics
+
+import (
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Options configures a Collector. The zero value is valid.
+type Options struct {
+	// Namespace prefixes every metric name; default "smartturn".
+	Namespace string
+	// ConstLabels are attached to every series (e.g. {"model": "v3.2"}).
+	ConstLabels prometheus.Labels
+	// QueueDepth, if set, is sampled on every scrape as the queue_depth gauge,
+	// for applications that buffer audio before PushPCM; with
+	// smartturn.Config.InputQueue, sample the engine's Health().QueuedChunks.
+	QueueDepth func() float64
+	// PerTenant adds a tenant label to the engine series, set by the
+	// Observer that Tenant returns; the Collector itself reports as
+	// tenant "".
+	PerTenant bool
+}
+
+// Collector is a prometheus.Collector and a smartturn.Observer. It is safe
+// for concurrent use, so several engines can report into one Collector.
+type Collector struct {
+	chunks          *prometheus.CounterVec
+	vadLatency      *prometheus.HistogramVec
+	segments        *prometheus.CounterVec
+	segmentDuration *prometheus.HistogramVec
+	predictions     *prometheus.CounterVec
+	turnLatency     *prometheus.HistogramVec
+	errors          *prometheus.CounterVec
+	queueDepth      prometheus.GaugeFunc // nil without Options.QueueDepth
+
+	perTenant  bool
+	collectors []prometheus.Collector
+	tenant     // the Collector's own series, tenant ""
+}
+
+var (
+	_ smartturn.Observer = (*Collector)(nil)
+	_ smartturn.Observer = tenant{}
+)
+
+// New creates a Collector. Register it with a prometheus.Registerer and set
+// it as Config.Observer.
+func New(opts Options) *Collector {
+	ns := opts.Namespace
+	if ns == "" {
+		ns = "smartturn"
+	}
+	labels := opts.ConstLabels
+	// tenantLabel appends the tenant label to a series' own labels.
+	tenantLabel := func(names ...string) []string {
+		if opts.PerTenant {
+			names = append(names, "tenant")
+		}
+		return names
+	}
+	c := &Collector{
+		chunks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns, Name: "chunks_processed_total", ConstLabels: labels,
+			Help: "Audio chunks run through VAD.",
+		}, tenantLabel()),
+		vadLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: ns, Name: "vad_inference_seconds", ConstLabels: labels,
+			Help:    "VAD inference latency per chunk.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 2, 12), // 0.1ms .. 205ms
+		}, tenantLabel()),
+		segments: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns, Name: "speech_segments_total", ConstLabels: labels,
+			Help: "Finished speech segments by how they ended.",
+		}, tenantLabel("end")),
+		segmentDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: ns, Name: "speech_segment_duration_seconds", ConstLabels: labels,
+			Help:    "Length of finished speech segments, including pre-speech padding.",
+			Buckets: prometheus.ExponentialBuckets(0.25, 2, 10), // 0.25s .. 128s
+		}, tenantLabel()),
+		predictions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns, Name: "turn_predictions_total", ConstLabels: labels,
+			Help: "Smart-Turn predictions by outcome.",
+		}, tenantLabel("outcome")),
+		turnLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: ns, Name: "turn_inference_seconds", ConstLabels: labels,
+			Help:    "Smart-Turn latency per prediction, including feature extraction.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 10), // 5ms .. 2.56s
+		}, tenantLabel()),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns, Name: "inference_errors_total", ConstLabels: labels,
+			Help: "Failed inference calls by stage.",
+		}, tenantLabel("stage")),
+		perTenant: opts.PerTenant,
+	}
+	c.tenant = c.Tenant("").(tenant)
+	c.collectors = []prometheus.Collector{c.chunks, c.vadLatency, c.segments, c.segmentDuration, c.predictions, c.turnLatency, c.errors}
+	if opts.QueueDepth != nil {
+		c.queueDepth = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: ns, Name: "queue_depth", ConstLabels: labels,
+			Help: "Audio chunks waiting to be processed.",
+		}, opts.QueueDepth)
+		c.collectors = append(c.collectors, c.queueDepth)
+	}
+	return c
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.collectors {
+		m.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c.collectors {
+		m.Collect(ch)
+	}
+}
+
+// Tenant returns the Observer for the engines of tenant id, whose series
+// carry it as the tenant label with Options.PerTenant; without, they are
+// the Collector's own. The first call for an id creates its series.
+func (c *Collector) Tenant(id string) smartturn.Observer {
+	t := tenant{c: c}
+	if c.perTenant {
+		t.labels = []string{id}
+	}
+	// Pre-create label values so that all series exist from the first scrape.
+	t.with(c.chunks)
+	t.with(c.segments, "silence")
+	t.with(c.segments, "max_duration")
+	for _, o := range []string{"end_of_turn", "incomplete", "error"} {
+		t.with(c.predictions, o)
+	}
+	t.with(c.errors, "vad")
+	t.with(c.errors, "smart_turn")
+	for _, h := range []*prometheus.HistogramVec{c.vadLatency, c.segmentDuration, c.turnLatency} {
+		h.WithLabelValues(t.labels...)
+	}
+	return t
+}
+
+// tenant is the Observer of one tenant's series.
+type tenant struct {
+	c      *Collector
+	labels []string // the tenant label value; nil without Options.PerTenant
+}
+
+// with returns the counter of v for label values lvs and the tenant.
+func (t tenant) with(v *prometheus.CounterVec, lvs ...string) prometheus.Counter {
+	return v.WithLabelValues(append(lvs, t.labels...)...)
+}
+
+// observe records x in the tenant's series of v.
+func (t tenant) observe(v *prometheus.HistogramVec, x float64) {
+	v.WithLabelValues(t.labels...).Observe(x)
+}
+
+// VADInference implements smartturn.Observer.
+func (t tenant) VADInference(d time.Duration, _ float32, err error) {
+	t.with(t.c.chunks).Inc()
+	if err != nil {
+		t.with(t.c.errors, "vad").Inc()
+		return
+	}
+	t.observe(t.c.vadLatency, d.Seconds())
+}
+
+// SegmentStarted implements smartturn.Observer.
+func (t tenant) SegmentStarted() {}
+
+// SegmentEnded implements smartturn.Observer.
+func (t tenant) SegmentEnded(samples int, bySilence bool) {
+	end := "silence"
+	if !bySilence {
+		end = "max_duration"
+	}
+	t.with(t.c.segments, end).Inc()
+	t.observe(t.c.segmentDuration, float64(samples)/smartturn.RequiredSampleRate)
+}
+
+// TurnInference implements smartturn.Observer.
+func (t tenant) TurnInference(d time.Duration, _ float32, endOfTurn bool, err error) {
+	switch {
+	case err != nil:
+		t.with(t.c.predictions, "error").Inc()
+		t.with(t.c.errors, "smart_turn").Inc()
+		return
+	case endOfTurn:
+		t.with(t.c.predictions, "end_of_turn").Inc()
+	default:
+		t.with(t.c.predictions, "incomplete").Inc()
+	}
+	t.observe(t.c.turnLatency, d.Seconds())
+}
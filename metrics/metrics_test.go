@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestCollector checks the series of a Collector without tenants: they
+// exist from the first scrape and count the Observer calls.
+func TestCollector(t *testing.T) {
+	c := New(Options{})
+	if n := testutil.CollectAndCount(c); n != 11 {
+		t.Errorf("%d series before any observation, want 11", n)
+	}
+	c.VADInference(time.Millisecond, 0.9, nil)
+	c.TurnInference(10*time.Millisecond, 0.8, true, nil)
+	c.SegmentEnded(16000, true)
+	want := `
+# HELP smartturn_turn_predictions_total Smart-Turn predictions by outcome.
+# TYPE smartturn_turn_predictions_total counter
+smartturn_turn_predictions_total{outcome="end_of_turn"} 1
+smartturn_turn_predictions_total{outcome="error"} 0
+smartturn_turn_predictions_total{outcome="incomplete"} 0
+# HELP smartturn_chunks_processed_total Audio chunks run through VAD.
+# TYPE smartturn_chunks_processed_total counter
+smartturn_chunks_processed_total 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want),
+		"smartturn_turn_predictions_total", "smartturn_chunks_processed_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestCollectorPerTenant checks that with PerTenant each tenant's
+// Observer counts into its own series.
+func TestCollectorPerTenant(t *testing.T) {
+	c := New(Options{PerTenant: true})
+	acme, other := c.Tenant("acme"), c.Tenant("other")
+	acme.VADInference(time.Millisecond, 0.9, nil)
+	acme.VADInference(time.Millisecond, 0.1, nil)
+	other.VADInference(time.Millisecond, 0.1, nil)
+	other.TurnInference(time.Millisecond, 0, false, errTest{})
+	want := `
+# HELP smartturn_chunks_processed_total Audio chunks run through VAD.
+# TYPE smartturn_chunks_processed_total counter
+smartturn_chunks_processed_total{tenant=""} 0
+smartturn_chunks_processed_total{tenant="acme"} 2
+smartturn_chunks_processed_total{tenant="other"} 1
+# HELP smartturn_inference_errors_total Failed inference calls by stage.
+# TYPE smartturn_inference_errors_total counter
+smartturn_inference_errors_total{stage="smart_turn",tenant=""} 0
+smartturn_inference_errors_total{stage="smart_turn",tenant="acme"} 0
+smartturn_inference_errors_total{stage="smart_turn",tenant="other"} 1
+smartturn_inference_errors_total{stage="vad",tenant=""} 0
+smartturn_inference_errors_total{stage="vad",tenant="acme"} 0
+smartturn_inference_errors_total{stage="vad",tenant="other"} 0
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want),
+		"smartturn_chunks_processed_total", "smartturn_inference_errors_total"); err != nil {
+		t.Error(err)
+	}
+
+	// Without PerTenant, tenants share the Collector's series.
+	c = New(Options{})
+	c.Tenant("acme").VADInference(time.Millisecond, 0.9, nil)
+	c.VADInference(time.Millisecond, 0.9, nil)
+	if n := testutil.ToFloat64(c.chunks); n != 2 {
+		t.Errorf("chunks_processed_total = %v without PerTenant, want 2", n)
+	}
+}
+
+type errTest struct{}
+
+func (errTest) Error() string { return "test error" }
@@ -0,0 +1,31 @@
+//go:build android
+
+package smartturn
+
+/*
+#include <stdint.h>
+
+typedef void *(*appendNnapiFn)(void *options, uint32_t flags);
+
+static void *callAppendNnapi(void *fn, void *options, uint32_t flags) {
+	return ((appendNnapiFn)fn)(options, flags);
+}
+*/
+import "C"
+
+import (
+	"errors"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// appendNNAPI calls OrtSessionOptionsAppendExecutionProvider_Nnapi, which
+// onnxruntime_go does not wrap, from the ONNX Runtime library it loaded.
+func appendNNAPI(opts *ort.SessionOptions, flags uint32) error {
+	fn := ortSymbol("OrtSessionOptionsAppendExecutionProvider_Nnapi")
+	if fn == nil {
+		return errors.New("ONNX Runtime library has no NNAPI provider")
+	}
+	status := C.callAppendNnapi(fn, nativeSessionOptions(opts), C.uint32_t(flags))
+	return ortStatusError(status, "append NNAPI provider")
+}
@@ -0,0 +1,15 @@
+//go:build !android
+
+package smartturn
+
+import (
+	"errors"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// appendNNAPI is unreachable off Android: providerSupported rejects NNAPI
+// first.
+func appendNNAPI(*ort.SessionOptions, uint32) error {
+	return errors.New("NNAPI requires Android")
+}
@@ -0,0 +1,128 @@
+package smartturn
+
+import (
+	"errors"
+	"math"
+
+	"github.com/cortexswarm/smart-turn-go/features"
+)
+
+// NonSpeechClassifier rejects VAD triggers that are not speech, such as
+// hold music, background TV, or machine noise (Config.NonSpeech). Classify
+// sees every chunk, so it can track context across them; its answer only
+// matters for chunks VAD scored as speech, which then count as silence.
+// Called from the engine's goroutine.
+type NonSpeechClassifier interface {
+	// Classify reports whether a 512-sample 16 kHz chunk (upsampled at
+	// 8 kHz) is non-speech audio.
+	Classify(chunk []float32) (nonSpeech bool, err error)
+	// Reset clears state (called by Engine.Reset).
+	Reset()
+}
+
+// Defaults for SpectralRejector.
+const (
+	DefaultMaxFlatness     = 0.45
+	DefaultMinModulationDB = 3.0
+	DefaultModulationMs    = 1000
+)
+
+// SpectralRejector is a lightweight NonSpeechClassifier built on two
+// heuristics. Noise has a flat spectrum, while speech concentrates energy
+// in harmonics and formants; and speech energy rises and falls with
+// syllables several times a second, while music beds and hum stay level.
+// Thresholds are per deployment: tune them on recordings of the noise to
+// reject, since loud, dynamic music will still pass.
+type SpectralRejector struct {
+	// MaxFlatness is the spectral flatness (0 tonal to 1 white noise, over
+	// 100 Hz to 4 kHz) above which a chunk is noise (default 0.45).
+	MaxFlatness float64
+	// MinModulationDB is the standard deviation of chunk energy (dB) over
+	// ModulationMs below which the audio is too steady to be speech
+	// (default 3).
+	MinModulationDB float64
+	ModulationMs    int // default 1000
+
+	spectrum *features.Spectrum
+	power    []float32
+	energy   []float64 // ring of chunk energies in dB
+	next     int
+	filled   bool
+}
+
+// NewSpectralRejector returns r with defaults applied to zero fields.
+func NewSpectralRejector(r SpectralRejector) (*SpectralRejector, error) {
+	if r.MaxFlatness < 0 || r.MaxFlatness > 1 || r.MinModulationDB < 0 || r.ModulationMs < 0 {
+		return nil, errors.New("smart-turn: SpectralRejector: MaxFlatness must be in [0, 1], MinModulationDB and ModulationMs >= 0")
+	}
+	if r.MaxFlatness == 0 {
+		r.MaxFlatness = DefaultMaxFlatness
+	}
+	if r.MinModulationDB == 0 {
+		r.MinModulationDB = DefaultMinModulationDB
+	}
+	if r.ModulationMs == 0 {
+		r.ModulationMs = DefaultModulationMs
+	}
+	r.spectrum = features.NewSpectrum(RequiredChunkSize)
+	r.power = make([]float32, r.spectrum.Bins())
+	r.energy = make([]float64, max(ceilDiv(r.ModulationMs, 32), 2))
+	return &r, nil
+}
+
+// Classify implements NonSpeechClassifier.
+func (r *SpectralRejector) Classify(chunk []float32) (bool, error) {
+	if len(chunk) != RequiredChunkSize {
+		return false, errChunkSize
+	}
+	var sum float64
+	for _, v := range chunk {
+		sum += float64(v) * float64(v)
+	}
+	// Silence between words is what modulates speech; floor it at -60 dB
+	// so digital silence does not dominate the spread.
+	db := 10 * math.Log10(math.Max(sum/RequiredChunkSize, 1e-6))
+	r.energy[r.next] = db
+	r.next = (r.next + 1) % len(r.energy)
+	if r.next == 0 {
+		r.filled = true
+	}
+	if r.filled && stddev(r.energy) < r.MinModulationDB {
+		return true, nil
+	}
+	return r.flatness(chunk) > r.MaxFlatness, nil
+}
+
+// Reset implements NonSpeechClassifier.
+func (r *SpectralRejector) Reset() {
+	r.next, r.filled = 0, false
+}
+
+// flatness is the ratio of the geometric to the arithmetic mean power
+// between 100 Hz and 4 kHz.
+func (r *SpectralRejector) flatness(chunk []float32) float64 {
+	p := r.spectrum.Power(r.power, chunk)
+	lo := 100 * RequiredChunkSize / RequiredSampleRate
+	hi := 4000 * RequiredChunkSize / RequiredSampleRate
+	var logSum, sum float64
+	for _, v := range p[lo : hi+1] {
+		x := float64(v) + 1e-12
+		logSum += math.Log(x)
+		sum += x
+	}
+	n := float64(hi - lo + 1)
+	return math.Exp(logSum/n) / (sum / n)
+}
+
+func stddev(xs []float64) float64 {
+	var mean float64
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	var v float64
+	for _, x := range xs {
+		v += (x - mean) * (x - mean)
+	}
+	return math.Sqrt(v / float64(len(xs)))
+}
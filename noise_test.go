@@ -0,0 +1,168 @@
+package smartturn_test
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// rejected returns how many chunks of audio r classifies as non-speech,
+// past the first window chunks it needs to judge modulation.
+func rejected(t *testing.T, r *smartturn.SpectralRejector, audio []float32, window int) (n, total int) {
+	t.Helper()
+	for i, c := range smartturntest.Chunks(audio) {
+		nonSpeech, err := r.Classify(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i < window {
+			continue
+		}
+		total++
+		if nonSpeech {
+			n++
+		}
+	}
+	return n, total
+}
+
+// synthesize returns d of audio with sample i at f(i/16000 s).
+func synthesize(d time.Duration, f func(t float64) float64) []float32 {
+	out := make([]float32, int(d*16000/time.Second))
+	for i := range out {
+		out[i] = float32(f(float64(i) / 16000))
+	}
+	return out
+}
+
+func TestSpectralRejector(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	sine := func(f, a float64) func(float64) float64 {
+		return func(t float64) float64 { return a * math.Sin(2*math.Pi*f*t) }
+	}
+	nonSpeech := map[string][]float32{
+		"white noise": synthesize(3*time.Second, func(float64) float64 { return 0.2 * rng.NormFloat64() }),
+		"chord": synthesize(3*time.Second, func(t float64) float64 {
+			return sine(262, 0.2)(t) + sine(330, 0.2)(t) + sine(392, 0.2)(t)
+		}),
+		"mains hum": synthesize(3*time.Second, func(t float64) float64 {
+			return sine(50, 0.3)(t) + sine(150, 0.1)(t) + sine(250, 0.05)(t)
+		}),
+	}
+	// The modulation window is 1 s, 32 chunks.
+	const window = 32
+	for name, audio := range nonSpeech {
+		r, err := smartturn.NewSpectralRejector(smartturn.SpectralRejector{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, total := rejected(t, r, audio, window); n != total {
+			t.Errorf("%s: %d of %d chunks rejected, want all", name, n, total)
+		}
+	}
+
+	for seed := uint64(1); seed <= 5; seed++ {
+		audio, _ := smartturntest.Synth{Seed: seed}.Generate(smartturntest.Speech(3 * time.Second))
+		r, _ := smartturn.NewSpectralRejector(smartturn.SpectralRejector{})
+		if n, total := rejected(t, r, audio, 0); n != 0 {
+			t.Errorf("speech (seed %d): %d of %d chunks rejected, want none", seed, n, total)
+		}
+	}
+
+	// White noise is rejected by flatness alone, from the first chunk.
+	r, _ := smartturn.NewSpectralRejector(smartturn.SpectralRejector{})
+	if n, total := rejected(t, r, nonSpeech["white noise"][:512*4], 0); n != total {
+		t.Errorf("white noise before the window fills: %d of %d chunks rejected", n, total)
+	}
+}
+
+// TestSpectralRejectorReset checks that Reset starts a new modulation
+// window, so a steady sound is judged afresh.
+func TestSpectralRejectorReset(t *testing.T) {
+	chord := synthesize(2*time.Second, func(t float64) float64 { return 0.3 * math.Sin(2*math.Pi*262*t) })
+	chunks := smartturntest.Chunks(chord)
+	r, _ := smartturn.NewSpectralRejector(smartturn.SpectralRejector{ModulationMs: 320})
+	for i, c := range chunks[:20] {
+		got, _ := r.Classify(c)
+		if want := i >= 9; got != want {
+			t.Fatalf("chunk %d: non-speech %v, want %v", i, got, want)
+		}
+	}
+	r.Reset()
+	if got, _ := r.Classify(chunks[20]); got {
+		t.Error("steady tone rejected right after Reset")
+	}
+}
+
+func TestNewSpectralRejector(t *testing.T) {
+	r, err := smartturn.NewSpectralRejector(smartturn.SpectralRejector{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.MaxFlatness != smartturn.DefaultMaxFlatness || r.MinModulationDB != smartturn.DefaultMinModulationDB || r.ModulationMs != smartturn.DefaultModulationMs {
+		t.Errorf("defaults %v, %v, %v", r.MaxFlatness, r.MinModulationDB, r.ModulationMs)
+	}
+	for _, bad := range []smartturn.SpectralRejector{
+		{MaxFlatness: -0.1}, {MaxFlatness: 1.1}, {MinModulationDB: -1}, {ModulationMs: -1},
+	} {
+		if _, err := smartturn.NewSpectralRejector(bad); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+	if _, err := r.Classify(make([]float32, 100)); err == nil {
+		t.Error("Classify accepted a 100-sample chunk")
+	}
+	chunk := smartturntest.Chunks(synthesize(32*time.Millisecond, func(t float64) float64 { return math.Sin(2 * math.Pi * 200 * t) }))[0]
+	if allocs := testing.AllocsPerRun(100, func() { _, _ = r.Classify(chunk) }); allocs != 0 {
+		t.Errorf("Classify allocates %v times per chunk", allocs)
+	}
+}
+
+// nonSpeechScript is a NonSpeechClassifier with a fixed answer.
+type nonSpeechScript struct {
+	nonSpeech bool
+	err       error
+	calls     int
+	resets    int
+}
+
+func (c *nonSpeechScript) Classify([]float32) (bool, error) {
+	c.calls++
+	return c.nonSpeech, c.err
+}
+
+func (c *nonSpeechScript) Reset() { c.resets++ }
+
+func TestNonSpeech(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 3}.Generate(smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	for _, tc := range []struct {
+		name   string
+		c      *nonSpeechScript
+		starts int
+		errs   int
+	}{
+		{"speech", &nonSpeechScript{}, 1, 0},
+		{"non-speech", &nonSpeechScript{nonSpeech: true}, 0, 0},
+		// A failing classifier leaves the VAD decision.
+		{"failing", &nonSpeechScript{nonSpeech: true, err: errors.New("classifier failed")}, 1, len(smartturntest.Chunks(audio))},
+	} {
+		starts, errs := 0, 0
+		e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+			OnSpeechStart: func() { starts++ },
+			OnError:       func(error) { errs++ },
+		}, func(cfg *smartturn.Config) { cfg.NonSpeech = tc.c })
+		pushAll(t, e, audio)
+		if starts != tc.starts || errs != tc.errs || tc.c.calls != len(smartturntest.Chunks(audio)) {
+			t.Errorf("%s: %d speech starts, %d errors, %d calls", tc.name, starts, errs, tc.c.calls)
+		}
+		e.Reset()
+		if tc.c.resets != 1 {
+			t.Errorf("%s: %d classifier resets after Reset, want 1", tc.name, tc.c.resets)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package smartturn
+
+import "time"
+
+// Observer receives measurements from the processing pipeline for metrics
+// and tracing (see the metrics package). It is set through Config.Observer.
+// Methods are called synchronously from PushPCM after the step they
+// describe and must not block; one Observer may be shared by several engines,
+// in which case it must be safe for concurrent use.
+type Observer interface {
+	// VADInference reports one VAD backend call (one chunk while listening).
+	VADInference(d time.Duration, prob float32, err error)
+	// SegmentStarted reports that VAD opened a speech segment. Unlike
+	// OnSpeechStart it also fires for segments inside a pending turn.
+	SegmentStarted()
+	// SegmentEnded reports a finished segment of the given length in
+	// samples; bySilence is false when it was cut at TurnMaxDurationSeconds.
+	SegmentEnded(samples int, bySilence bool)
+	// TurnInference reports one Smart-Turn call (features and model).
+	// endOfTurn is the engine's decision: whether OnSpeechEnd fires.
+	TurnInference(d time.Duration, prob float32, endOfTurn bool, err error)
+}
+
+// Observers combines several observers (e.g. metrics and tracing) into one
+// that calls each in order. Nil entries are skipped.
+func Observers(obs ...Observer) Observer {
+	var list multiObserver
+	for _, o := range obs {
+		if o != nil {
+			list = append(list, o)
+		}
+	}
+	return list
+}
+
+type multiObserver []Observer
+
+func (m multiObserver) VADInference(d time.Duration, prob float32, err error) {
+	for _, o := range m {
+		o.VADInference(d, prob, err)
+	}
+}
+
+func (m multiObserver) SegmentStarted() {
+	for _, o := range m {
+		o.SegmentStarted()
+	}
+}
+
+func (m multiObserver) SegmentEnded(samples int, bySilence bool) {
+	for _, o := range m {
+		o.SegmentEnded(samples, bySilence)
+	}
+}
+
+func (m multiObserver) TurnInference(d time.Duration, prob float32, endOfTurn bool, err error) {
+	for _, o := range m {
+		o.TurnInference(d, prob, endOfTurn, err)
+	}
+}
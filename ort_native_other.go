@@ -0,0 +1,20 @@
+//go:build !linux && !darwin
+
+package smartturn
+
+import (
+	"errors"
+	"runtime"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// enableProfiling would need OrtApi::EnableProfiling with a wide-character
+// prefix on Windows; the native access it relies on is Unix only.
+func enableProfiling(*ort.SessionOptions, string) error {
+	return errors.New("ONNX Runtime profiling is not supported on " + runtime.GOOS)
+}
+
+// libraryVersion is unknown before initialization off Unix; acquireRuntime
+// checks the version once the library is loaded.
+func libraryVersion(string) string { return "" }
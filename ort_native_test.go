@@ -0,0 +1,363 @@
+//go:build linux
+
+package smartturn
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cortexswarm/smart-turn-go/features"
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// fakeORT builds testdata/fakeort and loads it as the process's ONNX
+// Runtime for the test. The returned function returns the OrtApi calls
+// logged since it was last called. Without a C compiler the test is
+// skipped.
+func fakeORT(t *testing.T) func() []string {
+	t.Helper()
+	lib := buildFakeORT(t)
+	log := filepath.Join(filepath.Dir(lib), "calls")
+	t.Setenv("FAKEORT_LOG", log)
+	if err := acquireRuntime(lib); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = releaseRuntime() })
+	return func() []string {
+		b, _ := os.ReadFile(log)
+		_ = os.Remove(log)
+		if len(b) == 0 {
+			return nil
+		}
+		return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	}
+}
+
+// buildFakeORT builds testdata/fakeort into a temporary directory and
+// returns the library's path. FAKEORT_VERSION and FAKEORT_API_VERSION, if
+// set, replace the version it reports and the newest API it serves.
+func buildFakeORT(t *testing.T) string {
+	t.Helper()
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler")
+	}
+	// The library is built against onnxruntime_go's copy of the C API.
+	mod, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", "github.com/yalue/onnxruntime_go").Output()
+	if err != nil {
+		t.Skipf("locating onnxruntime_go: %v", err)
+	}
+	lib := filepath.Join(t.TempDir(), "libfakeort.so")
+	out, err := exec.Command(cc, "-shared", "-fPIC", "-o", lib,
+		"-I", strings.TrimSpace(string(mod)), filepath.Join("testdata", "fakeort", "fakeort.c")).CombinedOutput()
+	if err != nil {
+		t.Fatalf("building the fake ONNX Runtime: %v\n%s", err, out)
+	}
+	return lib
+}
+
+// checkCalls reports a difference between the calls logged and want.
+func checkCalls(t *testing.T, what string, got, want []string) {
+	t.Helper()
+	if !slices.Equal(got, want) {
+		t.Errorf("%s: calls\n\t%s\nwant\n\t%s", what, strings.Join(got, "\n\t"), strings.Join(want, "\n\t"))
+	}
+}
+
+func TestAppendXNNPACK(t *testing.T) {
+	calls := fakeORT(t)
+	for _, threads := range []int{0, 2} {
+		opts, err := newSessionOptions(SessionOptions{}, ExecutionProvider{Kind: ProviderXNNPACK, XNNPACKThreads: threads})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = opts.Destroy()
+	}
+	checkCalls(t, "XNNPACK", calls(), []string{
+		"CreateSessionOptions 1",
+		"AddSessionConfigEntry 1 session.intra_op.allow_spinning=0",
+		"SessionOptionsAppendExecutionProvider 1 XNNPACK intra_op_num_threads=0",
+		"ReleaseSessionOptions 1",
+		"CreateSessionOptions 2",
+		"AddSessionConfigEntry 2 session.intra_op.allow_spinning=0",
+		"SessionOptionsAppendExecutionProvider 2 XNNPACK intra_op_num_threads=2",
+		"ReleaseSessionOptions 2",
+	})
+
+	// A library without XNNPACK fails the append; the options are released.
+	t.Setenv("FAKEORT_FAIL", "SessionOptionsAppendExecutionProvider")
+	opts, err := newSessionOptions(SessionOptions{}, ExecutionProvider{Kind: ProviderXNNPACK})
+	if opts != nil || err == nil || !strings.Contains(err.Error(), "fake SessionOptionsAppendExecutionProvider failed") {
+		t.Fatalf("failing append: %v, %v", opts, err)
+	}
+	checkCalls(t, "failing append", calls(), []string{
+		"CreateSessionOptions 3",
+		"AddSessionConfigEntry 3 session.intra_op.allow_spinning=0",
+		"SessionOptionsAppendExecutionProvider 3 XNNPACK intra_op_num_threads=0",
+		"ReleaseStatus fake SessionOptionsAppendExecutionProvider failed",
+		"ReleaseSessionOptions 3",
+	})
+	t.Setenv("FAKEORT_FAIL", "AddSessionConfigEntry")
+	if _, err := newSessionOptions(SessionOptions{}, ExecutionProvider{Kind: ProviderXNNPACK}); err == nil {
+		t.Fatal("failing config entry: no error")
+	}
+	checkCalls(t, "failing config entry", calls(), []string{
+		"CreateSessionOptions 4",
+		"AddSessionConfigEntry 4 session.intra_op.allow_spinning=0",
+		"ReleaseStatus fake AddSessionConfigEntry failed",
+		"ReleaseSessionOptions 4",
+	})
+}
+
+// TestAppendROCM checks the OrtROCMProviderOptions reaching ONNX Runtime:
+// its defaults but for the device, laid out as the C API declares them.
+func TestAppendROCM(t *testing.T) {
+	if err := appendROCM(nil, 0); err == nil || err.Error() != "ONNX Runtime library not found" {
+		t.Errorf("without ONNX Runtime: %v", err)
+	}
+
+	calls := fakeORT(t)
+	opts, err := newSessionOptions(SessionOptions{}, ExecutionProvider{Kind: ProviderROCm, DeviceID: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = opts.Destroy()
+	checkCalls(t, "ROCm", calls(), []string{
+		"CreateSessionOptions 1",
+		"SessionOptionsAppendExecutionProvider_ROCM 1 device_id=3 miopen_conv_exhaustive_search=0 " +
+			"gpu_mem_limit=18446744073709551615 arena_extend_strategy=0 do_copy_in_default_stream=1 has_user_compute_stream=0 " +
+			"user_compute_stream=0 default_memory_arena_cfg=0 enable_hip_graph=0 tunable_op_enable=0 " +
+			"tunable_op_tuning_enable=0 tunable_op_max_tuning_duration_ms=0",
+		"ReleaseSessionOptions 1",
+	})
+
+	t.Setenv("FAKEORT_FAIL", "SessionOptionsAppendExecutionProvider_ROCM")
+	_, err = newSessionOptions(SessionOptions{}, ExecutionProvider{Kind: ProviderROCm})
+	if err == nil || err.Error() != "append ROCm provider: fake SessionOptionsAppendExecutionProvider_ROCM failed" {
+		t.Fatalf("failing append: %v", err)
+	}
+	got := calls()
+	if !slices.Contains(got, "ReleaseStatus fake SessionOptionsAppendExecutionProvider_ROCM failed") ||
+		!slices.Contains(got, "ReleaseSessionOptions 2") {
+		t.Errorf("failing append: calls %q, want the status and the options released", got)
+	}
+}
+
+// TestSessionOptionsLogging checks that LogLevel and ProfilePrefix reach
+// the session options, the profile directory being created first.
+func TestSessionOptionsLogging(t *testing.T) {
+	if err := enableProfiling(nil, "run"); err == nil || err.Error() != "ONNX Runtime library not found" {
+		t.Errorf("without ONNX Runtime: %v", err)
+	}
+
+	calls := fakeORT(t)
+	for i, tc := range []struct {
+		level ORTLogLevel
+		want  int // ORT_LOGGING_LEVEL_*
+	}{{ORTLogVerbose, 0}, {ORTLogInfo, 1}, {ORTLogWarning, 2}, {ORTLogError, 3}, {ORTLogFatal, 4}} {
+		opts, err := newSessionOptions(SessionOptions{LogLevel: tc.level}, ExecutionProvider{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = opts.Destroy()
+		checkCalls(t, string(tc.level), calls(), []string{
+			fmt.Sprint("CreateSessionOptions ", i+1),
+			fmt.Sprintf("SetSessionLogSeverityLevel %d %d", i+1, tc.want),
+			fmt.Sprint("ReleaseSessionOptions ", i+1),
+		})
+	}
+
+	prefix := filepath.Join(t.TempDir(), "profiles", "vad")
+	opts, err := newSessionOptions(SessionOptions{ProfilePrefix: prefix}, ExecutionProvider{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = opts.Destroy()
+	checkCalls(t, "profiling", calls(), []string{"CreateSessionOptions 6", "EnableProfiling 6 " + prefix, "ReleaseSessionOptions 6"})
+	if fi, err := os.Stat(filepath.Dir(prefix)); err != nil || !fi.IsDir() {
+		t.Errorf("profile directory: %v", err)
+	}
+
+	t.Setenv("FAKEORT_FAIL", "EnableProfiling")
+	_, err = newSessionOptions(SessionOptions{ProfilePrefix: prefix}, ExecutionProvider{})
+	if err == nil || err.Error() != "enable profiling: fake EnableProfiling failed" {
+		t.Errorf("failing EnableProfiling: %v", err)
+	}
+	calls() // drop the failed attempt's
+
+	// A directory that cannot be created fails before ONNX Runtime is asked.
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = newSessionOptions(SessionOptions{ProfilePrefix: filepath.Join(file, "vad")}, ExecutionProvider{})
+	if err == nil || !strings.HasPrefix(err.Error(), "profile dir:") {
+		t.Errorf("profile directory under a file: %v", err)
+	}
+	if got := calls(); slices.ContainsFunc(got, func(c string) bool { return strings.HasPrefix(c, "EnableProfiling") }) {
+		t.Errorf("EnableProfiling called without a profile directory: %q", got)
+	}
+}
+
+// TestSmartTurnSession checks that the Smart-Turn model is loaded once: its
+// graph is read from the file, and only the inference session is created.
+func TestSmartTurnSession(t *testing.T) {
+	calls := fakeORT(t)
+	path := writeModel(t, smartTurnV3[0], smartTurnV3[1])
+	st, err := newSmartTurn(path, features.Params{}, SessionOptions{}, ExecutionProvider{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.model.inputName != "input_features" || st.model.outputName != "logits" {
+		t.Errorf("model %+v", st.model)
+	}
+	_ = st.destroy()
+	checkCalls(t, "Smart-Turn", calls(), []string{
+		"CreateSessionOptions 1",
+		"CreateSession " + path,
+		"ReleaseSessionOptions 1",
+		"ReleaseSession 1",
+	})
+}
+
+// TestLazySmartTurn checks that with LazySmartTurn no Smart-Turn session
+// exists before the first speech segment, and one is created when it
+// starts.
+func TestLazySmartTurn(t *testing.T) {
+	calls := fakeORT(t)
+	t.Setenv(EnvONNXRuntimeLib, "")
+	path := writeModel(t, smartTurnV3[0], smartTurnV3[1])
+	vad := &switchVAD{}
+	cfg := ProfileConversational()
+	cfg.VADBackend = vad
+	cfg.SmartTurnModelPath = path
+	cfg.LazySmartTurn = true
+	e, err := New(cfg, Callbacks{OnError: func(err error) { t.Error(err) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Start()
+	chunk := make([]float32, RequiredChunkSize)
+	for range 20 {
+		if err := e.PushPCM(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkCalls(t, "before speech", calls(), nil)
+
+	vad.speech = true
+	for range 20 {
+		if err := e.PushPCM(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkCalls(t, "speech", calls(), []string{
+		"CreateSessionOptions 1",
+		"CreateSession " + path,
+		"ReleaseSessionOptions 1",
+	})
+	e.Close()
+	checkCalls(t, "Close", calls(), []string{"ReleaseSession 1"})
+}
+
+// TestSileroFallbackKeepsShadowRuntime checks that a Silero failure under
+// EnergyVADFallback leaves the engine's hold on the runtime for an ONNX
+// shadow model when the primary Smart-Turn backend is custom.
+func TestSileroFallbackKeepsShadowRuntime(t *testing.T) {
+	calls := fakeORT(t)
+	t.Setenv(EnvONNXRuntimeLib, "")
+	refs := func() int {
+		ortRuntime.mu.Lock()
+		defer ortRuntime.mu.Unlock()
+		return ortRuntime.refs
+	}
+	held := refs() // by fakeORT
+	cfg := ProfileConversational()
+	cfg.SileroVADModelPath = filepath.Join(t.TempDir(), "missing.onnx")
+	cfg.EnergyVADFallback = true
+	cfg.TurnBackend = &countingTurn{}
+	cfg.ShadowSmartTurnModelPath = writeModel(t, smartTurnV3[0], smartTurnV3[1])
+	var errs []error
+	e, err := New(cfg, Callbacks{OnError: func(err error) { errs = append(errs, err) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrVADDegraded) {
+		t.Errorf("errors %v, want the VAD fallback", errs)
+	}
+	if got := refs(); got != held+1 {
+		t.Errorf("runtime refs %d with the shadow session open, want %d", got, held+1)
+	}
+	e.Close()
+	checkCalls(t, "Close", calls(), []string{
+		"CreateSessionOptions 1",
+		"CreateSession " + cfg.ShadowSmartTurnModelPath,
+		"ReleaseSessionOptions 1",
+		"ReleaseSession 1",
+	})
+	if got := refs(); got != held {
+		t.Errorf("runtime refs %d after Close, want %d", got, held)
+	}
+}
+
+// TestRuntimeVersion loads runtimes reporting an old and a current
+// version: New must refuse the old one with ErrUnsupportedRuntime before
+// initializing it, where it would fail with GetApi returning nil.
+func TestRuntimeVersion(t *testing.T) {
+	lib := buildFakeORT(t)
+	if ort.IsInitialized() {
+		t.Skip("ONNX Runtime already initialized")
+	}
+	cfg := ProfileConversational()
+	cfg.ONNXRuntimeLibPath = lib
+	cfg.VADBackend = &switchVAD{}
+	cfg.SmartTurnModelPath = writeModel(t, smartTurnV3[0], smartTurnV3[1])
+
+	t.Setenv("FAKEORT_VERSION", "1.22.1")
+	t.Setenv("FAKEORT_API_VERSION", "22")
+	_, err := New(cfg, Callbacks{})
+	if !errors.Is(err, ErrUnsupportedRuntime) || !strings.Contains(err.Error(), "onnxruntime 1.22.1 found, need >="+MinONNXRuntimeVersion) {
+		t.Fatalf("New with onnxruntime 1.22.1: %v", err)
+	}
+	if ort.IsInitialized() {
+		t.Fatal("unsupported runtime initialized")
+	}
+
+	t.Setenv("FAKEORT_VERSION", "1.23.2-dev")
+	t.Setenv("FAKEORT_API_VERSION", "23")
+	e, err := New(cfg, Callbacks{})
+	if err != nil {
+		t.Fatalf("New with onnxruntime 1.23.2-dev: %v", err)
+	}
+	e.Close()
+}
+
+func TestCheckRuntimeVersion(t *testing.T) {
+	for _, tc := range []struct {
+		version string
+		ok      bool
+	}{
+		{"1.23.0", true},
+		{"1.23.2", true},
+		{"1.24.0", true},
+		{"2.0.0", true},
+		{"1.23.0-rc1", true},
+		{"1.22.9", false},
+		{"1.9.0", false},
+		{"0.99.0", false},
+		{"1.22", false},
+		{"", true},        // unknown: the library decides
+		{"unknown", true}, // unparsable: likewise
+	} {
+		err := checkRuntimeVersion(tc.version)
+		if (err == nil) != tc.ok || (err != nil && !errors.Is(err, ErrUnsupportedRuntime)) {
+			t.Errorf("checkRuntimeVersion(%q) = %v", tc.version, err)
+		}
+	}
+}
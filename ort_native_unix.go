@@ -0,0 +1,174 @@
+//go:build linux || darwin
+
+package smartturn
+
+/*
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+
+typedef struct {
+	const void **(*GetApi)(uint32_t version);
+	const char *(*GetVersionString)(void);
+} ortApiBase;
+
+// OrtApi indices, from onnxruntime_c_api.h.
+#define ORT_GET_ERROR_MESSAGE 2
+#define ORT_ENABLE_PROFILING 14
+#define ORT_RELEASE_STATUS 93
+
+static void *ortLookup(const char *lib, const char *sym) {
+	void *h = dlopen(lib, RTLD_NOW | RTLD_NOLOAD);
+	if (h == NULL) {
+		return NULL;
+	}
+	void *fn = dlsym(h, sym);
+	dlclose(h);
+	return fn;
+}
+
+// ortLibraryVersion loads lib just long enough to read its version. It
+// returns 0 when lib cannot be loaded or is not ONNX Runtime. The result is copied into buf.
+static int ortLibraryVersion(const char *lib, char *buf, size_t n) {
+	void *h = dlopen(lib, RTLD_LAZY | RTLD_LOCAL);
+	if (h == NULL) {
+		return 0;
+	}
+	void *getApiBase = dlsym(h, "OrtGetApiBase");
+	int ok = 0;
+	if (getApiBase != NULL) {
+		const char *v = ((ortApiBase *(*)(void))getApiBase)()->GetVersionString();
+		if (v != NULL) {
+			snprintf(buf, n, "%s", v);
+			ok = 1;
+		}
+	}
+	dlclose(h);
+	return ok;
+}
+
+static void *ortGetApi(void *getApiBase) {
+	return (void *)((ortApiBase *(*)(void))getApiBase)()->GetApi(1);
+}
+
+static void *ortApiEntry(void *api, int index) {
+	return (void *)((const void **)api)[index];
+}
+
+static const char *ortErrorMessage(void *api, void *status) {
+	return ((const char *(*)(const void *))ortApiEntry(api, ORT_GET_ERROR_MESSAGE))(status);
+}
+
+static void *ortEnableProfiling(void *api, void *options, const char *prefix) {
+	return ((void *(*)(void *, const char *))ortApiEntry(api, ORT_ENABLE_PROFILING))(options, prefix);
+}
+
+static void ortReleaseStatus(void *api, void *status) {
+	((void (*)(void *))ortApiEntry(api, ORT_RELEASE_STATUS))(status);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// Native access to the ONNX Runtime library onnxruntime_go loaded, for the
+// provider entry points it does not wrap. OrtApi is an append-only table of
+// function pointers, so an entry's index is stable across releases.
+
+// ortSymbol returns the address of name in the loaded ONNX Runtime library,
+// or nil.
+func ortSymbol(name string) unsafe.Pointer {
+	ortRuntime.mu.Lock()
+	libPath := ortRuntime.libPath
+	ortRuntime.mu.Unlock()
+	sym := C.CString(name)
+	defer C.free(unsafe.Pointer(sym))
+	// onnxruntime_go loads "onnxruntime.so" when no path is set; Android
+	// packages name it libonnxruntime.so.
+	for _, lib := range []string{libPath, "onnxruntime.so", "libonnxruntime.so"} {
+		if lib == "" {
+			continue
+		}
+		cLib := C.CString(lib)
+		p := C.ortLookup(cLib, sym)
+		C.free(unsafe.Pointer(cLib))
+		if p != nil {
+			return p
+		}
+	}
+	return nil
+}
+
+// ortAPI returns the OrtApi function table, or nil when the library
+// cannot be found.
+func ortAPI() unsafe.Pointer {
+	base := ortSymbol("OrtGetApiBase")
+	if base == nil {
+		return nil
+	}
+	return C.ortGetApi(base)
+}
+
+// ortAPIFunc returns entry index of the OrtApi table, or nil.
+func ortAPIFunc(index int) unsafe.Pointer {
+	api := ortAPI()
+	if api == nil {
+		return nil
+	}
+	return C.ortApiEntry(api, C.int(index))
+}
+
+// ortStatusError converts a non-nil OrtStatus returned by what to an
+// error, releasing the status.
+func ortStatusError(status unsafe.Pointer, what string) error {
+	if status == nil {
+		return nil
+	}
+	api := ortAPI()
+	if api == nil {
+		return errors.New(what + " failed")
+	}
+	msg := C.GoString(C.ortErrorMessage(api, status))
+	C.ortReleaseStatus(api, status)
+	return errors.New(what + ": " + msg)
+}
+
+// nativeSessionOptions returns the OrtSessionOptions behind opts;
+// ort.SessionOptions holds nothing else.
+func nativeSessionOptions(opts *ort.SessionOptions) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(opts))
+}
+
+// enableProfiling calls OrtApi::EnableProfiling on opts.
+func enableProfiling(opts *ort.SessionOptions, prefix string) error {
+	api := ortAPI()
+	if api == nil {
+		return errors.New("ONNX Runtime library not found")
+	}
+	p := C.CString(prefix)
+	defer C.free(unsafe.Pointer(p))
+	return ortStatusError(C.ortEnableProfiling(api, nativeSessionOptions(opts), p), "enable profiling")
+}
+
+// libraryVersion returns the version of the ONNX Runtime library at path
+// ("" for onnxruntime_go's default) without initializing it, or "" when it
+// cannot be loaded; InitializeEnvironment then reports why.
+func libraryVersion(path string) string {
+	if path == "" {
+		path = "onnxruntime.so"
+	}
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+	var buf [64]C.char
+	if C.ortLibraryVersion(cPath, &buf[0], C.size_t(len(buf))) == 0 {
+		return ""
+	}
+	return C.GoString(&buf[0])
+}
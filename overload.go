@@ -0,0 +1,125 @@
+package smartturn
+
+import (
+	"errors"
+	"time"
+)
+
+// Defaults for Config.Overload.
+const (
+	DefaultOverloadWindow    = 2 * time.Second
+	DefaultOverloadThreshold = 1.0
+)
+
+// chunkDuration is the audio time of one 512-sample chunk at 16 kHz.
+const chunkDuration = time.Duration(RequiredChunkSize) * time.Second / RequiredSampleRate
+
+// Overload configures real-time factor (RTF) monitoring. RTF is the
+// wall-clock time PushPCM spends on a chunk, callbacks included, divided by
+// the chunk's 32 ms of audio, averaged over Window; above 1 the engine falls
+// further behind the audio with every chunk.
+type Overload struct {
+	// Window is the span of audio RTF is averaged over (default 2s). No
+	// OnOverload fires before the first Window of audio has been processed.
+	Window time.Duration
+	// Threshold is the RTF above which the engine counts as overloaded
+	// (default 1). It recovers once RTF falls below 80% of Threshold.
+	Threshold float64
+	// Shed degrades processing while overloaded: Smart-Turn is skipped when
+	// a segment ends, so the turn stays pending and ends by TurnTimeoutMs
+	// (or continues with more speech), and OnSegmentReady slices are twice
+	// TurnSegmentEmitMs long. Without Shed, overload is only reported.
+	Shed bool
+}
+
+// OverloadEvent is passed to OnOverload when the engine becomes overloaded
+// and again when it recovers.
+type OverloadEvent struct {
+	Overloaded bool
+	RTF        float64 // over Config.Overload.Window
+	Shedding   bool    // Overloaded and Config.Overload.Shed
+}
+
+func (o Overload) withDefaults() Overload {
+	if o.Window == 0 {
+		o.Window = DefaultOverloadWindow
+	}
+	if o.Threshold == 0 {
+		o.Threshold = DefaultOverloadThreshold
+	}
+	return o
+}
+
+func validateOverload(o Overload) error {
+	if o.Window < 0 {
+		return errors.New("config: Overload.Window must be >= 0")
+	}
+	if o.Threshold < 0 {
+		return errors.New("config: Overload.Threshold must be >= 0")
+	}
+	return nil
+}
+
+// loadMonitor keeps the processing times of the last Window of chunks.
+type loadMonitor struct {
+	cfg        Overload
+	times      []time.Duration // ring, one entry per chunk
+	next       int
+	filled     bool
+	sum        time.Duration
+	overloaded bool
+}
+
+func newLoadMonitor(o Overload) loadMonitor {
+	o = o.withDefaults()
+	n := int(o.Window / chunkDuration)
+	if n < 1 {
+		n = 1
+	}
+	return loadMonitor{cfg: o, times: make([]time.Duration, n)}
+}
+
+// add records one chunk and returns the RTF over the window; ok is false
+// until the window is full.
+func (m *loadMonitor) add(d time.Duration) (rtf float64, ok bool) {
+	m.sum += d - m.times[m.next]
+	m.times[m.next] = d
+	m.next++
+	if m.next == len(m.times) {
+		m.next = 0
+		m.filled = true
+	}
+	if !m.filled {
+		return 0, false
+	}
+	return float64(m.sum) / float64(time.Duration(len(m.times))*chunkDuration), true
+}
+
+// trackLoad records the processing time of one chunk and reports overload
+// transitions.
+func (e *Engine) trackLoad(d time.Duration) {
+	rtf, ok := e.load.add(d)
+	if !ok {
+		return
+	}
+	m := &e.load
+	switch {
+	case !m.overloaded && rtf > m.cfg.Threshold:
+		m.overloaded = true
+		e.shedding = m.cfg.Shed
+		e.log.Warn("engine overloaded", "rtf", rtf, "threshold", m.cfg.Threshold, "shedding", e.shedding)
+	case m.overloaded && rtf < 0.8*m.cfg.Threshold:
+		m.overloaded = false
+		e.shedding = false
+		e.log.Info("engine recovered from overload", "rtf", rtf)
+	default:
+		e.health.load(rtf, m.overloaded)
+		return
+	}
+	e.health.load(rtf, m.overloaded)
+	ev := OverloadEvent{Overloaded: m.overloaded, RTF: rtf, Shedding: e.shedding}
+	e.record(Event{Kind: EventOverload, Overload: ev})
+	if e.cb.OnOverload != nil {
+		e.cb.OnOverload(ev)
+	}
+}
@@ -0,0 +1,108 @@
+package smartturn_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// slowVAD is an EnergyVAD that takes delay of clock time per chunk.
+type slowVAD struct {
+	smartturntest.EnergyVAD
+	clock *smartturntest.Clock
+	delay time.Duration
+}
+
+func (v *slowVAD) SpeechProb(chunk []float32) (float32, error) {
+	v.clock.Advance(v.delay)
+	return v.EnergyVAD.SpeechProb(chunk)
+}
+
+// TestOverload slows the VAD down to 48 ms a chunk (RTF 1.5) and back,
+// and checks when OnOverload reports the overload and the recovery, and
+// that Smart-Turn is shed in between.
+func TestOverload(t *testing.T) {
+	vad := &slowVAD{clock: smartturntest.NewClock(time.Unix(0, 0))}
+	turn := &smartturntest.TurnScript{Probabilities: []float32{0.9}}
+	var (
+		events []smartturn.OverloadEvent
+		ends   int
+	)
+	e := newTestEngine(t, nil, smartturn.Callbacks{
+		OnOverload:  func(ev smartturn.OverloadEvent) { events = append(events, ev) },
+		OnSpeechEnd: func() { ends++ },
+	}, func(c *smartturn.Config) {
+		c.Clock = vad.clock
+		c.VADBackend = vad
+		c.TurnBackend = turn
+		// 10 chunks.
+		c.Overload = smartturn.Overload{Window: 320 * time.Millisecond, Threshold: 1, Shed: true}
+	})
+	silence := make([]float32, smartturn.RequiredChunkSize)
+	push := func(n int) {
+		t.Helper()
+		for range n {
+			if err := e.PushPCM(silence); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	near := func(rtf, want float64) bool { return math.Abs(rtf-want) < 1e-9 }
+
+	push(20)
+	if len(events) != 0 || e.Health().RTF != 0 {
+		t.Fatalf("idle engine: events %+v, RTF %v", events, e.Health().RTF)
+	}
+
+	// Seven slow chunks put 336 ms of processing in the window.
+	vad.delay = 48 * time.Millisecond
+	push(6)
+	if len(events) != 0 {
+		t.Fatalf("overloaded after 6 slow chunks: %+v", events)
+	}
+	push(1)
+	if len(events) != 1 || !events[0].Overloaded || !events[0].Shedding || !near(events[0].RTF, 1.05) {
+		t.Fatalf("after 7 slow chunks: %+v", events)
+	}
+	if h := e.Health(); !h.Overloaded || !near(h.RTF, 1.05) {
+		t.Errorf("health %+v while overloaded", h)
+	}
+
+	// A turn ending while shedding skips Smart-Turn and is left to
+	// TurnTimeoutMs.
+	speech, _ := smartturntest.Synth{Seed: 5}.Generate(
+		smartturntest.Speech(time.Second), smartturntest.Silence(500*time.Millisecond))
+	pushAll(t, e, speech)
+	if turn.Calls != 0 || ends != 0 {
+		t.Errorf("while shedding: %d Smart-Turn calls, %d speech ends", turn.Calls, ends)
+	}
+	if len(events) != 1 {
+		t.Fatalf("overload reported again: %+v", events)
+	}
+
+	// Recovery needs the window below 256 ms: five fast chunks leave 240.
+	vad.delay = 0
+	push(4)
+	if len(events) != 1 {
+		t.Fatalf("recovered after 4 fast chunks: %+v", events)
+	}
+	push(1)
+	if len(events) != 2 || events[1].Overloaded || events[1].Shedding || !near(events[1].RTF, 0.75) {
+		t.Fatalf("after 5 fast chunks: %+v", events)
+	}
+	if h := e.Health(); h.Overloaded {
+		t.Errorf("health %+v after recovery", h)
+	}
+
+	// The next turn runs Smart-Turn again.
+	pushAll(t, e, speech)
+	if turn.Calls != 1 {
+		t.Errorf("after recovery: %d Smart-Turn calls, want 1", turn.Calls)
+	}
+	if len(events) != 2 {
+		t.Errorf("events after recovery: %+v", events)
+	}
+}
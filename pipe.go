@@ -0,0 +1,58 @@
+package smartturn
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ReadPCM16 feeds the engine raw s16le audio from r until EOF: mono, at
+// Config.SampleRate, as media tools write it to a pipe, e.g.
+//
+//	ffmpeg -i input -f s16le -ac 1 -ar 16000 - | app
+//	gst-launch-1.0 ... ! audio/x-raw,format=S16LE,channels=1,rate=16000 ! fdsink | app
+//
+// with engine.ReadPCM16(os.Stdin), or a named pipe opened with os.Open.
+// Reads of any size are assembled into chunks of Config.ChunkSize, and a
+// final partial chunk is padded with silence. EOF returns nil once the
+// audio is processed; a turn still in progress then stays open, so push
+// silence or call PushGap to let it end. Any other read error, or an error
+// from PushPCM, is returned after the audio before it is processed.
+func (e *Engine) ReadPCM16(r io.Reader) error {
+	size := e.cfg.ChunkSize
+	buf := make([]byte, 2*size)
+	chunk := make([]float32, size)
+	n := 0 // bytes buffered toward the next chunk
+	for {
+		m, err := r.Read(buf[n:])
+		n += m
+		if n == len(buf) {
+			if perr := e.PushPCM(decodePCM16(buf, chunk)); perr != nil {
+				return perr
+			}
+			n = 0
+		}
+		if err == nil {
+			continue
+		}
+		if n >= 2 {
+			// Pad the tail to a chunk; an odd trailing byte is dropped.
+			clear(buf[n&^1:])
+			if perr := e.PushPCM(decodePCM16(buf, chunk)); perr != nil {
+				return perr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+}
+
+// decodePCM16 converts the s16le samples of b into chunk.
+func decodePCM16(b []byte, chunk []float32) []float32 {
+	for i := range chunk {
+		chunk[i] = float32(int16(binary.LittleEndian.Uint16(b[2*i:]))) / 32768
+	}
+	return chunk
+}
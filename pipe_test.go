@@ -0,0 +1,186 @@
+package smartturn_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// chunkVAD is a VADBackend that records the chunks it scores and scores
+// them 0.
+type chunkVAD struct {
+	chunks [][]float32
+	err    error
+}
+
+func (v *chunkVAD) SpeechProb(chunk []float32) (float32, error) {
+	v.chunks = append(v.chunks, slices.Clone(chunk))
+	return 0, v.err
+}
+
+func (v *chunkVAD) Reset()       {}
+func (v *chunkVAD) Close() error { return nil }
+
+// pcm16 encodes n samples counting up from -n/2 as s16le.
+func pcm16(n int) []byte {
+	b := make([]byte, 0, 2*n)
+	for i := range n {
+		b = binary.LittleEndian.AppendUint16(b, uint16(int16(i-n/2)))
+	}
+	return b
+}
+
+// sample returns sample i of pcm16(n) as ReadPCM16 decodes it.
+func sample(i, n int) float32 {
+	return float32(int16(i-n/2)) / 32768
+}
+
+func chunkEngine(t *testing.T, vad *chunkVAD, tweak func(*smartturn.Config)) *smartturn.Engine {
+	return newTestEngine(t, nil, smartturn.Callbacks{OnError: func(error) {}}, func(cfg *smartturn.Config) {
+		cfg.VADBackend = vad
+		if tweak != nil {
+			tweak(cfg)
+		}
+	})
+}
+
+// TestReadPCM16 checks that reads of any size are assembled into chunks,
+// the last padded with silence and an odd trailing byte dropped.
+func TestReadPCM16(t *testing.T) {
+	const samples = 100*512 + 150 // 100.3 chunks
+	data := pcm16(samples)
+	for _, tc := range []struct {
+		name string
+		r    io.Reader
+	}{
+		{"one read", bytes.NewReader(data)},
+		{"7-byte reads", iotest.HalfReader(&sevenByteReader{data})},
+		{"1-byte reads", iotest.OneByteReader(bytes.NewReader(data))},
+		{"data with EOF", iotest.DataErrReader(bytes.NewReader(data))},
+		{"odd trailing byte", bytes.NewReader(append(slices.Clone(data), 0x7f))},
+	} {
+		vad := &chunkVAD{}
+		e := chunkEngine(t, vad, nil)
+		if err := e.ReadPCM16(tc.r); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(vad.chunks) != 101 {
+			t.Fatalf("%s: %d chunks, want 101", tc.name, len(vad.chunks))
+		}
+		for i, c := range vad.chunks {
+			if len(c) != 512 {
+				t.Fatalf("%s: chunk %d of %d samples", tc.name, i, len(c))
+			}
+			for j, v := range c {
+				want := float32(0)
+				if k := 512*i + j; k < samples {
+					want = sample(k, samples)
+				}
+				if v != want {
+					t.Fatalf("%s: chunk %d sample %d = %v, want %v", tc.name, i, j, v, want)
+				}
+			}
+		}
+	}
+
+	// No audio, or a single byte, gives no chunk; a single sample does.
+	for _, tc := range []struct {
+		in     []byte
+		chunks int
+	}{{nil, 0}, {[]byte{1}, 0}, {[]byte{1, 0}, 1}} {
+		vad := &chunkVAD{}
+		if err := chunkEngine(t, vad, nil).ReadPCM16(bytes.NewReader(tc.in)); err != nil || len(vad.chunks) != tc.chunks {
+			t.Errorf("%d bytes: %d chunks, %v; want %d", len(tc.in), len(vad.chunks), err, tc.chunks)
+		}
+	}
+}
+
+// sevenByteReader returns at most 7 bytes per Read.
+type sevenByteReader struct{ b []byte }
+
+func (r *sevenByteReader) Read(p []byte) (int, error) {
+	if len(r.b) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p[:min(len(p), 7)], r.b)
+	r.b = r.b[n:]
+	return n, nil
+}
+
+// TestReadPCM16Telephony checks chunks of TelephonyChunkSize at 8 kHz.
+func TestReadPCM16Telephony(t *testing.T) {
+	vad := &chunkVAD{}
+	e := chunkEngine(t, vad, telephony)
+	if err := e.ReadPCM16(bytes.NewReader(pcm16(3 * smartturn.TelephonyChunkSize))); err != nil {
+		t.Fatal(err)
+	}
+	// The VAD sees the chunks upsampled to 16 kHz.
+	if len(vad.chunks) != 3 {
+		t.Errorf("%d chunks, want 3", len(vad.chunks))
+	}
+}
+
+// TestReadPCM16Turns checks that audio read from a pipe gives the turns
+// PushPCM gives.
+func TestReadPCM16Turns(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 3}.Generate(
+		smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond),
+		smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	var data []byte
+	for i, v := range audio {
+		s := int16(max(-1, min(1, v)) * 32767)
+		audio[i] = float32(s) / 32768
+		data = binary.LittleEndian.AppendUint16(data, uint16(s))
+	}
+	log := func(got *[]string) smartturn.Callbacks {
+		return smartturn.Callbacks{
+			OnSpeechStart: func() { *got = append(*got, "start") },
+			OnTurnEnd:     func(r smartturn.TurnEndReason) { *got = append(*got, "end:"+r.String()) },
+		}
+	}
+	var want, got []string
+	pushAll(t, newTestEngine(t, []float32{0.9}, log(&want), nil), audio)
+	if err := newTestEngine(t, []float32{0.9}, log(&got), nil).ReadPCM16(iotest.HalfReader(bytes.NewReader(data))); err != nil {
+		t.Fatal(err)
+	}
+	if len(want) != 4 || !slices.Equal(got, want) {
+		t.Errorf("ReadPCM16 turns %q, PushPCM turns %q", got, want)
+	}
+}
+
+// TestReadPCM16Errors checks that read and PushPCM errors are returned
+// after the audio before them is processed.
+func TestReadPCM16Errors(t *testing.T) {
+	failed := errors.New("pipe broken")
+	// 2.5 chunks, then the error.
+	r := io.MultiReader(bytes.NewReader(pcm16(1280)), iotest.ErrReader(failed))
+	vad := &chunkVAD{}
+	if err := chunkEngine(t, vad, nil).ReadPCM16(r); !errors.Is(err, failed) {
+		t.Errorf("read error: %v", err)
+	}
+	if len(vad.chunks) != 3 || vad.chunks[2][255] != sample(1279, 1280) || vad.chunks[2][256] != 0 {
+		t.Errorf("%d chunks before the read error, want 3 with the last padded", len(vad.chunks))
+	}
+
+	// A wrapped EOF is a clean end.
+	r = io.MultiReader(bytes.NewReader(pcm16(512)), iotest.ErrReader(errors.Join(io.EOF)))
+	if err := chunkEngine(t, &chunkVAD{}, nil).ReadPCM16(r); err != nil {
+		t.Errorf("wrapped EOF: %v", err)
+	}
+
+	// PushPCM errors, of a whole chunk and of the padded tail.
+	for _, n := range []int{1024, 100} {
+		vad := &chunkVAD{err: errors.New("vad failed")}
+		if err := chunkEngine(t, vad, nil).ReadPCM16(bytes.NewReader(pcm16(n))); !errors.Is(err, vad.err) || len(vad.chunks) != 1 {
+			t.Errorf("%d samples with a failing VAD: %v after %d chunks", n, err, len(vad.chunks))
+		}
+	}
+}
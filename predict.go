@@ -0,0 +1,51 @@
+package smartturn
+
+import "time"
+
+// PredictFeatures runs the Smart-Turn model on precomputed model input,
+// bypassing VAD and segmentation, for researchers who compute their own
+// features or want the raw outputs to calibrate against. features has the
+// layout a TurnBackend receives (see TurnFeatureSize), or ErrFeatureSize is
+// returned. The result has Complete, Probability, Logit, AuxOutputs, and
+// InferenceDuration set; AuxOutputs is reused by the next inference. It
+// shares the engine's model and Config.InferencePool (at
+// PrioritySpeculative), so calls are serialized with audio processing;
+// OnTurnPrediction is not invoked.
+func (e *Engine) PredictFeatures(features []float32) (TurnPrediction, error) {
+	if e.acquire() {
+		defer e.finish()
+	}
+	if e.closing.Load() {
+		return TurnPrediction{}, ErrClosed
+	}
+	if len(features) != len(e.smartTurn.features) {
+		return TurnPrediction{}, ErrFeatureSize
+	}
+	if err := e.loadSmartTurn(); err != nil {
+		return TurnPrediction{}, err
+	}
+	if pool := e.cfg.InferencePool; pool != nil {
+		pool.acquire(PrioritySpeculative, e.poolReady)
+		defer pool.release()
+	}
+	start := e.clock.Now()
+	r, err := e.smartTurn.predict(features)
+	d := e.clock.Now().Sub(start)
+	e.health.turnInference(start, d, err)
+	if err != nil {
+		return TurnPrediction{}, err
+	}
+	return r.prediction(d), nil
+}
+
+// prediction is the TurnPrediction of a one-off inference.
+func (r smartTurnResult) prediction(d time.Duration) TurnPrediction {
+	return TurnPrediction{
+		Complete:          r.Complete,
+		Probability:       r.Probability,
+		Instant:           r.Probability,
+		Logit:             r.Logit,
+		AuxOutputs:        r.Aux,
+		InferenceDuration: d,
+	}
+}
@@ -0,0 +1,68 @@
+package smartturn_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// TestPredictFeatures checks the one-off inference on precomputed
+// features: its result, that it fires no callback, and its errors.
+func TestPredictFeatures(t *testing.T) {
+	predictions := 0
+	e := newTestEngine(t, []float32{0.2, 0.8}, smartturn.Callbacks{
+		OnTurnPrediction:       func(bool, float32) { predictions++ },
+		OnTurnPredictionDetail: func(smartturn.TurnPrediction) { predictions++ },
+	}, nil)
+	features := make([]float32, smartturn.TurnFeatureSize)
+	for _, want := range []struct {
+		prob, logit float32
+		complete    bool
+	}{
+		{0.2, float32(math.Log(0.25)), false},
+		{0.8, float32(math.Log(4)), true},
+	} {
+		p, err := e.PredictFeatures(features)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Probability != want.prob || p.Instant != want.prob || p.Complete != want.complete {
+			t.Errorf("prediction %+v, want probability %v, complete %v", p, want.prob, want.complete)
+		}
+		if math.Abs(float64(p.Logit-want.logit)) > 1e-5 {
+			t.Errorf("Logit = %v, want %v", p.Logit, want.logit)
+		}
+	}
+	if predictions != 0 {
+		t.Errorf("PredictFeatures fired %d prediction callbacks", predictions)
+	}
+
+	if _, err := e.PredictFeatures(features[1:]); !errors.Is(err, smartturn.ErrFeatureSize) {
+		t.Errorf("short features: error %v, want ErrFeatureSize", err)
+	}
+	e.Close()
+	if _, err := e.PredictFeatures(features); !errors.Is(err, smartturn.ErrClosed) {
+		t.Errorf("after Close: error %v, want ErrClosed", err)
+	}
+}
+
+// TestPredictionLogit checks that engine predictions carry the logit a
+// custom backend's probability corresponds to.
+func TestPredictionLogit(t *testing.T) {
+	var got []smartturn.TurnPrediction
+	e := newTestEngine(t, []float32{0.8}, smartturn.Callbacks{
+		OnTurnPredictionDetail: func(p smartturn.TurnPrediction) { got = append(got, p) },
+	}, nil)
+	audio, _ := smartturntest.Synth{Seed: 6}.Generate(smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	pushAll(t, e, audio)
+	if len(got) != 1 {
+		t.Fatalf("%d predictions, want 1", len(got))
+	}
+	if p := got[0]; math.Abs(float64(p.Logit)-math.Log(4)) > 1e-5 || p.AuxOutputs != nil {
+		t.Errorf("Logit = %v, AuxOutputs %v; want %v and none", p.Logit, p.AuxOutputs, math.Log(4))
+	}
+}
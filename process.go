@@ -0,0 +1,92 @@
+package smartturn
+
+import (
+	"errors"
+	"time"
+)
+
+var errProcessQueued = errors.New("smart-turn: Process is not available with Config.InputQueue")
+
+// EventKind identifies an Event returned by Process.
+type EventKind int
+
+const (
+	EventSpeechStart EventKind = iota
+	EventTurnStart
+	EventSpeechEnd
+	EventSegmentReady
+	EventTurnPrediction
+	EventTurnSplit
+	EventTurnMerged
+	EventTranscript
+	EventWakeWord
+	EventDTMF
+	EventSpeakerChange
+	EventShadowPrediction
+	EventOverload
+	EventError
+	EventAudioEvicted
+)
+
+// Event is one pipeline result of a Process call; the field matching Kind
+// is set. Each corresponds to the callback of the same name.
+type Event struct {
+	Kind EventKind
+	// Time is the media time of the chunk that produced the event; see
+	// Engine.MediaTime.
+	Time time.Time
+	// Segment is the audio of an EventSegmentReady. It points into engine
+	// buffers: valid until the next Process call and not to be modified.
+	Segment    []float32
+	TurnStart  TurnStart        // EventTurnStart
+	EndReason  TurnEndReason    // EventSpeechEnd
+	Prediction TurnPrediction   // EventTurnPrediction
+	Split      TurnSplit        // EventTurnSplit
+	Merge      TurnMerge        // EventTurnMerged
+	Transcript Transcript       // EventTranscript
+	Digit      rune             // EventDTMF
+	Speaker    SpeakerChange    // EventSpeakerChange
+	Shadow     ShadowPrediction // EventShadowPrediction
+	Overload   OverloadEvent    // EventOverload
+	Err        error            // EventError
+	Eviction   AudioEviction    // EventAudioEvicted
+}
+
+// Process is PushPCM for embedders that own an audio thread and prefer
+// polling to callbacks: it runs VAD, buffering, and turn logic on the
+// caller's goroutine and returns the events of this chunk, in order. The
+// slice is reused by the next call. Callbacks that are set still fire.
+// Process spawns no goroutines and uses no channels (FeatureWorkers > 1,
+// SemanticCheck and CallbackWatchdog.Async aside), and it is not available
+// with Config.InputQueue.
+func (e *Engine) Process(chunk []float32) ([]Event, error) {
+	return e.ProcessAt(chunk, time.Time{})
+}
+
+// ProcessAt is Process for a chunk whose first sample the source stamped
+// with media time ts, which the events' Time then follow; see MediaTime.
+func (e *Engine) ProcessAt(chunk []float32, ts time.Time) ([]Event, error) {
+	if e.queue != nil {
+		return nil, errProcessQueued
+	}
+	if e.acquire() {
+		defer e.finish()
+	}
+	if e.closing.Load() {
+		e.dropClosed(chunk)
+		return nil, ErrClosed
+	}
+	e.events = e.events[:0]
+	e.collecting = true
+	err := e.pushPCM(chunk, ts)
+	e.collecting = false
+	return e.events, err
+}
+
+// record adds an event for Process; a no-op for PushPCM.
+func (e *Engine) record(ev Event) {
+	if e.collecting {
+		ev.Time = e.chunkTime
+		e.events = append(e.events, ev)
+	}
+}
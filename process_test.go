@@ -0,0 +1,59 @@
+package smartturn_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// TestTurnThreshold checks that PushPCM and Process apply TurnThreshold
+// whether or not a prediction callback is set.
+func TestTurnThreshold(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 2}.Generate(
+		smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	for _, tc := range []struct {
+		name string
+		prob float32
+		end  bool
+	}{
+		{"below", 0.3, false},
+		{"at", 0.5, true},
+		{"above", 0.8, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, withPrediction := range []bool{false, true} {
+				var cb smartturn.Callbacks
+				if withPrediction {
+					cb.OnTurnPrediction = func(bool, float32) {}
+				}
+
+				ends := 0
+				cb.OnSpeechEnd = func() { ends++ }
+				pushAll(t, newTestEngine(t, []float32{tc.prob}, cb, nil), audio)
+				if got := ends == 1; got != tc.end {
+					t.Errorf("PushPCM, OnTurnPrediction set %v: %d OnSpeechEnd, want end %v", withPrediction, ends, tc.end)
+				}
+
+				cb.OnSpeechEnd = nil
+				e := newTestEngine(t, []float32{tc.prob}, cb, nil)
+				ends = 0
+				for _, c := range smartturntest.Chunks(audio) {
+					events, err := e.Process(c)
+					if err != nil {
+						t.Fatal(err)
+					}
+					for _, ev := range events {
+						if ev.Kind == smartturn.EventSpeechEnd {
+							ends++
+						}
+					}
+				}
+				if got := ends == 1; got != tc.end {
+					t.Errorf("Process, OnTurnPrediction set %v: %d EventSpeechEnd, want end %v", withPrediction, ends, tc.end)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package smartturn
+
+// Profiles are tuned starting points for common deployments. They set
+// every tuning field, following the Smart-Turn guidance of a short VAD
+// stop that runs the model at each pause, with TurnTimeoutMs as the
+// fallback when it predicts the turn is not over. Model paths are left
+// for the caller:
+//
+//	cfg := smartturn.ProfileTelephony()
+//	cfg.SileroVADModelPath, cfg.SmartTurnModelPath = vadPath, turnPath
+
+// ProfileConversational is for voice agents on 16 kHz wideband audio: a
+// 200 ms pause runs Smart-Turn, a turn ends at probability 0.5, and 3 s of
+// silence ends one the model considers incomplete.
+func ProfileConversational() Config {
+	return Config{
+		SampleRate:             RequiredSampleRate,
+		ChunkSize:              RequiredChunkSize,
+		VadThreshold:           0.5,
+		VadPreSpeechMs:         200,
+		VadStopMs:              200,
+		TurnMaxDurationSeconds: 600,
+		TurnSegmentEmitMs:      1000,
+		TurnThreshold:          0.5,
+		TurnTimeoutMs:          3000,
+	}
+}
+
+// ProfileTelephony is for 8 kHz PSTN and SIP calls (G.711). Compared with
+// ProfileConversational, VAD is stricter against line noise, pauses are
+// longer to ride out codec jitter, turns split by packet loss are merged,
+// the fallback is shorter for callers expecting a prompt reply, and DTMF
+// tones are detected.
+func ProfileTelephony() Config {
+	c := ProfileConversational()
+	c.SampleRate = TelephonySampleRate
+	c.ChunkSize = TelephonyChunkSize
+	c.VadThreshold = 0.6
+	c.VadPreSpeechMs = 300
+	c.VadStopMs = 300
+	c.TurnMergeGapMs = 300
+	c.TurnTimeoutMs = 2000
+	c.DetectDTMF = true
+	return c
+}
+
+// ProfileDictation is for long-form dictation, where speakers pause
+// mid-sentence to think. A turn ends only when Smart-Turn is confident
+// (0.8, smoothed over the turn's pauses) or after 5 s of silence; speech
+// resuming within 1 s continues the turn, and turns past 10 minutes are
+// split rather than ended.
+func ProfileDictation() Config {
+	c := ProfileConversational()
+	c.VadStopMs = 500
+	c.SplitLongTurns = true
+	c.TurnSplitOverlapMs = 500
+	c.TurnThreshold = 0.8
+	c.TurnSmoothing = 0.3
+	c.TurnMergeGapMs = 1000
+	c.TurnTimeoutMs = 5000
+	return c
+}
@@ -0,0 +1,69 @@
+package smartturn_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/cortexswarm/smart-turn-go"
+)
+
+// changed returns the Config fields in which a and b differ.
+func changed(a, b smartturn.Config) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	var out []string
+	for i := range va.NumField() {
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			out = append(out, va.Type().Field(i).Name)
+		}
+	}
+	return out
+}
+
+// TestProfiles checks that every profile, given model paths, is a valid
+// Config, and that it sets the fields its documentation lists (relative
+// to ProfileConversational for the others) and no others.
+func TestProfiles(t *testing.T) {
+	dir := t.TempDir()
+	vad, turn := filepath.Join(dir, "silero_vad.onnx"), filepath.Join(dir, "smart-turn.onnx")
+	for _, p := range []string{vad, turn} {
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conv := smartturn.ProfileConversational()
+	for _, tc := range []struct {
+		name   string
+		cfg    smartturn.Config
+		base   smartturn.Config
+		fields []string
+	}{
+		{"conversational", conv, smartturn.Config{}, []string{
+			"SampleRate", "ChunkSize", "VadThreshold", "VadPreSpeechMs", "VadStopMs",
+			"TurnMaxDurationSeconds", "TurnSegmentEmitMs", "TurnThreshold", "TurnTimeoutMs",
+		}},
+		{"telephony", smartturn.ProfileTelephony(), conv, []string{
+			"SampleRate", "ChunkSize", "VadThreshold", "VadPreSpeechMs", "VadStopMs",
+			"TurnTimeoutMs", "TurnMergeGapMs", "DetectDTMF",
+		}},
+		{"dictation", smartturn.ProfileDictation(), conv, []string{
+			"VadStopMs", "SplitLongTurns", "TurnSplitOverlapMs", "TurnThreshold",
+			"TurnSmoothing", "TurnMergeGapMs", "TurnTimeoutMs",
+		}},
+	} {
+		// Model paths are left to the caller.
+		cfg := tc.cfg
+		cfg.SileroVADModelPath, cfg.SmartTurnModelPath = vad, turn
+		if err := smartturn.ValidateConfig(cfg); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		got := changed(tc.base, tc.cfg)
+		slices.Sort(got)
+		slices.Sort(tc.fields)
+		if !slices.Equal(got, tc.fields) {
+			t.Errorf("%s sets %v, want %v", tc.name, got, tc.fields)
+		}
+	}
+}
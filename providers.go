@@ -0,0 +1,304 @@
+package smartturn
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// ErrProviderUnavailable is reported through OnError when the configured
+// execution provider could not be used and the session fell back to CPU.
+var ErrProviderUnavailable = errors.New("execution provider unavailable")
+
+// ProviderKind selects the ONNX Runtime execution provider for a model session.
+type ProviderKind string
+
+const (
+	// ProviderCPU is the default ONNX Runtime CPU provider.
+	ProviderCPU ProviderKind = ""
+	// ProviderCUDA runs the session on an NVIDIA GPU. Requires a CUDA-enabled
+	// ONNX Runtime build and matching CUDA/cuDNN libraries.
+	ProviderCUDA ProviderKind = "cuda"
+	// ProviderCoreML runs the session through CoreML on macOS (GPU/Neural
+	// Engine on Apple Silicon). Operators CoreML cannot handle are assigned
+	// to the CPU provider by ONNX Runtime's graph partitioner.
+	ProviderCoreML ProviderKind = "coreml"
+	// ProviderDirectML runs the session on a DirectX 12 GPU on Windows.
+	// Requires the DirectML build of ONNX Runtime (onnxruntime.dll from the
+	// Microsoft.ML.OnnxRuntime.DirectML package).
+	ProviderDirectML ProviderKind = "directml"
+	// ProviderTensorRT runs the session through TensorRT, with CUDA registered
+	// behind it for any nodes TensorRT does not take. Requires a TensorRT
+	// build of ONNX Runtime.
+	ProviderTensorRT ProviderKind = "tensorrt"
+	// ProviderOpenVINO runs the session through Intel OpenVINO on the device
+	// named by OpenVINODevice. Requires an OpenVINO build of ONNX Runtime.
+	ProviderOpenVINO ProviderKind = "openvino"
+	// ProviderROCm runs the session on an AMD GPU (Instinct or Radeon)
+	// through ROCm, the AMD counterpart of ProviderCUDA. Requires Linux, a
+	// ROCm build of ONNX Runtime, and the matching ROCm libraries.
+	ProviderROCm ProviderKind = "rocm"
+	// ProviderNNAPI runs the session through the Android Neural Networks API
+	// (GPU, DSP or NPU, depending on the phone's drivers). Requires Android
+	// and an ONNX Runtime build with NNAPI, such as the onnxruntime-android
+	// package. Operators NNAPI cannot take stay on CPU.
+	ProviderNNAPI ProviderKind = "nnapi"
+	// ProviderXNNPACK runs the session on CPU through XNNPACK's optimized
+	// ARM/x86 kernels, usually faster than the default CPU provider on
+	// phones. Requires an ONNX Runtime build with XNNPACK (included in
+	// onnxruntime-android and onnxruntime-mobile).
+	ProviderXNNPACK ProviderKind = "xnnpack"
+)
+
+// ExecutionProvider configures where a model session runs. The zero value is CPU.
+type ExecutionProvider struct {
+	Kind     ProviderKind
+	DeviceID int // GPU ordinal (CUDA, ROCm, DirectML, TensorRT); 0 is the first/default device
+
+	// TensorRTCacheDir enables TensorRT engine caching in this directory
+	// (created if missing). Building an engine takes from seconds to minutes;
+	// with a cache it is paid once per model/GPU/TensorRT version. Empty
+	// disables caching.
+	TensorRTCacheDir string
+	// TensorRTFP16 lets TensorRT use FP16 kernels where the GPU supports them.
+	TensorRTFP16 bool
+
+	// OpenVINODevice is the OpenVINO device_type: "CPU" (default when empty),
+	// "GPU", "NPU", an indexed device such as "GPU.1", or a virtual device
+	// such as "AUTO:NPU,CPU".
+	OpenVINODevice string
+
+	// NNAPIFP16 lets NNAPI compute float32 models in FP16, faster and
+	// lighter on battery where the accelerator supports it. NNAPICPUDisabled
+	// keeps NNAPI off its own CPU reference implementation, which is usually
+	// slower than ONNX Runtime's CPU kernels; nodes no accelerator takes then
+	// run on the ONNX Runtime CPU provider instead.
+	NNAPIFP16        bool
+	NNAPICPUDisabled bool
+
+	// XNNPACKThreads sizes XNNPACK's thread pool; 0 uses the session's
+	// intra-op pool size. When set, pair it with
+	// SessionOptions.IntraOpThreads = 1 so the two pools do not compete for
+	// cores. With XNNPACK the session pool never spins waiting for work,
+	// which saves battery.
+	XNNPACKThreads int
+
+	// FallbackToCPU creates the session on the CPU provider when Kind cannot
+	// be enabled (provider missing from the ONNX Runtime build, unsupported
+	// platform, or session creation failure). The failure is reported via
+	// OnError wrapping ErrProviderUnavailable. When false, New fails instead.
+	FallbackToCPU bool
+}
+
+func validateProvider(ep ExecutionProvider) error {
+	switch ep.Kind {
+	case ProviderCPU, ProviderCUDA, ProviderCoreML, ProviderDirectML, ProviderTensorRT, ProviderOpenVINO, ProviderROCm, ProviderNNAPI, ProviderXNNPACK:
+	default:
+		return errors.New("config: unknown execution provider " + strconv.Quote(string(ep.Kind)))
+	}
+	if ep.DeviceID < 0 {
+		return errors.New("config: execution provider DeviceID must be >= 0")
+	}
+	if ep.XNNPACKThreads < 0 {
+		return errors.New("config: execution provider XNNPACKThreads must be >= 0")
+	}
+	if ep.OpenVINODevice != "" && !validOpenVINODevice(ep.OpenVINODevice) {
+		return errors.New("config: OpenVINODevice must be CPU, GPU, NPU (optionally indexed) or AUTO/HETERO/MULTI, got " + strconv.Quote(ep.OpenVINODevice))
+	}
+	return nil
+}
+
+// newSessionOptions returns ORT session options for so and ep, or nil when
+// both are defaults. The caller must Destroy non-nil options once the session
+// has been created.
+func newSessionOptions(so SessionOptions, ep ExecutionProvider) (*ort.SessionOptions, error) {
+	if so.isZero() && ep.Kind == ProviderCPU {
+		return nil, nil
+	}
+	if err := providerSupported(ep.Kind); err != nil {
+		return nil, err
+	}
+	opts, err := ort.NewSessionOptions()
+	if err != nil {
+		return nil, err
+	}
+	err = so.apply(opts)
+	if err == nil {
+		err = appendProvider(opts, ep)
+	}
+	if err != nil {
+		_ = opts.Destroy()
+		return nil, err
+	}
+	return opts, nil
+}
+
+func appendProvider(opts *ort.SessionOptions, ep ExecutionProvider) error {
+	switch ep.Kind {
+	case ProviderCUDA:
+		return appendCUDA(opts, ep.DeviceID)
+	case ProviderCoreML:
+		// MLProgram covers more operators than the legacy NeuralNetwork format.
+		return opts.AppendExecutionProviderCoreMLV2(map[string]string{
+			"ModelFormat":    "MLProgram",
+			"MLComputeUnits": "ALL",
+		})
+	case ProviderDirectML:
+		return opts.AppendExecutionProviderDirectML(ep.DeviceID)
+	case ProviderOpenVINO:
+		device := ep.OpenVINODevice
+		if device == "" {
+			device = "CPU"
+		}
+		return opts.AppendExecutionProviderOpenVINO(map[string]string{"device_type": device})
+	case ProviderTensorRT:
+		if err := appendTensorRT(opts, ep); err != nil {
+			return err
+		}
+		return appendCUDA(opts, ep.DeviceID)
+	case ProviderROCm:
+		return appendROCM(opts, ep.DeviceID)
+	case ProviderNNAPI:
+		var flags uint32
+		if ep.NNAPIFP16 {
+			flags |= nnapiFlagUseFP16
+		}
+		if ep.NNAPICPUDisabled {
+			flags |= nnapiFlagCPUDisabled
+		}
+		return appendNNAPI(opts, flags)
+	case ProviderXNNPACK:
+		return appendXNNPACK(opts, ep.XNNPACKThreads)
+	}
+	return nil
+}
+
+func validOpenVINODevice(device string) bool {
+	for _, prefix := range []string{"CPU", "GPU", "NPU", "AUTO", "HETERO", "MULTI"} {
+		if device == prefix || strings.HasPrefix(device, prefix+".") || strings.HasPrefix(device, prefix+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// providerSupported rejects providers that cannot exist on this platform
+// before touching ONNX Runtime, so a single binary can be shipped everywhere
+// and fall back cleanly. Whether the loaded library was built with the
+// provider is only known when it is appended.
+func providerSupported(kind ProviderKind) error {
+	switch kind {
+	case ProviderCoreML:
+		if runtime.GOOS != "darwin" && runtime.GOOS != "ios" {
+			return errors.New("CoreML requires macOS or iOS")
+		}
+	case ProviderDirectML:
+		if runtime.GOOS != "windows" {
+			return errors.New("DirectML requires Windows")
+		}
+	case ProviderROCm:
+		if runtime.GOOS != "linux" {
+			return errors.New("ROCm requires Linux")
+		}
+	case ProviderNNAPI:
+		if runtime.GOOS != "android" {
+			return errors.New("NNAPI requires Android")
+		}
+	}
+	return nil
+}
+
+func appendCUDA(opts *ort.SessionOptions, deviceID int) error {
+	cuda, err := ort.NewCUDAProviderOptions()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = cuda.Destroy() }()
+	if err := cuda.Update(map[string]string{"device_id": strconv.Itoa(deviceID)}); err != nil {
+		return err
+	}
+	return opts.AppendExecutionProviderCUDA(cuda)
+}
+
+// NNAPI flags of OrtSessionOptionsAppendExecutionProvider_Nnapi
+// (nnapi_provider_factory.h).
+const (
+	nnapiFlagUseFP16     = 0x001
+	nnapiFlagCPUDisabled = 0x004
+)
+
+func appendXNNPACK(opts *ort.SessionOptions, threads int) error {
+	// XNNPACK runs kernels on its own pool; a spinning session pool would
+	// only burn CPU next to it.
+	if err := opts.AddSessionConfigEntry("session.intra_op.allow_spinning", "0"); err != nil {
+		return err
+	}
+	return opts.AppendExecutionProvider("XNNPACK", map[string]string{"intra_op_num_threads": strconv.Itoa(threads)})
+}
+
+func appendTensorRT(opts *ort.SessionOptions, ep ExecutionProvider) error {
+	settings := map[string]string{"device_id": strconv.Itoa(ep.DeviceID)}
+	if ep.TensorRTCacheDir != "" {
+		if err := os.MkdirAll(ep.TensorRTCacheDir, 0755); err != nil {
+			return fmt.Errorf("tensorrt cache dir: %w", err)
+		}
+		settings["trt_engine_cache_enable"] = "1"
+		settings["trt_engine_cache_path"] = ep.TensorRTCacheDir
+		// Timing cache speeds up building engines for new input shapes too.
+		settings["trt_timing_cache_enable"] = "1"
+		settings["trt_timing_cache_path"] = ep.TensorRTCacheDir
+	}
+	if ep.TensorRTFP16 {
+		settings["trt_fp16_enable"] = "1"
+	}
+	trt, err := ort.NewTensorRTProviderOptions()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = trt.Destroy() }()
+	if err := trt.Update(settings); err != nil {
+		return err
+	}
+	return opts.AppendExecutionProviderTensorRT(trt)
+}
+
+// newProviderSession creates an AdvancedSession on ep. If that fails and
+// ep.FallbackToCPU is set, it retries on CPU and returns the provider error
+// as fallbackErr alongside the working session.
+func newProviderSession(modelPath string, inputNames, outputNames []string, inputs, outputs []ort.Value, so SessionOptions, ep ExecutionProvider) (sess *ort.AdvancedSession, fallbackErr error, err error) {
+	return withProviderFallback(so, ep, func(opts *ort.SessionOptions) (*ort.AdvancedSession, error) {
+		return ort.NewAdvancedSession(modelPath, inputNames, outputNames, inputs, outputs, opts)
+	})
+}
+
+// withProviderFallback calls create with session options for ep, and on
+// failure with ep.FallbackToCPU set, once more for CPU.
+func withProviderFallback[S any](so SessionOptions, ep ExecutionProvider, create func(*ort.SessionOptions) (S, error)) (sess S, fallbackErr error, err error) {
+	opts, err := newSessionOptions(so, ep)
+	if err == nil {
+		sess, err = create(opts)
+		if opts != nil {
+			_ = opts.Destroy()
+		}
+	}
+	if err == nil || ep.Kind == ProviderCPU || !ep.FallbackToCPU {
+		return sess, nil, err
+	}
+	fallbackErr = fmt.Errorf("%w: %s: %v; using CPU", ErrProviderUnavailable, ep.Kind, err)
+	if opts, err = newSessionOptions(so, ExecutionProvider{}); err != nil {
+		return sess, nil, err
+	}
+	sess, err = create(opts)
+	if opts != nil {
+		_ = opts.Destroy()
+	}
+	if err != nil {
+		return sess, nil, err
+	}
+	return sess, fallbackErr, nil
+}
@@ -0,0 +1,57 @@
+package smartturn
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+func TestValidateProvider(t *testing.T) {
+	for _, tc := range []struct {
+		ep   ExecutionProvider
+		want string // error substring; "" when valid
+	}{
+		{ExecutionProvider{Kind: ProviderNNAPI, NNAPIFP16: true, NNAPICPUDisabled: true}, ""},
+		{ExecutionProvider{Kind: ProviderXNNPACK}, ""},
+		{ExecutionProvider{Kind: ProviderXNNPACK, XNNPACKThreads: 4}, ""},
+		{ExecutionProvider{Kind: ProviderXNNPACK, XNNPACKThreads: -1}, "XNNPACKThreads"},
+		{ExecutionProvider{Kind: ProviderROCm, DeviceID: 1}, ""},
+		{ExecutionProvider{Kind: ProviderROCm, DeviceID: -1}, "DeviceID"},
+		{ExecutionProvider{Kind: "nnapi2"}, "unknown execution provider"},
+	} {
+		err := validateProvider(tc.ep)
+		switch {
+		case tc.want == "" && err != nil:
+			t.Errorf("%+v: %v", tc.ep, err)
+		case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+			t.Errorf("%+v: error %v, want one containing %q", tc.ep, err, tc.want)
+		}
+	}
+}
+
+// TestProviderSupported checks that NNAPI is rejected off Android and ROCm
+// off Linux before ONNX Runtime is touched, while XNNPACK is left to the
+// library.
+func TestProviderSupported(t *testing.T) {
+	if ort.IsInitialized() {
+		t.Skip("ONNX Runtime is initialized")
+	}
+	_, err := newSessionOptions(SessionOptions{}, ExecutionProvider{Kind: ProviderNNAPI})
+	if runtime.GOOS != "android" {
+		if err == nil || err.Error() != "NNAPI requires Android" {
+			t.Errorf("NNAPI on %s: %v, want NNAPI requires Android", runtime.GOOS, err)
+		}
+	}
+	_, err = newSessionOptions(SessionOptions{}, ExecutionProvider{Kind: ProviderROCm})
+	if runtime.GOOS != "linux" && (err == nil || err.Error() != "ROCm requires Linux") {
+		t.Errorf("ROCm on %s: %v, want ROCm requires Linux", runtime.GOOS, err)
+	}
+	if err := providerSupported(ProviderROCm); (err == nil) != (runtime.GOOS == "linux") {
+		t.Errorf("ROCm on %s: %v", runtime.GOOS, err)
+	}
+	if err := providerSupported(ProviderXNNPACK); err != nil {
+		t.Errorf("XNNPACK: %v", err)
+	}
+}
@@ -0,0 +1,345 @@
+// Package mqtt is a minimal MQTT 3.1.1 client for publishing turn events
+// from devices, such as smart speakers, where the turn detector runs
+// on-device and the dialog manager runs elsewhere. It only publishes (QoS
+// 0 or 1), with no dependencies beyond the standard library, and is a
+// publish.Bus:
+//
+//	c, err := mqtt.Dial("broker.local:1883", mqtt.Options{ClientID: "kitchen-speaker"})
+//	p, err := publish.New(c, publish.Options{Topic: "home/smartturn", SessionID: "kitchen"})
+//	engine, err := smartturn.New(cfg, p.Wrap(callbacks))
+//
+// Messages go to topic/key, e.g. home/smartturn/kitchen. A lost connection
+// is redialed on the next publish.
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultKeepAlive is the keep-alive interval when Options.KeepAlive is
+// zero.
+const DefaultKeepAlive = 30 * time.Second
+
+// Options configures a Client.
+type Options struct {
+	// ClientID identifies the device to the broker; required.
+	ClientID string
+	// Username and Password, when set, are sent on connect.
+	Username string
+	Password string
+	// KeepAlive is the interval of pings while idle; default
+	// DefaultKeepAlive.
+	KeepAlive time.Duration
+	// QoS is 0 (at most once) or 1 (at least once: Publish waits for the
+	// broker's acknowledgment).
+	QoS byte
+	// Timeout bounds dialing, connecting, and waiting for an
+	// acknowledgment; default 10s.
+	Timeout time.Duration
+	// Dial opens the connection to addr; default a TCP dial. For TLS,
+	// return a tls.Client connection.
+	Dial func(addr string, timeout time.Duration) (net.Conn, error)
+}
+
+// Packet types.
+const (
+	packetConnect    = 1
+	packetConnack    = 2
+	packetPublish    = 3
+	packetPuback     = 4
+	packetPingreq    = 12
+	packetDisconnect = 14
+)
+
+// ErrClosed is returned by Publish after Close.
+var ErrClosed = errors.New("mqtt: client closed")
+
+// Client publishes to one broker. It is safe for concurrent use.
+type Client struct {
+	addr string
+	opts Options
+
+	mu     sync.Mutex // guards the fields below and writes to conn
+	conn   net.Conn
+	w      *bufio.Writer
+	acks   map[uint16]chan struct{}
+	nextID uint16
+	closed bool
+	stop   chan struct{} // closes the keep-alive loop of conn
+	buf    []byte
+}
+
+// Dial connects to the broker at addr (host:port).
+func Dial(addr string, opts Options) (*Client, error) {
+	if opts.ClientID == "" {
+		return nil, errors.New("mqtt: ClientID is required")
+	}
+	if opts.QoS > 1 {
+		return nil, fmt.Errorf("mqtt: unsupported QoS %d", opts.QoS)
+	}
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = DefaultKeepAlive
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Dial == nil {
+		opts.Dial = func(addr string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("tcp", addr, timeout)
+		}
+	}
+	c := &Client{addr: addr, opts: opts}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Publish sends msg to topic/key, redialing first if the connection was
+// lost. With QoS 1 it returns once the broker acknowledged the message.
+func (c *Client) Publish(topic, key string, msg []byte) error {
+	if key != "" {
+		topic += "/" + key
+	}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			c.mu.Unlock()
+			return err
+		}
+	}
+	var ack chan struct{}
+	var id uint16
+	flags := c.opts.QoS << 1
+	c.buf = appendString(c.buf[:0], topic)
+	if c.opts.QoS > 0 {
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID = 1
+		}
+		id = c.nextID
+		c.buf = binary.BigEndian.AppendUint16(c.buf, id)
+		ack = make(chan struct{})
+		c.acks[id] = ack
+	}
+	err := c.write(packetPublish<<4|flags, c.buf, msg)
+	conn := c.conn
+	c.mu.Unlock()
+	if err != nil || ack == nil {
+		return err
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-time.After(c.opts.Timeout):
+		c.mu.Lock()
+		delete(c.acks, id)
+		if c.conn == conn {
+			// The connection is presumably dead; redial on the next publish.
+			c.dropLocked()
+		}
+		c.mu.Unlock()
+		return fmt.Errorf("mqtt: publish to %s not acknowledged within %v", topic, c.opts.Timeout)
+	}
+}
+
+// Close disconnects from the broker.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	if err := c.write(packetDisconnect<<4, nil, nil); err != nil {
+		return err
+	}
+	return c.dropLocked()
+}
+
+// connect dials and completes the MQTT handshake. The caller holds c.mu.
+func (c *Client) connect() error {
+	conn, err := c.opts.Dial(c.addr, c.opts.Timeout)
+	if err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(c.opts.Timeout))
+	c.conn, c.w = conn, bufio.NewWriter(conn)
+	c.stop = make(chan struct{})
+
+	flags := byte(0x02) // clean session
+	if c.opts.Username != "" {
+		flags |= 0x80
+	}
+	if c.opts.Password != "" {
+		flags |= 0x40
+	}
+	b := appendString(nil, "MQTT")
+	b = append(b, 4, flags) // protocol level 4 is 3.1.1
+	b = binary.BigEndian.AppendUint16(b, uint16(c.opts.KeepAlive/time.Second))
+	b = appendString(b, c.opts.ClientID)
+	if c.opts.Username != "" {
+		b = appendString(b, c.opts.Username)
+	}
+	if c.opts.Password != "" {
+		b = appendString(b, c.opts.Password)
+	}
+	r := bufio.NewReader(conn)
+	err = c.write(packetConnect<<4, b, nil)
+	if err == nil {
+		var typ byte
+		var body []byte
+		typ, body, err = readPacket(r)
+		switch {
+		case err != nil:
+		case typ>>4 != packetConnack || len(body) != 2:
+			err = errors.New("mqtt: expected CONNACK")
+		case body[1] != 0:
+			err = fmt.Errorf("mqtt: connection refused (code %d)", body[1])
+		}
+	}
+	if err != nil {
+		if c.conn != nil {
+			c.dropLocked()
+		}
+		return err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	c.acks = make(map[uint16]chan struct{})
+	go c.read(conn, r)
+	go c.keepAlive(conn, c.stop)
+	return nil
+}
+
+// write sends a packet with header byte h. The caller holds c.mu.
+func (c *Client) write(h byte, header, payload []byte) error {
+	n := len(header) + len(payload)
+	var head [5]byte
+	head[0] = h
+	l := 1
+	for {
+		d := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			d |= 0x80
+		}
+		head[l] = d
+		l++
+		if n == 0 {
+			break
+		}
+	}
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.opts.Timeout))
+	c.w.Write(head[:l])
+	c.w.Write(header)
+	c.w.Write(payload)
+	if err := c.w.Flush(); err != nil {
+		c.dropLocked()
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	return nil
+}
+
+// read handles the broker's packets on conn until it fails.
+func (c *Client) read(conn net.Conn, r *bufio.Reader) {
+	for {
+		typ, body, err := readPacket(r)
+		if err != nil {
+			c.mu.Lock()
+			if c.conn == conn {
+				c.dropLocked()
+			}
+			c.mu.Unlock()
+			return
+		}
+		if typ>>4 == packetPuback && len(body) == 2 {
+			id := binary.BigEndian.Uint16(body)
+			c.mu.Lock()
+			if ack, ok := c.acks[id]; ok {
+				delete(c.acks, id)
+				close(ack)
+			}
+			c.mu.Unlock()
+		}
+		// Nothing else needs handling: PINGRESP only proves the link.
+	}
+}
+
+// keepAlive pings the broker while the connection is up.
+func (c *Client) keepAlive(conn net.Conn, stop chan struct{}) {
+	t := time.NewTicker(c.opts.KeepAlive / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			c.mu.Lock()
+			if c.conn != conn {
+				c.mu.Unlock()
+				return
+			}
+			_ = c.write(packetPingreq<<4, nil, nil)
+			c.mu.Unlock()
+		}
+	}
+}
+
+// dropLocked discards the connection after a failure or on Close, so the
+// next Publish redials; pending acknowledgments time out. The caller holds
+// c.mu, and c.conn is set.
+func (c *Client) dropLocked() error {
+	close(c.stop)
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// readPacket reads one packet: its header byte and body.
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	h, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, mult := 0, 1
+	for i := 0; ; i++ {
+		d, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n += int(d&0x7f) * mult
+		if d&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return 0, nil, errors.New("mqtt: malformed packet length")
+		}
+		mult *= 128
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return h, body, nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
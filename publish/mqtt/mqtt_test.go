@@ -0,0 +1,444 @@
+package mqtt_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/eventspb"
+	"github.com/cortexswarm/smart-turn-go/publish"
+	"github.com/cortexswarm/smart-turn-go/publish/mqtt"
+)
+
+// broker is a fake MQTT 3.1.1 broker speaking the part of the protocol a
+// publishing client uses.
+type broker struct {
+	ln     net.Listener
+	refuse byte // CONNACK return code
+
+	mu          sync.Mutex
+	noAck       bool // leave QoS 1 publishes unacknowledged
+	connects    []connect
+	msgs        []msg
+	conns       []net.Conn
+	pings       chan struct{}
+	disconnects chan struct{}
+}
+
+type connect struct {
+	protocol           string
+	level, flags       byte
+	keepAlive          uint16
+	clientID           string
+	username, password string
+}
+
+type msg struct {
+	topic string
+	qos   byte
+	id    uint16
+	data  string
+}
+
+// newBroker starts a broker configured by tweak, if set.
+func newBroker(t *testing.T, tweak func(*broker)) *broker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &broker{ln: ln, pings: make(chan struct{}, 16), disconnects: make(chan struct{}, 16)}
+	if tweak != nil {
+		tweak(b)
+	}
+	t.Cleanup(func() {
+		ln.Close()
+		b.drop()
+	})
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			b.conns = append(b.conns, c)
+			b.mu.Unlock()
+			go b.serve(c)
+		}
+	}()
+	return b
+}
+
+func (b *broker) addr() string { return b.ln.Addr().String() }
+
+func (b *broker) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		h, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch h >> 4 {
+		case 1: // CONNECT
+			var cn connect
+			cn.protocol, body = readString(body)
+			cn.level, cn.flags = body[0], body[1]
+			cn.keepAlive = binary.BigEndian.Uint16(body[2:])
+			cn.clientID, body = readString(body[4:])
+			if cn.flags&0x80 != 0 {
+				cn.username, body = readString(body)
+			}
+			if cn.flags&0x40 != 0 {
+				cn.password, _ = readString(body)
+			}
+			b.mu.Lock()
+			b.connects = append(b.connects, cn)
+			b.mu.Unlock()
+			c.Write([]byte{0x20, 2, 0, b.refuse})
+			if b.refuse != 0 {
+				return
+			}
+		case 3: // PUBLISH
+			m := msg{qos: h >> 1 & 3}
+			m.topic, body = readString(body)
+			if m.qos > 0 {
+				m.id, body = binary.BigEndian.Uint16(body), body[2:]
+			}
+			m.data = string(body)
+			b.mu.Lock()
+			b.msgs = append(b.msgs, m)
+			ack := m.qos > 0 && !b.noAck
+			b.mu.Unlock()
+			if ack {
+				c.Write([]byte{0x40, 2, byte(m.id >> 8), byte(m.id)})
+			}
+		case 12: // PINGREQ
+			c.Write([]byte{0xd0, 0})
+			b.pings <- struct{}{}
+		case 14: // DISCONNECT
+			b.disconnects <- struct{}{}
+			return
+		default:
+			return
+		}
+	}
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	h, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, shift := 0, 0
+	for {
+		d, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n |= int(d&0x7f) << shift
+		if d&0x80 == 0 {
+			break
+		}
+		shift += 7
+	}
+	body := make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	return h, body, err
+}
+
+func readString(b []byte) (string, []byte) {
+	n := int(binary.BigEndian.Uint16(b))
+	return string(b[2 : 2+n]), b[2+n:]
+}
+
+func (b *broker) messages() []msg {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]msg(nil), b.msgs...)
+}
+
+func (b *broker) connections() []connect {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]connect(nil), b.connects...)
+}
+
+// drop closes the broker side of every connection.
+func (b *broker) drop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, c := range b.conns {
+		c.Close()
+	}
+	b.conns = nil
+}
+
+// disconnected waits for a DISCONNECT, after which the broker has read
+// everything the client sent before it.
+func (b *broker) disconnected(t *testing.T) {
+	t.Helper()
+	select {
+	case <-b.disconnects:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no DISCONNECT")
+	}
+}
+
+func dial(t *testing.T, b *broker, opts mqtt.Options) *mqtt.Client {
+	t.Helper()
+	if opts.ClientID == "" {
+		opts.ClientID = "kitchen-speaker"
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	c, err := mqtt.Dial(b.addr(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestPublish(t *testing.T) {
+	big := strings.Repeat("x", 20000) // a three-byte remaining length
+	for _, qos := range []byte{0, 1} {
+		b := newBroker(t, nil)
+		c := dial(t, b, mqtt.Options{QoS: qos, Username: "speaker", Password: "s3cret", KeepAlive: 90 * time.Second})
+		for _, data := range []string{"one", "", big} {
+			if err := c.Publish("home/smartturn", "kitchen", []byte(data)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := c.Publish("bare", "", []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+		b.disconnected(t)
+
+		want := []msg{
+			{"home/smartturn/kitchen", qos, 1, "one"},
+			{"home/smartturn/kitchen", qos, 2, ""},
+			{"home/smartturn/kitchen", qos, 3, big},
+			{"bare", qos, 4, "x"},
+		}
+		if qos == 0 {
+			for i := range want {
+				want[i].id = 0
+			}
+		}
+		if got := b.messages(); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("QoS %d: broker got %.80q, want %.80q", qos, got, want)
+		}
+		wantConnect := connect{"MQTT", 4, 0xc2, 90, "kitchen-speaker", "speaker", "s3cret"}
+		if got := b.connections(); len(got) != 1 || got[0] != wantConnect {
+			t.Errorf("CONNECT %+v, want %+v", got, wantConnect)
+		}
+	}
+
+	// Without credentials only the clean session flag is set.
+	b := newBroker(t, nil)
+	c := dial(t, b, mqtt.Options{})
+	c.Close()
+	b.disconnected(t)
+	want := connect{"MQTT", 4, 0x02, uint16(mqtt.DefaultKeepAlive / time.Second), "kitchen-speaker", "", ""}
+	if got := b.connections(); len(got) != 1 || got[0] != want {
+		t.Errorf("CONNECT %+v, want %+v", got, want)
+	}
+}
+
+func TestDialErrors(t *testing.T) {
+	b := newBroker(t, func(b *broker) { b.refuse = 5 })
+	_, err := mqtt.Dial(b.addr(), mqtt.Options{ClientID: "kitchen-speaker", Timeout: 5 * time.Second})
+	if err == nil || !strings.Contains(err.Error(), "connection refused (code 5)") {
+		t.Errorf("Dial refused by the broker: %v", err)
+	}
+	ok := newBroker(t, nil)
+	for name, opts := range map[string]mqtt.Options{
+		"no ClientID": {},
+		"QoS 2":       {ClientID: "kitchen-speaker", QoS: 2},
+	} {
+		if _, err := mqtt.Dial(ok.addr(), opts); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+
+	// Dial replaces the TCP dial, and its errors are returned.
+	failed := errors.New("tls: handshake failure")
+	var addrs []string
+	_, err = mqtt.Dial("broker.local:8883", mqtt.Options{ClientID: "kitchen-speaker",
+		Dial: func(addr string, _ time.Duration) (net.Conn, error) {
+			addrs = append(addrs, addr)
+			return nil, failed
+		}})
+	if !errors.Is(err, failed) || len(addrs) != 1 || addrs[0] != "broker.local:8883" {
+		t.Errorf("Dial with a failing Dial option: %v, dialed %q", err, addrs)
+	}
+
+	// A broker that answers CONNECT with something else.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		readPacket(bufio.NewReader(c))
+		c.Write([]byte{0xd0, 0})
+		io.Copy(io.Discard, c)
+	}()
+	_, err = mqtt.Dial(ln.Addr().String(), mqtt.Options{ClientID: "kitchen-speaker", Timeout: 5 * time.Second})
+	if err == nil || !strings.Contains(err.Error(), "expected CONNACK") {
+		t.Errorf("Dial answered with PINGRESP: %v", err)
+	}
+}
+
+// TestKeepAlive checks that an idle client pings more often than
+// KeepAlive, the interval the broker expects to hear from it.
+func TestKeepAlive(t *testing.T) {
+	const keepAlive = 400 * time.Millisecond
+	b := newBroker(t, nil)
+	dial(t, b, mqtt.Options{KeepAlive: keepAlive})
+	select {
+	case <-b.pings:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no PINGREQ")
+	}
+	for range 2 {
+		start := time.Now()
+		select {
+		case <-b.pings:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no PINGREQ")
+		}
+		if d := time.Since(start); d >= keepAlive {
+			t.Errorf("PINGREQ %v after the last, want under %v", d, keepAlive)
+		}
+	}
+}
+
+// TestAckTimeout checks that a QoS 1 publish the broker does not
+// acknowledge fails after Timeout, and that the next publish redials.
+func TestAckTimeout(t *testing.T) {
+	b := newBroker(t, func(b *broker) { b.noAck = true })
+	c := dial(t, b, mqtt.Options{QoS: 1, Timeout: 200 * time.Millisecond})
+	err := c.Publish("home/smartturn", "kitchen", []byte("lost"))
+	if err == nil || !strings.Contains(err.Error(), "not acknowledged") {
+		t.Fatalf("unacknowledged publish: %v", err)
+	}
+	b.mu.Lock()
+	b.noAck = false
+	b.mu.Unlock()
+	if err := c.Publish("home/smartturn", "kitchen", []byte("again")); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(b.connections()); n != 2 {
+		t.Errorf("%d connections, want 2", n)
+	}
+}
+
+// closeConn reports on closed when the client closes it.
+type closeConn struct {
+	net.Conn
+	closed chan struct{}
+}
+
+func (c closeConn) Close() error {
+	c.closed <- struct{}{}
+	return c.Conn.Close()
+}
+
+// TestRedial checks that the client notices the broker dropping the
+// connection, and that the next publish goes out on a new one.
+func TestRedial(t *testing.T) {
+	b := newBroker(t, nil)
+	closed := make(chan struct{}, 4)
+	c := dial(t, b, mqtt.Options{QoS: 1, Dial: func(addr string, timeout time.Duration) (net.Conn, error) {
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		return closeConn{conn, closed}, err
+	}})
+	b.drop()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the client did not notice the dropped connection")
+	}
+	if err := c.Publish("t", "k", []byte("after")); err != nil {
+		t.Fatalf("publish after the drop: %v", err)
+	}
+	if got := b.messages(); len(got) != 1 || got[0].data != "after" {
+		t.Errorf("broker got %q", got)
+	}
+	if n := len(b.connections()); n != 2 {
+		t.Errorf("%d connections, want 2", n)
+	}
+}
+
+func TestClose(t *testing.T) {
+	b := newBroker(t, nil)
+	c := dial(t, b, mqtt.Options{})
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b.disconnected(t)
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if err := c.Publish("t", "k", nil); !errors.Is(err, mqtt.ErrClosed) {
+		t.Errorf("Publish after Close: %v, want ErrClosed", err)
+	}
+	if n := len(b.connections()); n != 1 {
+		t.Errorf("%d connections, want 1", n)
+	}
+}
+
+// TestPublisher publishes events through a publish.Publisher and decodes
+// them from the broker.
+func TestPublisher(t *testing.T) {
+	b := newBroker(t, nil)
+	c := dial(t, b, mqtt.Options{QoS: 1})
+	p, err := publish.New(c, publish.Options{Topic: "home/smartturn", SessionID: "kitchen"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb := p.Wrap(smartturn.Callbacks{})
+	cb.OnTurnStart(smartturn.TurnStart{ID: 0})
+	cb.OnSpeechStart()
+	cb.OnTurnEnd(smartturn.TurnEndTimeout)
+	cb.OnSpeechEnd()
+	p.Close()
+	var kinds []smartturn.EventKind
+	for _, m := range b.messages() {
+		if m.topic != "home/smartturn/kitchen" {
+			t.Errorf("topic %q", m.topic)
+		}
+		dec, err := eventspb.Unmarshal([]byte(m.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		kinds = append(kinds, dec.Event.Kind)
+		if dec.Event.Kind == smartturn.EventSpeechEnd && dec.Event.EndReason != smartturn.TurnEndTimeout {
+			t.Errorf("speech end reason %v", dec.Event.EndReason)
+		}
+	}
+	want := []smartturn.EventKind{smartturn.EventTurnStart, smartturn.EventSpeechStart, smartturn.EventSpeechEnd}
+	if fmt.Sprint(kinds) != fmt.Sprint(want) {
+		t.Errorf("published %v, want %v", kinds, want)
+	}
+}
@@ -0,0 +1,342 @@
+// Package nats is a minimal NATS client for publishing turn events to
+// subjects, for deployments that run NATS without wanting its full client
+// as a dependency. It speaks the core text protocol, only publishes, and
+// is a publish.Bus:
+//
+//	c, err := nats.Dial("nats.internal:4222", nats.Options{Name: "smartturn", Token: token})
+//	p, err := publish.New(c, publish.Options{Topic: "smartturn.events", SessionID: callID})
+//	engine, err := smartturn.New(cfg, p.Wrap(callbacks))
+//
+// Messages go to subject topic.key, e.g. smartturn.events.call-42. Core
+// NATS delivers at most once; Flush waits until the server has processed
+// everything published before it. A lost connection is redialed on the
+// next publish.
+package nats
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configures a Client.
+type Options struct {
+	// Name identifies the connection in the server's monitoring.
+	Name string
+	// User and Password, or Token, are sent on connect when set.
+	User     string
+	Password string
+	Token    string
+	// TLS upgrades the connection after the server's INFO, as NATS
+	// servers expect. A server that requires TLS gets a default config
+	// for the dialed host when TLS is nil.
+	TLS *tls.Config
+	// Timeout bounds dialing, connecting, and Flush; default 10s.
+	Timeout time.Duration
+	// Dial opens the connection to addr; default a TCP dial.
+	Dial func(addr string, timeout time.Duration) (net.Conn, error)
+}
+
+// ErrClosed is returned by Publish and Flush after Close.
+var ErrClosed = errors.New("nats: client closed")
+
+// serverInfo is the part of the server's INFO the client uses.
+type serverInfo struct {
+	TLSRequired bool  `json:"tls_required"`
+	MaxPayload  int64 `json:"max_payload"`
+}
+
+// Client publishes to one server. It is safe for concurrent use.
+type Client struct {
+	addr string
+	opts Options
+
+	mu         sync.Mutex // guards the fields below and writes to conn
+	conn       net.Conn
+	w          *bufio.Writer
+	maxPayload int64
+	pongs      []chan error // Flush calls waiting for a PONG, in order
+	closed     bool
+}
+
+// Dial connects to the server at addr (host:port).
+func Dial(addr string, opts Options) (*Client, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Dial == nil {
+		opts.Dial = func(addr string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("tcp", addr, timeout)
+		}
+	}
+	c := &Client{addr: addr, opts: opts}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Publish sends msg to subject topic.key, redialing first if the
+// connection was lost. It returns once the message is written; use Flush
+// to wait for the server.
+func (c *Client) Publish(topic, key string, msg []byte) error {
+	subject := topic
+	if key != "" {
+		subject += "." + key
+	}
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return fmt.Errorf("nats: invalid subject %q", subject)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return err
+		}
+	}
+	if c.maxPayload > 0 && int64(len(msg)) > c.maxPayload {
+		return fmt.Errorf("nats: message of %d bytes exceeds the server's max payload of %d", len(msg), c.maxPayload)
+	}
+	c.w.WriteString("PUB ")
+	c.w.WriteString(subject)
+	c.w.WriteByte(' ')
+	c.w.WriteString(strconv.Itoa(len(msg)))
+	c.w.WriteString("\r\n")
+	c.w.Write(msg)
+	c.w.WriteString("\r\n")
+	return c.flushLocked()
+}
+
+// Flush waits until the server has processed the messages published
+// before it, or reports the error (-ERR) it answered with.
+func (c *Client) Flush() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	if c.conn == nil {
+		c.mu.Unlock()
+		return errors.New("nats: not connected")
+	}
+	pong := make(chan error, 1)
+	c.pongs = append(c.pongs, pong)
+	c.w.WriteString("PING\r\n")
+	err := c.flushLocked()
+	conn := c.conn
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-pong:
+		return err
+	case <-time.After(c.opts.Timeout):
+		c.mu.Lock()
+		if c.conn == conn {
+			// The connection is presumably dead; redial on the next publish.
+			c.dropLocked(errors.New("nats: connection dropped"))
+		}
+		c.mu.Unlock()
+		return fmt.Errorf("nats: no PONG within %v", c.opts.Timeout)
+	}
+}
+
+// Close disconnects from the server. It does not flush.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	return c.dropLocked(ErrClosed)
+}
+
+// connect dials and completes the handshake: INFO, the optional TLS
+// upgrade, CONNECT, then a PING the server answers with PONG or -ERR once
+// it accepted the connection. The caller holds c.mu.
+func (c *Client) connect() error {
+	conn, err := c.opts.Dial(c.addr, c.opts.Timeout)
+	if err != nil {
+		return fmt.Errorf("nats: %w", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(c.opts.Timeout))
+	conn, r, info, err := c.handshake(conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	c.conn, c.w = conn, bufio.NewWriter(conn)
+	c.maxPayload = info.MaxPayload
+	go c.read(conn, r)
+	return nil
+}
+
+// handshake runs the handshake of connect on conn, returning the
+// connection to use from then on (conn, or its TLS client) and its reader.
+func (c *Client) handshake(conn net.Conn) (net.Conn, *bufio.Reader, serverInfo, error) {
+	var info serverInfo
+	r := bufio.NewReader(conn)
+	line, err := readLine(r)
+	if err != nil {
+		return conn, r, info, fmt.Errorf("nats: %w", err)
+	}
+	op, arg, _ := strings.Cut(line, " ")
+	if op != "INFO" || json.Unmarshal([]byte(arg), &info) != nil {
+		return conn, r, info, fmt.Errorf("nats: expected INFO, got %q", line)
+	}
+	if c.opts.TLS != nil || info.TLSRequired {
+		cfg := c.opts.TLS
+		if cfg == nil {
+			host, _, _ := net.SplitHostPort(c.addr)
+			cfg = &tls.Config{ServerName: host}
+		}
+		tc := tls.Client(conn, cfg)
+		if err := tc.Handshake(); err != nil {
+			return conn, r, info, fmt.Errorf("nats: %w", err)
+		}
+		conn, r = tc, bufio.NewReader(tc)
+	}
+	connect, _ := json.Marshal(struct {
+		Verbose  bool   `json:"verbose"`
+		Pedantic bool   `json:"pedantic"`
+		TLS      bool   `json:"tls_required"`
+		Name     string `json:"name,omitempty"`
+		User     string `json:"user,omitempty"`
+		Password string `json:"pass,omitempty"`
+		Token    string `json:"auth_token,omitempty"`
+		Lang     string `json:"lang"`
+		Version  string `json:"version"`
+		Protocol int    `json:"protocol"`
+		Echo     bool   `json:"echo"`
+	}{
+		TLS: c.opts.TLS != nil || info.TLSRequired, Name: c.opts.Name,
+		User: c.opts.User, Password: c.opts.Password, Token: c.opts.Token,
+		Lang: "go", Version: "smartturn", Protocol: 1,
+	})
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		return conn, r, info, fmt.Errorf("nats: %w", err)
+	}
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return conn, r, info, fmt.Errorf("nats: %w", err)
+		}
+		switch op, arg, _ := strings.Cut(line, " "); op {
+		case "PONG":
+			return conn, r, info, nil
+		case "-ERR":
+			return conn, r, info, serverError(arg)
+		case "PING":
+			if _, err := io.WriteString(conn, "PONG\r\n"); err != nil {
+				return conn, r, info, fmt.Errorf("nats: %w", err)
+			}
+		case "+OK", "INFO":
+		default:
+			return conn, r, info, fmt.Errorf("nats: unexpected %q while connecting", line)
+		}
+	}
+}
+
+// read handles the server's operations on conn until it fails: answers
+// PING, completes Flush calls on PONG and -ERR, and follows INFO updates.
+func (c *Client) read(conn net.Conn, r *bufio.Reader) {
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			c.mu.Lock()
+			if c.conn == conn {
+				c.dropLocked(fmt.Errorf("nats: %w", err))
+			}
+			c.mu.Unlock()
+			return
+		}
+		op, arg, _ := strings.Cut(line, " ")
+		c.mu.Lock()
+		if c.conn != conn {
+			c.mu.Unlock()
+			return
+		}
+		switch op {
+		case "PING":
+			c.w.WriteString("PONG\r\n")
+			_ = c.flushLocked()
+		case "PONG":
+			c.pongLocked(nil)
+		case "-ERR":
+			// The server closes the connection after most errors; fail the
+			// oldest Flush either way.
+			c.pongLocked(serverError(arg))
+		case "INFO":
+			var info serverInfo
+			if json.Unmarshal([]byte(arg), &info) == nil && info.MaxPayload > 0 {
+				c.maxPayload = info.MaxPayload
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// pongLocked completes the oldest Flush. The caller holds c.mu.
+func (c *Client) pongLocked(err error) {
+	if len(c.pongs) == 0 {
+		return
+	}
+	c.pongs[0] <- err
+	c.pongs = c.pongs[1:]
+}
+
+// flushLocked writes out c.w, dropping the connection on failure. The
+// caller holds c.mu.
+func (c *Client) flushLocked() error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.opts.Timeout))
+	if err := c.w.Flush(); err != nil {
+		c.dropLocked(fmt.Errorf("nats: %w", err))
+		return fmt.Errorf("nats: %w", err)
+	}
+	return nil
+}
+
+// dropLocked discards the connection after a failure or on Close, so the
+// next Publish redials, and fails the pending Flush calls with err. The
+// caller holds c.mu, and c.conn is set.
+func (c *Client) dropLocked(err error) error {
+	for _, pong := range c.pongs {
+		pong <- err
+	}
+	c.pongs = nil
+	cerr := c.conn.Close()
+	c.conn = nil
+	return cerr
+}
+
+// readLine reads one protocol line without its CRLF.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func serverError(arg string) error {
+	return fmt.Errorf("nats: server error: %s", strings.Trim(arg, "'"))
+}
@@ -0,0 +1,298 @@
+package nats_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/eventspb"
+	"github.com/cortexswarm/smart-turn-go/publish"
+	"github.com/cortexswarm/smart-turn-go/publish/nats"
+)
+
+// server is a fake NATS server speaking the part of the core protocol a
+// publisher uses.
+type server struct {
+	ln      net.Listener
+	token   string // required auth_token, if set
+	max     int    // max_payload
+	failPub string // subject a PUB to is answered with -ERR
+
+	mu       sync.Mutex
+	connects []map[string]any
+	msgs     []msg
+	conns    []net.Conn
+	pongs    chan struct{} // PONGs received, answering the server's PINGs
+}
+
+type msg struct {
+	subject string
+	data    string
+}
+
+// newServer starts a server configured by tweak, if set.
+func newServer(t *testing.T, tweak func(*server)) *server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{ln: ln, max: 1 << 20, pongs: make(chan struct{}, 16)}
+	if tweak != nil {
+		tweak(s)
+	}
+	t.Cleanup(func() {
+		ln.Close()
+		s.mu.Lock()
+		for _, c := range s.conns {
+			c.Close()
+		}
+		s.mu.Unlock()
+	})
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, c)
+			s.mu.Unlock()
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *server) addr() string { return s.ln.Addr().String() }
+
+func (s *server) serve(c net.Conn) {
+	defer c.Close()
+	fmt.Fprintf(c, "INFO {\"server_id\":\"fake\",\"max_payload\":%d,\"auth_required\":%v}\r\n", s.max, s.token != "")
+	r := bufio.NewReader(c)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		op, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		switch op {
+		case "CONNECT":
+			var opts map[string]any
+			if err := json.Unmarshal([]byte(arg), &opts); err != nil {
+				fmt.Fprintf(c, "-ERR 'Unknown Protocol Operation'\r\n")
+				return
+			}
+			s.mu.Lock()
+			s.connects = append(s.connects, opts)
+			s.mu.Unlock()
+			if s.token != "" && opts["auth_token"] != s.token {
+				fmt.Fprintf(c, "-ERR 'Authorization Violation'\r\n")
+				return
+			}
+		case "PUB":
+			f := strings.Fields(arg)
+			n, err := strconv.Atoi(f[len(f)-1])
+			if err != nil {
+				return
+			}
+			data := make([]byte, n+2)
+			if _, err := io.ReadFull(r, data); err != nil || string(data[n:]) != "\r\n" {
+				return
+			}
+			s.mu.Lock()
+			s.msgs = append(s.msgs, msg{f[0], string(data[:n])})
+			s.mu.Unlock()
+			if f[0] == s.failPub {
+				fmt.Fprintf(c, "-ERR 'Permissions Violation for Publish to %s'\r\n", f[0])
+			}
+		case "PING":
+			fmt.Fprintf(c, "PONG\r\n")
+		case "PONG":
+			s.pongs <- struct{}{}
+		default:
+			fmt.Fprintf(c, "-ERR 'Unknown Protocol Operation'\r\n")
+			return
+		}
+	}
+}
+
+func (s *server) messages() []msg {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]msg(nil), s.msgs...)
+}
+
+// drop closes the server side of every connection.
+func (s *server) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+func dial(t *testing.T, s *server, opts nats.Options) *nats.Client {
+	t.Helper()
+	opts.Timeout = 5 * time.Second
+	c, err := nats.Dial(s.addr(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestPublish(t *testing.T) {
+	s := newServer(t, func(s *server) { s.token = "s3cret" })
+	c := dial(t, s, nats.Options{Name: "kitchen", Token: "s3cret"})
+	for _, data := range []string{"one", "", "with\r\nCRLF and PUB inside"} {
+		if err := c.Publish("smartturn.events", "call-1", []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Publish("bare", "", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := []msg{
+		{"smartturn.events.call-1", "one"},
+		{"smartturn.events.call-1", ""},
+		{"smartturn.events.call-1", "with\r\nCRLF and PUB inside"},
+		{"bare", "x"},
+	}
+	if got := s.messages(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("server got %q, want %q", got, want)
+	}
+	s.mu.Lock()
+	opts := s.connects[0]
+	s.mu.Unlock()
+	if opts["name"] != "kitchen" || opts["verbose"] != false || opts["echo"] != false {
+		t.Errorf("CONNECT %v", opts)
+	}
+}
+
+func TestAuthorizationViolation(t *testing.T) {
+	s := newServer(t, func(s *server) { s.token = "s3cret" })
+	_, err := nats.Dial(s.addr(), nats.Options{Token: "wrong", Timeout: 5 * time.Second})
+	if err == nil || !strings.Contains(err.Error(), "Authorization Violation") {
+		t.Errorf("Dial with a wrong token: %v", err)
+	}
+}
+
+func TestServerPing(t *testing.T) {
+	s := newServer(t, nil)
+	dial(t, s, nats.Options{})
+	s.mu.Lock()
+	fmt.Fprintf(s.conns[0], "PING\r\n")
+	s.mu.Unlock()
+	select {
+	case <-s.pongs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no PONG to the server's PING")
+	}
+}
+
+func TestPublishErrors(t *testing.T) {
+	s := newServer(t, func(s *server) {
+		s.max = 8
+		s.failPub = "denied.key"
+	})
+	c := dial(t, s, nats.Options{})
+	if err := c.Publish("a b", "", nil); err == nil {
+		t.Error("subject with a space accepted")
+	}
+	if err := c.Publish("big", "", make([]byte, 9)); err == nil {
+		t.Error("message over max_payload accepted")
+	}
+	if err := c.Publish("denied", "key", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(); err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+		t.Errorf("Flush after a refused publish: %v", err)
+	}
+	c.Close()
+	if err := c.Publish("t", "k", nil); !errors.Is(err, nats.ErrClosed) {
+		t.Errorf("Publish after Close: %v, want ErrClosed", err)
+	}
+}
+
+// TestRedial checks that a publish after the server dropped the
+// connection goes out on a new one.
+func TestRedial(t *testing.T) {
+	s := newServer(t, nil)
+	c := dial(t, s, nats.Options{})
+	s.drop()
+	// Publish once the client noticed, or redial when the write fails.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := c.Publish("t", "k", []byte("after"))
+		if err == nil {
+			if err = c.Flush(); err == nil {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no publish after the drop: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := s.messages(); len(got) == 0 || got[len(got)-1] != (msg{"t.k", "after"}) {
+		t.Errorf("server got %q", got)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.connects) != 2 {
+		t.Errorf("%d connections, want 2", len(s.connects))
+	}
+}
+
+// TestPublisher publishes events through a publish.Publisher and decodes
+// them from the server.
+func TestPublisher(t *testing.T) {
+	s := newServer(t, nil)
+	c := dial(t, s, nats.Options{})
+	p, err := publish.New(c, publish.Options{Topic: "smartturn.events", SessionID: "call-9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb := p.Wrap(smartturn.Callbacks{})
+	cb.OnTurnStart(smartturn.TurnStart{ID: 0})
+	cb.OnSpeechStart()
+	cb.OnTurnEnd(smartturn.TurnEndTimeout)
+	cb.OnSpeechEnd()
+	p.Close()
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	var kinds []smartturn.EventKind
+	for _, m := range s.messages() {
+		if m.subject != "smartturn.events.call-9" {
+			t.Errorf("subject %q", m.subject)
+		}
+		dec, err := eventspb.Unmarshal([]byte(m.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		kinds = append(kinds, dec.Event.Kind)
+		if dec.Event.Kind == smartturn.EventSpeechEnd && dec.Event.EndReason != smartturn.TurnEndTimeout {
+			t.Errorf("speech end reason %v", dec.Event.EndReason)
+		}
+	}
+	want := []smartturn.EventKind{smartturn.EventTurnStart, smartturn.EventSpeechStart, smartturn.EventSpeechEnd}
+	if fmt.Sprint(kinds) != fmt.Sprint(want) {
+		t.Errorf("published %v, want %v", kinds, want)
+	}
+}
@@ -0,0 +1,272 @@
+// Package publish forwards the turn events of an engine to a message bus,
+// such as NATS subjects or Kafka topics, keyed by session ID, so
+// downstream services (ASR, analytics) consume them asynchronously.
+// Messages are eventspb Event messages.
+//
+// Package publish/nats provides a Bus for NATS; otherwise Bus adapts the
+// broker client the application already uses.
+//
+//	// NATS: one subject per session
+//	bus, err := nats.Dial("nats.internal:4222", nats.Options{Name: "smartturn"})
+//	// Kafka (segmentio/kafka-go): the session ID as the message key
+//	bus := publish.BusFunc(func(topic, key string, msg []byte) error {
+//		return w.WriteMessages(ctx, kafka.Message{Topic: topic, Key: []byte(key), Value: msg})
+//	})
+//
+//	p, err := publish.New(bus, publish.Options{Topic: "smartturn.events", SessionID: callID})
+//	engine, err := smartturn.New(cfg, p.Wrap(callbacks))
+//	p.Attach(engine)
+//	...
+//	engine.Close()
+//	p.Close()
+//
+// Publishing runs on a goroutine of its own behind a bounded queue, so a
+// slow broker never stalls the engine: when the queue is full, events are
+// dropped and counted.
+package publish
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/eventspb"
+)
+
+// DefaultQueue is the number of messages waiting to be published when
+// Options.Queue is zero.
+const DefaultQueue = 256
+
+// Bus publishes one message to topic (a NATS subject, a Kafka topic) with
+// key, the session ID. It is called from the publisher's goroutine only.
+type Bus interface {
+	Publish(topic, key string, msg []byte) error
+}
+
+// BusFunc adapts a function to Bus.
+type BusFunc func(topic, key string, msg []byte) error
+
+// Publish implements Bus.
+func (f BusFunc) Publish(topic, key string, msg []byte) error { return f(topic, key, msg) }
+
+// SegmentStore stores the audio of a speech segment slice of turn (see
+// smartturn.TurnStart.ID) and returns a reference to it, such as an
+// object-store URL, that is published in place of the audio.
+type SegmentStore func(sessionID string, turn int, audio []float32) (ref string, err error)
+
+// Options configures a Publisher.
+type Options struct {
+	// Topic every message is published to; required.
+	Topic string
+	// SessionID is the key of every message and their session_id.
+	SessionID string
+	// Segments, if set, publishes SegmentReady events with a reference to
+	// the audio stored by it; otherwise segments are not published.
+	Segments SegmentStore
+	// Queue bounds the messages waiting to be published; default
+	// DefaultQueue.
+	Queue int
+	// OnError receives failures of Bus and Segments, on the publisher's
+	// goroutine; the engine never sees them.
+	OnError func(err error)
+}
+
+// ErrNoTopic is returned by New without Options.Topic.
+var ErrNoTopic = errors.New("publish: Topic is required")
+
+// Publisher forwards an engine's events to a Bus. Use one per engine.
+type Publisher struct {
+	bus  Bus
+	opts Options
+	enc  eventspb.Encoder
+
+	engine *smartturn.Engine
+	turn   int
+	reason smartturn.TurnEndReason
+
+	mu      sync.Mutex // guards closed and sends on queue
+	closed  bool
+	queue   chan message
+	done    chan struct{}
+	dropped atomic.Uint64
+}
+
+// message is a queued publication: an encoded event, or a segment whose
+// audio is stored before publishing.
+type message struct {
+	msg   []byte
+	ev    smartturn.Event
+	turn  int
+	audio []float32
+}
+
+// New starts a Publisher on bus.
+func New(bus Bus, opts Options) (*Publisher, error) {
+	if opts.Topic == "" {
+		return nil, ErrNoTopic
+	}
+	if opts.Queue <= 0 {
+		opts.Queue = DefaultQueue
+	}
+	p := &Publisher{
+		bus:   bus,
+		opts:  opts,
+		enc:   eventspb.Encoder{SessionID: opts.SessionID},
+		queue: make(chan message, opts.Queue),
+		done:  make(chan struct{}),
+	}
+	go p.run()
+	return p, nil
+}
+
+// Attach sets the engine whose MediaTime stamps the events; until then
+// they carry the wall-clock time of the callback.
+func (p *Publisher) Attach(e *smartturn.Engine) {
+	p.engine = e
+}
+
+// Wrap returns callbacks that publish each turn event, then call cb: speech
+// start and end (with the end reason), turn start, merge and split,
+// Smart-Turn predictions, transcripts, errors, and segments with
+// Options.Segments. Pass the result to smartturn.New.
+func (p *Publisher) Wrap(cb smartturn.Callbacks) smartturn.Callbacks {
+	out := cb
+	out.OnSpeechStart = func() {
+		p.event(smartturn.Event{Kind: smartturn.EventSpeechStart})
+		if cb.OnSpeechStart != nil {
+			cb.OnSpeechStart()
+		}
+	}
+	out.OnTurnStart = func(t smartturn.TurnStart) {
+		p.turn = t.ID
+		p.event(smartturn.Event{Kind: smartturn.EventTurnStart, TurnStart: t})
+		if cb.OnTurnStart != nil {
+			cb.OnTurnStart(t)
+		}
+	}
+	out.OnTurnEnd = func(reason smartturn.TurnEndReason) {
+		p.reason = reason
+		if cb.OnTurnEnd != nil {
+			cb.OnTurnEnd(reason)
+		}
+	}
+	out.OnSpeechEnd = func() {
+		p.event(smartturn.Event{Kind: smartturn.EventSpeechEnd, EndReason: p.reason})
+		if cb.OnSpeechEnd != nil {
+			cb.OnSpeechEnd()
+		}
+	}
+	out.OnTurnMerged = func(m smartturn.TurnMerge) {
+		p.event(smartturn.Event{Kind: smartturn.EventTurnMerged, Merge: m})
+		if cb.OnTurnMerged != nil {
+			cb.OnTurnMerged(m)
+		}
+	}
+	out.OnTurnSplit = func(s smartturn.TurnSplit) {
+		p.event(smartturn.Event{Kind: smartturn.EventTurnSplit, Split: s})
+		if cb.OnTurnSplit != nil {
+			cb.OnTurnSplit(s)
+		}
+	}
+	out.OnTurnPredictionDetail = func(pr smartturn.TurnPrediction) {
+		p.event(smartturn.Event{Kind: smartturn.EventTurnPrediction, Prediction: pr})
+		if cb.OnTurnPredictionDetail != nil {
+			cb.OnTurnPredictionDetail(pr)
+		}
+	}
+	out.OnTranscript = func(t smartturn.Transcript) {
+		p.event(smartturn.Event{Kind: smartturn.EventTranscript, Transcript: t})
+		if cb.OnTranscript != nil {
+			cb.OnTranscript(t)
+		}
+	}
+	out.OnError = func(err error) {
+		p.event(smartturn.Event{Kind: smartturn.EventError, Err: err})
+		if cb.OnError != nil {
+			cb.OnError(err)
+		}
+	}
+	if p.opts.Segments != nil {
+		out.OnSegmentReady = func(segment []float32) {
+			// The engine reuses the slice; the store runs later.
+			audio := append([]float32(nil), segment...)
+			p.enqueue(message{ev: p.stamp(smartturn.Event{Kind: smartturn.EventSegmentReady}), turn: p.turn, audio: audio})
+			if cb.OnSegmentReady != nil {
+				cb.OnSegmentReady(segment)
+			}
+		}
+	}
+	return out
+}
+
+// Dropped returns the number of events dropped because the queue was full
+// or the Publisher closed.
+func (p *Publisher) Dropped() uint64 {
+	return p.dropped.Load()
+}
+
+// Close publishes the queued events and stops the Publisher. Close the
+// engine first: events after Close are dropped.
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	<-p.done
+	return nil
+}
+
+func (p *Publisher) stamp(ev smartturn.Event) smartturn.Event {
+	if p.engine != nil {
+		ev.Time = p.engine.MediaTime()
+	} else {
+		ev.Time = time.Now()
+	}
+	return ev
+}
+
+func (p *Publisher) event(ev smartturn.Event) {
+	p.enqueue(message{msg: p.enc.AppendEvent(nil, p.stamp(ev))})
+}
+
+func (p *Publisher) enqueue(m message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		p.dropped.Add(1)
+		return
+	}
+	select {
+	case p.queue <- m:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+func (p *Publisher) run() {
+	defer close(p.done)
+	for m := range p.queue {
+		if m.audio != nil {
+			ref, err := p.opts.Segments(p.opts.SessionID, m.turn, m.audio)
+			if err != nil {
+				p.fail(err)
+				continue
+			}
+			m.ev.Segment = m.audio
+			m.msg = p.enc.AppendSegmentRef(nil, m.ev, ref)
+		}
+		if err := p.bus.Publish(p.opts.Topic, p.opts.SessionID, m.msg); err != nil {
+			p.fail(err)
+		}
+	}
+}
+
+func (p *Publisher) fail(err error) {
+	if p.opts.OnError != nil {
+		p.opts.OnError(err)
+	}
+}
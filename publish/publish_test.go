@@ -0,0 +1,410 @@
+package publish_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/eventspb"
+	"github.com/cortexswarm/smart-turn-go/publish"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// bus is an in-memory Bus that decodes what it is sent. With gate set,
+// each Publish first reports on entered, then waits for gate.
+type bus struct {
+	t       *testing.T
+	gate    chan struct{}
+	entered chan struct{}
+
+	mu   sync.Mutex
+	msgs []eventspb.Message
+	err  error // returned by the next Publish, then cleared
+}
+
+func (b *bus) Publish(topic, key string, msg []byte) error {
+	if b.gate != nil {
+		b.entered <- struct{}{}
+		<-b.gate
+	}
+	if topic != "smartturn.events" || key != "call-7" {
+		b.t.Errorf("published to %q with key %q", topic, key)
+	}
+	m, err := eventspb.Unmarshal(msg)
+	if err != nil || m.SessionID != "call-7" {
+		b.t.Errorf("message with session_id %q: %v", m.SessionID, err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.err; err != nil {
+		b.err = nil
+		return err
+	}
+	b.msgs = append(b.msgs, m)
+	return nil
+}
+
+func (b *bus) messages() []eventspb.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.msgs)
+}
+
+func (b *bus) kinds() []smartturn.EventKind {
+	var kinds []smartturn.EventKind
+	for _, m := range b.messages() {
+		kinds = append(kinds, m.Event.Kind)
+	}
+	return kinds
+}
+
+// store is an in-memory SegmentStore; with fail set, it fails.
+type store struct {
+	mu    sync.Mutex
+	audio map[string][]float32
+	fail  bool
+}
+
+func (s *store) save(sessionID string, turn int, audio []float32) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return "", errors.New("store failed")
+	}
+	if s.audio == nil {
+		s.audio = map[string][]float32{}
+	}
+	ref := fmt.Sprintf("mem://%s/%d/%d", sessionID, turn, len(s.audio))
+	s.audio[ref] = audio
+	return ref, nil
+}
+
+// newPublisher returns a Publisher on b for session call-7.
+func newPublisher(t *testing.T, b *bus, opts publish.Options) *publish.Publisher {
+	t.Helper()
+	b.t = t
+	opts.Topic, opts.SessionID = "smartturn.events", "call-7"
+	p, err := publish.New(b, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+// TestPublisher runs an engine through a Publisher and checks that each
+// turn event is published in order, stamped with the engine's media time,
+// and still reaches the wrapped callbacks.
+func TestPublisher(t *testing.T) {
+	b, s := &bus{}, &store{}
+	p := newPublisher(t, b, publish.Options{Segments: s.save})
+
+	// want holds the events the wrapped callbacks saw, stamped with the
+	// media time of the chunk, and the turn of each segment.
+	var e *smartturn.Engine
+	var want []smartturn.Event
+	var turns []int
+	turn, reason := -1, smartturn.TurnEndReason(-1)
+	record := func(ev smartturn.Event) {
+		ev.Time = e.MediaTime()
+		want = append(want, ev)
+		turns = append(turns, turn)
+	}
+	cb := smartturn.Callbacks{
+		OnSpeechStart: func() { record(smartturn.Event{Kind: smartturn.EventSpeechStart}) },
+		OnTurnStart: func(s smartturn.TurnStart) {
+			turn = s.ID
+			record(smartturn.Event{Kind: smartturn.EventTurnStart, TurnStart: s})
+		},
+		OnTurnEnd:   func(r smartturn.TurnEndReason) { reason = r },
+		OnSpeechEnd: func() { record(smartturn.Event{Kind: smartturn.EventSpeechEnd, EndReason: reason}) },
+		OnTurnPredictionDetail: func(pr smartturn.TurnPrediction) {
+			record(smartturn.Event{Kind: smartturn.EventTurnPrediction, Prediction: pr})
+		},
+		OnSegmentReady: func(seg []float32) {
+			record(smartturn.Event{Kind: smartturn.EventSegmentReady, Segment: slices.Clone(seg)})
+		},
+	}
+	e, err := smartturn.New(smartturn.Config{
+		SampleRate:             smartturn.RequiredSampleRate,
+		ChunkSize:              smartturn.RequiredChunkSize,
+		VadThreshold:           0.5,
+		VadPreSpeechMs:         200,
+		VadStopMs:              300,
+		TurnMaxDurationSeconds: 600,
+		TurnSegmentEmitMs:      1000,
+		TurnThreshold:          0.5,
+		TurnTimeoutMs:          1000,
+		VADBackend:             &smartturntest.EnergyVAD{},
+		// The first turn times out, the second ends on the model.
+		TurnBackend: &smartturntest.TurnScript{Probabilities: []float32{0.2, 0.9}},
+	}, p.Wrap(cb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Attach(e)
+	e.Start()
+	audio, _ := smartturntest.Synth{Seed: 1}.Generate(
+		smartturntest.Speech(1500*time.Millisecond), smartturntest.Silence(2*time.Second),
+		smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	for i, c := range smartturntest.Chunks(audio) {
+		if i == 0 {
+			err = e.PushPCMAt(c, time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC))
+		} else {
+			err = e.PushPCM(c)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.Close()
+	p.Close()
+
+	got := b.messages()
+	if len(got) != len(want) {
+		t.Fatalf("published %v, want %d events", b.kinds(), len(want))
+	}
+	var reasons []smartturn.TurnEndReason
+	segments := 0
+	for i, m := range got {
+		w := want[i]
+		ev := m.Event
+		if !m.HasEvent || ev.Kind != w.Kind || !ev.Time.Equal(w.Time) {
+			t.Fatalf("message %d: %v at %v, want %v at %v", i, ev.Kind, ev.Time, w.Kind, w.Time)
+		}
+		switch w.Kind {
+		case smartturn.EventTurnStart:
+			if ev.TurnStart.ID != w.TurnStart.ID || ev.TurnStart.Offset != w.TurnStart.Offset || ev.TurnStart.Padding != w.TurnStart.Padding {
+				t.Errorf("turn start %+v, want %+v", ev.TurnStart, w.TurnStart)
+			}
+		case smartturn.EventSpeechEnd:
+			reasons = append(reasons, ev.EndReason)
+		case smartturn.EventTurnPrediction:
+			if ev.Prediction.Probability != w.Prediction.Probability || ev.Prediction.Complete != w.Prediction.Complete {
+				t.Errorf("prediction %+v, want %+v", ev.Prediction, w.Prediction)
+			}
+		case smartturn.EventSegmentReady:
+			segments++
+			prefix := fmt.Sprintf("mem://call-7/%d/", turns[i])
+			if m.SegmentSamples != len(w.Segment) || !strings.HasPrefix(m.SegmentRef, prefix) || len(ev.Segment) != 0 {
+				t.Errorf("segment of %d samples at %q with %d inline, want %d at %s*",
+					m.SegmentSamples, m.SegmentRef, len(ev.Segment), len(w.Segment), prefix)
+			}
+			if !slices.Equal(s.audio[m.SegmentRef], w.Segment) {
+				t.Errorf("stored audio at %q differs from the segment", m.SegmentRef)
+			}
+		}
+	}
+	if want := []smartturn.TurnEndReason{smartturn.TurnEndTimeout, smartturn.TurnEndModel}; !slices.Equal(reasons, want) {
+		t.Errorf("speech end reasons %v, want %v", reasons, want)
+	}
+	if segments < 3 || len(s.audio) != segments || turns[len(turns)-1] != 1 {
+		t.Errorf("%d segments published, %d stored; last turn %d", segments, len(s.audio), turns[len(turns)-1])
+	}
+	if n := p.Dropped(); n != 0 {
+		t.Errorf("%d dropped", n)
+	}
+}
+
+// TestPublisherWrap checks each wrapped callback: it publishes its event,
+// if any, then calls the application's.
+func TestPublisherWrap(t *testing.T) {
+	called := map[string]int{}
+	cb := smartturn.Callbacks{
+		OnSpeechStart:          func() { called["speech start"]++ },
+		OnTurnStart:            func(smartturn.TurnStart) { called["turn start"]++ },
+		OnTurnEnd:              func(smartturn.TurnEndReason) { called["turn end"]++ },
+		OnSpeechEnd:            func() { called["speech end"]++ },
+		OnTurnMerged:           func(smartturn.TurnMerge) { called["merged"]++ },
+		OnTurnSplit:            func(smartturn.TurnSplit) { called["split"]++ },
+		OnTurnPredictionDetail: func(smartturn.TurnPrediction) { called["prediction"]++ },
+		OnTranscript:           func(smartturn.Transcript) { called["transcript"]++ },
+		OnError:                func(error) { called["error"]++ },
+		OnSegmentReady:         func([]float32) { called["segment"]++ },
+		OnVadScore:             func(float32, int64) { called["vad score"]++ },
+	}
+	start := smartturn.TurnStart{ID: 4, Offset: 16000, Padding: 192 * time.Millisecond}
+	merge := smartturn.TurnMerge{Gap: 300 * time.Millisecond}
+	split := smartturn.TurnSplit{Part: 2, Samples: 160000, OverlapSamples: 3200}
+	pred := smartturn.TurnPrediction{Complete: true, Probability: 0.75}
+	tr := smartturn.Transcript{Turn: 3, Text: "hello", Start: 100, End: 200, Reason: smartturn.TurnEndSpeakerChange}
+	for _, tc := range []struct {
+		name    string
+		call    func(smartturn.Callbacks)
+		publish bool
+		want    smartturn.Event
+	}{
+		{"speech start", func(c smartturn.Callbacks) { c.OnSpeechStart() },
+			true, smartturn.Event{Kind: smartturn.EventSpeechStart}},
+		{"turn start", func(c smartturn.Callbacks) { c.OnTurnStart(start) },
+			true, smartturn.Event{Kind: smartturn.EventTurnStart, TurnStart: start}},
+		{"turn end", func(c smartturn.Callbacks) { c.OnTurnEnd(smartturn.TurnEndMaxDuration) }, false, smartturn.Event{}},
+		// The reason of OnTurnEnd goes with the speech end after it.
+		{"speech end", func(c smartturn.Callbacks) {
+			c.OnTurnEnd(smartturn.TurnEndMaxDuration)
+			c.OnSpeechEnd()
+		}, true, smartturn.Event{Kind: smartturn.EventSpeechEnd, EndReason: smartturn.TurnEndMaxDuration}},
+		{"merged", func(c smartturn.Callbacks) { c.OnTurnMerged(merge) },
+			true, smartturn.Event{Kind: smartturn.EventTurnMerged, Merge: merge}},
+		{"split", func(c smartturn.Callbacks) { c.OnTurnSplit(split) },
+			true, smartturn.Event{Kind: smartturn.EventTurnSplit, Split: split}},
+		{"prediction", func(c smartturn.Callbacks) { c.OnTurnPredictionDetail(pred) },
+			true, smartturn.Event{Kind: smartturn.EventTurnPrediction, Prediction: pred}},
+		{"transcript", func(c smartturn.Callbacks) { c.OnTranscript(tr) },
+			true, smartturn.Event{Kind: smartturn.EventTranscript, Transcript: tr}},
+		{"error", func(c smartturn.Callbacks) { c.OnError(errors.New("inference failed")) },
+			true, smartturn.Event{Kind: smartturn.EventError, Err: errors.New("inference failed")}},
+		// Without Options.Segments, segments are not published.
+		{"segment", func(c smartturn.Callbacks) { c.OnSegmentReady(make([]float32, 512)) }, false, smartturn.Event{}},
+		{"vad score", func(c smartturn.Callbacks) { c.OnVadScore(0.5, 0) }, false, smartturn.Event{}},
+	} {
+		clear(called)
+		b := &bus{}
+		p := newPublisher(t, b, publish.Options{})
+		tc.call(p.Wrap(cb))
+		p.Close()
+		if called[tc.name] != 1 {
+			t.Errorf("%s: application callback called %d times", tc.name, called[tc.name])
+		}
+		msgs := b.messages()
+		if !tc.publish {
+			if len(msgs) != 0 {
+				t.Errorf("%s: published %v", tc.name, b.kinds())
+			}
+			continue
+		}
+		if len(msgs) != 1 {
+			t.Errorf("%s: published %v, want one %v", tc.name, b.kinds(), tc.want.Kind)
+			continue
+		}
+		ev := msgs[0].Event
+		ev.Time, ev.TurnStart.Time = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(ev, tc.want) {
+			t.Errorf("%s: published %+v, want %+v", tc.name, ev, tc.want)
+		}
+	}
+
+	// Without the application's callbacks, the wrapped ones still publish.
+	b := &bus{}
+	p := newPublisher(t, b, publish.Options{Segments: (&store{}).save})
+	w := p.Wrap(smartturn.Callbacks{})
+	w.OnSpeechStart()
+	w.OnTurnStart(start)
+	w.OnTurnEnd(smartturn.TurnEndModel)
+	w.OnSpeechEnd()
+	w.OnTurnMerged(merge)
+	w.OnTurnSplit(split)
+	w.OnTurnPredictionDetail(pred)
+	w.OnTranscript(tr)
+	w.OnError(errors.New("failed"))
+	w.OnSegmentReady(make([]float32, 512))
+	p.Close()
+	if n := len(b.messages()); n != 9 {
+		t.Errorf("published %v, want 9 events", b.kinds())
+	}
+}
+
+// TestPublisherTime checks that without Attach events carry the time of
+// the callback.
+func TestPublisherTime(t *testing.T) {
+	b := &bus{}
+	p := newPublisher(t, b, publish.Options{})
+	before := time.Now()
+	p.Wrap(smartturn.Callbacks{}).OnSpeechStart()
+	after := time.Now()
+	p.Close()
+	msgs := b.messages()
+	if len(msgs) != 1 || msgs[0].Time.Before(before) || msgs[0].Time.After(after) {
+		t.Errorf("published %+v, want one event between %v and %v", msgs, before, after)
+	}
+}
+
+// TestPublisherQueue checks that while the bus is slow, events beyond
+// Options.Queue are dropped and counted, that Close publishes those
+// queued, and that events after Close are dropped.
+func TestPublisherQueue(t *testing.T) {
+	b := &bus{gate: make(chan struct{}), entered: make(chan struct{}, 8)}
+	p := newPublisher(t, b, publish.Options{Queue: 2})
+	cb := p.Wrap(smartturn.Callbacks{})
+	cb.OnSpeechStart()
+	<-b.entered // the first event is being published
+	// The callbacks do not wait for the bus.
+	start := time.Now()
+	for range 4 {
+		cb.OnTurnStart(smartturn.TurnStart{})
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("callbacks took %v with the bus stalled", d)
+	}
+	if n := p.Dropped(); n != 2 {
+		t.Errorf("%d dropped with a full queue, want 2", n)
+	}
+	close(b.gate)
+	p.Close()
+	want := []smartturn.EventKind{smartturn.EventSpeechStart, smartturn.EventTurnStart, smartturn.EventTurnStart}
+	if got := b.kinds(); !slices.Equal(got, want) {
+		t.Errorf("published %v, want %v", got, want)
+	}
+
+	cb.OnSpeechEnd()
+	if err := p.Close(); err != nil || p.Dropped() != 3 || len(b.messages()) != 3 {
+		t.Errorf("after Close: %d dropped, %d published, second Close %v", p.Dropped(), len(b.messages()), err)
+	}
+}
+
+// TestPublisherErrors checks that bus and store failures go to
+// Options.OnError and publishing goes on.
+func TestPublisherErrors(t *testing.T) {
+	if _, err := publish.New(&bus{}, publish.Options{SessionID: "call-7"}); !errors.Is(err, publish.ErrNoTopic) {
+		t.Errorf("New without Topic: %v", err)
+	}
+
+	var errs []error
+	b := &bus{err: errors.New("bus failed")}
+	s := &store{fail: true}
+	p := newPublisher(t, b, publish.Options{Segments: s.save, OnError: func(err error) { errs = append(errs, err) }})
+	cb := p.Wrap(smartturn.Callbacks{})
+	cb.OnSpeechStart() // the bus fails
+	cb.OnSegmentReady(make([]float32, 512))
+	cb.OnTurnStart(smartturn.TurnStart{})
+	p.Close()
+	if len(errs) != 2 || errs[0].Error() != "bus failed" || errs[1].Error() != "store failed" {
+		t.Errorf("OnError %v, want the bus's then the store's", errs)
+	}
+	if got := b.kinds(); !slices.Equal(got, []smartturn.EventKind{smartturn.EventTurnStart}) {
+		t.Errorf("published %v, want the turn start", got)
+	}
+
+	// Without OnError, failures are ignored.
+	b = &bus{err: errors.New("bus failed")}
+	p = newPublisher(t, b, publish.Options{})
+	p.Wrap(smartturn.Callbacks{}).OnSpeechStart()
+	p.Close()
+}
+
+// TestPublisherCloseConcurrent closes a Publisher while events arrive
+// from several goroutines; every event is published or counted dropped.
+func TestPublisherCloseConcurrent(t *testing.T) {
+	b := &bus{}
+	p := newPublisher(t, b, publish.Options{Queue: 16})
+	var wg sync.WaitGroup
+	for range 4 {
+		cb := p.Wrap(smartturn.Callbacks{})
+		wg.Go(func() {
+			for range 200 {
+				cb.OnSpeechStart()
+			}
+		})
+	}
+	p.Close()
+	wg.Wait()
+	if n := uint64(len(b.messages())) + p.Dropped(); n != 800 {
+		t.Errorf("%d events published or dropped, want 800", n)
+	}
+}
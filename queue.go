@@ -0,0 +1,279 @@
+package smartturn
+
+import (
+	"sync"
+	"time"
+)
+
+// OverflowPolicy selects what PushPCM does when Config.InputQueue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes PushPCM wait for room in the queue (the default).
+	// The audio source then falls behind instead of losing audio.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest queued chunk to make room, so
+	// processing resumes with the most recent audio.
+	OverflowDropOldest
+	// OverflowDropNewest discards the chunk being pushed.
+	OverflowDropNewest
+)
+
+// InputQueue configures the bounded queue between PushPCM and processing.
+// Each slot holds one 512-sample chunk (32 ms), preallocated in New. Start,
+// Stop, and Reset are queued too, so they apply in order with the audio.
+// Calls made from callbacks are queued like any other; a callback must not
+// push audio with OverflowBlock, since the queue only drains once it returns.
+type InputQueue struct {
+	Size     int // chunks; 0 processes audio synchronously inside PushPCM
+	Overflow OverflowPolicy
+}
+
+type queueOp uint8
+
+const (
+	opAudio queueOp = iota
+	opStart
+	opStop
+	opReset
+	opGap
+)
+
+// queueItem is a queued PushPCM (with its chunk) or lifecycle call. Start,
+// Stop, and Reset go through the queue so they apply in order with audio.
+type queueItem struct {
+	op  queueOp
+	buf *[RequiredChunkSize]float32 // opAudio only
+	ts  time.Time                   // opAudio only: media time, zero if none
+	gap time.Duration               // opGap only
+}
+
+// inputQueue is a FIFO of chunks and lifecycle calls drained by one engine
+// goroutine. Only audio counts against Size; lifecycle calls never block
+// or get dropped.
+type inputQueue struct {
+	e      *Engine
+	policy OverflowPolicy
+	size   int
+
+	mu       sync.Mutex
+	notEmpty sync.Cond
+	notFull  sync.Cond
+	items    []queueItem // pending, from head
+	head     int
+	audio    int                           // opAudio items pending
+	free     []*[RequiredChunkSize]float32 // unused chunk buffers
+	lost     int                           // chunks dropped since the last OnAudioDropped
+	closed   bool
+	done     chan struct{} // closed when run returns
+}
+
+func newInputQueue(e *Engine, cfg InputQueue) *inputQueue {
+	q := &inputQueue{
+		e:      e,
+		policy: cfg.Overflow,
+		size:   cfg.Size,
+		items:  make([]queueItem, 0, cfg.Size+4),
+		free:   make([]*[RequiredChunkSize]float32, cfg.Size+1),
+		done:   make(chan struct{}),
+	}
+	// One buffer more than Size for the chunk being processed.
+	bufs := make([][RequiredChunkSize]float32, cfg.Size+1)
+	for i := range bufs {
+		q.free[i] = &bufs[i]
+	}
+	q.notEmpty.L = &q.mu
+	q.notFull.L = &q.mu
+	return q
+}
+
+// push copies chunk into the queue, applying the overflow policy when full.
+func (q *inputQueue) push(chunk []float32, ts time.Time) error {
+	e := q.e
+	if len(chunk) != e.cfg.ChunkSize {
+		e.health.dropped()
+		e.log.Warn("audio dropped: wrong chunk size", "samples", len(chunk), "want", e.cfg.ChunkSize)
+		return ErrChunkSize
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.audio == q.size && !q.closed {
+		switch q.policy {
+		case OverflowDropNewest:
+			q.lost++
+			e.health.dropped()
+			q.notEmpty.Signal()
+			return nil
+		case OverflowDropOldest:
+			q.dropOldest()
+			e.health.dropped()
+		default:
+			for q.audio == q.size && !q.closed {
+				q.notFull.Wait()
+			}
+		}
+	}
+	if q.closed {
+		e.dropClosed(chunk)
+		return ErrClosed
+	}
+	buf := q.free[len(q.free)-1]
+	q.free = q.free[:len(q.free)-1]
+	copy(buf[:], chunk)
+	q.append(queueItem{op: opAudio, buf: buf, ts: ts})
+	q.audio++
+	return nil
+}
+
+// control queues a Start, Stop, or Reset.
+func (q *inputQueue) control(op queueOp) {
+	q.mu.Lock()
+	if !q.closed {
+		q.append(queueItem{op: op})
+	}
+	q.mu.Unlock()
+}
+
+// gap queues a PushGap. Like lifecycle calls it never blocks or gets
+// dropped, so the engine's timing stays whole.
+func (q *inputQueue) gap(d time.Duration) {
+	q.mu.Lock()
+	if !q.closed {
+		q.append(queueItem{op: opGap, gap: d})
+	}
+	q.mu.Unlock()
+}
+
+func (q *inputQueue) append(it queueItem) {
+	// Compact instead of letting the slice grow while the worker never
+	// catches up completely.
+	if q.head > 0 && q.head*2 >= len(q.items) {
+		n := copy(q.items, q.items[q.head:])
+		clear(q.items[n:])
+		q.items = q.items[:n]
+		q.head = 0
+	}
+	q.items = append(q.items, it)
+	q.notEmpty.Signal()
+}
+
+// dropOldest discards the oldest queued chunk. Called with q.mu held and at
+// least one chunk queued.
+func (q *inputQueue) dropOldest() {
+	for i := q.head; i < len(q.items); i++ {
+		if q.items[i].op == opAudio {
+			q.free = append(q.free, q.items[i].buf)
+			copy(q.items[i:], q.items[i+1:])
+			q.items[len(q.items)-1] = queueItem{}
+			q.items = q.items[:len(q.items)-1]
+			q.audio--
+			q.lost++
+			return
+		}
+	}
+}
+
+// close stops accepting items and wakes blocked pushers; with discard the
+// pending items are dropped too.
+func (q *inputQueue) close(discard bool) {
+	q.mu.Lock()
+	q.closed = true
+	if discard {
+		for _, it := range q.items[q.head:] {
+			if it.op == opAudio {
+				q.free = append(q.free, it.buf)
+			}
+		}
+		clear(q.items)
+		q.items, q.head, q.audio, q.lost = q.items[:0], 0, 0, 0
+	}
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+	q.mu.Unlock()
+}
+
+func (q *inputQueue) depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.audio
+}
+
+// next waits for an item or a drop notification. ok is false once the
+// queue is closed and drained.
+func (q *inputQueue) next() (it queueItem, lost int, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.head == len(q.items) && q.lost == 0 && !q.closed {
+		q.notEmpty.Wait()
+	}
+	lost, q.lost = q.lost, 0
+	if q.head == len(q.items) {
+		return queueItem{}, lost, lost > 0
+	}
+	it = q.items[q.head]
+	q.items[q.head] = queueItem{}
+	q.head++
+	if it.op == opAudio {
+		q.audio--
+	}
+	return it, lost, true
+}
+
+// recycle returns a processed chunk buffer.
+func (q *inputQueue) recycle(buf *[RequiredChunkSize]float32) {
+	q.mu.Lock()
+	q.free = append(q.free, buf)
+	q.notFull.Signal()
+	q.mu.Unlock()
+}
+
+// run is the engine goroutine: it applies queued items in order under
+// callMu, then releases the engine once Close has been called and the
+// queue is drained (or right away if Close came from a callback).
+func (q *inputQueue) run() {
+	e := q.e
+	defer close(q.done)
+	for !e.deferredClose.Load() {
+		it, lost, ok := q.next()
+		if !ok {
+			break
+		}
+		e.callMu.Lock()
+		if !e.deferredClose.Load() {
+			if lost > 0 {
+				e.audioDropped(lost)
+			}
+			switch it.op {
+			case opAudio:
+				if it.buf != nil {
+					_ = e.pushPCM(it.buf[:e.cfg.ChunkSize], it.ts)
+				}
+			case opStart:
+				e.start()
+			case opStop:
+				e.stop()
+			case opReset:
+				e.reset()
+			case opGap:
+				_ = e.pushGap(it.gap)
+			}
+		}
+		e.finish()
+		if it.buf != nil {
+			q.recycle(it.buf)
+		}
+	}
+	e.callMu.Lock()
+	if !e.closed {
+		e.release()
+	}
+	e.callMu.Unlock()
+}
+
+// audioDropped reports chunks lost to queue overflow.
+func (e *Engine) audioDropped(chunks int) {
+	e.log.Warn("audio dropped: input queue full", "chunks", chunks)
+	if e.cb.OnAudioDropped != nil {
+		e.cb.OnAudioDropped(chunks)
+	}
+}
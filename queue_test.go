@@ -0,0 +1,178 @@
+package smartturn_test
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+)
+
+// gateVAD records the marker (first sample) of every chunk it scores and
+// holds the first one until release is closed, so the queue fills up.
+type gateVAD struct {
+	entered, release chan struct{}
+
+	mu   sync.Mutex
+	seen []float32
+}
+
+func newGateVAD() *gateVAD {
+	return &gateVAD{entered: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (v *gateVAD) SpeechProb(chunk []float32) (float32, error) {
+	v.mu.Lock()
+	v.seen = append(v.seen, chunk[0])
+	first := len(v.seen) == 1
+	v.mu.Unlock()
+	if first {
+		close(v.entered)
+		<-v.release
+	}
+	return 0, nil
+}
+
+func (v *gateVAD) Reset()       {}
+func (v *gateVAD) Close() error { return nil }
+
+// processed returns the markers of the chunks scored, as chunk numbers.
+func (v *gateVAD) processed() []int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	var out []int
+	for _, m := range v.seen {
+		out = append(out, int(m*1000+0.5))
+	}
+	return out
+}
+
+// marked is silence whose first sample is i/1000.
+func marked(i int) []float32 {
+	c := make([]float32, smartturn.RequiredChunkSize)
+	c[0] = float32(i) / 1000
+	return c
+}
+
+// queuedEngine returns an engine with a 4-chunk input queue and policy
+// whose VAD holds chunk 0, after pushing it, and the chunk counts passed
+// to OnAudioDropped.
+func queuedEngine(t *testing.T, overflow smartturn.OverflowPolicy, closePolicy smartturn.ClosePolicy) (*smartturn.Engine, *gateVAD, *[]int) {
+	t.Helper()
+	vad := newGateVAD()
+	var mu sync.Mutex
+	drops := new([]int)
+	e := newTestEngine(t, nil, smartturn.Callbacks{
+		OnAudioDropped: func(n int) {
+			mu.Lock()
+			*drops = append(*drops, n)
+			mu.Unlock()
+		},
+	}, func(cfg *smartturn.Config) {
+		cfg.VADBackend = vad
+		cfg.InputQueue = smartturn.InputQueue{Size: 4, Overflow: overflow}
+		cfg.ClosePolicy = closePolicy
+	})
+	if err := e.PushPCM(marked(0)); err != nil {
+		t.Fatal(err)
+	}
+	<-vad.entered
+	return e, vad, drops
+}
+
+func TestInputQueueDrop(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		overflow  smartturn.OverflowPolicy
+		processed []int
+	}{
+		{"oldest", smartturn.OverflowDropOldest, []int{0, 7, 8, 9, 10}},
+		{"newest", smartturn.OverflowDropNewest, []int{0, 1, 2, 3, 4}},
+	} {
+		e, vad, drops := queuedEngine(t, tc.overflow, smartturn.CloseDrain)
+		for i := 1; i <= 10; i++ {
+			if err := e.PushPCM(marked(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if h := e.Health(); h.QueuedChunks != 4 || h.DroppedChunks != 6 {
+			t.Errorf("%s: %d chunks queued, %d dropped; want 4, 6", tc.name, h.QueuedChunks, h.DroppedChunks)
+		}
+		close(vad.release)
+		e.Close() // drains the queue
+		if got := vad.processed(); !slices.Equal(got, tc.processed) {
+			t.Errorf("%s: processed %v, want %v", tc.name, got, tc.processed)
+		}
+		// The losses while the engine was busy are reported at once,
+		// before the next chunk.
+		if !slices.Equal(*drops, []int{6}) {
+			t.Errorf("%s: OnAudioDropped %v, want [6]", tc.name, *drops)
+		}
+	}
+}
+
+func TestInputQueueBlock(t *testing.T) {
+	e, vad, drops := queuedEngine(t, smartturn.OverflowBlock, smartturn.CloseDrain)
+	for i := 1; i <= 4; i++ {
+		if err := e.PushPCM(marked(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pushed := make(chan error, 1)
+	go func() { pushed <- e.PushPCM(marked(5)) }()
+	select {
+	case err := <-pushed:
+		t.Fatalf("PushPCM into a full queue returned %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(vad.release)
+	if err := <-pushed; err != nil {
+		t.Fatal(err)
+	}
+	e.Close()
+	if got, want := vad.processed(), []int{0, 1, 2, 3, 4, 5}; !slices.Equal(got, want) {
+		t.Errorf("processed %v, want %v", got, want)
+	}
+	if h := e.Health(); len(*drops) != 0 || h.DroppedChunks != 0 {
+		t.Errorf("OnAudioDropped %v, %d dropped chunks", *drops, h.DroppedChunks)
+	}
+}
+
+// TestInputQueueCloseDiscard checks that CloseDiscard drops the queued
+// audio, and that a PushPCM blocked on the full queue returns ErrClosed.
+func TestInputQueueCloseDiscard(t *testing.T) {
+	e, vad, drops := queuedEngine(t, smartturn.OverflowBlock, smartturn.CloseDiscard)
+	for i := 1; i <= 4; i++ {
+		if err := e.PushPCM(marked(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pushed := make(chan error, 1)
+	go func() { pushed <- e.PushPCM(marked(5)) }()
+	closed := make(chan struct{})
+	go func() {
+		e.Close()
+		close(closed)
+	}()
+	if err := <-pushed; !errors.Is(err, smartturn.ErrClosed) {
+		t.Errorf("blocked PushPCM: %v, want ErrClosed", err)
+	}
+	select {
+	case <-closed:
+		t.Fatal("Close returned while chunk 0 was being processed")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(vad.release)
+	<-closed
+	if got := vad.processed(); !slices.Equal(got, []int{0}) {
+		t.Errorf("processed %v, want [0]", got)
+	}
+	if h := e.Health(); len(*drops) != 0 || h.QueuedChunks != 0 {
+		t.Errorf("OnAudioDropped %v, %d chunks queued", *drops, h.QueuedChunks)
+	}
+	if err := e.PushPCM(marked(6)); !errors.Is(err, smartturn.ErrClosed) {
+		t.Errorf("PushPCM after Close: %v", err)
+	}
+}
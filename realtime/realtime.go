@@ -0,0 +1,792 @@
+// Package realtime serves turn detection over WebSocket with the messages
+// of the OpenAI Realtime API, so clients written against its server_vad or
+// semantic_vad turn detection can point at a self-hosted smart-turn-go.
+//
+//	group, err := smartturn.NewSessionGroup(cfg) // SampleRate 16000, no InputQueue
+//	srv, err := realtime.NewServer(group)
+//	http.Handle("/v1/realtime", srv)
+//
+// Each connection runs an engine of the group. The server understands the
+// client events session.update, input_audio_buffer.append (base64 pcm16
+// at 24 kHz, mono), input_audio_buffer.commit and input_audio_buffer.clear,
+// and sends session.created, session.updated,
+// input_audio_buffer.speech_started, input_audio_buffer.speech_stopped,
+// input_audio_buffer.committed, input_audio_buffer.cleared and error.
+// There is no model behind it: conversation and response events are
+// answered with an error.
+//
+// Turn detection settings map onto the engine's: for server_vad,
+// threshold to VadThreshold, prefix_padding_ms to VadPreSpeechMs and
+// silence_duration_ms to VadStopMs; for semantic_vad, eagerness low,
+// medium (auto) and high to TurnTimeoutMs of 8, 4 and 2 seconds, with a
+// TurnThreshold of 0.7, the group's, and 0.3. Either way Smart-Turn
+// confirms the end of the turn, so speech_stopped comes when the engine's
+// turn ends, immediately followed by committed. A session.update that
+// changes them starts a new engine: a turn in progress is dropped. With
+// turn_detection null, audio is only committed by the client.
+//
+// With Server.ResumeWindow set, sessions survive a dropped connection: the
+// session object carries a resume_token, and a client reconnecting with it
+// (see ResumeToken) within the window gets session.resumed and continues
+// the same turn. Both are extensions of the OpenAI API.
+//
+// Connections are checked before the WebSocket upgrade: browser requests
+// must come from the server's own origin or one of Server.AllowedOrigins,
+// and with Server.Authenticate set, the hook maps each request, e.g. by
+// the API key APIKey extracts, to a Tenant with its own session limit, so
+// one server can serve several applications; Server.Observer can label
+// each tenant's metrics. Client events are bounded by Server.MaxMessage.
+package realtime
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/internal/websocket"
+)
+
+// InputSampleRate is the rate of the Realtime API's pcm16 audio.
+const InputSampleRate = 24000
+
+// DefaultMaxMessage is the size bound of a client event when
+// Server.MaxMessage is zero: an input_audio_buffer.append of about four
+// seconds of audio, where clients typically send 20 to 100 ms.
+const DefaultMaxMessage = 256 << 10
+
+// Server is an http.Handler serving Realtime sessions.
+type Server struct {
+	group *smartturn.SessionGroup
+	cfg   smartturn.Config
+	// Log receives connection errors; default slog.Default().
+	Log *slog.Logger
+	// ResumeWindow, when positive, keeps the session of a connection that
+	// dropped without a close handshake for this long. A client reconnecting
+	// with the session's resume_token (see ResumeToken) continues it:
+	// engine, turn in progress and buffered audio included. Only an event
+	// whose delivery failed as the connection dropped is lost.
+	ResumeWindow time.Duration
+	// MaxSessions caps the sessions, connected or waiting to be resumed;
+	// further connections are refused with 429. 0 means no limit.
+	MaxSessions int
+	// MaxChunkRate caps the audio a session may send, in engine chunks
+	// (32 ms) per second, with a burst of one second's worth: real time is
+	// 31.25. An append over the limit is dropped and answered with a
+	// rate_limit_exceeded error, so a client streaming faster than real
+	// time cannot starve inference for the others; an append of more than
+	// one second's worth is always dropped. 0 means no limit.
+	MaxChunkRate float64
+	// AllowedOrigins lists the browser origins, besides the server's own,
+	// that may connect (see websocket.CheckOrigin for the forms: "*",
+	// "https://app.example.com", "https://*.example.com"). Requests from
+	// other origins are refused with 403; requests without an Origin
+	// header, from non-browser clients, are not checked.
+	AllowedOrigins []string
+	// MaxMessage bounds the size of a client event; a bigger one closes
+	// the connection with status 1009. 0 means DefaultMaxMessage.
+	MaxMessage int
+	// Authenticate, when set, is called before a connection is upgraded
+	// and returns the tenant the request authenticates as, e.g. by looking
+	// up APIKey(r) or validating a JWT; an error refuses the connection
+	// with 401 and the error's message. A session can only be resumed by
+	// its tenant.
+	Authenticate func(r *http.Request) (Tenant, error)
+	// Observer, when set, returns the Observer of a tenant's engines in
+	// place of the group's Config.Observer, e.g. the Tenant method of a
+	// metrics.Collector with PerTenant set. Without Authenticate, the
+	// tenant is "".
+	Observer func(tenant string) smartturn.Observer
+
+	mu       sync.Mutex
+	admitted int            // sessions counted against MaxSessions
+	tenants  map[string]int // admitted sessions by tenant
+	sessions map[*session]struct{}
+	detached map[string]*session // by resume token
+	draining bool
+	closing  bool           // Shutdown's deadline passed
+	done     sync.WaitGroup // one per session
+}
+
+// NewServer returns a Server running engines of group, whose Config must
+// have SampleRate 16000 and no InputQueue: sessions feed their engines
+// with Process, which answers each append with its events.
+func NewServer(group *smartturn.SessionGroup) (*Server, error) {
+	cfg := group.Config()
+	if cfg.SampleRate != smartturn.RequiredSampleRate {
+		return nil, fmt.Errorf("realtime: group SampleRate must be %d, got %d", smartturn.RequiredSampleRate, cfg.SampleRate)
+	}
+	if cfg.InputQueue.Size > 0 {
+		return nil, errors.New("realtime: group Config must not set InputQueue")
+	}
+	return &Server{
+		group:    group,
+		cfg:      cfg,
+		tenants:  make(map[string]int),
+		sessions: make(map[*session]struct{}),
+		detached: make(map[string]*session),
+	}, nil
+}
+
+// Tenant is who a connection authenticated as; see Server.Authenticate.
+type Tenant struct {
+	// ID names the tenant in logs and, through Server.Observer, metrics.
+	ID string
+	// MaxSessions caps the tenant's sessions, connected or waiting to be
+	// resumed, on top of Server.MaxSessions; further connections of the
+	// tenant are refused with 429. 0 means no limit.
+	MaxSessions int
+}
+
+// APIKey returns the API key of a Realtime request: the bearer token of
+// its Authorization header, or for browsers, which cannot set headers on
+// a WebSocket, the key of its "openai-insecure-api-key." subprotocol.
+// It returns "" when the request has neither.
+func APIKey(r *http.Request) string {
+	if v, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(v)
+	}
+	for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(v, ",") {
+			if key, ok := strings.CutPrefix(strings.TrimSpace(p), "openai-insecure-api-key."); ok {
+				return key
+			}
+		}
+	}
+	return ""
+}
+
+// ResumeTokenHeader is the request header carrying a resume token.
+const ResumeTokenHeader = "Resume-Token"
+
+// ResumeToken returns the resume token of a Realtime request: its
+// Resume-Token header or for browsers, which cannot set headers on a
+// WebSocket, the token of its "resume-token." subprotocol. It returns ""
+// when the request has neither. The token is not taken from the URL, which
+// proxies and access logs record.
+func ResumeToken(r *http.Request) string {
+	if v := strings.TrimSpace(r.Header.Get(ResumeTokenHeader)); v != "" {
+		return v
+	}
+	for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(v, ",") {
+			if token, ok := strings.CutPrefix(strings.TrimSpace(p), "resume-token."); ok {
+				return token
+			}
+		}
+	}
+	return ""
+}
+
+// Sessions returns the number of connected sessions.
+func (s *Server) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// TenantSessions returns the number of sessions of each tenant, connected
+// or waiting to be resumed. Tenants without sessions are left out.
+func (s *Server) TenantSessions() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := make(map[string]int, len(s.tenants))
+	for t, c := range s.tenants {
+		n[t] = c
+	}
+	return n
+}
+
+// Draining reports whether Shutdown was called.
+func (s *Server) Draining() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.draining
+}
+
+// Shutdown drains the server: new connections are refused with 503 while
+// connected sessions run until their clients disconnect; sessions waiting
+// to be resumed end at once. When ctx is done first, the remaining
+// connections are closed with status 1001 (going away) and ctx's error is
+// returned once their sessions have ended.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.draining = true
+	detached := s.detached
+	s.detached = make(map[string]*session)
+	s.mu.Unlock()
+	for _, ss := range detached {
+		ss.expiry.Stop()
+		ss.close()
+	}
+	idle := make(chan struct{})
+	go func() {
+		s.done.Wait()
+		close(idle)
+	}()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	s.closing = true
+	for ss := range s.sessions {
+		_ = ss.conn.CloseWith(websocket.CloseGoingAway, "server shutting down")
+	}
+	s.mu.Unlock()
+	<-idle
+	return ctx.Err()
+}
+
+// ServeHTTP upgrades the request to a WebSocket and runs a session until
+// the client disconnects.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	draining := s.draining
+	if !draining {
+		s.done.Add(1)
+	}
+	s.mu.Unlock()
+	if draining {
+		httpError(w, http.StatusServiceUnavailable, "server_error", "server_shutting_down", "server shutting down")
+		return
+	}
+	defer s.done.Done()
+	if !websocket.CheckOrigin(r, s.AllowedOrigins) {
+		httpError(w, http.StatusForbidden, "invalid_request_error", "origin_not_allowed", "origin not allowed")
+		return
+	}
+	if r.URL.Query().Has("resume_token") {
+		httpError(w, http.StatusBadRequest, "invalid_request_error", "resume_token_in_url",
+			"send resume_token in the "+ResumeTokenHeader+" header, not the URL")
+		return
+	}
+	var tenant Tenant
+	if s.Authenticate != nil {
+		var err error
+		if tenant, err = s.Authenticate(r); err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			httpError(w, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", err.Error())
+			return
+		}
+	}
+	var ss *session
+	if token := ResumeToken(r); token != "" {
+		if ss = s.takeDetached(token, tenant.ID); ss == nil {
+			httpError(w, http.StatusNotFound, "invalid_request_error", "session_not_found", "unknown or expired resume_token")
+			return
+		}
+	} else if msg := s.admit(tenant); msg != "" {
+		w.Header().Set("Retry-After", "1")
+		httpError(w, http.StatusTooManyRequests, "rate_limit_error", "session_limit_reached", msg)
+		return
+	}
+	// Browsers pass the API key and beta flag as subprotocols, "realtime"
+	// first.
+	conn, err := websocket.Upgrade(w, r, "realtime")
+	if err != nil {
+		if ss != nil {
+			ss.close()
+		} else {
+			s.release(tenant.ID)
+		}
+		return
+	}
+	conn.MaxMessage = s.MaxMessage
+	if conn.MaxMessage <= 0 {
+		conn.MaxMessage = DefaultMaxMessage
+	}
+	log := s.Log
+	if log == nil {
+		log = slog.Default()
+	}
+	resumed := ss != nil
+	if resumed {
+		ss.conn = conn
+	} else {
+		ss = &session{srv: s, conn: conn, id: "sess_" + randomID(), tenant: tenant.ID, rs: newResampler()}
+		if s.ResumeWindow > 0 {
+			ss.token = randomID()
+		}
+	}
+	s.mu.Lock()
+	s.sessions[ss] = struct{}{}
+	if s.closing {
+		_ = conn.CloseWith(websocket.CloseGoingAway, "server shutting down")
+	}
+	s.mu.Unlock()
+	err = ss.run(resumed)
+	s.mu.Lock()
+	delete(s.sessions, ss)
+	s.mu.Unlock()
+	if ss.token != "" && dropped(err) && s.detach(ss) {
+		log.Info("realtime session detached", "session", ss.id, "tenant", ss.tenant, "remote", conn.RemoteAddr(), "err", err)
+		return
+	}
+	ss.close()
+	// Clients vanishing and Shutdown closing the connection are routine.
+	if err != nil && !errors.Is(err, websocket.ErrClosed) &&
+		!errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+		log.Warn("realtime session ended", "session", ss.id, "tenant", ss.tenant, "remote", conn.RemoteAddr(), "err", err)
+	}
+}
+
+// admit counts a new session of t against MaxSessions and t.MaxSessions,
+// or returns why it is refused.
+func (s *Server) admit(t Tenant) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.MaxSessions > 0 && s.admitted >= s.MaxSessions {
+		return fmt.Sprintf("the server is at its limit of %d sessions", s.MaxSessions)
+	}
+	if t.MaxSessions > 0 && s.tenants[t.ID] >= t.MaxSessions {
+		return fmt.Sprintf("the tenant is at its limit of %d sessions", t.MaxSessions)
+	}
+	s.admitted++
+	s.tenants[t.ID]++
+	return ""
+}
+
+// release ends a session of tenant admitted by admit.
+func (s *Server) release(tenant string) {
+	s.mu.Lock()
+	s.admitted--
+	if s.tenants[tenant]--; s.tenants[tenant] <= 0 {
+		delete(s.tenants, tenant)
+	}
+	s.mu.Unlock()
+}
+
+// httpError refuses a connection with an error body shaped like the error
+// event's.
+func httpError(w http.ResponseWriter, status int, typ, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{
+		"type": typ, "code": code, "message": message,
+	}})
+}
+
+// dropped reports whether err means the connection was lost, rather than
+// closed by either side or failed on a protocol error.
+func dropped(err error) bool {
+	var ne *net.OpError
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		(errors.As(err, &ne) && !errors.Is(err, net.ErrClosed))
+}
+
+// detach keeps ss for ResumeWindow, unless the server is draining.
+func (s *Server) detach(ss *session) bool {
+	ss.conn.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		return false
+	}
+	ss.expiry = time.AfterFunc(s.ResumeWindow, func() {
+		if s.takeDetached(ss.token, ss.tenant) != nil {
+			ss.close()
+		}
+	})
+	s.detached[ss.token] = ss
+	return true
+}
+
+// takeDetached removes and returns the session of tenant waiting to be
+// resumed with token, or nil.
+func (s *Server) takeDetached(token, tenant string) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ss := s.detached[token]
+	if ss == nil || ss.tenant != tenant {
+		return nil
+	}
+	delete(s.detached, token)
+	ss.expiry.Stop()
+	return ss
+}
+
+// TurnDetection is the session's turn_detection object.
+type TurnDetection struct {
+	Type              string   `json:"type"` // "server_vad" or "semantic_vad"
+	Threshold         *float32 `json:"threshold,omitempty"`
+	PrefixPaddingMs   *int     `json:"prefix_padding_ms,omitempty"`
+	SilenceDurationMs *int     `json:"silence_duration_ms,omitempty"`
+	Eagerness         string   `json:"eagerness,omitempty"`
+	CreateResponse    *bool    `json:"create_response,omitempty"`
+	InterruptResponse *bool    `json:"interrupt_response,omitempty"`
+}
+
+// sessionObject is the session of session.created and session.updated.
+type sessionObject struct {
+	ID                 string         `json:"id"`
+	Object             string         `json:"object"`
+	InputAudioFormat   string         `json:"input_audio_format"`
+	TurnDetection      *TurnDetection `json:"turn_detection"`
+	Modalities         []string       `json:"modalities"`
+	InputTranscription any            `json:"input_audio_transcription"`
+	// ResumeToken resumes the session after a dropped connection; see
+	// Server.ResumeWindow. Not part of the OpenAI API.
+	ResumeToken string `json:"resume_token,omitempty"`
+}
+
+// clientEvent is any client event; only the fields of its Type are set.
+type clientEvent struct {
+	Type    string `json:"type"`
+	EventID string `json:"event_id"`
+	Audio   string `json:"audio"`
+	Session *struct {
+		InputAudioFormat *string         `json:"input_audio_format"`
+		TurnDetection    json.RawMessage `json:"turn_detection"`
+	} `json:"session"`
+}
+
+// session is one connection.
+type session struct {
+	srv    *Server
+	conn   *websocket.Conn
+	id     string
+	tenant string // Tenant.ID
+
+	token  string      // resume token; "" when resuming is off
+	expiry *time.Timer // ends the session while detached
+
+	detection *TurnDetection // nil: turn detection off
+	engine    *smartturn.Engine
+	rs        *resampler
+	pending   []float32 // 16 kHz audio short of a chunk
+	samples   []float32 // scratch for decoded audio
+	base      int64     // samples fed before the current engine
+	fed       int64     // 16 kHz samples fed in total
+	buffered  int64     // samples appended since the last commit
+
+	item, lastItem string // current and last committed item IDs
+	nextEvent      int
+
+	tokens float64   // chunks the session may still send under MaxChunkRate
+	refill time.Time // when tokens were last refilled
+}
+
+// run serves the connection; a resumed session continues where it was
+// and announces itself with session.resumed instead of session.created.
+func (ss *session) run(resumed bool) error {
+	if resumed {
+		if err := ss.send("session.resumed", map[string]any{"session": ss.object()}); err != nil {
+			return err
+		}
+	} else {
+		ss.detection = &TurnDetection{Type: "server_vad"}
+		if err := ss.startEngine(); err != nil {
+			return err
+		}
+		if err := ss.send("session.created", map[string]any{"session": ss.object()}); err != nil {
+			return err
+		}
+	}
+	for {
+		_, msg, err := ss.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		var ev clientEvent
+		if err := json.Unmarshal(msg, &ev); err != nil {
+			if err := ss.fail("", "invalid_json", "invalid JSON: "+err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := ss.handle(&ev); err != nil {
+			return err
+		}
+	}
+}
+
+func (ss *session) handle(ev *clientEvent) error {
+	switch ev.Type {
+	case "session.update":
+		return ss.update(ev)
+	case "input_audio_buffer.append":
+		audio, err := base64.StdEncoding.DecodeString(ev.Audio)
+		if err != nil || len(audio)%2 != 0 {
+			return ss.fail(ev.EventID, "invalid_audio", "audio must be base64-encoded pcm16")
+		}
+		if !ss.allow(len(audio) / 2) {
+			return ss.send("error", map[string]any{"error": map[string]any{
+				"type": "rate_limit_error", "code": "rate_limit_exceeded", "param": nil, "event_id": eventIDOrNil(ev.EventID),
+				"message": fmt.Sprintf("audio exceeds %g chunks per second; the append was dropped", ss.srv.MaxChunkRate),
+			}})
+		}
+		return ss.append(audio)
+	case "input_audio_buffer.commit":
+		if ss.buffered == 0 {
+			return ss.fail(ev.EventID, "input_audio_buffer_commit_empty", "the input audio buffer is empty")
+		}
+		if ss.item == "" {
+			ss.item = ss.newItem()
+		}
+		return ss.commit()
+	case "input_audio_buffer.clear":
+		if ss.engine != nil {
+			ss.engine.Reset()
+		}
+		ss.item, ss.buffered = "", 0
+		return ss.send("input_audio_buffer.cleared", nil)
+	}
+	return ss.fail(ev.EventID, "unsupported_event", "this server only does turn detection; unsupported event "+ev.Type)
+}
+
+// update applies a session.update.
+func (ss *session) update(ev *clientEvent) error {
+	if ev.Session == nil {
+		return ss.fail(ev.EventID, "missing_session", "session.update needs a session")
+	}
+	if f := ev.Session.InputAudioFormat; f != nil && *f != "pcm16" {
+		return ss.fail(ev.EventID, "unsupported_audio_format", "only pcm16 input audio is supported")
+	}
+	if raw := ev.Session.TurnDetection; raw != nil {
+		var td *TurnDetection
+		if err := json.Unmarshal(raw, &td); err != nil {
+			return ss.fail(ev.EventID, "invalid_turn_detection", err.Error())
+		}
+		if td != nil && td.Type != "server_vad" && td.Type != "semantic_vad" {
+			return ss.fail(ev.EventID, "invalid_turn_detection", "turn_detection.type must be server_vad or semantic_vad")
+		}
+		old := ss.detection
+		ss.detection = td
+		if err := ss.startEngine(); err != nil {
+			ss.detection = old
+			return ss.fail(ev.EventID, "invalid_turn_detection", err.Error())
+		}
+	}
+	return ss.send("session.updated", map[string]any{"session": ss.object()})
+}
+
+// startEngine replaces the engine with one for the current turn detection
+// settings, or none when it is off.
+func (ss *session) startEngine() error {
+	var e *smartturn.Engine
+	if td := ss.detection; td != nil {
+		o := ss.srv.group.Defaults()
+		switch td.Type {
+		case "server_vad":
+			if td.Threshold != nil {
+				o.VadThreshold = *td.Threshold
+			}
+			if td.PrefixPaddingMs != nil {
+				o.VadPreSpeechMs = *td.PrefixPaddingMs
+			}
+			if td.SilenceDurationMs != nil {
+				o.VadStopMs = *td.SilenceDurationMs
+			}
+		case "semantic_vad":
+			switch td.Eagerness {
+			case "low":
+				o.TurnThreshold, o.TurnTimeoutMs = 0.7, 8000
+			case "high":
+				o.TurnThreshold, o.TurnTimeoutMs = 0.3, 2000
+			case "", "auto", "medium":
+				o.TurnTimeoutMs = 4000
+			default:
+				return fmt.Errorf("invalid eagerness %q", td.Eagerness)
+			}
+		}
+		obs := ss.srv.cfg.Observer
+		if ss.srv.Observer != nil {
+			obs = ss.srv.Observer(ss.tenant)
+		}
+		var err error
+		if e, err = ss.srv.group.NewSessionObserved(o, smartturn.Callbacks{}, obs); err != nil {
+			return err
+		}
+		e.Start()
+	}
+	if ss.engine != nil {
+		ss.engine.Close()
+	}
+	ss.engine, ss.base, ss.item = e, ss.fed, ""
+	ss.pending = ss.pending[:0]
+	return nil
+}
+
+// allow takes the chunks that n input samples make from the session's
+// MaxChunkRate budget, or reports that they exceed it.
+func (ss *session) allow(n int) bool {
+	rate := ss.srv.MaxChunkRate
+	if rate <= 0 {
+		return true
+	}
+	now := time.Now()
+	if ss.refill.IsZero() {
+		ss.tokens = rate
+	} else {
+		ss.tokens = min(ss.tokens+rate*now.Sub(ss.refill).Seconds(), rate)
+	}
+	ss.refill = now
+	chunks := float64(n) * smartturn.RequiredSampleRate / InputSampleRate / float64(ss.srv.cfg.ChunkSize)
+	if chunks > ss.tokens {
+		return false
+	}
+	ss.tokens -= chunks
+	return true
+}
+
+// append feeds pcm16 audio to the engine, chunk by chunk.
+func (ss *session) append(audio []byte) error {
+	in := ss.samples[:0]
+	for i := 0; i < len(audio); i += 2 {
+		in = append(in, float32(int16(binary.LittleEndian.Uint16(audio[i:])))/32768)
+	}
+	ss.samples = in
+	n := len(ss.pending)
+	ss.pending = ss.rs.process(in, ss.pending)
+	ss.buffered += int64(len(ss.pending) - n)
+	size := ss.srv.cfg.ChunkSize
+	off := 0
+	for ; off+size <= len(ss.pending); off += size {
+		if err := ss.process(ss.pending[off : off+size]); err != nil {
+			return err
+		}
+	}
+	ss.pending = ss.pending[:copy(ss.pending, ss.pending[off:])]
+	return nil
+}
+
+// process runs one chunk and translates its events.
+func (ss *session) process(chunk []float32) error {
+	start := ss.fed
+	ss.fed += int64(len(chunk))
+	if ss.engine == nil {
+		return nil
+	}
+	events, err := ss.engine.Process(chunk)
+	if err != nil {
+		return err
+	}
+	for _, ev := range events {
+		switch ev.Kind {
+		case smartturn.EventTurnStart:
+			err = ss.speechStarted(ss.base + ev.TurnStart.Offset)
+		case smartturn.EventTurnMerged:
+			// A committed turn cannot be reopened: continue in a new item.
+			err = ss.speechStarted(start)
+		case smartturn.EventSpeechEnd:
+			if ss.item == "" {
+				continue
+			}
+			err = ss.send("input_audio_buffer.speech_stopped", map[string]any{
+				"audio_end_ms": ms(ss.fed),
+				"item_id":      ss.item,
+			})
+			if err == nil {
+				err = ss.commit()
+			}
+		case smartturn.EventError:
+			err = ss.send("error", map[string]any{"error": map[string]any{
+				"type": "server_error", "code": nil, "message": ev.Err.Error(), "param": nil, "event_id": nil,
+			}})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ss *session) speechStarted(offset int64) error {
+	ss.item = ss.newItem()
+	return ss.send("input_audio_buffer.speech_started", map[string]any{
+		"audio_start_ms": ms(offset),
+		"item_id":        ss.item,
+	})
+}
+
+// commit reports the current item as committed.
+func (ss *session) commit() error {
+	var prev any
+	if ss.lastItem != "" {
+		prev = ss.lastItem
+	}
+	err := ss.send("input_audio_buffer.committed", map[string]any{
+		"previous_item_id": prev,
+		"item_id":          ss.item,
+	})
+	ss.lastItem, ss.item, ss.buffered = ss.item, "", 0
+	return err
+}
+
+func (ss *session) object() sessionObject {
+	return sessionObject{
+		ID:               ss.id,
+		Object:           "realtime.session",
+		InputAudioFormat: "pcm16",
+		TurnDetection:    ss.detection,
+		Modalities:       []string{"text"},
+		ResumeToken:      ss.token,
+	}
+}
+
+// send writes a server event of type typ with fields.
+func (ss *session) send(typ string, fields map[string]any) error {
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	ss.nextEvent++
+	fields["type"] = typ
+	fields["event_id"] = fmt.Sprintf("event_%s_%d", ss.id[len("sess_"):], ss.nextEvent)
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return ss.conn.WriteMessage(websocket.Text, b)
+}
+
+// fail sends an invalid_request_error about the client event eventID.
+func (ss *session) fail(eventID, code, message string) error {
+	return ss.send("error", map[string]any{"error": map[string]any{
+		"type": "invalid_request_error", "code": code, "message": message, "param": nil, "event_id": eventIDOrNil(eventID),
+	}})
+}
+
+// eventIDOrNil returns a client event ID for JSON, null when empty.
+func eventIDOrNil(id string) any {
+	if id == "" {
+		return nil
+	}
+	return id
+}
+
+func (ss *session) newItem() string {
+	return "item_" + randomID()
+}
+
+func (ss *session) close() {
+	if ss.engine != nil {
+		ss.engine.Close()
+	}
+	ss.conn.Close()
+	ss.srv.release(ss.tenant)
+}
+
+// ms converts 16 kHz samples to milliseconds.
+func ms(samples int64) int64 {
+	return samples * 1000 / smartturn.RequiredSampleRate
+}
+
+func randomID() string {
+	var b [10]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,899 @@
+package realtime_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/realtime"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// newTestServer returns a Server on a group with energy VAD and a
+// scripted Smart-Turn backend, and the HTTP server it is mounted on.
+// tweak, when set, configures the Server before it serves.
+func newTestServer(t *testing.T, tweak func(*realtime.Server)) (*realtime.Server, *httptest.Server) {
+	t.Helper()
+	group, err := smartturn.NewSessionGroup(smartturn.Config{
+		SampleRate:             smartturn.RequiredSampleRate,
+		ChunkSize:              smartturn.RequiredChunkSize,
+		VadThreshold:           0.5,
+		VadPreSpeechMs:         200,
+		VadStopMs:              300,
+		TurnMaxDurationSeconds: 600,
+		TurnSegmentEmitMs:      1000,
+		TurnThreshold:          0.5,
+		TurnTimeoutMs:          1000,
+		VADBackend:             &smartturntest.EnergyVAD{},
+		TurnBackend:            &smartturntest.TurnScript{Probabilities: []float32{0.9}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = group.Close() })
+	srv, err := realtime.NewServer(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tweak != nil {
+		tweak(srv)
+	}
+	hs := httptest.NewServer(srv)
+	t.Cleanup(hs.Close)
+	return srv, hs
+}
+
+// client is the test side of a WebSocket connection.
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dial sends a WebSocket handshake with header to hs and returns the
+// response, and on 101 the connection, closed with the test.
+func dial(t *testing.T, hs *httptest.Server, target string, header http.Header) (*http.Response, *client) {
+	t.Helper()
+	conn, err := net.Dial("tcp", hs.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, hs.URL+target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	c := &client{conn: conn, r: bufio.NewReader(conn)}
+	resp, err := http.ReadResponse(c.r, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return resp, nil
+	}
+	t.Cleanup(func() { c.conn.Close() })
+	return resp, c
+}
+
+// write sends one masked frame.
+func (c *client) write(t *testing.T, fin bool, opcode byte, payload []byte) {
+	t.Helper()
+	head := []byte{opcode, 0x80}
+	if fin {
+		head[0] |= 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		head[1] |= byte(n)
+	case n <= 0xffff:
+		head[1] |= 126
+		head = binary.BigEndian.AppendUint16(head, uint16(n))
+	default:
+		head[1] |= 127
+		head = binary.BigEndian.AppendUint64(head, uint64(n))
+	}
+	var mask [4]byte
+	_, _ = rand.Read(mask[:])
+	masked := make([]byte, len(payload))
+	for i, b := range payload {
+		masked[i] = b ^ mask[i%4]
+	}
+	if _, err := c.conn.Write(append(append(head, mask[:]...), masked...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// send writes a client event.
+func (c *client) send(t *testing.T, ev map[string]any) {
+	t.Helper()
+	b, err := json.Marshal(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.write(t, true, 1, b)
+}
+
+// frame reads one server frame, which must be unmasked and final.
+func (c *client) frame(t *testing.T) (opcode byte, payload []byte) {
+	t.Helper()
+	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	if head[0]&0x80 == 0 || head[1]&0x80 != 0 {
+		t.Fatalf("server frame header %08b %08b: want FIN set and no mask", head[0], head[1])
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			t.Fatal(err)
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			t.Fatal(err)
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return head[0] & 0x0f, payload
+}
+
+// event reads the next server event.
+func (c *client) event(t *testing.T) map[string]any {
+	t.Helper()
+	op, payload := c.frame(t)
+	if op != 1 {
+		t.Fatalf("server sent opcode %d, want a text event", op)
+	}
+	var ev map[string]any
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		t.Fatal(err)
+	}
+	return ev
+}
+
+// expect reads the next server event and checks its type.
+func (c *client) expect(t *testing.T, typ string) map[string]any {
+	t.Helper()
+	ev := c.event(t)
+	if ev["type"] != typ {
+		t.Fatalf("got %v, want %s", ev, typ)
+	}
+	return ev
+}
+
+// errorCode returns the error code of a refused handshake.
+func errorCode(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	var body struct {
+		Error struct{ Code string } `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return body.Error.Code
+}
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// keyAuth authenticates the API keys of tenants.
+func keyAuth(tenants map[string]realtime.Tenant) func(*http.Request) (realtime.Tenant, error) {
+	return func(r *http.Request) (realtime.Tenant, error) {
+		t, ok := tenants[realtime.APIKey(r)]
+		if !ok {
+			return realtime.Tenant{}, errors.New("invalid API key")
+		}
+		return t, nil
+	}
+}
+
+func bearer(key string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + key}}
+}
+
+// resumeHeader returns the header resuming the session of token.
+func resumeHeader(token string) http.Header {
+	return http.Header{realtime.ResumeTokenHeader: {token}}
+}
+
+func TestAPIKey(t *testing.T) {
+	for _, tc := range []struct {
+		header http.Header
+		want   string
+	}{
+		{http.Header{}, ""},
+		{bearer("sk-1"), "sk-1"},
+		{http.Header{"Authorization": {"Basic dTpw"}}, ""},
+		{http.Header{"Sec-Websocket-Protocol": {"realtime, openai-insecure-api-key.sk-2, openai-beta.realtime-v1"}}, "sk-2"},
+		{http.Header{"Sec-Websocket-Protocol": {"realtime", "openai-insecure-api-key.sk-3"}}, "sk-3"},
+	} {
+		r := &http.Request{Header: tc.header}
+		if got := realtime.APIKey(r); got != tc.want {
+			t.Errorf("APIKey(%v) = %q, want %q", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestResumeToken(t *testing.T) {
+	for _, tc := range []struct {
+		header http.Header
+		want   string
+	}{
+		{http.Header{}, ""},
+		{resumeHeader("tok1"), "tok1"},
+		{http.Header{"Sec-Websocket-Protocol": {"realtime, resume-token.tok2, openai-beta.realtime-v1"}}, "tok2"},
+		{http.Header{"Sec-Websocket-Protocol": {"realtime", "resume-token.tok3"}}, "tok3"},
+		{http.Header{"Resume-Token": {"tok4"}, "Sec-Websocket-Protocol": {"resume-token.tok5"}}, "tok4"},
+	} {
+		r := &http.Request{Header: tc.header}
+		if got := realtime.ResumeToken(r); got != tc.want {
+			t.Errorf("ResumeToken(%v) = %q, want %q", tc.header, got, tc.want)
+		}
+	}
+}
+
+// TestAuthenticate checks that Authenticate refuses requests before the
+// upgrade and that each tenant's sessions get its Observer.
+func TestAuthenticate(t *testing.T) {
+	var mu sync.Mutex
+	observed := map[string]int{}
+	srv, hs := newTestServer(t, func(s *realtime.Server) {
+		s.Authenticate = keyAuth(map[string]realtime.Tenant{"sk-acme": {ID: "acme"}})
+		s.Observer = func(tenant string) smartturn.Observer {
+			mu.Lock()
+			observed[tenant]++
+			mu.Unlock()
+			return nil
+		}
+	})
+
+	for _, h := range []http.Header{{}, bearer("sk-wrong")} {
+		resp, _ := dial(t, hs, "/", h)
+		if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+			t.Fatalf("%v: status %d, WWW-Authenticate %q; want 401 with a challenge", h, resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+		}
+		if code := errorCode(t, resp); code != "invalid_api_key" {
+			t.Errorf("error code %q, want invalid_api_key", code)
+		}
+	}
+	if n := srv.Sessions(); n != 0 {
+		t.Errorf("%d sessions after refused connections", n)
+	}
+
+	resp, c := dial(t, hs, "/", bearer("sk-acme"))
+	if c == nil {
+		t.Fatalf("bearer key: status %d, want 101", resp.StatusCode)
+	}
+	c.expect(t, "session.created")
+	resp, c = dial(t, hs, "/", http.Header{"Sec-Websocket-Protocol": {"realtime, openai-insecure-api-key.sk-acme"}})
+	if c == nil {
+		t.Fatalf("subprotocol key: status %d, want 101", resp.StatusCode)
+	}
+	if p := resp.Header.Get("Sec-WebSocket-Protocol"); p != "realtime" {
+		t.Errorf("selected subprotocol %q, want realtime", p)
+	}
+	c.expect(t, "session.created")
+	if got := srv.TenantSessions(); got["acme"] != 2 || len(got) != 1 {
+		t.Errorf("TenantSessions = %v, want acme: 2", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if observed["acme"] != 2 || len(observed) != 1 {
+		t.Errorf("Observer calls by tenant %v, want acme: 2", observed)
+	}
+}
+
+// TestTenantMaxSessions checks that a tenant at its limit is refused while
+// others are not, and is admitted again once a session ends.
+func TestTenantMaxSessions(t *testing.T) {
+	srv, hs := newTestServer(t, func(s *realtime.Server) {
+		s.Authenticate = keyAuth(map[string]realtime.Tenant{
+			"sk-acme":  {ID: "acme", MaxSessions: 1},
+			"sk-other": {ID: "other"},
+		})
+		s.MaxSessions = 3
+	})
+	_, first := dial(t, hs, "/", bearer("sk-acme"))
+	if first == nil {
+		t.Fatal("first acme session refused")
+	}
+	first.expect(t, "session.created")
+	resp, c := dial(t, hs, "/", bearer("sk-acme"))
+	if c != nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("second acme session: status %d, want 429", resp.StatusCode)
+	}
+	if code := errorCode(t, resp); code != "session_limit_reached" {
+		t.Errorf("error code %q, want session_limit_reached", code)
+	}
+	for range 2 {
+		if _, c := dial(t, hs, "/", bearer("sk-other")); c == nil {
+			t.Fatal("other tenant refused below the server limit")
+		}
+	}
+	// The server limit applies across tenants.
+	if resp, c := dial(t, hs, "/", bearer("sk-other")); c != nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("session over MaxSessions: status %d, want 429", resp.StatusCode)
+	}
+
+	first.write(t, true, 8, []byte{0x03, 0xe8}) // close, 1000
+	waitFor(t, "the acme session to end", func() bool { return srv.TenantSessions()["acme"] == 0 })
+	if resp, c := dial(t, hs, "/", bearer("sk-acme")); c == nil {
+		t.Fatalf("acme after its session ended: status %d, want 101", resp.StatusCode)
+	}
+}
+
+// TestResumeTenant checks that only the tenant of a detached session can
+// resume it.
+func TestResumeTenant(t *testing.T) {
+	srv, hs := newTestServer(t, func(s *realtime.Server) {
+		s.Authenticate = keyAuth(map[string]realtime.Tenant{
+			"sk-acme":  {ID: "acme"},
+			"sk-other": {ID: "other"},
+		})
+		s.ResumeWindow = time.Minute
+	})
+	_, c := dial(t, hs, "/", bearer("sk-acme"))
+	created := c.expect(t, "session.created")
+	token, _ := created["session"].(map[string]any)["resume_token"].(string)
+	if token == "" {
+		t.Fatalf("session.created without resume_token: %v", created)
+	}
+	c.conn.Close() // dropped without a close handshake
+	waitFor(t, "the session to detach", func() bool { return srv.Sessions() == 0 })
+
+	other, acme := bearer("sk-other"), bearer("sk-acme")
+	other.Set(realtime.ResumeTokenHeader, token)
+	acme.Set(realtime.ResumeTokenHeader, token)
+	resp, c := dial(t, hs, "/", other)
+	if c != nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("resume by another tenant: status %d, want 404", resp.StatusCode)
+	}
+	resp, c = dial(t, hs, "/", acme)
+	if c == nil {
+		t.Fatalf("resume by its tenant: status %d, want 101", resp.StatusCode)
+	}
+	c.expect(t, "session.resumed")
+}
+
+// TestAllowedOrigins checks that browser requests from origins other
+// than the server's own and AllowedOrigins are refused before the upgrade.
+func TestAllowedOrigins(t *testing.T) {
+	srv, hs := newTestServer(t, func(s *realtime.Server) {
+		s.AllowedOrigins = []string{"https://app.example.com"}
+	})
+	for _, tc := range []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{"http://" + hs.Listener.Addr().String(), true},
+		{"https://app.example.com", true},
+		{"https://evil.example.com", false},
+	} {
+		h := http.Header{}
+		if tc.origin != "" {
+			h.Set("Origin", tc.origin)
+		}
+		resp, c := dial(t, hs, "/", h)
+		if !tc.ok {
+			if c != nil || resp.StatusCode != http.StatusForbidden {
+				t.Fatalf("origin %q: status %d, want 403", tc.origin, resp.StatusCode)
+			}
+			if code := errorCode(t, resp); code != "origin_not_allowed" {
+				t.Errorf("error code %q, want origin_not_allowed", code)
+			}
+			continue
+		}
+		if c == nil {
+			t.Fatalf("origin %q: status %d, want 101", tc.origin, resp.StatusCode)
+		}
+		c.expect(t, "session.created")
+	}
+	if n := srv.Sessions(); n != 3 {
+		t.Errorf("%d sessions, want 3", n)
+	}
+}
+
+// TestMaxMessage checks that a client event over MaxMessage closes the
+// connection with status 1009 and ends the session.
+func TestMaxMessage(t *testing.T) {
+	srv, hs := newTestServer(t, func(s *realtime.Server) { s.MaxMessage = 1024 })
+	_, c := dial(t, hs, "/", nil)
+	if c == nil {
+		t.Fatal("handshake refused")
+	}
+	c.expect(t, "session.created")
+	c.send(t, map[string]any{"type": "input_audio_buffer.append", "audio": make([]byte, 600)}) // 800 base64 bytes
+	c.send(t, map[string]any{"type": "input_audio_buffer.append", "audio": make([]byte, 1024)})
+	op, payload := c.frame(t)
+	if op != 8 || len(payload) < 2 || binary.BigEndian.Uint16(payload) != 1009 {
+		t.Fatalf("got opcode %d %q, want a close frame with status 1009", op, payload)
+	}
+	waitFor(t, "the session to end", func() bool { return srv.Sessions() == 0 })
+}
+
+// TestShutdown checks that a draining server refuses new connections with
+// 503 while serving the connected ones, and closes them with 1001 once the
+// drain times out.
+func TestShutdown(t *testing.T) {
+	srv, hs := newTestServer(t, nil)
+	_, c := dial(t, hs, "/", nil)
+	c.expect(t, "session.created")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Shutdown(ctx) }()
+	waitFor(t, "the drain", srv.Draining)
+	resp, c2 := dial(t, hs, "/", nil)
+	if c2 != nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("connection while draining: status %d, want 503", resp.StatusCode)
+	}
+	if code := errorCode(t, resp); code != "server_shutting_down" {
+		t.Errorf("error code %q, want server_shutting_down", code)
+	}
+
+	// The connected session is still served.
+	c.send(t, map[string]any{"type": "input_audio_buffer.commit"})
+	c.expect(t, "error")
+	if err := <-errc; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v, want the deadline", err)
+	}
+	if n := srv.Sessions(); n != 0 {
+		t.Errorf("%d sessions after Shutdown", n)
+	}
+	op, payload := c.frame(t)
+	if op != 8 || len(payload) < 2 || binary.BigEndian.Uint16(payload) != 1001 {
+		t.Fatalf("got opcode %d %q, want a close frame with status 1001", op, payload)
+	}
+}
+
+// TestShutdownIdle checks that Shutdown returns once the last client
+// disconnects.
+func TestShutdownIdle(t *testing.T) {
+	idle, _ := newTestServer(t, nil)
+	if err := idle.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown without sessions: %v", err)
+	}
+
+	srv, hs := newTestServer(t, nil)
+	_, c := dial(t, hs, "/", nil)
+	c.expect(t, "session.created")
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Shutdown(context.Background()) }()
+	waitFor(t, "the drain", srv.Draining)
+	select {
+	case err := <-errc:
+		t.Fatalf("Shutdown returned %v with a session connected", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	c.write(t, true, 8, []byte{0x03, 0xe8}) // close, 1000
+	if err := <-errc; err != nil {
+		t.Errorf("Shutdown = %v after the client disconnected", err)
+	}
+}
+
+// appendAudio sends d of 24 kHz pcm16 in 100 ms appends: a 300 Hz tone
+// when speech is set, else silence.
+func (c *client) appendAudio(t *testing.T, d time.Duration, speech bool) {
+	t.Helper()
+	n := int(d.Seconds() * realtime.InputSampleRate)
+	for off := 0; off < n; off += realtime.InputSampleRate / 10 {
+		var b []byte
+		for i := off; i < min(n, off+realtime.InputSampleRate/10); i++ {
+			var v int16
+			if speech {
+				v = int16(10000 * math.Sin(2*math.Pi*300*float64(i)/realtime.InputSampleRate))
+			}
+			b = binary.LittleEndian.AppendUint16(b, uint16(v))
+		}
+		c.send(t, map[string]any{"type": "input_audio_buffer.append", "audio": b})
+	}
+}
+
+// drop closes the connection without a close handshake and waits for the
+// server to notice.
+func (c *client) drop(t *testing.T, srv *realtime.Server) {
+	t.Helper()
+	c.conn.Close()
+	waitFor(t, "the session to detach", func() bool { return srv.Sessions() == 0 })
+}
+
+// TestResume checks that a session resumed after a dropped connection
+// continues the turn in progress, and that its token only works once.
+func TestResume(t *testing.T) {
+	srv, hs := newTestServer(t, func(s *realtime.Server) { s.ResumeWindow = time.Minute })
+	_, c := dial(t, hs, "/", nil)
+	session := c.expect(t, "session.created")["session"].(map[string]any)
+	token, _ := session["resume_token"].(string)
+	c.appendAudio(t, 500*time.Millisecond, true)
+	item := c.expect(t, "input_audio_buffer.speech_started")["item_id"]
+	c.drop(t, srv)
+	if got := srv.TenantSessions(); got[""] != 1 {
+		t.Errorf("TenantSessions = %v while detached, want the session counted", got)
+	}
+
+	_, c = dial(t, hs, "/", resumeHeader(token))
+	if c == nil {
+		t.Fatal("resume refused")
+	}
+	resumed := c.expect(t, "session.resumed")["session"].(map[string]any)
+	if resumed["id"] != session["id"] || resumed["resume_token"] != token {
+		t.Errorf("resumed %v, want the session of %v", resumed, session)
+	}
+	c.appendAudio(t, time.Second, false)
+	if ev := c.expect(t, "input_audio_buffer.speech_stopped"); ev["item_id"] != item {
+		t.Errorf("speech_stopped for %v, want %v", ev["item_id"], item)
+	}
+	if ev := c.expect(t, "input_audio_buffer.committed"); ev["item_id"] != item {
+		t.Errorf("committed %v, want %v", ev["item_id"], item)
+	}
+
+	// The token is taken while the session is connected, and a clean
+	// close ends the session.
+	if resp, c2 := dial(t, hs, "/", resumeHeader(token)); c2 != nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("second resume: status %d, want 404", resp.StatusCode)
+	}
+	c.write(t, true, 8, []byte{0x03, 0xe8}) // close, 1000
+	waitFor(t, "the session to end", func() bool { return len(srv.TenantSessions()) == 0 })
+	resp, c := dial(t, hs, "/", resumeHeader(token))
+	if c != nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("resume after a clean close: status %d, want 404", resp.StatusCode)
+	}
+	if code := errorCode(t, resp); code != "session_not_found" {
+		t.Errorf("error code %q, want session_not_found", code)
+	}
+}
+
+// TestResumeTokenURL checks that a resume_token in the URL, where proxies
+// and access logs record it, is refused without touching the session, and
+// that a browser can resume with the token as a subprotocol.
+func TestResumeTokenURL(t *testing.T) {
+	srv, hs := newTestServer(t, func(s *realtime.Server) { s.ResumeWindow = time.Minute })
+	_, c := dial(t, hs, "/", nil)
+	token, _ := c.expect(t, "session.created")["session"].(map[string]any)["resume_token"].(string)
+	c.drop(t, srv)
+
+	for _, header := range []http.Header{nil, resumeHeader(token)} {
+		resp, c := dial(t, hs, "/?resume_token="+token, header)
+		if c != nil || resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("resume_token in the URL: status %d, want 400", resp.StatusCode)
+		}
+		if code := errorCode(t, resp); code != "resume_token_in_url" {
+			t.Errorf("error code %q, want resume_token_in_url", code)
+		}
+	}
+
+	resp, c := dial(t, hs, "/", http.Header{"Sec-Websocket-Protocol": {"realtime, resume-token." + token}})
+	if c == nil {
+		t.Fatalf("resume by subprotocol: status %d, want 101", resp.StatusCode)
+	}
+	if p := resp.Header.Get("Sec-WebSocket-Protocol"); p != "realtime" {
+		t.Errorf("subprotocol %q, want realtime", p)
+	}
+	c.expect(t, "session.resumed")
+}
+
+// TestNewServer checks the group configurations NewServer refuses.
+func TestNewServer(t *testing.T) {
+	base := smartturn.Config{
+		SampleRate:             smartturn.RequiredSampleRate,
+		ChunkSize:              smartturn.RequiredChunkSize,
+		VadThreshold:           0.5,
+		VadPreSpeechMs:         200,
+		VadStopMs:              300,
+		TurnMaxDurationSeconds: 600,
+		TurnSegmentEmitMs:      1000,
+		TurnThreshold:          0.5,
+		TurnTimeoutMs:          1000,
+		VADBackend:             &smartturntest.EnergyVAD{},
+		TurnBackend:            &smartturntest.TurnScript{},
+	}
+	for _, tc := range []struct {
+		name  string
+		tweak func(*smartturn.Config)
+		err   string // "" for none
+	}{
+		{"plain", func(*smartturn.Config) {}, ""},
+		{"8 kHz", func(c *smartturn.Config) { c.SampleRate, c.ChunkSize = 8000, 256 }, "SampleRate"},
+		{"InputQueue", func(c *smartturn.Config) { c.InputQueue.Size = 8 }, "InputQueue"},
+	} {
+		cfg := base
+		tc.tweak(&cfg)
+		group, err := smartturn.NewSessionGroup(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = realtime.NewServer(group)
+		if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%s: NewServer error %v, want %q", tc.name, err, tc.err)
+		}
+		_ = group.Close()
+	}
+}
+
+// TestResumeExpiry checks that a detached session ends after ResumeWindow,
+// and at once on Shutdown or when it drops during the drain.
+func TestResumeExpiry(t *testing.T) {
+	srv, hs := newTestServer(t, func(s *realtime.Server) { s.ResumeWindow = 100 * time.Millisecond })
+	_, c := dial(t, hs, "/", nil)
+	token, _ := c.expect(t, "session.created")["session"].(map[string]any)["resume_token"].(string)
+	c.drop(t, srv)
+	waitFor(t, "the session to expire", func() bool { return len(srv.TenantSessions()) == 0 })
+	for _, tok := range []string{token, "unknown"} {
+		if resp, c := dial(t, hs, "/", resumeHeader(tok)); c != nil || resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("resume with %q: status %d, want 404", tok, resp.StatusCode)
+		}
+	}
+
+	srv, hs = newTestServer(t, func(s *realtime.Server) { s.ResumeWindow = time.Minute })
+	_, c = dial(t, hs, "/", nil)
+	if tok := c.expect(t, "session.created")["session"].(map[string]any)["resume_token"]; tok == token {
+		t.Errorf("two sessions with resume_token %v", tok)
+	}
+	c.drop(t, srv)
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.TenantSessions(); len(got) != 0 {
+		t.Errorf("TenantSessions = %v after Shutdown, want none", got)
+	}
+
+	// A connection dropping during the drain is not kept either.
+	srv, hs = newTestServer(t, func(s *realtime.Server) { s.ResumeWindow = time.Minute })
+	_, c = dial(t, hs, "/", nil)
+	c.expect(t, "session.created")
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Shutdown(context.Background()) }()
+	waitFor(t, "the drain", srv.Draining)
+	c.drop(t, srv)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.TenantSessions(); len(got) != 0 {
+		t.Errorf("TenantSessions = %v after a drop during the drain, want none", got)
+	}
+}
+
+// TestResumeOff checks that without ResumeWindow a session has no token
+// and ends when its connection drops.
+func TestResumeOff(t *testing.T) {
+	srv, hs := newTestServer(t, nil)
+	_, c := dial(t, hs, "/", nil)
+	if session := c.expect(t, "session.created")["session"].(map[string]any); session["resume_token"] != nil {
+		t.Errorf("session %v has a resume_token", session)
+	}
+	c.drop(t, srv)
+	waitFor(t, "the session to end", func() bool { return len(srv.TenantSessions()) == 0 })
+}
+
+// TestMaxSessions checks that connections over MaxSessions are refused
+// with 429 and Retry-After, that a detached session keeps its slot and
+// can be resumed at the limit, and that an ended one frees it.
+func TestMaxSessions(t *testing.T) {
+	srv, hs := newTestServer(t, func(s *realtime.Server) {
+		s.MaxSessions = 1
+		s.ResumeWindow = time.Minute
+	})
+	_, c := dial(t, hs, "/", nil)
+	token, _ := c.expect(t, "session.created")["session"].(map[string]any)["resume_token"].(string)
+	refused := func(when string) {
+		t.Helper()
+		resp, c := dial(t, hs, "/", nil)
+		if c != nil || resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+			t.Fatalf("%s: status %d, Retry-After %q; want 429 with Retry-After", when, resp.StatusCode, resp.Header.Get("Retry-After"))
+		}
+		if code := errorCode(t, resp); code != "session_limit_reached" {
+			t.Errorf("%s: error code %q, want session_limit_reached", when, code)
+		}
+	}
+	refused("second session")
+	c.drop(t, srv)
+	refused("while the first is detached")
+	_, c = dial(t, hs, "/", resumeHeader(token))
+	if c == nil {
+		t.Fatal("resume refused at the limit")
+	}
+	c.expect(t, "session.resumed")
+
+	c.write(t, true, 8, []byte{0x03, 0xe8}) // close, 1000
+	waitFor(t, "the session to end", func() bool { return len(srv.TenantSessions()) == 0 })
+	if resp, c := dial(t, hs, "/", nil); c == nil {
+		t.Fatalf("after the session ended: status %d, want 101", resp.StatusCode)
+	}
+}
+
+// TestMaxChunkRate checks that appends over the session's budget are
+// dropped with a rate_limit_exceeded error, and that the budget refills.
+func TestMaxChunkRate(t *testing.T) {
+	_, hs := newTestServer(t, func(s *realtime.Server) { s.MaxChunkRate = 31.25 })
+	_, c := dial(t, hs, "/", nil)
+	c.expect(t, "session.created")
+	half := make([]byte, realtime.InputSampleRate) // 0.5 s of pcm16
+	for i := range 4 {
+		c.send(t, map[string]any{"type": "input_audio_buffer.append", "event_id": fmt.Sprint("append_", i), "audio": half})
+	}
+	// Appends are not acknowledged: a commit shows which passed.
+	c.send(t, map[string]any{"type": "input_audio_buffer.commit"})
+	for i := 2; i < 4; i++ {
+		e := c.expect(t, "error")["error"].(map[string]any)
+		if e["type"] != "rate_limit_error" || e["code"] != "rate_limit_exceeded" || e["event_id"] != fmt.Sprint("append_", i) {
+			t.Errorf("append %d: error %v, want rate_limit_exceeded for its event_id", i, e)
+		}
+	}
+	c.expect(t, "input_audio_buffer.committed")
+
+	// Half a second refills half a second's worth.
+	time.Sleep(600 * time.Millisecond)
+	c.send(t, map[string]any{"type": "input_audio_buffer.append", "audio": half})
+	c.send(t, map[string]any{"type": "input_audio_buffer.commit"})
+	c.expect(t, "input_audio_buffer.committed")
+
+	// More than the burst is never allowed.
+	time.Sleep(1100 * time.Millisecond)
+	c.send(t, map[string]any{"type": "input_audio_buffer.append", "audio": make([]byte, 2*realtime.InputSampleRate+2)})
+	if e := c.expect(t, "error")["error"].(map[string]any); e["code"] != "rate_limit_exceeded" {
+		t.Errorf("append over a second: error %v, want rate_limit_exceeded", e)
+	}
+}
+
+// within reports whether the number v of a JSON event is in [lo, hi].
+func within(v any, lo, hi float64) bool {
+	f, ok := v.(float64)
+	return ok && f >= lo && f <= hi
+}
+
+// TestTurns checks the events of two turns under server_vad: each starts
+// prefix_padding_ms before its speech, stops silence_duration_ms after it
+// (rounded up to chunks), and is committed after the previous one. The
+// second turn runs on the engine a session.update started, whose offsets
+// count from the start of the session all the same.
+func TestTurns(t *testing.T) {
+	_, hs := newTestServer(t, nil)
+	_, c := dial(t, hs, "/", nil)
+	created := c.expect(t, "session.created")
+	session := created["session"].(map[string]any)
+	if session["object"] != "realtime.session" || session["input_audio_format"] != "pcm16" ||
+		session["turn_detection"].(map[string]any)["type"] != "server_vad" {
+		t.Errorf("session.created %v", created)
+	}
+	c.appendAudio(t, 500*time.Millisecond, false)
+	var previous any
+	for turn, tc := range []struct{ start, pad, stop float64 }{{500, 200, 300}, {3000, 100, 500}} {
+		if turn > 0 {
+			c.send(t, map[string]any{"type": "session.update", "session": map[string]any{
+				"turn_detection": map[string]any{"type": "server_vad", "prefix_padding_ms": tc.pad, "silence_duration_ms": tc.stop},
+			}})
+			c.expect(t, "session.updated")
+			c.appendAudio(t, 500*time.Millisecond, false)
+		}
+		c.appendAudio(t, time.Second, true)
+		c.appendAudio(t, time.Second, false)
+		started := c.expect(t, "input_audio_buffer.speech_started")
+		stopped := c.expect(t, "input_audio_buffer.speech_stopped")
+		committed := c.expect(t, "input_audio_buffer.committed")
+		item := started["item_id"]
+		end := tc.start + 1000 + tc.stop
+		if start := tc.start - tc.pad; !within(started["audio_start_ms"], start-32, start+32) || !within(stopped["audio_end_ms"], end, end+64) {
+			t.Errorf("turn %d: audio_start_ms %v, audio_end_ms %v; want about %v and %v",
+				turn, started["audio_start_ms"], stopped["audio_end_ms"], start, end)
+		}
+		if stopped["item_id"] != item || committed["item_id"] != item || committed["previous_item_id"] != previous {
+			t.Errorf("turn %d: item %v stopped %v, committed %v after %v; want after %v",
+				turn, item, stopped["item_id"], committed["item_id"], committed["previous_item_id"], previous)
+		}
+		previous = item
+	}
+}
+
+// TestManualCommit checks commit and clear with turn detection off.
+func TestManualCommit(t *testing.T) {
+	_, hs := newTestServer(t, nil)
+	_, c := dial(t, hs, "/", nil)
+	c.expect(t, "session.created")
+	c.send(t, map[string]any{"type": "session.update", "session": map[string]any{"turn_detection": nil}})
+	if td, ok := c.expect(t, "session.updated")["session"].(map[string]any)["turn_detection"]; !ok || td != nil {
+		t.Fatalf("session.updated turn_detection %v, want null", td)
+	}
+
+	c.send(t, map[string]any{"type": "input_audio_buffer.commit", "event_id": "c1"})
+	if e := c.expect(t, "error")["error"].(map[string]any); e["code"] != "input_audio_buffer_commit_empty" || e["event_id"] != "c1" {
+		t.Errorf("empty commit: %v", e)
+	}
+	// Speech is not detected, only committed.
+	c.appendAudio(t, time.Second, true)
+	c.appendAudio(t, time.Second, false)
+	c.send(t, map[string]any{"type": "input_audio_buffer.commit"})
+	first := c.expect(t, "input_audio_buffer.committed")
+	if first["item_id"] == nil || first["previous_item_id"] != nil {
+		t.Errorf("first commit %v", first)
+	}
+	c.appendAudio(t, 200*time.Millisecond, true)
+	c.send(t, map[string]any{"type": "input_audio_buffer.clear"})
+	c.expect(t, "input_audio_buffer.cleared")
+	c.send(t, map[string]any{"type": "input_audio_buffer.commit"})
+	c.expect(t, "error")
+	c.appendAudio(t, 200*time.Millisecond, true)
+	c.send(t, map[string]any{"type": "input_audio_buffer.commit"})
+	if ev := c.expect(t, "input_audio_buffer.committed"); ev["previous_item_id"] != first["item_id"] {
+		t.Errorf("second commit %v, want it after %v", ev, first["item_id"])
+	}
+}
+
+// TestClientErrors checks the error events of client events the server
+// cannot act on; none ends the session.
+func TestClientErrors(t *testing.T) {
+	_, hs := newTestServer(t, nil)
+	_, c := dial(t, hs, "/", nil)
+	c.expect(t, "session.created")
+	for _, tc := range []struct {
+		ev   map[string]any
+		code string
+	}{
+		{map[string]any{"type": "response.create", "event_id": "e1"}, "unsupported_event"},
+		{map[string]any{"type": "conversation.item.create", "event_id": "e2"}, "unsupported_event"},
+		{map[string]any{"type": "session.update", "event_id": "e3"}, "missing_session"},
+		{map[string]any{"type": "session.update", "event_id": "e4", "session": map[string]any{"input_audio_format": "g711_ulaw"}}, "unsupported_audio_format"},
+		{map[string]any{"type": "session.update", "event_id": "e5", "session": map[string]any{"turn_detection": map[string]any{"type": "push_to_talk"}}}, "invalid_turn_detection"},
+		{map[string]any{"type": "session.update", "event_id": "e6", "session": map[string]any{"turn_detection": map[string]any{"type": "semantic_vad", "eagerness": "eager"}}}, "invalid_turn_detection"},
+		{map[string]any{"type": "input_audio_buffer.append", "event_id": "e7", "audio": "not base64!"}, "invalid_audio"},
+		{map[string]any{"type": "input_audio_buffer.append", "event_id": "e8", "audio": "AAAA"}, "invalid_audio"}, // 3 bytes
+	} {
+		c.send(t, tc.ev)
+		e := c.expect(t, "error")["error"].(map[string]any)
+		if e["type"] != "invalid_request_error" || e["code"] != tc.code || e["event_id"] != tc.ev["event_id"] {
+			t.Errorf("%v: error %v, want %s", tc.ev, e, tc.code)
+		}
+	}
+	c.write(t, true, 1, []byte("{"))
+	if e := c.expect(t, "error")["error"].(map[string]any); e["code"] != "invalid_json" || e["event_id"] != nil {
+		t.Errorf("invalid JSON: error %v", e)
+	}
+
+	// A failed update keeps the settings.
+	c.send(t, map[string]any{"type": "session.update", "session": map[string]any{}})
+	if td := c.expect(t, "session.updated")["session"].(map[string]any)["turn_detection"].(map[string]any); td["type"] != "server_vad" {
+		t.Errorf("turn_detection %v after failed updates, want server_vad", td)
+	}
+}
@@ -0,0 +1,72 @@
+package realtime
+
+import "math"
+
+// resampleTaps is the length of the 24 kHz → 16 kHz lowpass filter.
+const resampleTaps = 48
+
+// resampleKernel holds the two phases of a Blackman-windowed sinc with its
+// cutoff at the new Nyquist frequency (8 kHz): phase 0 for output samples
+// that fall on an input sample, phase 1 for those halfway between two.
+var resampleKernel = func() (k [2][resampleTaps]float32) {
+	const fc = 8000.0 / 24000 // cutoff in cycles per input sample
+	for phase := range k {
+		var h [resampleTaps]float64
+		var sum float64
+		for i := range h {
+			x := float64(i-resampleTaps/2+1) - float64(phase)/2 // offset in input samples
+			r := math.Pi * x / (resampleTaps / 2)
+			w := 0.42 + 0.5*math.Cos(r) + 0.08*math.Cos(2*r)
+			s := 1.0
+			if x != 0 {
+				s = math.Sin(2*math.Pi*fc*x) / (2 * math.Pi * fc * x)
+			}
+			h[i] = s * w
+			sum += h[i]
+		}
+		for i := range h {
+			k[phase][i] = float32(h[i] / sum)
+		}
+	}
+	return k
+}()
+
+// resampler converts the 24 kHz pcm16 audio of the Realtime API to the
+// engine's 16 kHz, for input of any length: two output samples per three
+// input samples. Its output lags the input by resampleTaps/2 input samples
+// (1 ms).
+type resampler struct {
+	buf  []float32 // input from the oldest sample the filter still needs
+	next int       // position of the next output in buf, in half samples
+}
+
+func newResampler() *resampler {
+	// Start with a half filter of silence, so the first output is centered
+	// on the first input sample.
+	const hist = resampleTaps/2 - 1
+	return &resampler{buf: make([]float32, hist), next: 2 * hist}
+}
+
+// process appends the 16 kHz samples that in completes to out.
+func (r *resampler) process(in, out []float32) []float32 {
+	r.buf = append(r.buf, in...)
+	for {
+		i, phase := r.next/2, r.next%2
+		start := i - (resampleTaps/2 - 1)
+		if i+resampleTaps/2 >= len(r.buf) {
+			break
+		}
+		x := r.buf[start : start+resampleTaps]
+		var s float32
+		for k, h := range resampleKernel[phase] {
+			s += h * x[k]
+		}
+		out = append(out, s)
+		r.next += 3
+	}
+	if drop := r.next/2 - (resampleTaps/2 - 1); drop > 0 {
+		r.buf = r.buf[:copy(r.buf, r.buf[drop:])]
+		r.next -= 2 * drop
+	}
+	return out
+}
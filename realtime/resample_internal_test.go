@@ -0,0 +1,74 @@
+package realtime
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// level returns the amplitude of the f Hz component of x at rate.
+func level(x []float32, f, rate float64) float64 {
+	var re, im float64
+	for i, v := range x {
+		w := 2 * math.Pi * f * float64(i) / rate
+		re += float64(v) * math.Cos(w)
+		im -= float64(v) * math.Sin(w)
+	}
+	return 2 * math.Hypot(re, im) / float64(len(x))
+}
+
+func tone(f float64, n int) []float32 {
+	in := make([]float32, n)
+	for i := range in {
+		in[i] = float32(0.5 * math.Sin(2*math.Pi*f*float64(i)/InputSampleRate))
+	}
+	return in
+}
+
+// TestResampler checks that tones up to 7 kHz pass at unity gain and in
+// phase, and that the aliases of tones above 8 kHz are at least 38 dB down.
+func TestResampler(t *testing.T) {
+	for _, f := range []float64{300, 1000, 3000, 5000, 7000, 9000, 10000} {
+		out := newResampler().process(tone(f, InputSampleRate), nil)
+		// 1 ms of input is held back for the filter.
+		if len(out) != 15984 {
+			t.Fatalf("%v Hz: %d samples out of one second, want 15984", f, len(out))
+		}
+		// Past the filter's start-up, and whole cycles of the tones.
+		out = out[160:15984]
+		if f < 8000 {
+			if a := level(out, f, 16000); math.Abs(a-0.5) > 0.01 {
+				t.Errorf("%v Hz: amplitude %.4f, want 0.5", f, a)
+			}
+		} else if db := 20 * math.Log10(level(out, 16000-f, 16000)/0.5); db > -38 {
+			t.Errorf("%v Hz: alias at %.1f dB", f, db)
+		}
+	}
+
+	// Output j is input sample 1.5j.
+	in := tone(100, InputSampleRate/10)
+	for j, v := range newResampler().process(in, nil)[16:] {
+		x := 1.5 * float64(j+16)
+		want := 0.5 * math.Sin(2*math.Pi*100*x/InputSampleRate)
+		if math.Abs(float64(v)-want) > 1e-3 {
+			t.Fatalf("out[%d] = %v, want %v", j+16, v, want)
+		}
+	}
+}
+
+// TestResamplerPieces checks that input split at any sizes gives the
+// output of a single call.
+func TestResamplerPieces(t *testing.T) {
+	in := tone(440, InputSampleRate/2)
+	want := newResampler().process(in, nil)
+	for _, size := range []int{1, 2, 3, 7, 480, 4096} {
+		r := newResampler()
+		var out []float32
+		for rest := in; len(rest) > 0; rest = rest[min(size, len(rest)):] {
+			out = r.process(rest[:min(size, len(rest))], out)
+		}
+		if !slices.Equal(out, want) {
+			t.Errorf("%d-sample pieces: %d samples differ from a single call's %d", size, len(out), len(want))
+		}
+	}
+}
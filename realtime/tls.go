@@ -0,0 +1,67 @@
+package realtime
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSOptions configures TLS for the listener of a Server, so it can be
+// exposed beyond localhost without a terminating proxy:
+//
+//	tc, err := realtime.TLSOptions{CertFile: "server.pem", KeyFile: "server.key",
+//		ClientCAFile: "clients-ca.pem"}.Config()
+//	hs := &http.Server{Addr: ":8443", Handler: srv, TLSConfig: tc}
+//	err = hs.ListenAndServeTLS("", "")
+//
+// With mTLS, Server.Authenticate can map the verified client certificate,
+// r.TLS.PeerCertificates[0], to a tenant.
+type TLSOptions struct {
+	// CertFile and KeyFile hold the PEM certificate chain and private key
+	// of the server.
+	CertFile, KeyFile string
+	// ClientCAFile, when set, holds the PEM certificates of the CAs that
+	// client certificates are verified against (mTLS).
+	ClientCAFile string
+	// ClientAuth is the client certificate policy. The zero value,
+	// tls.NoClientCert, means tls.RequireAndVerifyClientCert when
+	// ClientCAFile is set. The policies that verify certificates need
+	// ClientCAFile.
+	ClientAuth tls.ClientAuthType
+}
+
+// Config loads the files into a tls.Config for http.Server.TLSConfig,
+// with TLS 1.2 as the minimum version.
+func (o TLSOptions) Config() (*tls.Config, error) {
+	if o.CertFile == "" || o.KeyFile == "" {
+		return nil, errors.New("realtime: TLS needs both a certificate and a key file")
+	}
+	cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("realtime: %w", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		ClientAuth:   o.ClientAuth,
+	}
+	if o.ClientCAFile != "" {
+		pem, err := os.ReadFile(o.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("realtime: %w", err)
+		}
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("realtime: no certificates in %s", o.ClientCAFile)
+		}
+		if cfg.ClientAuth == tls.NoClientCert {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	if cfg.ClientCAs == nil && (cfg.ClientAuth == tls.VerifyClientCertIfGiven || cfg.ClientAuth == tls.RequireAndVerifyClientCert) {
+		return nil, fmt.Errorf("realtime: client auth %v needs a client CA file", cfg.ClientAuth)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,178 @@
+package realtime_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go/realtime"
+)
+
+// testCA issues certificates for the TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the PEM certificate and key of a leaf named cn.
+func (ca *testCA) issue(t *testing.T, cn string, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kder, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder})
+}
+
+// writeFile writes data into the test's temporary directory.
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestTLSConfig serves over mTLS and checks that clients are verified
+// against the client CA, and that Authenticate sees the client
+// certificate.
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	serverCA, clientCA, otherCA := newTestCA(t), newTestCA(t), newTestCA(t)
+	cert, key := serverCA.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	opts := realtime.TLSOptions{
+		CertFile:     writeFile(t, dir, "server.pem", cert),
+		KeyFile:      writeFile(t, dir, "server.key", key),
+		ClientCAFile: writeFile(t, dir, "clients.pem", clientCA.pem),
+	}
+	tc, err := opts.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.ClientAuth != tls.RequireAndVerifyClientCert || tc.MinVersion != tls.VersionTLS12 {
+		t.Errorf("ClientAuth %v, MinVersion %x; want RequireAndVerifyClientCert and TLS 1.2", tc.ClientAuth, tc.MinVersion)
+	}
+	hs := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	hs.TLS = tc
+	hs.Config.ErrorLog = log.New(io.Discard, "", 0) // refused handshakes
+	hs.StartTLS()
+	defer hs.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCA.cert)
+	get := func(certPEM, keyPEM []byte) (string, error) {
+		cfg := &tls.Config{RootCAs: roots}
+		if certPEM != nil {
+			c, err := tls.X509KeyPair(certPEM, keyPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg.Certificates = []tls.Certificate{c}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+		resp, err := client.Get(hs.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+	if cn, err := get(clientCA.issue(t, "acme", x509.ExtKeyUsageClientAuth)); err != nil || cn != "acme" {
+		t.Errorf("client with a trusted certificate: %q, %v; want acme", cn, err)
+	}
+	if _, err := get(nil, nil); err == nil {
+		t.Error("client without a certificate was served")
+	}
+	if _, err := get(otherCA.issue(t, "mallory", x509.ExtKeyUsageClientAuth)); err == nil {
+		t.Error("client with an untrusted certificate was served")
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	cert, key := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	certFile, keyFile := writeFile(t, dir, "server.pem", cert), writeFile(t, dir, "server.key", key)
+	for _, tc := range []struct {
+		name string
+		opts realtime.TLSOptions
+		want string
+	}{
+		{"no key", realtime.TLSOptions{CertFile: certFile}, "both"},
+		{"key mismatch", realtime.TLSOptions{CertFile: certFile, KeyFile: certFile}, "realtime:"},
+		{"empty CA file", realtime.TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile}, "no certificates"},
+		{"verify without CA", realtime.TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientAuth: tls.VerifyClientCertIfGiven}, "needs a client CA"},
+	} {
+		if _, err := tc.opts.Config(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error %v, want one containing %q", tc.name, err, tc.want)
+		}
+	}
+	// A policy that does not verify needs no CA.
+	if _, err := (realtime.TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientAuth: tls.RequestClientCert}).Config(); err != nil {
+		t.Error(err)
+	}
+}
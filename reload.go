@@ -0,0 +1,136 @@
+package smartturn
+
+import (
+	"errors"
+	"log/slog"
+)
+
+// reloadedModels are models loaded by ReloadModels, waiting to be swapped
+// in between turns. A nil field keeps the engine's model.
+type reloadedModels struct {
+	vad  *sileroVAD
+	turn *smartTurn
+}
+
+func (m *reloadedModels) close() error {
+	var err error
+	if m.vad != nil {
+		err = m.vad.Close()
+	}
+	if m.turn != nil {
+		if terr := m.turn.destroy(); err == nil {
+			err = terr
+		}
+	}
+	return err
+}
+
+// ReloadModels loads the model files of cfg and swaps them in between
+// turns, without dropping the session: fleets can roll out new Smart-Turn
+// weights (or a new Silero model) with no interruption of calls in
+// progress.
+//
+// The built-in models the engine runs are reloaded: Silero from
+// SileroVADModelPath, SileroWindowSamples and SileroSessionOptions, and
+// Smart-Turn from SmartTurnModelPath, SmartTurnFeatures,
+// SmartTurnSessionOptions, SmartTurnProvider and TurnCalibration. Other
+// fields of cfg are ignored, except that it must be valid and keep
+// SampleRate; custom backends (VADBackend, TurnBackend) and the shadow
+// model are not replaced.
+//
+// Loading happens on the calling goroutine, which may be any, while the
+// engine keeps processing audio with the old models. The new ones take
+// over at the first chunk that arrives outside a turn (no speech segment
+// open and no turn pending), or at Reset; the old ones are then closed.
+// A failed load returns the error and leaves the engine untouched. A
+// reload that has not taken over yet is discarded by the next one.
+func (e *Engine) ReloadModels(cfg Config) error {
+	if e.closing.Load() {
+		return ErrClosed
+	}
+	if err := ValidateConfig(cfg); err != nil {
+		return err
+	}
+	if cfg.SampleRate != e.cfg.SampleRate {
+		return errors.New("config: ReloadModels cannot change SampleRate")
+	}
+	builtinTurn := e.cfg.TurnBackend == nil
+	if !e.builtinSilero && !builtinTurn {
+		return errors.New("smart-turn: ReloadModels: engine runs no built-in model")
+	}
+	// Hold the runtime while loading, so a concurrent Close cannot tear it
+	// down under the new sessions.
+	if err := acquireRuntime(runtimeLibPath(e.cfg)); err != nil {
+		return err
+	}
+	defer releaseRuntime()
+
+	m := &reloadedModels{}
+	if e.builtinSilero {
+		v, err := newSileroVAD(cfg.SileroVADModelPath, cfg.SampleRate, cfg.SileroWindowSamples, cfg.SileroSessionOptions, e.clock)
+		if err != nil {
+			return err
+		}
+		m.vad = v
+	}
+	if builtinTurn {
+		st, err := newSmartTurn(cfg.SmartTurnModelPath, cfg.SmartTurnFeatures, cfg.SmartTurnSessionOptions, cfg.SmartTurnProvider)
+		if err != nil {
+			_ = m.close()
+			return err
+		}
+		st.setFeatureWorkers(e.cfg.FeatureWorkers)
+		st.calib = cfg.TurnCalibration
+		m.turn = st
+	}
+
+	if old := e.reload.Swap(m); old != nil {
+		_ = old.close()
+	}
+	// Close may have released the engine before the swap above.
+	if e.closing.Load() {
+		if p := e.reload.Swap(nil); p != nil {
+			_ = p.close()
+		}
+		return ErrClosed
+	}
+	e.log.Info("models loaded; swapping in after the current turn",
+		"silero", m.vad != nil, "smart_turn", m.turn != nil)
+	return nil
+}
+
+// installModels swaps in the models of a pending ReloadModels and closes
+// the ones they replace. The caller checks that no turn is in progress.
+func (e *Engine) installModels() {
+	m := e.reload.Swap(nil)
+	if m == nil {
+		return
+	}
+	if m.vad != nil {
+		var old VADBackend
+		if ens, ok := e.vad.(*vadEnsemble); ok {
+			old, ens.members[0] = ens.members[0], m.vad
+		} else {
+			old, e.vad = e.vad, m.vad
+		}
+		if err := old.Close(); err != nil {
+			e.reportError("closing replaced vad backend", err)
+		}
+	}
+	if m.turn != nil {
+		old := e.smartTurn
+		e.smartTurn = m.turn
+		if err := old.destroy(); err != nil {
+			e.reportError("closing replaced smart-turn backend", err)
+		}
+		if m.turn.fallbackErr != nil {
+			e.reportError("smart-turn execution provider unavailable", m.turn.fallbackErr)
+		}
+	}
+	e.setModelPaths()
+	if e.logs(slog.LevelInfo) {
+		e.log.Info("models reloaded",
+			"turn_input", e.smartTurn.kind(),
+			"turn_window_s", float64(e.smartTurn.model.windowSamples)/RequiredSampleRate)
+	}
+}
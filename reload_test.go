@@ -0,0 +1,151 @@
+package smartturn
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cortexswarm/smart-turn-go/features"
+)
+
+// switchVAD calls every chunk speech while speech is set.
+type switchVAD struct{ speech bool }
+
+func (v *switchVAD) SpeechProb([]float32) (float32, error) {
+	if v.speech {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (v *switchVAD) Reset()       {}
+func (v *switchVAD) Close() error { return nil }
+
+// countingTurn predicts p and counts its calls.
+type countingTurn struct {
+	p                   float32
+	predictions, closes int
+}
+
+func (b *countingTurn) Predict([]float32) (float32, error) {
+	b.predictions++
+	return b.p, nil
+}
+
+func (b *countingTurn) Close() error {
+	b.closes++
+	return nil
+}
+
+// reloadEngine returns a started engine on vad and turn.
+func reloadEngine(t *testing.T, vad VADBackend, turn TurnBackend) *Engine {
+	t.Helper()
+	cfg := ProfileConversational()
+	cfg.VADBackend = vad
+	cfg.TurnBackend = turn
+	e, err := New(cfg, Callbacks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+	e.Start()
+	return e
+}
+
+// pending stores a reload of the Smart-Turn backend turn in e, as
+// ReloadModels does once the model is loaded.
+func pending(t *testing.T, e *Engine, turn TurnBackend) *smartTurn {
+	t.Helper()
+	st, err := newCustomSmartTurn(turn, features.Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.reload.Store(&reloadedModels{turn: st})
+	return st
+}
+
+func push(t *testing.T, e *Engine, chunks int) {
+	t.Helper()
+	for range chunks {
+		if err := e.PushPCM(make([]float32, RequiredChunkSize)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestInstallModels checks that reloaded models wait for the end of the
+// turn in progress, pending turns included, then replace and close the
+// old ones.
+func TestInstallModels(t *testing.T) {
+	vad := &switchVAD{speech: true}
+	old, next := &countingTurn{p: 0.1}, &countingTurn{p: 0.9}
+	e := reloadEngine(t, vad, old)
+	push(t, e, 20)
+	st := pending(t, e, next)
+
+	// The turn ends incomplete and waits TurnTimeoutMs (3 s, 94 chunks)
+	// for speech to resume.
+	vad.speech = false
+	push(t, e, 7+90)
+	if e.smartTurn == st || old.predictions != 1 || old.closes != 0 || !e.turnPending {
+		t.Fatalf("swapped during the turn: %d predictions, %d closes", old.predictions, old.closes)
+	}
+	push(t, e, 10)
+	if e.smartTurn != st || old.closes != 1 || next.closes != 0 {
+		t.Fatalf("not swapped after the turn: old closed %d times, new %d", old.closes, next.closes)
+	}
+
+	vad.speech = true
+	push(t, e, 20)
+	vad.speech = false
+	push(t, e, 10)
+	if old.predictions != 1 || next.predictions != 1 {
+		t.Errorf("next turn predicted by the old model %d times, the new %d", old.predictions-1, next.predictions)
+	}
+}
+
+// TestInstallModelsReset checks that Reset installs a pending reload at
+// once, and that Close releases one still pending.
+func TestInstallModelsReset(t *testing.T) {
+	vad := &switchVAD{speech: true}
+	old, next := &countingTurn{p: 0.9}, &countingTurn{p: 0.9}
+	e := reloadEngine(t, vad, old)
+	push(t, e, 20)
+	st := pending(t, e, next)
+	e.Reset()
+	if e.smartTurn != st || old.closes != 1 {
+		t.Errorf("Reset did not install the reload: old closed %d times", old.closes)
+	}
+
+	later := &countingTurn{}
+	push(t, e, 20)
+	pending(t, e, later)
+	e.Close()
+	if later.closes != 1 || next.closes != 1 {
+		t.Errorf("Close: pending reload closed %d times, current model %d", later.closes, next.closes)
+	}
+}
+
+func TestReloadModelsErrors(t *testing.T) {
+	e := reloadEngine(t, &switchVAD{}, &countingTurn{})
+	cfg := ProfileConversational()
+	cfg.VADBackend = &switchVAD{}
+	cfg.TurnBackend = &countingTurn{}
+	if err := e.ReloadModels(cfg); err == nil || !strings.Contains(err.Error(), "no built-in model") {
+		t.Errorf("engine without built-in models: %v", err)
+	}
+	bad := cfg
+	bad.VadThreshold = 2
+	if err := e.ReloadModels(bad); err == nil || !strings.Contains(err.Error(), "VadThreshold") {
+		t.Errorf("invalid config: %v", err)
+	}
+	bad = cfg
+	bad.SampleRate, bad.ChunkSize = TelephonySampleRate, TelephonyChunkSize
+	if err := e.ReloadModels(bad); err == nil || !strings.Contains(err.Error(), "SampleRate") {
+		t.Errorf("SampleRate change: %v", err)
+	}
+	e.Close()
+	if err := e.ReloadModels(cfg); !errors.Is(err, ErrClosed) {
+		t.Errorf("after Close: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+//go:build linux
+
+package smartturn
+
+/*
+#include <stddef.h>
+#include <stdint.h>
+#include <string.h>
+
+// OrtROCMProviderOptions, from onnxruntime_c_api.h.
+typedef struct {
+	int device_id;
+	int miopen_conv_exhaustive_search;
+	size_t gpu_mem_limit;
+	int arena_extend_strategy;
+	int do_copy_in_default_stream;
+	int has_user_compute_stream;
+	void *user_compute_stream;
+	void *default_memory_arena_cfg;
+	int enable_hip_graph;
+	int tunable_op_enable;
+	int tunable_op_tuning_enable;
+	int tunable_op_max_tuning_duration_ms;
+} rocmOptions;
+
+static void *callAppendROCM(void *fn, void *options, int deviceID) {
+	rocmOptions o;
+	memset(&o, 0, sizeof o);
+	o.device_id = deviceID;
+	o.gpu_mem_limit = SIZE_MAX;
+	o.do_copy_in_default_stream = 1;
+	return ((void *(*)(void *, const rocmOptions *))fn)(options, &o);
+}
+*/
+import "C"
+
+import (
+	"errors"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// ortIndexAppendROCM is OrtApi::SessionOptionsAppendExecutionProvider_ROCM.
+const ortIndexAppendROCM = 153
+
+// appendROCM calls SessionOptionsAppendExecutionProvider_ROCM, which
+// onnxruntime_go does not wrap, with the ONNX Runtime defaults but for the
+// device.
+func appendROCM(opts *ort.SessionOptions, deviceID int) error {
+	fn := ortAPIFunc(ortIndexAppendROCM)
+	if fn == nil {
+		return errors.New("ONNX Runtime library not found")
+	}
+	status := C.callAppendROCM(fn, nativeSessionOptions(opts), C.int(deviceID))
+	return ortStatusError(status, "append ROCm provider")
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package smartturn
+
+import (
+	"errors"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// appendROCM is unreachable off Linux: providerSupported rejects ROCm
+// first.
+func appendROCM(*ort.SessionOptions, int) error {
+	return errors.New("ROCm requires Linux")
+}
@@ -0,0 +1,138 @@
+package smartturn
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// MinONNXRuntimeVersion is the oldest ONNX Runtime release the SDK can
+// load. onnxruntime_go requests OrtApi version 23 at initialization, which
+// older libraries do not provide.
+const MinONNXRuntimeVersion = "1.23.0"
+
+// ErrUnsupportedRuntime is returned when the ONNX Runtime shared library is
+// older than MinONNXRuntimeVersion.
+var ErrUnsupportedRuntime = errors.New("unsupported onnxruntime version")
+
+// ortRuntime is the process-wide ONNX Runtime environment shared by all
+// engines. onnxruntime_go allows one environment per process, so it is
+// initialized by the first engine and destroyed when the last one closes.
+var ortRuntime struct {
+	mu      sync.Mutex
+	refs    int
+	libPath string
+	owned   bool // false when the host initialized ORT itself; we never destroy it then
+}
+
+// runtimeLibPath returns the shared library path from config or EnvONNXRuntimeLib.
+func runtimeLibPath(cfg Config) string {
+	if cfg.ONNXRuntimeLibPath != "" {
+		return cfg.ONNXRuntimeLibPath
+	}
+	return os.Getenv(EnvONNXRuntimeLib)
+}
+
+// acquireRuntime initializes the shared environment on first use and takes a
+// reference. Every successful call must be paired with releaseRuntime.
+func acquireRuntime(libPath string) error {
+	ortRuntime.mu.Lock()
+	defer ortRuntime.mu.Unlock()
+	if ortRuntime.refs > 0 {
+		if libPath != "" && ortRuntime.libPath != "" && libPath != ortRuntime.libPath {
+			return errors.New("onnxruntime already loaded from " + ortRuntime.libPath + "; cannot load " + libPath + " in the same process")
+		}
+		ortRuntime.refs++
+		return nil
+	}
+	if ort.IsInitialized() {
+		// Initialized by the host application; share it but leave teardown to the host.
+		ortRuntime.owned = false
+	} else {
+		// Check before initializing: an old library fails there with an
+		// opaque "GetApi returned nil".
+		if err := checkRuntimeVersion(libraryVersion(libPath)); err != nil {
+			return err
+		}
+		if libPath != "" {
+			ort.SetSharedLibraryPath(libPath)
+		}
+		if err := ort.InitializeEnvironment(); err != nil {
+			return err
+		}
+		ortRuntime.owned = true
+	}
+	if err := checkRuntimeVersion(ort.GetVersion()); err != nil {
+		if ortRuntime.owned {
+			_ = ort.DestroyEnvironment()
+		}
+		return err
+	}
+	ortRuntime.libPath = libPath
+	ortRuntime.refs = 1
+	return nil
+}
+
+// releaseRuntime drops a reference and destroys the environment (unloading
+// the shared library) when the last engine is gone.
+func releaseRuntime() error {
+	ortRuntime.mu.Lock()
+	defer ortRuntime.mu.Unlock()
+	if ortRuntime.refs == 0 {
+		return nil
+	}
+	ortRuntime.refs--
+	if ortRuntime.refs > 0 || !ortRuntime.owned {
+		return nil
+	}
+	ortRuntime.libPath = ""
+	return ort.DestroyEnvironment()
+}
+
+// checkRuntimeVersion reports whether version, as returned by
+// OrtGetApiBase()->GetVersionString, is at least MinONNXRuntimeVersion. An
+// empty or unparsable version passes; the library is then left to fail on
+// its own.
+func checkRuntimeVersion(version string) error {
+	have, ok := parseRuntimeVersion(version)
+	if !ok {
+		return nil
+	}
+	need, _ := parseRuntimeVersion(MinONNXRuntimeVersion)
+	for i := range have {
+		if have[i] != need[i] {
+			if have[i] < need[i] {
+				return fmt.Errorf("%w: onnxruntime %s found, need >=%s", ErrUnsupportedRuntime, version, MinONNXRuntimeVersion)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// parseRuntimeVersion parses "major.minor.patch", ignoring any suffix after
+// the patch number ("1.23.2-dev").
+func parseRuntimeVersion(version string) ([3]int, bool) {
+	var v [3]int
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return v, false
+	}
+	if len(parts) == 3 {
+		// Keep only the leading digits of the patch number.
+		parts[2] = parts[2][:len(parts[2])-len(strings.TrimLeft(parts[2], "0123456789"))]
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
@@ -0,0 +1,54 @@
+package smartturn
+
+// sanitize returns chunk unchanged when every sample is in [-1, 1].
+// Otherwise it returns a copy in e.clean with NaN replaced by 0 and the
+// rest (±Inf included) clamped to [-1, 1]: a single NaN would otherwise
+// poison Silero's recurrent state and every mel feature of the segment.
+// The caller's slice is never modified.
+func (e *Engine) sanitize(chunk []float32) []float32 {
+	for i, v := range chunk {
+		if !(v >= -1 && v <= 1) {
+			return e.sanitizeFrom(chunk, i)
+		}
+	}
+	return chunk
+}
+
+func (e *Engine) sanitizeFrom(chunk []float32, first int) []float32 {
+	out := e.clean[:len(chunk)]
+	copy(out, chunk[:first])
+	n := 0
+	for i := first; i < len(chunk); i++ {
+		v := chunk[i]
+		if !(v >= -1 && v <= 1) {
+			n++
+			v = clampSample(v)
+		}
+		out[i] = v
+	}
+	if e.health.sanitized(n) {
+		e.log.Warn("audio contains NaN/Inf or samples outside [-1, 1]; clamping", "samples", n)
+	}
+	return out
+}
+
+// sanitizeInPlace applies the same rules to a buffer the SDK owns.
+func sanitizeInPlace(s []float32) {
+	for i, v := range s {
+		if !(v >= -1 && v <= 1) {
+			s[i] = clampSample(v)
+		}
+	}
+}
+
+// clampSample maps an out-of-range sample into [-1, 1], NaN to 0.
+func clampSample(v float32) float32 {
+	switch {
+	case v > 1:
+		return 1
+	case v < -1:
+		return -1
+	default: // NaN
+		return 0
+	}
+}
@@ -0,0 +1,152 @@
+package smartturn_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/cortexswarm/smart-turn-go"
+)
+
+// finiteTurn is a TurnBackend that fails the test when the features it is
+// given hold NaN or ±Inf.
+type finiteTurn struct {
+	t     *testing.T
+	calls int
+}
+
+func (b *finiteTurn) Predict(features []float32) (float32, error) {
+	b.calls++
+	for i, v := range features {
+		if v-v != 0 {
+			b.t.Fatalf("feature %d = %v", i, v)
+		}
+	}
+	return 0.9, nil
+}
+
+func (b *finiteTurn) Close() error { return nil }
+
+// FuzzPushPCM pushes chunks of arbitrary length and content (float32
+// little-endian, so NaN, ±Inf, subnormals and extremes included) through
+// an engine. Wrong lengths must fail with ErrChunkSize; full chunks, loud
+// enough to be speech and then followed by silence, must reach the VAD,
+// the segment callbacks and Smart-Turn clamped to [-1, 1], with the
+// replaced samples counted and the caller's slice untouched.
+func FuzzPushPCM(f *testing.F) {
+	n := smartturn.RequiredChunkSize
+	for _, seed := range [][]float32{
+		{},
+		{0.5, -0.5},
+		{float32(math.NaN())},
+		{float32(math.Inf(1)), float32(math.Inf(-1))},
+		{math.MaxFloat32, -math.MaxFloat32, 2, -1.5},
+		{math.SmallestNonzeroFloat32, 1, -1},
+	} {
+		b := make([]byte, 4*len(seed))
+		for i, v := range seed {
+			binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(v))
+		}
+		f.Add(b, uint16(n))
+		f.Add(b, uint16(n-1))
+	}
+	f.Add([]byte{}, uint16(0))
+	f.Add([]byte{0, 0, 0xc0, 0x7f}, uint16(2*n))
+	f.Fuzz(func(t *testing.T, data []byte, size uint16) {
+		samples := make([]float32, len(data)/4)
+		for i := range samples {
+			samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+		}
+		chunk := make([]float32, int(size)%(4*n))
+		if len(samples) > 0 {
+			for i := range chunk {
+				chunk[i] = samples[i%len(samples)]
+			}
+		}
+
+		inRange := func(what string, x []float32) {
+			for i, v := range x {
+				if !(v >= -1 && v <= 1) {
+					t.Fatalf("%s[%d] = %v, want within [-1, 1]", what, i, v)
+				}
+			}
+		}
+		turn := &finiteTurn{t: t}
+		vad := &checkedVAD{check: inRange}
+		e := newTestEngine(t, nil, smartturn.Callbacks{
+			OnChunk:        func(c []float32) { inRange("OnChunk", c) },
+			OnSegmentReady: func(s []float32) { inRange("OnSegmentReady", s) },
+			OnError:        func(err error) { t.Error(err) },
+		}, func(c *smartturn.Config) {
+			c.VADBackend = vad
+			c.TurnBackend = turn
+		})
+
+		before := append([]float32(nil), chunk...)
+		err := e.PushPCM(chunk)
+		if len(chunk) != n {
+			if !errors.Is(err, smartturn.ErrChunkSize) {
+				t.Fatalf("PushPCM of %d samples: %v, want ErrChunkSize", len(chunk), err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range chunk {
+			if math.Float32bits(chunk[i]) != math.Float32bits(before[i]) {
+				t.Fatalf("PushPCM modified sample %d: %v -> %v", i, before[i], chunk[i])
+			}
+		}
+		var replaced uint64
+		for _, v := range chunk {
+			if !(v >= -1 && v <= 1) {
+				replaced++
+			}
+		}
+		if got := e.Health().SanitizedSamples; got != replaced {
+			t.Fatalf("SanitizedSamples = %d, want %d", got, replaced)
+		}
+
+		// Continue the chunk as speech for a second, then end it with
+		// silence so the segment reaches Smart-Turn.
+		vad.speech = true
+		for range 30 {
+			if err := e.PushPCM(chunk); err != nil {
+				t.Fatal(err)
+			}
+		}
+		vad.speech = false
+		silence := make([]float32, n)
+		for range 20 {
+			if err := e.PushPCM(silence); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if turn.calls == 0 {
+			t.Fatal("the segment never reached Smart-Turn")
+		}
+		if got := e.Health().SanitizedSamples; got != 31*replaced {
+			t.Fatalf("SanitizedSamples = %d, want %d", got, 31*replaced)
+		}
+	})
+}
+
+// checkedVAD reports speech while speech is set and passes every chunk to
+// check.
+type checkedVAD struct {
+	speech bool
+	check  func(what string, chunk []float32)
+}
+
+func (v *checkedVAD) SpeechProb(chunk []float32) (float32, error) {
+	v.check("VAD chunk", chunk)
+	if v.speech {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (v *checkedVAD) Reset()       {}
+func (v *checkedVAD) Close() error { return nil }
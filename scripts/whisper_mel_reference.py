@@ -0,0 +1,241 @@
+#!/usr/bin/env python3
+"""Generate golden log-mel fixtures for the Go Whisper feature pipeline.
+
+For a set of synthetic clips, plus any 16 kHz WAV files given on the command
+line, writes to the output directory:
+
+    <name>.audio.npy  float32 mono audio at 16 kHz (the exact Go input)
+    <name>.mel.npy    float32 (80, seconds*100) log-mel
+
+The audio goes through the preprocessing of the Smart-Turn v3 reference
+inference: truncated to the last --seconds, left-padded with zeros to that
+length, then
+
+    WhisperFeatureExtractor(chunk_length=seconds)(audio, padding="max_length",
+        max_length=seconds*16000, truncation=True, do_normalize=True)
+
+so the zero-mean, unit-variance normalization covers the padding too.
+
+Two backends compute the features:
+
+    transformers  transformers.WhisperFeatureExtractor (needs numpy and
+                  transformers)
+    python        a dependency-free port of the same code path
+                  (audio_utils.mel_filter_bank with Slaney scale and norm,
+                  audio_utils.spectrogram with center=True reflect padding,
+                  the last frame dropped, log10, max-8, (x+4)/4)
+
+The default uses transformers when it is installed and the port otherwise;
+--check computes both and fails if they differ by more than 1e-4.
+features/testdata/whisper holds fixtures written with
+
+    scripts/whisper_mel_reference.py --seconds 1 -o features/testdata/whisper
+
+and TestWhisperParity compares against them under go test. Larger sets
+(e.g. 8 s clips and real recordings) can be compared with
+
+    go run ./examples/melparity -dir testdata/parity
+"""
+
+import argparse
+import cmath
+import math
+import os
+import random
+import struct
+import wave
+
+SAMPLE_RATE = 16000
+N_FFT = 400
+HOP = 160
+N_MELS = 80
+
+
+def synthetic_clips(seconds):
+    """Yields (name, samples) with lengths relative to the window."""
+    rng = random.Random(0)
+    n = seconds * SAMPLE_RATE
+
+    def t(i):
+        return i / SAMPLE_RATE
+
+    yield "silence", [0.0] * (n // 2)
+    yield "tone_440hz", [0.3 * math.sin(2 * math.pi * 440 * t(i)) for i in range(n)]
+    # Linear sweep 100 Hz -> 7.9 kHz over 1.5 windows; longer than the window.
+    dur = 1.5 * seconds
+    yield "sweep", [
+        0.3 * math.sin(2 * math.pi * (100 * t(i) + 7800 / (2 * dur) * t(i) ** 2))
+        for i in range(int(dur * SAMPLE_RATE))
+    ]
+    yield "noise", [0.1 * rng.gauss(0, 1) for _ in range(n)]
+    yield "noise_short", [0.1 * rng.gauss(0, 1) for _ in range(int(0.3 * n))]
+    # Speech-like: 150 Hz harmonics with a 4 Hz syllable envelope and a DC offset.
+    voiced = []
+    for i in range(int(0.7 * n)):
+        env = 0.5 * (1 + math.sin(2 * math.pi * 4 * t(i)))
+        v = sum(math.sin(2 * math.pi * 150 * k * t(i)) / k for k in range(1, 20))
+        voiced.append(0.05 + 0.2 * env * v)
+    yield "voiced", voiced
+    yield "clipped", [max(-1.0, min(1.0, 3 * math.sin(2 * math.pi * 220 * t(i)))) for i in range(n)]
+
+
+def wav_clip(path):
+    with wave.open(path) as w:
+        if w.getframerate() != SAMPLE_RATE or w.getsampwidth() != 2:
+            raise SystemExit(f"{path}: want 16-bit PCM at {SAMPLE_RATE} Hz")
+        ch = w.getnchannels()
+        raw = w.readframes(w.getnframes())
+    pcm = struct.unpack(f"<{len(raw) // 2}h", raw)
+    audio = [sum(pcm[i : i + ch]) / ch / 32768 for i in range(0, len(pcm), ch)]
+    return os.path.splitext(os.path.basename(path))[0], audio
+
+
+def to_float32(xs):
+    return list(struct.unpack(f"<{len(xs)}f", struct.pack(f"<{len(xs)}f", *xs)))
+
+
+def pad_window(audio, n):
+    """Truncates to the last n samples or left-pads with zeros to n."""
+    audio = audio[-n:]
+    return [0.0] * (n - len(audio)) + list(audio)
+
+
+# --- dependency-free port of transformers' Whisper feature extraction ---
+
+
+def hertz_to_mel(freq):
+    if freq < 1000.0:
+        return 3.0 * freq / 200.0
+    return 15.0 + math.log(freq / 1000.0) * (27.0 / math.log(6.4))
+
+
+def mel_to_hertz(mels):
+    if mels < 15.0:
+        return 200.0 * mels / 3.0
+    return 1000.0 * math.exp(math.log(6.4) / 27.0 * (mels - 15.0))
+
+
+def mel_filter_bank(n_bins, n_mels, f_min, f_max, sr):
+    """mel_filter_bank(..., norm="slaney", mel_scale="slaney"), as rows (n_mels, n_bins)."""
+    m_min, m_max = hertz_to_mel(f_min), hertz_to_mel(f_max)
+    ff = [mel_to_hertz(m_min + (m_max - m_min) * i / (n_mels + 1)) for i in range(n_mels + 2)]
+    fft_freqs = [(sr // 2) * k / (n_bins - 1) for k in range(n_bins)]
+    bank = []
+    for m in range(n_mels):
+        enorm = 2.0 / (ff[m + 2] - ff[m])
+        row = []
+        for f in fft_freqs:
+            down = (f - ff[m]) / (ff[m + 1] - ff[m])
+            up = (ff[m + 2] - f) / (ff[m + 2] - ff[m + 1])
+            row.append(max(0.0, min(down, up)) * enorm)
+        bank.append(row)
+    return bank
+
+
+def normalize(x):
+    """zero_mean_unit_var_norm over the whole (already padded) window."""
+    mean = sum(x) / len(x)
+    var = sum((v - mean) ** 2 for v in x) / len(x)
+    s = 1.0 / math.sqrt(var + 1e-7)
+    return [(v - mean) * s for v in x]
+
+
+def python_log_mel(x):
+    x = normalize(x)
+    n = len(x)
+    half = N_FFT // 2
+    padded = [x[half - i] for i in range(half)] + x + [x[n - 2 - i] for i in range(half)]
+    window = [0.5 - 0.5 * math.cos(2 * math.pi * i / N_FFT) for i in range(N_FFT)]
+    n_bins = N_FFT // 2 + 1
+    twiddle = [cmath.exp(-2j * math.pi * k / N_FFT) for k in range(N_FFT)]
+    bank = mel_filter_bank(n_bins, N_MELS, 0.0, 8000.0, SAMPLE_RATE)
+    frames = 1 + (len(padded) - N_FFT) // HOP - 1  # Whisper drops the last frame
+    log_spec = [[0.0] * frames for _ in range(N_MELS)]
+    for t in range(frames):
+        frame = [padded[t * HOP + i] * window[i] for i in range(N_FFT)]
+        power = []
+        for k in range(n_bins):
+            acc = sum(v * twiddle[(k * i) % N_FFT] for i, v in enumerate(frame))
+            power.append(acc.real * acc.real + acc.imag * acc.imag)
+        for m in range(N_MELS):
+            v = max(1e-10, sum(f * p for f, p in zip(bank[m], power)))
+            log_spec[m][t] = math.log10(v)
+    top = max(max(row) for row in log_spec)
+    return [(max(v, top - 8.0) + 4.0) / 4.0 for row in log_spec for v in row], (N_MELS, frames)
+
+
+def transformers_log_mel(x, seconds):
+    import numpy as np
+    from transformers import WhisperFeatureExtractor
+
+    extractor = WhisperFeatureExtractor(chunk_length=seconds)
+    mel = extractor(
+        np.asarray(x, dtype=np.float32),
+        sampling_rate=SAMPLE_RATE,
+        return_tensors="np",
+        padding="max_length",
+        max_length=seconds * SAMPLE_RATE,
+        truncation=True,
+        do_normalize=True,
+    ).input_features[0]
+    return [float(v) for v in mel.reshape(-1)], mel.shape
+
+
+# --- .npy ---
+
+
+def write_npy(path, data, shape):
+    dims = ", ".join(str(d) for d in shape) + ("," if len(shape) == 1 else "")
+    header = "{'descr': '<f4', 'fortran_order': False, 'shape': (%s), }" % dims
+    header += " " * ((64 - (10 + len(header) + 1) % 64) % 64) + "\n"
+    with open(path, "wb") as f:
+        f.write(b"\x93NUMPY\x01\x00" + struct.pack("<H", len(header)) + header.encode("latin1"))
+        f.write(struct.pack(f"<{len(data)}f", *data))
+
+
+def have_transformers():
+    try:
+        import numpy  # noqa: F401
+        import transformers  # noqa: F401
+    except ImportError:
+        return False
+    return True
+
+
+def main():
+    parser = argparse.ArgumentParser(description=__doc__.splitlines()[0])
+    parser.add_argument("wav", nargs="*", help="extra 16-bit 16 kHz WAV files")
+    parser.add_argument("-o", "--out", default="testdata/parity", help="output directory")
+    parser.add_argument("--seconds", type=int, default=8, help="window length (Frames = 100*seconds)")
+    parser.add_argument("--backend", choices=["auto", "transformers", "python"], default="auto")
+    parser.add_argument("--check", action="store_true", help="compare the port with transformers")
+    args = parser.parse_args()
+
+    backend = args.backend
+    if backend == "auto":
+        backend = "transformers" if have_transformers() else "python"
+    if args.check and not have_transformers():
+        raise SystemExit("--check needs numpy and transformers")
+
+    os.makedirs(args.out, exist_ok=True)
+    n = args.seconds * SAMPLE_RATE
+    clips = list(synthetic_clips(args.seconds)) + [wav_clip(p) for p in args.wav]
+    for name, audio in clips:
+        audio = to_float32(audio[-n:])
+        x = pad_window(audio, n)
+        if backend == "transformers":
+            mel, shape = transformers_log_mel(x, args.seconds)
+        else:
+            mel, shape = python_log_mel(x)
+        if args.check:
+            other, _ = python_log_mel(x) if backend == "transformers" else transformers_log_mel(x, args.seconds)
+            diff = max(abs(a - b) for a, b in zip(mel, other))
+            if diff > 1e-4:
+                raise SystemExit(f"{name}: backends differ by {diff:.3g}")
+        write_npy(os.path.join(args.out, name + ".audio.npy"), audio, (len(audio),))
+        write_npy(os.path.join(args.out, name + ".mel.npy"), mel, shape)
+        print(f"{name}: {len(audio)} samples -> mel {tuple(shape)} ({backend})")
+
+
+if __name__ == "__main__":
+    main()
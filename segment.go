@@ -1,20 +1,20 @@
 package smartturn
 
-import "sync"
-
-// chunkPool reuses 512-sample buffers to avoid per-chunk allocations in the hot path.
-var chunkPool = sync.Pool{
-	New: func() interface{} { return make([]float32, RequiredChunkSize) },
-}
+// segmentRetainSamples caps the segment buffer kept for reuse after a
+// segment ends (30s); a longer one is released to the GC.
+const segmentRetainSamples = 30 * RequiredSampleRate
 
 // segmenter holds state for the segmentation state machine. Pure logic; no ONNX, no callbacks.
+// Its buffers are reused across chunks and segments, so steady-state
+// processing does not allocate.
 type segmenter struct {
 	cfg configSegment
 
-	preBuffer    [][]float32
+	preBuffer    []float32 // ring of preChunks chunks
 	preBufIdx    int
 	preBufCount  int
 	segment      []float32
+	spare        []float32 // next buffer of a split segment
 	speechActive bool
 	trailingChunks int
 	sinceTrigger  int
@@ -25,6 +25,16 @@ type configSegment struct {
 	stopChunks  int
 	maxChunks   int
 	chunkSize   int
+	// split continues a segment that reaches maxChunks in a new one that
+	// starts with its last overlapChunks chunks.
+	split         bool
+	overlapChunks int
+	// maxSamples caps the segment buffer (Config.MaxBufferedAudioMs); the
+	// oldest audio is evicted beyond it. 0 is unlimited.
+	maxSamples int
+	// evictSlack is evicted on top of the overflow, so the buffer is
+	// moved once per evictSlack of audio rather than on every chunk.
+	evictSlack int
 }
 
 func newSegmenter(sampleRate, chunkSize, preSpeechMs, stopMs int, maxDurationSec float32) *segmenter {
@@ -49,10 +59,30 @@ func newSegmenter(sampleRate, chunkSize, preSpeechMs, stopMs int, maxDurationSec
 			maxChunks:  maxChunks,
 			chunkSize:  chunkSize,
 		},
-		preBuffer: make([][]float32, preChunks),
+		preBuffer: make([]float32, preChunks*chunkSize),
 	}
 }
 
+// setSplit enables splitting at max duration with overlapMs of overlap,
+// kept below the max duration so every part makes progress.
+func (s *segmenter) setSplit(overlapMs, sampleRate int) {
+	s.cfg.split = true
+	s.cfg.overlapChunks = min(ceilDiv(overlapMs*sampleRate/1000, s.cfg.chunkSize), s.cfg.maxChunks-1)
+}
+
+// setBudget caps the segment buffer at ms of audio, rounded up to whole
+// chunks. Eviction frees an eighth of the budget, in whole chunks, beyond
+// the overflow.
+func (s *segmenter) setBudget(ms, sampleRate int) {
+	s.cfg.maxSamples = ceilDiv(ms*sampleRate/1000, s.cfg.chunkSize) * s.cfg.chunkSize
+	s.cfg.evictSlack = s.cfg.maxSamples / 8 / s.cfg.chunkSize * s.cfg.chunkSize
+}
+
+// capacity returns the samples allocated for segment audio.
+func (s *segmenter) capacity() int {
+	return cap(s.segment) + cap(s.spare) + len(s.preBuffer)
+}
+
 func ceilDiv(a, b int) int {
 	if b <= 0 {
 		return 0
@@ -79,6 +109,10 @@ type segmentResult struct {
 	Started        bool
 	Ended          bool
 	EndedBySilence bool   // true when segment ended due to trailing silence (VAD); false when capped at max duration
+	TrailingChunks int    // non-speech chunks at the end of an Ended segment
+	Split          bool   // Ended at max duration and continued in a new segment
+	Overlap        int    // samples of a Split segment repeated at the start of the next
+	Evicted        int    // samples dropped from the start of Segment by the buffer cap
 	Segment        []float32 // current accumulated segment (including pre-speech) while speech is active
 }
 
@@ -91,14 +125,8 @@ func (s *segmenter) processChunk(isSpeech bool, chunk []float32) segmentResult {
 		return out
 	}
 
-	chunkCopy := chunkPool.Get().([]float32)
-	copy(chunkCopy, chunk)
-
 	if !s.speechActive {
-		if old := s.preBuffer[s.preBufIdx]; old != nil {
-			chunkPool.Put(old)
-		}
-		s.preBuffer[s.preBufIdx] = chunkCopy
+		copy(s.preSlot(s.preBufIdx), chunk)
 		s.preBufIdx = (s.preBufIdx + 1) % s.cfg.preChunks
 		if s.preBufCount < s.cfg.preChunks {
 			s.preBufCount++
@@ -108,14 +136,17 @@ func (s *segmenter) processChunk(isSpeech bool, chunk []float32) segmentResult {
 			out.Started = true
 			s.trailingChunks = 0
 			s.sinceTrigger = 1
-			s.segment = s.buildSegmentWithChunk(chunkCopy)
+			s.segment = s.buildSegment()
 			out.Segment = s.segment
 		}
 		return out
 	}
 
-	s.segment = append(s.segment, chunkCopy...)
-	chunkPool.Put(chunkCopy)
+	s.segment = append(s.segment, chunk...)
+	if s.cfg.maxSamples > 0 && len(s.segment) > s.cfg.maxSamples {
+		out.Evicted = len(s.segment) - s.cfg.maxSamples + s.cfg.evictSlack
+		s.segment = s.segment[:copy(s.segment, s.segment[out.Evicted:])]
+	}
 	out.Segment = s.segment
 	s.sinceTrigger++
 	if isSpeech {
@@ -126,43 +157,86 @@ func (s *segmenter) processChunk(isSpeech bool, chunk []float32) segmentResult {
 
 	if s.trailingChunks >= s.cfg.stopChunks {
 		out.Ended = true
+		out.TrailingChunks = s.trailingChunks
 		out.EndedBySilence = true
 		out.Segment = s.segment
 		s.reset()
 	} else if s.sinceTrigger >= s.cfg.maxChunks {
 		out.Ended = true
 		out.EndedBySilence = false
+		out.TrailingChunks = s.trailingChunks
 		out.Segment = s.segment
-		s.reset()
+		if s.cfg.split {
+			out.Split = true
+			out.Overlap = s.continueSegment()
+		} else {
+			s.reset()
+		}
 	}
 	return out
 }
 
-func (s *segmenter) buildSegmentWithChunk(firstChunk []float32) []float32 {
-	n := s.preBufCount*s.cfg.chunkSize + len(firstChunk)
-	seg := make([]float32, 0, n)
+// continueSegment starts the next part of a split segment with the tail of
+// the current one and returns its length. The next part is built in the
+// spare buffer, so the ended one stays valid until the next processChunk.
+func (s *segmenter) continueSegment() int {
+	n := min(s.cfg.overlapChunks*s.cfg.chunkSize, len(s.segment))
+	next := append(s.spare[:0], s.segment[len(s.segment)-n:]...)
+	s.spare = s.segment
+	if cap(s.spare) > segmentRetainSamples {
+		s.spare = nil
+	}
+	s.segment = next
+	s.sinceTrigger = n / s.cfg.chunkSize
+	return n
+}
+
+// restart ends the current segment before its last chunk and starts a new
+// one with that chunk, for speech that goes on with another speaker. The
+// ended segment stays valid until the next processChunk, as with
+// continueSegment.
+func (s *segmenter) restart() (ended, next []float32) {
+	n := s.cfg.chunkSize
+	next = append(s.spare[:0], s.segment[len(s.segment)-n:]...)
+	ended = s.segment[:len(s.segment)-n]
+	s.spare = s.segment
+	if cap(s.spare) > segmentRetainSamples {
+		s.spare = nil
+	}
+	s.segment = next
+	s.sinceTrigger = 1
+	s.trailingChunks = 0
+	return ended, next
+}
+
+// preSlot returns chunk slot i of the pre-speech ring.
+func (s *segmenter) preSlot(i int) []float32 {
+	return s.preBuffer[i*s.cfg.chunkSize : (i+1)*s.cfg.chunkSize]
+}
+
+// buildSegment starts a segment from the pre-speech ring, whose newest
+// chunk is the one that triggered speech. The previous segment's buffer is
+// reused.
+func (s *segmenter) buildSegment() []float32 {
+	seg := s.segment[:0]
 	startIdx := (s.preBufIdx - s.preBufCount + s.cfg.preChunks) % s.cfg.preChunks
 	for i := 0; i < s.preBufCount; i++ {
-		idx := (startIdx + i) % s.cfg.preChunks
-		if s.preBuffer[idx] != nil {
-			seg = append(seg, s.preBuffer[idx]...)
-		}
+		seg = append(seg, s.preSlot((startIdx+i)%s.cfg.preChunks)...)
 	}
-	seg = append(seg, firstChunk...)
 	return seg
 }
 
+// reset ends the current segment. The returned Segment of the chunk that
+// ended it stays valid until the next processChunk.
 func (s *segmenter) reset() {
-	s.segment = nil
+	if cap(s.segment) > segmentRetainSamples {
+		s.segment = nil
+	} else {
+		s.segment = s.segment[:0]
+	}
 	s.speechActive = false
 	s.trailingChunks = 0
 	s.sinceTrigger = 0
 	s.preBufIdx = 0
 	s.preBufCount = 0
-	for i := range s.preBuffer {
-		if s.preBuffer[i] != nil {
-			chunkPool.Put(s.preBuffer[i])
-			s.preBuffer[i] = nil
-		}
-	}
 }
@@ -0,0 +1,48 @@
+package smartturn
+
+import "sync"
+
+// segmentPool holds released Segments for reuse by every engine.
+var segmentPool = sync.Pool{
+	New: func() any { return new(Segment) },
+}
+
+// Segment is a slice of segment audio passed to OnSegment. It comes from a
+// pool shared by all engines, so servers running many sessions do not
+// allocate for every emitted slice. The receiver owns it: it may keep it
+// after the callback returns or hand it to another goroutine, and should
+// call Release once done. A Segment that is never released is simply
+// garbage collected.
+type Segment struct {
+	Samples []float32
+
+	released bool
+}
+
+// newSegment returns a pooled Segment holding a copy of samples.
+func newSegment(samples []float32) *Segment {
+	s := segmentPool.Get().(*Segment)
+	s.released = false
+	if cap(s.Samples) < len(samples) {
+		s.Samples = make([]float32, len(samples))
+	}
+	s.Samples = s.Samples[:len(samples)]
+	copy(s.Samples, samples)
+	return s
+}
+
+// Release returns s to the pool. Samples must not be used afterwards;
+// further calls are no-ops.
+func (s *Segment) Release() {
+	if s.released {
+		return
+	}
+	s.released = true
+	s.Samples = s.Samples[:0]
+	segmentPool.Put(s)
+}
+
+// Copy returns the samples in a new slice that stays valid after Release.
+func (s *Segment) Copy() []float32 {
+	return append([]float32(nil), s.Samples...)
+}
@@ -0,0 +1,87 @@
+package smartturn_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// TestPushPCMAllocs checks that steady-state PushPCM does not allocate
+// over speech, silence, segment emits and Smart-Turn features, with the
+// callbacks of BenchmarkEnginePushPCM set.
+func TestPushPCMAllocs(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 1}.Generate(
+		smartturntest.Speech(2*time.Second), smartturntest.Silence(time.Second),
+		smartturntest.Speech(2*time.Second), smartturntest.Silence(time.Second))
+	chunks := smartturntest.Chunks(audio)
+	e := newTestEngine(t, []float32{0.2, 0.9}, smartturn.Callbacks{
+		OnSpeechStart:          func() {},
+		OnSpeechEnd:            func() {},
+		OnTurnEnd:              func(smartturn.TurnEndReason) {},
+		OnVadScore:             func(float32, int64) {},
+		OnChunk:                func([]float32) {},
+		OnSegmentReady:         func([]float32) {},
+		OnSegment:              func(seg *smartturn.Segment) { seg.Release() },
+		OnTurnPredictionDetail: func(smartturn.TurnPrediction) {},
+		OnError:                func(err error) { t.Error(err) },
+	}, func(cfg *smartturn.Config) { cfg.TurnSegmentEmitMs = 500 })
+	// One pass over the audio warms the engine's buffers.
+	for _, c := range chunks {
+		if err := e.PushPCM(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i := 0
+	allocs := testing.AllocsPerRun(2*len(chunks), func() {
+		if err := e.PushPCM(chunks[i%len(chunks)]); err != nil {
+			t.Fatal(err)
+		}
+		i++
+	})
+	if allocs != 0 {
+		t.Errorf("PushPCM allocates %v times per chunk, want 0", allocs)
+	}
+}
+
+// TestSegmentLength checks that a segment is the contiguous input from
+// VadPreSpeechMs before the trigger chunk through VadStopMs after the last
+// speech chunk, with the trigger chunk in it once.
+func TestSegmentLength(t *testing.T) {
+	const (
+		chunk      = smartturn.RequiredChunkSize
+		preChunks  = 7  // ceil(200 ms / 32 ms), trigger chunk included
+		stopChunks = 10 // ceil(300 ms / 32 ms)
+	)
+	var segment []float32
+	var speech []int64 // offsets of the chunks VAD found speech in
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+		OnVadScore: func(prob float32, offset int64) {
+			if prob > 0.5 {
+				speech = append(speech, offset)
+			}
+		},
+		OnSegmentReady: func(s []float32) { segment = append(segment, s...) },
+	}, nil)
+	audio, _ := smartturntest.Synth{Seed: 3}.Generate(
+		smartturntest.Silence(time.Second), smartturntest.Speech(time.Second),
+		smartturntest.Silence(time.Second))
+	pushAll(t, e, audio)
+
+	if len(speech) == 0 {
+		t.Fatal("VAD found no speech")
+	}
+	trigger := int(speech[0]) / chunk
+	last := int(speech[len(speech)-1]) / chunk
+	start := (trigger - preChunks + 1) * chunk
+	end := (last + 1 + stopChunks) * chunk
+	if len(segment) != end-start {
+		t.Fatalf("segment has %d samples (%d chunks), want %d (%d pre-speech, %d speech, %d stop chunks)",
+			len(segment), len(segment)/chunk, end-start, preChunks-1, last-trigger+1, stopChunks)
+	}
+	if !slices.Equal(segment, audio[start:end]) {
+		t.Error("segment differs from the input audio it spans")
+	}
+}
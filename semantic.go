@@ -0,0 +1,82 @@
+package smartturn
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// TurnVerdict is a SemanticCheck answer.
+type TurnVerdict int
+
+const (
+	// VerdictAcoustic keeps the Smart-Turn decision: the check deferred,
+	// missed its deadline, or did not run.
+	VerdictAcoustic TurnVerdict = iota
+	// VerdictComplete ends the turn.
+	VerdictComplete
+	// VerdictIncomplete keeps the turn open for more speech.
+	VerdictIncomplete
+)
+
+func (v TurnVerdict) String() string {
+	switch v {
+	case VerdictAcoustic:
+		return "acoustic"
+	case VerdictComplete:
+		return "complete"
+	case VerdictIncomplete:
+		return "incomplete"
+	}
+	return "unknown"
+}
+
+// SemanticCheck consults an external signal (partial transcript and an
+// LLM, a punctuation heuristic) when the Smart-Turn probability lands in
+// the uncertain band [Low, High). The engine waits up to Deadline for
+// Check, which runs on its own goroutine and should return when ctx is
+// done; after the deadline the acoustic decision stands and the late
+// answer is discarded. Processing of the stream is paused while waiting,
+// so keep Deadline short or use Config.InputQueue.
+type SemanticCheck struct {
+	Low, High float32
+	Deadline  time.Duration
+	Check     func(ctx context.Context, p TurnPrediction) TurnVerdict
+}
+
+func validateSemanticCheck(c SemanticCheck) error {
+	if c.Check == nil {
+		return nil
+	}
+	if c.Low < 0 || c.High > 1 || c.Low >= c.High {
+		return errors.New("config: SemanticCheck band must satisfy 0 <= Low < High <= 1")
+	}
+	if c.Deadline <= 0 {
+		return errors.New("config: SemanticCheck.Deadline must be > 0")
+	}
+	return nil
+}
+
+// semanticCheck runs Config.SemanticCheck for p and waits for its verdict
+// until the deadline.
+func (e *Engine) semanticCheck(p TurnPrediction) TurnVerdict {
+	sc := e.cfg.SemanticCheck
+	ctx, cancel := context.WithTimeout(context.Background(), sc.Deadline)
+	defer cancel()
+	start := e.clock.Now()
+	answer := make(chan TurnVerdict, 1)
+	go func() { answer <- sc.Check(ctx, p) }()
+	var v TurnVerdict
+	select {
+	case v = <-answer:
+	case <-ctx.Done():
+		e.log.Info("semantic check missed its deadline; keeping acoustic decision",
+			"deadline", sc.Deadline, "probability", p.Probability)
+		return VerdictAcoustic
+	}
+	if e.logs(slog.LevelDebug) {
+		e.log.Debug("semantic check", "verdict", v, "probability", p.Probability, "duration", e.clock.Now().Sub(start))
+	}
+	return v
+}
@@ -0,0 +1,161 @@
+package smartturn_test
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// TestSemanticCheck checks that a verdict in the uncertain band overrides
+// TurnThreshold, and that probabilities outside it are not checked.
+func TestSemanticCheck(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		prob    float32
+		verdict smartturn.TurnVerdict
+		checked bool
+		want    smartturn.TurnEndReason
+	}{
+		{"complete below threshold", 0.3, smartturn.VerdictComplete, true, smartturn.TurnEndModel},
+		{"incomplete above threshold", 0.7, smartturn.VerdictIncomplete, true, smartturn.TurnEndTimeout},
+		{"acoustic below threshold", 0.3, smartturn.VerdictAcoustic, true, smartturn.TurnEndTimeout},
+		{"acoustic above threshold", 0.7, smartturn.VerdictAcoustic, true, smartturn.TurnEndModel},
+		{"at High", 0.8, smartturn.VerdictIncomplete, false, smartturn.TurnEndModel},
+		{"below Low", 0.1, smartturn.VerdictComplete, false, smartturn.TurnEndTimeout},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var checked []float32
+			var verdicts []smartturn.TurnVerdict
+			var reasons []smartturn.TurnEndReason
+			e := newTestEngine(t, []float32{tc.prob}, smartturn.Callbacks{
+				OnTurnPredictionDetail: func(p smartturn.TurnPrediction) { verdicts = append(verdicts, p.Verdict) },
+				OnTurnEnd:              func(r smartturn.TurnEndReason) { reasons = append(reasons, r) },
+			}, func(cfg *smartturn.Config) {
+				cfg.SemanticCheck = smartturn.SemanticCheck{
+					Low: 0.2, High: 0.8, Deadline: time.Second,
+					Check: func(ctx context.Context, p smartturn.TurnPrediction) smartturn.TurnVerdict {
+						checked = append(checked, p.Probability)
+						return tc.verdict
+					},
+				}
+			})
+			audio, _ := smartturntest.Synth{Seed: 6}.Generate(smartturntest.Speech(time.Second), smartturntest.Silence(2*time.Second))
+			pushAll(t, e, audio)
+
+			if len(reasons) != 1 || reasons[0] != tc.want {
+				t.Errorf("OnTurnEnd reasons %v, want [%v]", reasons, tc.want)
+			}
+			if len(verdicts) == 0 {
+				t.Fatal("no prediction")
+			}
+			want := smartturn.VerdictAcoustic
+			if tc.checked {
+				want = tc.verdict
+			}
+			if verdicts[0] != want {
+				t.Errorf("TurnPrediction.Verdict = %v, want %v", verdicts[0], want)
+			}
+			if tc.checked != (len(checked) > 0) || len(checked) > 0 && checked[0] != tc.prob {
+				t.Errorf("Check called with %v, want checked %v", checked, tc.checked)
+			}
+		})
+	}
+}
+
+// TestSemanticCheckDeadline checks that the acoustic decision stands when
+// Check misses its deadline, whether it honours ctx or not.
+func TestSemanticCheckDeadline(t *testing.T) {
+	for _, honour := range []bool{true, false} {
+		var ctxDone atomic.Bool
+		var called atomic.Int64 // UnixNano; Check runs on its own goroutine
+		var decided time.Time
+		var verdicts []smartturn.TurnVerdict
+		var reasons []smartturn.TurnEndReason
+		e := newTestEngine(t, []float32{0.3}, smartturn.Callbacks{
+			OnTurnPredictionDetail: func(p smartturn.TurnPrediction) {
+				verdicts = append(verdicts, p.Verdict)
+				decided = time.Now()
+			},
+			OnTurnEnd: func(r smartturn.TurnEndReason) { reasons = append(reasons, r) },
+		}, func(cfg *smartturn.Config) {
+			cfg.SemanticCheck = smartturn.SemanticCheck{
+				Low: 0.2, High: 0.8, Deadline: 20 * time.Millisecond,
+				Check: func(ctx context.Context, _ smartturn.TurnPrediction) smartturn.TurnVerdict {
+					called.Store(time.Now().UnixNano())
+					if honour {
+						<-ctx.Done()
+						ctxDone.Store(true)
+					} else {
+						time.Sleep(200 * time.Millisecond)
+					}
+					return smartturn.VerdictComplete
+				},
+			}
+		})
+		audio, _ := smartturntest.Synth{Seed: 6}.Generate(smartturntest.Speech(time.Second), smartturntest.Silence(2*time.Second))
+		pushAll(t, e, audio)
+		if d := decided.Sub(time.Unix(0, called.Load())); d > 150*time.Millisecond {
+			t.Errorf("honour ctx %v: the engine waited %v past a 20ms deadline", honour, d)
+		}
+		if len(verdicts) == 0 || verdicts[0] != smartturn.VerdictAcoustic {
+			t.Errorf("honour ctx %v: verdicts %v, want acoustic first", honour, verdicts)
+		}
+		if len(reasons) != 1 || reasons[0] != smartturn.TurnEndTimeout {
+			t.Errorf("honour ctx %v: OnTurnEnd reasons %v, want [timeout]", honour, reasons)
+		}
+		if honour {
+			time.Sleep(10 * time.Millisecond)
+			if !ctxDone.Load() {
+				t.Error("ctx not cancelled at the deadline")
+			}
+		}
+	}
+}
+
+func TestSemanticCheckValidate(t *testing.T) {
+	check := func(context.Context, smartturn.TurnPrediction) smartturn.TurnVerdict {
+		return smartturn.VerdictAcoustic
+	}
+	for _, tc := range []struct {
+		sc   smartturn.SemanticCheck
+		want string // "" for valid
+	}{
+		{smartturn.SemanticCheck{}, ""},
+		{smartturn.SemanticCheck{Low: 2, High: 1}, ""}, // off: no Check
+		{smartturn.SemanticCheck{Low: 0, High: 1, Deadline: time.Millisecond, Check: check}, ""},
+		{smartturn.SemanticCheck{Low: -0.1, High: 0.5, Deadline: time.Second, Check: check}, "band"},
+		{smartturn.SemanticCheck{Low: 0.5, High: 1.1, Deadline: time.Second, Check: check}, "band"},
+		{smartturn.SemanticCheck{Low: 0.5, High: 0.5, Deadline: time.Second, Check: check}, "band"},
+		{smartturn.SemanticCheck{Low: 0.2, High: 0.8, Check: check}, "Deadline"},
+	} {
+		cfg := benchConfig()
+		cfg.VADBackend = &smartturntest.EnergyVAD{}
+		cfg.TurnBackend = &smartturntest.TurnScript{}
+		cfg.SemanticCheck = tc.sc
+		e, err := smartturn.New(cfg, smartturn.Callbacks{})
+		if err == nil {
+			e.Close()
+		}
+		if tc.want == "" && err != nil || tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)) {
+			t.Errorf("SemanticCheck{Low: %v, High: %v, Deadline: %v}: error %v, want %q", tc.sc.Low, tc.sc.High, tc.sc.Deadline, err, tc.want)
+		}
+	}
+}
+
+func TestTurnVerdictString(t *testing.T) {
+	for v, want := range map[smartturn.TurnVerdict]string{
+		smartturn.VerdictAcoustic:   "acoustic",
+		smartturn.VerdictComplete:   "complete",
+		smartturn.VerdictIncomplete: "incomplete",
+		smartturn.TurnVerdict(9):    "unknown",
+	} {
+		if got := v.String(); got != want {
+			t.Errorf("TurnVerdict(%d).String() = %q, want %q", int(v), got, want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package smartturn
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrGroupClosed is returned by SessionGroup.NewSession after Close, and by
+// the Smart-Turn inferences of its sessions.
+var ErrGroupClosed = errors.New("smart-turn: session group is closed")
+
+// SessionOverrides are the thresholds and timeouts a SessionGroup session
+// may change, e.g. a long VadStopMs and TurnTimeoutMs for dictation and
+// short ones for quick commands. Start from SessionGroup.Defaults and
+// change what differs: every field is used as is, like Config's.
+type SessionOverrides struct {
+	VadThreshold           float32
+	VadPreSpeechMs         int
+	VadStopMs              int
+	TurnThreshold          float32
+	TurnTimeoutMs          int
+	TurnMaxDurationSeconds float32
+	TurnMergeGapMs         int
+	TurnSegmentEmitMs      int
+}
+
+// overridesOf returns the overridable fields of cfg.
+func overridesOf(cfg Config) SessionOverrides {
+	return SessionOverrides{
+		VadThreshold:           cfg.VadThreshold,
+		VadPreSpeechMs:         cfg.VadPreSpeechMs,
+		VadStopMs:              cfg.VadStopMs,
+		TurnThreshold:          cfg.TurnThreshold,
+		TurnTimeoutMs:          cfg.TurnTimeoutMs,
+		TurnMaxDurationSeconds: cfg.TurnMaxDurationSeconds,
+		TurnMergeGapMs:         cfg.TurnMergeGapMs,
+		TurnSegmentEmitMs:      cfg.TurnSegmentEmitMs,
+	}
+}
+
+// apply returns cfg with the overridden fields replaced.
+func (o SessionOverrides) apply(cfg Config) Config {
+	cfg.VadThreshold = o.VadThreshold
+	cfg.VadPreSpeechMs = o.VadPreSpeechMs
+	cfg.VadStopMs = o.VadStopMs
+	cfg.TurnThreshold = o.TurnThreshold
+	cfg.TurnTimeoutMs = o.TurnTimeoutMs
+	cfg.TurnMaxDurationSeconds = o.TurnMaxDurationSeconds
+	cfg.TurnMergeGapMs = o.TurnMergeGapMs
+	cfg.TurnSegmentEmitMs = o.TurnSegmentEmitMs
+	return cfg
+}
+
+// SessionGroup creates sessions (engines) from one Config that share a
+// single Smart-Turn model, each with its own SessionOverrides. The model
+// runs one inference at a time, like TurnClassifier; on a GPU, prefer a
+// TurnBatcher as Config.TurnBackend. VAD keeps per-stream state, so every
+// session loads its own Silero model; a Config.VADBackend would be shared
+// by all sessions, so leave it unset. Close the sessions before the group.
+type SessionGroup struct {
+	cfg   Config
+	model smartTurnModel
+
+	mu          sync.Mutex // serializes inference and guards closed
+	backend     TurnBackend
+	closed      bool
+	usesRuntime bool
+}
+
+// NewSessionGroup validates cfg and loads its Smart-Turn model (or takes
+// ownership of cfg.TurnBackend).
+func NewSessionGroup(cfg Config) (*SessionGroup, error) {
+	if err := ValidateConfig(cfg); err != nil {
+		return nil, err
+	}
+	g := &SessionGroup{cfg: cfg}
+	var st *smartTurn
+	var err error
+	if cfg.TurnBackend != nil {
+		st, err = newCustomSmartTurn(cfg.TurnBackend, cfg.SmartTurnFeatures)
+	} else {
+		if err := acquireRuntime(runtimeLibPath(cfg)); err != nil {
+			return nil, err
+		}
+		g.usesRuntime = true
+		st, err = newSmartTurn(cfg.SmartTurnModelPath, cfg.SmartTurnFeatures, cfg.SmartTurnSessionOptions, cfg.SmartTurnProvider)
+	}
+	if err != nil {
+		if g.usesRuntime {
+			_ = releaseRuntime()
+		}
+		return nil, err
+	}
+	g.model, g.backend = st.model, st.backend
+	return g, nil
+}
+
+// Defaults returns the thresholds and timeouts of the group's Config.
+func (g *SessionGroup) Defaults() SessionOverrides {
+	return overridesOf(g.cfg)
+}
+
+// Config returns the Config the group was created with.
+func (g *SessionGroup) Config() Config {
+	return g.cfg
+}
+
+// NewSession creates an engine from the group's Config with o applied,
+// running the shared Smart-Turn model. The resulting Config is validated
+// as by New, so invalid overrides fail here.
+func (g *SessionGroup) NewSession(o SessionOverrides, cb Callbacks) (*Engine, error) {
+	return g.NewSessionObserved(o, cb, g.cfg.Observer)
+}
+
+// NewSessionObserved is NewSession with obs as the session's
+// Config.Observer in place of the group's, e.g. to label the metrics of
+// a server's sessions with their tenant.
+func (g *SessionGroup) NewSessionObserved(o SessionOverrides, cb Callbacks, obs Observer) (*Engine, error) {
+	g.mu.Lock()
+	closed := g.closed
+	g.mu.Unlock()
+	if closed {
+		return nil, ErrGroupClosed
+	}
+	cfg := o.apply(g.cfg)
+	cfg.TurnBackend = &groupBackend{g: g}
+	cfg.Observer = obs
+	return New(cfg, cb)
+}
+
+// Close releases the shared model. Sessions still open fail their
+// Smart-Turn inferences with ErrGroupClosed.
+func (g *SessionGroup) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return nil
+	}
+	g.closed = true
+	err := g.backend.Close()
+	if g.usesRuntime {
+		if rerr := releaseRuntime(); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// groupBackend is one session's handle on the group's model. It keeps the
+// raw outputs of its own last inference.
+type groupBackend struct {
+	g     *SessionGroup
+	logit float32
+	aux   []ModelOutput
+}
+
+// Predict runs the shared model under the group's lock.
+func (h *groupBackend) Predict(features []float32) (float32, error) {
+	h.g.mu.Lock()
+	defer h.g.mu.Unlock()
+	if h.g.closed {
+		return 0, ErrGroupClosed
+	}
+	prob, err := h.g.backend.Predict(features)
+	if err != nil {
+		return 0, err
+	}
+	h.logit = logit(prob)
+	if rb, ok := h.g.backend.(rawOutputBackend); ok {
+		var aux []ModelOutput
+		h.logit, aux = rb.lastOutputs()
+		if h.aux == nil {
+			h.aux = make([]ModelOutput, len(aux))
+			for i, o := range aux {
+				h.aux[i] = ModelOutput{Name: o.Name, Shape: o.Shape, Data: make([]float32, len(o.Data))}
+			}
+		}
+		for i, o := range aux {
+			copy(h.aux[i].Data, o.Data)
+		}
+	}
+	return prob, nil
+}
+
+func (h *groupBackend) lastOutputs() (float32, []ModelOutput) {
+	return h.logit, h.aux
+}
+
+// smartTurnModel lets the session use the group's feature parameters.
+func (h *groupBackend) smartTurnModel() smartTurnModel { return h.g.model }
+
+// Close implements TurnBackend; the group stays open.
+func (h *groupBackend) Close() error { return nil }
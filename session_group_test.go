@@ -0,0 +1,167 @@
+package smartturn_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// newGroup returns a group sharing backend, with energy VAD.
+func newGroup(t *testing.T, backend smartturn.TurnBackend) *smartturn.SessionGroup {
+	t.Helper()
+	cfg := benchConfig()
+	cfg.VadStopMs = 300
+	cfg.VADBackend = &smartturntest.EnergyVAD{}
+	cfg.TurnBackend = backend
+	g, err := smartturn.NewSessionGroup(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = g.Close() })
+	return g
+}
+
+// session returns a started session of g logging its turns to got.
+func session(t *testing.T, g *smartturn.SessionGroup, o smartturn.SessionOverrides, got *[]string) *smartturn.Engine {
+	t.Helper()
+	var mu sync.Mutex
+	log := func(s string) {
+		mu.Lock()
+		*got = append(*got, s)
+		mu.Unlock()
+	}
+	e, err := g.NewSession(o, smartturn.Callbacks{
+		OnSpeechStart: func() { log("start") },
+		OnTurnEnd:     func(r smartturn.TurnEndReason) { log("end:" + r.String()) },
+		OnError:       func(err error) { log("error:" + err.Error()) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+	e.Start()
+	return e
+}
+
+// TestSessionGroup checks that sessions share the group's backend, each
+// with its own overrides.
+func TestSessionGroup(t *testing.T) {
+	backend := &recordingTurn{Probability: 0.6}
+	g := newGroup(t, backend)
+	d := g.Defaults()
+	if d.VadStopMs != 300 || d.TurnThreshold != 0.5 || d.TurnTimeoutMs != 1000 || g.Config().TurnBackend != backend {
+		t.Fatalf("Defaults %+v", d)
+	}
+	audio, _ := smartturntest.Synth{Seed: 27}.Generate(
+		smartturntest.Speech(time.Second), smartturntest.Silence(700*time.Millisecond),
+		smartturntest.Speech(time.Second), smartturntest.Silence(3*time.Second))
+
+	// A long VadStopMs rides out the pause; a TurnThreshold above the
+	// model's 0.6 ends turns by timeout.
+	patient, strict := d, d
+	patient.VadStopMs = 1000
+	strict.TurnThreshold = 0.7
+	for _, tc := range []struct {
+		name string
+		o    smartturn.SessionOverrides
+		want string
+	}{
+		{"defaults", d, "start end:model start end:model"},
+		{"patient", patient, "start end:model"},
+		{"strict", strict, "start end:timeout"},
+	} {
+		var got []string
+		e := session(t, g, tc.o, &got)
+		pushAll(t, e, audio)
+		if s := strings.Join(got, " "); s != tc.want {
+			t.Errorf("%s: callbacks %q, want %q", tc.name, s, tc.want)
+		}
+	}
+	if backend.calls != 2+1+2 || backend.closed {
+		t.Errorf("shared backend: %d calls, closed %v; want 5 calls, open", backend.calls, backend.closed)
+	}
+
+	bad := d
+	bad.VadThreshold = 2
+	if _, err := g.NewSession(bad, smartturn.Callbacks{}); err == nil {
+		t.Error("VadThreshold 2 accepted")
+	}
+}
+
+// unsyncTurn is a TurnBackend that is not safe for concurrent use.
+type unsyncTurn struct{ calls int }
+
+func (b *unsyncTurn) Predict([]float32) (float32, error) {
+	b.calls++
+	return 0.9, nil
+}
+
+func (b *unsyncTurn) Close() error { return nil }
+
+// TestSessionGroupConcurrent runs sessions on goroutines of their own;
+// the group serializes their inferences (run with -race).
+func TestSessionGroupConcurrent(t *testing.T) {
+	backend := &unsyncTurn{}
+	g := newGroup(t, backend)
+	audio, _ := smartturntest.Synth{Seed: 28}.Generate(smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	const sessions = 4
+	got := make([][]string, sessions)
+	var wg sync.WaitGroup
+	for i := range sessions {
+		e := session(t, g, g.Defaults(), &got[i])
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 3 {
+				for _, c := range smartturntest.Chunks(audio) {
+					if err := e.PushPCM(c); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for i, g := range got {
+		if s := strings.Join(g, " "); s != strings.Repeat("start end:model ", 2)+"start end:model" {
+			t.Errorf("session %d: callbacks %q", i, s)
+		}
+	}
+	if backend.calls != 3*sessions {
+		t.Errorf("%d inferences, want %d", backend.calls, 3*sessions)
+	}
+}
+
+func TestSessionGroupClose(t *testing.T) {
+	backend := &recordingTurn{Probability: 0.9}
+	g := newGroup(t, backend)
+	var got []string
+	e := session(t, g, g.Defaults(), &got)
+	e.Close()
+	if backend.closed {
+		t.Fatal("closing a session closed the shared backend")
+	}
+	e = session(t, g, g.Defaults(), &got)
+	if err := g.Close(); err != nil || !backend.closed {
+		t.Fatalf("Close: %v, backend closed %v", err, backend.closed)
+	}
+	if err := g.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if _, err := g.NewSession(g.Defaults(), smartturn.Callbacks{}); !errors.Is(err, smartturn.ErrGroupClosed) {
+		t.Errorf("NewSession after Close: %v", err)
+	}
+	// Open sessions fail their inferences.
+	audio, _ := smartturntest.Synth{Seed: 29}.Generate(smartturntest.Speech(time.Second), smartturntest.Silence(1500*time.Millisecond))
+	pushAll(t, e, audio)
+	if s := strings.Join(got, " "); s != fmt.Sprintf("start error:%v end:timeout", smartturn.ErrGroupClosed) {
+		t.Errorf("session after group Close: %q", s)
+	}
+}
@@ -0,0 +1,145 @@
+package smartturn
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// GraphOptimization is the ONNX Runtime graph optimization level.
+type GraphOptimization string
+
+const (
+	GraphOptimizationDefault  GraphOptimization = ""         // ORT default (all)
+	GraphOptimizationDisable  GraphOptimization = "disable"  // no graph rewrites
+	GraphOptimizationBasic    GraphOptimization = "basic"    // constant folding, redundant node removal
+	GraphOptimizationExtended GraphOptimization = "extended" // basic + node fusions
+	GraphOptimizationAll      GraphOptimization = "all"      // extended + layout optimizations
+)
+
+// ORTLogLevel is the minimum severity of the messages ONNX Runtime writes
+// to stderr for a session.
+type ORTLogLevel string
+
+const (
+	ORTLogDefault ORTLogLevel = ""        // ORT default (warning)
+	ORTLogVerbose ORTLogLevel = "verbose" // everything, incl. node placement per provider
+	ORTLogInfo    ORTLogLevel = "info"
+	ORTLogWarning ORTLogLevel = "warning" // incl. nodes not assigned to the requested provider
+	ORTLogError   ORTLogLevel = "error"
+	ORTLogFatal   ORTLogLevel = "fatal"
+)
+
+var ortLogLevels = map[ORTLogLevel]ort.LoggingLevel{
+	ORTLogVerbose: ort.LoggingLevelVerbose,
+	ORTLogInfo:    ort.LoggingLevelInfo,
+	ORTLogWarning: ort.LoggingLevelWarning,
+	ORTLogError:   ort.LoggingLevelError,
+	ORTLogFatal:   ort.LoggingLevelFatal,
+}
+
+// SessionOptions tunes one ONNX Runtime session. Zero values keep ORT defaults.
+//
+// By default ORT creates an intra-op thread pool sized to the number of
+// physical cores for every session, so N engines in one process spawn
+// N*cores threads. Servers running many engines should set IntraOpThreads
+// to 1 (or a small number) and scale with engines instead.
+type SessionOptions struct {
+	IntraOpThreads int // threads used inside an operator; 0 = ORT default
+	// InterOpThreads is the pool size for running independent nodes in
+	// parallel. Values > 1 switch the session to parallel execution mode;
+	// 0 keeps sequential execution.
+	InterOpThreads    int
+	GraphOptimization GraphOptimization
+	// DisableCPUMemArena turns off ORT's CPU memory arena. The arena keeps
+	// peak allocations for reuse; disabling it lowers resident memory per
+	// session at the cost of allocator calls on each run.
+	DisableCPUMemArena bool
+
+	// LogLevel sets how much ONNX Runtime logs about this session, to
+	// diagnose slow sessions and operators falling back from an execution
+	// provider to CPU. It does not go through Config.Logger.
+	LogLevel ORTLogLevel
+	// ProfilePrefix turns on ONNX Runtime's profiler. When the session is
+	// closed, a Chrome trace of every run (per-operator timings and the
+	// provider each ran on) is written to <ProfilePrefix>_<timestamp>.json;
+	// open it in chrome://tracing or Perfetto. The directory is created if
+	// missing. The timestamp has one-second resolution, so give concurrent
+	// engines distinct prefixes. Linux and macOS only.
+	ProfilePrefix string
+}
+
+func (o SessionOptions) isZero() bool {
+	return o == SessionOptions{}
+}
+
+func validateSessionOptions(name string, o SessionOptions) error {
+	if o.IntraOpThreads < 0 {
+		return errors.New("config: " + name + ".IntraOpThreads must be >= 0")
+	}
+	if o.InterOpThreads < 0 {
+		return errors.New("config: " + name + ".InterOpThreads must be >= 0")
+	}
+	if _, ok := ortLogLevels[o.LogLevel]; !ok && o.LogLevel != ORTLogDefault {
+		return errors.New("config: " + name + ".LogLevel unknown level " + strconv.Quote(string(o.LogLevel)))
+	}
+	if _, ok := graphOptimizationLevels[o.GraphOptimization]; !ok {
+		return errors.New("config: " + name + ".GraphOptimization unknown level " + strconv.Quote(string(o.GraphOptimization)))
+	}
+	return nil
+}
+
+var graphOptimizationLevels = map[GraphOptimization]ort.GraphOptimizationLevel{
+	GraphOptimizationDefault:  ort.GraphOptimizationLevelEnableAll,
+	GraphOptimizationDisable:  ort.GraphOptimizationLevelDisableAll,
+	GraphOptimizationBasic:    ort.GraphOptimizationLevelEnableBasic,
+	GraphOptimizationExtended: ort.GraphOptimizationLevelEnableExtended,
+	GraphOptimizationAll:      ort.GraphOptimizationLevelEnableAll,
+}
+
+// apply sets the tuning knobs on opts.
+func (o SessionOptions) apply(opts *ort.SessionOptions) error {
+	if o.IntraOpThreads > 0 {
+		if err := opts.SetIntraOpNumThreads(o.IntraOpThreads); err != nil {
+			return err
+		}
+	}
+	if o.InterOpThreads > 0 {
+		if err := opts.SetInterOpNumThreads(o.InterOpThreads); err != nil {
+			return err
+		}
+		if o.InterOpThreads > 1 {
+			if err := opts.SetExecutionMode(ort.ExecutionModeParallel); err != nil {
+				return err
+			}
+		}
+	}
+	if o.GraphOptimization != GraphOptimizationDefault {
+		if err := opts.SetGraphOptimizationLevel(graphOptimizationLevels[o.GraphOptimization]); err != nil {
+			return err
+		}
+	}
+	if o.DisableCPUMemArena {
+		if err := opts.SetCpuMemArena(false); err != nil {
+			return err
+		}
+	}
+	if o.LogLevel != ORTLogDefault {
+		if err := opts.SetLogSeverityLevel(ortLogLevels[o.LogLevel]); err != nil {
+			return err
+		}
+	}
+	if o.ProfilePrefix != "" {
+		if err := os.MkdirAll(filepath.Dir(o.ProfilePrefix), 0755); err != nil {
+			return fmt.Errorf("profile dir: %w", err)
+		}
+		if err := enableProfiling(opts, o.ProfilePrefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package smartturn
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateSessionOptionsLogLevel(t *testing.T) {
+	for _, l := range []ORTLogLevel{ORTLogDefault, ORTLogVerbose, ORTLogInfo, ORTLogWarning, ORTLogError, ORTLogFatal} {
+		if err := validateSessionOptions("SileroSessionOptions", SessionOptions{LogLevel: l}); err != nil {
+			t.Errorf("%q: %v", l, err)
+		}
+	}
+	err := validateSessionOptions("SmartTurnSessionOptions", SessionOptions{LogLevel: "debug"})
+	if err == nil || !strings.Contains(err.Error(), `SmartTurnSessionOptions.LogLevel unknown level "debug"`) {
+		t.Errorf("unknown level: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package smartturn
+
+import (
+	"log/slog"
+	"time"
+)
+
+// ShadowPrediction compares the shadow Smart-Turn model
+// (Config.ShadowSmartTurnModelPath or ShadowTurnBackend) with the primary
+// on one evaluation, for OnShadowPrediction. The shadow never decides.
+type ShadowPrediction struct {
+	// Primary is the prediction that made the decision, as passed to
+	// OnTurnPrediction.
+	Primary TurnPrediction
+	// Probability and Logit are the shadow model's own score of the same
+	// audio, with neither TurnCalibration nor TurnSmoothing applied;
+	// compare Logit with Primary.Logit.
+	Probability float32
+	Logit       float32
+	// Complete reports whether the shadow would have ended the turn
+	// (Probability >= TurnThreshold).
+	Complete          bool
+	InferenceDuration time.Duration
+	// Err is set when the shadow inference failed; it is not reported via
+	// OnError, so the shadow cannot affect the primary's error rate.
+	Err error
+}
+
+// newShadowTurn builds the shadow model of cfg, nil when none is set.
+func newShadowTurn(cfg Config) (*smartTurn, error) {
+	var st *smartTurn
+	var err error
+	switch {
+	case cfg.ShadowTurnBackend != nil:
+		st, err = newCustomSmartTurn(cfg.ShadowTurnBackend, cfg.SmartTurnFeatures)
+	case cfg.ShadowSmartTurnModelPath != "":
+		st, err = newSmartTurn(cfg.ShadowSmartTurnModelPath, cfg.SmartTurnFeatures, cfg.SmartTurnSessionOptions, ExecutionProvider{})
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	st.setFeatureWorkers(cfg.FeatureWorkers)
+	return st, nil
+}
+
+// runShadow scores segment with the shadow model after the primary made
+// its decision p, and reports both.
+func (e *Engine) runShadow(segment []float32, p TurnPrediction) {
+	start := e.clock.Now()
+	r, err := e.shadow.run(segment)
+	s := ShadowPrediction{
+		Primary:           p,
+		Probability:       r.Probability,
+		Logit:             r.Logit,
+		Complete:          err == nil && r.Probability >= e.cfg.TurnThreshold,
+		InferenceDuration: e.clock.Now().Sub(start),
+		Err:               err,
+	}
+	if e.logs(slog.LevelInfo) {
+		e.log.Info("smart-turn shadow evaluation",
+			"probability", p.Probability,
+			"shadow_probability", s.Probability,
+			"shadow_duration", s.InferenceDuration,
+			"shadow_err", err)
+	}
+	e.record(Event{Kind: EventShadowPrediction, Shadow: s})
+	if e.cb.OnShadowPrediction != nil {
+		e.cb.OnShadowPrediction(s)
+	}
+}
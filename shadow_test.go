@@ -0,0 +1,139 @@
+package smartturn_test
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// pauseTwice is speech with an incomplete pause and an end of turn.
+func pauseTwice(seed uint64) []float32 {
+	audio, _ := smartturntest.Synth{Seed: seed}.Generate(
+		smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond),
+		smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	return audio
+}
+
+// TestShadow checks that the shadow scores the audio of every primary
+// prediction after its decision is delivered, raw and without deciding.
+func TestShadow(t *testing.T) {
+	primary, shadow := &recordingTurn{}, &recordingTurn{}
+	var got []string
+	var shadows []smartturn.ShadowPrediction
+	var predictions []smartturn.TurnPrediction
+	e := newTestEngine(t, nil, smartturn.Callbacks{
+		OnTurnPrediction: func(bool, float32) {},
+		OnTurnPredictionDetail: func(p smartturn.TurnPrediction) {
+			predictions = append(predictions, p)
+			got = append(got, "prediction")
+			// The shadow scores this evaluation after the callback: 0.7,
+			// then 0.1. The primary ends the turn at its next.
+			primary.Probability, shadow.Probability = 0.9, 0.7-0.6*float32(len(predictions)-1)
+		},
+		OnSpeechEnd: func() { got = append(got, "end") },
+		OnShadowPrediction: func(s smartturn.ShadowPrediction) {
+			got = append(got, "shadow")
+			shadows = append(shadows, s)
+			if !slices.Equal(shadow.features, primary.features) {
+				t.Error("shadow scored other features than the primary")
+			}
+		},
+		OnError: func(err error) { t.Error(err) },
+	}, func(cfg *smartturn.Config) {
+		primary.Probability = 0.2
+		cfg.TurnBackend = primary
+		cfg.ShadowTurnBackend = shadow
+		// Calibration applies to the primary only.
+		cfg.TurnCalibration = smartturn.Calibration{B: 1}
+		clk := smartturntest.NewClock(time.Unix(0, 0))
+		clk.SetStep(time.Millisecond)
+		cfg.Clock = clk
+	})
+	pushAll(t, e, pauseTwice(22))
+	if s := strings.Join(got, " "); s != "prediction shadow prediction end shadow" {
+		t.Fatalf("callbacks %q", s)
+	}
+	for i, want := range []struct {
+		p        float32
+		complete bool
+	}{{0.7, true}, {0.1, false}} {
+		s := shadows[i]
+		if s.Primary.Probability != predictions[i].Probability || s.Primary.Logit != predictions[i].Logit {
+			t.Errorf("shadow %d: Primary %+v, want %+v", i, s.Primary, predictions[i])
+		}
+		logit := float32(math.Log(float64(want.p) / float64(1-want.p)))
+		if math.Abs(float64(s.Probability-want.p)) > 1e-6 || math.Abs(float64(s.Logit-logit)) > 1e-4 ||
+			s.Complete != want.complete || s.Err != nil || s.InferenceDuration <= 0 {
+			t.Errorf("shadow %d: %+v, want probability %v (logit %v), complete %v", i, s, want.p, logit, want.complete)
+		}
+	}
+	if primary.calls != 2 || shadow.calls != 2 {
+		t.Errorf("%d primary and %d shadow inferences, want 2 each", primary.calls, shadow.calls)
+	}
+	e.Close()
+	if !shadow.closed {
+		t.Error("shadow backend not closed with the engine")
+	}
+}
+
+// TestShadowErrors checks that a failing shadow reports Err without
+// reaching OnError or the primary's decisions.
+func TestShadowErrors(t *testing.T) {
+	var got []string
+	e := newTestEngine(t, []float32{0.2, 0.9}, smartturn.Callbacks{
+		OnSpeechEnd: func() { got = append(got, "end") },
+		OnShadowPrediction: func(s smartturn.ShadowPrediction) {
+			got = append(got, fmt.Sprintf("shadow:%v:%v", s.Complete, s.Err))
+		},
+		OnError: func(err error) { got = append(got, "error") },
+	}, func(cfg *smartturn.Config) { cfg.ShadowTurnBackend = failingTurn{} })
+	pushAll(t, e, pauseTwice(23))
+	if s := strings.Join(got, " "); s != "shadow:false:inference failed end shadow:false:inference failed" {
+		t.Errorf("callbacks %q", s)
+	}
+
+	cfg := benchConfig()
+	cfg.VADBackend = &smartturntest.EnergyVAD{}
+	cfg.TurnBackend = &smartturntest.TurnScript{}
+	cfg.ShadowSmartTurnModelPath = "missing.onnx"
+	if err := smartturn.ValidateConfig(cfg); err == nil || !strings.Contains(err.Error(), "shadow") {
+		t.Errorf("missing shadow model: %v", err)
+	}
+}
+
+func TestShadowProcess(t *testing.T) {
+	e := newTestEngine(t, []float32{0.2, 0.9}, smartturn.Callbacks{}, func(cfg *smartturn.Config) {
+		cfg.ShadowTurnBackend = &smartturntest.TurnScript{Probabilities: []float32{0.6}}
+	})
+	var kinds []smartturn.EventKind
+	for _, c := range smartturntest.Chunks(pauseTwice(24)) {
+		events, err := e.Process(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ev := range events {
+			switch ev.Kind {
+			case smartturn.EventTurnPrediction, smartturn.EventSpeechEnd:
+				kinds = append(kinds, ev.Kind)
+			case smartturn.EventShadowPrediction:
+				kinds = append(kinds, ev.Kind)
+				if ev.Shadow.Probability != 0.6 || !ev.Shadow.Complete {
+					t.Errorf("EventShadowPrediction %+v", ev.Shadow)
+				}
+			}
+		}
+	}
+	want := []smartturn.EventKind{
+		smartturn.EventTurnPrediction, smartturn.EventShadowPrediction,
+		smartturn.EventTurnPrediction, smartturn.EventSpeechEnd, smartturn.EventShadowPrediction,
+	}
+	if !slices.Equal(kinds, want) {
+		t.Errorf("events %v, want %v", kinds, want)
+	}
+}
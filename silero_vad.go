@@ -2,153 +2,288 @@ package smartturn
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
+	"github.com/cortexswarm/smart-turn-go/internal/onnxgraph"
 	ort "github.com/yalue/onnxruntime_go"
 )
 
-var errChunkSize = errors.New("chunk must be exactly 512 samples")
+var (
+	errChunkSize    = errors.New("chunk must be exactly 512 samples")
+	errVADChunkSize = errors.New("chunk must be exactly 512 samples, or 256 at 8 kHz")
+)
 
 const (
-	sileroContextSamples = 64
-	sileroInputSamples   = sileroContextSamples + RequiredChunkSize // 576
-	sileroStateSize      = 2 * 1 * 128
-	sileroResetInterval  = 5 * time.Second
+	sileroResetInterval = 5 * time.Second
+
+	// v5 graphs see the tail of the previous window before each window:
+	// 64 samples for 512, the ratio the upstream wrapper uses.
+	sileroContextRatio = 8
 )
 
-// sileroVAD is a stateful ONNX wrapper for Silero VAD. Not safe for concurrent use.
-type sileroVAD struct {
-	session  *ort.AdvancedSession
-	input    *ort.Tensor[float32]   // (1, 576)
-	state    *ort.Tensor[float32]   // (2, 1, 128)
-	sr       *ort.Tensor[int64]     // (1,) = 16000
-	output   *ort.Tensor[float32]   // (1, 1) speech prob
-	stateOut *ort.Tensor[float32]   // (2, 1, 128) new state
-
-	context [sileroContextSamples]float32
-	stateBuf [sileroStateSize]float32
-	lastReset time.Time
+// sileroModel describes the Silero revision detected in the ONNX graph.
+// v5 has one "state" input (2, 1, 128) and is fed context before each
+// window; v3/v4 have LSTM "h" and "c" inputs (2, 1, 64) and no context.
+type sileroModel struct {
+	inputName  string
+	srName     string // "" when the graph has no sample-rate input
+	srScalar   bool   // sr is declared as a scalar rather than (1,)
+	outputName string
+	states     []ort.InputOutputInfo // recurrent inputs, in graph order
+	stateOuts  []string              // matching outputs
+	window     int                   // samples scored per run
+	context    int                   // samples of the previous window prepended
 }
 
-func newSileroVAD(modelPath string) (*sileroVAD, error) {
-	inputShape := ort.NewShape(1, sileroInputSamples)
-	inputData := make([]float32, sileroInputSamples)
-	inputTensor, err := ort.NewTensor(inputShape, inputData)
+// detectSileroModel inspects the graph so older and newer silero_vad.onnx
+// revisions are fed the inputs they expect. window (0 for one chunk of
+// chunkSize) must agree with a static input length in the graph.
+func detectSileroModel(modelPath string, window, chunkSize int) (sileroModel, error) {
+	inputs, outputs, err := onnxgraph.ReadFile(modelPath)
 	if err != nil {
-		return nil, err
+		return sileroModel{}, err
 	}
+	return sileroModelOf(inputs, outputs, window, chunkSize)
+}
 
-	stateShape := ort.NewShape(2, 1, 128)
-	stateData := make([]float32, sileroStateSize)
-	stateTensor, err := ort.NewTensor(stateShape, stateData)
-	if err != nil {
-		_ = inputTensor.Destroy()
-		return nil, err
+// sileroModelOf is detectSileroModel on the graph's inputs and outputs.
+func sileroModelOf(inputs, outputs []ort.InputOutputInfo, window, chunkSize int) (sileroModel, error) {
+	var m sileroModel
+	var in ort.InputOutputInfo
+	for _, i := range inputs {
+		switch {
+		case i.Name == "input":
+			in = i
+		case i.DataType == ort.TensorElementDataTypeInt64:
+			m.srName = i.Name
+			m.srScalar = len(i.Dimensions) == 0
+		case i.DataType == ort.TensorElementDataTypeFloat:
+			m.states = append(m.states, i)
+		default:
+			return sileroModel{}, fmt.Errorf("smart-turn: silero vad: unexpected model input %q", i.Name)
+		}
+	}
+	if in.Name == "" || len(in.Dimensions) != 2 {
+		return sileroModel{}, errors.New("smart-turn: silero vad: model needs an input \"input\" of shape (1, samples)")
 	}
+	if len(outputs) != len(m.states)+1 {
+		return sileroModel{}, fmt.Errorf("smart-turn: silero vad: model has %d recurrent inputs but %d outputs besides the probability", len(m.states), len(outputs)-1)
+	}
+	m.inputName = in.Name
+	m.outputName = outputs[0].Name
+	for _, o := range outputs[1:] {
+		m.stateOuts = append(m.stateOuts, o.Name)
+	}
+	v5 := len(m.states) == 1
 
-	srShape := ort.NewShape(1)
-	srData := []int64{16000}
-	srTensor, err := ort.NewTensor(srShape, srData)
-	if err != nil {
-		_ = inputTensor.Destroy()
-		_ = stateTensor.Destroy()
-		return nil, err
+	m.window = window
+	if d := int(in.Dimensions[1]); d > 0 {
+		w := d
+		if v5 {
+			w = d * sileroContextRatio / (sileroContextRatio + 1)
+		}
+		if window != 0 && window != w {
+			return sileroModel{}, fmt.Errorf("smart-turn: silero vad: model expects %d-sample windows, SileroWindowSamples is %d", w, window)
+		}
+		m.window = w
+	}
+	if m.window == 0 {
+		m.window = chunkSize
+	}
+	if !validSileroWindow(m.window) {
+		return sileroModel{}, fmt.Errorf("smart-turn: silero vad: unsupported window of %d samples (want 256, 512 or 768)", m.window)
 	}
+	if v5 {
+		m.context = m.window / sileroContextRatio
+	}
+	return m, nil
+}
 
-	outputShape := ort.NewShape(1, 1)
-	outputTensor, err := ort.NewEmptyTensor[float32](outputShape)
+// validSileroWindow reports whether n is a window the engine can feed
+// from its chunks.
+func validSileroWindow(n int) bool {
+	return n == 256 || n == 512 || n == 768
+}
+
+// sileroVAD is a stateful ONNX wrapper for Silero VAD and the default
+// VADBackend. Chunks are cut into the model's windows, carrying a partial
+// window over to the next chunk. Not safe for concurrent use.
+type sileroVAD struct {
+	model     sileroModel
+	path      string
+	chunkSize int // 32 ms at the sample rate the model runs at
+	session   *ort.AdvancedSession
+	input     *ort.Tensor[float32]   // (1, context+window)
+	states    []*ort.Tensor[float32] // recurrent state, fed back each run
+	outs      []*ort.Tensor[float32] // new state
+	output    *ort.Tensor[float32]   // (1, 1) speech prob
+	values    []ort.Value            // every bound tensor, for Close
+
+	filled    int     // samples of the next window already in input
+	last      float32 // probability of the last window run
+	lastReset time.Time
+	clock     Clock
+}
+
+// newSileroVAD loads the model to score 32 ms chunks at sampleRate (16000,
+// or 8000 in telephony mode).
+func newSileroVAD(modelPath string, sampleRate, window int, so SessionOptions, clock Clock) (*sileroVAD, error) {
+	chunkSize := sampleRate * RequiredChunkSize / RequiredSampleRate
+	m, err := detectSileroModel(modelPath, window, chunkSize)
 	if err != nil {
-		_ = inputTensor.Destroy()
-		_ = stateTensor.Destroy()
-		_ = srTensor.Destroy()
 		return nil, err
 	}
-
-	stateOutShape := ort.NewShape(2, 1, 128)
-	stateOutTensor, err := ort.NewEmptyTensor[float32](stateOutShape)
-	if err != nil {
-		_ = inputTensor.Destroy()
-		_ = stateTensor.Destroy()
-		_ = srTensor.Destroy()
-		_ = outputTensor.Destroy()
+	v := &sileroVAD{model: m, path: modelPath, chunkSize: chunkSize, lastReset: clock.Now(), clock: clock}
+	fail := func(err error) (*sileroVAD, error) {
+		for _, t := range v.values {
+			_ = t.Destroy()
+		}
 		return nil, err
 	}
 
-	sess, err := ort.NewAdvancedSession(modelPath,
-		[]string{"input", "state", "sr"},
-		[]string{"output", "stateN"},
-		[]ort.Value{inputTensor, stateTensor, srTensor},
-		[]ort.Value{outputTensor, stateOutTensor},
-		nil)
+	v.input, err = ort.NewEmptyTensor[float32](ort.NewShape(1, int64(m.context+m.window)))
 	if err != nil {
-		_ = inputTensor.Destroy()
-		_ = stateTensor.Destroy()
-		_ = srTensor.Destroy()
-		_ = outputTensor.Destroy()
-		_ = stateOutTensor.Destroy()
-		return nil, err
+		return fail(err)
+	}
+	v.values = append(v.values, v.input)
+	inputNames := []string{m.inputName}
+	inputs := []ort.Value{v.input}
+	for _, s := range m.states {
+		shape := make(ort.Shape, len(s.Dimensions))
+		for i, d := range s.Dimensions {
+			if d <= 0 {
+				d = 1 // batch of one
+			}
+			shape[i] = d
+		}
+		st, err := ort.NewEmptyTensor[float32](shape)
+		if err != nil {
+			return fail(err)
+		}
+		v.values = append(v.values, st)
+		out, err := ort.NewEmptyTensor[float32](shape)
+		if err != nil {
+			return fail(err)
+		}
+		v.values = append(v.values, out)
+		v.states = append(v.states, st)
+		v.outs = append(v.outs, out)
+		inputNames = append(inputNames, s.Name)
+		inputs = append(inputs, st)
+	}
+	if m.srName != "" {
+		var sr ort.Value
+		if m.srScalar {
+			sr, err = ort.NewScalar(int64(sampleRate))
+		} else {
+			sr, err = ort.NewTensor(ort.NewShape(1), []int64{int64(sampleRate)})
+		}
+		if err != nil {
+			return fail(err)
+		}
+		v.values = append(v.values, sr)
+		inputNames = append(inputNames, m.srName)
+		inputs = append(inputs, sr)
+	}
+	v.output, err = ort.NewEmptyTensor[float32](ort.NewShape(1, 1))
+	if err != nil {
+		return fail(err)
+	}
+	v.values = append(v.values, v.output)
+	outputs := []ort.Value{v.output}
+	for _, o := range v.outs {
+		outputs = append(outputs, o)
 	}
 
-	v := &sileroVAD{
-		session:   sess,
-		input:     inputTensor,
-		state:     stateTensor,
-		sr:        srTensor,
-		output:    outputTensor,
-		stateOut:  stateOutTensor,
-		lastReset: time.Now(),
+	v.session, _, err = newProviderSession(modelPath, inputNames, append([]string{m.outputName}, m.stateOuts...),
+		inputs, outputs, so, ExecutionProvider{})
+	if err != nil {
+		return fail(err)
 	}
 	return v, nil
 }
 
-func (v *sileroVAD) resetState() {
-	for i := range v.context {
-		v.context[i] = 0
-	}
-	for i := range v.stateBuf {
-		v.stateBuf[i] = 0
+// Reset clears the recurrent state, audio context, and partial window.
+func (v *sileroVAD) Reset() {
+	v.input.ZeroContents()
+	for _, s := range v.states {
+		s.ZeroContents()
 	}
-	v.state.ZeroContents()
-	v.lastReset = time.Now()
+	v.filled = 0
+	v.last = 0
+	v.lastReset = v.clock.Now()
 }
 
 func (v *sileroVAD) maybeReset() {
-	if time.Since(v.lastReset) >= sileroResetInterval {
-		v.resetState()
+	if v.clock.Now().Sub(v.lastReset) >= sileroResetInterval {
+		v.Reset()
 	}
 }
 
-// speechProb returns the speech probability for the given 512-sample chunk.
-// Caller must not modify chunk. No allocations in hot path (reuses session tensors).
-func (v *sileroVAD) speechProb(chunk []float32) (float32, error) {
-	if len(chunk) != RequiredChunkSize {
-		return 0, errChunkSize
+// SpeechProb returns the speech probability for the given chunk: the
+// highest of the windows completed in it, or the last window's when a
+// window longer than the chunk is still filling. Caller must not modify
+// chunk. No allocations in hot path (reuses session tensors).
+func (v *sileroVAD) SpeechProb(chunk []float32) (float32, error) {
+	if len(chunk) != v.chunkSize {
+		return 0, errVADChunkSize
 	}
 
 	v.maybeReset()
 
-	// Build input: context (64) + chunk (512) into input tensor
-	inputData := v.input.GetData()
-	copy(inputData[:sileroContextSamples], v.context[:])
-	copy(inputData[sileroContextSamples:], chunk)
-
-	// Update context to last 64 samples of effective input (chunk's last 64 or context+chunk boundary)
-	for i := 0; i < sileroContextSamples; i++ {
-		v.context[i] = inputData[sileroInputSamples-sileroContextSamples+i]
-	}
-
-	if err := v.session.Run(); err != nil {
+	prob, ran := v.last, false
+	err := feedWindows(v.input.GetData(), v.model.context, &v.filled, chunk, func() error {
+		if err := v.session.Run(); err != nil {
+			return err
+		}
+		p := v.output.GetData()[0]
+		if !ran || p > prob {
+			prob = p
+		}
+		ran = true
+		v.last = p
+		// Feed the new state back.
+		for i, s := range v.states {
+			copy(s.GetData(), v.outs[i].GetData())
+		}
+		return nil
+	})
+	if err != nil {
 		return 0, err
 	}
-
-	prob := v.output.GetData()[0]
-
-	// Copy stateN back to state for next run
-	copy(v.state.GetData(), v.stateOut.GetData())
-
 	return prob, nil
 }
 
-func (v *sileroVAD) destroy() error {
-	return v.session.Destroy()
+// feedWindows appends chunk to buf, which holds context samples followed
+// by the window being filled (*filled samples of it so far), and calls run
+// on each window it completes. The tail of each window run is kept as the
+// next one's context; a partial window stays for the next chunk.
+func feedWindows(buf []float32, context int, filled *int, chunk []float32, run func() error) error {
+	window := len(buf) - context
+	for len(chunk) > 0 {
+		n := copy(buf[context+*filled:], chunk)
+		chunk = chunk[n:]
+		*filled += n
+		if *filled < window {
+			break
+		}
+		if err := run(); err != nil {
+			return err
+		}
+		copy(buf[:context], buf[len(buf)-context:])
+		*filled = 0
+	}
+	return nil
+}
+
+// Close releases the session and its bound tensors.
+func (v *sileroVAD) Close() error {
+	err := v.session.Destroy()
+	for _, t := range v.values {
+		if derr := t.Destroy(); err == nil {
+			err = derr
+		}
+	}
+	return err
 }
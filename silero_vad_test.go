@@ -0,0 +1,134 @@
+package smartturn
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// TestFeedWindows feeds a ramp in engine chunks and checks that every
+// window holds the next samples, preceded by the tail of the previous
+// window as context.
+func TestFeedWindows(t *testing.T) {
+	for _, window := range []int{256, 512, 768} {
+		for _, context := range []int{0, window / sileroContextRatio} {
+			name := fmt.Sprintf("window %d, context %d", window, context)
+			buf := make([]float32, context+window)
+			filled, next := 0, 0
+			var runsPerChunk []int
+			for c := 0; c < 6; c++ {
+				chunk := make([]float32, RequiredChunkSize)
+				for i := range chunk {
+					chunk[i] = float32(c*RequiredChunkSize + i + 1)
+				}
+				runs := 0
+				err := feedWindows(buf, context, &filled, chunk, func() error {
+					for i, v := range buf {
+						// Sample s is s+1; the first window's context is zeros.
+						if want := float32(max(next*window-context+i+1, 0)); v != want {
+							t.Fatalf("%s: window %d, input[%d] = %v, want %v", name, next, i, v, want)
+						}
+					}
+					next++
+					runs++
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				runsPerChunk = append(runsPerChunk, runs)
+			}
+			want := map[int]string{256: "[2 2 2 2 2 2]", 512: "[1 1 1 1 1 1]", 768: "[0 1 1 0 1 1]"}[window]
+			if got := fmt.Sprint(runsPerChunk); got != want {
+				t.Errorf("%s: windows per chunk %s, want %s", name, got, want)
+			}
+		}
+	}
+
+	// A failing run leaves the window to be retried.
+	buf := make([]float32, 512)
+	filled := 0
+	boom := errors.New("boom")
+	if err := feedWindows(buf, 0, &filled, make([]float32, 512), func() error { return boom }); err != boom || filled != 512 {
+		t.Errorf("error %v, %d samples filled; want %v, 512", err, filled, boom)
+	}
+}
+
+func TestValidSileroWindow(t *testing.T) {
+	for n, want := range map[int]bool{0: false, 128: false, 256: true, 512: true, 768: true, 1024: false} {
+		if validSileroWindow(n) != want {
+			t.Errorf("validSileroWindow(%d) = %v", n, !want)
+		}
+	}
+}
+
+func tensor(name string, dt ort.TensorElementDataType, dims ...int64) ort.InputOutputInfo {
+	return ort.InputOutputInfo{Name: name, OrtValueType: ort.ONNXTypeTensor, Dimensions: dims, DataType: dt}
+}
+
+const (
+	f32 = ort.TensorElementDataTypeFloat
+	i64 = ort.TensorElementDataTypeInt64
+)
+
+var (
+	// v5: one state, context fed.
+	sileroV5Inputs  = []ort.InputOutputInfo{tensor("input", f32, -1, -1), tensor("state", f32, 2, -1, 128), tensor("sr", i64)}
+	sileroV5Outputs = []ort.InputOutputInfo{tensor("output", f32, -1, 1), tensor("stateN", f32, 2, -1, 128)}
+	// v4: LSTM h and c, sr of shape (1,).
+	sileroV4Inputs  = []ort.InputOutputInfo{tensor("input", f32, -1, -1), tensor("sr", i64, 1), tensor("h", f32, 2, -1, 64), tensor("c", f32, 2, -1, 64)}
+	sileroV4Outputs = []ort.InputOutputInfo{tensor("output", f32, -1, 1), tensor("hn", f32, 2, -1, 64), tensor("cn", f32, 2, -1, 64)}
+)
+
+func TestSileroModelOf(t *testing.T) {
+	static := func(n int64) []ort.InputOutputInfo {
+		in := append([]ort.InputOutputInfo(nil), sileroV5Inputs...)
+		in[0] = tensor("input", f32, 1, n)
+		return in
+	}
+	for _, tc := range []struct {
+		name              string
+		inputs, outputs   []ort.InputOutputInfo
+		window, chunkSize int
+		want              string // window/context/states/sr, or an error
+	}{
+		{"v5", sileroV5Inputs, sileroV5Outputs, 0, 512, "512/64/[state]->[stateN]/sr scalar"},
+		{"v5 256", sileroV5Inputs, sileroV5Outputs, 256, 512, "256/32/[state]->[stateN]/sr scalar"},
+		{"v5 768", sileroV5Inputs, sileroV5Outputs, 768, 512, "768/96/[state]->[stateN]/sr scalar"},
+		{"v5 telephony", sileroV5Inputs, sileroV5Outputs, 0, 256, "256/32/[state]->[stateN]/sr scalar"},
+		{"v5 static", static(576), sileroV5Outputs, 0, 512, "512/64/[state]->[stateN]/sr scalar"},
+		{"v5 static agrees", static(288), sileroV5Outputs, 256, 512, "256/32/[state]->[stateN]/sr scalar"},
+		{"v5 static disagrees", static(576), sileroV5Outputs, 256, 512, "model expects 512-sample windows"},
+		{"v4", sileroV4Inputs, sileroV4Outputs, 0, 512, "512/0/[h c]->[hn cn]/sr (1,)"},
+		{"no sr", sileroV4Inputs[:1:1], sileroV4Outputs[:1], 0, 512, "512/0/[]->[]/no sr"},
+		{"unsupported window", sileroV5Inputs, sileroV5Outputs, 1024, 512, "unsupported window of 1024"},
+		{"bad input", append(sileroV4Inputs[:1:1], tensor("x", ort.TensorElementDataTypeString)), sileroV4Outputs[:1], 0, 512, `unexpected model input "x"`},
+		{"no input", sileroV5Inputs[1:], sileroV5Outputs, 0, 512, `needs an input "input"`},
+		{"outputs", sileroV4Inputs, sileroV4Outputs[:2], 0, 512, "2 recurrent inputs but 1 outputs"},
+	} {
+		m, err := sileroModelOf(tc.inputs, tc.outputs, tc.window, tc.chunkSize)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		} else {
+			var states []string
+			for _, s := range m.states {
+				states = append(states, s.Name)
+			}
+			sr := "no sr"
+			if m.srName != "" {
+				sr = map[bool]string{true: "sr scalar", false: "sr (1,)"}[m.srScalar]
+			}
+			got = fmt.Sprintf("%d/%d/%v->%v/%s", m.window, m.context, states, m.stateOuts, sr)
+			if m.inputName != "input" || m.outputName != "output" {
+				t.Errorf("%s: input %q, output %q", tc.name, m.inputName, m.outputName)
+			}
+		}
+		if !strings.Contains(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -2,73 +2,388 @@ package smartturn
 
 import (
 	"errors"
+	"fmt"
+	"math"
 
+	"github.com/cortexswarm/smart-turn-go/features"
+	"github.com/cortexswarm/smart-turn-go/internal/onnxgraph"
 	ort "github.com/yalue/onnxruntime_go"
 )
 
 var errInvalidSegment = errors.New("invalid segment for Smart-Turn")
 
+// smartTurnInput identifies the input convention of a Smart-Turn model revision.
+type smartTurnInput int
+
+const (
+	// smartTurnMel is the v3 convention: "input_features" (1, 80, frames) Whisper log-mel.
+	smartTurnMel smartTurnInput = iota
+	// smartTurnRaw is the v2 convention: "input_values" (1, samples) normalized waveform.
+	smartTurnRaw
+)
+
+const (
+	smartTurnMelInputName = "input_features"
+	smartTurnRawInputName = "input_values"
+	// smartTurnRawSamples is the v2 window (16s @ 16kHz) used when the graph has a dynamic length.
+	smartTurnRawSamples = 16 * RequiredSampleRate
+)
+
+// smartTurnModel describes what newSmartTurn detected in the ONNX graph.
+type smartTurnModel struct {
+	input         smartTurnInput
+	inputName     string
+	batch         int64 // fixed batch size of the input; 0 when dynamic
+	outputName    string
+	params        features.Params       // mel input only; defaults applied
+	windowSamples int                   // audio samples fed per inference
+	aux           []ort.InputOutputInfo // static float outputs after the first
+}
+
 // smartTurnResult is the structured result from Smart-Turn inference (not exposed to SDK users).
 type smartTurnResult struct {
 	Complete    bool
 	Probability float32
+	Logit       float32
+	Aux         []ModelOutput
 }
 
-// smartTurn runs inference on a finalized speech segment. Unexported; used by engine only.
+// smartTurn runs inference on a finalized speech segment: it computes the
+// model features and scores them with a TurnBackend. Unexported; used by engine only.
 type smartTurn struct {
+	model    smartTurnModel
+	backend  TurnBackend
+	mel      *features.Stream // per-segment STFT cache (mel models only)
+	features []float32        // model input; the backend's own buffer when it exposes one
+	calib    Calibration
+	path     string // model file; empty for a custom backend
+
+	// fallbackErr is set when the configured execution provider was
+	// unavailable and the session was created on CPU instead.
+	fallbackErr error
+}
+
+// ortTurnBackend is the ONNX Runtime TurnBackend. Input/output tensors are
+// bound to the session once at creation and features are written straight
+// into the input tensor, so Predict does not allocate them.
+type ortTurnBackend struct {
 	session *ort.AdvancedSession
 	input   *ort.Tensor[float32]
 	output  *ort.Tensor[float32]
+	// sigmoid is set for graphs whose output is a raw logit (v2).
+	sigmoid bool
+
+	aux    []*ort.Tensor[float32]
+	auxOut []ModelOutput // views of aux, returned by lastOutputs
+	logit  float32       // of the last Predict
 }
 
-func newSmartTurn(modelPath string) (*smartTurn, error) {
-	// Smart-Turn v3.2 CPU expects input_features shape (1, 80, 800) - Whisper mel for 8s.
-	inputShape := ort.NewShape(1, whisperNMels, whisper8sFrames)
-	inputData := make([]float32, 1*whisperNMels*whisper8sFrames)
-	inputTensor, err := ort.NewTensor(inputShape, inputData)
+// detectSmartTurnModel inspects the graph inputs/outputs so that a v2 (raw audio,
+// 16s) or v3 (Whisper mel, 8s) revision is fed the features it was trained on.
+// For mel models, params (zero fields default) must agree with the static
+// dimensions of the graph; a frame count left at zero is taken from the graph.
+// The graph is read from the model file, without creating a session.
+func detectSmartTurnModel(modelPath string, params features.Params) (smartTurnModel, error) {
+	inputs, outputs, err := onnxgraph.ReadFile(modelPath)
+	if err != nil {
+		return smartTurnModel{}, err
+	}
+	return smartTurnModelOf(inputs, outputs, params)
+}
+
+// smartTurnModelOf is detectSmartTurnModel on the graph's inputs and outputs.
+func smartTurnModelOf(inputs, outputs []ort.InputOutputInfo, params features.Params) (smartTurnModel, error) {
+	if len(inputs) != 1 || len(outputs) < 1 {
+		return smartTurnModel{}, fmt.Errorf("smart-turn: expected 1 input and at least 1 output, got %d and %d", len(inputs), len(outputs))
+	}
+	in := inputs[0]
+	m := smartTurnModel{inputName: in.Name, outputName: outputs[0].Name}
+	if len(in.Dimensions) > 0 && in.Dimensions[0] > 0 {
+		m.batch = in.Dimensions[0]
+	}
+	for _, o := range outputs[1:] {
+		// Only outputs with a known shape can be bound to the session.
+		if o.OrtValueType == ort.ONNXTypeTensor && o.DataType == ort.TensorElementDataTypeFloat && staticShape(o.Dimensions) {
+			m.aux = append(m.aux, o)
+		}
+	}
+	switch in.Name {
+	case smartTurnMelInputName:
+		// (batch, n_mels, frames); frames may be dynamic (-1) in some exports.
+		p := params.WithDefaults()
+		if len(in.Dimensions) != 3 {
+			return smartTurnModel{}, fmt.Errorf("smart-turn: input %q has shape %v, want (1, %d, frames)", in.Name, in.Dimensions, p.NMels)
+		}
+		if d := in.Dimensions[1]; d > 0 && int(d) != p.NMels {
+			return smartTurnModel{}, fmt.Errorf("smart-turn: model expects %d mel bins, feature pipeline produces %d", d, p.NMels)
+		}
+		if d := in.Dimensions[2]; d > 0 {
+			if params.Frames != 0 && params.Frames != int(d) {
+				return smartTurnModel{}, fmt.Errorf("smart-turn: model expects %d frames, SmartTurnFeatures.Frames is %d", d, params.Frames)
+			}
+			p.Frames = int(d)
+		}
+		if err := p.Validate(); err != nil {
+			return smartTurnModel{}, fmt.Errorf("smart-turn: %w", err)
+		}
+		m.input = smartTurnMel
+		m.params = p
+		m.windowSamples = p.WindowSamples()
+	case smartTurnRawInputName:
+		// (batch, samples); samples may be dynamic (-1).
+		if len(in.Dimensions) != 2 {
+			return smartTurnModel{}, fmt.Errorf("smart-turn: input %q has shape %v, want (1, samples)", in.Name, in.Dimensions)
+		}
+		m.input = smartTurnRaw
+		m.windowSamples = smartTurnRawSamples
+		if d := in.Dimensions[1]; d > 0 {
+			m.windowSamples = int(d)
+		}
+	default:
+		return smartTurnModel{}, fmt.Errorf("smart-turn: unsupported model input %q (want %q or %q)", in.Name, smartTurnMelInputName, smartTurnRawInputName)
+	}
+	return m, nil
+}
+
+func newSmartTurn(modelPath string, params features.Params, so SessionOptions, ep ExecutionProvider) (*smartTurn, error) {
+	model, err := detectSmartTurnModel(modelPath, params)
 	if err != nil {
 		return nil, err
 	}
+	backend, fallbackErr, err := newORTTurnBackend(modelPath, model, so, ep)
+	if err != nil {
+		return nil, err
+	}
+	st, err := newSmartTurnWithBackend(model, backend)
+	if err != nil {
+		_ = backend.Close()
+		return nil, err
+	}
+	st.fallbackErr = fallbackErr
+	st.path = modelPath
+	return st, nil
+}
+
+// newCustomSmartTurn wraps a user-supplied backend, which receives mel
+// features for params (v3, 8s, by default). SDK backends that load a model
+// themselves (TurnBatcher) dictate its parameters instead.
+func newCustomSmartTurn(backend TurnBackend, params features.Params) (*smartTurn, error) {
+	if mb, ok := backend.(modelBackend); ok {
+		return newSmartTurnWithBackend(mb.smartTurnModel(), backend)
+	}
+	p := params.WithDefaults()
+	model := smartTurnModel{input: smartTurnMel, params: p, windowSamples: p.WindowSamples()}
+	return newSmartTurnWithBackend(model, backend)
+}
+
+func newSmartTurnWithBackend(model smartTurnModel, backend TurnBackend) (*smartTurn, error) {
+	st := &smartTurn{model: model, backend: backend}
+	if model.input == smartTurnMel {
+		mel, err := features.NewStreamWithParams(model.params)
+		if err != nil {
+			return nil, fmt.Errorf("smart-turn: %w", err)
+		}
+		st.mel = mel
+	}
+	if fb, ok := backend.(featureBuffer); ok {
+		st.features = fb.featureBuffer()
+	} else if model.input == smartTurnMel {
+		shape := model.params.Shape()
+		st.features = make([]float32, shape.Mels*shape.Frames)
+	} else {
+		st.features = make([]float32, model.windowSamples)
+	}
+	return st, nil
+}
+
+func newORTTurnBackend(modelPath string, model smartTurnModel, so SessionOptions, ep ExecutionProvider) (*ortTurnBackend, error, error) {
+	// v3 expects input_features (1, 80, 800) - Whisper mel for 8s; v2 expects input_values (1, samples).
+	var inputShape ort.Shape
+	if model.input == smartTurnMel {
+		inputShape = ort.NewShape(1, int64(model.params.NMels), int64(model.params.Frames))
+	} else {
+		inputShape = ort.NewShape(1, int64(model.windowSamples))
+	}
+	inputData := make([]float32, inputShape.FlattenedSize())
+	inputTensor, err := ort.NewTensor(inputShape, inputData)
+	if err != nil {
+		return nil, nil, err
+	}
 	// Model output "logits" has shape (1, 1) — rank 2
 	outputShape := ort.NewShape(1, 1)
 	outputTensor, err := ort.NewEmptyTensor[float32](outputShape)
 	if err != nil {
 		_ = inputTensor.Destroy()
-		return nil, err
+		return nil, nil, err
+	}
+	// v3 exports "logits" already passed through sigmoid; the v2 wav2vec2
+	// classifier head emits a raw logit.
+	b := &ortTurnBackend{input: inputTensor, output: outputTensor, sigmoid: model.input == smartTurnRaw}
+	names := []string{model.outputName}
+	outputs := []ort.Value{outputTensor}
+	for _, o := range model.aux {
+		t, err := ort.NewEmptyTensor[float32](o.Dimensions)
+		if err != nil {
+			_ = b.Close()
+			return nil, nil, err
+		}
+		b.aux = append(b.aux, t)
+		b.auxOut = append(b.auxOut, ModelOutput{Name: o.Name, Shape: o.Dimensions, Data: t.GetData()})
+		names = append(names, o.Name)
+		outputs = append(outputs, t)
 	}
-	// Model output is named "logits" (sigmoid probability), not "output"
-	sess, err := ort.NewAdvancedSession(modelPath,
-		[]string{"input_features"},
-		[]string{"logits"},
+	sess, fallbackErr, err := newProviderSession(modelPath,
+		[]string{model.inputName},
+		names,
 		[]ort.Value{inputTensor},
-		[]ort.Value{outputTensor},
-		nil)
+		outputs,
+		so, ep)
 	if err != nil {
-		_ = inputTensor.Destroy()
-		_ = outputTensor.Destroy()
-		return nil, err
+		_ = b.Close()
+		return nil, nil, err
+	}
+	b.session = sess
+	return b, fallbackErr, nil
+}
+
+func (b *ortTurnBackend) featureBuffer() []float32 {
+	return b.input.GetData()
+}
+
+// Predict runs the session. features must be the tensor's own buffer or be
+// copied into it.
+func (b *ortTurnBackend) Predict(features []float32) (float32, error) {
+	in := b.input.GetData()
+	if len(features) != len(in) {
+		return 0, errInvalidSegment
+	}
+	if &features[0] != &in[0] {
+		copy(in, features)
+	}
+	if err := b.session.Run(); err != nil {
+		return 0, err
+	}
+	prob := b.output.GetData()[0]
+	if b.sigmoid {
+		b.logit = prob
+		prob = float32(1 / (1 + math.Exp(-float64(prob))))
+	} else {
+		b.logit = logit(prob)
+	}
+	return prob, nil
+}
+
+func (b *ortTurnBackend) lastOutputs() (float32, []ModelOutput) {
+	return b.logit, b.auxOut
+}
+
+// Close releases the session and its bound tensors.
+func (b *ortTurnBackend) Close() error {
+	var err error
+	if b.session != nil {
+		err = b.session.Destroy()
+	}
+	if derr := b.input.Destroy(); err == nil {
+		err = derr
+	}
+	if derr := b.output.Destroy(); err == nil {
+		err = derr
 	}
-	return &smartTurn{session: sess, input: inputTensor, output: outputTensor}, nil
+	for _, t := range b.aux {
+		if derr := t.Destroy(); err == nil {
+			err = derr
+		}
+	}
+	return err
 }
 
-// run runs Smart-Turn on the segment audio. Segment is truncated to last 8s or left-padded to 8s.
+// staticShape reports whether every dimension of s is known.
+func staticShape(s ort.Shape) bool {
+	for _, d := range s {
+		if d <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// logit inverts the sigmoid, clamping p so a saturated probability gives a
+// large finite logit instead of ±Inf.
+func logit(p float32) float32 {
+	const eps = 1e-7
+	q := math.Min(math.Max(float64(p), eps), 1-eps)
+	return float32(math.Log(q / (1 - q)))
+}
+
+// loadFeatures converts segment audio into the model's input layout in
+// st.features. The segment is truncated to the last window or left-padded to it.
+// For mel models, frames already transformed for the same segment are reused;
+// the engine calls resetSegment whenever a new segment starts.
+func (st *smartTurn) loadFeatures(segment []float32) bool {
+	if st.model.input == smartTurnRaw {
+		return features.NormalizeWindow(st.features, segment)
+	}
+	return st.mel.Compute(st.features, segment) == nil
+}
+
+// window returns the part of segment the model sees: its last windowSamples.
+func (st *smartTurn) window(segment []float32) []float32 {
+	if n := st.model.windowSamples; len(segment) > n {
+		return segment[len(segment)-n:]
+	}
+	return segment
+}
+
+// setFeatureWorkers sets the goroutine budget for mel extraction.
+func (st *smartTurn) setFeatureWorkers(n int) {
+	if st.mel != nil {
+		st.mel.SetWorkers(n)
+	}
+}
+
+// resetSegment drops cached mel frames of the previous segment.
+func (st *smartTurn) resetSegment() {
+	if st.mel != nil {
+		st.mel.Reset()
+	}
+}
+
+// dropSegment keeps the cached mel frames valid after n samples were
+// evicted from the start of the segment.
+func (st *smartTurn) dropSegment(n int) {
+	if st.mel != nil {
+		st.mel.Drop(n)
+	}
+}
+
+// run runs Smart-Turn on the segment audio.
 func (st *smartTurn) run(segment []float32) (smartTurnResult, error) {
-	mel := computeWhisperMel(segment)
-	if mel == nil {
+	if !st.loadFeatures(segment) {
 		return smartTurnResult{}, errInvalidSegment
 	}
-	inputData := st.input.GetData()
-	copy(inputData, mel)
-	if err := st.session.Run(); err != nil {
+	return st.predict(st.features)
+}
+
+// predict scores model features, collecting the raw outputs when the
+// backend keeps them.
+func (st *smartTurn) predict(features []float32) (smartTurnResult, error) {
+	prob, err := st.backend.Predict(features)
+	if err != nil {
 		return smartTurnResult{}, err
 	}
-	prob := st.output.GetData()[0]
-	return smartTurnResult{
-		Complete:    prob > 0.5,
-		Probability: prob,
-	}, nil
+	r := smartTurnResult{Probability: prob}
+	if rb, ok := st.backend.(rawOutputBackend); ok {
+		r.Logit, r.Aux = rb.lastOutputs()
+	} else {
+		r.Logit = logit(prob)
+	}
+	if !st.calib.identity() {
+		r.Probability = st.calib.apply(r.Logit)
+	}
+	r.Complete = r.Probability > 0.5
+	return r, nil
 }
 
 func (st *smartTurn) destroy() error {
-	return st.session.Destroy()
+	return st.backend.Close()
 }
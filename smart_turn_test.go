@@ -0,0 +1,135 @@
+package smartturn
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cortexswarm/smart-turn-go/features"
+	ort "github.com/yalue/onnxruntime_go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// writeModel writes an ONNX model whose graph has just the inputs and
+// outputs given, enough to be inspected, and returns its path. Negative
+// dimensions are symbolic.
+func writeModel(t *testing.T, inputs, outputs []ort.InputOutputInfo) string {
+	t.Helper()
+	message := func(b []byte, n protowire.Number, m []byte) []byte {
+		return protowire.AppendBytes(protowire.AppendTag(b, n, protowire.BytesType), m)
+	}
+	valueInfo := func(b []byte, n protowire.Number, info ort.InputOutputInfo) []byte {
+		var shape []byte
+		for _, d := range info.Dimensions {
+			var dim []byte
+			if d < 0 {
+				dim = protowire.AppendString(protowire.AppendTag(nil, 2, protowire.BytesType), "batch")
+			} else {
+				dim = protowire.AppendVarint(protowire.AppendTag(nil, 1, protowire.VarintType), uint64(d))
+			}
+			shape = message(shape, 1, dim)
+		}
+		tensor := protowire.AppendVarint(protowire.AppendTag(nil, 1, protowire.VarintType), uint64(info.DataType))
+		tensor = message(tensor, 2, shape)
+		vi := protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), info.Name)
+		vi = message(vi, 2, message(nil, 1, tensor))
+		return message(b, n, vi)
+	}
+	var graph []byte
+	for _, in := range inputs {
+		graph = valueInfo(graph, 11, in)
+	}
+	for _, out := range outputs {
+		graph = valueInfo(graph, 12, out)
+	}
+	path := filepath.Join(t.TempDir(), "model.onnx")
+	if err := os.WriteFile(path, message(nil, 7, graph), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// floatInfo describes a float tensor.
+func floatInfo(name string, dims ...int64) ort.InputOutputInfo {
+	return ort.InputOutputInfo{Name: name, OrtValueType: ort.ONNXTypeTensor, Dimensions: dims, DataType: ort.TensorElementDataTypeFloat}
+}
+
+// smartTurnV3 is the graph of smart-turn-v3.2-cpu.onnx.
+var smartTurnV3 = [2][]ort.InputOutputInfo{
+	{floatInfo("input_features", -1, 80, 800)},
+	{floatInfo("logits", -1, 1)},
+}
+
+// TestDetectSmartTurnModel checks the model detected from the graph in a
+// model file.
+func TestDetectSmartTurnModel(t *testing.T) {
+	p := features.Params{}.WithDefaults()
+	for _, tc := range []struct {
+		name            string
+		inputs, outputs []ort.InputOutputInfo
+		want            smartTurnModel
+		err             string
+	}{
+		{"v3", smartTurnV3[0], smartTurnV3[1],
+			smartTurnModel{input: smartTurnMel, inputName: "input_features", outputName: "logits", params: p, windowSamples: 8 * RequiredSampleRate}, ""},
+		{"v3 fixed batch", []ort.InputOutputInfo{floatInfo("input_features", 1, 80, 800)}, smartTurnV3[1],
+			smartTurnModel{input: smartTurnMel, inputName: "input_features", batch: 1, outputName: "logits", params: p, windowSamples: 8 * RequiredSampleRate}, ""},
+		{"v3 aux outputs", smartTurnV3[0], append([]ort.InputOutputInfo{floatInfo("logits", 1, 1), floatInfo("embedding", 1, 4)}, floatInfo("dynamic", -1, 4)),
+			smartTurnModel{input: smartTurnMel, inputName: "input_features", outputName: "logits", params: p, windowSamples: 8 * RequiredSampleRate,
+				aux: []ort.InputOutputInfo{floatInfo("embedding", 1, 4)}}, ""},
+		{"v2", []ort.InputOutputInfo{floatInfo("input_values", 1, -1)}, smartTurnV3[1],
+			smartTurnModel{input: smartTurnRaw, inputName: "input_values", batch: 1, outputName: "logits", windowSamples: smartTurnRawSamples}, ""},
+		{"mel bins", []ort.InputOutputInfo{floatInfo("input_features", 1, 128, 800)}, smartTurnV3[1], smartTurnModel{}, "128 mel bins"},
+		{"input", []ort.InputOutputInfo{floatInfo("audio", 1, 16000)}, smartTurnV3[1], smartTurnModel{}, `unsupported model input "audio"`},
+		{"no output", smartTurnV3[0], nil, smartTurnModel{}, "expected 1 input and at least 1 output"},
+	} {
+		m, err := detectSmartTurnModel(writeModel(t, tc.inputs, tc.outputs), features.Params{})
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: error %v, want %q", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(m, tc.want) {
+			t.Errorf("%s: %+v, %v; want %+v", tc.name, m, err, tc.want)
+		}
+	}
+	if _, err := detectSmartTurnModel(filepath.Join(t.TempDir(), "missing.onnx"), features.Params{}); err == nil {
+		t.Error("missing model: no error")
+	}
+}
+
+// TestLogit checks that logit inverts the sigmoid and stays finite for
+// saturated probabilities.
+func TestLogit(t *testing.T) {
+	for _, x := range []float64{-8, -1.5, 0, 0.25, 3, 12} {
+		p := float32(1 / (1 + math.Exp(-x)))
+		if got := logit(p); math.Abs(float64(got)-x) > 1e-3*math.Max(1, math.Abs(x)) {
+			t.Errorf("logit(sigmoid(%v)) = %v", x, got)
+		}
+	}
+	for _, p := range []float32{0, 1, -0.5, 1.5} {
+		if got := logit(p); math.IsInf(float64(got), 0) || math.IsNaN(float64(got)) || math.Abs(float64(got)) < 10 {
+			t.Errorf("logit(%v) = %v, want a large finite value", p, got)
+		}
+	}
+}
+
+func TestStaticShape(t *testing.T) {
+	for _, tc := range []struct {
+		shape ort.Shape
+		want  bool
+	}{
+		{ort.NewShape(1, 2), true},
+		{ort.NewShape(), true},
+		{ort.NewShape(-1, 2), false},
+		{ort.NewShape(1, 0), false},
+	} {
+		if got := staticShape(tc.shape); got != tc.want {
+			t.Errorf("staticShape(%v) = %v, want %v", tc.shape, got, tc.want)
+		}
+	}
+}
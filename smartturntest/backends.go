@@ -0,0 +1,60 @@
+package smartturntest
+
+import (
+	"math"
+
+	"github.com/cortexswarm/smart-turn-go"
+)
+
+// EnergyVAD is a smartturn.VADBackend that reports speech (probability 1)
+// for chunks whose RMS exceeds Threshold, and 0 otherwise. With Synth audio
+// it detects speech exactly at the generated boundaries, to chunk accuracy,
+// as long as Synth.Noise stays below about half the threshold.
+type EnergyVAD struct {
+	Threshold float32 // 0 means 0.02
+}
+
+var _ smartturn.VADBackend = (*EnergyVAD)(nil)
+
+// SpeechProb implements smartturn.VADBackend.
+func (v *EnergyVAD) SpeechProb(chunk []float32) (float32, error) {
+	th := v.Threshold
+	if th == 0 {
+		th = 0.02
+	}
+	var sum float64
+	for _, x := range chunk {
+		sum += float64(x) * float64(x)
+	}
+	if float32(math.Sqrt(sum/float64(len(chunk)))) > th {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Reset implements smartturn.VADBackend.
+func (v *EnergyVAD) Reset() {}
+
+// Close implements smartturn.VADBackend.
+func (v *EnergyVAD) Close() error { return nil }
+
+// TurnScript is a smartturn.TurnBackend that returns Probabilities in order,
+// repeating the last one, and counts its calls.
+type TurnScript struct {
+	Probabilities []float32
+	Calls         int
+}
+
+var _ smartturn.TurnBackend = (*TurnScript)(nil)
+
+// Predict implements smartturn.TurnBackend.
+func (b *TurnScript) Predict([]float32) (float32, error) {
+	b.Calls++
+	if len(b.Probabilities) == 0 {
+		return 1, nil
+	}
+	return b.Probabilities[min(b.Calls, len(b.Probabilities))-1], nil
+}
+
+// Close implements smartturn.TurnBackend.
+func (b *TurnScript) Close() error { return nil }
@@ -0,0 +1,58 @@
+package smartturntest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+)
+
+// Clock is a manual smartturn.Clock for deterministic tests. Time moves only
+// through Advance and Set, and by Step on every Now call when Step is set,
+// so measured latencies become fixed values:
+//
+//	clk := smartturntest.NewClock(time.Unix(0, 0))
+//	clk.SetStep(time.Millisecond) // every measured call takes 1ms
+//	cfg.Clock = clk
+type Clock struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+var _ smartturn.Clock = (*Clock)(nil)
+
+// NewClock returns a Clock stopped at start.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now returns the current time, then advances it by the step.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.now
+	c.now = c.now.Add(c.step)
+	return t
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// Set moves the clock to t.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	c.now = t
+	c.mu.Unlock()
+}
+
+// SetStep makes every Now call advance the clock by d after reading it.
+func (c *Clock) SetStep(d time.Duration) {
+	c.mu.Lock()
+	c.step = d
+	c.mu.Unlock()
+}
@@ -0,0 +1,255 @@
+// Package smartturntest provides a scriptable fake smartturn.Detector, so
+// applications can unit-test their conversation logic without models or
+// ONNX Runtime:
+//
+//	fake := smartturntest.NewFake(callbacks)
+//	fake.At(10, fake.EmitSpeechStart)
+//	fake.At(40, func() { fake.EmitTurn(0.93, true) })
+//	app := NewAgent(fake) // takes a smartturn.Detector
+//
+// Events fire synchronously on the goroutine that calls PushPCM or an Emit
+// method, as they do with a real engine.
+//
+// To exercise a real engine without models instead, Synth generates speech
+// and silence with known boundaries, EnergyVAD detects them, and TurnScript
+// plays back Smart-Turn probabilities.
+package smartturntest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+)
+
+// Fake is a smartturn.Detector that emits callback events when told to,
+// either directly (Emit*) or when a given chunk is pushed (At). It enforces
+// the engine's chunk size and lifecycle rules and counts what it receives.
+// Its methods may be called from any goroutine.
+type Fake struct {
+	cb smartturn.Callbacks
+
+	mu        sync.Mutex
+	listening bool
+	closed    bool
+	pushed    int
+	dropped   int
+	resets    int
+	turns     int
+	script    map[int][]func()
+
+	// PushErr, when set, is returned by PushPCM for accepted chunks.
+	PushErr error
+	// ChunkSize is the chunk length PushPCM accepts; 0 means
+	// smartturn.RequiredChunkSize. Set smartturn.TelephonyChunkSize to
+	// stand in for an 8 kHz engine.
+	ChunkSize int
+}
+
+var _ smartturn.Detector = (*Fake)(nil)
+
+// NewFake returns a fake that delivers events to cb.
+func NewFake(cb smartturn.Callbacks) *Fake {
+	return &Fake{cb: cb, script: make(map[int][]func())}
+}
+
+// At runs fn when the n-th chunk (counting from 1) is accepted by PushPCM,
+// after OnChunk. Several functions for the same chunk run in order.
+func (f *Fake) At(n int, fn func()) {
+	f.mu.Lock()
+	f.script[n] = append(f.script[n], fn)
+	f.mu.Unlock()
+}
+
+// Start starts listening and calls OnListeningStarted.
+func (f *Fake) Start() {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return
+	}
+	f.listening = true
+	f.mu.Unlock()
+	if f.cb.OnListeningStarted != nil {
+		f.cb.OnListeningStarted()
+	}
+}
+
+// Stop stops listening and calls OnListeningStopped.
+func (f *Fake) Stop() {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return
+	}
+	f.listening = false
+	f.mu.Unlock()
+	if f.cb.OnListeningStopped != nil {
+		f.cb.OnListeningStopped()
+	}
+}
+
+// Reset counts the call.
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	f.resets++
+	f.mu.Unlock()
+}
+
+// PushPCM accepts a chunk while listening, calls OnChunk, and runs the
+// functions scheduled for it. Like the engine it returns ErrChunkSize for
+// chunks that are not ChunkSize samples and ErrClosed after Close.
+func (f *Fake) PushPCM(chunk []float32) error {
+	f.mu.Lock()
+	if f.closed {
+		f.dropped++
+		f.mu.Unlock()
+		return smartturn.ErrClosed
+	}
+	size := f.ChunkSize
+	if size == 0 {
+		size = smartturn.RequiredChunkSize
+	}
+	if len(chunk) != size {
+		f.dropped++
+		f.mu.Unlock()
+		return smartturn.ErrChunkSize
+	}
+	if !f.listening {
+		f.dropped++
+		f.mu.Unlock()
+		return nil
+	}
+	f.pushed++
+	fns := f.script[f.pushed]
+	delete(f.script, f.pushed)
+	err := f.PushErr
+	f.mu.Unlock()
+
+	if f.cb.OnChunk != nil {
+		f.cb.OnChunk(chunk)
+	}
+	for _, fn := range fns {
+		fn()
+	}
+	return err
+}
+
+// Close stops the fake; later PushPCM calls fail with ErrClosed.
+func (f *Fake) Close() {
+	f.mu.Lock()
+	f.closed = true
+	f.listening = false
+	f.mu.Unlock()
+}
+
+// Health reports the fake's state and counters.
+func (f *Fake) Health() smartturn.Health {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return smartturn.Health{
+		ModelsLoaded:    !f.closed,
+		Listening:       f.listening,
+		ChunksProcessed: uint64(f.pushed),
+		DroppedChunks:   uint64(f.dropped),
+	}
+}
+
+// Pushed returns the number of chunks accepted by PushPCM.
+func (f *Fake) Pushed() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pushed
+}
+
+// Listening reports whether the fake was started and not stopped.
+func (f *Fake) Listening() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.listening
+}
+
+// Resets returns the number of Reset calls.
+func (f *Fake) Resets() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.resets
+}
+
+// Closed reports whether Close was called.
+func (f *Fake) Closed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+// EmitSpeechStart starts a turn the way the engine does: OnTurnStart with
+// the next ID (from 0), then OnSpeechStart.
+func (f *Fake) EmitSpeechStart() {
+	f.mu.Lock()
+	t := smartturn.TurnStart{ID: f.turns}
+	f.turns++
+	f.mu.Unlock()
+	if f.cb.OnTurnStart != nil {
+		f.cb.OnTurnStart(t)
+	}
+	if f.cb.OnSpeechStart != nil {
+		f.cb.OnSpeechStart()
+	}
+}
+
+// EmitSpeechEnd calls OnSpeechEnd.
+func (f *Fake) EmitSpeechEnd() {
+	if f.cb.OnSpeechEnd != nil {
+		f.cb.OnSpeechEnd()
+	}
+}
+
+// EmitTurnEnd ends a turn for reason: OnTurnEnd, then OnSpeechEnd.
+func (f *Fake) EmitTurnEnd(reason smartturn.TurnEndReason) {
+	if f.cb.OnTurnEnd != nil {
+		f.cb.OnTurnEnd(reason)
+	}
+	f.EmitSpeechEnd()
+}
+
+// EmitSegment calls OnSegmentReady with segment, and OnSegment with a copy.
+func (f *Fake) EmitSegment(segment []float32) {
+	if f.cb.OnSegmentReady != nil {
+		f.cb.OnSegmentReady(segment)
+	}
+	if f.cb.OnSegment != nil {
+		f.cb.OnSegment(&smartturn.Segment{Samples: append([]float32(nil), segment...)})
+	}
+}
+
+// EmitPrediction calls OnTurnPrediction and OnTurnPredictionDetail with p.
+func (f *Fake) EmitPrediction(p smartturn.TurnPrediction) {
+	if f.cb.OnTurnPrediction != nil {
+		f.cb.OnTurnPrediction(p.Complete, p.Probability)
+	}
+	if f.cb.OnTurnPredictionDetail != nil {
+		f.cb.OnTurnPredictionDetail(p)
+	}
+}
+
+// EmitTurn ends a segment the way the engine does: OnTurnPrediction with
+// probability, then OnTurnEnd and OnSpeechEnd when endOfTurn is true.
+func (f *Fake) EmitTurn(probability float32, endOfTurn bool) {
+	f.EmitPrediction(smartturn.TurnPrediction{
+		Complete:              probability > 0.5,
+		Probability:           probability,
+		InferenceDuration:     10 * time.Millisecond,
+		SilenceBeforeDecision: 800 * time.Millisecond,
+	})
+	if endOfTurn {
+		f.EmitTurnEnd(smartturn.TurnEndModel)
+	}
+}
+
+// EmitError calls OnError with err.
+func (f *Fake) EmitError(err error) {
+	if f.cb.OnError != nil {
+		f.cb.OnError(err)
+	}
+}
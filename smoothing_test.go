@@ -0,0 +1,70 @@
+package smartturn_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// TestTurnSmoothing checks that TurnSmoothing averages the evaluations of
+// one turn, decides on the average, and starts afresh with the next turn.
+func TestTurnSmoothing(t *testing.T) {
+	// Turn 0 is judged incomplete, resumes and is judged again; turn 1
+	// follows after its end.
+	audio, _ := smartturntest.Synth{Seed: 8}.Generate(
+		smartturntest.Speech(time.Second), smartturntest.Silence(500*time.Millisecond),
+		smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond),
+		smartturntest.Speech(time.Second), smartturntest.Silence(2*time.Second))
+	for _, tc := range []struct {
+		smoothing float32
+		prob      []float32 // decided on
+		ends      []smartturn.TurnEndReason
+	}{
+		{0, []float32{0.2, 0.9, 0.9}, []smartturn.TurnEndReason{smartturn.TurnEndModel, smartturn.TurnEndModel}},
+		{0.5, []float32{0.2, 0.55, 0.9}, []smartturn.TurnEndReason{smartturn.TurnEndModel, smartturn.TurnEndModel}},
+		// 0.8*0.2 + 0.2*0.9 = 0.34 leaves turn 0 pending, and turn 1's
+		// speech continues it: 0.8*0.34 + 0.2*0.9 = 0.452.
+		{0.8, []float32{0.2, 0.34, 0.452}, []smartturn.TurnEndReason{smartturn.TurnEndTimeout}},
+	} {
+		var preds []smartturn.TurnPrediction
+		var ends []smartturn.TurnEndReason
+		e := newTestEngine(t, []float32{0.2, 0.9}, smartturn.Callbacks{
+			OnTurnPredictionDetail: func(p smartturn.TurnPrediction) { preds = append(preds, p) },
+			OnTurnEnd:              func(r smartturn.TurnEndReason) { ends = append(ends, r) },
+		}, func(cfg *smartturn.Config) { cfg.TurnSmoothing = tc.smoothing })
+		pushAll(t, e, audio)
+
+		if len(preds) != len(tc.prob) {
+			t.Fatalf("smoothing %v: %d predictions, want %d", tc.smoothing, len(preds), len(tc.prob))
+		}
+		for i, p := range preds {
+			instant := []float32{0.2, 0.9, 0.9}[i]
+			if math.Abs(float64(p.Probability-tc.prob[i])) > 1e-6 || p.Instant != instant || p.Complete != (tc.prob[i] > 0.5) {
+				t.Errorf("smoothing %v, prediction %d: %+v, want probability %v, instant %v", tc.smoothing, i, p, tc.prob[i], instant)
+			}
+		}
+		if len(ends) != len(tc.ends) {
+			t.Fatalf("smoothing %v: turn ends %v, want %v", tc.smoothing, ends, tc.ends)
+		}
+		for i := range ends {
+			if ends[i] != tc.ends[i] {
+				t.Errorf("smoothing %v: turn ends %v, want %v", tc.smoothing, ends, tc.ends)
+			}
+		}
+	}
+}
+
+func TestTurnSmoothingValidate(t *testing.T) {
+	for _, s := range []float32{-0.1, 1, 1.5} {
+		cfg := benchConfig()
+		cfg.VADBackend = &smartturntest.EnergyVAD{}
+		cfg.TurnBackend = &smartturntest.TurnScript{}
+		cfg.TurnSmoothing = s
+		if err := smartturn.ValidateConfig(cfg); err == nil {
+			t.Errorf("TurnSmoothing %v accepted", s)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package smartturn
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+)
+
+// SpeakerTracker labels who is speaking on a mixed channel (Config.Speakers),
+// such as caller and agent sharing one telephony line. It is called from the
+// engine's goroutine with each chunk VAD scored as speech. Plug in a speaker
+// embedding model, or use PitchSpeakerTracker.
+type SpeakerTracker interface {
+	// Speaker returns the label (0, 1, ...) of the speaker after this
+	// 512-sample 16 kHz chunk (upsampled at 8 kHz), or -1 while it cannot
+	// tell yet. Labels should be smoothed: each change splits the turn.
+	Speaker(chunk []float32) (int, error)
+	// Reset forgets the speakers seen so far (called by Engine.Reset).
+	Reset()
+}
+
+// SpeakerChange is passed to OnSpeakerChange.
+type SpeakerChange struct {
+	// From is the previous label, -1 for the first speaker identified.
+	From, To int
+	// Offset is the sample offset, in the audio accepted since New, of the
+	// chunk where the tracker reported the change. Detection lags the
+	// actual change by the tracker's hold time.
+	Offset int64
+}
+
+// changeSpeaker reports a new label from Config.Speakers. When it replaces
+// a known speaker the current turn ends, so the new speaker's speech starts
+// a turn of its own; res is updated to describe that new segment.
+func (e *Engine) changeSpeaker(to int, offset int64, res *segmentResult) {
+	c := SpeakerChange{From: e.speaker, To: to, Offset: offset}
+	e.speaker = to
+	if e.logs(slog.LevelInfo) {
+		e.log.Info("speaker changed", "from", c.From, "to", c.To)
+	}
+	e.record(Event{Kind: EventSpeakerChange, Speaker: c})
+	if e.cb.OnSpeakerChange != nil {
+		e.cb.OnSpeakerChange(c)
+	}
+	// A segment ending at max duration on this chunk ends or splits anyway.
+	if c.From < 0 || res.Ended {
+		return
+	}
+	if res.Started {
+		// Speech resumed: a pending turn was the previous speaker's, and
+		// it is not merged with the new one.
+		if e.turnPending {
+			e.endTurn(TurnEndSpeakerChange)
+		}
+		e.sinceTurnEnd = -1
+		return
+	}
+	ended, next := e.segmenter.restart()
+	if e.cb.OnSegmentReady != nil || e.cb.OnSegment != nil || e.collecting || e.cfg.Transcriber != nil || e.exporter != nil {
+		if len(ended) > e.segmentEmittedSoFar {
+			segStart := offset - int64(len(ended))
+			e.emitSegment(ended[e.segmentEmittedSoFar:], segStart+int64(e.segmentEmittedSoFar))
+		}
+	}
+	if e.cfg.Observer != nil {
+		e.cfg.Observer.SegmentEnded(len(ended), false)
+	}
+	e.endTurn(TurnEndSpeakerChange)
+	e.sinceTurnEnd = -1
+	res.Started = true
+	res.Segment = next
+}
+
+// Defaults for PitchSpeakerTracker.
+const (
+	DefaultSpeakerMaxSpeakers  = 2
+	DefaultSpeakerMinSemitones = 4.0
+	DefaultSpeakerHoldMs       = 320
+)
+
+// Pitch search range and the rate at which a speaker's pitch follows the
+// voiced chunks attributed to it.
+const (
+	pitchMinHz        = 60
+	pitchMaxHz        = 400
+	pitchMinCorr      = 0.5
+	speakerAdaptation = 0.05
+)
+
+// PitchSpeakerTracker is a lightweight SpeakerTracker that tells speakers
+// apart by voice pitch. It separates voices of clearly different pitch,
+// typically a male and a female speaker; similar voices need an embedding
+// model behind SpeakerTracker.
+type PitchSpeakerTracker struct {
+	// MaxSpeakers caps the labels handed out (default 2, caller and agent).
+	MaxSpeakers int
+	// MinSemitones is the distance from every known speaker's pitch at
+	// which a voice counts as a new speaker (default 4).
+	MinSemitones float64
+	// HoldMs is the voiced audio a different speaker must hold before the
+	// label changes (default 320).
+	HoldMs int
+
+	holdChunks int
+	pitches    []float64 // per label, in semitones above 100 Hz
+	label      int
+	pending    int // label a run of chunks votes for; len(pitches) for a new one
+	run        int
+	runSum     float64
+}
+
+// NewPitchSpeakerTracker returns t with defaults applied to zero fields.
+func NewPitchSpeakerTracker(t PitchSpeakerTracker) (*PitchSpeakerTracker, error) {
+	if t.MaxSpeakers < 0 || t.MinSemitones < 0 || t.HoldMs < 0 {
+		return nil, errors.New("smart-turn: PitchSpeakerTracker: MaxSpeakers, MinSemitones and HoldMs must be >= 0")
+	}
+	if t.MaxSpeakers == 0 {
+		t.MaxSpeakers = DefaultSpeakerMaxSpeakers
+	}
+	if t.MinSemitones == 0 {
+		t.MinSemitones = DefaultSpeakerMinSemitones
+	}
+	if t.HoldMs == 0 {
+		t.HoldMs = DefaultSpeakerHoldMs
+	}
+	t.holdChunks = max(ceilDiv(t.HoldMs, 32), 1)
+	t.pitches = make([]float64, 0, t.MaxSpeakers)
+	t.Reset()
+	return &t, nil
+}
+
+// Speaker implements SpeakerTracker.
+func (t *PitchSpeakerTracker) Speaker(chunk []float32) (int, error) {
+	if len(chunk) != RequiredChunkSize {
+		return t.label, errChunkSize
+	}
+	hz, ok := pitch(chunk)
+	if !ok {
+		return t.label, nil
+	}
+	p := 12 * math.Log2(hz/100)
+	vote := t.nearest(p)
+	if vote == t.label {
+		t.pitches[vote] += speakerAdaptation * (p - t.pitches[vote])
+		t.run = 0
+		return t.label, nil
+	}
+	if vote != t.pending || t.run == 0 {
+		t.pending, t.run, t.runSum = vote, 0, 0
+	}
+	t.run++
+	t.runSum += p
+	if t.run < t.holdChunks {
+		return t.label, nil
+	}
+	if t.pending == len(t.pitches) {
+		t.pitches = append(t.pitches, t.runSum/float64(t.run))
+	}
+	t.label = t.pending
+	t.run = 0
+	return t.label, nil
+}
+
+// nearest returns the label whose pitch is closest to p, or len(t.pitches)
+// when p is far from all of them and another label is available.
+func (t *PitchSpeakerTracker) nearest(p float64) int {
+	best, dist := len(t.pitches), math.Inf(1)
+	for i, q := range t.pitches {
+		if d := math.Abs(p - q); d < dist {
+			best, dist = i, d
+		}
+	}
+	if dist > t.MinSemitones && len(t.pitches) < t.MaxSpeakers {
+		return len(t.pitches)
+	}
+	return best
+}
+
+// Reset implements SpeakerTracker.
+func (t *PitchSpeakerTracker) Reset() {
+	t.pitches = t.pitches[:0]
+	t.label = -1
+	t.pending, t.run, t.runSum = -1, 0, 0
+}
+
+// pitch estimates the fundamental frequency of a chunk by normalized
+// autocorrelation, taking the shortest lag close to the best one so
+// subharmonics are not picked; ok is false for unvoiced audio.
+func pitch(chunk []float32) (hz float64, ok bool) {
+	var mean float64
+	for _, v := range chunk {
+		mean += float64(v)
+	}
+	mean /= float64(len(chunk))
+	minLag := RequiredSampleRate / pitchMaxHz
+	maxLag := RequiredSampleRate / pitchMinHz
+	var corr [RequiredSampleRate/pitchMinHz + 1]float64
+	best := 0.0
+	for lag := minLag; lag <= maxLag; lag++ {
+		var xy, xx, yy float64
+		for i := 0; i+lag < len(chunk); i++ {
+			x := float64(chunk[i]) - mean
+			y := float64(chunk[i+lag]) - mean
+			xy += x * y
+			xx += x * x
+			yy += y * y
+		}
+		if xx > 0 && yy > 0 {
+			corr[lag] = xy / math.Sqrt(xx*yy)
+		}
+		best = math.Max(best, corr[lag])
+	}
+	if best < pitchMinCorr {
+		return 0, false
+	}
+	for lag := minLag; lag <= maxLag; lag++ {
+		// Only a local maximum counts, not the slope leading to one.
+		if corr[lag] >= 0.9*best && (lag == maxLag || corr[lag] >= corr[lag+1]) {
+			return float64(RequiredSampleRate) / float64(lag), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,255 @@
+package smartturn_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// voice returns d of a voiced tone at f0 Hz with decaying harmonics.
+func voice(f0 float64, d time.Duration) []float32 {
+	return synthesize(d, func(t float64) float64 {
+		var v float64
+		for h := 1.0; h <= 6; h++ {
+			v += math.Sin(2*math.Pi*h*f0*t) / h
+		}
+		return 0.2 * v
+	})
+}
+
+// labels runs tr over audio and returns its label after each chunk.
+func labels(t *testing.T, tr *smartturn.PitchSpeakerTracker, audio []float32) []int {
+	t.Helper()
+	var out []int
+	for _, c := range smartturntest.Chunks(audio) {
+		l, err := tr.Speaker(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+// changes returns the chunk indexes where labels change, and the labels.
+func changes(labels []int) (at, to []int) {
+	prev := -1
+	for i, l := range labels {
+		if l != prev {
+			at, to = append(at, i), append(to, l)
+			prev = l
+		}
+	}
+	return at, to
+}
+
+func TestPitchSpeakerTracker(t *testing.T) {
+	// HoldMs 320 is 10 chunks of voiced audio.
+	for _, tc := range []struct {
+		a, b float64 // Hz
+	}{
+		{110, 210},
+		{180, 230},
+	} {
+		tr, err := smartturn.NewPitchSpeakerTracker(smartturn.PitchSpeakerTracker{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var audio []float32
+		for _, f0 := range []float64{tc.a, tc.b, tc.a} {
+			audio = append(audio, voice(f0, 32*32*time.Millisecond)...)
+		}
+		at, to := changes(labels(t, tr, audio))
+		if fmt.Sprint(at, to) != "[9 41 73] [0 1 0]" {
+			t.Errorf("%v/%v Hz: labels change at chunks %v to %v, want [9 41 73] to [0 1 0]", tc.a, tc.b, at, to)
+		}
+	}
+}
+
+func TestPitchSpeakerTrackerSameSpeaker(t *testing.T) {
+	tr, _ := smartturn.NewPitchSpeakerTracker(smartturn.PitchSpeakerTracker{})
+	// 3 semitones apart, under MinSemitones.
+	audio := append(voice(110, time.Second), voice(110*math.Pow(2, 3.0/12), time.Second)...)
+	if _, to := changes(labels(t, tr, audio)); fmt.Sprint(to) != "[0]" {
+		t.Errorf("labels %v for voices 3 semitones apart, want [0]", to)
+	}
+
+	// Silence and noise keep the current label.
+	tr.Reset()
+	audio = append(voice(110, time.Second), make([]float32, 16000)...)
+	audio = append(audio, synthesize(time.Second, func(t float64) float64 { return 0.1 * math.Sin(1e6*t*t) })...)
+	if _, to := changes(labels(t, tr, audio)); fmt.Sprint(to) != "[0]" {
+		t.Errorf("labels %v through silence and a chirp, want [0]", to)
+	}
+
+	// MaxSpeakers caps the labels.
+	tr.Reset()
+	audio = nil
+	for _, f0 := range []float64{100, 160, 250} {
+		audio = append(audio, voice(f0, time.Second)...)
+	}
+	if _, to := changes(labels(t, tr, audio)); fmt.Sprint(to) != "[0 1]" {
+		t.Errorf("labels %v for three voices with MaxSpeakers 2, want [0 1]", to)
+	}
+}
+
+func TestNewPitchSpeakerTracker(t *testing.T) {
+	tr, err := smartturn.NewPitchSpeakerTracker(smartturn.PitchSpeakerTracker{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.MaxSpeakers != smartturn.DefaultSpeakerMaxSpeakers || tr.MinSemitones != smartturn.DefaultSpeakerMinSemitones || tr.HoldMs != smartturn.DefaultSpeakerHoldMs {
+		t.Errorf("defaults %v, %v, %v", tr.MaxSpeakers, tr.MinSemitones, tr.HoldMs)
+	}
+	for _, bad := range []smartturn.PitchSpeakerTracker{{MaxSpeakers: -1}, {MinSemitones: -1}, {HoldMs: -1}} {
+		if _, err := smartturn.NewPitchSpeakerTracker(bad); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+	if l, err := tr.Speaker(make([]float32, 100)); err == nil || l != -1 {
+		t.Errorf("Speaker of a 100-sample chunk = %d, %v", l, err)
+	}
+	chunk := smartturntest.Chunks(voice(150, 32*time.Millisecond))[0]
+	if allocs := testing.AllocsPerRun(100, func() { _, _ = tr.Speaker(chunk) }); allocs != 0 {
+		t.Errorf("Speaker allocates %v times per chunk", allocs)
+	}
+}
+
+// speakerScript is a SpeakerTracker whose label for the n-th speech chunk
+// is label(n).
+type speakerScript struct {
+	label  func(n int) int
+	err    error
+	calls  int
+	resets int
+}
+
+func (s *speakerScript) Speaker([]float32) (int, error) {
+	s.calls++
+	return s.label(s.calls - 1), s.err
+}
+
+func (s *speakerScript) Reset() { s.resets++ }
+
+// speakerEngine returns an engine tracking speakers with tr that logs
+// its callbacks to got.
+func speakerEngine(t *testing.T, probs []float32, tr smartturn.SpeakerTracker, got *[]string) *smartturn.Engine {
+	return newTestEngine(t, probs, smartturn.Callbacks{
+		OnSpeechStart: func() { *got = append(*got, "start") },
+		OnSpeechEnd:   func() { *got = append(*got, "end") },
+		OnTurnEnd:     func(r smartturn.TurnEndReason) { *got = append(*got, r.String()) },
+		OnSpeakerChange: func(c smartturn.SpeakerChange) {
+			*got = append(*got, fmt.Sprintf("%d>%d", c.From, c.To))
+		},
+		OnError: func(err error) { *got = append(*got, "error") },
+	}, func(cfg *smartturn.Config) { cfg.Speakers = tr })
+}
+
+// TestSpeakerChange checks that a change of speaker mid-speech ends the
+// turn and starts one for the new speaker.
+func TestSpeakerChange(t *testing.T) {
+	var got []string
+	tr := &speakerScript{label: func(n int) int {
+		switch {
+		case n < 3:
+			return -1
+		case n < 31:
+			return 0
+		}
+		return 1
+	}}
+	e := speakerEngine(t, []float32{0.9}, tr, &got)
+	audio, _ := smartturntest.Synth{Seed: 8}.Generate(smartturntest.Speech(2*time.Second), smartturntest.Silence(600*time.Millisecond))
+	pushAll(t, e, audio)
+	if s, want := strings.Join(got, " "), "start -1>0 0>1 speaker_change end start model end"; s != want {
+		t.Errorf("callbacks %q, want %q", s, want)
+	}
+	e.Reset()
+	if tr.resets != 1 {
+		t.Errorf("%d tracker resets after Reset, want 1", tr.resets)
+	}
+}
+
+// TestSpeakerChangePending checks that a pending turn ends when another
+// speaker resumes, instead of continuing.
+func TestSpeakerChangePending(t *testing.T) {
+	var got []string
+	speech := 31 // chunks of the first second
+	tr := &speakerScript{label: func(n int) int {
+		if n < speech {
+			return 0
+		}
+		return 1
+	}}
+	e := speakerEngine(t, []float32{0.1, 0.9}, tr, &got)
+	audio, _ := smartturntest.Synth{Seed: 8}.Generate(
+		smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond),
+		smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	pushAll(t, e, audio)
+	if s, want := strings.Join(got, " "), "-1>0 start 0>1 speaker_change end start model end"; s != want {
+		t.Errorf("callbacks %q, want %q", s, want)
+	}
+
+	// The same speaker resuming continues the pending turn.
+	got = nil
+	tr = &speakerScript{label: func(int) int { return 0 }}
+	e = speakerEngine(t, []float32{0.1, 0.9}, tr, &got)
+	pushAll(t, e, audio)
+	if s, want := strings.Join(got, " "), "-1>0 start model end"; s != want {
+		t.Errorf("same speaker: callbacks %q, want %q", s, want)
+	}
+}
+
+func TestSpeakerChangeErrors(t *testing.T) {
+	var got []string
+	tr := &speakerScript{label: func(int) int { return 1 }, err: errors.New("tracker failed")}
+	e := speakerEngine(t, []float32{0.9}, tr, &got)
+	audio, _ := smartturntest.Synth{Seed: 8}.Generate(smartturntest.Speech(96*time.Millisecond), smartturntest.Silence(600*time.Millisecond))
+	pushAll(t, e, audio)
+	if s := strings.Join(got, " "); s != "error start error error model end" {
+		t.Errorf("callbacks %q: a failing tracker keeps the label", s)
+	}
+}
+
+// TestSpeakerChangeProcess checks the event order at a change: the
+// change, the tail of the previous speaker's segment, the end of their
+// turn, then the new speaker's speech start.
+func TestSpeakerChangeProcess(t *testing.T) {
+	tr := &speakerScript{label: func(n int) int { return min(n/31, 1) }}
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{}, func(cfg *smartturn.Config) {
+		cfg.Speakers = tr
+		cfg.TurnSegmentEmitMs = 10000
+	})
+	audio, _ := smartturntest.Synth{Seed: 8}.Generate(smartturntest.Speech(2*time.Second), smartturntest.Silence(600*time.Millisecond))
+	names := map[smartturn.EventKind]string{
+		smartturn.EventSpeechStart:   "start",
+		smartturn.EventSpeechEnd:     "end",
+		smartturn.EventSegmentReady:  "segment",
+		smartturn.EventSpeakerChange: "change",
+	}
+	var got []string
+	for _, c := range smartturntest.Chunks(audio) {
+		events, err := e.Process(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ev := range events {
+			if name, ok := names[ev.Kind]; ok {
+				if ev.Kind == smartturn.EventSpeechEnd {
+					name += ":" + ev.EndReason.String()
+				}
+				got = append(got, name)
+			}
+		}
+	}
+	want := "change start change segment end:speaker_change start segment end:model"
+	if s := strings.Join(got, " "); s != want {
+		t.Errorf("events %q, want %q", s, want)
+	}
+}
@@ -0,0 +1,56 @@
+package smartturn
+
+import "math"
+
+// upsampleTaps is the length of the interpolation filter for the odd
+// output samples; the even ones are the input samples themselves.
+const upsampleTaps = 32
+
+// upsampleKernel is a Blackman-windowed sinc sampled halfway between input
+// samples: a half-band lowpass that keeps the images of the 0-3.4 kHz
+// telephone band at least 55 dB down.
+var upsampleKernel = func() (k [upsampleTaps]float32) {
+	var sum float64
+	var h [upsampleTaps]float64
+	for i := range h {
+		x := float64(i) - (upsampleTaps-1)/2.0 // offset in input samples
+		r := math.Pi * x / (upsampleTaps / 2)  // window zero at ±taps/2
+		w := 0.42 + 0.5*math.Cos(r) + 0.08*math.Cos(2*r)
+		h[i] = math.Sin(math.Pi*x) / (math.Pi * x) * w
+		sum += h[i]
+	}
+	for i := range h {
+		k[i] = float32(h[i] / sum)
+	}
+	return k
+}()
+
+// upsampler doubles the rate of the 8 kHz chunks of telephony mode
+// (Config.SampleRate = TelephonySampleRate) for the 16 kHz pipeline. Its
+// output lags the input by upsampleTaps/2 input samples (2 ms).
+type upsampler struct {
+	buf [upsampleTaps - 1 + TelephonyChunkSize]float32 // history, then chunk
+}
+
+// process writes the 2·len(in) samples at twice the rate of in to out and
+// returns them.
+func (u *upsampler) process(in, out []float32) []float32 {
+	const hist = upsampleTaps - 1
+	copy(u.buf[hist:], in)
+	for i := range in {
+		x := u.buf[i : i+upsampleTaps]
+		var odd float32
+		for k, h := range upsampleKernel {
+			odd += h * x[k]
+		}
+		out[2*i] = x[upsampleTaps/2-1]
+		out[2*i+1] = odd
+	}
+	copy(u.buf[:hist], u.buf[len(in):len(in)+hist])
+	return out[:2*len(in)]
+}
+
+// reset clears the filter history.
+func (u *upsampler) reset() {
+	clear(u.buf[:])
+}
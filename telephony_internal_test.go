@@ -0,0 +1,98 @@
+package smartturn
+
+import (
+	"math"
+	"testing"
+)
+
+// upsample runs in through a fresh upsampler chunk by chunk.
+func upsample(in []float32) []float32 {
+	var u upsampler
+	var out []float32
+	wide := make([]float32, 2*TelephonyChunkSize)
+	for ; len(in) >= TelephonyChunkSize; in = in[TelephonyChunkSize:] {
+		out = append(out, u.process(in[:TelephonyChunkSize], wide)...)
+	}
+	return out
+}
+
+// level returns the amplitude of the f Hz component of x at rate.
+func level(x []float32, f, rate float64) float64 {
+	var re, im float64
+	for i, v := range x {
+		w := 2 * math.Pi * f * float64(i) / rate
+		re += float64(v) * math.Cos(w)
+		im -= float64(v) * math.Sin(w)
+	}
+	return 2 * math.Hypot(re, im) / float64(len(x))
+}
+
+// TestUpsampler checks that the even outputs are the input 16 samples
+// (2 ms) late, a constant passes unchanged, and the images of tones in
+// the telephone band are at least 55 dB down.
+func TestUpsampler(t *testing.T) {
+	in := make([]float32, 8*TelephonyChunkSize)
+	for i := range in {
+		in[i] = float32(math.Sin(float64(i)*0.37) + 0.2*math.Cos(float64(i)*1.9))
+	}
+	out := upsample(in)
+	if len(out) != 2*len(in) {
+		t.Fatalf("%d samples out for %d in", len(out), len(in))
+	}
+	for i := range in {
+		want := float32(0)
+		if i >= upsampleTaps/2 {
+			want = in[i-upsampleTaps/2]
+		}
+		if out[2*i] != want {
+			t.Fatalf("out[%d] = %v, want in[%d] = %v", 2*i, out[2*i], i-upsampleTaps/2, want)
+		}
+	}
+
+	for i := range in {
+		in[i] = 0.5
+	}
+	for i, v := range upsample(in)[2*upsampleTaps:] {
+		if math.Abs(float64(v)-0.5) > 1e-5 {
+			t.Fatalf("constant 0.5 upsampled to %v at %d", v, i+2*upsampleTaps)
+		}
+	}
+
+	const seconds = 1
+	in = make([]float32, seconds*TelephonySampleRate)
+	for _, f := range []float64{300, 1000, 2000, 3000, 3400} {
+		for i := range in {
+			in[i] = float32(0.5 * math.Sin(2*math.Pi*f*float64(i)/TelephonySampleRate))
+		}
+		// Past the filter's start-up, and whole cycles of both tones.
+		out := upsample(in)[RequiredSampleRate/100:]
+		tone := level(out, f, RequiredSampleRate)
+		image := level(out, TelephonySampleRate-f, RequiredSampleRate)
+		if db := 20 * math.Log10(image/tone); math.Abs(tone-0.5) > 0.01 || db > -55 {
+			t.Errorf("%v Hz: amplitude %.3f, image %.1f dB", f, tone, db)
+		}
+	}
+}
+
+// TestUpsamplerReset checks that reset clears the history, so the next
+// chunk is upsampled as by a new upsampler.
+func TestUpsamplerReset(t *testing.T) {
+	chunk := make([]float32, TelephonyChunkSize)
+	for i := range chunk {
+		chunk[i] = float32(math.Sin(float64(i) * 0.2))
+	}
+	var u upsampler
+	wide := make([]float32, 2*TelephonyChunkSize)
+	first := append([]float32(nil), u.process(chunk, wide)...)
+	u.process(chunk, wide)
+	u.reset()
+	again := u.process(chunk, wide)
+	for i := range first {
+		if again[i] != first[i] {
+			t.Fatalf("sample %d after reset = %v, want %v", i, again[i], first[i])
+		}
+	}
+	if allocs := testing.AllocsPerRun(100, func() { u.process(chunk, wide) }); allocs != 0 {
+		t.Errorf("process allocates %v times per chunk", allocs)
+	}
+}
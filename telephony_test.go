@@ -0,0 +1,156 @@
+package smartturn_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+	"github.com/cortexswarm/smart-turn-go/webrtcvad"
+)
+
+// telephony returns a config tweak for 8 kHz input.
+func telephony(cfg *smartturn.Config) {
+	cfg.SampleRate = smartturn.TelephonySampleRate
+	cfg.ChunkSize = smartturn.TelephonyChunkSize
+}
+
+// decimate halves the rate of 16 kHz audio by averaging sample pairs.
+func decimate(audio []float32) []float32 {
+	out := make([]float32, len(audio)/2)
+	for i := range out {
+		out[i] = (audio[2*i] + audio[2*i+1]) / 2
+	}
+	return out
+}
+
+// timeline pushes audio to e in chunks of size and returns the speech
+// boundaries and segment lengths, each with the chunk it came in.
+func timeline(t *testing.T, tweak func(*smartturn.Config), size int, audio []float32) string {
+	t.Helper()
+	var got []string
+	n := 0
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+		OnSpeechStart:  func() { got = append(got, fmt.Sprintf("start@%d", n)) },
+		OnSpeechEnd:    func() { got = append(got, fmt.Sprintf("end@%d", n)) },
+		OnSegmentReady: func(s []float32) { got = append(got, fmt.Sprintf("segment:%d@%d", len(s), n)) },
+		OnError:        func(err error) { t.Error(err) },
+	}, tweak)
+	for ; (n+1)*size <= len(audio); n++ {
+		if err := e.PushPCM(audio[n*size : (n+1)*size]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return strings.Join(got, " ")
+}
+
+// TestTelephony checks that 8 kHz input gives the speech boundaries and
+// 16 kHz segment lengths of the same audio at 16 kHz.
+func TestTelephony(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 11}.Generate(
+		smartturntest.Silence(500*time.Millisecond), smartturntest.Speech(time.Second),
+		smartturntest.Silence(600*time.Millisecond), smartturntest.Speech(1500*time.Millisecond),
+		smartturntest.Silence(600*time.Millisecond))
+	want := timeline(t, nil, smartturn.RequiredChunkSize, audio)
+	if strings.Count(want, "start") != 2 {
+		t.Fatalf("16 kHz: %s, want two turns", want)
+	}
+	narrow := decimate(audio)
+	if got := timeline(t, telephony, smartturn.TelephonyChunkSize, narrow); got != want {
+		t.Errorf("8 kHz: %s\nwant %s", got, want)
+	}
+	adaptive := func(cfg *smartturn.Config) {
+		telephony(cfg)
+		cfg.VADBackend, _ = smartturn.NewAdaptiveEnergyVAD(smartturn.AdaptiveEnergyVAD{})
+	}
+	if got := timeline(t, adaptive, smartturn.TelephonyChunkSize, narrow); strings.Count(got, "start") != 2 {
+		t.Errorf("8 kHz with AdaptiveEnergyVAD: %s, want two turns", got)
+	}
+	webrtc := func(cfg *smartturn.Config) {
+		telephony(cfg)
+		cfg.VADBackend = nil
+		cfg.VADEngine = smartturn.VADWebRTC
+		cfg.WebRTCVADMode = webrtcvad.Aggressive
+	}
+	if got := timeline(t, webrtc, smartturn.TelephonyChunkSize, narrow); strings.Count(got, "start") != 2 {
+		t.Errorf("8 kHz with WebRTC VAD: %s, want two turns", got)
+	}
+}
+
+func TestTelephonyChunkSize(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		tweak func(*smartturn.Config)
+		bad   int
+	}{
+		{"8 kHz", telephony, smartturn.RequiredChunkSize},
+		{"16 kHz", nil, smartturn.TelephonyChunkSize},
+		{"8 kHz queued", func(cfg *smartturn.Config) {
+			telephony(cfg)
+			cfg.InputQueue.Size = 4
+		}, smartturn.RequiredChunkSize},
+	} {
+		e := newTestEngine(t, nil, smartturn.Callbacks{}, tc.tweak)
+		if err := e.PushPCM(make([]float32, tc.bad)); !errors.Is(err, smartturn.ErrChunkSize) {
+			t.Errorf("%s: %d-sample chunk: error %v, want ErrChunkSize", tc.name, tc.bad, err)
+		}
+	}
+
+	f := smartturntest.NewFake(smartturn.Callbacks{})
+	f.ChunkSize = smartturn.TelephonyChunkSize
+	f.Start()
+	if err := f.PushPCM(make([]float32, smartturn.TelephonyChunkSize)); err != nil || f.Pushed() != 1 {
+		t.Errorf("Fake with TelephonyChunkSize: error %v, %d pushed", err, f.Pushed())
+	}
+	if err := f.PushPCM(make([]float32, smartturn.RequiredChunkSize)); !errors.Is(err, smartturn.ErrChunkSize) {
+		t.Errorf("Fake with TelephonyChunkSize took a 512-sample chunk: %v", err)
+	}
+}
+
+func TestTelephonyValidate(t *testing.T) {
+	for _, tc := range []struct {
+		rate, size int
+		ok         bool
+	}{
+		{smartturn.TelephonySampleRate, smartturn.TelephonyChunkSize, true},
+		{smartturn.TelephonySampleRate, smartturn.RequiredChunkSize, false},
+		{smartturn.RequiredSampleRate, smartturn.TelephonyChunkSize, false},
+		{44100, 1411, false},
+	} {
+		cfg := benchConfig()
+		cfg.VADBackend = &smartturntest.EnergyVAD{}
+		cfg.TurnBackend = &smartturntest.TurnScript{}
+		cfg.SampleRate, cfg.ChunkSize = tc.rate, tc.size
+		if err := smartturn.ValidateConfig(cfg); (err == nil) != tc.ok {
+			t.Errorf("%d Hz, %d samples: error %v", tc.rate, tc.size, err)
+		}
+	}
+	cfg := smartturn.ProfileTelephony()
+	cfg.VADBackend = &smartturntest.EnergyVAD{}
+	cfg.TurnBackend = &smartturntest.TurnScript{}
+	if err := smartturn.ValidateConfig(cfg); err != nil {
+		t.Errorf("ProfileTelephony: %v", err)
+	}
+}
+
+// TestTelephonyAllocs checks that a whole 8 kHz turn, once the engine
+// has seen one, is processed without allocating.
+func TestTelephonyAllocs(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 12}.Generate(smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	audio = decimate(audio)
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{}, telephony)
+	turn := func() {
+		for c := audio; len(c) >= smartturn.TelephonyChunkSize; c = c[smartturn.TelephonyChunkSize:] {
+			if err := e.PushPCM(c[:smartturn.TelephonyChunkSize]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	turn()
+	if allocs := testing.AllocsPerRun(5, turn); allocs != 0 {
+		t.Errorf("%v allocations per turn", allocs)
+	}
+}
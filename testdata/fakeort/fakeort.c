@@ -0,0 +1,228 @@
+// fakeort stands in for the ONNX Runtime shared library in the tests of
+// the native entry points onnxruntime_go does not wrap. It implements just
+// enough of OrtApi for onnxruntime_go to initialize, create session
+// options, tensors and sessions, and run them; a run leaves the outputs as
+// they were, and a session has no inputs or outputs to inspect. It appends
+// a line per call to the file named by $FAKEORT_LOG. The entry named by
+// $FAKEORT_FAIL returns an error status.
+//
+// It is built against onnxruntime_go's copy of onnxruntime_c_api.h, so the
+// entries land at the header's indices.
+
+#include <stdarg.h>
+#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#include "onnxruntime_c_api.h"
+
+struct OrtStatus {
+	char message[128];
+};
+
+struct OrtSessionOptions {
+	int id;
+};
+
+struct OrtSession {
+	int id;
+};
+
+static int lastOptions, lastSession;
+static int env, memoryInfo, value;
+
+static void record(const char *format, ...) {
+	const char *path = getenv("FAKEORT_LOG");
+	if (path == NULL) {
+		return;
+	}
+	FILE *f = fopen(path, "a");
+	if (f == NULL) {
+		return;
+	}
+	va_list args;
+	va_start(args, format);
+	vfprintf(f, format, args);
+	va_end(args);
+	fputc('\n', f);
+	fclose(f);
+}
+
+// result returns the status of entry fn: an error when $FAKEORT_FAIL
+// names it.
+static OrtStatus *result(const char *fn) {
+	const char *fail = getenv("FAKEORT_FAIL");
+	if (fail == NULL || strcmp(fail, fn) != 0) {
+		return NULL;
+	}
+	OrtStatus *s = calloc(1, sizeof *s);
+	snprintf(s->message, sizeof s->message, "fake %s failed", fn);
+	return s;
+}
+
+static OrtStatus *unimplemented(void) {
+	return result("");
+}
+
+static const char *getErrorMessage(const OrtStatus *s) {
+	return s->message;
+}
+
+static void releaseStatus(OrtStatus *s) {
+	record("ReleaseStatus %s", s->message);
+	free(s);
+}
+
+static OrtStatus *createEnv(OrtLoggingLevel level, const char *logid, OrtEnv **out) {
+	*out = (OrtEnv *)&env;
+	return NULL;
+}
+
+static void releaseEnv(OrtEnv *e) {}
+
+static OrtStatus *createCpuMemoryInfo(enum OrtAllocatorType type, enum OrtMemType memType, OrtMemoryInfo **out) {
+	*out = (OrtMemoryInfo *)&memoryInfo;
+	return NULL;
+}
+
+static void releaseMemoryInfo(OrtMemoryInfo *m) {}
+
+static OrtStatus *createSessionOptions(OrtSessionOptions **out) {
+	OrtSessionOptions *o = calloc(1, sizeof *o);
+	o->id = ++lastOptions;
+	record("CreateSessionOptions %d", o->id);
+	*out = o;
+	return NULL;
+}
+
+static void releaseSessionOptions(OrtSessionOptions *o) {
+	record("ReleaseSessionOptions %d", o->id);
+	free(o);
+}
+
+static OrtStatus *addSessionConfigEntry(OrtSessionOptions *o, const char *key, const char *value) {
+	record("AddSessionConfigEntry %d %s=%s", o->id, key, value);
+	return result("AddSessionConfigEntry");
+}
+
+static OrtStatus *setSessionLogSeverityLevel(OrtSessionOptions *o, int level) {
+	record("SetSessionLogSeverityLevel %d %d", o->id, level);
+	return result("SetSessionLogSeverityLevel");
+}
+
+static OrtStatus *enableProfiling(OrtSessionOptions *o, const ORTCHAR_T *prefix) {
+	record("EnableProfiling %d %s", o->id, prefix);
+	return result("EnableProfiling");
+}
+
+static OrtStatus *appendExecutionProvider(OrtSessionOptions *o, const char *name,
+		const char *const *keys, const char *const *values, size_t n) {
+	char line[512];
+	int len = snprintf(line, sizeof line, "SessionOptionsAppendExecutionProvider %d %s", o->id, name);
+	for (size_t i = 0; i < n && len < (int)sizeof line; i++) {
+		len += snprintf(line + len, sizeof line - len, " %s=%s", keys[i], values[i]);
+	}
+	record("%s", line);
+	return result("SessionOptionsAppendExecutionProvider");
+}
+
+static OrtStatus *appendROCM(OrtSessionOptions *o, const OrtROCMProviderOptions *r) {
+	record("SessionOptionsAppendExecutionProvider_ROCM %d device_id=%d miopen_conv_exhaustive_search=%d "
+		"gpu_mem_limit=%zu arena_extend_strategy=%d do_copy_in_default_stream=%d has_user_compute_stream=%d "
+		"user_compute_stream=%d default_memory_arena_cfg=%d enable_hip_graph=%d tunable_op_enable=%d "
+		"tunable_op_tuning_enable=%d tunable_op_max_tuning_duration_ms=%d",
+		o->id, r->device_id, r->miopen_conv_exhaustive_search, r->gpu_mem_limit, r->arena_extend_strategy,
+		r->do_copy_in_default_stream, r->has_user_compute_stream, r->user_compute_stream != NULL,
+		r->default_memory_arena_cfg != NULL, r->enable_hip_graph, r->tunable_op_enable,
+		r->tunable_op_tuning_enable, r->tunable_op_max_tuning_duration_ms);
+	return result("SessionOptionsAppendExecutionProvider_ROCM");
+}
+
+static OrtStatus *createTensorWithData(const OrtMemoryInfo *info, void *data, size_t size,
+		const int64_t *shape, size_t rank, ONNXTensorElementDataType type, OrtValue **out) {
+	*out = (OrtValue *)&value;
+	return NULL;
+}
+
+static void releaseValue(OrtValue *v) {}
+
+static OrtStatus *createSession(const OrtEnv *e, const ORTCHAR_T *path, const OrtSessionOptions *o, OrtSession **out) {
+	record("CreateSession %s", path);
+	OrtStatus *status = result("CreateSession");
+	if (status == NULL) {
+		OrtSession *s = calloc(1, sizeof *s);
+		s->id = ++lastSession;
+		*out = s;
+	}
+	return status;
+}
+
+static void releaseSession(OrtSession *s) {
+	record("ReleaseSession %d", s->id);
+	free(s);
+}
+
+static OrtStatus *run(OrtSession *s, const OrtRunOptions *r, const char *const *inputNames,
+		const OrtValue *const *inputs, size_t nInputs, const char *const *outputNames, size_t nOutputs,
+		OrtValue **outputs) {
+	record("Run %d", s->id);
+	return result("Run");
+}
+
+static OrtStatus *sessionGetCount(const OrtSession *s, size_t *out) {
+	*out = 0;
+	return NULL;
+}
+
+static OrtApi api;
+
+static const OrtApi *getApi(uint32_t version) {
+	// $FAKEORT_API_VERSION, if set, stands in for an older runtime's.
+	uint32_t newest = ORT_API_VERSION;
+	const char *v = getenv("FAKEORT_API_VERSION");
+	if (v != NULL) {
+		newest = (uint32_t)atoi(v);
+	}
+	if (version > newest) {
+		return NULL;
+	}
+	if (api.GetErrorMessage == NULL) {
+		void **entries = (void **)&api;
+		for (size_t i = 0; i < sizeof api / sizeof *entries; i++) {
+			entries[i] = (void *)unimplemented;
+		}
+		api.GetErrorMessage = getErrorMessage;
+		api.ReleaseStatus = releaseStatus;
+		api.CreateEnv = createEnv;
+		api.ReleaseEnv = releaseEnv;
+		api.CreateCpuMemoryInfo = createCpuMemoryInfo;
+		api.ReleaseMemoryInfo = releaseMemoryInfo;
+		api.CreateSessionOptions = createSessionOptions;
+		api.ReleaseSessionOptions = releaseSessionOptions;
+		api.AddSessionConfigEntry = addSessionConfigEntry;
+		api.SetSessionLogSeverityLevel = setSessionLogSeverityLevel;
+		api.EnableProfiling = enableProfiling;
+		api.SessionOptionsAppendExecutionProvider = appendExecutionProvider;
+		api.SessionOptionsAppendExecutionProvider_ROCM = appendROCM;
+		api.CreateTensorWithDataAsOrtValue = createTensorWithData;
+		api.ReleaseValue = releaseValue;
+		api.CreateSession = createSession;
+		api.ReleaseSession = releaseSession;
+		api.Run = run;
+		api.SessionGetInputCount = sessionGetCount;
+		api.SessionGetOutputCount = sessionGetCount;
+	}
+	return &api;
+}
+
+static const char *getVersionString(void) {
+	const char *v = getenv("FAKEORT_VERSION");
+	return v != NULL ? v : "1.23.2";
+}
+
+static const OrtApiBase base = {getApi, getVersionString};
+
+const OrtApiBase *OrtGetApiBase(void) {
+	return &base;
+}
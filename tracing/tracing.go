@@ -0,0 +1,193 @@
+// Package tracing records OpenTelemetry spans for turn detection, so the
+// latency of end-of-turn decisions shows up inside a voice agent's existing
+// distributed traces.
+//
+// Each user turn becomes a "smartturn.turn" span, from the first speech
+// segment to OnSpeechEnd (decision or timeout). Its children are one
+// "smartturn.segment" span per VAD speech segment, and one
+// "smartturn.inference" span per Smart-Turn call, carrying the probability
+// and the decision. A Tracer follows one engine:
+//
+//	tr := tracing.New(otel.GetTracerProvider(), callCtx)
+//	cfg.Observer = tr // or smartturn.Observers(metricsCollector, tr)
+//	engine, err := smartturn.New(cfg, tr.Wrap(callbacks))
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope of the spans.
+const ScopeName = "github.com/cortexswarm/smart-turn-go"
+
+// Tracer is a smartturn.Observer that turns engine events into spans. Use
+// one Tracer per engine. Its methods may be called from the engine's
+// goroutine while SetParent/TurnContext are called from others.
+type Tracer struct {
+	tracer trace.Tracer
+
+	mu      sync.Mutex
+	parent  context.Context
+	turn    trace.Span
+	turnCtx context.Context
+	segment trace.Span
+	nSegs   int
+}
+
+var _ smartturn.Observer = (*Tracer)(nil)
+
+// New returns a Tracer that creates spans with tp under parent (e.g. the
+// context of the call or session the engine serves).
+func New(tp trace.TracerProvider, parent context.Context) *Tracer {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return &Tracer{tracer: tp.Tracer(ScopeName), parent: parent}
+}
+
+// SetParent changes the context new turns are started under.
+func (t *Tracer) SetParent(ctx context.Context) {
+	t.mu.Lock()
+	t.parent = ctx
+	t.mu.Unlock()
+}
+
+// TurnContext returns a context carrying the current turn span (or the
+// parent when no turn is open), to nest application spans under the turn.
+func (t *Tracer) TurnContext() context.Context {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.turnCtx != nil {
+		return t.turnCtx
+	}
+	return t.parent
+}
+
+// Wrap returns callbacks that close the turn span on OnSpeechEnd and record
+// errors on it, then call cb.
+func (t *Tracer) Wrap(cb smartturn.Callbacks) smartturn.Callbacks {
+	onEnd, onErr := cb.OnSpeechEnd, cb.OnError
+	cb.OnSpeechEnd = func() {
+		t.endTurn()
+		if onEnd != nil {
+			onEnd()
+		}
+	}
+	cb.OnError = func(err error) {
+		t.recordError(err)
+		if onErr != nil {
+			onErr(err)
+		}
+	}
+	onStopped := cb.OnListeningStopped
+	cb.OnListeningStopped = func() {
+		t.abandonTurn("listening stopped")
+		if onStopped != nil {
+			onStopped()
+		}
+	}
+	return cb
+}
+
+// VADInference implements smartturn.Observer; per-chunk VAD is not traced.
+func (t *Tracer) VADInference(time.Duration, float32, error) {}
+
+// SegmentStarted implements smartturn.Observer.
+func (t *Tracer) SegmentStarted() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.turn == nil {
+		t.turnCtx, t.turn = t.tracer.Start(t.parent, "smartturn.turn")
+		t.nSegs = 0
+	}
+	if t.segment != nil {
+		// The engine was Reset mid-segment.
+		t.segment.End()
+	}
+	t.nSegs++
+	_, t.segment = t.tracer.Start(t.turnCtx, "smartturn.segment",
+		trace.WithAttributes(attribute.Int("smartturn.segment.index", t.nSegs)))
+}
+
+// SegmentEnded implements smartturn.Observer.
+func (t *Tracer) SegmentEnded(samples int, bySilence bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.segment == nil {
+		return
+	}
+	end := "silence"
+	if !bySilence {
+		end = "max_duration"
+	}
+	t.segment.SetAttributes(
+		attribute.Float64("smartturn.segment.duration_s", float64(samples)/smartturn.RequiredSampleRate),
+		attribute.String("smartturn.segment.end", end),
+	)
+	t.segment.End()
+	t.segment = nil
+}
+
+// TurnInference implements smartturn.Observer. The span is recorded after
+// the fact with its measured start time.
+func (t *Tracer) TurnInference(d time.Duration, prob float32, endOfTurn bool, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ctx := t.parent
+	if t.turnCtx != nil {
+		ctx = t.turnCtx
+	}
+	now := time.Now()
+	_, span := t.tracer.Start(ctx, "smartturn.inference", trace.WithTimestamp(now.Add(-d)))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(
+			attribute.Float64("smartturn.probability", float64(prob)),
+			attribute.Bool("smartturn.end_of_turn", endOfTurn),
+		)
+	}
+	span.End(trace.WithTimestamp(now))
+}
+
+func (t *Tracer) endTurn() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.turn == nil {
+		return
+	}
+	t.turn.SetAttributes(attribute.Int("smartturn.turn.segments", t.nSegs))
+	t.turn.End()
+	t.turn, t.turnCtx = nil, nil
+}
+
+// abandonTurn ends open spans when the engine stops mid-turn.
+func (t *Tracer) abandonTurn(reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.segment != nil {
+		t.segment.End()
+		t.segment = nil
+	}
+	if t.turn != nil {
+		t.turn.SetAttributes(attribute.String("smartturn.turn.abandoned", reason))
+		t.turn.End()
+		t.turn, t.turnCtx = nil, nil
+	}
+}
+
+func (t *Tracer) recordError(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.turn != nil {
+		t.turn.RecordError(err)
+	}
+}
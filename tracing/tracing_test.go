@@ -0,0 +1,259 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+	"github.com/cortexswarm/smart-turn-go/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+)
+
+// memProvider is a TracerProvider that keeps every span in memory, in the
+// order the spans end, like the SDK's tracetest.InMemoryExporter.
+type memProvider struct {
+	embedded.TracerProvider
+
+	mu     sync.Mutex
+	nextID uint64
+	ended  []*memSpan
+	scope  string
+}
+
+// memSpan is a span of memProvider.
+type memSpan struct {
+	embedded.Span
+	p *memProvider
+
+	name       string
+	sc, parent trace.SpanContext
+	start, end time.Time
+	attrs      map[attribute.Key]attribute.Value
+	errs       []error
+	status     codes.Code
+}
+
+// memTracer is the Tracer of a memProvider.
+type memTracer struct {
+	embedded.Tracer
+	p *memProvider
+}
+
+func (p *memProvider) Tracer(name string, _ ...trace.TracerOption) trace.Tracer {
+	p.mu.Lock()
+	p.scope = name
+	p.mu.Unlock()
+	return memTracer{p: p}
+}
+
+func (tr memTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	p, cfg := tr.p, trace.NewSpanStartConfig(opts...)
+	p.mu.Lock()
+	p.nextID++
+	id := p.nextID
+	p.mu.Unlock()
+	parent := trace.SpanContextFromContext(ctx)
+	var sid trace.SpanID
+	copy(sid[:], fmt.Sprintf("%08d", id))
+	tid := parent.TraceID()
+	if !tid.IsValid() {
+		copy(tid[:], fmt.Sprintf("%016d", id))
+	}
+	s := &memSpan{
+		p:      p,
+		name:   name,
+		sc:     trace.NewSpanContext(trace.SpanContextConfig{TraceID: tid, SpanID: sid}),
+		parent: parent,
+		start:  cfg.Timestamp(),
+		attrs:  make(map[attribute.Key]attribute.Value),
+	}
+	if s.start.IsZero() {
+		s.start = time.Now()
+	}
+	s.SetAttributes(cfg.Attributes()...)
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+// spans returns the ended spans.
+func (p *memProvider) spans() []*memSpan {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*memSpan(nil), p.ended...)
+}
+
+func (s *memSpan) End(opts ...trace.SpanEndOption) {
+	cfg := trace.NewSpanEndConfig(opts...)
+	s.end = cfg.Timestamp()
+	if s.end.IsZero() {
+		s.end = time.Now()
+	}
+	s.p.mu.Lock()
+	s.p.ended = append(s.p.ended, s)
+	s.p.mu.Unlock()
+}
+
+func (s *memSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, a := range kv {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *memSpan) RecordError(err error, _ ...trace.EventOption) { s.errs = append(s.errs, err) }
+func (s *memSpan) SetStatus(code codes.Code, _ string)           { s.status = code }
+func (s *memSpan) SpanContext() trace.SpanContext                { return s.sc }
+func (s *memSpan) IsRecording() bool                             { return s.end.IsZero() }
+func (s *memSpan) SetName(name string)                           { s.name = name }
+func (s *memSpan) AddEvent(string, ...trace.EventOption)         {}
+func (s *memSpan) AddLink(trace.Link)                            {}
+func (s *memSpan) TracerProvider() trace.TracerProvider          { return s.p }
+
+// failTurn is a Smart-Turn backend whose calls fail.
+type failTurn struct{ err error }
+
+func (b failTurn) Predict([]float32) (float32, error) { return 0, b.err }
+func (b failTurn) Close() error                       { return nil }
+
+// traceEngine runs audio through an engine traced by a Tracer on a
+// memProvider under a parent span, and returns the provider and the
+// parent's context.
+func traceEngine(t *testing.T, turn smartturn.TurnBackend, audio []float32) (*memProvider, trace.SpanContext) {
+	t.Helper()
+	p := &memProvider{}
+	ctx, parent := p.Tracer("test").Start(context.Background(), "call")
+	tr := tracing.New(p, ctx)
+	cfg := smartturn.ProfileConversational()
+	cfg.VadStopMs = 300
+	cfg.TurnTimeoutMs = 1000
+	cfg.VADBackend = &smartturntest.EnergyVAD{}
+	cfg.TurnBackend = turn
+	cfg.Observer = tr
+	e, err := smartturn.New(cfg, tr.Wrap(smartturn.Callbacks{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	e.Start()
+	for _, c := range smartturntest.Chunks(audio) {
+		if err := e.PushPCM(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p.scope != tracing.ScopeName {
+		t.Errorf("scope %q, want %q", p.scope, tracing.ScopeName)
+	}
+	return p, parent.SpanContext()
+}
+
+func checkAttrs(t *testing.T, s *memSpan, want map[attribute.Key]attribute.Value) {
+	t.Helper()
+	if len(s.attrs) != len(want) {
+		t.Errorf("%s attributes %v, want %v", s.name, s.attrs, want)
+		return
+	}
+	for k, v := range want {
+		if s.attrs[k] != v {
+			t.Errorf("%s %s = %v, want %v", s.name, k, s.attrs[k].Emit(), v.Emit())
+		}
+	}
+}
+
+// TestTracer checks the spans of a turn of two segments, the first judged
+// incomplete: a turn span under the parent, holding a segment span and an
+// inference span per segment, each with its attributes.
+func TestTracer(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 3}.Generate(
+		smartturntest.Silence(500*time.Millisecond), smartturntest.Speech(time.Second), smartturntest.Silence(500*time.Millisecond),
+		smartturntest.Speech(700*time.Millisecond), smartturntest.Silence(1500*time.Millisecond))
+	p, parent := traceEngine(t, &smartturntest.TurnScript{Probabilities: []float32{0.2, 0.9}}, audio)
+
+	spans := p.spans()
+	var names []string
+	for _, s := range spans {
+		names = append(names, s.name)
+	}
+	// Spans are listed as they end.
+	want := []string{
+		"smartturn.segment", "smartturn.inference",
+		"smartturn.segment", "smartturn.inference",
+		"smartturn.turn",
+	}
+	if fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Fatalf("spans %q, want %q", names, want)
+	}
+	turn := spans[4]
+	if !turn.parent.Equal(parent) {
+		t.Errorf("turn parent %v, want the call span %v", turn.parent.SpanID(), parent.SpanID())
+	}
+	checkAttrs(t, turn, map[attribute.Key]attribute.Value{
+		"smartturn.turn.segments": attribute.IntValue(2),
+	})
+	for i, prob := range []float32{0.2, 0.9} {
+		seg, inf := spans[2*i], spans[2*i+1]
+		for _, s := range []*memSpan{seg, inf} {
+			if !s.parent.Equal(turn.sc) {
+				t.Errorf("%s %d parent %v, want the turn", s.name, i, s.parent.SpanID())
+			}
+			if s.start.Before(turn.start) || s.end.After(turn.end) || s.end.Before(s.start) {
+				t.Errorf("%s %d runs %v-%v, outside the turn", s.name, i, s.start, s.end)
+			}
+		}
+		// A segment runs from VadPreSpeechMs before the speech to VadStopMs
+		// after it, give or take a chunk.
+		speech := []float64{1, 0.7}[i]
+		d := seg.attrs["smartturn.segment.duration_s"].AsFloat64()
+		if lo := speech + 0.5 - 0.064; d < lo || d > lo+0.128 {
+			t.Errorf("segment %d lasts %.3f s, want about %.3f", i, d, speech+0.5)
+		}
+		checkAttrs(t, seg, map[attribute.Key]attribute.Value{
+			"smartturn.segment.index":      attribute.IntValue(i + 1),
+			"smartturn.segment.duration_s": attribute.Float64Value(d),
+			"smartturn.segment.end":        attribute.StringValue("silence"),
+		})
+		checkAttrs(t, inf, map[attribute.Key]attribute.Value{
+			"smartturn.probability": attribute.Float64Value(float64(prob)),
+			"smartturn.end_of_turn": attribute.BoolValue(prob > 0.5),
+		})
+		if inf.status != codes.Unset || seg.status != codes.Unset {
+			t.Errorf("segment %d status %v, inference %v", i, seg.status, inf.status)
+		}
+	}
+}
+
+// TestTracerInferenceError checks that a failed Smart-Turn call marks its
+// span as an error and records the error on the turn.
+func TestTracerInferenceError(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 4}.Generate(
+		smartturntest.Speech(time.Second), smartturntest.Silence(1500*time.Millisecond))
+	boom := errors.New("boom")
+	p, _ := traceEngine(t, failTurn{boom}, audio)
+
+	var inf, turn *memSpan
+	for _, s := range p.spans() {
+		switch s.name {
+		case "smartturn.inference":
+			inf = s
+		case "smartturn.turn":
+			turn = s
+		}
+	}
+	if inf == nil || turn == nil {
+		t.Fatalf("spans %v", p.spans())
+	}
+	if inf.status != codes.Error || len(inf.errs) != 1 || !errors.Is(inf.errs[0], boom) {
+		t.Errorf("inference status %v, errors %v", inf.status, inf.errs)
+	}
+	if _, ok := inf.attrs["smartturn.probability"]; ok {
+		t.Errorf("failed inference has a probability: %v", inf.attrs)
+	}
+	if len(turn.errs) != 1 || !errors.Is(turn.errs[0], boom) {
+		t.Errorf("turn errors %v", turn.errs)
+	}
+}
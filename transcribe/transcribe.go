@@ -0,0 +1,163 @@
+// Package transcribe provides smartturn.Transcriber adapters, so an engine
+// delivers one transcript per turn through OnTranscript:
+//
+//	cfg.Transcriber = &transcribe.HTTP{URL: "http://localhost:8080/inference"}
+//	cb.OnTranscript = func(t smartturn.Transcript) { fmt.Println(t.Text) }
+//
+// Buffered wraps any function that transcribes a whole utterance, such as
+// the whisper.cpp Go bindings; HTTP posts each turn to a transcription
+// server (whisper.cpp server, or an OpenAI-compatible
+// /v1/audio/transcriptions endpoint).
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+)
+
+// Buffered collects a turn's audio and transcribes it in one call to Func
+// at the end of the turn.
+type Buffered struct {
+	// Func transcribes 16 kHz mono audio; the slice is reused afterwards.
+	Func  func(audio []float32) (string, error)
+	audio []float32
+}
+
+// PushSegment implements smartturn.Transcriber.
+func (b *Buffered) PushSegment(audio []float32, _ smartturn.SegmentMeta) error {
+	b.audio = append(b.audio, audio...)
+	return nil
+}
+
+// Finalize implements smartturn.Transcriber.
+func (b *Buffered) Finalize() (string, error) {
+	audio := b.audio
+	b.audio = b.audio[:0]
+	if len(audio) == 0 {
+		return "", nil
+	}
+	return b.Func(audio)
+}
+
+// HTTP transcribes each turn by posting it as a 16-bit WAV file (multipart
+// field "file") to URL and reading "text" from the JSON response. This is
+// the protocol of the whisper.cpp server (/inference) and of
+// OpenAI-compatible /v1/audio/transcriptions endpoints.
+type HTTP struct {
+	URL      string
+	Model    string // sent as "model" when set (required by OpenAI-compatible APIs)
+	Language string // sent as "language" when set
+	APIKey   string // sent as a bearer token when set
+	// Timeout bounds one request (default 30s); the engine waits for it.
+	Timeout time.Duration
+	Client  *http.Client // default http.DefaultClient
+
+	buf Buffered
+}
+
+// PushSegment implements smartturn.Transcriber.
+func (h *HTTP) PushSegment(audio []float32, meta smartturn.SegmentMeta) error {
+	return h.buf.PushSegment(audio, meta)
+}
+
+// Finalize implements smartturn.Transcriber.
+func (h *HTTP) Finalize() (string, error) {
+	h.buf.Func = h.transcribe
+	return h.buf.Finalize()
+}
+
+func (h *HTTP) transcribe(audio []float32) (string, error) {
+	if h.URL == "" {
+		return "", errors.New("transcribe: HTTP.URL is required")
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "turn.wav")
+	if err != nil {
+		return "", err
+	}
+	if err := writeWAV(fw, audio); err != nil {
+		return "", err
+	}
+	fields := [][2]string{{"response_format", "json"}, {"model", h.Model}, {"language", h.Language}}
+	for _, f := range fields {
+		if f[1] == "" {
+			continue
+		}
+		if err := mw.WriteField(f[0], f[1]); err != nil {
+			return "", err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if h.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.APIKey)
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("transcribe: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("transcribe: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	var out struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("transcribe: decoding response: %w", err)
+	}
+	return out.Text, nil
+}
+
+// writeWAV writes audio as a 16 kHz mono 16-bit PCM WAV file.
+func writeWAV(w io.Writer, audio []float32) error {
+	data := make([]byte, 44+2*len(audio))
+	copy(data, "RIFF")
+	binary.LittleEndian.PutUint32(data[4:], uint32(36+2*len(audio)))
+	copy(data[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(data[16:], 16)
+	binary.LittleEndian.PutUint16(data[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(data[22:], 1) // mono
+	binary.LittleEndian.PutUint32(data[24:], smartturn.RequiredSampleRate)
+	binary.LittleEndian.PutUint32(data[28:], 2*smartturn.RequiredSampleRate)
+	binary.LittleEndian.PutUint16(data[32:], 2)
+	binary.LittleEndian.PutUint16(data[34:], 16)
+	copy(data[36:], "data")
+	binary.LittleEndian.PutUint32(data[40:], uint32(2*len(audio)))
+	for i, v := range audio {
+		s := math.Max(-1, math.Min(1, float64(v)))
+		binary.LittleEndian.PutUint16(data[44+2*i:], uint16(int16(math.Round(s*math.MaxInt16))))
+	}
+	_, err := w.Write(data)
+	return err
+}
@@ -0,0 +1,154 @@
+package transcribe
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cortexswarm/smart-turn-go"
+)
+
+func TestBuffered(t *testing.T) {
+	var got [][]float32
+	b := &Buffered{Func: func(audio []float32) (string, error) {
+		got = append(got, slices.Clone(audio))
+		return "ok", nil
+	}}
+	_ = b.PushSegment([]float32{1, 2}, smartturn.SegmentMeta{})
+	_ = b.PushSegment([]float32{3}, smartturn.SegmentMeta{Offset: 2})
+	if text, err := b.Finalize(); text != "ok" || err != nil {
+		t.Fatalf("Finalize() = %q, %v", text, err)
+	}
+	// Nothing pushed: no call.
+	if text, err := b.Finalize(); text != "" || err != nil {
+		t.Fatalf("empty Finalize() = %q, %v", text, err)
+	}
+	_ = b.PushSegment([]float32{4}, smartturn.SegmentMeta{})
+	_, _ = b.Finalize()
+	if len(got) != 2 || !slices.Equal(got[0], []float32{1, 2, 3}) || !slices.Equal(got[1], []float32{4}) {
+		t.Errorf("Func got %v, want [[1 2 3] [4]]", got)
+	}
+}
+
+// whisperServer answers like the whisper.cpp server and records the form
+// and audio of each request.
+type whisperServer struct {
+	t      *testing.T
+	hs     *httptest.Server
+	form   map[string]string
+	auth   string
+	audio  []int16
+	status int
+	body   string
+}
+
+func newWhisperServer(t *testing.T) *whisperServer {
+	s := &whisperServer{t: t, status: http.StatusOK, body: `{"text":" hello there"}`}
+	s.hs = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("ParseMultipartForm: %v", err)
+		}
+		s.auth = r.Header.Get("Authorization")
+		s.form = map[string]string{}
+		for k, v := range r.MultipartForm.Value {
+			s.form[k] = v[0]
+		}
+		f, h, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("FormFile: %v", err)
+		} else {
+			defer f.Close()
+			if h.Filename != "turn.wav" {
+				t.Errorf("file name %q", h.Filename)
+			}
+			s.audio = readWAV(t, f)
+		}
+		w.WriteHeader(s.status)
+		_, _ = io.WriteString(w, s.body)
+	}))
+	t.Cleanup(s.hs.Close)
+	return s
+}
+
+// readWAV checks the header of a 16 kHz mono 16-bit WAV file and returns
+// its samples.
+func readWAV(t *testing.T, r io.Reader) []int16 {
+	t.Helper()
+	b, err := io.ReadAll(r)
+	if err != nil || len(b) < 44 {
+		t.Fatalf("WAV of %d bytes: %v", len(b), err)
+	}
+	le := binary.LittleEndian
+	n := int(le.Uint32(b[40:]))
+	if string(b[:4]) != "RIFF" || string(b[8:16]) != "WAVEfmt " || string(b[36:40]) != "data" ||
+		le.Uint32(b[4:]) != uint32(len(b)-8) || le.Uint16(b[20:]) != 1 || le.Uint16(b[22:]) != 1 ||
+		le.Uint32(b[24:]) != 16000 || le.Uint32(b[28:]) != 32000 || le.Uint16(b[32:]) != 2 || le.Uint16(b[34:]) != 16 ||
+		n != len(b)-44 {
+		t.Fatalf("bad WAV header % x", b[:44])
+	}
+	samples := make([]int16, n/2)
+	for i := range samples {
+		samples[i] = int16(le.Uint16(b[44+2*i:]))
+	}
+	return samples
+}
+
+func TestHTTP(t *testing.T) {
+	s := newWhisperServer(t)
+	h := &HTTP{URL: s.hs.URL, Model: "whisper-1", Language: "en", APIKey: "sk-test"}
+	_ = h.PushSegment([]float32{0, 0.5, -0.5}, smartturn.SegmentMeta{})
+	_ = h.PushSegment([]float32{1, -1, 2, float32(math.Inf(-1))}, smartturn.SegmentMeta{})
+	text, err := h.Finalize()
+	if err != nil || text != " hello there" {
+		t.Fatalf("Finalize() = %q, %v", text, err)
+	}
+	if want := []int16{0, 16384, -16384, 32767, -32767, 32767, -32767}; !slices.Equal(s.audio, want) {
+		t.Errorf("samples %v, want %v", s.audio, want)
+	}
+	if s.form["model"] != "whisper-1" || s.form["language"] != "en" || s.form["response_format"] != "json" || s.auth != "Bearer sk-test" {
+		t.Errorf("form %v, Authorization %q", s.form, s.auth)
+	}
+
+	// Unset fields are not sent; the buffer starts afresh.
+	h = &HTTP{URL: s.hs.URL}
+	_ = h.PushSegment([]float32{0.25}, smartturn.SegmentMeta{})
+	if _, err := h.Finalize(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.form["model"]; ok || s.auth != "" || len(s.form) != 1 || len(s.audio) != 1 {
+		t.Errorf("form %v, Authorization %q, %d samples", s.form, s.auth, len(s.audio))
+	}
+}
+
+func TestHTTPErrors(t *testing.T) {
+	s := newWhisperServer(t)
+	for _, tc := range []struct {
+		name   string
+		h      *HTTP
+		status int
+		body   string
+		want   string
+	}{
+		{"no URL", &HTTP{}, http.StatusOK, "", "URL is required"},
+		{"status", &HTTP{URL: s.hs.URL}, http.StatusBadRequest, "  no audio\n", "400 Bad Request: no audio"},
+		{"not JSON", &HTTP{URL: s.hs.URL}, http.StatusOK, "<html>", "decoding response"},
+		{"unreachable", &HTTP{URL: "http://127.0.0.1:1"}, http.StatusOK, "", "transcribe: "},
+	} {
+		s.status, s.body = tc.status, tc.body
+		_ = tc.h.PushSegment([]float32{0.1}, smartturn.SegmentMeta{})
+		if _, err := tc.h.Finalize(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error %v, want %q", tc.name, err, tc.want)
+		}
+	}
+
+	// A turn without audio sends nothing.
+	h := &HTTP{URL: "http://127.0.0.1:1"}
+	if text, err := h.Finalize(); text != "" || err != nil {
+		t.Errorf("empty turn: %q, %v", text, err)
+	}
+}
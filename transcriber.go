@@ -0,0 +1,91 @@
+package smartturn
+
+// Transcriber turns the audio of each turn into text. With
+// Config.Transcriber set, the engine pushes every segment slice of a turn
+// (the OnSegmentReady audio) and calls Finalize when the turn ends; the
+// result reaches OnTranscript. Both run on the engine's goroutine, so a
+// remote ASR delays the next chunk by its latency; use Config.InputQueue
+// to keep the audio source from stalling. The transcribe package has
+// adapters.
+type Transcriber interface {
+	// PushSegment receives the next audio of the current turn. audio is
+	// an engine buffer, valid only during the call.
+	PushSegment(audio []float32, meta SegmentMeta) error
+	// Finalize returns the transcript of the audio pushed since the last
+	// Finalize and starts a new turn.
+	Finalize() (string, error)
+}
+
+// SegmentMeta locates a segment slice passed to a Transcriber.
+type SegmentMeta struct {
+	Turn   int   // TurnStart.ID of the turn
+	Offset int64 // first sample, in the audio accepted since New
+}
+
+// Transcript is passed to OnTranscript after the turn's OnSpeechEnd.
+type Transcript struct {
+	Turn int
+	Text string
+	// Start and End delimit the turn's audio, [Start, End), in samples of
+	// the audio accepted since New (pre-speech padding included).
+	Start, End int64
+	Reason     TurnEndReason
+}
+
+// pushTurnAudio forwards a segment slice to Config.Transcriber and the
+// turn exporter.
+func (e *Engine) pushTurnAudio(part []float32, offset int64) {
+	if !e.turnAudio {
+		e.turnAudio = true
+		e.turnStart = offset
+	} else if skip := e.turnEnd - offset; skip > 0 {
+		// The overlap of a SplitLongTurns part was pushed already.
+		if skip >= int64(len(part)) {
+			return
+		}
+		part, offset = part[skip:], e.turnEnd
+	}
+	e.turnEnd = offset + int64(len(part))
+	if e.exporter != nil {
+		e.exporter.push(part)
+	}
+	if e.cfg.Transcriber == nil {
+		return
+	}
+	if err := e.cfg.Transcriber.PushSegment(part, SegmentMeta{Turn: e.turnID, Offset: offset}); err != nil {
+		e.reportError("transcriber push failed", err)
+	}
+}
+
+// finishTurnAudio exports the turn's audio and finalizes its transcript
+// and delivers it; with deliver false (Reset) both are discarded.
+func (e *Engine) finishTurnAudio(reason TurnEndReason, deliver bool) {
+	if !e.turnAudio {
+		return
+	}
+	t := Transcript{Turn: e.turnID, Start: e.turnStart, End: e.turnEnd, Reason: reason}
+	e.turnAudio = false
+	if e.exporter != nil {
+		if !deliver {
+			e.exporter.discard()
+		} else if err := e.exporter.write(t.Turn, e.mediaTimeAt(t.Start), e.mediaTimeAt(t.End-1), reason); err != nil {
+			e.reportError("exporting turn audio", err)
+		}
+	}
+	if e.cfg.Transcriber == nil {
+		return
+	}
+	text, err := e.cfg.Transcriber.Finalize()
+	if !deliver {
+		return
+	}
+	if err != nil {
+		e.reportError("transcriber finalize failed", err)
+		return
+	}
+	t.Text = text
+	e.record(Event{Kind: EventTranscript, Transcript: t})
+	if e.cb.OnTranscript != nil {
+		e.cb.OnTranscript(t)
+	}
+}
@@ -0,0 +1,157 @@
+package smartturn_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// turnTranscriber is a Transcriber that keeps the audio pushed for each
+// turn and transcribes it as "turn <id>: <samples>".
+type turnTranscriber struct {
+	audio     []float32
+	metas     []smartturn.SegmentMeta
+	finalized int
+	pushErr   error
+	finalErr  error
+}
+
+func (tr *turnTranscriber) PushSegment(audio []float32, meta smartturn.SegmentMeta) error {
+	tr.audio = append(tr.audio, audio...)
+	tr.metas = append(tr.metas, meta)
+	return tr.pushErr
+}
+
+func (tr *turnTranscriber) Finalize() (string, error) {
+	tr.finalized++
+	text := fmt.Sprintf("turn %d: %d", tr.metas[len(tr.metas)-1].Turn, len(tr.audio))
+	tr.audio, tr.metas = nil, nil
+	return text, tr.finalErr
+}
+
+// TestTranscriber checks that each turn's audio reaches the Transcriber
+// once, in order and contiguous, and that its transcript follows the
+// turn's OnSpeechEnd.
+func TestTranscriber(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 14}.Generate(
+		smartturntest.Silence(300*time.Millisecond), smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond),
+		smartturntest.Speech(1500*time.Millisecond), smartturntest.Silence(600*time.Millisecond))
+	tr := &turnTranscriber{}
+	var calls []string
+	var transcripts []smartturn.Transcript
+	check := func(meta smartturn.SegmentMeta, pushed []float32) {
+		if !slices.Equal(pushed, audio[meta.Offset:meta.Offset+int64(len(pushed))]) {
+			t.Errorf("turn %d: pushed audio at %d is not the input", meta.Turn, meta.Offset)
+		}
+	}
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+		OnSpeechEnd: func() {
+			calls = append(calls, "end")
+			// The turn's pushes are contiguous from its first one.
+			for i, m := range tr.metas {
+				if m.Turn != tr.metas[0].Turn || (i > 0 && m.Offset <= tr.metas[i-1].Offset) {
+					t.Errorf("pushes %+v", tr.metas)
+				}
+			}
+			check(tr.metas[0], tr.audio)
+		},
+		OnTranscript: func(tx smartturn.Transcript) {
+			calls = append(calls, "transcript")
+			transcripts = append(transcripts, tx)
+		},
+	}, func(cfg *smartturn.Config) { cfg.Transcriber = tr })
+	pushAll(t, e, audio)
+
+	if s := strings.Join(calls, " "); s != "end transcript end transcript" {
+		t.Fatalf("callbacks %q", s)
+	}
+	for i, tx := range transcripts {
+		if tx.Turn != i || tx.Reason != smartturn.TurnEndModel || tx.Text != fmt.Sprintf("turn %d: %d", i, tx.End-tx.Start) {
+			t.Errorf("transcript %d: %+v", i, tx)
+		}
+	}
+	// Each turn holds its speech, with the pre-speech padding before it.
+	if s := transcripts[0].Start; s < 300*16-200*16-512 || s > 300*16 {
+		t.Errorf("turn 0 starts at sample %d", s)
+	}
+	if transcripts[1].Start < transcripts[0].End {
+		t.Errorf("turns overlap: %+v", transcripts)
+	}
+}
+
+// TestTranscriberErrors checks that failures are reported through OnError
+// and a failed Finalize delivers no transcript.
+func TestTranscriberErrors(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 15}.Generate(smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	boom := errors.New("asr down")
+	for _, tc := range []struct {
+		name        string
+		tr          *turnTranscriber
+		transcripts int
+	}{
+		{"push", &turnTranscriber{pushErr: boom}, 1},
+		{"finalize", &turnTranscriber{finalErr: boom}, 0},
+	} {
+		var errs []error
+		transcripts := 0
+		e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+			OnError:      func(err error) { errs = append(errs, err) },
+			OnTranscript: func(smartturn.Transcript) { transcripts++ },
+		}, func(cfg *smartturn.Config) { cfg.Transcriber = tc.tr })
+		pushAll(t, e, audio)
+		if len(errs) == 0 || !errors.Is(errs[0], boom) {
+			t.Errorf("%s: errors %v, want %v", tc.name, errs, boom)
+		}
+		if transcripts != tc.transcripts {
+			t.Errorf("%s: %d transcripts, want %d", tc.name, transcripts, tc.transcripts)
+		}
+	}
+}
+
+// TestTranscriberReset checks that Reset finalizes the open turn without
+// delivering it, so its audio does not leak into the next transcript.
+func TestTranscriberReset(t *testing.T) {
+	speech, _ := smartturntest.Synth{Seed: 16}.Generate(smartturntest.Speech(time.Second))
+	turn, _ := smartturntest.Synth{Seed: 17}.Generate(smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	tr := &turnTranscriber{}
+	var transcripts []smartturn.Transcript
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+		OnTranscript: func(tx smartturn.Transcript) { transcripts = append(transcripts, tx) },
+	}, func(cfg *smartturn.Config) { cfg.Transcriber = tr })
+	pushAll(t, e, speech)
+	e.Reset()
+	if tr.finalized != 1 || len(transcripts) != 0 {
+		t.Fatalf("Reset: %d Finalize, %d transcripts; want 1 and 0", tr.finalized, len(transcripts))
+	}
+	pushAll(t, e, turn)
+	if len(transcripts) != 1 || transcripts[0].End-transcripts[0].Start > int64(len(turn)) {
+		t.Errorf("transcripts after Reset %+v", transcripts)
+	}
+}
+
+// TestTranscriberProcess checks that Process returns EventTranscript.
+func TestTranscriberProcess(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 18}.Generate(smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{}, func(cfg *smartturn.Config) { cfg.Transcriber = &turnTranscriber{} })
+	var got []smartturn.Transcript
+	for _, c := range smartturntest.Chunks(audio) {
+		events, err := e.Process(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ev := range events {
+			if ev.Kind == smartturn.EventTranscript {
+				got = append(got, ev.Transcript)
+			}
+		}
+	}
+	if len(got) != 1 || !strings.HasPrefix(got[0].Text, "turn 0: ") {
+		t.Errorf("EventTranscript %+v", got)
+	}
+}
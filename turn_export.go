@@ -0,0 +1,152 @@
+package smartturn
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go/internal/wav"
+)
+
+// DefaultTurnExportName is the TurnExport file name template used when
+// Name is empty.
+const DefaultTurnExportName = "{session}_turn_{turn}_{start}.wav"
+
+// turnExportTime formats the {start} and {end} placeholders.
+const turnExportTime = "20060102T150405.000"
+
+// TurnExport writes the audio of every completed turn to its own WAV file
+// (mono, 16 kHz, 32-bit float), for QA review and building datasets. The
+// audio is that of the turn's segments, OnSegmentReady, from the first
+// segment to the end of the turn, including pre-speech padding; the
+// overlap repeated by SplitLongTurns is written once.
+//
+// Name is a template for the file name in Dir, with the placeholders:
+//   - {session}: SessionID, or the engine start time when empty
+//   - {turn}: TurnStart.ID of the turn, zero-padded to 6 digits
+//   - {start}, {end}: media time (see MediaTime) of the first and last
+//     sample, as 20060102T150405.000
+//   - {reason}: the TurnEndReason
+//
+// Once the files written by the engine exceed MaxFiles or MaxBytes, the
+// oldest are deleted (the newest is always kept). Turns discarded by Reset
+// are not written. Write failures are reported via OnError; turn detection
+// is never affected.
+type TurnExport struct {
+	Dir       string // export is enabled when non-empty; created if missing
+	Name      string // file name template; "" means DefaultTurnExportName
+	SessionID string // {session}; "" means the engine start time
+	MaxFiles  int    // files kept; 0 means no limit
+	MaxBytes  int64  // total size of the files kept; 0 means no limit
+}
+
+// validate checks the fields of an enabled export.
+func (t TurnExport) validate() error {
+	if t.MaxFiles < 0 {
+		return errors.New("config: TurnExport.MaxFiles must be >= 0")
+	}
+	if t.MaxBytes < 0 {
+		return errors.New("config: TurnExport.MaxBytes must be >= 0")
+	}
+	if strings.ContainsAny(t.Name, `/\`) || strings.ContainsAny(t.SessionID, `/\`) {
+		return errors.New("config: TurnExport.Name and SessionID must not contain path separators")
+	}
+	if t.Name != "" && !strings.Contains(t.Name, "{turn}") && !strings.Contains(t.Name, "{start}") {
+		return errors.New("config: TurnExport.Name must contain {turn} or {start} so turns get distinct files")
+	}
+	return nil
+}
+
+// exportedTurn is a file written by a turnExporter, kept for retention.
+type exportedTurn struct {
+	path string
+	size int64
+}
+
+// turnExporter implements TurnExport for one engine.
+type turnExporter struct {
+	cfg     TurnExport
+	session string
+	audio   []float32 // current turn, grown as segments arrive
+	files   []exportedTurn
+	written int64 // size of files
+}
+
+func newTurnExporter(cfg TurnExport, now time.Time) (*turnExporter, error) {
+	if cfg.Name == "" {
+		cfg.Name = DefaultTurnExportName
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("turn export: %w", err)
+	}
+	session := cfg.SessionID
+	if session == "" {
+		session = now.Format("20060102T150405.000000")
+	}
+	return &turnExporter{cfg: cfg, session: session}, nil
+}
+
+// push appends audio of the current turn.
+func (x *turnExporter) push(part []float32) {
+	x.audio = append(x.audio, part...)
+}
+
+// discard drops the current turn.
+func (x *turnExporter) discard() {
+	x.audio = x.audio[:0]
+}
+
+// write saves the current turn as turn number turn, spanning the media
+// times [start, end], then applies retention.
+func (x *turnExporter) write(turn int, start, end time.Time, reason TurnEndReason) error {
+	defer x.discard()
+	name := strings.NewReplacer(
+		"{session}", x.session,
+		"{turn}", fmt.Sprintf("%06d", turn),
+		"{start}", start.Format(turnExportTime),
+		"{end}", end.Format(turnExportTime),
+		"{reason}", reason.String(),
+	).Replace(x.cfg.Name)
+	path := filepath.Join(x.cfg.Dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("turn export: %w", err)
+	}
+	w, err := wav.NewWriter(f, RequiredSampleRate)
+	if err == nil {
+		err = w.Write(x.audio)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return fmt.Errorf("turn export: %w", err)
+	}
+	size := int64(len(x.audio))*4 + wav.HeaderSize
+	x.files = append(x.files, exportedTurn{path: path, size: size})
+	x.written += size
+	return x.retain()
+}
+
+// retain deletes the oldest files until MaxFiles and MaxBytes are met; the
+// newest file is always kept.
+func (x *turnExporter) retain() error {
+	var err error
+	for len(x.files) > 1 && ((x.cfg.MaxFiles > 0 && len(x.files) > x.cfg.MaxFiles) ||
+		(x.cfg.MaxBytes > 0 && x.written > x.cfg.MaxBytes)) {
+		old := x.files[0]
+		x.files = x.files[1:]
+		x.written -= old.size
+		if rerr := os.Remove(old.path); rerr != nil && !errors.Is(rerr, os.ErrNotExist) && err == nil {
+			err = fmt.Errorf("turn export: %w", rerr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,203 @@
+package smartturn_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/internal/wav"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// exportFiles returns the names of the files in dir.
+func exportFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+// turns returns n turns of 1 s of speech, each followed by 600 ms of
+// silence.
+func turns(n int, seed uint64) []float32 {
+	var parts []smartturntest.Part
+	for range n {
+		parts = append(parts, smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	}
+	audio, _ := smartturntest.Synth{Seed: seed}.Generate(parts...)
+	return audio
+}
+
+// speech returns d of speech.
+func speech(d time.Duration) []float32 {
+	audio, _ := smartturntest.Synth{Seed: 18}.Generate(smartturntest.Speech(d))
+	return audio
+}
+
+// TestTurnExport checks each turn's file name and that its audio is the
+// turn's own input, the SplitLongTurns overlap included once.
+func TestTurnExport(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		tweak func(*smartturn.Config)
+		reset []float32 // pushed first, then discarded by Reset
+		audio []float32
+		want  []string
+	}{
+		{"two turns", nil, nil, turns(2, 14), []string{
+			"call-7_000000_20260101T090000.000_20260101T090001.343_model.wav",
+			"call-7_000001_20260101T090001.408_20260101T090002.911_model.wav",
+		}},
+		// Past TurnSegmentEmitMs, so the discarded turn has audio.
+		{"after reset", nil, speech(47 * 32 * time.Millisecond), turns(2, 14), []string{
+			"call-7_000001_20260101T090000.000_20260101T090001.343_model.wav",
+			"call-7_000002_20260101T090001.408_20260101T090002.911_model.wav",
+		}},
+		{"split", func(cfg *smartturn.Config) {
+			cfg.TurnMaxDurationSeconds = 1
+			cfg.SplitLongTurns = true
+			cfg.TurnSplitOverlapMs = 200
+		}, nil, func() []float32 {
+			audio, _ := smartturntest.Synth{Seed: 15}.Generate(smartturntest.Speech(2500*time.Millisecond), smartturntest.Silence(600*time.Millisecond))
+			return audio
+		}(), []string{"call-7_000000_20260101T090000.000_20260101T090002.815_model.wav"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var start int64
+			var starts []int64
+			var errs []error
+			e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+				OnTurnStart: func(s smartturn.TurnStart) { start = s.Offset },
+				OnSpeechEnd: func() { starts = append(starts, start) },
+				OnError:     func(err error) { errs = append(errs, err) },
+			}, func(cfg *smartturn.Config) {
+				cfg.TurnExport = smartturn.TurnExport{Dir: dir, Name: "{session}_{turn}_{start}_{end}_{reason}.wav", SessionID: "call-7"}
+				if tc.tweak != nil {
+					tc.tweak(cfg)
+				}
+			})
+			pushed := slices.Clone(tc.reset)
+			pushAll(t, e, pushed)
+			e.Reset()
+			pushed = append(pushed, tc.audio...)
+			if err := e.PushPCMAt(tc.audio[:smartturn.RequiredChunkSize], time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)); err != nil {
+				t.Fatal(err)
+			}
+			pushAll(t, e, tc.audio[smartturn.RequiredChunkSize:])
+			if got := exportFiles(t, dir); !slices.Equal(got, tc.want) || len(starts) != len(tc.want) || errs != nil {
+				t.Fatalf("files %q, want %q (%d turns, errors %v)", got, tc.want, len(starts), errs)
+			}
+			for i, name := range tc.want {
+				f, err := os.Open(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, rate, err := wav.Read(f)
+				f.Close()
+				if err != nil || rate != smartturn.RequiredSampleRate {
+					t.Fatalf("%s: %d Hz, %v", name, rate, err)
+				}
+				if want := pushed[starts[i]:][:len(got)]; !slices.Equal(got, want) {
+					t.Errorf("%s: audio is not the input from offset %d", name, starts[i])
+				}
+			}
+		})
+	}
+}
+
+// TestTurnExportRetention checks MaxFiles, MaxBytes, the default name and
+// that a turn cut short by Reset is not written.
+func TestTurnExportRetention(t *testing.T) {
+	audio := turns(3, 16)
+	for _, tc := range []struct {
+		name   string
+		export smartturn.TurnExport
+		want   []string
+	}{
+		// The session is the engine's start time on Config.Clock.
+		{"default name", smartturn.TurnExport{}, []string{
+			"20260101T080000.000000_turn_000000_20260101T090000.000.wav",
+			"20260101T080000.000000_turn_000001_20260101T090001.408.wav",
+			"20260101T080000.000000_turn_000002_20260101T090003.008.wav",
+		}},
+		{"max files", smartturn.TurnExport{MaxFiles: 2}, []string{
+			"20260101T080000.000000_turn_000001_20260101T090001.408.wav",
+			"20260101T080000.000000_turn_000002_20260101T090003.008.wav",
+		}},
+		// Turns are about 90 kB.
+		{"max bytes", smartturn.TurnExport{MaxBytes: 200000}, []string{
+			"20260101T080000.000000_turn_000001_20260101T090001.408.wav",
+			"20260101T080000.000000_turn_000002_20260101T090003.008.wav",
+		}},
+		// The newest file is kept whatever its size.
+		{"newest", smartturn.TurnExport{MaxBytes: 1}, []string{
+			"20260101T080000.000000_turn_000002_20260101T090003.008.wav",
+		}},
+	} {
+		dir := t.TempDir()
+		tc.export.Dir = dir
+		e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{}, func(cfg *smartturn.Config) {
+			cfg.Clock = smartturntest.NewClock(time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC))
+			cfg.TurnExport = tc.export
+		})
+		if err := e.PushPCMAt(audio[:smartturn.RequiredChunkSize], time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)); err != nil {
+			t.Fatal(err)
+		}
+		pushAll(t, e, audio[smartturn.RequiredChunkSize:])
+		// Reset mid-turn discards the turn.
+		pushAll(t, e, audio[:len(audio)/6])
+		e.Reset()
+		if got := exportFiles(t, dir); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: files %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTurnExportErrors(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "turns")
+	var errs []error
+	var ends int
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+		OnError:     func(err error) { errs = append(errs, err) },
+		OnSpeechEnd: func() { ends++ },
+	}, func(cfg *smartturn.Config) { cfg.TurnExport.Dir = dir })
+	// The directory is created by New.
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	pushAll(t, e, turns(1, 17))
+	if len(errs) != 1 || ends != 1 {
+		t.Errorf("errors %v and %d speech ends, want one export error and the turn", errs, ends)
+	}
+
+	for _, tc := range []struct {
+		export smartturn.TurnExport
+		ok     bool
+	}{
+		{smartturn.TurnExport{Dir: dir, Name: "{turn}.wav"}, true},
+		{smartturn.TurnExport{Dir: dir, Name: "{start}.wav"}, true},
+		{smartturn.TurnExport{Dir: dir, MaxFiles: -1}, false},
+		{smartturn.TurnExport{Dir: dir, MaxBytes: -1}, false},
+		{smartturn.TurnExport{Dir: dir, Name: "a/{turn}.wav"}, false},
+		{smartturn.TurnExport{Dir: dir, SessionID: `a\b`}, false},
+		{smartturn.TurnExport{Dir: dir, Name: "{session}.wav"}, false},
+	} {
+		cfg := benchConfig()
+		cfg.VADBackend = &smartturntest.EnergyVAD{}
+		cfg.TurnBackend = &smartturntest.TurnScript{}
+		cfg.TurnExport = tc.export
+		if err := smartturn.ValidateConfig(cfg); (err == nil) != tc.ok {
+			t.Errorf("%+v: error %v", tc.export, err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package smartturn
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TurnLogEntry is one line of Config.TurnLog: a JSON object per turn, from
+// OnSpeechStart to OnSpeechEnd, for analyzing production behavior offline
+// (decode lines into it). Times are media times (see MediaTime); durations
+// are in milliseconds of audio unless noted.
+type TurnLogEntry struct {
+	Turn       int       `json:"turn"`  // TurnStart.ID; merged entries share it
+	Start      time.Time `json:"start"` // first sample of the first segment
+	End        time.Time `json:"end"`   // end of the chunk that ended the turn
+	DurationMs float64   `json:"duration_ms"`
+	SpeechMs   float64   `json:"speech_ms"` // total length of the turn's segments
+	Segments   int       `json:"segments"`
+	// Merged is set when speech resumed within TurnMergeGapMs of the end
+	// of the previous turn (OnTurnMerged), so this entry continues it.
+	Merged bool `json:"merged"`
+	// Probabilities holds the Smart-Turn probability of every evaluation
+	// of the turn, as compared with TurnThreshold (after calibration and
+	// smoothing); InferenceMs the wall-clock time of each.
+	Probabilities []float32 `json:"probabilities"`
+	InferenceMs   []float64 `json:"inference_ms"`
+	Errors        int       `json:"inference_errors"`
+	Reason        string    `json:"reason"` // TurnEndReason
+}
+
+// turnLog builds the TurnLogEntry of the current turn. Its slices are
+// reused from turn to turn.
+type turnLog struct {
+	entry  TurnLogEntry
+	active bool
+	buf    []byte
+}
+
+func newTurnLog() *turnLog {
+	// Empty rather than nil slices, so turns without evaluations log [].
+	return &turnLog{entry: TurnLogEntry{Probabilities: []float32{}, InferenceMs: []float64{}}}
+}
+
+// start opens an entry for a turn whose speech begins at start.
+func (l *turnLog) start(turn int, start time.Time, merged bool) {
+	l.entry = TurnLogEntry{
+		Turn:          turn,
+		Start:         start,
+		Merged:        merged,
+		Probabilities: l.entry.Probabilities[:0],
+		InferenceMs:   l.entry.InferenceMs[:0],
+	}
+	l.active = true
+}
+
+// segment counts a finished segment of samples.
+func (l *turnLog) segment(samples int) {
+	l.entry.Segments++
+	l.entry.SpeechMs += float64(samples) * 1000 / RequiredSampleRate
+}
+
+// inference records one Smart-Turn evaluation; prob is ignored when err
+// is set.
+func (l *turnLog) inference(d time.Duration, prob float32, err error) {
+	if err != nil {
+		l.entry.Errors++
+		return
+	}
+	l.entry.Probabilities = append(l.entry.Probabilities, prob)
+	l.entry.InferenceMs = append(l.entry.InferenceMs, float64(d)/float64(time.Millisecond))
+}
+
+// line closes the entry and returns it as a JSON line, or nil when no turn
+// was open. The line is valid until the next call.
+func (l *turnLog) line(end time.Time, reason TurnEndReason) ([]byte, error) {
+	if !l.active {
+		return nil, nil
+	}
+	l.active = false
+	l.entry.End = end
+	l.entry.DurationMs = float64(end.Sub(l.entry.Start)) / float64(time.Millisecond)
+	l.entry.Reason = reason.String()
+	b, err := json.Marshal(&l.entry)
+	if err != nil {
+		return nil, fmt.Errorf("turn log: %w", err)
+	}
+	l.buf = append(append(l.buf[:0], b...), '\n')
+	return l.buf, nil
+}
+
+// writeTurnLog appends the entry of the turn that just ended to
+// Config.TurnLog.
+func (e *Engine) writeTurnLog(reason TurnEndReason) {
+	b, err := e.turnLog.line(e.mediaTimeAt(e.samples), reason)
+	if err == nil && b != nil {
+		if _, werr := e.cfg.TurnLog.Write(b); werr != nil {
+			err = fmt.Errorf("turn log: %w", werr)
+		}
+	}
+	if err != nil {
+		e.reportError("writing turn log", err)
+	}
+}
@@ -0,0 +1,133 @@
+package smartturn_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// lineWriter keeps each Write as a line, failing with err when set.
+type lineWriter struct {
+	lines []string
+	err   error
+}
+
+func (w *lineWriter) Write(b []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.lines = append(w.lines, string(b))
+	return len(b), nil
+}
+
+// turnLog runs audio through an engine with a TurnLog and returns the
+// entries and the turn starts.
+func turnLog(t *testing.T, probs []float32, audio []float32, tweak func(*smartturn.Config)) ([]smartturn.TurnLogEntry, []smartturn.TurnStart) {
+	t.Helper()
+	w := &lineWriter{}
+	var starts []smartturn.TurnStart
+	e := newTestEngine(t, probs, smartturn.Callbacks{
+		OnTurnStart: func(s smartturn.TurnStart) { starts = append(starts, s) },
+		OnError:     func(error) {},
+	}, func(cfg *smartturn.Config) {
+		cfg.TurnLog = w
+		clk := smartturntest.NewClock(time.Unix(0, 0))
+		clk.SetStep(time.Millisecond)
+		cfg.Clock = clk
+		if tweak != nil {
+			tweak(cfg)
+		}
+	})
+	if err := e.PushPCMAt(audio[:smartturn.RequiredChunkSize], rtp); err != nil {
+		t.Fatal(err)
+	}
+	pushAll(t, e, audio[smartturn.RequiredChunkSize:])
+	var entries []smartturn.TurnLogEntry
+	for _, l := range w.lines {
+		if !strings.HasSuffix(l, "}\n") || strings.Count(l, "\n") != 1 {
+			t.Fatalf("Write of %q, want one JSON line", l)
+		}
+		var entry smartturn.TurnLogEntry
+		if err := json.Unmarshal([]byte(l), &entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, starts
+}
+
+// TestTurnLog checks the entries of a turn that pauses and resumes, and
+// of a turn merged into it that times out.
+func TestTurnLog(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 19}.Generate(
+		smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond),
+		smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond),
+		smartturntest.Speech(time.Second), smartturntest.Silence(2*time.Second))
+	entries, starts := turnLog(t, []float32{0.2, 0.9, 0.3}, audio, func(cfg *smartturn.Config) { cfg.TurnMergeGapMs = 800 })
+	// Inference takes one step of the test clock.
+	want := []smartturn.TurnLogEntry{{
+		Turn: 0, Start: rtp, End: rtp.Add(2944 * time.Millisecond), DurationMs: 2944,
+		SpeechMs: 2880, Segments: 2,
+		Probabilities: []float32{0.2, 0.9}, InferenceMs: []float64{1, 1}, Reason: "model",
+	}, {
+		Turn: 0, Start: rtp.Add(3008 * time.Millisecond), End: rtp.Add(5536 * time.Millisecond), DurationMs: 2528,
+		SpeechMs: 1504, Segments: 1, Merged: true,
+		Probabilities: []float32{0.3}, InferenceMs: []float64{1}, Reason: "timeout",
+	}}
+	if len(entries) != len(want) || len(starts) != 1 || !entries[0].Start.Equal(starts[0].Time) {
+		t.Fatalf("entries %+v for turn starts %+v", entries, starts)
+	}
+	for i := range want {
+		got, want := entries[i], want[i]
+		if !got.Start.Equal(want.Start) || !got.End.Equal(want.End) {
+			t.Errorf("entry %d: %v to %v, want %v to %v", i, got.Start, got.End, want.Start, want.End)
+		}
+		got.Start, got.End = want.Start, want.End
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("entry %d:\n%+v\nwant\n%+v", i, got, want)
+		}
+	}
+}
+
+// TestTurnLogErrors checks that a turn discarded by Reset is not logged
+// and that a failed write reaches OnError.
+func TestTurnLogErrors(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 20}.Generate(smartturntest.Speech(time.Second), smartturntest.Silence(2*time.Second))
+	w := &lineWriter{}
+	var errs []error
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+		OnError: func(err error) { errs = append(errs, err) },
+	}, func(cfg *smartturn.Config) { cfg.TurnLog = w })
+	pushAll(t, e, audio[:len(audio)/3])
+	e.Reset()
+	pushAll(t, e, make([]float32, 16000))
+	if len(w.lines) != 0 {
+		t.Errorf("turn discarded by Reset logged: %q", w.lines)
+	}
+	w.err = errors.New("disk full")
+	pushAll(t, e, audio)
+	if len(errs) != 1 || !errors.Is(errs[0], w.err) {
+		t.Errorf("errors %v, want the write error", errs)
+	}
+}
+
+// TestTurnLogFailedInference checks that failed inferences are counted,
+// leaving the slices logged as [].
+func TestTurnLogFailedInference(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 21}.Generate(smartturntest.Speech(time.Second), smartturntest.Silence(2*time.Second))
+	w := &lineWriter{}
+	e := newTestEngine(t, nil, smartturn.Callbacks{OnError: func(error) {}}, func(cfg *smartturn.Config) {
+		cfg.TurnLog = w
+		cfg.TurnBackend = failingTurn{}
+	})
+	pushAll(t, e, audio)
+	if len(w.lines) != 1 || !strings.Contains(w.lines[0], `"probabilities":[],"inference_ms":[],"inference_errors":1,"reason":"timeout"`) {
+		t.Errorf("lines %q, want a timeout after one failed inference", w.lines)
+	}
+}
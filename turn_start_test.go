@@ -0,0 +1,98 @@
+package smartturn_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// TestTurnStart checks that OnTurnStart comes right before the
+// OnSpeechStart of each new turn, not of speech resuming a pending one,
+// with IDs that survive Reset and the turn's first sample.
+func TestTurnStart(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 25}.Generate(
+		smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond),
+		smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond),
+		smartturntest.Silence(time.Second), smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	var got []string
+	var starts []smartturn.TurnStart
+	var chunk int64 // offset of the chunk being processed
+	tr := &turnTranscriber{}
+	e := newTestEngine(t, []float32{0.2, 0.9}, smartturn.Callbacks{
+		OnVadScore: func(_ float32, off int64) { chunk = off },
+		OnTurnStart: func(s smartturn.TurnStart) {
+			got = append(got, fmt.Sprintf("turn%d", s.ID))
+			starts = append(starts, s)
+			// The turn's audio runs from its padding to the chunk
+			// speech was detected in.
+			if s.Offset+int64(s.Padding*smartturn.RequiredSampleRate/time.Second) != chunk {
+				t.Errorf("turn %d: offset %d plus padding %v is not the chunk at %d", s.ID, s.Offset, s.Padding, chunk)
+			}
+		},
+		OnSpeechStart: func() { got = append(got, "start") },
+		OnSpeechEnd:   func() { got = append(got, "end") },
+		OnTranscript:  func(tx smartturn.Transcript) { got = append(got, tx.Text[:len("turn 0")]) },
+	}, func(cfg *smartturn.Config) { cfg.Transcriber = tr })
+	pushAll(t, e, audio)
+	// A turn cut by Reset keeps its ID, and the next turn takes the one
+	// after it.
+	pushAll(t, e, audio[:len(audio)/8])
+	e.Reset()
+	pushAll(t, e, audio)
+
+	// The first turn resumes after an incomplete prediction; from then on
+	// the script predicts 0.9, so every pause ends the turn.
+	want := "turn0 start end turn 0 turn1 start end turn 1 turn2 start " +
+		"turn3 start end turn 3 turn4 start end turn 4 turn5 start end turn 5"
+	if s := strings.Join(got, " "); s != want {
+		t.Fatalf("callbacks %q\nwant %q", s, want)
+	}
+	// Speech right after New or Reset has no padding; other turns have
+	// VadPreSpeechMs (200 ms) rounded down to whole chunks.
+	for i, want := range []time.Duration{0, 192, 192, 0, 192, 192} {
+		if starts[i].Padding != want*time.Millisecond {
+			t.Errorf("turn %d: padding %v, want %v", i, starts[i].Padding, want*time.Millisecond)
+		}
+	}
+}
+
+func TestTurnStartProcess(t *testing.T) {
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{}, nil)
+	audio, _ := smartturntest.Synth{Seed: 26}.Generate(
+		smartturntest.Silence(time.Second), smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	var got []string
+	for _, c := range smartturntest.Chunks(audio) {
+		events, err := e.Process(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ev := range events {
+			switch ev.Kind {
+			case smartturn.EventTurnStart:
+				got = append(got, fmt.Sprintf("turn%d+%v", ev.TurnStart.ID, ev.TurnStart.Padding))
+			case smartturn.EventSpeechStart:
+				got = append(got, "start")
+			}
+		}
+	}
+	if s := strings.Join(got, " "); s != "turn0+192ms start" {
+		t.Errorf("events %q, want EventTurnStart before EventSpeechStart", s)
+	}
+}
+
+func TestTurnStartFake(t *testing.T) {
+	var got []string
+	f := smartturntest.NewFake(smartturn.Callbacks{
+		OnTurnStart:   func(s smartturn.TurnStart) { got = append(got, fmt.Sprintf("turn%d", s.ID)) },
+		OnSpeechStart: func() { got = append(got, "start") },
+	})
+	f.EmitSpeechStart()
+	f.EmitSpeechStart()
+	if s := strings.Join(got, " "); s != "turn0 start turn1 start" {
+		t.Errorf("Fake callbacks %q", s)
+	}
+}
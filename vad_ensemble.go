@@ -0,0 +1,99 @@
+package smartturn
+
+// VotePolicy combines the scores of the VADs in Config.VADEnsemble into
+// the chunk's speech probability.
+type VotePolicy int
+
+const (
+	// VoteAverage scores the mean probability.
+	VoteAverage VotePolicy = iota
+	// VoteAll scores the lowest probability: speech only when every VAD
+	// agrees (AND), which rejects triggers a single model falls for.
+	VoteAll
+	// VoteAny scores the highest probability: speech when any VAD hears it
+	// (OR), for recall at the cost of false triggers.
+	VoteAny
+	// VoteMajority scores the median probability, which crosses
+	// VadThreshold when most VADs do. With an even count the lower median
+	// is used, so a tie is not speech.
+	VoteMajority
+)
+
+// vadEnsemble is the VADBackend built from the engine's VAD and
+// Config.VADEnsemble; it owns and closes all of them.
+type vadEnsemble struct {
+	members []VADBackend
+	policy  VotePolicy
+	probs   []float32 // per member, reused every chunk
+}
+
+func newVADEnsemble(primary VADBackend, others []VADBackend, policy VotePolicy) *vadEnsemble {
+	members := append([]VADBackend{primary}, others...)
+	return &vadEnsemble{members: members, policy: policy, probs: make([]float32, len(members))}
+}
+
+// SpeechProb implements VADBackend. Every member scores every chunk, so
+// recurrent state stays in step; the first error is returned.
+func (v *vadEnsemble) SpeechProb(chunk []float32) (float32, error) {
+	var firstErr error
+	for i, m := range v.members {
+		p, err := m.SpeechProb(chunk)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		v.probs[i] = p
+	}
+	if firstErr != nil {
+		return 0, firstErr
+	}
+	switch v.policy {
+	case VoteAll:
+		r := v.probs[0]
+		for _, p := range v.probs[1:] {
+			if p < r {
+				r = p
+			}
+		}
+		return r, nil
+	case VoteAny:
+		r := v.probs[0]
+		for _, p := range v.probs[1:] {
+			if p > r {
+				r = p
+			}
+		}
+		return r, nil
+	case VoteMajority:
+		// Insertion sort: ensembles are a handful of models.
+		for i := 1; i < len(v.probs); i++ {
+			for j := i; j > 0 && v.probs[j] < v.probs[j-1]; j-- {
+				v.probs[j], v.probs[j-1] = v.probs[j-1], v.probs[j]
+			}
+		}
+		return v.probs[(len(v.probs)-1)/2], nil
+	default:
+		var sum float32
+		for _, p := range v.probs {
+			sum += p
+		}
+		return sum / float32(len(v.probs)), nil
+	}
+}
+
+// Reset implements VADBackend.
+func (v *vadEnsemble) Reset() {
+	for _, m := range v.members {
+		m.Reset()
+	}
+}
+
+// Close implements VADBackend, closing every member.
+func (v *vadEnsemble) Close() error {
+	var err error
+	for _, m := range v.members {
+		if cerr := m.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,118 @@
+package smartturn_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// constVAD is a VADBackend scoring every chunk p.
+type constVAD struct {
+	p      float32
+	err    error
+	calls  int
+	resets int
+	closes int
+}
+
+func (v *constVAD) SpeechProb([]float32) (float32, error) {
+	v.calls++
+	return v.p, v.err
+}
+
+func (v *constVAD) Reset()       { v.resets++ }
+func (v *constVAD) Close() error { v.closes++; return v.err }
+
+// ensembleScore returns the probability an engine with the given member
+// scores and policy reports through OnVadScore.
+func ensembleScore(t *testing.T, probs []float32, vote smartturn.VotePolicy) float32 {
+	t.Helper()
+	var got float32 = -1
+	e := newTestEngine(t, nil, smartturn.Callbacks{
+		OnVadScore: func(p float32, _ int64) { got = p },
+	}, func(cfg *smartturn.Config) {
+		cfg.VADBackend = &constVAD{p: probs[0]}
+		for _, p := range probs[1:] {
+			cfg.VADEnsemble = append(cfg.VADEnsemble, &constVAD{p: p})
+		}
+		cfg.VADVote = vote
+	})
+	pushAll(t, e, make([]float32, smartturn.RequiredChunkSize))
+	return got
+}
+
+func TestVADEnsemble(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		probs []float32
+		vote  smartturn.VotePolicy
+		want  float32
+	}{
+		{"average", []float32{0.9, 0.2, 0.6}, smartturn.VoteAverage, (0.9 + 0.2 + 0.6) / 3},
+		{"all", []float32{0.9, 0.2, 0.6}, smartturn.VoteAll, 0.2},
+		{"any", []float32{0.2, 0.9, 0.6}, smartturn.VoteAny, 0.9},
+		{"majority", []float32{0.9, 0.2, 0.6}, smartturn.VoteMajority, 0.6},
+		{"majority of two", []float32{0.9, 0.2}, smartturn.VoteMajority, 0.2},
+		{"majority of four", []float32{0.9, 0.2, 0.6, 0.4}, smartturn.VoteMajority, 0.4},
+		{"majority of five", []float32{0.1, 0.9, 0.8, 0.2, 0.7}, smartturn.VoteMajority, 0.7},
+	} {
+		if got := ensembleScore(t, tc.probs, tc.vote); math.Abs(float64(got-tc.want)) > 1e-6 {
+			t.Errorf("%s of %v: %v, want %v", tc.name, tc.probs, got, tc.want)
+		}
+	}
+}
+
+// TestVADEnsembleMembers checks that every member scores every chunk,
+// even after an error, and is reset and closed once.
+func TestVADEnsembleMembers(t *testing.T) {
+	failing := &constVAD{p: 0.9, err: errors.New("member failed")}
+	primary, other := &constVAD{p: 0.9}, &constVAD{p: 0.9}
+	var errs []error
+	cfg := benchConfig()
+	cfg.VADBackend = primary
+	cfg.VADEnsemble = []smartturn.VADBackend{failing, other}
+	cfg.TurnBackend = &smartturntest.TurnScript{}
+	e, err := smartturn.New(cfg, smartturn.Callbacks{OnError: func(err error) { errs = append(errs, err) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Start()
+	for range 3 {
+		if err := e.PushPCM(make([]float32, smartturn.RequiredChunkSize)); !errors.Is(err, failing.err) {
+			t.Errorf("PushPCM error %v, want the member's error", err)
+		}
+	}
+	if len(errs) != 3 || !errors.Is(errs[0], failing.err) {
+		t.Errorf("OnError %v, want the member's error for each chunk", errs)
+	}
+	e.Reset()
+	e.Close()
+	for i, m := range []*constVAD{primary, failing, other} {
+		if m.calls != 3 || m.resets != 1 || m.closes != 1 {
+			t.Errorf("member %d: %d calls, %d resets, %d closes", i, m.calls, m.resets, m.closes)
+		}
+	}
+}
+
+func TestVADEnsembleValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		ensemble []smartturn.VADBackend
+		vote     smartturn.VotePolicy
+	}{
+		{"nil member", []smartturn.VADBackend{&constVAD{}, nil}, smartturn.VoteAll},
+		{"unknown vote", []smartturn.VADBackend{&constVAD{}}, smartturn.VoteMajority + 1},
+		{"negative vote", nil, -1},
+	} {
+		cfg := benchConfig()
+		cfg.VADBackend = &constVAD{}
+		cfg.TurnBackend = &smartturntest.TurnScript{}
+		cfg.VADEnsemble, cfg.VADVote = tc.ensemble, tc.vote
+		if _, err := smartturn.New(cfg, smartturn.Callbacks{}); err == nil {
+			t.Errorf("%s accepted", tc.name)
+		}
+	}
+}
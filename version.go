@@ -0,0 +1,186 @@
+package smartturn
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"maps"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// SDKVersion is the semantic version of this SDK.
+const SDKVersion = "0.9.0"
+
+// VersionInfo identifies the software stack making turn decisions.
+type VersionInfo struct {
+	SDK string
+	// ONNXRuntime is the version of the loaded ONNX Runtime library, empty
+	// while no engine (or host code) has initialized it.
+	ONNXRuntime string
+	Go          string
+}
+
+// Version reports the SDK, ONNX Runtime and Go versions, for logging at
+// startup or alongside each decision.
+func Version() VersionInfo {
+	v := VersionInfo{SDK: SDKVersion, Go: runtime.Version()}
+	if ort.IsInitialized() {
+		v.ONNXRuntime = ort.GetVersion()
+	}
+	return v
+}
+
+// ModelFile describes a model file an engine runs.
+type ModelFile struct {
+	Path    string
+	SHA256  string // hex digest of the file
+	Size    int64
+	ModTime time.Time
+
+	// From the ONNX model metadata; empty when the export did not set them.
+	Producer    string
+	GraphName   string
+	Domain      string
+	Description string
+	Version     int64
+	Metadata    map[string]string // custom metadata_props
+}
+
+// ModelInfo describes the models an engine runs. A model that is not a
+// file the SDK loaded (a custom VADBackend or TurnBackend, the energy or
+// WebRTC VAD) is nil.
+type ModelInfo struct {
+	VAD       *ModelFile
+	SmartTurn *ModelFile
+	Shadow    *ModelFile // Config.ShadowSmartTurnModelPath
+
+	// SmartTurnInput is "mel" (v3) or "raw" (v2), and SmartTurnWindow the
+	// audio fed per inference.
+	SmartTurnInput  string
+	SmartTurnWindow time.Duration
+}
+
+// modelPaths is what ModelInfo reports before the files are read; the
+// engine replaces it when ReloadModels takes over.
+type modelPaths struct {
+	vad, turn, shadow string
+	input             string
+	window            time.Duration
+}
+
+// setModelPaths records the models the engine runs now.
+func (e *Engine) setModelPaths() {
+	m := &modelPaths{
+		input:  e.smartTurn.kind(),
+		window: time.Duration(e.smartTurn.model.windowSamples) * time.Second / RequiredSampleRate,
+		turn:   e.smartTurn.path,
+	}
+	if e.builtinSilero {
+		vad := e.vad
+		if ens, ok := vad.(*vadEnsemble); ok {
+			vad = ens.members[0]
+		}
+		if s, ok := vad.(*sileroVAD); ok {
+			m.vad = s.path
+		}
+	}
+	if e.shadow != nil {
+		m.shadow = e.shadow.path
+	}
+	e.models.Store(m)
+}
+
+// ModelInfo returns the paths, SHA-256 digests and ONNX metadata of the
+// model files the engine runs, reflecting the last ReloadModels that took
+// over. Files are hashed on the first call for each and cached while
+// their size and modification time are unchanged. It may be called from
+// any goroutine.
+func (e *Engine) ModelInfo() (ModelInfo, error) {
+	if e.closing.Load() {
+		return ModelInfo{}, ErrClosed
+	}
+	m := e.models.Load()
+	info := ModelInfo{SmartTurnInput: m.input, SmartTurnWindow: m.window}
+	for _, f := range []struct {
+		path string
+		dst  **ModelFile
+	}{{m.vad, &info.VAD}, {m.turn, &info.SmartTurn}, {m.shadow, &info.Shadow}} {
+		if f.path == "" {
+			continue
+		}
+		mf, err := describeModelFile(f.path)
+		if err != nil {
+			return ModelInfo{}, err
+		}
+		*f.dst = mf
+	}
+	return info, nil
+}
+
+// modelFiles caches describeModelFile by path.
+var modelFiles sync.Map // string -> *ModelFile
+
+// describeModelFile hashes path and reads its ONNX metadata, reusing the
+// cached result while the file's size and modification time match.
+func describeModelFile(path string) (*ModelFile, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := modelFiles.Load(path); ok {
+		mf := c.(*ModelFile)
+		if mf.Size == st.Size() && mf.ModTime.Equal(st.ModTime()) {
+			return mf.clone(), nil
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	mf := &ModelFile{Path: path, SHA256: hex.EncodeToString(h.Sum(nil)), Size: st.Size(), ModTime: st.ModTime()}
+	readModelMetadata(mf)
+	modelFiles.Store(path, mf)
+	return mf.clone(), nil
+}
+
+func (mf *ModelFile) clone() *ModelFile {
+	cp := *mf
+	cp.Metadata = maps.Clone(mf.Metadata)
+	return &cp
+}
+
+// readModelMetadata fills the metadata fields of mf. Metadata is
+// informational, so fields that cannot be read are left empty.
+func readModelMetadata(mf *ModelFile) {
+	md, err := ort.GetModelMetadata(mf.Path)
+	if err != nil {
+		return
+	}
+	defer md.Destroy()
+	mf.Producer, _ = md.GetProducerName()
+	mf.GraphName, _ = md.GetGraphName()
+	mf.Domain, _ = md.GetDomain()
+	mf.Description, _ = md.GetDescription()
+	mf.Version, _ = md.GetVersion()
+	keys, _ := md.GetCustomMetadataMapKeys()
+	for _, k := range keys {
+		v, ok, err := md.LookupCustomMetadataMap(k)
+		if err != nil || !ok {
+			continue
+		}
+		if mf.Metadata == nil {
+			mf.Metadata = make(map[string]string, len(keys))
+		}
+		mf.Metadata[k] = v
+	}
+}
@@ -0,0 +1,63 @@
+package smartturn
+
+import "log/slog"
+
+// WakeWordDetector gates the engine on a wake word (Config.WakeWord). It is
+// called from the engine's goroutine with every chunk while the engine is
+// dormant, and owned by the engine, which closes it in Close.
+type WakeWordDetector interface {
+	// Detect reports whether the wake word ended in this 512-sample 16 kHz
+	// chunk (upsampled at 8 kHz).
+	Detect(chunk []float32) (bool, error)
+	// Reset clears detector state; called each time the gate re-arms.
+	Reset()
+	Close() error
+}
+
+// wake runs the wake-word gate on a chunk and reports whether the engine
+// is dormant, i.e. whether the chunk stops here.
+func (e *Engine) wake(chunk []float32) bool {
+	if !e.dormant {
+		if e.awaitingSpeech {
+			e.wakeChunks++
+			if e.wakeTimeoutChunks > 0 && e.wakeChunks >= e.wakeTimeoutChunks {
+				e.log.Info("no speech after wake word; re-arming", "timeout_ms", e.cfg.WakeWordTimeoutMs)
+				e.rearm()
+				return true
+			}
+		}
+		return false
+	}
+	fired, err := e.cfg.WakeWord.Detect(chunk)
+	if err != nil {
+		e.reportError("wake word detection failed", err)
+		return true
+	}
+	if !fired {
+		return true
+	}
+	// Start the turn pipeline from a clean state after the wake word.
+	e.dormant = false
+	e.awaitingSpeech = true
+	e.wakeChunks = 0
+	e.vad.Reset()
+	e.segmenter.reset()
+	if e.logs(slog.LevelInfo) {
+		e.log.Info("wake word detected", "offset", e.samples)
+	}
+	e.record(Event{Kind: EventWakeWord})
+	if e.cb.OnWakeWord != nil {
+		e.cb.OnWakeWord()
+	}
+	return true
+}
+
+// rearm puts a wake-word gated engine back to sleep.
+func (e *Engine) rearm() {
+	if e.cfg.WakeWord == nil {
+		return
+	}
+	e.dormant = true
+	e.awaitingSpeech = false
+	e.cfg.WakeWord.Reset()
+}
@@ -0,0 +1,186 @@
+package smartturn_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// wakeScript is a WakeWordDetector that fires on the next chunk after
+// arm is set.
+type wakeScript struct {
+	arm    bool
+	err    error
+	calls  int
+	resets int
+	closed bool
+}
+
+func (w *wakeScript) Detect([]float32) (bool, error) {
+	w.calls++
+	fired := w.arm
+	w.arm = false
+	return fired, w.err
+}
+
+func (w *wakeScript) Reset()       { w.resets++ }
+func (w *wakeScript) Close() error { w.closed = true; return nil }
+
+// utterance is a second of speech the model judges complete once it
+// ends, followed by enough silence for the turn to end.
+func utterance() []float32 {
+	audio, _ := smartturntest.Synth{Seed: 7}.Generate(smartturntest.Speech(time.Second), smartturntest.Silence(600*time.Millisecond))
+	return audio
+}
+
+// silence returns d of digital silence.
+func silence(d time.Duration) []float32 {
+	return make([]float32, int(d*16000/time.Second))
+}
+
+func TestWakeWord(t *testing.T) {
+	w := &wakeScript{}
+	ts := &smartturntest.TurnScript{Probabilities: []float32{0.9}}
+	var got []string
+	callsAtEnd := 0
+	e := newTestEngine(t, nil, smartturn.Callbacks{
+		OnWakeWord:    func() { got = append(got, "wake") },
+		OnSpeechStart: func() { got = append(got, "start") },
+		OnSpeechEnd: func() {
+			got = append(got, "end")
+			callsAtEnd = w.calls
+		},
+	}, func(cfg *smartturn.Config) {
+		cfg.WakeWord = w
+		cfg.TurnBackend = ts
+	})
+	turns := func() int { return ts.Calls }
+
+	// Dormant: the detector sees every chunk and speech is ignored.
+	audio := utterance()
+	pushAll(t, e, audio)
+	if n := len(smartturntest.Chunks(audio)); w.calls != n || len(got) != 0 || turns() != 0 {
+		t.Fatalf("dormant: %d detector calls for %d chunks, callbacks %v, %d predictions", w.calls, n, got, turns())
+	}
+
+	// The wake word opens the gate for one turn; the detector rests.
+	w.arm, w.calls = true, 0
+	pushAll(t, e, silence(32*time.Millisecond))
+	pushAll(t, e, audio)
+	if s := strings.Join(got, " "); s != "wake start end" || callsAtEnd != 1 || turns() != 1 {
+		t.Fatalf("after the wake word: callbacks %q, %d detector calls in the turn, %d predictions", s, callsAtEnd, turns())
+	}
+
+	// The end of the turn re-arms the gate.
+	if w.resets != 1 {
+		t.Errorf("%d detector resets after the turn, want 1", w.resets)
+	}
+	calls := w.calls
+	pushAll(t, e, audio)
+	if s := strings.Join(got, " "); s != "wake start end" || w.calls != calls+len(smartturntest.Chunks(audio)) || turns() != 1 {
+		t.Errorf("re-armed: callbacks %q, %d detector calls, %d predictions", s, w.calls-calls, turns())
+	}
+}
+
+// TestWakeWordTimeout checks that the gate re-arms when no speech starts
+// within WakeWordTimeoutMs of the wake word, and only then.
+func TestWakeWordTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		timeoutMs int
+		silence   time.Duration
+		rearmed   bool
+	}{
+		{500, 400 * time.Millisecond, false},
+		{500, 600 * time.Millisecond, true},
+		{0, 3 * time.Second, false},
+		// Speech that starts in time may outlast the timeout.
+		{500, 100 * time.Millisecond, false},
+	} {
+		w := &wakeScript{arm: true}
+		starts, ends := 0, 0
+		e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+			OnSpeechStart: func() { starts++ },
+			OnSpeechEnd:   func() { ends++ },
+		}, func(cfg *smartturn.Config) {
+			cfg.WakeWord = w
+			cfg.WakeWordTimeoutMs = tc.timeoutMs
+		})
+		pushAll(t, e, silence(32*time.Millisecond))
+		pushAll(t, e, silence(tc.silence))
+		pushAll(t, e, utterance())
+		if rearmed := starts == 0; rearmed != tc.rearmed || rearmed && w.resets != 1 || !rearmed && ends != 1 {
+			t.Errorf("timeout %dms after %v of silence: re-armed %v (%d resets, %d turn ends), want %v", tc.timeoutMs, tc.silence, rearmed, w.resets, ends, tc.rearmed)
+		}
+	}
+}
+
+func TestWakeWordReset(t *testing.T) {
+	w := &wakeScript{arm: true}
+	starts := 0
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{OnSpeechStart: func() { starts++ }},
+		func(cfg *smartturn.Config) { cfg.WakeWord = w })
+	pushAll(t, e, silence(32*time.Millisecond))
+	e.Reset()
+	pushAll(t, e, utterance())
+	if starts != 0 || w.resets != 1 {
+		t.Errorf("after Reset: %d speech starts, %d detector resets", starts, w.resets)
+	}
+}
+
+func TestWakeWordErrorsAndClose(t *testing.T) {
+	w := &wakeScript{arm: true, err: errors.New("detector failed")}
+	var errs []error
+	wakes := 0
+	cfg := benchConfig()
+	cfg.VADBackend = &smartturntest.EnergyVAD{}
+	cfg.TurnBackend = &smartturntest.TurnScript{}
+	cfg.WakeWord = w
+	e, err := smartturn.New(cfg, smartturn.Callbacks{
+		OnWakeWord: func() { wakes++ },
+		OnError:    func(err error) { errs = append(errs, err) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Start()
+	pushAll(t, e, silence(64*time.Millisecond))
+	if wakes != 0 || len(errs) != 2 || !strings.Contains(errs[0].Error(), "detector failed") {
+		t.Errorf("failing detector: %d wakes, errors %v", wakes, errs)
+	}
+	e.Close()
+	if !w.closed {
+		t.Error("Close did not close the detector")
+	}
+
+	cfg.WakeWordTimeoutMs = -1
+	if _, err := smartturn.New(cfg, smartturn.Callbacks{}); err == nil {
+		t.Error("negative WakeWordTimeoutMs accepted")
+	}
+}
+
+func TestWakeWordProcess(t *testing.T) {
+	w := &wakeScript{arm: true}
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{}, func(cfg *smartturn.Config) { cfg.WakeWord = w })
+	audio := append(silence(32*time.Millisecond), utterance()...)
+	var kinds []smartturn.EventKind
+	for _, c := range smartturntest.Chunks(audio) {
+		events, err := e.Process(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ev := range events {
+			switch ev.Kind {
+			case smartturn.EventWakeWord, smartturn.EventSpeechStart, smartturn.EventSpeechEnd:
+				kinds = append(kinds, ev.Kind)
+			}
+		}
+	}
+	want := []smartturn.EventKind{smartturn.EventWakeWord, smartturn.EventSpeechStart, smartturn.EventSpeechEnd}
+	if len(kinds) != len(want) || kinds[0] != want[0] || kinds[1] != want[1] || kinds[2] != want[2] {
+		t.Errorf("events %v, want %v", kinds, want)
+	}
+}
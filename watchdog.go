@@ -0,0 +1,236 @@
+package smartturn
+
+import (
+	"runtime/debug"
+	"slices"
+	"time"
+)
+
+// DefaultCallbackQueueSize is the default CallbackWatchdog.QueueSize.
+const DefaultCallbackQueueSize = 64
+
+// CallbackWatchdog times every callback against a budget, to find handlers
+// that stall the real-time path (Config.CallbackWatchdog).
+type CallbackWatchdog struct {
+	// Budget is the longest a callback may run; each call over it is
+	// logged and reported to OnSlowCallback. 0 disables the watchdog.
+	Budget time.Duration
+	// Async moves the callbacks off the engine goroutine once one of them
+	// exceeded Budget: from the next call on, every callback but
+	// OnSlowCallback runs on one dispatch goroutine, in the order the
+	// engine emits them. Arguments the engine reuses (chunks, segment
+	// slices, AuxOutputs) are copied for it. Callbacks may then run
+	// concurrently with the rest of the engine, a panic in them is only
+	// logged, and one already running may still be running when Close
+	// returns.
+	Async bool
+	// QueueSize bounds the calls waiting for the dispatch goroutine
+	// (default DefaultCallbackQueueSize); calls beyond it are dropped and
+	// logged.
+	QueueSize int
+}
+
+// SlowCallback is passed to OnSlowCallback.
+type SlowCallback struct {
+	Callback string // e.g. "OnSpeechEnd"
+	Duration time.Duration
+	Budget   time.Duration
+	// Async is set when CallbackWatchdog.Async moved the callbacks to the
+	// dispatch goroutine because of this call.
+	Async bool
+}
+
+// callbackWatch is the watchdog of one callback.
+type callbackWatch struct {
+	e    *Engine
+	name string
+}
+
+// watchCallbacks wraps the callbacks of cb, except OnSlowCallback, with the
+// watchdog of e.cfg.CallbackWatchdog.
+func (e *Engine) watchCallbacks(cb Callbacks) Callbacks {
+	w := cb
+	if e.cfg.CallbackWatchdog.Async {
+		size := e.cfg.CallbackWatchdog.QueueSize
+		if size <= 0 {
+			size = DefaultCallbackQueueSize
+		}
+		// New starts the dispatch goroutine once nothing can fail.
+		e.asyncCalls = make(chan func(), size)
+	}
+	watch := func(name string) *callbackWatch { return &callbackWatch{e: e, name: name} }
+	if cb.OnListeningStarted != nil {
+		w.OnListeningStarted = watch0(watch("OnListeningStarted"), cb.OnListeningStarted)
+	}
+	if cb.OnListeningStopped != nil {
+		w.OnListeningStopped = watch0(watch("OnListeningStopped"), cb.OnListeningStopped)
+	}
+	if cb.OnSpeechStart != nil {
+		w.OnSpeechStart = watch0(watch("OnSpeechStart"), cb.OnSpeechStart)
+	}
+	if cb.OnSpeechEnd != nil {
+		w.OnSpeechEnd = watch0(watch("OnSpeechEnd"), cb.OnSpeechEnd)
+	}
+	if cb.OnWakeWord != nil {
+		w.OnWakeWord = watch0(watch("OnWakeWord"), cb.OnWakeWord)
+	}
+	if cb.OnTurnStart != nil {
+		w.OnTurnStart = watch1(watch("OnTurnStart"), cb.OnTurnStart, nil)
+	}
+	if cb.OnDTMF != nil {
+		w.OnDTMF = watch1(watch("OnDTMF"), cb.OnDTMF, nil)
+	}
+	if cb.OnSpeakerChange != nil {
+		w.OnSpeakerChange = watch1(watch("OnSpeakerChange"), cb.OnSpeakerChange, nil)
+	}
+	if cb.OnTurnEnd != nil {
+		w.OnTurnEnd = watch1(watch("OnTurnEnd"), cb.OnTurnEnd, nil)
+	}
+	if cb.OnChunk != nil {
+		w.OnChunk = watch1(watch("OnChunk"), cb.OnChunk, slices.Clone[[]float32])
+	}
+	if cb.OnSegmentReady != nil {
+		w.OnSegmentReady = watch1(watch("OnSegmentReady"), cb.OnSegmentReady, slices.Clone[[]float32])
+	}
+	if cb.OnSegment != nil {
+		// The callback owns the Segment, so it needs no copy.
+		w.OnSegment = watch1(watch("OnSegment"), cb.OnSegment, nil)
+	}
+	if cb.OnTurnMerged != nil {
+		w.OnTurnMerged = watch1(watch("OnTurnMerged"), cb.OnTurnMerged, nil)
+	}
+	if cb.OnTurnSplit != nil {
+		w.OnTurnSplit = watch1(watch("OnTurnSplit"), cb.OnTurnSplit, nil)
+	}
+	if cb.OnTurnPrediction != nil {
+		w.OnTurnPrediction = watch2(watch("OnTurnPrediction"), cb.OnTurnPrediction)
+	}
+	if cb.OnTurnPredictionDetail != nil {
+		w.OnTurnPredictionDetail = watch1(watch("OnTurnPredictionDetail"), cb.OnTurnPredictionDetail, cloneTurnPrediction)
+	}
+	if cb.OnShadowPrediction != nil {
+		w.OnShadowPrediction = watch1(watch("OnShadowPrediction"), cb.OnShadowPrediction, func(s ShadowPrediction) ShadowPrediction {
+			s.Primary = cloneTurnPrediction(s.Primary)
+			return s
+		})
+	}
+	if cb.OnTranscript != nil {
+		w.OnTranscript = watch1(watch("OnTranscript"), cb.OnTranscript, nil)
+	}
+	if cb.OnOverload != nil {
+		w.OnOverload = watch1(watch("OnOverload"), cb.OnOverload, nil)
+	}
+	if cb.OnAudioDropped != nil {
+		w.OnAudioDropped = watch1(watch("OnAudioDropped"), cb.OnAudioDropped, nil)
+	}
+	if cb.OnAudioEvicted != nil {
+		w.OnAudioEvicted = watch1(watch("OnAudioEvicted"), cb.OnAudioEvicted, nil)
+	}
+	if cb.OnError != nil {
+		w.OnError = watch1(watch("OnError"), cb.OnError, nil)
+	}
+	if cb.OnVadScore != nil {
+		w.OnVadScore = watch2(watch("OnVadScore"), cb.OnVadScore)
+	}
+	return w
+}
+
+// watch0 wraps a callback without arguments.
+func watch0(w *callbackWatch, f func()) func() {
+	return func() {
+		if w.e.callbacksAsync {
+			w.enqueue(f)
+			return
+		}
+		start := w.e.clock.Now()
+		f()
+		w.took(w.e.clock.Now().Sub(start))
+	}
+}
+
+// watch1 wraps a callback of one argument; clone, if set, copies engine
+// buffers out of the argument for an asynchronous call.
+func watch1[T any](w *callbackWatch, f func(T), clone func(T) T) func(T) {
+	return func(v T) {
+		if w.e.callbacksAsync {
+			if clone != nil {
+				v = clone(v)
+			}
+			w.enqueue(func() { f(v) })
+			return
+		}
+		start := w.e.clock.Now()
+		f(v)
+		w.took(w.e.clock.Now().Sub(start))
+	}
+}
+
+// watch2 wraps a callback of two arguments the engine does not reuse.
+func watch2[A, B any](w *callbackWatch, f func(A, B)) func(A, B) {
+	return func(a A, b B) {
+		if w.e.callbacksAsync {
+			w.enqueue(func() { f(a, b) })
+			return
+		}
+		start := w.e.clock.Now()
+		f(a, b)
+		w.took(w.e.clock.Now().Sub(start))
+	}
+}
+
+func cloneTurnPrediction(p TurnPrediction) TurnPrediction {
+	p.AuxOutputs = slices.Clone(p.AuxOutputs)
+	for i := range p.AuxOutputs {
+		p.AuxOutputs[i].Data = slices.Clone(p.AuxOutputs[i].Data)
+	}
+	return p
+}
+
+// took checks a synchronous call of d against the budget.
+func (w *callbackWatch) took(d time.Duration) {
+	wd := w.e.cfg.CallbackWatchdog
+	if d <= wd.Budget {
+		return
+	}
+	w.e.callbacksAsync = wd.Async
+	s := SlowCallback{Callback: w.name, Duration: d, Budget: wd.Budget, Async: wd.Async}
+	w.e.log.Warn("callback exceeded its budget",
+		"callback", s.Callback, "duration_ms", d.Milliseconds(),
+		"budget_ms", wd.Budget.Milliseconds(), "async", s.Async)
+	if w.e.cb.OnSlowCallback != nil {
+		w.e.cb.OnSlowCallback(s)
+	}
+}
+
+// enqueue hands a call to the dispatch goroutine, dropping it when the
+// queue is full. Calls come from the engine goroutine under callMu, which
+// release also holds when it closes the queue.
+func (w *callbackWatch) enqueue(call func()) {
+	select {
+	case w.e.asyncCalls <- call:
+	default:
+		w.e.log.Warn("async callback dropped: queue full", "callback", w.name)
+	}
+}
+
+// dispatchCallbacks runs asynchronous callbacks until calls is closed,
+// skipping those still queued once Close was called.
+func (e *Engine) dispatchCallbacks(calls <-chan func()) {
+	for call := range calls {
+		if !e.closing.Load() {
+			e.runAsync(call)
+		}
+	}
+}
+
+// runAsync runs one asynchronous callback, logging a panic. It cannot go
+// through OnError, which may be running on the engine goroutine.
+func (e *Engine) runAsync(call func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			e.log.Error("async callback panicked", "panic", v, "stack", string(debug.Stack()))
+			e.health.error(ErrCallbackPanic, e.clock.Now())
+		}
+	}()
+	call()
+}
@@ -0,0 +1,113 @@
+package smartturn_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+)
+
+// watchdogEngine returns an engine whose first OnTurnStart takes 50 ms on
+// the engine's clock, against a 10 ms CallbackWatchdog budget, and the
+// callbacks it ran, in order. extra runs inside OnTurnEnd.
+func watchdogEngine(t *testing.T, async bool, slow *[]smartturn.SlowCallback, extra func()) (*smartturn.Engine, func() []string) {
+	t.Helper()
+	clk := smartturntest.NewClock(time.Unix(0, 0))
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	add := func(s string) {
+		mu.Lock()
+		calls = append(calls, s)
+		mu.Unlock()
+	}
+	starts := 0
+	e := newTestEngine(t, []float32{0.9}, smartturn.Callbacks{
+		OnTurnStart: func(smartturn.TurnStart) {
+			add("turn start")
+			if starts++; starts == 1 {
+				clk.Advance(50 * time.Millisecond)
+			}
+		},
+		OnSpeechStart: func() { add("speech start") },
+		OnTurnEnd: func(smartturn.TurnEndReason) {
+			add("turn end")
+			if extra != nil {
+				extra()
+			}
+		},
+		OnSpeechEnd:    func() { add("speech end") },
+		OnSlowCallback: func(s smartturn.SlowCallback) { *slow = append(*slow, s) },
+	}, func(cfg *smartturn.Config) {
+		cfg.Clock = clk
+		cfg.CallbackWatchdog = smartturn.CallbackWatchdog{Budget: 10 * time.Millisecond, Async: async}
+	})
+	return e, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), calls...)
+	}
+}
+
+const twoTurns = "turn start,speech start,turn end,speech end,turn start,speech start,turn end,speech end"
+
+// TestCallbackWatchdog checks that a call over budget is reported to
+// OnSlowCallback and, without Async, changes nothing else.
+func TestCallbackWatchdog(t *testing.T) {
+	var slow []smartturn.SlowCallback
+	e, calls := watchdogEngine(t, false, &slow, nil)
+	for _, c := range speechTurns(2) {
+		if err := e.PushPCM(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := strings.Join(calls(), ","); got != twoTurns {
+		t.Errorf("callbacks %q, want %q", got, twoTurns)
+	}
+	want := smartturn.SlowCallback{Callback: "OnTurnStart", Duration: 50 * time.Millisecond, Budget: 10 * time.Millisecond}
+	if len(slow) != 1 || slow[0] != want {
+		t.Errorf("OnSlowCallback %+v, want %+v", slow, want)
+	}
+}
+
+// TestCallbackWatchdogAsync checks that once a callback exceeded the
+// budget, every callback runs on the dispatch goroutine, in the order the
+// engine emitted them, while the engine goes on.
+func TestCallbackWatchdogAsync(t *testing.T) {
+	var slow []smartturn.SlowCallback
+	release := make(chan struct{})
+	blocked := false
+	e, calls := watchdogEngine(t, true, &slow, func() {
+		if !blocked {
+			// The first OnTurnEnd holds the dispatch goroutine; PushPCM
+			// must not wait for it.
+			blocked = true
+			select {
+			case <-release:
+			case <-time.After(5 * time.Second):
+				t.Error("OnTurnEnd ran on the engine goroutine")
+			}
+		}
+	})
+	for _, c := range speechTurns(2) {
+		if err := e.PushPCM(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(calls()) < 8 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := strings.Join(calls(), ","); got != twoTurns {
+		t.Errorf("callbacks %q, want %q", got, twoTurns)
+	}
+	want := smartturn.SlowCallback{Callback: "OnTurnStart", Duration: 50 * time.Millisecond, Budget: 10 * time.Millisecond, Async: true}
+	if len(slow) != 1 || slow[0] != want {
+		t.Errorf("OnSlowCallback %+v, want %+v", slow, want)
+	}
+}
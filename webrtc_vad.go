@@ -0,0 +1,87 @@
+package smartturn
+
+import (
+	"fmt"
+
+	"github.com/cortexswarm/smart-turn-go/webrtcvad"
+)
+
+// VADKind selects the built-in VAD used when Config.VADBackend is nil.
+type VADKind string
+
+const (
+	// VADSilero is the default Silero ONNX model (SileroVADModelPath).
+	VADSilero VADKind = ""
+	// VADWebRTC is the WebRTC GMM detector (WebRTCVAD): no model file or
+	// ONNX Runtime, at a fraction of Silero's CPU, but more false alarms
+	// in noise and more clipped word edges.
+	VADWebRTC VADKind = "webrtc"
+)
+
+// webrtcFrame is the longest WebRTC VAD frame used: 10 ms at 16 kHz.
+const webrtcFrame = RequiredSampleRate / 100
+
+// WebRTCVAD is a VADBackend running the WebRTC voice activity detector
+// (package webrtcvad) on 10 ms frames, at 8 kHz natively for 256-sample
+// chunks. A chunk's probability is the share
+// of the frames completed in it that the detector called speech, so it is
+// coarse (quarters or thirds) and needs no tuning of VadThreshold.
+type WebRTCVAD struct {
+	vad   *webrtcvad.VAD
+	frame [webrtcFrame]int16 // samples carried to the next chunk
+	n     int
+}
+
+// NewWebRTCVAD returns a WebRTC VAD with the given aggressiveness.
+func NewWebRTCVAD(mode webrtcvad.Mode) (*WebRTCVAD, error) {
+	v, err := webrtcvad.New(mode)
+	if err != nil {
+		return nil, fmt.Errorf("smart-turn: %w", err)
+	}
+	return &WebRTCVAD{vad: v}, nil
+}
+
+// SpeechProb implements VADBackend.
+func (v *WebRTCVAD) SpeechProb(chunk []float32) (float32, error) {
+	rate := RequiredSampleRate
+	switch len(chunk) {
+	case RequiredChunkSize:
+	case TelephonyChunkSize:
+		rate = TelephonySampleRate
+	default:
+		return 0, errVADChunkSize
+	}
+	frame := v.frame[:rate/100]
+	var frames, voiced int // 3 or 4 frames end in a 32 ms chunk
+	for _, s := range chunk {
+		if s > 1 {
+			s = 1
+		} else if s < -1 {
+			s = -1
+		}
+		v.frame[v.n] = int16(s * 32767)
+		v.n++
+		if v.n < len(frame) {
+			continue
+		}
+		v.n = 0
+		speech, err := v.vad.Process(rate, frame)
+		if err != nil {
+			return 0, err
+		}
+		frames++
+		if speech {
+			voiced++
+		}
+	}
+	return float32(voiced) / float32(frames), nil
+}
+
+// Reset implements VADBackend.
+func (v *WebRTCVAD) Reset() {
+	v.vad.Reset()
+	v.n = 0
+}
+
+// Close implements VADBackend.
+func (v *WebRTCVAD) Close() error { return nil }
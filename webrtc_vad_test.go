@@ -0,0 +1,111 @@
+package smartturn_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+	"github.com/cortexswarm/smart-turn-go/webrtcvad"
+)
+
+// TestWebRTCVAD checks that a chunk's probability is the share of the
+// 10 ms frames completed in it called speech.
+func TestWebRTCVAD(t *testing.T) {
+	audio, spans := smartturntest.Synth{Seed: 10, Noise: 0.003}.Generate(
+		smartturntest.Silence(time.Second), smartturntest.Speech(time.Second), smartturntest.Silence(time.Second))
+	v, err := smartturn.NewWebRTCVAD(webrtcvad.Aggressive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 512-sample chunks complete 3 or 4 160-sample frames.
+	shares := map[float32]bool{0: true, 1. / 3: true, 2. / 3: true, 1: true, 0.25: true, 0.5: true, 0.75: true}
+	p := scores(t, v, audio)
+	for i, s := range p {
+		if !shares[s] {
+			t.Fatalf("chunk %d scored %v, not a share of 3 or 4 frames", i, s)
+		}
+	}
+	speech := spans[1]
+	for i := speech.StartChunk() + 1; i < speech.EndChunk()-1; i++ {
+		if p[i] != 1 {
+			t.Errorf("chunk %d inside speech scored %v", i, p[i])
+		}
+	}
+	// After the first second's adaptation, and past the hangover.
+	for i := spans[2].StartChunk() + 8; i < len(p); i++ {
+		if p[i] != 0 {
+			t.Errorf("chunk %d inside silence scored %v", i, p[i])
+		}
+	}
+
+	// Reset restarts both the detector and the frame carried over.
+	v.Reset()
+	again := scores(t, v, audio)
+	for i := range p {
+		if again[i] != p[i] {
+			t.Fatalf("chunk %d after Reset scored %v, want %v", i, again[i], p[i])
+		}
+	}
+
+	if _, err := v.SpeechProb(make([]float32, smartturn.TelephonyChunkSize)); err != nil {
+		t.Errorf("8 kHz chunk: %v", err)
+	}
+	if _, err := v.SpeechProb(make([]float32, 100)); err == nil {
+		t.Error("100-sample chunk accepted")
+	}
+	if _, err := smartturn.NewWebRTCVAD(9); err == nil {
+		t.Error("mode 9 accepted")
+	}
+	chunk := smartturntest.Chunks(audio[16000:])[0]
+	if allocs := testing.AllocsPerRun(100, func() { _, _ = v.SpeechProb(chunk) }); allocs != 0 {
+		t.Errorf("SpeechProb allocates %v times per chunk", allocs)
+	}
+}
+
+// TestVADEngineWebRTC checks that VADEngine selects the WebRTC VAD, with
+// no Silero model file needed.
+func TestVADEngineWebRTC(t *testing.T) {
+	cfg := benchConfig()
+	cfg.VadStopMs = 300
+	cfg.VADEngine = smartturn.VADWebRTC
+	cfg.WebRTCVADMode = webrtcvad.Aggressive
+	cfg.TurnBackend = &smartturntest.TurnScript{Probabilities: []float32{0.9}}
+	var got []string
+	e, err := smartturn.New(cfg, smartturn.Callbacks{
+		OnSpeechStart: func() { got = append(got, "start") },
+		OnSpeechEnd:   func() { got = append(got, "end") },
+		OnError:       func(err error) { t.Error(err) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	e.Start()
+	// Without noise: the detector's initial models call the first frames
+	// of a noisy stream speech until they adapt.
+	audio, _ := smartturntest.Synth{Seed: 10}.Generate(
+		smartturntest.Silence(time.Second), smartturntest.Speech(time.Second), smartturntest.Silence(time.Second))
+	pushAll(t, e, audio)
+	if s := strings.Join(got, " "); s != "start end" {
+		t.Errorf("callbacks %q, want \"start end\"", s)
+	}
+
+	for _, tc := range []struct {
+		engine smartturn.VADKind
+		mode   webrtcvad.Mode
+		want   string
+	}{
+		{"rnnoise", 0, "VADEngine"},
+		{smartturn.VADWebRTC, -1, "WebRTCVADMode"},
+		{smartturn.VADWebRTC, 4, "WebRTCVADMode"},
+		// Silero still needs its model.
+		{smartturn.VADSilero, 0, "SileroVADModelPath"},
+	} {
+		cfg.VADEngine, cfg.WebRTCVADMode = tc.engine, tc.mode
+		if _, err := smartturn.New(cfg, smartturn.Callbacks{}); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("VADEngine %q, mode %d: error %v, want %q", tc.engine, tc.mode, err, tc.want)
+		}
+	}
+}
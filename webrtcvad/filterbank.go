@@ -0,0 +1,203 @@
+package webrtcvad
+
+import "math/bits"
+
+// Filter bank constants (vad_filterbank.c, vad_sp.c).
+var (
+	hpZeroCoefs      = [3]int16{6631, -13262, 6631} // Q14
+	hpPoleCoefs      = [3]int16{16384, -7756, 5620} // Q14
+	allPassCoefsQ15  = [2]int16{20972, 5571}        // upper 0.64, lower 0.17
+	allPassCoefsQ13  = [2]int16{5243, 1392}
+	logEnergyOffsets = [numChannels]int16{368, 368, 272, 176, 176, 176}
+)
+
+const (
+	logConst         = 24660 // 160*log10(2) in Q9
+	logEnergyIntPart = 14336 // 14 in Q10
+)
+
+// downsample halves the sample rate of in into out with a pair of allpass
+// filters.
+func downsample(in, out []int16, state *[2]int32) {
+	s1, s2 := state[0], state[1]
+	for n := range out {
+		x := int32(in[2*n])
+		t1 := int16((s1 >> 1) + ((int32(allPassCoefsQ13[0]) * x) >> 14))
+		s1 = x - ((int32(allPassCoefsQ13[0]) * int32(t1)) >> 12)
+		y := int32(in[2*n+1])
+		t2 := int16((s2 >> 1) + ((int32(allPassCoefsQ13[1]) * y) >> 14))
+		s2 = y - ((int32(allPassCoefsQ13[1]) * int32(t2)) >> 12)
+		out[n] = t1 + t2
+	}
+	state[0], state[1] = s1, s2
+}
+
+// calculateFeatures splits an 8 kHz frame into six bands (80-250, 250-500,
+// 500-1000, 1000-2000, 2000-3000, 3000-4000 Hz) and stores their log
+// energies (Q4) in features. It returns an indicator of the frame's total
+// energy, compared with minEnergy.
+func (v *VAD) calculateFeatures(in []int16, features *[numChannels]int16) int16 {
+	var (
+		totalEnergy  int16
+		hp120, lp120 [120]int16
+		hp60, lp60   [60]int16
+	)
+	half := len(in) >> 1
+
+	// Split at 2000 Hz, then the upper band at 3000 Hz.
+	splitFilter(in, &v.upperState[0], &v.lowerState[0], hp120[:half], lp120[:half])
+	n := half >> 1
+	splitFilter(hp120[:half], &v.upperState[1], &v.lowerState[1], hp60[:n], lp60[:n])
+	features[5] = logOfEnergy(hp60[:n], logEnergyOffsets[5], &totalEnergy)
+	features[4] = logOfEnergy(lp60[:n], logEnergyOffsets[4], &totalEnergy)
+
+	// Split the lower band at 1000 Hz.
+	splitFilter(lp120[:half], &v.upperState[2], &v.lowerState[2], hp60[:n], lp60[:n])
+	features[3] = logOfEnergy(hp60[:n], logEnergyOffsets[3], &totalEnergy)
+
+	// Split 0-1000 Hz at 500 Hz.
+	m := n >> 1
+	splitFilter(lp60[:n], &v.upperState[3], &v.lowerState[3], hp120[:m], lp120[:m])
+	features[2] = logOfEnergy(hp120[:m], logEnergyOffsets[2], &totalEnergy)
+
+	// Split 0-500 Hz at 250 Hz.
+	k := m >> 1
+	splitFilter(lp120[:m], &v.upperState[4], &v.lowerState[4], hp60[:k], lp60[:k])
+	features[1] = logOfEnergy(hp60[:k], logEnergyOffsets[1], &totalEnergy)
+
+	// Remove 0-80 Hz from the lowest band.
+	highPassFilter(lp60[:k], &v.hpFilterState, hp120[:k])
+	features[0] = logOfEnergy(hp120[:k], logEnergyOffsets[0], &totalEnergy)
+	return totalEnergy
+}
+
+// splitFilter splits in into its upper and lower half bands, each at half
+// the sample rate; len(hp) = len(lp) = len(in)/2.
+func splitFilter(in []int16, upperState, lowerState *int16, hp, lp []int16) {
+	allPassFilter(in, allPassCoefsQ15[0], upperState, hp)
+	allPassFilter(in[1:], allPassCoefsQ15[1], lowerState, lp)
+	for i := range hp {
+		t := hp[i]
+		hp[i] -= lp[i]
+		lp[i] += t
+	}
+}
+
+// allPassFilter filters every other sample of in into out (Q-1).
+func allPassFilter(in []int16, coef int16, state *int16, out []int16) {
+	state32 := int32(*state) * (1 << 16) // Q15
+	for i := range out {
+		x := int32(in[2*i])
+		t := int16((state32 + int32(coef)*x) >> 16)
+		out[i] = t
+		state32 = (x*(1<<14) - int32(coef)*int32(t)) * 2
+	}
+	*state = int16(state32 >> 16)
+}
+
+// highPassFilter removes frequencies below 80 Hz from a band sampled at
+// 500 Hz.
+func highPassFilter(in []int16, state *[4]int16, out []int16) {
+	for i, x := range in {
+		t := int32(hpZeroCoefs[0])*int32(x) + int32(hpZeroCoefs[1])*int32(state[0]) + int32(hpZeroCoefs[2])*int32(state[1])
+		state[1] = state[0]
+		state[0] = x
+		t -= int32(hpPoleCoefs[1])*int32(state[2]) + int32(hpPoleCoefs[2])*int32(state[3])
+		state[3] = state[2]
+		state[2] = int16(t >> 14)
+		out[i] = state[2]
+	}
+}
+
+// logOfEnergy returns 10*log10 of the energy of in (Q4) plus offset, and
+// raises totalEnergy while it is at most minEnergy.
+func logOfEnergy(in []int16, offset int16, totalEnergy *int16) int16 {
+	e, rshifts := energy(in)
+	if e == 0 {
+		return offset
+	}
+	en := uint32(e)
+	// Normalize to 15 bits; log2 is then 14 plus the fraction, in Q10.
+	norm := 17 - normU32(en)
+	rshifts += norm
+	if norm < 0 {
+		en <<= -norm
+	} else {
+		en >>= norm
+	}
+	log2Energy := int16(logEnergyIntPart + (en&0x3FFF)>>4)
+	logEnergy := int16((logConst*int32(log2Energy))>>19 + (int32(rshifts)*logConst)>>9)
+	if logEnergy < 0 {
+		logEnergy = 0
+	}
+	logEnergy += offset
+
+	if *totalEnergy <= minEnergy {
+		if rshifts >= 0 {
+			*totalEnergy += minEnergy + 1
+		} else {
+			*totalEnergy += int16(en >> -rshifts)
+		}
+	}
+	return logEnergy
+}
+
+// energy returns the energy of v, right-shifted by scale so it fits in 32
+// bits.
+func energy(v []int16) (e int32, scale int) {
+	scale = scalingSquare(v, len(v))
+	for _, x := range v {
+		e += (int32(x) * int32(x)) >> scale
+	}
+	return e, scale
+}
+
+// scalingSquare returns the right shift needed to sum times squares of
+// the largest magnitude in v without overflow.
+func scalingSquare(v []int16, times int) int {
+	nbits := 32 - bits.LeadingZeros32(uint32(times))
+	smax := int16(-1)
+	for _, x := range v {
+		if x <= 0 {
+			x = -x
+		}
+		if x > smax {
+			smax = x
+		}
+	}
+	if smax == 0 {
+		return 0
+	}
+	t := normW32(int32(smax) * int32(smax))
+	if t > nbits {
+		return 0
+	}
+	return nbits - t
+}
+
+// normW32 returns the left shift that normalizes a, 0 for 0.
+func normW32(a int32) int {
+	if a == 0 {
+		return 0
+	}
+	if a < 0 {
+		a = ^a
+	}
+	return bits.LeadingZeros32(uint32(a)) - 1
+}
+
+// normU32 returns the number of leading zeros of a, 0 for 0.
+func normU32(a uint32) int {
+	if a == 0 {
+		return 0
+	}
+	return bits.LeadingZeros32(a)
+}
+
+// divW32W16 divides with the C library's guard against division by zero.
+func divW32W16(num int32, den int16) int32 {
+	if den == 0 {
+		return 0x7FFFFFFF
+	}
+	return num / int32(den)
+}
@@ -0,0 +1,429 @@
+// Package webrtcvad is a pure-Go port of the WebRTC voice activity detector
+// (common_audio/vad): a Gaussian mixture model over six sub-band energies
+// that adapts to the background noise. It needs no model file and little
+// CPU or memory, at some cost in accuracy compared with neural VADs.
+//
+// The port is bit-exact with the fixed-point C implementation for 8 and
+// 16 kHz input. WebRTC is Copyright (c) 2011, The WebRTC project authors,
+// under the BSD license in the LICENSE file of this directory.
+package webrtcvad
+
+import "errors"
+
+// Mode is the detector's aggressiveness: higher modes report speech less
+// often, trading missed speech for fewer false alarms in noise.
+type Mode int
+
+const (
+	Quality Mode = iota
+	LowBitrate
+	Aggressive
+	VeryAggressive
+)
+
+var (
+	errMode  = errors.New("webrtcvad: mode must be Quality, LowBitrate, Aggressive, or VeryAggressive")
+	errFrame = errors.New("webrtcvad: frame must be 10, 20, or 30 ms of 8 or 16 kHz audio")
+)
+
+const (
+	numChannels  = 6 // frequency bands
+	numGaussians = 2 // per band and model
+	tableSize    = numChannels * numGaussians
+	minEnergy    = 10 // frame energy below which the models are not updated
+)
+
+// Model constants, in the Q formats of the C implementation.
+var (
+	spectrumWeight    = [numChannels]int16{6, 8, 10, 12, 14, 16}
+	minimumDifference = [numChannels]int16{544, 544, 576, 576, 576, 576}             // Q5
+	maximumSpeech     = [numChannels]int16{11392, 11392, 11520, 11520, 11520, 11520} // Q7
+	minimumMean       = [numGaussians]int16{640, 768}
+	maximumNoise      = [numChannels]int16{9216, 9088, 8960, 8832, 8704, 8576} // Q7
+
+	noiseDataWeights  = [tableSize]int16{34, 62, 72, 66, 53, 25, 94, 66, 56, 62, 75, 103}
+	speechDataWeights = [tableSize]int16{48, 82, 45, 87, 50, 47, 80, 46, 83, 41, 78, 81}
+	noiseDataMeans    = [tableSize]int16{6738, 4892, 7065, 6715, 6771, 3369, 7646, 3863, 7820, 7266, 5020, 4362}
+	speechDataMeans   = [tableSize]int16{8306, 10085, 10078, 11823, 11843, 6309, 9473, 9571, 10879, 7581, 8180, 7483}
+	noiseDataStds     = [tableSize]int16{378, 1064, 493, 582, 688, 593, 474, 697, 475, 688, 421, 455}
+	speechDataStds    = [tableSize]int16{555, 505, 567, 524, 585, 1231, 509, 828, 492, 1540, 1079, 850}
+)
+
+const (
+	noiseUpdateConst  = 655  // Q15
+	speechUpdateConst = 6554 // Q15
+	backEta           = 154  // Q8
+	maxSpeechFrames   = 6
+	minStd            = 384
+)
+
+// modeThresholds holds the hangover lengths and the local and global
+// likelihood-ratio thresholds of a Mode, for 10, 20, and 30 ms frames.
+type modeThresholds struct {
+	overHangMax1, overHangMax2, individual, total [3]int16
+}
+
+var modes = [...]modeThresholds{
+	Quality:        {[3]int16{8, 4, 3}, [3]int16{14, 7, 5}, [3]int16{24, 21, 24}, [3]int16{57, 48, 57}},
+	LowBitrate:     {[3]int16{8, 4, 3}, [3]int16{14, 7, 5}, [3]int16{37, 32, 37}, [3]int16{100, 80, 100}},
+	Aggressive:     {[3]int16{6, 3, 2}, [3]int16{9, 5, 3}, [3]int16{82, 78, 82}, [3]int16{285, 260, 285}},
+	VeryAggressive: {[3]int16{6, 3, 2}, [3]int16{9, 5, 3}, [3]int16{94, 94, 94}, [3]int16{1100, 1050, 1100}},
+}
+
+// VAD is one detector instance. It keeps state across frames and is not
+// safe for concurrent use.
+type VAD struct {
+	mode modeThresholds
+
+	downsamplingState [2]int32
+	noiseMeans        [tableSize]int16
+	speechMeans       [tableSize]int16
+	noiseStds         [tableSize]int16
+	speechStds        [tableSize]int16
+	frameCounter      int32
+	overHang          int16
+	numOfSpeech       int16
+	ageVector         [16 * numChannels]int16
+	lowValueVector    [16 * numChannels]int16
+	meanValue         [numChannels]int16
+	upperState        [5]int16
+	lowerState        [5]int16
+	hpFilterState     [4]int16
+
+	narrowband [240]int16 // 16 kHz frame downsampled to 8 kHz
+}
+
+// New returns a detector with the given aggressiveness.
+func New(mode Mode) (*VAD, error) {
+	v := &VAD{}
+	if err := v.SetMode(mode); err != nil {
+		return nil, err
+	}
+	v.Reset()
+	return v, nil
+}
+
+// SetMode changes the aggressiveness; the adapted models are kept.
+func (v *VAD) SetMode(mode Mode) error {
+	if mode < Quality || mode > VeryAggressive {
+		return errMode
+	}
+	v.mode = modes[mode]
+	return nil
+}
+
+// Reset returns the detector to its initial models, keeping the mode.
+func (v *VAD) Reset() {
+	m := v.mode
+	*v = VAD{mode: m}
+	v.noiseMeans = noiseDataMeans
+	v.speechMeans = speechDataMeans
+	v.noiseStds = noiseDataStds
+	v.speechStds = speechDataStds
+	for i := range v.lowValueVector {
+		v.lowValueVector[i] = 10000
+	}
+	for i := range v.meanValue {
+		v.meanValue[i] = 1600
+	}
+}
+
+// Process reports whether a frame of 16-bit PCM at sampleRate (8000 or
+// 16000) holds speech. The frame must be 10, 20, or 30 ms long.
+func (v *VAD) Process(sampleRate int, frame []int16) (bool, error) {
+	n := len(frame)
+	if (sampleRate != 8000 && sampleRate != 16000) || (n != sampleRate/100 && n != sampleRate/50 && n != sampleRate*3/100) {
+		return false, errFrame
+	}
+	if sampleRate == 16000 {
+		n /= 2
+		downsample(frame, v.narrowband[:n], &v.downsamplingState)
+		frame = v.narrowband[:n]
+	}
+	var features [numChannels]int16
+	totalPower := v.calculateFeatures(frame, &features)
+	return v.gmmProbability(&features, totalPower, n) > 0, nil
+}
+
+// gmmProbability runs the likelihood-ratio test of speech against noise
+// on one frame's features, updates the models with the outcome, and
+// returns the decision with hangover applied (0 is noise).
+func (v *VAD) gmmProbability(features *[numChannels]int16, totalPower int16, frameLength int) int16 {
+	var (
+		deltaN, deltaS   [tableSize]int16
+		ngprvec, sgprvec [tableSize]int16
+		noiseProb        [numGaussians]int32
+		speechProb       [numGaussians]int32
+		sumLLR           int32
+		vadflag          int16
+	)
+	th := 2
+	switch frameLength {
+	case 80:
+		th = 0
+	case 160:
+		th = 1
+	}
+	overhead1, overhead2 := v.mode.overHangMax1[th], v.mode.overHangMax2[th]
+	individualTest, totalTest := v.mode.individual[th], v.mode.total[th]
+
+	if totalPower > minEnergy {
+		for ch := 0; ch < numChannels; ch++ {
+			var h0Test, h1Test int32
+			for k := 0; k < numGaussians; k++ {
+				g := ch + k*numChannels
+				p := gaussianProbability(features[ch], v.noiseMeans[g], v.noiseStds[g], &deltaN[g])
+				noiseProb[k] = int32(noiseDataWeights[g]) * p
+				h0Test += noiseProb[k] // Q27
+				p = gaussianProbability(features[ch], v.speechMeans[g], v.speechStds[g], &deltaS[g])
+				speechProb[k] = int32(speechDataWeights[g]) * p
+				h1Test += speechProb[k] // Q27
+			}
+			// log2 of the likelihood ratio, approximated by the difference
+			// of the normalizing shifts.
+			shiftsH0, shiftsH1 := normW32(h0Test), normW32(h1Test)
+			if h0Test == 0 {
+				shiftsH0 = 31
+			}
+			if h1Test == 0 {
+				shiftsH1 = 31
+			}
+			llr := shiftsH0 - shiftsH1
+			sumLLR += int32(llr) * int32(spectrumWeight[ch])
+			if int32(llr)*4 > int32(individualTest) {
+				vadflag = 1
+			}
+			// Conditional probabilities of each Gaussian, for the updates.
+			if h0 := int16(h0Test >> 12); h0 > 0 {
+				t := int32((uint32(noiseProb[0]) & 0xFFFFF000) << 2) // Q29
+				ngprvec[ch] = int16(divW32W16(t, h0))                // Q14
+				ngprvec[ch+numChannels] = 16384 - ngprvec[ch]
+			} else {
+				ngprvec[ch] = 16384
+			}
+			if h1 := int16(h1Test >> 12); h1 > 0 {
+				t := int32((uint32(speechProb[0]) & 0xFFFFF000) << 2)
+				sgprvec[ch] = int16(divW32W16(t, h1))
+				sgprvec[ch+numChannels] = 16384 - sgprvec[ch]
+			}
+		}
+		if sumLLR >= int32(totalTest) {
+			vadflag = 1
+		}
+
+		maxspe := int16(12800)
+		for ch := 0; ch < numChannels; ch++ {
+			featureMinimum := v.findMinimum(features[ch], ch)
+			noiseGlobalMean := weightedAverage(&v.noiseMeans, ch, 0, &noiseDataWeights)
+			globalQ8 := int16(noiseGlobalMean >> 6)
+
+			for k := 0; k < numGaussians; k++ {
+				g := ch + k*numChannels
+				nmk, smk := v.noiseMeans[g], v.speechMeans[g]
+				nsk, ssk := v.noiseStds[g], v.speechStds[g]
+
+				// Noise mean: follow noise frames, plus a long term
+				// correction toward the feature minimum.
+				nmk2 := nmk
+				if vadflag == 0 {
+					delt := int16((int32(ngprvec[g]) * int32(deltaN[g])) >> 11)
+					nmk2 = nmk + int16((int32(delt)*noiseUpdateConst)>>22)
+				}
+				ndelt := int16(int32(featureMinimum)<<4 - int32(globalQ8))
+				nmk3 := nmk2 + int16((int32(ndelt)*backEta)>>9)
+				if lo := int16((k + 5) << 7); nmk3 < lo {
+					nmk3 = lo
+				}
+				if hi := int16((72 + k - ch) << 7); nmk3 > hi {
+					nmk3 = hi
+				}
+				v.noiseMeans[g] = nmk3
+
+				if vadflag != 0 {
+					// Speech mean and std follow speech frames.
+					delt := int16((int32(sgprvec[g]) * int32(deltaS[g])) >> 11)
+					t16 := int16((int32(delt) * speechUpdateConst) >> 21)
+					smk2 := int16(int32(smk) + (int32(t16)+1)>>1)
+					maxmu := maxspe + 640
+					if smk2 < minimumMean[k] {
+						smk2 = minimumMean[k]
+					}
+					if smk2 > maxmu {
+						smk2 = maxmu
+					}
+					v.speechMeans[g] = smk2
+
+					t16 = int16((int32(smk) + 4) >> 3)
+					t16 = features[ch] - t16
+					t1 := (int32(deltaS[g]) * int32(t16)) >> 3
+					t2 := t1 - 4096
+					t1 = int32(sgprvec[g]>>2) * t2
+					t2 = t1 >> 4 // Q20
+					den := int16(int32(ssk) * 10)
+					if t2 > 0 {
+						t16 = int16(divW32W16(t2, den))
+					} else {
+						t16 = int16(divW32W16(-t2, den))
+						t16 = -t16
+					}
+					t16 += 128
+					ssk += t16 >> 8
+					if ssk < minStd {
+						ssk = minStd
+					}
+					v.speechStds[g] = ssk
+				} else {
+					t16 := features[ch] - (nmk >> 3)
+					t1 := (int32(deltaN[g]) * int32(t16)) >> 3
+					t1 -= 4096
+					t16 = (ngprvec[g] + 2) >> 2
+					t1 = (int32(t16) * t1) >> 14 // may wrap, as in C
+					if t1 > 0 {
+						t16 = int16(divW32W16(t1, nsk))
+					} else {
+						t16 = int16(divW32W16(-t1, nsk))
+						t16 = -t16
+					}
+					t16 += 32
+					nsk += t16 >> 6
+					if nsk < minStd {
+						nsk = minStd
+					}
+					v.noiseStds[g] = nsk
+				}
+			}
+
+			// Separate the models if they are too close.
+			noiseGlobalMean = weightedAverage(&v.noiseMeans, ch, 0, &noiseDataWeights)
+			speechGlobalMean := weightedAverage(&v.speechMeans, ch, 0, &speechDataWeights)
+			diff := int16(speechGlobalMean>>9) - int16(noiseGlobalMean>>9)
+			if diff < minimumDifference[ch] {
+				t := minimumDifference[ch] - diff
+				speechGlobalMean = weightedAverage(&v.speechMeans, ch, int16((13*int32(t))>>2), &speechDataWeights)
+				noiseGlobalMean = weightedAverage(&v.noiseMeans, ch, -int16((3*int32(t))>>2), &noiseDataWeights)
+			}
+
+			// Keep the means from drifting too far.
+			maxspe = maximumSpeech[ch]
+			if t := int16(speechGlobalMean >> 7); t > maxspe {
+				for k := 0; k < numGaussians; k++ {
+					v.speechMeans[ch+k*numChannels] -= t - maxspe
+				}
+			}
+			if t := int16(noiseGlobalMean >> 7); t > maximumNoise[ch] {
+				for k := 0; k < numGaussians; k++ {
+					v.noiseMeans[ch+k*numChannels] -= t - maximumNoise[ch]
+				}
+			}
+		}
+		v.frameCounter++
+	}
+
+	// Hangover: keep reporting speech for a few frames after it stops.
+	if vadflag == 0 {
+		if v.overHang > 0 {
+			vadflag = 2 + v.overHang
+			v.overHang--
+		}
+		v.numOfSpeech = 0
+	} else {
+		v.numOfSpeech++
+		if v.numOfSpeech > maxSpeechFrames {
+			v.numOfSpeech = maxSpeechFrames
+			v.overHang = overhead2
+		} else {
+			v.overHang = overhead1
+		}
+	}
+	return vadflag
+}
+
+// weightedAverage adds offset to the means of channel ch and returns their
+// average weighted by weights.
+func weightedAverage(data *[tableSize]int16, ch int, offset int16, weights *[tableSize]int16) int32 {
+	var avg int32
+	for k := 0; k < numGaussians; k++ {
+		i := ch + k*numChannels
+		data[i] += offset
+		avg += int32(data[i]) * int32(weights[i])
+	}
+	return avg
+}
+
+// gaussianProbability returns (1/s) * exp(-(x-m)^2 / (2*s^2)) in Q20 for
+// input x (Q4), mean m and std s (Q7), and sets delta = (x-m)/s^2 (Q11).
+func gaussianProbability(input, mean, std int16, delta *int16) int32 {
+	const (
+		compVar = 22005
+		log2Exp = 5909 // log2(e) in Q12
+	)
+	invStd := int16(divW32W16(131072+int32(std>>1), std)) // Q10
+	t16 := invStd >> 2
+	invStd2 := int16((int32(t16) * int32(t16)) >> 2) // Q14
+	t16 = input<<3 - mean                            // Q7
+	*delta = int16((int32(invStd2) * int32(t16)) >> 10)
+	t32 := (int32(*delta) * int32(t16)) >> 9 // Q10
+	var expValue int16
+	if t32 < compVar {
+		// exp(-t32) = exp2(-log2(e) * t32), in Q10.
+		t16 = -int16((log2Exp * t32) >> 12)
+		expValue = 0x0400 | (t16 & 0x03FF)
+		t16 = ^t16
+		t16 >>= 10
+		t16++
+		// Mask the shift as x86 does, where C leaves it undefined.
+		expValue = int16(int32(expValue) >> (uint16(t16) & 31))
+	}
+	return int32(invStd) * int32(expValue)
+}
+
+// findMinimum tracks the 16 smallest values of a channel's feature over
+// the last 100 frames and returns a smoothed median of the five smallest.
+func (v *VAD) findMinimum(feature int16, ch int) int16 {
+	const (
+		smoothingDown = 6553  // 0.2 in Q15
+		smoothingUp   = 32439 // 0.99 in Q15
+	)
+	age := v.ageVector[ch*16 : ch*16+16]
+	smallest := v.lowValueVector[ch*16 : ch*16+16]
+
+	// Age the values and drop those older than 100 frames.
+	for i := 0; i < 16; i++ {
+		if age[i] != 100 {
+			age[i]++
+		} else {
+			copy(smallest[i:15], smallest[i+1:])
+			copy(age[i:15], age[i+1:])
+			age[15] = 101
+			smallest[15] = 10000
+		}
+	}
+	// smallest is sorted: insert feature before the first larger value.
+	for pos := 0; pos < 16; pos++ {
+		if feature < smallest[pos] {
+			copy(smallest[pos+1:], smallest[pos:15])
+			copy(age[pos+1:], age[pos:15])
+			smallest[pos] = feature
+			age[pos] = 1
+			break
+		}
+	}
+
+	median := int16(1600)
+	if v.frameCounter > 2 {
+		median = smallest[2]
+	} else if v.frameCounter > 0 {
+		median = smallest[0]
+	}
+	var alpha int32
+	if v.frameCounter > 0 {
+		if median < v.meanValue[ch] {
+			alpha = smoothingDown
+		} else {
+			alpha = smoothingUp
+		}
+	}
+	t := (alpha+1)*int32(v.meanValue[ch]) + (32767-alpha)*int32(median) + 16384
+	v.meanValue[ch] = int16(t >> 15)
+	return v.meanValue[ch]
+}
@@ -0,0 +1,165 @@
+package webrtcvad
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+var (
+	rates        = []int{8000, 16000}
+	frameLengths = []int{80, 160, 240, 320, 480, 640, 960}
+)
+
+func validFrame(rate, n int) bool {
+	return n == rate/100 || n == rate/50 || n == rate*3/100
+}
+
+// TestProcessUpstream runs the checks of WebRTC's own vad_unittest: a
+// silent frame is not speech, and its (wrapping) i*i signal is speech in
+// every mode, rate and frame length.
+func TestProcessUpstream(t *testing.T) {
+	var zeros, speech [960]int16
+	for i := range speech {
+		speech[i] = int16(i * i)
+	}
+	v, err := New(Quality)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := v.Process(8000, zeros[:80]); got || err != nil {
+		t.Errorf("zeros: Process() = %v, %v", got, err)
+	}
+	for _, mode := range []Mode{Quality, LowBitrate, Aggressive, VeryAggressive} {
+		if err := v.SetMode(mode); err != nil {
+			t.Fatal(err)
+		}
+		for _, rate := range rates {
+			for _, n := range frameLengths {
+				if !validFrame(rate, n) {
+					continue
+				}
+				if got, err := v.Process(rate, speech[:n]); !got || err != nil {
+					t.Errorf("mode %d, %d Hz, %d samples: Process() = %v, %v", mode, rate, n, got, err)
+				}
+			}
+		}
+	}
+}
+
+func TestProcessErrors(t *testing.T) {
+	v, _ := New(Quality)
+	frame := make([]int16, 960)
+	for _, rate := range []int{8000, 16000, 32000, 48000, 0} {
+		for _, n := range append(frameLengths, 0, 100) {
+			_, err := v.Process(rate, frame[:n])
+			if valid := (rate == 8000 || rate == 16000) && validFrame(rate, n); valid != (err == nil) {
+				t.Errorf("%d Hz, %d samples: error %v", rate, n, err)
+			}
+		}
+	}
+	for _, mode := range []Mode{-1, 4} {
+		if _, err := New(mode); err == nil {
+			t.Errorf("New(%d) accepted", mode)
+		}
+		if err := v.SetMode(mode); err == nil {
+			t.Errorf("SetMode(%d) accepted", mode)
+		}
+	}
+}
+
+// signal returns 10 ms frames at 16 kHz: a second of noise, then
+// alternating half-seconds of a voiced tone and noise.
+func signal(seed uint64, noise float64) [][]int16 {
+	rng := rand.New(rand.NewPCG(seed, 1))
+	var frames [][]int16
+	for f := 0; f < 300; f++ {
+		voiced := f >= 100 && (f-100)/50%2 == 0
+		frame := make([]int16, 160)
+		for i := range frame {
+			v := noise * rng.NormFloat64()
+			if voiced {
+				t := float64(f*160+i) / 16000
+				for h := 1.0; h <= 6; h++ {
+					v += 3000 / h * math.Sin(2*math.Pi*h*140*t)
+				}
+			}
+			frame[i] = int16(max(min(v, 32767), -32768))
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+func decisions(t *testing.T, v *VAD, frames [][]int16) []bool {
+	t.Helper()
+	out := make([]bool, len(frames))
+	for i, f := range frames {
+		var err error
+		if out[i], err = v.Process(16000, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return out
+}
+
+func TestVoicedAndNoise(t *testing.T) {
+	frames := signal(1, 300)
+	v, _ := New(Aggressive)
+	got := decisions(t, v, frames)
+	var voicedHits, noiseHits, voiced, noise int
+	for f, speech := range got {
+		if f >= 100 && (f-100)/50%2 == 0 {
+			voiced++
+			if speech {
+				voicedHits++
+			}
+		} else if f >= 100 && (f-100)%50 >= 15 {
+			// Past the hangover of the voiced stretch before.
+			noise++
+			if speech {
+				noiseHits++
+			}
+		}
+	}
+	if voicedHits < voiced*9/10 || noiseHits != 0 {
+		t.Errorf("speech in %d of %d voiced and %d of %d noise frames", voicedHits, voiced, noiseHits, noise)
+	}
+}
+
+// TestModes checks that higher modes call noise speech no more often.
+func TestModes(t *testing.T) {
+	frames := signal(2, 2000)
+	prev := math.MaxInt
+	for _, mode := range []Mode{Quality, LowBitrate, Aggressive, VeryAggressive} {
+		v, _ := New(mode)
+		n := 0
+		for _, speech := range decisions(t, v, frames) {
+			if speech {
+				n++
+			}
+		}
+		if n > prev {
+			t.Errorf("mode %d: %d speech frames, more than the previous mode's %d", mode, n, prev)
+		}
+		prev = n
+	}
+}
+
+// TestReset checks that Reset restores the initial models and keeps the
+// mode.
+func TestReset(t *testing.T) {
+	frames := signal(3, 500)
+	v, _ := New(Aggressive)
+	want := decisions(t, v, frames)
+	v.Reset()
+	got := decisions(t, v, frames)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("frame %d after Reset: %v, want %v", i, got[i], want[i])
+		}
+	}
+	if allocs := testing.AllocsPerRun(100, func() { _, _ = v.Process(16000, frames[150]) }); allocs != 0 {
+		t.Errorf("Process allocates %v times per frame", allocs)
+	}
+}
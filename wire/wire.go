@@ -0,0 +1,529 @@
+// Package wire is a compact binary protocol for streaming audio to an
+// engine and its events back, for media servers running many channels over
+// one WebSocket or TCP connection, where JSON encoding would dominate the
+// cost of a 32 ms chunk.
+//
+// A stream is a sequence of frames, each a one-byte FrameType, a uint32
+// payload length, and the payload; all integers are little-endian. The
+// client sends PCM frames of one engine chunk each (s16le or f32le
+// samples); the server answers each with the chunk's events, one event
+// frame per Event:
+//
+//	kind   u8     see the Kind* constants
+//	time   i64    Event.Time in Unix nanoseconds, 0 when unset
+//	fields ...    by kind, below
+//
+// Fields: uvarints for counts, IDs and offsets (varints when signed),
+// float32 for probabilities, float64 for RTF, nanosecond varints for
+// durations, u8 for booleans and reasons, and uvarint-prefixed UTF-8 for
+// text. Segment audio is not sent back; a SegmentReady frame carries its
+// length.
+//
+// A server loop over one connection:
+//
+//	engine.Start()
+//	err := wire.Stream(engine, conn, conn)
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+)
+
+// FrameType identifies a frame.
+type FrameType uint8
+
+const (
+	FramePCM16     FrameType = 1 // client: s16le samples
+	FramePCMF32    FrameType = 2 // client: f32le samples in [-1, 1]
+	FrameEvent     FrameType = 3 // server: one event record
+	FrameChunkDone FrameType = 4 // server: all events of a PCM frame were sent
+)
+
+func (t FrameType) String() string {
+	switch t {
+	case FramePCM16:
+		return "PCM16"
+	case FramePCMF32:
+		return "PCMF32"
+	case FrameEvent:
+		return "Event"
+	case FrameChunkDone:
+		return "ChunkDone"
+	}
+	return "FrameType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// Event kinds on the wire. They are fixed by the protocol, independent of
+// the values of smartturn.EventKind.
+const (
+	KindSpeechStart      = 1
+	KindTurnStart        = 2
+	KindSpeechEnd        = 3
+	KindSegmentReady     = 4
+	KindTurnPrediction   = 5
+	KindTurnSplit        = 6
+	KindTurnMerged       = 7
+	KindTranscript       = 8
+	KindWakeWord         = 9
+	KindDTMF             = 10
+	KindSpeakerChange    = 11
+	KindShadowPrediction = 12
+	KindOverload         = 13
+	KindError            = 14
+	KindAudioEvicted     = 15
+)
+
+var kinds = map[smartturn.EventKind]byte{
+	smartturn.EventSpeechStart:      KindSpeechStart,
+	smartturn.EventTurnStart:        KindTurnStart,
+	smartturn.EventSpeechEnd:        KindSpeechEnd,
+	smartturn.EventSegmentReady:     KindSegmentReady,
+	smartturn.EventTurnPrediction:   KindTurnPrediction,
+	smartturn.EventTurnSplit:        KindTurnSplit,
+	smartturn.EventTurnMerged:       KindTurnMerged,
+	smartturn.EventTranscript:       KindTranscript,
+	smartturn.EventWakeWord:         KindWakeWord,
+	smartturn.EventDTMF:             KindDTMF,
+	smartturn.EventSpeakerChange:    KindSpeakerChange,
+	smartturn.EventShadowPrediction: KindShadowPrediction,
+	smartturn.EventOverload:         KindOverload,
+	smartturn.EventError:            KindError,
+	smartturn.EventAudioEvicted:     KindAudioEvicted,
+}
+
+var eventKinds = func() map[byte]smartturn.EventKind {
+	m := make(map[byte]smartturn.EventKind, len(kinds))
+	for k, v := range kinds {
+		m[v] = k
+	}
+	return m
+}()
+
+// MaxPayload bounds the payload length a Reader accepts.
+const MaxPayload = 1 << 20
+
+// ErrFormat is returned by Reader for input that is not a valid frame.
+var ErrFormat = errors.New("wire: invalid format")
+
+// Frame is a decoded frame; the fields of its Type are set.
+type Frame struct {
+	Type FrameType
+	// PCM holds the samples of a PCM frame, converted to float32. It is
+	// reused by the next ReadFrame.
+	PCM []float32
+	// Event is the event of an event frame. Its Segment is nil (see
+	// SegmentSamples) and Err, for KindError, carries only the message.
+	Event          smartturn.Event
+	SegmentSamples int
+}
+
+// Writer encodes frames to w, one Write call per frame. It is not safe for
+// concurrent use.
+type Writer struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewWriter returns a Writer encoding to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WritePCM16 sends chunk as s16le samples, clamping to [-1, 1].
+func (w *Writer) WritePCM16(chunk []float32) error {
+	w.buf = header(w.buf[:0], FramePCM16)
+	for _, v := range chunk {
+		v = max(-1, min(1, v))
+		w.buf = binary.LittleEndian.AppendUint16(w.buf, uint16(int16(v*32767)))
+	}
+	return w.flush()
+}
+
+// WritePCMF32 sends chunk as f32le samples.
+func (w *Writer) WritePCMF32(chunk []float32) error {
+	w.buf = header(w.buf[:0], FramePCMF32)
+	for _, v := range chunk {
+		w.buf = binary.LittleEndian.AppendUint32(w.buf, math.Float32bits(v))
+	}
+	return w.flush()
+}
+
+// WriteEvent sends ev as an event frame. Events of kinds the protocol does
+// not know are skipped.
+func (w *Writer) WriteEvent(ev smartturn.Event) error {
+	kind, ok := kinds[ev.Kind]
+	if !ok {
+		return nil
+	}
+	b := append(header(w.buf[:0], FrameEvent), kind)
+	var ts int64
+	if !ev.Time.IsZero() {
+		ts = ev.Time.UnixNano()
+	}
+	b = binary.LittleEndian.AppendUint64(b, uint64(ts))
+	switch ev.Kind {
+	case smartturn.EventTurnStart:
+		t := ev.TurnStart
+		b = binary.AppendUvarint(b, uint64(t.ID))
+		b = binary.AppendVarint(b, t.Offset)
+		b = binary.AppendVarint(b, int64(t.Padding))
+	case smartturn.EventSpeechEnd:
+		b = append(b, byte(ev.EndReason))
+	case smartturn.EventSegmentReady:
+		b = binary.AppendUvarint(b, uint64(len(ev.Segment)))
+	case smartturn.EventTurnPrediction:
+		p := ev.Prediction
+		b = append(b, boolByte(p.Complete), byte(p.Verdict))
+		b = appendFloat32(b, p.Probability)
+		b = appendFloat32(b, p.Instant)
+		b = appendFloat32(b, p.Logit)
+		b = binary.AppendVarint(b, int64(p.InferenceDuration))
+	case smartturn.EventTurnSplit:
+		s := ev.Split
+		b = binary.AppendUvarint(b, uint64(s.Part))
+		b = binary.AppendUvarint(b, uint64(s.Samples))
+		b = binary.AppendUvarint(b, uint64(s.OverlapSamples))
+	case smartturn.EventTurnMerged:
+		b = binary.AppendVarint(b, int64(ev.Merge.Gap))
+	case smartturn.EventTranscript:
+		t := ev.Transcript
+		b = binary.AppendUvarint(b, uint64(t.Turn))
+		b = binary.AppendVarint(b, t.Start)
+		b = binary.AppendVarint(b, t.End)
+		b = append(b, byte(t.Reason))
+		b = appendString(b, t.Text)
+	case smartturn.EventDTMF:
+		b = binary.AppendUvarint(b, uint64(ev.Digit))
+	case smartturn.EventSpeakerChange:
+		c := ev.Speaker
+		b = binary.AppendVarint(b, int64(c.From))
+		b = binary.AppendVarint(b, int64(c.To))
+		b = binary.AppendVarint(b, c.Offset)
+	case smartturn.EventShadowPrediction:
+		s := ev.Shadow
+		b = append(b, boolByte(s.Complete))
+		b = appendFloat32(b, s.Primary.Probability)
+		b = appendFloat32(b, s.Probability)
+		b = appendFloat32(b, s.Logit)
+	case smartturn.EventOverload:
+		o := ev.Overload
+		b = append(b, boolByte(o.Overloaded), boolByte(o.Shedding))
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(o.RTF))
+	case smartturn.EventError:
+		var msg string
+		if ev.Err != nil {
+			msg = ev.Err.Error()
+		}
+		b = appendString(b, msg)
+	case smartturn.EventAudioEvicted:
+		v := ev.Eviction
+		b = binary.AppendUvarint(b, uint64(v.Turn))
+		b = binary.AppendVarint(b, v.Offset)
+		b = binary.AppendVarint(b, int64(v.Budget))
+	}
+	w.buf = b
+	return w.flush()
+}
+
+// WriteChunkDone marks the end of the events of one PCM frame, so a client
+// can match answers to the chunks it sent.
+func (w *Writer) WriteChunkDone() error {
+	w.buf = header(w.buf[:0], FrameChunkDone)
+	return w.flush()
+}
+
+// header starts a frame; flush fills in its length.
+func header(b []byte, t FrameType) []byte {
+	return append(b, byte(t), 0, 0, 0, 0)
+}
+
+func (w *Writer) flush() error {
+	binary.LittleEndian.PutUint32(w.buf[1:5], uint32(len(w.buf)-5))
+	_, err := w.w.Write(w.buf)
+	return err
+}
+
+// Reader decodes frames from r. It is not safe for concurrent use.
+type Reader struct {
+	r       io.Reader
+	head    [5]byte
+	payload []byte
+	pcm     []float32
+}
+
+// NewReader returns a Reader decoding from r; wrap r in a bufio.Reader
+// when it is unbuffered.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// ReadFrame decodes the next frame. It returns io.EOF at a clean end of the
+// stream and an error wrapping ErrFormat for malformed input.
+func (r *Reader) ReadFrame() (Frame, error) {
+	if _, err := io.ReadFull(r.r, r.head[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Frame{}, fmt.Errorf("%w: truncated", ErrFormat)
+		}
+		return Frame{}, err
+	}
+	f := Frame{Type: FrameType(r.head[0])}
+	n := binary.LittleEndian.Uint32(r.head[1:])
+	if n > MaxPayload {
+		return Frame{}, fmt.Errorf("%w: %d-byte payload", ErrFormat, n)
+	}
+	if cap(r.payload) < int(n) {
+		r.payload = make([]byte, n)
+	}
+	p := r.payload[:n]
+	if _, err := io.ReadFull(r.r, p); err != nil {
+		return Frame{}, corrupt(err)
+	}
+	switch f.Type {
+	case FramePCM16:
+		if len(p)%2 != 0 {
+			return Frame{}, fmt.Errorf("%w: odd PCM16 payload", ErrFormat)
+		}
+		f.PCM = r.samples(len(p) / 2)
+		for i := range f.PCM {
+			f.PCM[i] = float32(int16(binary.LittleEndian.Uint16(p[2*i:]))) / 32768
+		}
+	case FramePCMF32:
+		if len(p)%4 != 0 {
+			return Frame{}, fmt.Errorf("%w: PCMF32 payload not a multiple of 4", ErrFormat)
+		}
+		f.PCM = r.samples(len(p) / 4)
+		for i := range f.PCM {
+			f.PCM[i] = math.Float32frombits(binary.LittleEndian.Uint32(p[4*i:]))
+		}
+	case FrameEvent:
+		d := decoder{b: p}
+		d.event(&f)
+		if d.err != nil {
+			return Frame{}, d.err
+		}
+	case FrameChunkDone:
+	default:
+		return Frame{}, fmt.Errorf("%w: unknown frame type %d", ErrFormat, f.Type)
+	}
+	return f, nil
+}
+
+func (r *Reader) samples(n int) []float32 {
+	if cap(r.pcm) < n {
+		r.pcm = make([]float32, n)
+	}
+	return r.pcm[:n]
+}
+
+// decoder reads the fields of an event payload, keeping the first error.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) event(f *Frame) {
+	kind, ok := eventKinds[d.byte()]
+	ts := int64(d.uint64())
+	if d.err != nil {
+		return
+	}
+	if !ok {
+		d.err = fmt.Errorf("%w: unknown event kind", ErrFormat)
+		return
+	}
+	ev := &f.Event
+	ev.Kind = kind
+	if ts != 0 {
+		ev.Time = time.Unix(0, ts)
+	}
+	switch kind {
+	case smartturn.EventTurnStart:
+		ev.TurnStart.ID = int(d.uvarint())
+		ev.TurnStart.Offset = d.varint()
+		ev.TurnStart.Padding = time.Duration(d.varint())
+		ev.TurnStart.Time = ev.Time
+	case smartturn.EventSpeechEnd:
+		ev.EndReason = smartturn.TurnEndReason(d.byte())
+	case smartturn.EventSegmentReady:
+		f.SegmentSamples = int(d.uvarint())
+	case smartturn.EventTurnPrediction:
+		p := &ev.Prediction
+		p.Complete = d.byte() != 0
+		p.Verdict = smartturn.TurnVerdict(d.byte())
+		p.Probability = d.float32()
+		p.Instant = d.float32()
+		p.Logit = d.float32()
+		p.InferenceDuration = time.Duration(d.varint())
+	case smartturn.EventTurnSplit:
+		ev.Split.Part = int(d.uvarint())
+		ev.Split.Samples = int(d.uvarint())
+		ev.Split.OverlapSamples = int(d.uvarint())
+	case smartturn.EventTurnMerged:
+		ev.Merge.Gap = time.Duration(d.varint())
+	case smartturn.EventTranscript:
+		t := &ev.Transcript
+		t.Turn = int(d.uvarint())
+		t.Start = d.varint()
+		t.End = d.varint()
+		t.Reason = smartturn.TurnEndReason(d.byte())
+		t.Text = d.string()
+	case smartturn.EventDTMF:
+		ev.Digit = rune(d.uvarint())
+	case smartturn.EventSpeakerChange:
+		ev.Speaker.From = int(d.varint())
+		ev.Speaker.To = int(d.varint())
+		ev.Speaker.Offset = d.varint()
+	case smartturn.EventShadowPrediction:
+		s := &ev.Shadow
+		s.Complete = d.byte() != 0
+		s.Primary.Probability = d.float32()
+		s.Probability = d.float32()
+		s.Logit = d.float32()
+	case smartturn.EventOverload:
+		ev.Overload.Overloaded = d.byte() != 0
+		ev.Overload.Shedding = d.byte() != 0
+		ev.Overload.RTF = math.Float64frombits(d.uint64())
+	case smartturn.EventError:
+		ev.Err = errors.New(d.string())
+	case smartturn.EventAudioEvicted:
+		ev.Eviction.Turn = int(d.uvarint())
+		ev.Eviction.Offset = d.varint()
+		ev.Eviction.Budget = time.Duration(d.varint())
+	}
+	if d.err == nil && len(d.b) != 0 {
+		d.err = fmt.Errorf("%w: %d trailing bytes in event", ErrFormat, len(d.b))
+	}
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: truncated event", ErrFormat)
+	}
+	d.b = nil
+}
+
+func (d *decoder) byte() byte {
+	if len(d.b) < 1 {
+		d.fail()
+		return 0
+	}
+	v := d.b[0]
+	d.b = d.b[1:]
+	return v
+}
+
+func (d *decoder) uint64() uint64 {
+	if len(d.b) < 8 {
+		d.fail()
+		return 0
+	}
+	v := binary.LittleEndian.Uint64(d.b)
+	d.b = d.b[8:]
+	return v
+}
+
+func (d *decoder) float32() float32 {
+	if len(d.b) < 4 {
+		d.fail()
+		return 0
+	}
+	v := math.Float32frombits(binary.LittleEndian.Uint32(d.b))
+	d.b = d.b[4:]
+	return v
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if n > uint64(len(d.b)) {
+		d.fail()
+		return ""
+	}
+	s := string(d.b[:n])
+	d.b = d.b[n:]
+	return s
+}
+
+func appendFloat32(b []byte, v float32) []byte {
+	return binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(binary.AppendUvarint(b, uint64(len(s))), s...)
+}
+
+func boolByte(v bool) byte {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func corrupt(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: truncated", ErrFormat)
+	}
+	return err
+}
+
+// Stream serves one connection: it reads PCM frames from r, runs each
+// through e.Process, and writes the chunk's events to w followed by a
+// ChunkDone frame. Each PCM frame must hold exactly one chunk of
+// Config.ChunkSize samples at Config.SampleRate; e must be started and
+// must not use Config.InputQueue. Stream returns nil when r ends cleanly,
+// and otherwise the first read, Process, or write error. Frames of other
+// types from the client are rejected.
+func Stream(e *smartturn.Engine, r io.Reader, w io.Writer) error {
+	rd, wr := NewReader(r), NewWriter(w)
+	for {
+		f, err := rd.ReadFrame()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if f.Type != FramePCM16 && f.Type != FramePCMF32 {
+			return fmt.Errorf("%w: unexpected %v frame from client", ErrFormat, f.Type)
+		}
+		events, err := e.Process(f.PCM)
+		if err != nil {
+			return fmt.Errorf("wire: %w", err)
+		}
+		for _, ev := range events {
+			if err := wr.WriteEvent(ev); err != nil {
+				return err
+			}
+		}
+		if err := wr.WriteChunkDone(); err != nil {
+			return err
+		}
+	}
+}
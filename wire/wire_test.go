@@ -0,0 +1,417 @@
+package wire_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexswarm/smart-turn-go"
+	"github.com/cortexswarm/smart-turn-go/smartturntest"
+	"github.com/cortexswarm/smart-turn-go/wire"
+)
+
+var start = time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+
+// newEngine returns a started engine with energy VAD, a scripted Smart-Turn
+// backend predicting 0.9 and a fixed clock, so two engines fed the same
+// audio give the same events.
+func newEngine(t *testing.T) *smartturn.Engine {
+	t.Helper()
+	e, err := smartturn.New(smartturn.Config{
+		SampleRate:             smartturn.RequiredSampleRate,
+		ChunkSize:              smartturn.RequiredChunkSize,
+		VadThreshold:           0.5,
+		VadPreSpeechMs:         200,
+		VadStopMs:              300,
+		TurnMaxDurationSeconds: 600,
+		TurnSegmentEmitMs:      1000,
+		TurnThreshold:          0.5,
+		TurnTimeoutMs:          1000,
+		VADBackend:             &smartturntest.EnergyVAD{},
+		TurnBackend:            &smartturntest.TurnScript{Probabilities: []float32{0.9}},
+		Clock:                  smartturntest.NewClock(start),
+	}, smartturn.Callbacks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Start()
+	t.Cleanup(e.Close)
+	return e
+}
+
+// carried returns the fields of ev that an event frame carries, as Reader
+// decodes them, with Time left to the caller.
+func carried(ev smartturn.Event) smartturn.Event {
+	out := smartturn.Event{
+		Kind:       ev.Kind,
+		TurnStart:  ev.TurnStart,
+		EndReason:  ev.EndReason,
+		Split:      ev.Split,
+		Merge:      ev.Merge,
+		Transcript: ev.Transcript,
+		Digit:      ev.Digit,
+		Speaker:    ev.Speaker,
+		Overload:   ev.Overload,
+		Eviction:   ev.Eviction,
+	}
+	out.TurnStart.Time = time.Time{}
+	p := ev.Prediction
+	out.Prediction = smartturn.TurnPrediction{
+		Complete: p.Complete, Verdict: p.Verdict,
+		Probability: p.Probability, Instant: p.Instant, Logit: p.Logit,
+		InferenceDuration: p.InferenceDuration,
+	}
+	s := ev.Shadow
+	out.Shadow = smartturn.ShadowPrediction{
+		Complete: s.Complete, Probability: s.Probability, Logit: s.Logit,
+		Primary: smartturn.TurnPrediction{Probability: s.Primary.Probability},
+	}
+	if ev.Kind == smartturn.EventError {
+		out.Err = errors.New(ev.Err.Error())
+	}
+	return out
+}
+
+// checkEvent compares a decoded event frame with the event it encodes.
+func checkEvent(t *testing.T, f wire.Frame, want smartturn.Event) {
+	t.Helper()
+	if f.Type != wire.FrameEvent {
+		t.Fatalf("%v frame, want an event for %v", f.Type, want.Kind)
+	}
+	if !f.Event.Time.Equal(want.Time) {
+		t.Errorf("%v: Time %v, want %v", want.Kind, f.Event.Time, want.Time)
+	}
+	if want.Kind == smartturn.EventTurnStart && !f.Event.TurnStart.Time.Equal(want.Time) {
+		t.Errorf("TurnStart.Time %v, want the event time %v", f.Event.TurnStart.Time, want.Time)
+	}
+	if f.SegmentSamples != len(want.Segment) {
+		t.Errorf("%v: %d segment samples, want %d", want.Kind, f.SegmentSamples, len(want.Segment))
+	}
+	got := f.Event
+	got.Time, got.TurnStart.Time = time.Time{}, time.Time{}
+	if w := carried(want); !reflect.DeepEqual(got, w) {
+		t.Errorf("decoded %+v, want %+v", got, w)
+	}
+}
+
+// quantize returns chunk as the server decodes it from a PCM16 frame.
+func quantize(chunk []float32) []float32 {
+	out := make([]float32, len(chunk))
+	for i, v := range chunk {
+		out[i] = float32(int16(max(-1, min(1, v))*32767)) / 32768
+	}
+	return out
+}
+
+// TestStream checks that Stream answers each PCM frame with the events
+// Process gives for the chunk, then a ChunkDone frame.
+func TestStream(t *testing.T) {
+	audio, _ := smartturntest.Synth{Seed: 1}.Generate(
+		smartturntest.Silence(500*time.Millisecond), smartturntest.Speech(time.Second),
+		smartturntest.Silence(600*time.Millisecond), smartturntest.Speech(1500*time.Millisecond),
+		smartturntest.Silence(600*time.Millisecond))
+	chunks := smartturntest.Chunks(audio)
+	for _, tc := range []struct {
+		name  string
+		write func(*wire.Writer, []float32) error
+		// decoded is the chunk the server sees.
+		decoded func([]float32) []float32
+	}{
+		{"f32le", (*wire.Writer).WritePCMF32, func(c []float32) []float32 { return c }},
+		{"s16le", (*wire.Writer).WritePCM16, quantize},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var in, out bytes.Buffer
+			w := wire.NewWriter(&in)
+			for _, c := range chunks {
+				if err := tc.write(w, c); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := wire.Stream(newEngine(t), &in, &out); err != nil {
+				t.Fatalf("Stream: %v", err)
+			}
+
+			ref := newEngine(t)
+			r := wire.NewReader(&out)
+			seen := map[smartturn.EventKind]int{}
+			for i, c := range chunks {
+				events, err := ref.Process(tc.decoded(c))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, ev := range events {
+					f, err := r.ReadFrame()
+					if err != nil {
+						t.Fatalf("chunk %d: %v", i, err)
+					}
+					checkEvent(t, f, ev)
+					seen[ev.Kind]++
+				}
+				if f, err := r.ReadFrame(); err != nil || f.Type != wire.FrameChunkDone {
+					t.Fatalf("chunk %d: %v frame, %v; want ChunkDone", i, f.Type, err)
+				}
+			}
+			if _, err := r.ReadFrame(); err != io.EOF {
+				t.Errorf("after the last ChunkDone: %v, want io.EOF", err)
+			}
+			for _, k := range []smartturn.EventKind{
+				smartturn.EventSpeechStart, smartturn.EventTurnStart, smartturn.EventSegmentReady,
+				smartturn.EventTurnPrediction, smartturn.EventSpeechEnd,
+			} {
+				if seen[k] == 0 {
+					t.Errorf("no %v events in %v", k, seen)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	var events bytes.Buffer
+	wire.NewWriter(&events).WriteChunkDone()
+	var short bytes.Buffer
+	wire.NewWriter(&short).WritePCMF32(make([]float32, 100))
+	for _, tc := range []struct {
+		name   string
+		in     []byte
+		is     error
+		substr string
+	}{
+		{"server frame from client", events.Bytes(), wire.ErrFormat, "ChunkDone"},
+		{"wrong chunk size", short.Bytes(), nil, "wire: "},
+		{"truncated", []byte{byte(wire.FramePCMF32), 8, 0, 0, 0, 1}, wire.ErrFormat, "truncated"},
+	} {
+		err := wire.Stream(newEngine(t), bytes.NewReader(tc.in), io.Discard)
+		if err == nil || (tc.is != nil && !errors.Is(err, tc.is)) || !strings.Contains(err.Error(), tc.substr) {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+	}
+
+	// Write errors end the stream, of event frames as of ChunkDone ones.
+	speech, _ := smartturntest.Synth{Seed: 1}.Generate(smartturntest.Speech(time.Second))
+	var in bytes.Buffer
+	w := wire.NewWriter(&in)
+	for _, c := range smartturntest.Chunks(speech) {
+		w.WritePCMF32(c)
+	}
+	failed := errors.New("write failed")
+	for _, typ := range []wire.FrameType{wire.FrameEvent, wire.FrameChunkDone} {
+		err := wire.Stream(newEngine(t), bytes.NewReader(in.Bytes()), failingWriter{typ, failed})
+		if !errors.Is(err, failed) {
+			t.Errorf("%v write error: %v", typ, err)
+		}
+	}
+}
+
+// failingWriter fails writes of frames of one type.
+type failingWriter struct {
+	typ wire.FrameType
+	err error
+}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	if wire.FrameType(p[0]) == w.typ {
+		return 0, w.err
+	}
+	return len(p), nil
+}
+
+// TestEventRoundTrip checks every event kind through Writer and Reader.
+func TestEventRoundTrip(t *testing.T) {
+	pred := smartturn.TurnPrediction{
+		Complete: true, Probability: 0.75, Instant: 0.8, Logit: 1.1,
+		InferenceDuration: 12 * time.Millisecond, Verdict: smartturn.VerdictIncomplete,
+		// Not carried.
+		QueueWait: time.Millisecond, SilenceBeforeDecision: 300 * time.Millisecond,
+	}
+	events := []smartturn.Event{
+		{Kind: smartturn.EventSpeechStart},
+		{Kind: smartturn.EventTurnStart, TurnStart: smartturn.TurnStart{ID: 3, Offset: 48000, Padding: 192 * time.Millisecond}},
+		{Kind: smartturn.EventSpeechEnd, EndReason: smartturn.TurnEndMaxDuration},
+		{Kind: smartturn.EventSegmentReady, Segment: make([]float32, 17000)},
+		{Kind: smartturn.EventTurnPrediction, Prediction: pred},
+		{Kind: smartturn.EventTurnSplit, Split: smartturn.TurnSplit{Part: 2, Samples: 160000, OverlapSamples: 3200}},
+		{Kind: smartturn.EventTurnMerged, Merge: smartturn.TurnMerge{Gap: 300 * time.Millisecond}},
+		{Kind: smartturn.EventTranscript, Transcript: smartturn.Transcript{
+			Turn: 4, Text: "héllo, wörld", Start: 1000, End: -1, Reason: smartturn.TurnEndTimeout}},
+		{Kind: smartturn.EventTranscript},
+		{Kind: smartturn.EventWakeWord},
+		{Kind: smartturn.EventDTMF, Digit: '#'},
+		{Kind: smartturn.EventSpeakerChange, Speaker: smartturn.SpeakerChange{From: -1, To: 2, Offset: 7}},
+		{Kind: smartturn.EventShadowPrediction, Shadow: smartturn.ShadowPrediction{
+			Primary: pred, Probability: 0.25, Logit: -1.1, Complete: true, InferenceDuration: time.Millisecond}},
+		{Kind: smartturn.EventOverload, Overload: smartturn.OverloadEvent{Overloaded: true, RTF: 1.25}},
+		{Kind: smartturn.EventOverload, Overload: smartturn.OverloadEvent{RTF: 0.5, Shedding: true}},
+		{Kind: smartturn.EventError, Err: errors.New("inference failed")},
+		{Kind: smartturn.EventAudioEvicted, Eviction: smartturn.AudioEviction{Turn: 1, Offset: 320000, Budget: 20 * time.Second}},
+	}
+	for i := range events {
+		// A zero Time stays zero; others keep the nanosecond.
+		if i%2 == 1 {
+			events[i].Time = start.Add(time.Duration(i)*time.Second + 123)
+		}
+	}
+	var buf bytes.Buffer
+	w := wire.NewWriter(&buf)
+	for _, ev := range events {
+		if err := w.WriteEvent(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Kinds the protocol does not know are skipped.
+	n := buf.Len()
+	if err := w.WriteEvent(smartturn.Event{Kind: smartturn.EventAudioEvicted + 1}); err != nil || buf.Len() != n {
+		t.Errorf("unknown kind: %v, %d bytes written", err, buf.Len()-n)
+	}
+
+	r := wire.NewReader(&buf)
+	for _, ev := range events {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("%v: %v", ev.Kind, err)
+		}
+		checkEvent(t, f, ev)
+	}
+	if _, err := r.ReadFrame(); err != io.EOF {
+		t.Errorf("after the last event: %v, want io.EOF", err)
+	}
+}
+
+// TestEventKinds checks the fixed wire codes of the event kinds.
+func TestEventKinds(t *testing.T) {
+	for kind, code := range map[smartturn.EventKind]byte{
+		smartturn.EventSpeechStart:      wire.KindSpeechStart,
+		smartturn.EventTurnStart:        wire.KindTurnStart,
+		smartturn.EventSpeechEnd:        wire.KindSpeechEnd,
+		smartturn.EventSegmentReady:     wire.KindSegmentReady,
+		smartturn.EventTurnPrediction:   wire.KindTurnPrediction,
+		smartturn.EventTurnSplit:        wire.KindTurnSplit,
+		smartturn.EventTurnMerged:       wire.KindTurnMerged,
+		smartturn.EventTranscript:       wire.KindTranscript,
+		smartturn.EventWakeWord:         wire.KindWakeWord,
+		smartturn.EventDTMF:             wire.KindDTMF,
+		smartturn.EventSpeakerChange:    wire.KindSpeakerChange,
+		smartturn.EventShadowPrediction: wire.KindShadowPrediction,
+		smartturn.EventOverload:         wire.KindOverload,
+		smartturn.EventError:            wire.KindError,
+		smartturn.EventAudioEvicted:     wire.KindAudioEvicted,
+	} {
+		var buf bytes.Buffer
+		wire.NewWriter(&buf).WriteEvent(smartturn.Event{Kind: kind, Err: errors.New("")})
+		if b := buf.Bytes(); len(b) < 6 || b[0] != byte(wire.FrameEvent) || b[5] != code {
+			t.Errorf("%v: frame % x, want kind %d", kind, b, code)
+		}
+	}
+}
+
+func TestPCM(t *testing.T) {
+	chunk := []float32{0, 0.5, -0.5, 1, -1, 1.5, -2, 1e-3}
+	var buf bytes.Buffer
+	w := wire.NewWriter(&buf)
+	w.WritePCM16(chunk)
+	want16 := []byte{byte(wire.FramePCM16), 16, 0, 0, 0,
+		0x00, 0x00, 0xff, 0x3f, 0x01, 0xc0, 0xff, 0x7f, 0x01, 0x80, 0xff, 0x7f, 0x01, 0x80, 0x20, 0x00}
+	if !bytes.Equal(buf.Bytes(), want16) {
+		t.Errorf("PCM16 frame % x, want % x", buf.Bytes(), want16)
+	}
+	w.WritePCMF32(chunk)
+	w.WritePCM16(nil)
+
+	r := wire.NewReader(&buf)
+	f, err := r.ReadFrame()
+	if err != nil || f.Type != wire.FramePCM16 {
+		t.Fatalf("%v frame, %v", f.Type, err)
+	}
+	if want := quantize(chunk); !reflect.DeepEqual(f.PCM, want) {
+		t.Errorf("PCM16 samples %v, want %v", f.PCM, want)
+	}
+	pcm16 := f.PCM
+	f, err = r.ReadFrame()
+	if err != nil || f.Type != wire.FramePCMF32 || !reflect.DeepEqual(f.PCM, chunk) {
+		t.Errorf("PCMF32 frame: %v %v, %v; want %v", f.Type, f.PCM, err, chunk)
+	}
+	if &f.PCM[0] != &pcm16[0] {
+		t.Error("ReadFrame did not reuse its sample buffer")
+	}
+	f, err = r.ReadFrame()
+	if err != nil || f.Type != wire.FramePCM16 || len(f.PCM) != 0 {
+		t.Errorf("empty PCM16 frame: %v %v, %v", f.Type, f.PCM, err)
+	}
+}
+
+func TestReadFrameErrors(t *testing.T) {
+	frame := func(typ wire.FrameType, payload ...byte) []byte {
+		n := len(payload)
+		return append([]byte{byte(typ), byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24)}, payload...)
+	}
+	// event returns an event frame of kind with a zero time and fields.
+	event := func(kind byte, fields ...byte) []byte {
+		return frame(wire.FrameEvent, append([]byte{kind, 0, 0, 0, 0, 0, 0, 0, 0}, fields...)...)
+	}
+	for _, tc := range []struct {
+		name string
+		in   []byte
+	}{
+		{"truncated header", []byte{byte(wire.FramePCM16), 2, 0}},
+		{"truncated payload", frame(wire.FramePCM16, 1, 2, 3, 4)[:7]},
+		{"missing payload", frame(wire.FramePCM16, 1, 2)[:5]},
+		{"oversized payload", []byte{byte(wire.FramePCM16), 1, 0, 0x10, 0}},
+		{"odd PCM16", frame(wire.FramePCM16, 1, 2, 3)},
+		{"PCMF32 not a multiple of 4", frame(wire.FramePCMF32, 1, 2, 3, 4, 5, 6)},
+		{"unknown frame type", frame(9)},
+		{"zero frame type", frame(0)},
+		{"empty event", frame(wire.FrameEvent)},
+		{"truncated time", frame(wire.FrameEvent, wire.KindSpeechStart, 0, 0, 0)},
+		{"unknown kind", event(99)},
+		{"zero kind", event(0)},
+		{"missing fields", event(wire.KindTurnStart)},
+		{"missing uvarint", event(wire.KindDTMF)},
+		{"truncated varint", event(wire.KindTurnMerged, 0x80)},
+		{"truncated float", event(wire.KindTurnPrediction, 1, 0, 0, 0)},
+		{"truncated RTF", event(wire.KindOverload, 1, 1, 0, 0, 0, 0)},
+		{"string past payload", event(wire.KindError, 5, 'a', 'b')},
+		{"trailing bytes", event(wire.KindSpeechStart, 0)},
+		{"trailing after fields", event(wire.KindDTMF, '#', 0)},
+	} {
+		_, err := wire.NewReader(bytes.NewReader(tc.in)).ReadFrame()
+		if !errors.Is(err, wire.ErrFormat) {
+			t.Errorf("%s: %v, want ErrFormat", tc.name, err)
+		}
+	}
+
+	// A frame of MaxPayload bytes is accepted.
+	big := frame(wire.FramePCMF32, make([]byte, wire.MaxPayload)...)
+	if f, err := wire.NewReader(bytes.NewReader(big)).ReadFrame(); err != nil || len(f.PCM) != wire.MaxPayload/4 {
+		t.Errorf("MaxPayload frame: %d samples, %v", len(f.PCM), err)
+	}
+	if _, err := wire.NewReader(bytes.NewReader(nil)).ReadFrame(); err != io.EOF {
+		t.Errorf("empty stream: %v, want io.EOF", err)
+	}
+	failed := errors.New("read failed")
+	if _, err := wire.NewReader(io.MultiReader(bytes.NewReader(frame(wire.FramePCM16, 1, 2)[:6]), failingReader{failed})).ReadFrame(); !errors.Is(err, failed) {
+		t.Errorf("read error: %v", err)
+	}
+}
+
+type failingReader struct{ err error }
+
+func (r failingReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestFrameTypeString(t *testing.T) {
+	for typ, want := range map[wire.FrameType]string{
+		wire.FramePCM16:     "PCM16",
+		wire.FramePCMF32:    "PCMF32",
+		wire.FrameEvent:     "Event",
+		wire.FrameChunkDone: "ChunkDone",
+		wire.FrameType(9):   "FrameType(9)",
+	} {
+		if got := typ.String(); got != want {
+			t.Errorf("FrameType(%d).String() = %q, want %q", uint8(typ), got, want)
+		}
+	}
+}